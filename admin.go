@@ -0,0 +1,229 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// adminAPI serves Config.AdminAddr, through which a running recorder is changed without a restart:
+//
+//	GET    /symbols                                recorded instruments and their streams
+//	PUT    /symbols/{symbol}?streams=a,b           start recording an instrument (every stream if none are given)
+//	DELETE /symbols/{symbol}                       stop recording an instrument, closing its files
+//	PUT    /symbols/{symbol}/streams/{name}        switch a WebSocket stream on
+//	DELETE /symbols/{symbol}/streams/{name}        switch a WebSocket stream off, keeping its file open
+//	PUT    /symbols/{symbol}/streams/{name}/debug  log the stream's frames as received (see FrameDebugger)
+//	DELETE /symbols/{symbol}/streams/{name}/debug  stop logging them
+//	GET    /debug                                  streams whose frames are logged
+//	POST   /symbols/{symbol}/snapshot              fetch an order book snapshot ahead of the schedule
+//	POST   /rotate?symbol=X                        finish the current files and continue in new part files
+//	GET    /recorders                              recorder status, as in Introspect
+//
+// Responses are JSON; errors are plain text with a 4xx or 5xx status. The API has no authentication, so bind it to
+// a loopback or otherwise private address.
+type adminAPI struct {
+	// ctx and cfg are Run's, used for instruments started through the API
+	ctx context.Context
+	cfg Config
+	env *pipelineEnv
+}
+
+func (a *adminAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /symbols", a.listSymbols)
+	mux.HandleFunc("PUT /symbols/{symbol}", a.addSymbol)
+	mux.HandleFunc("DELETE /symbols/{symbol}", a.removeSymbol)
+	mux.HandleFunc("PUT /symbols/{symbol}/streams/{stream}", a.setStream(true))
+	mux.HandleFunc("DELETE /symbols/{symbol}/streams/{stream}", a.setStream(false))
+	mux.HandleFunc("PUT /symbols/{symbol}/streams/{stream}/debug", a.setDebug(true))
+	mux.HandleFunc("DELETE /symbols/{symbol}/streams/{stream}/debug", a.setDebug(false))
+	mux.HandleFunc("GET /debug", a.debugStreams)
+	mux.HandleFunc("POST /symbols/{symbol}/snapshot", a.requestSnapshot)
+	mux.HandleFunc("POST /rotate", a.rotate)
+	mux.HandleFunc("GET /recorders", a.recorders)
+	return mux
+}
+
+func (a *adminAPI) listSymbols(w http.ResponseWriter, r *http.Request) {
+	a.env.mu.Lock()
+	statuses := make([]InstrumentStatus, 0, len(a.env.pipelines))
+	for _, p := range a.env.pipelines {
+		statuses = append(statuses, p.status())
+	}
+	a.env.mu.Unlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Instrument < statuses[j].Instrument })
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (a *adminAPI) addSymbol(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+	// Validated like a configured instrument, against the same market and intervals
+	cfg := a.cfg
+	cfg.Instruments = []string{symbol}
+	cfg.Streams = nil
+	if list := r.URL.Query().Get("streams"); list != "" {
+		cfg.Streams = map[string][]string{symbol: strings.Split(list, ",")}
+	}
+	if err := cfg.validateStreams(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.env.mu.Lock()
+	defer a.env.mu.Unlock()
+	if _, ok := a.env.pipelines[symbol]; ok {
+		http.Error(w, fmt.Sprintf("%s is already being recorded", symbol), http.StatusConflict)
+		return
+	}
+	if err := startInstrument(a.ctx, cfg, symbol, a.env); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.env.logger.Infof("Started recording %s through the admin API", symbol)
+	writeJSON(w, http.StatusCreated, a.env.pipelines[symbol].status())
+}
+
+func (a *adminAPI) removeSymbol(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+	a.env.mu.Lock()
+	defer a.env.mu.Unlock()
+	if err := stopInstrument(a.env, symbol); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	a.env.logger.Infof("Stopped recording %s through the admin API", symbol)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminAPI) setStream(on bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		symbol, stream := r.PathValue("symbol"), r.PathValue("stream")
+		p, ok := a.pipeline(w, symbol)
+		if !ok {
+			return
+		}
+		if err := p.setStream(stream, on, a.cfg, a.env); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		state := "off"
+		if on {
+			state = "on"
+		}
+		a.env.logger.Infof("Switched the %s stream of %s %s through the admin API", stream, symbol, state)
+		writeJSON(w, http.StatusOK, p.status())
+	}
+}
+
+func (a *adminAPI) setDebug(on bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		symbol, stream := r.PathValue("symbol"), r.PathValue("stream")
+		p, ok := a.pipeline(w, symbol)
+		if !ok {
+			return
+		}
+		key := listenerKey(stream)
+		if _, ok := p.listeners[key]; !ok {
+			http.Error(w, fmt.Sprintf("%s does not record the %s WebSocket stream", symbol, stream), http.StatusConflict)
+			return
+		}
+		DefaultFrameDebugger.Set(strings.ToLower(symbol)+"@"+key, on)
+		state := "off"
+		if on {
+			state = "on"
+		}
+		a.env.logger.Infof("Switched frame logging of the %s stream of %s %s through the admin API", stream, symbol, state)
+		writeJSON(w, http.StatusOK, DefaultFrameDebugger.Streams())
+	}
+}
+
+func (a *adminAPI) debugStreams(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, DefaultFrameDebugger.Streams())
+}
+
+func (a *adminAPI) requestSnapshot(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+	if _, ok := a.pipeline(w, symbol); !ok {
+		return
+	}
+	requested := false
+	for _, scheduler := range []*SnapshotScheduler{a.env.snapshots, a.env.topSnapshots} {
+		if scheduler != nil && scheduler.Has(symbol) {
+			scheduler.Request(symbol)
+			requested = true
+		}
+	}
+	if !requested {
+		http.Error(w, fmt.Sprintf("%s records no order book snapshots", symbol), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *adminAPI) rotate(w http.ResponseWriter, r *http.Request) {
+	a.env.mu.Lock()
+	var pipelines []*instrumentPipeline
+	if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+		p, ok := a.env.pipelines[symbol]
+		if !ok {
+			a.env.mu.Unlock()
+			http.Error(w, fmt.Sprintf("%s is not being recorded", symbol), http.StatusNotFound)
+			return
+		}
+		pipelines = append(pipelines, p)
+	} else {
+		for _, p := range a.env.pipelines {
+			pipelines = append(pipelines, p)
+		}
+	}
+	a.env.mu.Unlock()
+
+	var errs []error
+	var rotated []RecorderStats
+	for _, p := range pipelines {
+		for _, rec := range p.recorders {
+			if err := rec.Rotate(); err != nil {
+				stats := rec.Stats()
+				errs = append(errs, fmt.Errorf("failed to rotate %s %s: %w", stats.Instrument, stats.DataType, err))
+				continue
+			}
+			rotated = append(rotated, rec.Stats())
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		a.env.logger.Errorf("%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(rotated, func(i, j int) bool {
+		a, b := rotated[i], rotated[j]
+		return a.Instrument < b.Instrument || (a.Instrument == b.Instrument && a.DataType < b.DataType)
+	})
+	writeJSON(w, http.StatusOK, rotated)
+}
+
+func (a *adminAPI) recorders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Introspect().Recorders)
+}
+
+// pipeline returns the running pipeline of symbol, answering 404 if there is none.
+func (a *adminAPI) pipeline(w http.ResponseWriter, symbol string) (*instrumentPipeline, bool) {
+	a.env.mu.Lock()
+	defer a.env.mu.Unlock()
+	p, ok := a.env.pipelines[symbol]
+	if !ok {
+		http.Error(w, fmt.Sprintf("%s is not being recorded", symbol), http.StatusNotFound)
+	}
+	return p, ok
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,242 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+// startAdminRun starts Run with cfg and an admin API on a free local port, returning the API's base URL and a
+// function stopping Run.
+func startAdminRun(t *testing.T, cfg Config) (string, func()) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AdminAddr = l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+	base := "http://" + cfg.AdminAddr
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(base + "/symbols")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("admin API did not come up: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return base, func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	}
+}
+
+// adminRequest sends a request to the admin API, decoding a JSON response into out if it is non-nil.
+func adminRequest(t *testing.T, method, url string, wantStatus int, out interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s: expected status %d, got %d: %s", method, url, wantStatus, resp.StatusCode, body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			t.Fatalf("%s %s: failed to decode %s: %v", method, url, body, err)
+		}
+	}
+}
+
+// waitForRows blocks until the recorder of instrument and dataType has written n rows.
+func waitForRows(t *testing.T, instrument, dataType string, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, s := range Introspect().Recorders {
+			if s.Instrument == instrument && s.DataType == dataType && s.Rows >= n {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d %s rows of %s", n, dataType, instrument)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAdminAPI_ManagesInstrumentsWhileRecording(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("admausdt@trade", mockbinance.TradeMessage("ADMAUSDT", 1, "1.0", "1"))
+	srv.SetStream("admbusdt@trade",
+		mockbinance.TradeMessage("ADMBUSDT", 1, "2.0", "1"),
+		mockbinance.TradeMessage("ADMBUSDT", 2, "2.1", "1"))
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"ADMAUSDT"}
+	cfg.Streams = map[string][]string{"ADMAUSDT": {StreamTrade}}
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+	base, stop := startAdminRun(t, cfg)
+	defer stop()
+
+	var symbols []InstrumentStatus
+	adminRequest(t, http.MethodGet, base+"/symbols", http.StatusOK, &symbols)
+	want := []InstrumentStatus{{Instrument: "ADMAUSDT", Streams: []string{"trade"}, Listening: []string{"trade"}}}
+	if !reflect.DeepEqual(symbols, want) {
+		t.Fatalf("expected %+v, got %+v", want, symbols)
+	}
+
+	// Adding an instrument starts its pipelines without a restart
+	var added InstrumentStatus
+	adminRequest(t, http.MethodPut, base+"/symbols/ADMBUSDT?streams=trade", http.StatusCreated, &added)
+	if !reflect.DeepEqual(added.Listening, []string{"trade"}) {
+		t.Errorf("expected the new instrument to listen to trades, got %+v", added)
+	}
+	waitForRows(t, "ADMBUSDT", "trade", 2)
+	adminRequest(t, http.MethodPut, base+"/symbols/ADMBUSDT", http.StatusConflict, nil)
+	adminRequest(t, http.MethodPut, base+"/symbols/admbusdt", http.StatusBadRequest, nil)
+	adminRequest(t, http.MethodPut, base+"/symbols/ADMCUSDT?streams=trades", http.StatusBadRequest, nil)
+
+	// Switching a stream off keeps the instrument and its recorder
+	var status InstrumentStatus
+	adminRequest(t, http.MethodDelete, base+"/symbols/ADMAUSDT/streams/trade", http.StatusOK, &status)
+	if len(status.Listening) != 0 || !reflect.DeepEqual(status.Streams, []string{"trade"}) {
+		t.Errorf("expected the trade stream to be off, got %+v", status)
+	}
+	adminRequest(t, http.MethodPut, base+"/symbols/ADMAUSDT/streams/trade", http.StatusOK, &status)
+	if !reflect.DeepEqual(status.Listening, []string{"trade"}) {
+		t.Errorf("expected the trade stream to be back on, got %+v", status)
+	}
+	adminRequest(t, http.MethodPut, base+"/symbols/ADMAUSDT/streams/depth", http.StatusConflict, nil)
+	adminRequest(t, http.MethodPost, base+"/symbols/ADMAUSDT/snapshot", http.StatusConflict, nil)
+	adminRequest(t, http.MethodPost, base+"/symbols/NOPEUSDT/snapshot", http.StatusNotFound, nil)
+
+	// Rotation finishes the current file and continues in the next part
+	var rotated []RecorderStats
+	adminRequest(t, http.MethodPost, base+"/rotate?symbol=ADMBUSDT", http.StatusOK, &rotated)
+	if len(rotated) != 1 || rotated[0].Files != 1 || rotated[0].File != BuildPartFileName("trade", "ADMBUSDT", NowFunc(), 1) {
+		t.Errorf("expected ADMBUSDT's trades to continue in part 1, got %+v", rotated)
+	}
+	trades, err := ReadParquetFile[Trade](BuildFileName("trade", "ADMBUSDT", NowFunc()))
+	if err != nil || len(trades) != 2 {
+		t.Errorf("expected the rotated file to hold both trades, got %d (%v)", len(trades), err)
+	}
+
+	// Removing an instrument closes its recorders
+	adminRequest(t, http.MethodDelete, base+"/symbols/ADMBUSDT", http.StatusNoContent, nil)
+	adminRequest(t, http.MethodDelete, base+"/symbols/ADMBUSDT", http.StatusNotFound, nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var recorders []RecorderStats
+		adminRequest(t, http.MethodGet, base+"/recorders", http.StatusOK, &recorders)
+		open := false
+		for _, s := range recorders {
+			open = open || s.Instrument == "ADMBUSDT"
+		}
+		if !open {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the removed instrument's recorder to be closed, got %+v", recorders)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	adminRequest(t, http.MethodGet, base+"/symbols", http.StatusOK, &symbols)
+	if len(symbols) != 1 || symbols[0].Instrument != "ADMAUSDT" {
+		t.Errorf("expected only ADMAUSDT to be recorded, got %+v", symbols)
+	}
+}
+
+func TestAdminAPI_RollsBackInstrumentThatFailsToStart(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("admausdt@trade")
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"ADMAUSDT"}
+	cfg.Streams = map[string][]string{"ADMAUSDT": {StreamTrade}}
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+	base, stop := startAdminRun(t, cfg)
+	defer stop()
+
+	// The trade recorder cannot create its file, after the trade stream's spill queue has been created
+	if err := os.Mkdir(BuildFileName("trade", "ADMDUSDT", NowFunc()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	adminRequest(t, http.MethodPut, base+"/symbols/ADMDUSDT?streams=trade", http.StatusInternalServerError, nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		files, err := filepath.Glob(filepath.Join(cfg.SpillDir, "ADMDUSDT_*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the spill queue of the failed instrument to be closed, found %v", files)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAdminAPI_SwitchesMultiplexedStreams(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("admxusdt@trade")
+	srv.SetStream("admxusdt@bookTicker")
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"ADMXUSDT"}
+	cfg.Streams = map[string][]string{"ADMXUSDT": {StreamTrade, StreamBookTicker}}
+	cfg.MultiplexStreams = true
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+	base, stop := startAdminRun(t, cfg)
+	defer stop()
+	waitForSubscribers(t, srv, "admxusdt@bookTicker", 1)
+
+	adminRequest(t, http.MethodDelete, base+"/symbols/ADMXUSDT/streams/bookTicker", http.StatusOK, nil)
+	if srv.Subscribers("admxusdt@bookTicker") != 0 || srv.Subscribers("admxusdt@trade") != 1 {
+		t.Errorf("expected only the book ticker stream to be unsubscribed")
+	}
+	srv.Publish("admxusdt@trade", mockbinance.TradeMessage("ADMXUSDT", 1, "1.0", "1"))
+	waitForRows(t, "ADMXUSDT", "trade", 1)
+
+	adminRequest(t, http.MethodDelete, base+"/symbols/ADMXUSDT", http.StatusNoContent, nil)
+	if srv.Subscribers("admxusdt@trade") != 0 {
+		t.Errorf("expected the removed instrument to be unsubscribed")
+	}
+}
@@ -0,0 +1,303 @@
+package gobinapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of Alert.
+const (
+	AlertSequenceGap  = "sequence_gap"
+	AlertReconnects   = "reconnects"
+	AlertWriteFailure = "write_failure"
+	AlertLowDisk      = "low_disk"
+	AlertCorruptFile  = "corrupt_file"
+)
+
+func init() {
+	DefaultMetrics.Describe("binance_alerts_total", "counter", "Alerts sent, per kind.")
+	DefaultMetrics.Describe("binance_alerts_suppressed_total", "counter", "Alerts held back by rate limiting or a full queue, per kind.")
+	DefaultMetrics.Describe("binance_disk_free_bytes", "gauge", "Bytes available on the file system of the output directory.")
+}
+
+// Alert is a condition an operator should know about, e.g. a sequence gap or a failing recorder.
+type Alert struct {
+	Kind string `json:"kind"`
+	// Symbol and Stream say where it happened, if anywhere in particular. Stream is a data type such as "depth", or
+	// a WebSocket stream name for connection alerts.
+	Symbol  string    `json:"symbol,omitempty"`
+	Stream  string    `json:"stream,omitempty"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+	// Suppressed counts the alerts like this one held back since the last one was sent.
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// key identifies the alerts rate limited together.
+func (a Alert) key() string {
+	return a.Kind + "|" + a.Symbol + "|" + a.Stream
+}
+
+// Text formats the alert as one line of text, e.g. "[sequence_gap] BTCUSDT depth: ...".
+func (a Alert) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]", a.Kind)
+	for _, where := range []string{a.Symbol, a.Stream} {
+		if where != "" {
+			b.WriteString(" " + where)
+		}
+	}
+	fmt.Fprintf(&b, ": %s", a.Message)
+	if a.Suppressed > 0 {
+		fmt.Fprintf(&b, " (%d similar alerts suppressed)", a.Suppressed)
+	}
+	return b.String()
+}
+
+// Alerter delivers alerts, e.g. to a chat webhook (WebhookAlerter) or Telegram (TelegramAlerter).
+type Alerter interface {
+	Send(ctx context.Context, alert Alert) error
+}
+
+// AlertConfig configures the alerts of Config.Alerts. Zero values take the defaults noted.
+type AlertConfig struct {
+	// WebhookURL receives every alert as a JSON POST with its text in a "text" field, which Slack incoming webhooks
+	// post as a message, and the Alert's fields alongside.
+	WebhookURL string `json:"webhook_url,omitempty" yaml:"webhook_url"`
+	// Telegram, if set, also sends every alert as a Telegram message.
+	Telegram *TelegramConfig `json:"telegram,omitempty" yaml:"telegram"`
+	// MinInterval is how long further alerts of the same kind for the same symbol and stream are held back after
+	// one is sent (default 5m). The next one sent reports how many were held back.
+	MinInterval time.Duration `json:"min_interval,omitempty" yaml:"min_interval"`
+	// ReconnectThreshold reconnects of one stream within ReconnectWindow raise an alert (defaults 3 and 10m).
+	ReconnectThreshold int           `json:"reconnect_threshold,omitempty" yaml:"reconnect_threshold"`
+	ReconnectWindow    time.Duration `json:"reconnect_window,omitempty" yaml:"reconnect_window"`
+	// MinFreeDisk raises an alert when the output directory's file system has fewer bytes available (default
+	// 1 GiB), checked every DiskCheckInterval (default 1m). Negative disables the check.
+	MinFreeDisk       int64         `json:"min_free_disk,omitempty" yaml:"min_free_disk"`
+	DiskCheckInterval time.Duration `json:"disk_check_interval,omitempty" yaml:"disk_check_interval"`
+}
+
+// Validate checks the settings.
+func (c AlertConfig) Validate() error {
+	if c.WebhookURL == "" && c.Telegram == nil {
+		return errors.New("alerts: a webhook URL is required unless telegram is configured")
+	}
+	if c.Telegram != nil {
+		if err := c.Telegram.Validate(); err != nil {
+			return fmt.Errorf("alerts: %w", err)
+		}
+	}
+	if c.WebhookURL != "" && !strings.HasPrefix(c.WebhookURL, "http://") && !strings.HasPrefix(c.WebhookURL, "https://") {
+		return fmt.Errorf("alerts: webhook URL %q is not an http or https URL", c.WebhookURL)
+	}
+	if c.MinInterval < 0 || c.ReconnectThreshold < 0 || c.ReconnectWindow < 0 || c.DiskCheckInterval < 0 {
+		return errors.New("alerts: intervals and thresholds must not be negative")
+	}
+	return nil
+}
+
+func (c AlertConfig) withDefaults() AlertConfig {
+	if c.MinInterval == 0 {
+		c.MinInterval = 5 * time.Minute
+	}
+	if c.ReconnectThreshold == 0 {
+		c.ReconnectThreshold = 3
+	}
+	if c.ReconnectWindow == 0 {
+		c.ReconnectWindow = 10 * time.Minute
+	}
+	if c.MinFreeDisk == 0 {
+		c.MinFreeDisk = 1 << 30
+	}
+	if c.DiskCheckInterval == 0 {
+		c.DiskCheckInterval = time.Minute
+	}
+	return c
+}
+
+// WebhookAlerter posts alerts to a Slack-compatible webhook.
+type WebhookAlerter struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookAlerter creates a WebhookAlerter posting to url with client.
+func NewWebhookAlerter(url string, client *http.Client) *WebhookAlerter {
+	return &WebhookAlerter{url: url, client: client}
+}
+
+// Send posts alert as {"text": alert.Text(), "kind": ..., ...}.
+func (w *WebhookAlerter) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+		Alert
+	}{alert.Text(), alert})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alert webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// alertQueueSize bounds the alerts waiting to be sent; more are dropped rather than blocking the pipelines.
+const alertQueueSize = 100
+
+// AlertDispatcher rate limits alerts raised anywhere in the recorder and hands them to its Alerters. Raising an
+// alert never blocks: it is queued for Run, and dropped if the queue is full. Without alerters, alerts are
+// discarded.
+type AlertDispatcher struct {
+	mu       sync.Mutex
+	cfg      AlertConfig
+	alerters []Alerter
+	queue    chan Alert
+	// sent holds when each key's last alert was queued, suppressed how many were held back since
+	sent       map[string]time.Time
+	suppressed map[string]int
+	// reconnects holds the recent reconnect times per stream
+	reconnects map[string][]time.Time
+}
+
+// DefaultAlerts receives the alerts of the recorder's pipelines. Run sets its alerters from Config.Alerts.
+var DefaultAlerts = NewAlertDispatcher()
+
+// NewAlertDispatcher creates a dispatcher without alerters.
+func NewAlertDispatcher() *AlertDispatcher {
+	return &AlertDispatcher{
+		cfg:        AlertConfig{}.withDefaults(),
+		queue:      make(chan Alert, alertQueueSize),
+		sent:       make(map[string]time.Time),
+		suppressed: make(map[string]int),
+		reconnects: make(map[string][]time.Time),
+	}
+}
+
+// Configure makes d send alerts to alerters, rate limited as cfg says, and forgets its rate limiting state. No
+// alerters switches alerting off.
+func (d *AlertDispatcher) Configure(cfg AlertConfig, alerters ...Alerter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cfg = cfg.withDefaults()
+	d.alerters = alerters
+	clear(d.sent)
+	clear(d.suppressed)
+	clear(d.reconnects)
+}
+
+// Raise queues alert, stamped with the current time, unless an alert of its kind for its symbol and stream was
+// queued less than the configured interval ago.
+func (d *AlertDispatcher) Raise(alert Alert) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.alerters) == 0 {
+		return
+	}
+	alert.Time = NowFunc().UTC()
+	key := alert.key()
+	if last, ok := d.sent[key]; ok && alert.Time.Sub(last) < d.cfg.MinInterval {
+		d.suppressed[key]++
+		DefaultMetrics.Add("binance_alerts_suppressed_total", Labels{"kind": alert.Kind}, 1)
+		return
+	}
+	alert.Suppressed = d.suppressed[key]
+	select {
+	case d.queue <- alert:
+		d.sent[key] = alert.Time
+		delete(d.suppressed, key)
+	default:
+		d.suppressed[key]++
+		DefaultMetrics.Add("binance_alerts_suppressed_total", Labels{"kind": alert.Kind}, 1)
+	}
+}
+
+// noteReconnect records a reconnect of stream and raises an AlertReconnects alert once the stream has reconnected
+// the threshold number of times within the window.
+func (d *AlertDispatcher) noteReconnect(stream string) {
+	now := NowFunc()
+	d.mu.Lock()
+	if len(d.alerters) == 0 {
+		d.mu.Unlock()
+		return
+	}
+	recent := d.reconnects[stream][:0]
+	for _, t := range d.reconnects[stream] {
+		if now.Sub(t) < d.cfg.ReconnectWindow {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	d.reconnects[stream] = recent
+	threshold, window := d.cfg.ReconnectThreshold, d.cfg.ReconnectWindow
+	d.mu.Unlock()
+	if len(recent) >= threshold {
+		d.Raise(Alert{
+			Kind:    AlertReconnects,
+			Stream:  stream,
+			Message: fmt.Sprintf("reconnected %d times within %s", len(recent), window),
+		})
+	}
+}
+
+// Run sends queued alerts to every alerter until ctx is cancelled, logging failures.
+func (d *AlertDispatcher) Run(ctx context.Context, logger LoggerInterface) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-d.queue:
+			d.mu.Lock()
+			alerters := d.alerters
+			d.mu.Unlock()
+			for _, a := range alerters {
+				if err := a.Send(ctx, alert); err != nil {
+					logger.Errorf("Failed to send alert %q: %v", alert.Text(), err)
+				}
+			}
+			DefaultMetrics.Add("binance_alerts_total", Labels{"kind": alert.Kind}, 1)
+		}
+	}
+}
+
+// RunDiskMonitor raises an AlertLowDisk alert on alerts whenever the file system holding dir has fewer than minFree
+// bytes available, checking every interval until ctx is cancelled.
+func RunDiskMonitor(ctx context.Context, dir string, minFree int64, interval time.Duration, alerts *AlertDispatcher, logger LoggerInterface) {
+	if dir == "" {
+		dir = "."
+	}
+	for {
+		free, err := freeDiskSpace(dir)
+		if err != nil {
+			logger.Errorf("Failed to check the free disk space of %s: %v", dir, err)
+		} else {
+			DefaultMetrics.Set("binance_disk_free_bytes", Labels{"dir": dir}, float64(free))
+			if free < minFree {
+				alerts.Raise(Alert{
+					Kind:    AlertLowDisk,
+					Message: fmt.Sprintf("%s has %d MiB free, below %d MiB", dir, free>>20, minFree>>20),
+				})
+			}
+		}
+		if err := sleepContext(ctx, interval); err != nil {
+			return
+		}
+	}
+}
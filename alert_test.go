@@ -0,0 +1,124 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// alertRecorder is an Alerter collecting the alerts it is sent.
+type alertRecorder chan Alert
+
+func (a alertRecorder) Send(ctx context.Context, alert Alert) error {
+	a <- alert
+	return nil
+}
+
+func TestAlertDispatcher_RateLimitsAlertsToWebhook(t *testing.T) {
+	posts := make(chan map[string]any, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected webhook request %s: %v", data, err)
+		}
+		posts <- body
+	}))
+	defer srv.Close()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	oldNow := NowFunc
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = oldNow }()
+
+	d := NewAlertDispatcher()
+	d.Configure(AlertConfig{WebhookURL: srv.URL}, NewWebhookAlerter(srv.URL, srv.Client()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx, NewLogger(io.Discard))
+
+	gap := Alert{Kind: AlertSequenceGap, Symbol: "BTCUSDT", Stream: "depth", Message: "gap"}
+	d.Raise(gap)
+	now = now.Add(time.Minute)
+	d.Raise(gap)
+	d.Raise(Alert{Kind: AlertSequenceGap, Symbol: "ETHUSDT", Stream: "depth", Message: "gap"})
+	d.Raise(gap)
+	now = now.Add(5 * time.Minute)
+	d.Raise(gap)
+
+	var texts []string
+	for range 3 {
+		select {
+		case body := <-posts:
+			texts = append(texts, body["text"].(string))
+			if body["kind"] != AlertSequenceGap {
+				t.Errorf("expected the alert's fields alongside the text, got %v", body)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for alerts, got %q", texts)
+		}
+	}
+	want := []string{
+		"[sequence_gap] BTCUSDT depth: gap",
+		"[sequence_gap] ETHUSDT depth: gap",
+		"[sequence_gap] BTCUSDT depth: gap (2 similar alerts suppressed)",
+	}
+	for i := range want {
+		if texts[i] != want[i] {
+			t.Errorf("alert %d: expected %q, got %q", i, want[i], texts[i])
+		}
+	}
+	select {
+	case body := <-posts:
+		t.Errorf("unexpected alert %v", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAlertDispatcher_AlertsOnRepeatedReconnects(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	oldNow := NowFunc
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = oldNow }()
+
+	sent := make(alertRecorder, 10)
+	d := NewAlertDispatcher()
+	d.Configure(AlertConfig{WebhookURL: "http://unused", ReconnectThreshold: 3, ReconnectWindow: 10 * time.Minute}, sent)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx, NewLogger(io.Discard))
+
+	// Reconnects spread wider than the window do not alert
+	for range 3 {
+		d.noteReconnect("btcusdt@trade")
+		now = now.Add(6 * time.Minute)
+	}
+	d.noteReconnect("btcusdt@depth")
+	d.noteReconnect("btcusdt@depth")
+	select {
+	case alert := <-sent:
+		t.Fatalf("unexpected alert %+v", alert)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	d.noteReconnect("btcusdt@depth")
+	select {
+	case alert := <-sent:
+		if alert.Kind != AlertReconnects || alert.Stream != "btcusdt@depth" || alert.Message != "reconnected 3 times within 10m0s" {
+			t.Errorf("unexpected alert %+v", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the reconnect alert")
+	}
+}
+
+func TestAlertDispatcher_DiscardsAlertsWithoutAlerters(t *testing.T) {
+	d := NewAlertDispatcher()
+	d.Raise(Alert{Kind: AlertLowDisk, Message: "full"})
+	if len(d.queue) != 0 {
+		t.Errorf("expected nothing to be queued, got %d alerts", len(d.queue))
+	}
+}
@@ -0,0 +1,203 @@
+package gobinapi
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+)
+
+// avroMarker starts an Avro single-object encoded message, followed by the little-endian CRC-64-AVRO fingerprint of
+// the writer schema and the binary encoded record.
+var avroMarker = []byte{0xC3, 0x01}
+
+// avroCodec is the schema and encoder of one record type.
+type avroCodec struct {
+	schema      string
+	fingerprint [8]byte
+	encode      func(buf []byte, v reflect.Value) []byte
+}
+
+var avroCodecs sync.Map // reflect.Type -> *avroCodec
+
+// AvroSchema returns the Avro schema of a record type in Parsing Canonical Form. It is derived from the parquet
+// tags: fields keep their column names, strings map to "string", integers to "long", booleans to "boolean",
+// optional doubles to ["null","double"] and repeated structs to arrays of records.
+func AvroSchema(record interface{}) (string, error) {
+	codec, err := avroCodecFor(reflect.TypeOf(record))
+	if err != nil {
+		return "", err
+	}
+	return codec.schema, nil
+}
+
+// EncodeAvro returns record in Avro single-object encoding, which carries its schema's fingerprint so consumers
+// can look up the schema returned by AvroSchema.
+func EncodeAvro(record interface{}) ([]byte, error) {
+	codec, err := avroCodecFor(reflect.TypeOf(record))
+	if err != nil {
+		return nil, err
+	}
+	buf := append([]byte(nil), avroMarker...)
+	buf = append(buf, codec.fingerprint[:]...)
+	return codec.encode(buf, reflect.ValueOf(record)), nil
+}
+
+func avroCodecFor(t reflect.Type) (*avroCodec, error) {
+	if c, ok := avroCodecs.Load(t); ok {
+		return c.(*avroCodec), nil
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("avro: %v is not a record type", t)
+	}
+	schema, encode, err := avroRecord(t, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	codec := &avroCodec{schema: string(data), encode: encode}
+	binary.LittleEndian.PutUint64(codec.fingerprint[:], avroFingerprint(data))
+	c, _ := avroCodecs.LoadOrStore(t, codec)
+	return c.(*avroCodec), nil
+}
+
+// avroField and avroRecordSchema marshal in Parsing Canonical Form, which orders attributes name, type, fields,
+// items.
+type avroField struct {
+	Name string `json:"name"`
+	Type any    `json:"type"`
+}
+
+type avroRecordSchema struct {
+	Name   string      `json:"name"`
+	Type   string      `json:"type"`
+	Fields []avroField `json:"fields"`
+}
+
+type avroArraySchema struct {
+	Type  string `json:"type"`
+	Items any    `json:"items"`
+}
+
+// avroRecord derives the schema and encoder of a struct from its parquet tags. Record names already in defined are
+// referenced by name, since Avro forbids defining a name twice.
+func avroRecord(t reflect.Type, defined map[string]bool) (avroRecordSchema, func([]byte, reflect.Value) []byte, error) {
+	schema := avroRecordSchema{Name: t.Name(), Type: "record"}
+	defined[t.Name()] = true
+	var indexes []int
+	var encoders []func([]byte, reflect.Value) []byte
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("parquet")
+		if !ok {
+			continue
+		}
+		kv, err := parseParquetTag(tag)
+		if err != nil {
+			return schema, nil, fmt.Errorf("avro: %s.%s: %w", t.Name(), f.Name, err)
+		}
+		fieldType, encode, err := avroType(f.Type, defined)
+		if err != nil {
+			return schema, nil, fmt.Errorf("avro: %s.%s: %w", t.Name(), f.Name, err)
+		}
+		schema.Fields = append(schema.Fields, avroField{Name: kv["name"], Type: fieldType})
+		indexes = append(indexes, i)
+		encoders = append(encoders, encode)
+	}
+	encode := func(buf []byte, v reflect.Value) []byte {
+		for i, index := range indexes {
+			buf = encoders[i](buf, v.Field(index))
+		}
+		return buf
+	}
+	return schema, encode, nil
+}
+
+func avroType(t reflect.Type, defined map[string]bool) (any, func([]byte, reflect.Value) []byte, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return "string", func(buf []byte, v reflect.Value) []byte {
+			buf = binary.AppendVarint(buf, int64(v.Len()))
+			return append(buf, v.String()...)
+		}, nil
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "long", func(buf []byte, v reflect.Value) []byte {
+			return binary.AppendVarint(buf, v.Int())
+		}, nil
+	case reflect.Bool:
+		return "boolean", func(buf []byte, v reflect.Value) []byte {
+			if v.Bool() {
+				return append(buf, 1)
+			}
+			return append(buf, 0)
+		}, nil
+	case reflect.Float64:
+		return "double", func(buf []byte, v reflect.Value) []byte {
+			return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float()))
+		}, nil
+	case reflect.Pointer:
+		elem, encodeElem, err := avroType(t.Elem(), defined)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Unions are encoded as the zero-based branch index followed by the value
+		return []any{"null", elem}, func(buf []byte, v reflect.Value) []byte {
+			if v.IsNil() {
+				return binary.AppendVarint(buf, 0)
+			}
+			return encodeElem(binary.AppendVarint(buf, 1), v.Elem())
+		}, nil
+	case reflect.Slice:
+		items, encodeItem, err := avroType(t.Elem(), defined)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Arrays are encoded as one block holding every item, terminated by an empty block
+		return avroArraySchema{Type: "array", Items: items}, func(buf []byte, v reflect.Value) []byte {
+			if v.Len() > 0 {
+				buf = binary.AppendVarint(buf, int64(v.Len()))
+				for i := 0; i < v.Len(); i++ {
+					buf = encodeItem(buf, v.Index(i))
+				}
+			}
+			return binary.AppendVarint(buf, 0)
+		}, nil
+	case reflect.Struct:
+		if defined[t.Name()] {
+			_, encode, err := avroRecord(t, defined)
+			return t.Name(), encode, err
+		}
+		schema, encode, err := avroRecord(t, defined)
+		return schema, encode, err
+	}
+	return nil, nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// avroEmpty is the CRC-64-AVRO seed, also the fingerprint of empty input.
+const avroEmpty = 0xc15d213aa4d7a795
+
+var avroFingerprintTable = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (avroEmpty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	return table
+}()
+
+// avroFingerprint is the 64-bit Rabin fingerprint (CRC-64-AVRO) the Avro specification defines for schemas.
+func avroFingerprint(data []byte) uint64 {
+	fp := uint64(avroEmpty)
+	for _, b := range data {
+		fp = (fp >> 8) ^ avroFingerprintTable[byte(fp)^b]
+	}
+	return fp
+}
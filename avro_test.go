@@ -0,0 +1,70 @@
+package gobinapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAvroFingerprint_MatchesSpecification(t *testing.T) {
+	// Fingerprints of primitive schemas from the Avro test suite
+	for schema, want := range map[string]uint64{
+		`"null"`: 0x63dd24e7cc258f8a,
+		`"int"`:  0x7275d51a3f395c8f,
+	} {
+		if got := avroFingerprint([]byte(schema)); got != want {
+			t.Errorf("fingerprint of %s: expected %#x, got %#x", schema, want, got)
+		}
+	}
+}
+
+func TestAvroSchema_ReferencesRepeatedRecordsByName(t *testing.T) {
+	schema, err := AvroSchema(OrderBookDiff{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(schema, `{"name":"OrderBookDiff","type":"record","fields":[{"name":"event_type","type":"string"}`) {
+		t.Errorf("unexpected schema %s", schema)
+	}
+	if strings.Count(schema, `"name":"PriceLevel"`) != 1 || !strings.Contains(schema, `{"type":"array","items":"PriceLevel"}`) {
+		t.Errorf("expected PriceLevel to be defined once and referenced by name, got %s", schema)
+	}
+	for _, record := range RecordedTypes() {
+		if _, err := avroCodecFor(reflect.TypeOf(record).Elem()); err != nil {
+			t.Errorf("%T: %v", record, err)
+		}
+	}
+}
+
+func TestEncodeAvro_SingleObjectEncoding(t *testing.T) {
+	mid := 1.5
+	record := BestPrice{UpdateID: 3, Symbol: "BTCUSDT", Mid: &mid}
+	data, err := EncodeAvro(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, _ := AvroSchema(record)
+	var header []byte
+	header = append(header, 0xC3, 0x01)
+	header = binary.LittleEndian.AppendUint64(header, avroFingerprint([]byte(schema)))
+	if !bytes.HasPrefix(data, header) {
+		t.Fatalf("expected the marker and schema fingerprint, got % x", data[:10])
+	}
+
+	var body []byte
+	body = append(body, 0)  // event_type ""
+	body = append(body, 6)  // update_id 3
+	body = append(body, 14) // symbol length 7
+	body = append(body, "BTCUSDT"...)
+	body = append(body, 0, 0, 0, 0) // bid and ask prices and quantities ""
+	body = append(body, 0, 0)       // event_time, transaction_time 0
+	body = append(body, 2)          // mid: second union branch
+	body = binary.LittleEndian.AppendUint64(body, 0x3FF8000000000000)
+	body = append(body, 0, 0) // spread, spread_bps null
+	body = append(body, 0, 0) // conn_id "", conn_generation 0
+	if got := data[len(header):]; !bytes.Equal(got, body) {
+		t.Errorf("expected body\n% x\ngot\n% x", body, got)
+	}
+}
@@ -0,0 +1,466 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceREST marks trades and aggregate trades fetched from the REST API by a Backfiller in their source column.
+// Records received on the WebSocket streams leave it empty.
+const SourceREST = "rest"
+
+// backfillPageSize is the largest page the trade endpoints return.
+const backfillPageSize = 1000
+
+// Request weights of the trade endpoints, following the exchange's published tables.
+const (
+	historicalTradesWeight        = 25
+	aggTradesWeight               = 4
+	futuresHistoricalTradesWeight = 20
+	futuresAggTradesWeight        = 20
+)
+
+// Backfiller fetches past trades and aggregate trades from the REST API, paginating by ID and pacing its requests
+// through a WeightTracker, to fill gaps in the recordings.
+type Backfiller struct {
+	client  *http.Client
+	market  string
+	tracker *WeightTracker
+	apiKey  string
+	logger  LoggerInterface
+}
+
+// NewBackfiller creates a Backfiller for market (MarketSpot or MarketUSDM) whose requests share the weight budget
+// of tracker, usually DefaultWeightTracker.
+func NewBackfiller(client *http.Client, market string, tracker *WeightTracker, logger LoggerInterface) *Backfiller {
+	return &Backfiller{client: client, market: market, tracker: tracker, logger: logger}
+}
+
+// SetAPIKey sends key in the X-MBX-APIKEY header, which some deployments of the historical trades endpoint require.
+func (b *Backfiller) SetAPIKey(key string) {
+	b.apiKey = key
+}
+
+// restAggTrade is an aggregate trade as returned by the aggTrades endpoint.
+type restAggTrade struct {
+	AggTradeID   int64  `json:"a"`
+	Price        string `json:"p"`
+	Quantity     string `json:"q"`
+	FirstTradeID int64  `json:"f"`
+	LastTradeID  int64  `json:"l"`
+	TradeTime    int64  `json:"T"`
+	IsBuyerMaker bool   `json:"m"`
+}
+
+// record converts t to the recorded AggTrade of symbol.
+func (t restAggTrade) record(symbol string) AggTrade {
+	side, notional := DeriveTradeFields(t.Price, t.Quantity, t.IsBuyerMaker)
+	return AggTrade{
+		EventType:    "aggTrade",
+		EventTime:    t.TradeTime,
+		Symbol:       symbol,
+		AggTradeID:   t.AggTradeID,
+		Price:        t.Price,
+		Quantity:     t.Quantity,
+		FirstTradeID: t.FirstTradeID,
+		LastTradeID:  t.LastTradeID,
+		TradeTime:    t.TradeTime,
+		IsBuyerMaker: t.IsBuyerMaker,
+		Side:         side,
+		Notional:     notional,
+		Source:       SourceREST,
+	}
+}
+
+// restTrade is a trade as returned by the historicalTrades endpoint.
+type restTrade struct {
+	ID           int64  `json:"id"`
+	Price        string `json:"price"`
+	Quantity     string `json:"qty"`
+	Time         int64  `json:"time"`
+	IsBuyerMaker bool   `json:"isBuyerMaker"`
+}
+
+// record converts t to the recorded Trade.
+func (t restTrade) record() Trade {
+	side, notional := DeriveTradeFields(t.Price, t.Quantity, t.IsBuyerMaker)
+	return Trade{
+		EventType:    "trade",
+		EventTime:    t.Time,
+		TradeID:      t.ID,
+		Price:        t.Price,
+		Quantity:     t.Quantity,
+		TradeTime:    t.Time,
+		IsBuyerMaker: t.IsBuyerMaker,
+		Side:         side,
+		Notional:     notional,
+		Source:       SourceREST,
+	}
+}
+
+// AggTrades fetches the aggregate trades of symbol traded in [start, end), passing them to handle a page at a time
+// in ID order.
+func (b *Backfiller) AggTrades(ctx context.Context, symbol string, start, end time.Time, handle func([]AggTrade) error) error {
+	page, err := b.firstAggTrades(ctx, symbol, start, end)
+	for err == nil && len(page) > 0 {
+		records := make([]AggTrade, 0, len(page))
+		for _, t := range page {
+			if t.TradeTime >= end.UnixMilli() {
+				break
+			}
+			records = append(records, t.record(symbol))
+		}
+		if len(records) > 0 {
+			if err := handle(records); err != nil {
+				return err
+			}
+		}
+		if len(records) < len(page) || len(page) < backfillPageSize {
+			return nil
+		}
+		page, err = b.aggTradesFrom(ctx, symbol, page[len(page)-1].AggTradeID+1)
+	}
+	return err
+}
+
+// Trades fetches the trades of symbol traded in [start, end), passing them to handle a page at a time in ID order.
+// The first trade ID is looked up through the aggregate trades, since the historical trades endpoint only pages by
+// ID.
+func (b *Backfiller) Trades(ctx context.Context, symbol string, start, end time.Time, handle func([]Trade) error) error {
+	first, err := b.firstAggTrades(ctx, symbol, start, end)
+	if err != nil || len(first) == 0 {
+		return err
+	}
+	fromID := first[0].FirstTradeID
+	for {
+		page, err := b.tradesFrom(ctx, symbol, fromID)
+		if err != nil {
+			return err
+		}
+		records := make([]Trade, 0, len(page))
+		for _, t := range page {
+			if t.Time >= end.UnixMilli() {
+				break
+			}
+			if t.Time < start.UnixMilli() {
+				continue
+			}
+			records = append(records, t.record())
+		}
+		if len(records) > 0 {
+			if err := handle(records); err != nil {
+				return err
+			}
+		}
+		if len(page) < backfillPageSize || page[len(page)-1].Time >= end.UnixMilli() {
+			return nil
+		}
+		fromID = page[len(page)-1].ID + 1
+	}
+}
+
+// AggTradesBetween fetches the aggregate trades of symbol with IDs fromID to toID, passing them to handle a page at a
+// time in ID order.
+func (b *Backfiller) AggTradesBetween(ctx context.Context, symbol string, fromID, toID int64, handle func([]AggTrade) error) error {
+	for fromID <= toID {
+		page, err := b.aggTradesFrom(ctx, symbol, fromID)
+		if err != nil {
+			return err
+		}
+		records := make([]AggTrade, 0, len(page))
+		for _, t := range page {
+			if t.AggTradeID <= toID {
+				records = append(records, t.record(symbol))
+			}
+		}
+		if len(records) > 0 {
+			if err := handle(records); err != nil {
+				return err
+			}
+		}
+		if len(page) < backfillPageSize {
+			return nil
+		}
+		fromID = page[len(page)-1].AggTradeID + 1
+	}
+	return nil
+}
+
+// TradesBetween fetches the trades of symbol with IDs fromID to toID, passing them to handle a page at a time in ID
+// order.
+func (b *Backfiller) TradesBetween(ctx context.Context, symbol string, fromID, toID int64, handle func([]Trade) error) error {
+	for fromID <= toID {
+		page, err := b.tradesFrom(ctx, symbol, fromID)
+		if err != nil {
+			return err
+		}
+		records := make([]Trade, 0, len(page))
+		for _, t := range page {
+			if t.ID <= toID {
+				records = append(records, t.record())
+			}
+		}
+		if len(records) > 0 {
+			if err := handle(records); err != nil {
+				return err
+			}
+		}
+		if len(page) < backfillPageSize {
+			return nil
+		}
+		fromID = page[len(page)-1].ID + 1
+	}
+	return nil
+}
+
+// firstAggTrades returns the first page of aggregate trades at or after start, searching hour by hour up to end
+// since the endpoint only accepts time windows of less than an hour.
+func (b *Backfiller) firstAggTrades(ctx context.Context, symbol string, start, end time.Time) ([]restAggTrade, error) {
+	for from := start; from.Before(end); from = from.Add(time.Hour) {
+		to := from.Add(time.Hour - time.Millisecond)
+		if to.After(end) {
+			to = end
+		}
+		q := url.Values{}
+		q.Set("symbol", symbol)
+		q.Set("startTime", strconv.FormatInt(from.UnixMilli(), 10))
+		q.Set("endTime", strconv.FormatInt(to.UnixMilli(), 10))
+		q.Set("limit", strconv.Itoa(backfillPageSize))
+		var page []restAggTrade
+		if err := b.get(ctx, b.aggTradesPath(), q, b.aggTradesWeight(), &page); err != nil {
+			return nil, err
+		}
+		if len(page) > 0 {
+			return page, nil
+		}
+	}
+	return nil, nil
+}
+
+// aggTradesFrom returns the page of aggregate trades starting at fromID.
+func (b *Backfiller) aggTradesFrom(ctx context.Context, symbol string, fromID int64) ([]restAggTrade, error) {
+	q := url.Values{}
+	q.Set("symbol", symbol)
+	q.Set("fromId", strconv.FormatInt(fromID, 10))
+	q.Set("limit", strconv.Itoa(backfillPageSize))
+	var page []restAggTrade
+	err := b.get(ctx, b.aggTradesPath(), q, b.aggTradesWeight(), &page)
+	return page, err
+}
+
+// tradesFrom returns the page of trades starting at fromID.
+func (b *Backfiller) tradesFrom(ctx context.Context, symbol string, fromID int64) ([]restTrade, error) {
+	q := url.Values{}
+	q.Set("symbol", symbol)
+	q.Set("fromId", strconv.FormatInt(fromID, 10))
+	q.Set("limit", strconv.Itoa(backfillPageSize))
+	path, weight := RESTBaseURL+"/api/v3/historicalTrades", historicalTradesWeight
+	if b.market == MarketUSDM {
+		path, weight = FuturesRESTBaseURL+"/fapi/v1/historicalTrades", futuresHistoricalTradesWeight
+	}
+	var page []restTrade
+	err := b.get(ctx, path, q, weight, &page)
+	return page, err
+}
+
+func (b *Backfiller) aggTradesPath() string {
+	if b.market == MarketUSDM {
+		return FuturesRESTBaseURL + "/fapi/v1/aggTrades"
+	}
+	return RESTBaseURL + "/api/v3/aggTrades"
+}
+
+func (b *Backfiller) aggTradesWeight() int {
+	if b.market == MarketUSDM {
+		return futuresAggTradesWeight
+	}
+	return aggTradesWeight
+}
+
+// get waits for weight in the tracker's queue and decodes the JSON response of a GET request into out. A 429 or 418
+// response is retried once the backoff the exchange asks for, which holds up every request of the tracker, has
+// passed.
+func (b *Backfiller) get(ctx context.Context, endpoint string, query url.Values, weight int, out interface{}) error {
+	for {
+		if err := b.tracker.Wait(ctx, weight); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		if b.apiKey != "" {
+			req.Header.Set("X-MBX-APIKEY", b.apiKey)
+		}
+		resp, err := b.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("backfill request failed: %w", err)
+		}
+		backoff := b.tracker.ObserveResponse(resp, NowFunc())
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read backfill response: %w", err)
+		}
+		if backoff > 0 {
+			b.logger.Errorf("backfill: %s from the exchange, retrying in %s", resp.Status, backoff)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("backfill request %s returned %s: %s", endpoint, resp.Status, strings.TrimSpace(string(data)))
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to parse backfill response: %w", err)
+		}
+		return nil
+	}
+}
+
+// sleepContext waits for d on DefaultClock or until ctx is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := DefaultClock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// BackfillStats summarizes a Backfill.
+type BackfillStats struct {
+	Fetched  int      `json:"fetched"`
+	Recorded int      `json:"recorded"`
+	Files    []string `json:"files"`
+	// Unavailable lists the days (YYYY-MM-DD) a VisionDownloader found no archive for.
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// Backfill fetches symbol's trades or aggregate trades (dataType "trade" or "aggTrade") traded in [start, end) and
+// writes those missing from the recordings under layout into the same layout, one new part file per UTC day listed
+// in the day's part index next to the recorded parts. Trades already recorded, by ID, are skipped, so Backfill can be
+// run over a range that is only partly missing.
+func (b *Backfiller) Backfill(ctx context.Context, layout FileLayout, dataType, symbol string, start, end time.Time) (BackfillStats, error) {
+	switch dataType {
+	case "trade":
+		return backfillDays(ctx, layout, dataType, symbol, start, end, b.Trades, func(t Trade) (int64, int64) {
+			return t.TradeID, t.TradeTime
+		})
+	case "aggTrade":
+		return backfillDays(ctx, layout, dataType, symbol, start, end, b.AggTrades, func(t AggTrade) (int64, int64) {
+			return t.AggTradeID, t.TradeTime
+		})
+	default:
+		return BackfillStats{}, fmt.Errorf("unsupported data type for backfill: %s", dataType)
+	}
+}
+
+// backfillDays runs fetch day by day over [start, end) and writes each day's missing records. key returns a
+// record's ID and trade time in milliseconds.
+func backfillDays[T any](ctx context.Context, layout FileLayout, dataType, symbol string, start, end time.Time, fetch func(context.Context, string, time.Time, time.Time, func([]T) error) error, key func(T) (int64, int64)) (BackfillStats, error) {
+	var stats BackfillStats
+	for day := start.UTC().Truncate(24 * time.Hour); day.Before(end); day = day.Add(24 * time.Hour) {
+		from, to := day, day.Add(24*time.Hour)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		var fetched []T
+		err := fetch(ctx, symbol, from, to, func(page []T) error {
+			fetched = append(fetched, page...)
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+		stats.Fetched += len(fetched)
+		added, filePath, err := writeMissing(layout, dataType, symbol, day, fetched, key)
+		if err != nil {
+			return stats, err
+		}
+		if added > 0 {
+			stats.Recorded += added
+			stats.Files = append(stats.Files, filePath)
+		}
+	}
+	return stats, nil
+}
+
+// writeMissing writes the records of symbol's dataType on day that are not in the day's part files yet to a new part
+// and returns how many there were along with the part's path. key returns a record's ID and trade time in
+// milliseconds.
+func writeMissing[T any](layout FileLayout, dataType, symbol string, day time.Time, records []T, key func(T) (int64, int64)) (int, string, error) {
+	recorded, err := recordedIDs[T](layout, dataType, symbol, day, key)
+	if err != nil {
+		return 0, "", err
+	}
+	var missing []T
+	for _, record := range records {
+		if id, _ := key(record); !recorded[id] {
+			missing = append(missing, record)
+		}
+	}
+	if len(missing) == 0 {
+		return 0, "", nil
+	}
+	_, first := key(missing[0])
+	_, last := key(missing[len(missing)-1])
+	filePath, err := writeDayPart(layout, dataType, symbol, day, missing, time.UnixMilli(first).UTC(), time.UnixMilli(last).UTC())
+	if err != nil {
+		return 0, "", err
+	}
+	return len(missing), filePath, nil
+}
+
+// recordedIDs returns the IDs of the records in the part files of symbol's dataType on day. Files that cannot be
+// read, like the one a running recorder is still writing, are skipped.
+func recordedIDs[T any](layout FileLayout, dataType, symbol string, day time.Time, key func(T) (int64, int64)) (map[int64]bool, error) {
+	ids := make(map[int64]bool)
+	for part := 0; ; part++ {
+		filePath := layout.FilePath(dataType, symbol, day, part)
+		if !FileExists(filePath) {
+			return ids, nil
+		}
+		records, err := ReadParquetFile[T](filePath)
+		if err != nil {
+			continue
+		}
+		for _, record := range records {
+			id, _ := key(record)
+			ids[id] = true
+		}
+	}
+}
+
+// writeDayPart writes records of instrument's dataType on day to the day's next unused part file under layout and
+// lists it in the day's part index, which is how a recorder resuming the day (see ExistingFileNewPart) adds to it.
+func writeDayPart[T any](layout FileLayout, dataType, instrument string, day time.Time, records []T, first, last time.Time) (string, error) {
+	r := &Recorder[T]{layout: layout, dataType: dataType, instrument: instrument}
+	if err := r.resume(day); err != nil {
+		return "", err
+	}
+	filePath := layout.FilePath(dataType, instrument, day, r.part)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := writeParquetFile(filePath, records, ParquetOptionsFor(dataType)); err != nil {
+		return "", err
+	}
+	entry := PartIndexEntry{Part: r.part, File: filepath.Base(filePath), FirstTime: first, LastTime: last, Rows: int64(len(records))}
+	if err := AppendPartIndex(layout.IndexPath(dataType, instrument, day), entry); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
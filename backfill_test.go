@@ -0,0 +1,162 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTradeHistory serves the aggTrades and historicalTrades endpoints for trades 1 to count, trade i being traded
+// at start plus i seconds as its own aggregate trade i.
+type fakeTradeHistory struct {
+	start time.Time
+	count int64
+
+	mu          sync.Mutex
+	requests    []string
+	rateLimited int
+}
+
+func (h *fakeTradeHistory) tradeTime(id int64) int64 {
+	return h.start.Add(time.Duration(id) * time.Second).UnixMilli()
+}
+
+func (h *fakeTradeHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.requests = append(h.requests, r.URL.Path+"?"+r.URL.RawQuery)
+	limited := h.rateLimited > 0
+	if limited {
+		h.rateLimited--
+	}
+	h.mu.Unlock()
+	if limited {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	from, _ := strconv.ParseInt(q.Get("fromId"), 10, 64)
+	if q.Has("startTime") {
+		startTime, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+		from = (startTime - h.start.UnixMilli() + 999) / 1000
+		if endTime, _ := strconv.ParseInt(q.Get("endTime"), 10, 64); h.tradeTime(from) > endTime {
+			from = h.count + 1
+		}
+	}
+	var page []map[string]any
+	for id := max(from, 1); id <= h.count && len(page) < limit; id++ {
+		if r.URL.Path == "/api/v3/aggTrades" {
+			page = append(page, map[string]any{"a": id, "p": "100.5", "q": "2", "f": id, "l": id, "T": h.tradeTime(id), "m": id%2 == 0})
+		} else {
+			page = append(page, map[string]any{"id": id, "price": "100.5", "qty": "2", "time": h.tradeTime(id), "isBuyerMaker": id%2 == 0})
+		}
+	}
+	w.Header().Set("X-MBX-USED-WEIGHT-1M", "30")
+	json.NewEncoder(w).Encode(page)
+}
+
+func newFakeTradeHistory(t *testing.T, count int64) *fakeTradeHistory {
+	h := &fakeTradeHistory{start: time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC), count: count}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	old := RESTBaseURL
+	RESTBaseURL = srv.URL
+	t.Cleanup(func() { RESTBaseURL = old })
+	return h
+}
+
+func TestBackfiller_RecordsMissingTradesInNextPart(t *testing.T) {
+	history := newFakeTradeHistory(t, 3000)
+	layout := FileLayout{Root: t.TempDir()}
+	var recorded []Trade
+	for id := int64(100); id < 600; id++ {
+		recorded = append(recorded, Trade{TradeID: id, TradeTime: history.tradeTime(id)})
+	}
+	if err := WriteParquetFile(layout.FilePath("trade", "BTCUSDT", history.start, 0), recorded); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBackfiller(http.DefaultClient, MarketSpot, NewWeightTracker(DefaultRESTWeightLimit), &FakeLogger{})
+	start, end := history.start.Add(100*time.Second), history.start.Add(2100*time.Second)
+	stats, err := b.Backfill(context.Background(), layout, "trade", "BTCUSDT", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Fetched != 2000 || stats.Recorded != 1500 || len(stats.Files) != 1 {
+		t.Fatalf("expected 2000 trades fetched and 1500 recorded in one file, got %+v", stats)
+	}
+	if expected := layout.FilePath("trade", "BTCUSDT", history.start, 1); stats.Files[0] != expected {
+		t.Errorf("expected the trades in %s, got %s", expected, stats.Files[0])
+	}
+	trades, err := ReadParquetFile[Trade](stats.Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trades[0].TradeID != 600 || trades[len(trades)-1].TradeID != 2099 {
+		t.Errorf("expected trades 600 to 2099, got %d to %d", trades[0].TradeID, trades[len(trades)-1].TradeID)
+	}
+	if trades[0].Source != SourceREST || trades[0].Side != TradeSideSell || trades[0].Notional != "201" {
+		t.Errorf("unexpected backfilled trade %+v", trades[0])
+	}
+	index, err := ReadPartIndex(layout.IndexPath("trade", "BTCUSDT", history.start))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 2 || index[0].Rows != 500 || index[1].File != filepath.Base(stats.Files[0]) || index[1].Rows != 1500 {
+		t.Errorf("expected the recorded and backfilled parts in the index, got %+v", index)
+	}
+	if !index[1].FirstTime.Equal(time.UnixMilli(history.tradeTime(600))) {
+		t.Errorf("unexpected first time %s", index[1].FirstTime)
+	}
+
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	// One aggregate trade lookup for the first ID, then pages of historical trades until one passes the end
+	if len(history.requests) != 4 || history.requests[1] != "/api/v3/historicalTrades?fromId=100&limit=1000&symbol=BTCUSDT" {
+		t.Errorf("unexpected requests %v", history.requests)
+	}
+}
+
+func TestBackfiller_WaitsForWeightAndRateLimits(t *testing.T) {
+	history := newFakeTradeHistory(t, 10)
+	history.rateLimited = 1
+	clock := useFakeClock(t, history.start)
+	oldNow := NowFunc
+	NowFunc = clock.Now
+	t.Cleanup(func() { NowFunc = oldNow })
+
+	// The budget fits one aggTrades request per minute
+	b := NewBackfiller(http.DefaultClient, MarketSpot, NewWeightTracker(aggTradesWeight), &FakeLogger{})
+	done := make(chan error, 1)
+	var trades []AggTrade
+	go func() {
+		done <- b.AggTrades(context.Background(), "BTCUSDT", history.start, history.start.Add(time.Hour), func(page []AggTrade) error {
+			trades = append(trades, page...)
+			return nil
+		})
+	}()
+
+	// The rate limited request is retried after Retry-After, which finds the minute's budget used up
+	waitForWaiters(t, clock, 1)
+	clock.Advance(2 * time.Second)
+	waitForWaiters(t, clock, 1)
+	clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 10 || trades[9].AggTradeID != 10 || trades[0].Source != SourceREST || trades[0].Symbol != "BTCUSDT" {
+		t.Errorf("unexpected aggregate trades %+v", trades)
+	}
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	if len(history.requests) != 2 {
+		t.Errorf("expected the rate limited request to be retried once, got %v", history.requests)
+	}
+}
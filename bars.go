@@ -0,0 +1,193 @@
+package gobinapi
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+func init() {
+	DefaultMetrics.Describe("binance_bar_late_trades_total", "counter", "Trades left out of the bars because their bar had already been written, per symbol and interval.")
+}
+
+// BarIntervals are the intervals bars can be built for, with their lengths.
+var BarIntervals = map[string]time.Duration{
+	"1s": time.Second, "1m": time.Minute, "3m": 3 * time.Minute, "5m": 5 * time.Minute, "15m": 15 * time.Minute,
+	"30m": 30 * time.Minute, "1h": time.Hour, "2h": 2 * time.Hour, "4h": 4 * time.Hour, "6h": 6 * time.Hour,
+	"8h": 8 * time.Hour, "12h": 12 * time.Hour, "1d": 24 * time.Hour,
+}
+
+// barDataTypePrefix starts the data type of bars, which is followed by their interval.
+const barDataTypePrefix = "bar_"
+
+// BarDataType returns the data type bars of interval are recorded as, e.g. "bar_1m", so a day's 1m bars of BTCUSDT
+// go to BTCUSDT_bar_1m_2025-02-19.parquet.
+func BarDataType(interval string) string {
+	return barDataTypePrefix + interval
+}
+
+// BarInterval returns the interval of a data type returned by BarDataType, and false for other data types.
+func BarInterval(dataType string) (string, bool) {
+	interval, ok := strings.CutPrefix(dataType, barDataTypePrefix)
+	_, known := BarIntervals[interval]
+	return interval, ok && known
+}
+
+// Bar is an OHLCV bar aggregated from a symbol's trades by a BarBuilder, shaped like a Kline. Prices and volumes are
+// decimal strings like those of trades; Volume is in base and QuoteVolume in quote units.
+type Bar struct {
+	Symbol   string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Interval string `json:"i" parquet:"name=interval, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	// OpenTime and CloseTime are the first and last millisecond of the bar's interval, in exchange time.
+	OpenTime            int64  `json:"t" timestamp:"millis" parquet:"name=open_time, type=INT64"`
+	CloseTime           int64  `json:"T" timestamp:"millis" parquet:"name=close_time, type=INT64"`
+	Open                string `json:"o" decimal:"true" parquet:"name=open, type=BYTE_ARRAY, convertedtype=UTF8"`
+	High                string `json:"h" decimal:"true" parquet:"name=high, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Low                 string `json:"l" decimal:"true" parquet:"name=low, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Close               string `json:"c" decimal:"true" parquet:"name=close, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Volume              string `json:"v" decimal:"true" parquet:"name=volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+	QuoteVolume         string `json:"q" decimal:"true" parquet:"name=quote_volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Trades              int64  `json:"n" parquet:"name=trades, type=INT64"`
+	TakerBuyVolume      string `json:"V" decimal:"true" parquet:"name=taker_buy_volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TakerBuyQuoteVolume string `json:"Q" decimal:"true" parquet:"name=taker_buy_quote_volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+	FirstTradeID        int64  `json:"f" parquet:"name=first_trade_id, type=INT64"`
+	LastTradeID         int64  `json:"L" parquet:"name=last_trade_id, type=INT64"`
+	// Partial is set on a bar written on shutdown before its interval was over.
+	Partial bool `json:"partial" parquet:"name=partial, type=BOOLEAN"`
+}
+
+// BarBuilder aggregates a symbol's trades into bars of one interval, aligned to exchange time: a bar covers the trades
+// whose trade time falls into its interval since the Unix epoch, so 1m bars start on the minute. A bar is written to
+// the next sink once a trade of a later interval arrives, and the bar in progress is written, marked partial, on
+// Close. Intervals without trades have no bar, and a trade arriving after its bar was written is counted in
+// binance_bar_late_trades_total but left out.
+//
+// BarBuilder is a Sink[Trade], so it can be added to a trade stream's sinks; its other Sink methods pass through to
+// next.
+type BarBuilder struct {
+	Sink[Bar]
+	symbol   string
+	interval string
+	length   int64
+	bar      *barAccumulator
+}
+
+// barAccumulator is the bar in progress.
+type barAccumulator struct {
+	bar                                                      Bar
+	open, high, low, close                                   *big.Rat
+	volume, quoteVolume, takerBuyVolume, takerBuyQuoteVolume *big.Rat
+	// qtyPlaces and pricePlaces are the most decimal places seen, so volumes are formatted exactly
+	qtyPlaces, pricePlaces int
+}
+
+// NewBarBuilder creates a builder of symbol's bars of interval (one of BarIntervals), writing them to next.
+func NewBarBuilder(symbol, interval string, next Sink[Bar]) (*BarBuilder, error) {
+	length, ok := BarIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unknown bar interval %q", interval)
+	}
+	return &BarBuilder{Sink: next, symbol: symbol, interval: interval, length: length.Milliseconds()}, nil
+}
+
+// Write adds a trade to its bar, first writing the bar in progress if the trade starts a later one.
+func (b *BarBuilder) Write(trade Trade) error {
+	at := trade.TradeTime
+	if at == 0 {
+		at = trade.EventTime
+	}
+	open := at - at%b.length
+	if b.bar != nil && open < b.bar.bar.OpenTime {
+		DefaultMetrics.Add("binance_bar_late_trades_total", Labels{"symbol": b.symbol, "interval": b.interval}, 1)
+		return nil
+	}
+	var err error
+	if b.bar != nil && open > b.bar.bar.OpenTime {
+		err = b.flush(false)
+	}
+	if b.bar == nil {
+		b.bar = &barAccumulator{bar: Bar{Symbol: b.symbol, Interval: b.interval, OpenTime: open, CloseTime: open + b.length - 1}}
+	}
+	b.bar.add(trade)
+	return err
+}
+
+// Close writes the bar in progress, marked partial, and closes the next sink.
+func (b *BarBuilder) Close() error {
+	err := b.flush(true)
+	if closeErr := b.Sink.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// flush writes the bar in progress, if any.
+func (b *BarBuilder) flush(partial bool) error {
+	if b.bar == nil {
+		return nil
+	}
+	bar := b.bar.result()
+	bar.Partial = partial
+	b.bar = nil
+	return b.Sink.Write(bar)
+}
+
+// add adds a trade to the bar. Trades whose price or quantity is not a decimal number are left out.
+func (a *barAccumulator) add(trade Trade) {
+	if !IsDecimalString(trade.Price) || !IsDecimalString(trade.Quantity) {
+		return
+	}
+	price, _ := new(big.Rat).SetString(trade.Price)
+	qty, _ := new(big.Rat).SetString(trade.Quantity)
+	notional := new(big.Rat).Mul(price, qty)
+	if a.open == nil {
+		a.open, a.high, a.low = price, price, price
+		a.volume, a.quoteVolume = new(big.Rat), new(big.Rat)
+		a.takerBuyVolume, a.takerBuyQuoteVolume = new(big.Rat), new(big.Rat)
+		a.bar.FirstTradeID = trade.TradeID
+	}
+	if price.Cmp(a.high) > 0 {
+		a.high = price
+	}
+	if price.Cmp(a.low) < 0 {
+		a.low = price
+	}
+	a.close = price
+	a.volume.Add(a.volume, qty)
+	a.quoteVolume.Add(a.quoteVolume, notional)
+	if !trade.IsBuyerMaker {
+		a.takerBuyVolume.Add(a.takerBuyVolume, qty)
+		a.takerBuyQuoteVolume.Add(a.takerBuyQuoteVolume, notional)
+	}
+	a.pricePlaces = max(a.pricePlaces, decimalPlaces(trade.Price))
+	a.qtyPlaces = max(a.qtyPlaces, decimalPlaces(trade.Quantity))
+	a.bar.Trades++
+	a.bar.LastTradeID = trade.TradeID
+}
+
+// result returns the bar of the trades added so far.
+func (a *barAccumulator) result() Bar {
+	bar := a.bar
+	if a.open == nil {
+		return bar
+	}
+	bar.Open = formatRat(a.open, a.pricePlaces)
+	bar.High = formatRat(a.high, a.pricePlaces)
+	bar.Low = formatRat(a.low, a.pricePlaces)
+	bar.Close = formatRat(a.close, a.pricePlaces)
+	bar.Volume = formatRat(a.volume, a.qtyPlaces)
+	bar.QuoteVolume = formatRat(a.quoteVolume, a.pricePlaces+a.qtyPlaces)
+	bar.TakerBuyVolume = formatRat(a.takerBuyVolume, a.qtyPlaces)
+	bar.TakerBuyQuoteVolume = formatRat(a.takerBuyQuoteVolume, a.pricePlaces+a.qtyPlaces)
+	return bar
+}
+
+// formatRat formats r, which has at most places decimal places, exactly, trimming trailing zeros after the point.
+func formatRat(r *big.Rat, places int) string {
+	s := r.FloatString(places)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
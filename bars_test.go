@@ -0,0 +1,87 @@
+package gobinapi
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var _ Sink[Trade] = (*BarBuilder)(nil)
+
+func TestBarBuilder_AggregatesTradesIntoAlignedBars(t *testing.T) {
+	next := &fakeSink{}
+	b, err := NewBarBuilder("BTCUSDT", "1m", UntypedSink[Bar](next))
+	if err != nil {
+		t.Fatalf("failed to create bar builder: %v", err)
+	}
+	minute := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) int64 { return minute.Add(d).UnixMilli() }
+	for _, trade := range []Trade{
+		{TradeID: 1, TradeTime: at(5 * time.Second), Price: "100.5", Quantity: "0.2"},
+		{TradeID: 2, TradeTime: at(20 * time.Second), Price: "101", Quantity: "0.10", IsBuyerMaker: true},
+		{TradeID: 3, TradeTime: at(59 * time.Second), Price: "99.25", Quantity: "1"},
+		{TradeID: 4, TradeTime: at(90 * time.Second), Price: "100", Quantity: "2"},
+		// Late for the first bar, which has been written already
+		{TradeID: 5, TradeTime: at(58 * time.Second), Price: "100", Quantity: "1"},
+	} {
+		if err := b.Write(trade); err != nil {
+			t.Fatalf("failed to write trade %d: %v", trade.TradeID, err)
+		}
+	}
+	if len(next.records) != 1 {
+		t.Fatalf("expected the first bar once the second started, got %d bars", len(next.records))
+	}
+	want := Bar{Symbol: "BTCUSDT", Interval: "1m", OpenTime: minute.UnixMilli(), CloseTime: at(time.Minute) - 1,
+		Open: "100.5", High: "101", Low: "99.25", Close: "99.25", Volume: "1.3", QuoteVolume: "129.45", Trades: 3,
+		TakerBuyVolume: "1.2", TakerBuyQuoteVolume: "119.35", FirstTradeID: 1, LastTradeID: 3}
+	if got := next.records[0].(Bar); got != want {
+		t.Errorf("expected bar %+v, got %+v", want, got)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("failed to close bar builder: %v", err)
+	}
+	if len(next.records) != 2 || len(next.calls) != 1 || next.calls[0] != "close" {
+		t.Fatalf("expected the bar in progress to be written before closing, got %d bars and calls %v", len(next.records), next.calls)
+	}
+	if last := next.records[1].(Bar); !last.Partial || last.OpenTime != at(time.Minute) || last.Trades != 1 || last.Volume != "2" {
+		t.Errorf("expected a partial second bar of one trade, got %+v", last)
+	}
+}
+
+func TestBarBuilder_RecordsBars(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	if _, err := NewBarBuilder("BTCUSDT", "2m", nil); err == nil {
+		t.Error("expected an unknown interval to be rejected")
+	}
+	rec, err := NewRecorder[Bar]("BTCUSDT", BarDataType("1s"), 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	b, err := NewBarBuilder("BTCUSDT", "1s", rec)
+	if err != nil {
+		t.Fatalf("failed to create bar builder: %v", err)
+	}
+	second := time.Date(2025, 2, 19, 11, 59, 0, 0, time.UTC).UnixMilli()
+	for i := int64(0); i < 3; i++ {
+		if err := b.Write(Trade{TradeID: i + 1, TradeTime: second + i*1000 + 500, Price: "100", Quantity: "1"}); err != nil {
+			t.Fatalf("failed to write trade: %v", err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("failed to close bar builder: %v", err)
+	}
+
+	path := filepath.Join(".", BuildFileName("bar_1s", "BTCUSDT", time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)))
+	records, err := ReadRecordingFile(BarDataType("1s"), path)
+	if err != nil {
+		t.Fatalf("failed to read bars: %v", err)
+	}
+	if len(records) != 3 || records[2].(Bar).OpenTime != second+2000 || !records[2].(Bar).Partial || records[1].(Bar).Partial {
+		t.Errorf("expected three 1s bars with the last partial, got %+v", records)
+	}
+	if interval, ok := BarInterval("bar_1s"); !ok || interval != "1s" {
+		t.Errorf("expected bar_1s to be a bar data type, got %q %v", interval, ok)
+	}
+}
@@ -0,0 +1,142 @@
+package gobinapi
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EnrichBestPrice is a pure function returning bp with Mid, Spread and SpreadBps (spread relative to mid, in basis
+// points) computed from its bid and ask. They are left nil when either side is missing or not a positive price,
+// e.g. while one side of the book is empty.
+func EnrichBestPrice(bp BestPrice) BestPrice {
+	bid, err1 := strconv.ParseFloat(bp.BidPrice, 64)
+	ask, err2 := strconv.ParseFloat(bp.AskPrice, 64)
+	if err1 != nil || err2 != nil || bid <= 0 || ask <= 0 {
+		return bp
+	}
+	mid := (bid + ask) / 2
+	spread := ask - bid
+	spreadBps := spread / mid * 1e4
+	bp.Mid, bp.Spread, bp.SpreadBps = &mid, &spread, &spreadBps
+	return bp
+}
+
+// BestPriceEnricher is a RecorderWriter that applies EnrichBestPrice to best prices before passing them on to Next.
+type BestPriceEnricher struct {
+	Next RecorderWriter[BestPrice]
+}
+
+// Write enriches record and writes it to Next.
+func (e BestPriceEnricher) Write(record BestPrice) error {
+	return e.Next.Write(EnrichBestPrice(record))
+}
+
+// BestPriceChanged is a pure function reporting whether cur differs from prev in bid or ask price or quantity.
+// Update IDs and derived fields are ignored.
+func BestPriceChanged(prev, cur BestPrice) bool {
+	return prev.BidPrice != cur.BidPrice || prev.BidQty != cur.BidQty ||
+		prev.AskPrice != cur.AskPrice || prev.AskQty != cur.AskQty
+}
+
+// BestPriceChangeFilter is a RecorderWriter that passes a best price on to Next only when it differs from the last
+// one passed on (see BestPriceChanged), which cuts file sizes substantially on quiet pairs. An unchanged best price
+// is still passed on once Keyframe has elapsed since the last write, so readers can tell a quiet book from a gap in
+// the recording. A filter tracks one symbol; use one per recorder.
+type BestPriceChangeFilter struct {
+	Next     RecorderWriter[BestPrice]
+	Keyframe time.Duration
+
+	mu        sync.Mutex
+	last      BestPrice
+	lastWrite time.Time
+	written   bool
+}
+
+// NewBestPriceChangeFilter creates a filter in front of next forcing a keyframe at least every keyframe interval.
+func NewBestPriceChangeFilter(next RecorderWriter[BestPrice], keyframe time.Duration) *BestPriceChangeFilter {
+	return &BestPriceChangeFilter{Next: next, Keyframe: keyframe}
+}
+
+// Write passes record on to Next unless it equals the previous one and no keyframe is due.
+func (f *BestPriceChangeFilter) Write(bp BestPrice) error {
+	now := NowFunc()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.written && !BestPriceChanged(f.last, bp) && now.Sub(f.lastWrite) < f.Keyframe {
+		return nil
+	}
+	if err := f.Next.Write(bp); err != nil {
+		return err
+	}
+	f.last, f.lastWrite, f.written = bp, now, true
+	return nil
+}
+
+// BestPriceSampler is a RecorderWriter that passes at most one best price per Interval of local time on to Next,
+// dropping the others, for recordings that need the book ticker's level but not every tick. A best price is passed
+// on as soon as Interval has elapsed since the last one, so the recorded state lags the book by at most Interval
+// while updates keep arriving. A sampler tracks one symbol; use one per recorder.
+type BestPriceSampler struct {
+	Next     RecorderWriter[BestPrice]
+	Interval time.Duration
+
+	mu        sync.Mutex
+	lastWrite time.Time
+	written   bool
+}
+
+// NewBestPriceSampler creates a sampler in front of next passing on at most maxPerSecond best prices a second.
+func NewBestPriceSampler(next RecorderWriter[BestPrice], maxPerSecond int) *BestPriceSampler {
+	return &BestPriceSampler{Next: next, Interval: time.Second / time.Duration(maxPerSecond)}
+}
+
+// Write passes record on to Next unless the last one was passed on less than Interval ago.
+func (s *BestPriceSampler) Write(bp BestPrice) error {
+	now := NowFunc()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.written && now.Sub(s.lastWrite) < s.Interval {
+		return nil
+	}
+	if err := s.Next.Write(bp); err != nil {
+		return err
+	}
+	s.lastWrite, s.written = now, true
+	return nil
+}
+
+// BestPriceConflation selects how an instrument's best prices are thinned out before they are recorded.
+type BestPriceConflation struct {
+	// ChangeOnly records a best price only when it changed (see BestPriceChangeFilter).
+	ChangeOnly bool `json:"change_only" yaml:"change_only"`
+	// MaxPerSecond records at most that many best prices a second (see BestPriceSampler). Zero records every one.
+	MaxPerSecond int `json:"max_per_second,omitempty" yaml:"max_per_second"`
+}
+
+// BestPriceConflationFor returns the conflation of instrument's best prices: its entry in BestPriceConflation, or
+// BestPriceChangeOnly and BestPriceMaxPerSecond if it has none.
+func (cfg Config) BestPriceConflationFor(instrument string) BestPriceConflation {
+	if c, ok := cfg.BestPriceConflation[instrument]; ok {
+		return c
+	}
+	return BestPriceConflation{ChangeOnly: cfg.BestPriceChangeOnly, MaxPerSecond: cfg.BestPriceMaxPerSecond}
+}
+
+// ConflateBestPrices returns the writer recording instrument's best prices to rec according to
+// BestPriceConflationFor, or nil if every best price is recorded. Sampling comes first, so the change filter compares
+// against what was actually recorded.
+func (cfg Config) ConflateBestPrices(instrument string, rec RecorderWriter[BestPrice]) RecorderWriter[BestPrice] {
+	c := cfg.BestPriceConflationFor(instrument)
+	if !c.ChangeOnly && c.MaxPerSecond <= 0 {
+		return nil
+	}
+	w := rec
+	if c.ChangeOnly {
+		w = NewBestPriceChangeFilter(w, cfg.BestPriceKeyframe)
+	}
+	if c.MaxPerSecond > 0 {
+		w = NewBestPriceSampler(w, c.MaxPerSecond)
+	}
+	return w
+}
@@ -0,0 +1,149 @@
+package gobinapi
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnrichBestPrice(t *testing.T) {
+	bp := EnrichBestPrice(BestPrice{Symbol: "BTCUSDT", BidPrice: "99.99", AskPrice: "100.01"})
+	if bp.Mid == nil || bp.Spread == nil || bp.SpreadBps == nil {
+		t.Fatalf("expected all derived fields, got %+v", bp)
+	}
+	if math.Abs(*bp.Mid-100) > 1e-9 || math.Abs(*bp.Spread-0.02) > 1e-9 || math.Abs(*bp.SpreadBps-2) > 1e-9 {
+		t.Errorf("expected mid 100, spread 0.02 and 2 bps, got %v, %v and %v", *bp.Mid, *bp.Spread, *bp.SpreadBps)
+	}
+
+	for _, bad := range []BestPrice{
+		{BidPrice: "0", AskPrice: "100"},
+		{BidPrice: "", AskPrice: "100"},
+		{BidPrice: "100", AskPrice: "abc"},
+	} {
+		if got := EnrichBestPrice(bad); got.Mid != nil || got.Spread != nil || got.SpreadBps != nil {
+			t.Errorf("expected no derived fields for %+v, got %+v", bad, got)
+		}
+	}
+}
+
+func TestBestPriceEnricher_RoundTripsThroughParquet(t *testing.T) {
+	rec := &FakeBestPriceRecorder{}
+	if err := (BestPriceEnricher{Next: rec}).Write(BestPrice{UpdateID: 1, BidPrice: "10", AskPrice: "12"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if len(rec.GetRecords()) != 1 {
+		t.Fatalf("expected 1 record passed on, got %d", len(rec.GetRecords()))
+	}
+	enriched := rec.GetRecords()[0]
+	if enriched.Mid == nil || *enriched.Mid != 11 {
+		t.Fatalf("expected mid 11, got %+v", enriched)
+	}
+
+	// Enriched and plain rows share a file; the derived columns are null for the plain one
+	path := filepath.Join(t.TempDir(), "BTCUSDT_bestPrice_2025-02-19.parquet")
+	if err := WriteParquetFile(path, []BestPrice{enriched, {UpdateID: 2, BidPrice: "10", AskPrice: "12"}}); err != nil {
+		t.Fatalf("WriteParquetFile: %v", err)
+	}
+	records, err := ReadRecordingFile("bestPrice", path)
+	if err != nil {
+		t.Fatalf("ReadRecordingFile: %v", err)
+	}
+	first, second := records[0].(BestPrice), records[1].(BestPrice)
+	if first.SpreadBps == nil || math.Abs(*first.SpreadBps-2/11.0*1e4) > 1e-6 {
+		t.Errorf("expected spread bps to round trip, got %+v", first)
+	}
+	if second.Mid != nil {
+		t.Errorf("expected a null mid for the plain row, got %v", *second.Mid)
+	}
+
+	var csvOut bytes.Buffer
+	if err := WriteRecordsCSV(&csvOut, records); err != nil {
+		t.Fatalf("WriteRecordsCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if !strings.HasSuffix(lines[0], ",mid,spread,spread_bps,conn_id,conn_generation") || !strings.HasSuffix(lines[2], ",,,,0") {
+		t.Errorf("unexpected CSV output:\n%s", csvOut.String())
+	}
+}
+
+func TestBestPriceChangeFilter_WritesChangesAndKeyframes(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	now := start
+	origNow := NowFunc
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = origNow }()
+
+	rec := &FakeBestPriceRecorder{}
+	f := NewBestPriceChangeFilter(rec, time.Minute)
+	quote := func(id int64, bid, bidQty string) BestPrice {
+		return BestPrice{UpdateID: id, BidPrice: bid, BidQty: bidQty, AskPrice: "101", AskQty: "1"}
+	}
+	steps := []struct {
+		after time.Duration
+		bp    BestPrice
+	}{
+		{0, quote(1, "100", "1")},                // first record is always written
+		{time.Second, quote(2, "100", "1")},      // unchanged: skipped
+		{2 * time.Second, quote(3, "100", "2")},  // size changed
+		{30 * time.Second, quote(4, "100", "2")}, // unchanged: skipped
+		{62 * time.Second, quote(5, "100", "2")}, // unchanged, but a keyframe is due
+		{63 * time.Second, quote(6, "99", "2")},  // price changed
+	}
+	for _, s := range steps {
+		now = start.Add(s.after)
+		if err := f.Write(s.bp); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	var ids []int64
+	for _, bp := range rec.GetRecords() {
+		ids = append(ids, bp.UpdateID)
+	}
+	if fmt.Sprint(ids) != "[1 3 5 6]" {
+		t.Errorf("expected updates [1 3 5 6] to be written, got %v", ids)
+	}
+}
+
+func TestBestPriceSampler_WritesAtMostNPerSecond(t *testing.T) {
+	clock := useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	rec := &FakeBestPriceRecorder{}
+	s := NewBestPriceSampler(rec, 4)
+	for id := int64(1); id <= 10; id++ {
+		if err := s.Write(BestPrice{UpdateID: id}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		clock.Advance(100 * time.Millisecond)
+	}
+	var ids []int64
+	for _, bp := range rec.GetRecords() {
+		ids = append(ids, bp.UpdateID)
+	}
+	if fmt.Sprint(ids) != "[1 4 7 10]" {
+		t.Errorf("expected one update per 250ms, [1 4 7 10], got %v", ids)
+	}
+}
+
+func TestConfig_ConflateBestPricesPerInstrument(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	cfg := DefaultConfig()
+	cfg.BestPriceMaxPerSecond = 1
+	cfg.BestPriceConflation = map[string]BestPriceConflation{"ETHUSDT": {}, "BNBUSDT": {ChangeOnly: true, MaxPerSecond: 2}}
+	rec := &FakeBestPriceRecorder{}
+	if w := cfg.ConflateBestPrices("ETHUSDT", rec); w != nil {
+		t.Errorf("expected every ETHUSDT best price to be recorded, got %T", w)
+	}
+	if w, ok := cfg.ConflateBestPrices("BTCUSDT", rec).(*BestPriceSampler); !ok || w.Interval != time.Second {
+		t.Errorf("expected the default sampling for BTCUSDT, got %+v", w)
+	}
+	w, ok := cfg.ConflateBestPrices("BNBUSDT", rec).(*BestPriceSampler)
+	if !ok || w.Interval != 500*time.Millisecond {
+		t.Fatalf("expected BNBUSDT sampled twice a second, got %+v", w)
+	}
+	if filter, ok := w.Next.(*BestPriceChangeFilter); !ok || filter.Keyframe != cfg.BestPriceKeyframe {
+		t.Errorf("expected the sampler in front of a change filter, got %T", w.Next)
+	}
+}
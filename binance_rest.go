@@ -1,4 +1,4 @@
-package main
+package gobinapi
 
 import (
 	"context"
@@ -10,6 +10,10 @@ import (
 	"time"
 )
 
+// RESTBaseURL is the base URL of the Binance REST API. Run sets it from Config.RESTEndpoints or Config.Testnet; tests
+// point it at a local mock server.
+var RESTBaseURL = "https://api.binance.com"
+
 // orderBookSnapshotResponse defines the JSON structure returned by the Binance REST API.
 type orderBookSnapshotResponse struct {
 	LastUpdateID int64      `json:"lastUpdateId"`
@@ -57,43 +61,114 @@ func parseOrderBookSnapshot(data []byte) (*OrderBookSnapshot, error) {
 // FetchOrderBookSnapshot makes an HTTP GET request to Binance's REST API for the order book snapshot
 // of the given instrument. It uses the provided http.Client so that it can be easily mocked in tests.
 func FetchOrderBookSnapshot(client *http.Client, instrument string) (*OrderBookSnapshot, error) {
-	url := fmt.Sprintf("https://api.binance.com/api/v3/depth?symbol=%s&limit=100", instrument)
-	resp, err := client.Get(url)
+	return FetchOrderBookSnapshotLimit(client, instrument, 100)
+}
+
+// FetchOrderBookSnapshotLimit is like FetchOrderBookSnapshot but requests limit levels per side. Deeper snapshots
+// cost more request weight (see DepthWeight); the weight reported by the exchange, and any 429 or 418 backoff, is
+// fed to DefaultWeightTracker.
+func FetchOrderBookSnapshotLimit(client *http.Client, instrument string, limit int) (*OrderBookSnapshot, error) {
+	return FetchOrderBookSnapshotContext(context.Background(), client, instrument, limit)
+}
+
+// FetchOrderBookSnapshotContext is FetchOrderBookSnapshotLimit with retries (see SnapshotRetries) that stop when
+// ctx is cancelled.
+func FetchOrderBookSnapshotContext(ctx context.Context, client *http.Client, instrument string, limit int) (*OrderBookSnapshot, error) {
+	url := fmt.Sprintf("%s/api/v3/depth?symbol=%s&limit=%d", RESTBaseURL, instrument, limit)
+	return fetchOrderBookSnapshot(ctx, client, url, DepthWeight(limit))
+}
+
+// SnapshotRetries is how many times a snapshot request is retried after a 429 or 5xx response. A 429 is retried
+// once DefaultWeightTracker's Retry-After backoff has passed, a 5xx after SnapshotRetryBackoff, doubled for every
+// further retry. A 418 is not retried.
+var SnapshotRetries = 3
+
+// SnapshotRetryBackoff is the delay before the first retry of a snapshot after a 5xx response.
+var SnapshotRetryBackoff = time.Second
+
+// ErrIPBanned is returned, wrapped, for requests answered 418: the exchange has banned the IP for sending requests
+// after 429s. DefaultWeightTracker then holds up every REST request until the ban ends, like a tripped circuit
+// breaker.
+var ErrIPBanned = errors.New("IP banned by the exchange")
+
+// fetchOrderBookSnapshot fetches and parses a depth snapshot of the given request weight from url, retrying as
+// SnapshotRetries describes. The caller has reserved the weight of the first attempt; retries wait for theirs.
+func fetchOrderBookSnapshot(ctx context.Context, client *http.Client, url string, weight int) (*OrderBookSnapshot, error) {
+	backoff := SnapshotRetryBackoff
+	for attempt := 0; ; attempt++ {
+		snapshot, status, err := getOrderBookSnapshot(ctx, client, url)
+		switch {
+		case err == nil:
+			return snapshot, nil
+		case attempt == SnapshotRetries:
+			return nil, err
+		case status == http.StatusTooManyRequests:
+			// The tracker holds up the retry until the Retry-After delay has passed
+		case status >= 500:
+			if err := sleepContext(ctx, backoff); err != nil {
+				return nil, err
+			}
+			backoff *= 2
+		default:
+			return nil, err
+		}
+		if err := DefaultWeightTracker.Wait(ctx, weight); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// getOrderBookSnapshot makes one snapshot request to url, returning the HTTP status along with any error.
+func getOrderBookSnapshot(ctx context.Context, client *http.Client, url string) (*OrderBookSnapshot, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch snapshot: %w", err)
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch snapshot: %w", err)
 	}
 	defer resp.Body.Close()
-
+	// A 429 or 418 holds up the snapshots of every symbol, which are paced through DefaultWeightTracker
+	backoff := DefaultWeightTracker.ObserveResponse(resp, NowFunc())
+	if resp.StatusCode == http.StatusTeapot {
+		return nil, resp.StatusCode, fmt.Errorf("%w, backing off for %s", ErrIPBanned, backoff)
+	}
+	if backoff > 0 {
+		return nil, resp.StatusCode, fmt.Errorf("non-OK HTTP status: %s, backing off for %s", resp.Status, backoff)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("non-OK HTTP status: %s", resp.Status)
+		return nil, resp.StatusCode, fmt.Errorf("non-OK HTTP status: %s", resp.Status)
 	}
 
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, resp.StatusCode, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	snapshot, err := parseOrderBookSnapshot(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+		return nil, resp.StatusCode, fmt.Errorf("failed to parse snapshot: %w", err)
 	}
 
-	return snapshot, nil
+	return snapshot, resp.StatusCode, nil
 }
 
 // StartOrderBookSnapshotFetcher periodically fetches the order book snapshot for a given instrument
 // at the specified interval. It sends each successfully fetched snapshot to the provided channel.
 // The function is designed with a functional core (FetchOrderBookSnapshot and parseOrderBookSnapshot) and an
 // imperative shell (ticker-based scheduling and channel handling), enabling easier testing of the core logic.
+// Every fetcher fires on its own ticker; to record many instruments use a SnapshotScheduler, which staggers and
+// paces their snapshots within one weight budget.
 func StartOrderBookSnapshotFetcher(ctx context.Context, client *http.Client, instrument string, interval time.Duration, out chan<- OrderBookSnapshot) error {
-	ticker := time.NewTicker(interval)
+	ticker := DefaultClock.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-ticker.C:
+		case <-ticker.C():
 			snapshot, err := FetchOrderBookSnapshot(client, instrument)
 			if err != nil {
 				// In a production setting, consider logging this error using the Logger module.
@@ -1,9 +1,16 @@
-package main
+package gobinapi
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
+
+	"gobinapi_o3/internal/mockbinance"
 )
 
 func TestParseOrderBookSnapshot_ValidInput(t *testing.T) {
@@ -55,7 +62,10 @@ func TestParseOrderBookSnapshot_InvalidInput(t *testing.T) {
 	}
 }
 
-func TestFetchOrderBookSnapshot_LiveData(t *testing.T) {
+func TestFetchOrderBookSnapshot_MockServer(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetSnapshot("BTCUSDT", mockbinance.SnapshotMessage(1027024,
+		[]mockbinance.Level{{"95000.00", "0.5"}}, []mockbinance.Level{{"95000.01", "1.2"}}))
 	// Create an HTTP client with a timeout of 10 seconds
 	client := &http.Client{
 		Timeout: 10 * time.Second,
@@ -63,7 +73,7 @@ func TestFetchOrderBookSnapshot_LiveData(t *testing.T) {
 
 	snapshot, err := FetchOrderBookSnapshot(client, "BTCUSDT")
 	if err != nil {
-		t.Fatalf("Failed to fetch snapshot from live API: %v", err)
+		t.Fatalf("Failed to fetch snapshot from the mock server: %v", err)
 	}
 
 	if snapshot.LastUpdateID == 0 {
@@ -78,3 +88,89 @@ func TestFetchOrderBookSnapshot_LiveData(t *testing.T) {
 		t.Fatalf("Expected non-empty asks array")
 	}
 }
+
+func TestFetchOrderBookSnapshot_RateLimitHoldsUpTracker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	oldURL, oldTracker, oldRetries := RESTBaseURL, DefaultWeightTracker, SnapshotRetries
+	RESTBaseURL, DefaultWeightTracker, SnapshotRetries = srv.URL, NewWeightTracker(DefaultRESTWeightLimit), 0
+	defer func() { RESTBaseURL, DefaultWeightTracker, SnapshotRetries = oldURL, oldTracker, oldRetries }()
+
+	if _, err := FetchOrderBookSnapshotLimit(srv.Client(), "BTCUSDT", 1000); err == nil {
+		t.Fatal("expected a rate limited snapshot to fail")
+	}
+	if wait := DefaultWeightTracker.Reserve(1, NowFunc()); wait <= 0 || wait > 5*time.Second {
+		t.Errorf("expected further requests to wait out the Retry-After, got %s", wait)
+	}
+}
+
+func TestFetchOrderBookSnapshot_RetriesRateLimitsAndServerErrors(t *testing.T) {
+	var mu sync.Mutex
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		status := statuses[min(requests, len(statuses)-1)]
+		requests++
+		mu.Unlock()
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "10")
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"lastUpdateId":7,"bids":[["100.0","1.0"]],"asks":[["101.0","2.0"]]}`))
+	}))
+	defer srv.Close()
+	clock := useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	oldURL, oldTracker, oldNow := RESTBaseURL, DefaultWeightTracker, NowFunc
+	RESTBaseURL, DefaultWeightTracker, NowFunc = srv.URL, NewWeightTracker(DefaultRESTWeightLimit), clock.Now
+	defer func() { RESTBaseURL, DefaultWeightTracker, NowFunc = oldURL, oldTracker, oldNow }()
+
+	done := make(chan error, 1)
+	go func() {
+		snapshot, err := FetchOrderBookSnapshotContext(context.Background(), srv.Client(), "BTCUSDT", 100)
+		if err == nil && snapshot.LastUpdateID != 7 {
+			err = fmt.Errorf("unexpected snapshot %+v", snapshot)
+		}
+		done <- err
+	}()
+	// The 503 is retried after SnapshotRetryBackoff, the 429 after its Retry-After
+	waitForWaiters(t, clock, 1)
+	clock.Advance(SnapshotRetryBackoff)
+	waitForWaiters(t, clock, 1)
+	clock.Advance(10 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestFetchOrderBookSnapshot_BanIsNotRetried(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+	oldURL, oldTracker := RESTBaseURL, DefaultWeightTracker
+	RESTBaseURL, DefaultWeightTracker = srv.URL, NewWeightTracker(DefaultRESTWeightLimit)
+	defer func() { RESTBaseURL, DefaultWeightTracker = oldURL, oldTracker }()
+
+	_, err := FetchOrderBookSnapshotLimit(srv.Client(), "BTCUSDT", 100)
+	if !errors.Is(err, ErrIPBanned) {
+		t.Fatalf("expected ErrIPBanned, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected a ban not to be retried, got %d requests", requests)
+	}
+	if wait := DefaultWeightTracker.Reserve(1, NowFunc()); wait < time.Minute {
+		t.Errorf("expected the ban to hold up further requests, got %s", wait)
+	}
+}
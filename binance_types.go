@@ -1,4 +1,4 @@
-package main
+package gobinapi
 
 import (
 	"encoding/json"
@@ -12,55 +12,170 @@ import (
 // It contains fields like event type, event time, trade ID, price, quantity, buyer/seller order IDs, trade time, and a flag indicating if the buyer was the market maker.
 type Trade struct {
 	EventType     string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	EventTime     int64  `json:"E" parquet:"name=event_time, type=INT64"`
+	EventTime     int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
 	TradeID       int64  `json:"t" parquet:"name=trade_id, type=INT64"`
-	Price         string `json:"p" parquet:"name=price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	Quantity      string `json:"q" parquet:"name=quantity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Price         string `json:"p" decimal:"true" parquet:"name=price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Quantity      string `json:"q" decimal:"true" parquet:"name=quantity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	BuyerOrderID  int64  `json:"b" parquet:"name=buyer_order_id, type=INT64"`
 	SellerOrderID int64  `json:"a" parquet:"name=seller_order_id, type=INT64"`
-	TradeTime     int64  `json:"T" parquet:"name=trade_time, type=INT64"`
+	TradeTime     int64  `json:"T" timestamp:"millis" parquet:"name=trade_time, type=INT64"`
 	IsBuyerMaker  bool   `json:"m" parquet:"name=is_buyer_maker, type=BOOLEAN"`
+
+	// Side and Notional are derived when the trade is decoded, see DeriveTradeFields.
+	Side     string `json:"side,omitempty" parquet:"name=side, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Notional string `json:"notional,omitempty" parquet:"name=notional, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+
+	// Source is SourceREST for trades fetched by a Backfiller, SourceVision for trades read from the bulk archives and
+	// empty for trades received on the stream.
+	Source string `json:"source,omitempty" parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 }
 
 // AggTrade represents an aggregated trade event from Binance.
 // It includes the event type, event time, symbol, aggregated trade ID, price, quantity, first and last trade IDs, trade time, and buyer maker indicator.
 type AggTrade struct {
 	EventType    string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	EventTime    int64  `json:"E" parquet:"name=event_time, type=INT64"`
+	EventTime    int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
 	Symbol       string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	AggTradeID   int64  `json:"a" parquet:"name=agg_trade_id, type=INT64"`
-	Price        string `json:"p" parquet:"name=price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	Quantity     string `json:"q" parquet:"name=quantity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Price        string `json:"p" decimal:"true" parquet:"name=price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Quantity     string `json:"q" decimal:"true" parquet:"name=quantity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	FirstTradeID int64  `json:"f" parquet:"name=first_trade_id, type=INT64"`
 	LastTradeID  int64  `json:"l" parquet:"name=last_trade_id, type=INT64"`
-	TradeTime    int64  `json:"T" parquet:"name=trade_time, type=INT64"`
+	TradeTime    int64  `json:"T" timestamp:"millis" parquet:"name=trade_time, type=INT64"`
 	IsBuyerMaker bool   `json:"m" parquet:"name=is_buyer_maker, type=BOOLEAN"`
+
+	// Side and Notional are derived when the trade is decoded, see DeriveTradeFields.
+	Side     string `json:"side,omitempty" parquet:"name=side, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Notional string `json:"notional,omitempty" parquet:"name=notional, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+
+	// Source is SourceREST for aggregate trades fetched by a Backfiller, SourceVision for those read from the bulk
+	// archives and empty for those received on the stream.
+	Source string `json:"source,omitempty" parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 }
 
 // PriceLevel represents a price level entry in the order book with a price and its associated quantity.
 type PriceLevel struct {
-	Price    string `parquet:"name=price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	Quantity string `parquet:"name=quantity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Price    string `decimal:"true" parquet:"name=price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Quantity string `decimal:"true" parquet:"name=quantity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 }
 
 // OrderBookDiff represents a differential update to the order book as received from Binance.
 type OrderBookDiff struct {
 	EventType     string       `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	EventTime     int64        `json:"E" parquet:"name=event_time, type=INT64"`
+	EventTime     int64        `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
 	Symbol        string       `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	FirstUpdateID int64        `json:"U" parquet:"name=first_update_id, type=INT64"`
 	FinalUpdateID int64        `json:"u" parquet:"name=final_update_id, type=INT64"`
 	Bids          []PriceLevel `json:"b" parquet:"name=bids, repetitiontype=REPEATED"`
 	Asks          []PriceLevel `json:"a" parquet:"name=asks, repetitiontype=REPEATED"`
+
+	// TransactionTime and PrevFinalUpdateID are only sent by USD-M futures, whose diffs are chained through the
+	// previous diff's final update ID (see ProcessFuturesOrderBookDiffMessage). They are zero for spot.
+	TransactionTime   int64 `json:"T,omitempty" timestamp:"millis" parquet:"name=transaction_time, type=INT64"`
+	PrevFinalUpdateID int64 `json:"pu,omitempty" parquet:"name=prev_final_update_id, type=INT64"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
 }
 type BestPrice struct {
 	EventType string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	UpdateID  int64  `json:"u" parquet:"name=update_id, type=INT64"`
 	Symbol    string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	BidPrice  string `json:"b" parquet:"name=bid_price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	BidQty    string `json:"B" parquet:"name=bid_qty, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	AskPrice  string `json:"a" parquet:"name=ask_price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	AskQty    string `json:"A" parquet:"name=ask_qty, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	BidPrice  string `json:"b" decimal:"true" parquet:"name=bid_price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	BidQty    string `json:"B" decimal:"true" parquet:"name=bid_qty, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	AskPrice  string `json:"a" decimal:"true" parquet:"name=ask_price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	AskQty    string `json:"A" decimal:"true" parquet:"name=ask_qty, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+
+	// EventTime and TransactionTime are only sent by USD-M futures and are zero for spot.
+	EventTime       int64 `json:"E,omitempty" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	TransactionTime int64 `json:"T,omitempty" timestamp:"millis" parquet:"name=transaction_time, type=INT64"`
+
+	// Mid, Spread and SpreadBps are derived at write time when enrichment is enabled (see EnrichBestPrice), and
+	// null otherwise.
+	Mid       *float64 `json:"mid,omitempty" parquet:"name=mid, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Spread    *float64 `json:"spread,omitempty" parquet:"name=spread, type=DOUBLE, repetitiontype=OPTIONAL"`
+	SpreadBps *float64 `json:"spread_bps,omitempty" parquet:"name=spread_bps, type=DOUBLE, repetitiontype=OPTIONAL"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// MarkPrice is a USD-M futures mark price update, sent every second with the index price and funding rate.
+type MarkPrice struct {
+	EventType            string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventTime            int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	Symbol               string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	MarkPrice            string `json:"p" decimal:"true" parquet:"name=mark_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	IndexPrice           string `json:"i" decimal:"true" parquet:"name=index_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EstimatedSettlePrice string `json:"P" decimal:"true" parquet:"name=estimated_settle_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	FundingRate          string `json:"r" parquet:"name=funding_rate, type=BYTE_ARRAY, convertedtype=UTF8"`
+	NextFundingTime      int64  `json:"T" timestamp:"millis" parquet:"name=next_funding_time, type=INT64"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// AvgPrice is a spot average price update, the volume-weighted average price of a symbol's trades over the last
+// Interval (5 minutes), which the exchange's percent price filters check orders against. Binance sends one per second.
+type AvgPrice struct {
+	EventType string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventTime int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	Symbol    string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Interval  string `json:"i" parquet:"name=interval, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Price     string `json:"w" decimal:"true" parquet:"name=price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// LastTradeTime is the time of the last trade averaged.
+	LastTradeTime int64 `json:"T" timestamp:"millis" parquet:"name=last_trade_time, type=INT64"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// Kline is a candlestick of a symbol over one interval, as returned by the klines endpoint. Prices and volumes are
+// decimal strings like those of trades; Volume is in base and QuoteVolume in quote units.
+type Kline struct {
+	Symbol              string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Interval            string `json:"i" parquet:"name=interval, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	OpenTime            int64  `json:"t" timestamp:"millis" parquet:"name=open_time, type=INT64"`
+	CloseTime           int64  `json:"T" timestamp:"millis" parquet:"name=close_time, type=INT64"`
+	Open                string `json:"o" parquet:"name=open, type=BYTE_ARRAY, convertedtype=UTF8"`
+	High                string `json:"h" parquet:"name=high, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Low                 string `json:"l" parquet:"name=low, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Close               string `json:"c" parquet:"name=close, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Volume              string `json:"v" parquet:"name=volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+	QuoteVolume         string `json:"q" parquet:"name=quote_volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Trades              int64  `json:"n" parquet:"name=trades, type=INT64"`
+	TakerBuyVolume      string `json:"V" parquet:"name=taker_buy_volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TakerBuyQuoteVolume string `json:"Q" parquet:"name=taker_buy_quote_volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// SymbolInfo is a symbol's entry in the exchange info, recorded once a day as "exchangeInfo" so the instrument
+// metadata of a day sits next to its market data. TickSize, StepSize, MinQty, MaxQty and MinNotional are taken from
+// the price, lot size and notional filters; Filters holds every filter as the exchange returned it, in JSON.
+type SymbolInfo struct {
+	Symbol      string `json:"symbol" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Status      string `json:"status" parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	BaseAsset   string `json:"baseAsset" parquet:"name=base_asset, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	QuoteAsset  string `json:"quoteAsset" parquet:"name=quote_asset, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	TickSize    string `json:"tickSize" parquet:"name=tick_size, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StepSize    string `json:"stepSize" parquet:"name=step_size, type=BYTE_ARRAY, convertedtype=UTF8"`
+	MinQty      string `json:"minQty" parquet:"name=min_qty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	MaxQty      string `json:"maxQty" parquet:"name=max_qty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	MinNotional string `json:"minNotional" parquet:"name=min_notional, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Filters     string `json:"filters" parquet:"name=filters, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// FetchTime is when the exchange info was fetched, in milliseconds.
+	FetchTime int64 `json:"fetchTime" timestamp:"millis" parquet:"name=fetch_time, type=INT64"`
 }
 
 // OrderBookSnapshot represents a full snapshot of the order book as obtained via Binance's REST API.
@@ -83,3 +198,34 @@ func (p *PriceLevel) UnmarshalJSON(data []byte) error {
 	p.Quantity = arr[1]
 	return nil
 }
+
+// UnmarshalJSON decodes a trade event. Binance also sends an unused "M" field, which encoding/json would otherwise
+// match case-insensitively to IsBuyerMaker ("m") and overwrite it; it is absorbed by a shallower field here.
+func (t *Trade) UnmarshalJSON(data []byte) error {
+	type plain Trade
+	var aux struct {
+		plain
+		Ignore bool `json:"M"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*t = Trade(aux.plain)
+	t.Side, t.Notional = DeriveTradeFields(t.Price, t.Quantity, t.IsBuyerMaker)
+	return nil
+}
+
+// UnmarshalJSON decodes an aggregate trade event, ignoring the unused "M" field as for Trade.
+func (a *AggTrade) UnmarshalJSON(data []byte) error {
+	type plain AggTrade
+	var aux struct {
+		plain
+		Ignore bool `json:"M"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*a = AggTrade(aux.plain)
+	a.Side, a.Notional = DeriveTradeFields(a.Price, a.Quantity, a.IsBuyerMaker)
+	return nil
+}
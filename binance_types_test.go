@@ -1,21 +1,27 @@
-package main
+package gobinapi
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 )
 
 func TestFieldTags(t *testing.T) {
 	expectedTags := map[string]string{
-		"EventType":     "name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"EventTime":     "name=event_time, type=INT64",
-		"TradeID":       "name=trade_id, type=INT64",
-		"Price":         "name=price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"Quantity":      "name=quantity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"BuyerOrderID":  "name=buyer_order_id, type=INT64",
-		"SellerOrderID": "name=seller_order_id, type=INT64",
-		"TradeTime":     "name=trade_time, type=INT64",
-		"IsBuyerMaker":  "name=is_buyer_maker, type=BOOLEAN",
+		"EventType":      "name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"EventTime":      "name=event_time, type=INT64",
+		"TradeID":        "name=trade_id, type=INT64",
+		"Price":          "name=price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"Quantity":       "name=quantity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"BuyerOrderID":   "name=buyer_order_id, type=INT64",
+		"SellerOrderID":  "name=seller_order_id, type=INT64",
+		"TradeTime":      "name=trade_time, type=INT64",
+		"IsBuyerMaker":   "name=is_buyer_maker, type=BOOLEAN",
+		"Side":           "name=side, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"Notional":       "name=notional, type=BYTE_ARRAY, convertedtype=UTF8",
+		"ConnID":         "name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"ConnGeneration": "name=conn_generation, type=INT64",
+		"Source":         "name=source, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
 	}
 
 	tradeType := reflect.TypeOf(Trade{})
@@ -33,16 +39,21 @@ func TestFieldTags(t *testing.T) {
 
 func TestAggTradeFieldTags(t *testing.T) {
 	expectedTags := map[string]string{
-		"EventType":    "name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"EventTime":    "name=event_time, type=INT64",
-		"Symbol":       "name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"AggTradeID":   "name=agg_trade_id, type=INT64",
-		"Price":        "name=price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"Quantity":     "name=quantity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"FirstTradeID": "name=first_trade_id, type=INT64",
-		"LastTradeID":  "name=last_trade_id, type=INT64",
-		"TradeTime":    "name=trade_time, type=INT64",
-		"IsBuyerMaker": "name=is_buyer_maker, type=BOOLEAN",
+		"EventType":      "name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"EventTime":      "name=event_time, type=INT64",
+		"Symbol":         "name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"AggTradeID":     "name=agg_trade_id, type=INT64",
+		"Price":          "name=price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"Quantity":       "name=quantity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"FirstTradeID":   "name=first_trade_id, type=INT64",
+		"LastTradeID":    "name=last_trade_id, type=INT64",
+		"TradeTime":      "name=trade_time, type=INT64",
+		"IsBuyerMaker":   "name=is_buyer_maker, type=BOOLEAN",
+		"Side":           "name=side, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"Notional":       "name=notional, type=BYTE_ARRAY, convertedtype=UTF8",
+		"ConnID":         "name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"ConnGeneration": "name=conn_generation, type=INT64",
+		"Source":         "name=source, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
 	}
 	aggTradeType := reflect.TypeOf(AggTrade{})
 	for i := 0; i < aggTradeType.NumField(); i++ {
@@ -59,13 +70,17 @@ func TestAggTradeFieldTags(t *testing.T) {
 
 func TestOrderBookDiffAndPriceLevelTags(t *testing.T) {
 	expectedDiffTags := map[string]string{
-		"EventType":     "name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"EventTime":     "name=event_time, type=INT64",
-		"Symbol":        "name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"FirstUpdateID": "name=first_update_id, type=INT64",
-		"FinalUpdateID": "name=final_update_id, type=INT64",
-		"Bids":          "name=bids, repetitiontype=REPEATED",
-		"Asks":          "name=asks, repetitiontype=REPEATED",
+		"EventType":         "name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"EventTime":         "name=event_time, type=INT64",
+		"Symbol":            "name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"FirstUpdateID":     "name=first_update_id, type=INT64",
+		"FinalUpdateID":     "name=final_update_id, type=INT64",
+		"Bids":              "name=bids, repetitiontype=REPEATED",
+		"Asks":              "name=asks, repetitiontype=REPEATED",
+		"TransactionTime":   "name=transaction_time, type=INT64",
+		"PrevFinalUpdateID": "name=prev_final_update_id, type=INT64",
+		"ConnID":            "name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"ConnGeneration":    "name=conn_generation, type=INT64",
 	}
 	diffType := reflect.TypeOf(OrderBookDiff{})
 	for i := 0; i < diffType.NumField(); i++ {
@@ -98,13 +113,20 @@ func TestOrderBookDiffAndPriceLevelTags(t *testing.T) {
 
 func TestBestPriceFieldTags(t *testing.T) {
 	expectedTags := map[string]string{
-		"EventType": "name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"UpdateID":  "name=update_id, type=INT64",
-		"Symbol":    "name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"BidPrice":  "name=bid_price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"BidQty":    "name=bid_qty, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"AskPrice":  "name=ask_price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
-		"AskQty":    "name=ask_qty, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"EventType":       "name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"UpdateID":        "name=update_id, type=INT64",
+		"Symbol":          "name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"BidPrice":        "name=bid_price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"BidQty":          "name=bid_qty, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"AskPrice":        "name=ask_price, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"AskQty":          "name=ask_qty, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"EventTime":       "name=event_time, type=INT64",
+		"TransactionTime": "name=transaction_time, type=INT64",
+		"Mid":             "name=mid, type=DOUBLE, repetitiontype=OPTIONAL",
+		"Spread":          "name=spread, type=DOUBLE, repetitiontype=OPTIONAL",
+		"SpreadBps":       "name=spread_bps, type=DOUBLE, repetitiontype=OPTIONAL",
+		"ConnID":          "name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+		"ConnGeneration":  "name=conn_generation, type=INT64",
 	}
 	bestPriceType := reflect.TypeOf(BestPrice{})
 	for i := 0; i < bestPriceType.NumField(); i++ {
@@ -138,3 +160,20 @@ func TestOrderBookSnapshotFieldTags(t *testing.T) {
 		}
 	}
 }
+
+func TestTradeUnmarshalKeepsBuyerMaker(t *testing.T) {
+	var trade Trade
+	if err := json.Unmarshal([]byte(`{"e":"trade","t":1,"p":"1.0","m":false,"M":true}`), &trade); err != nil {
+		t.Fatalf("failed to unmarshal trade: %v", err)
+	}
+	if trade.IsBuyerMaker || trade.TradeID != 1 || trade.Price != "1.0" {
+		t.Errorf("unexpected trade: %+v", trade)
+	}
+	var agg AggTrade
+	if err := json.Unmarshal([]byte(`{"e":"aggTrade","a":2,"m":false,"M":true}`), &agg); err != nil {
+		t.Fatalf("failed to unmarshal aggTrade: %v", err)
+	}
+	if agg.IsBuyerMaker || agg.AggTradeID != 2 {
+		t.Errorf("unexpected aggTrade: %+v", agg)
+	}
+}
@@ -1,17 +1,21 @@
-package main
+package gobinapi
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"log"
 )
 
-// const BASE_STREAM = "stream.binance.com"
-const BASE_STREAM = "data-stream.binance.vision"
+// StreamBaseURL is the scheme, host and port that stream URLs are built from, by default the first of
+// DefaultStreamEndpoints. Config.StreamEndpoints and Config.Testnet choose others for Run; tests point it at a local
+// mock server.
+var StreamBaseURL = DefaultStreamEndpoints[0]
 
 func safeReadMessage(conn *websocket.Conn) (int, []byte, error) {
 	var mt int
@@ -43,55 +47,256 @@ type readResult struct {
 // listenWebSocket connects to the given WebSocket URL, then spawns a goroutine
 // to continuously read messages, sending them over a channel. The main goroutine
 // waits for either context cancellation or messages from that channel.
-func listenWebSocket(ctx context.Context, url string, handler func([]byte) error) error {
-	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to dial websocket %s: %w", url, err)
+func listenWebSocket(ctx context.Context, url string, handler func(msg []byte, session WSSession) error) error {
+	return listenWebSocketStreams(ctx, url, nil, handler)
+}
+
+// ListenStreams connects to the raw stream endpoint and subscribes to streams (e.g. "btcusdt@trade") with a live
+// SUBSCRIBE request, passing every market data message to handler. Acknowledgements and error payloads are not
+// passed on: it returns an error if the subscription is rejected, is not acknowledged within AckTimeout, or the
+// exchange reports an error later on.
+func ListenStreams(ctx context.Context, streams []string, handler func([]byte) error) error {
+	return listenStreams(ctx, StreamBaseURL, streams, handler)
+}
+
+// listenStreams is ListenStreams against the given stream base URL.
+func listenStreams(ctx context.Context, base string, streams []string, handler func([]byte) error) error {
+	if len(streams) == 0 {
+		return fmt.Errorf("no streams to subscribe to")
 	}
-	log.Printf("Successfully connected to %s", url)
-	defer conn.Close()
+	return listenWebSocketStreams(ctx, base+"/ws", streams, func(msg []byte, _ WSSession) error {
+		return handler(msg)
+	})
+}
 
-	readCh := make(chan readResult)
+// listenWebSocketStreams is listenWebSocket that, if streams is not empty, first subscribes to them and then
+// separates control responses from market data. handler is called with each message and the connection's session.
+// The connection is pinged every PingInterval, closed by the liveness watchdog if it stalls, and replaced every
+// ConnectionLifetime by a planned reconnect that overlaps the old and new connections (see handover), so the
+// exchange's 24 hour limit never cuts a stream off.
+func listenWebSocketStreams(ctx context.Context, url string, streams []string, handler func(msg []byte, session WSSession) error) error {
+	stream := streamNameFromURL(url)
+	if len(streams) > 0 {
+		stream = strings.Join(streams, "/")
+	}
+	return listenWebSocketControlled(ctx, url, stream, func() []string { return streams }, nil, handler)
+}
 
-	go func() {
-		defer close(readCh)
+// listenWebSocketControlled is listenWebSocketStreams for a set of streams that changes at runtime: every connection
+// subscribes to streams() when it connects, and the SUBSCRIBE and UNSUBSCRIBE requests received on control are sent
+// on the live connection, with the exchange's answer delivered to the request. stream names the connection in the
+// metrics.
+func listenWebSocketControlled(ctx context.Context, url, stream string, streams func() []string, control <-chan streamControl, handler func(msg []byte, session WSSession) error) error {
+	// Connections that may send requests later need to tell their answers from market data
+	requests := control != nil
 
-		for {
-			// Blocking read with no deadline
-			mt, msg, err := safeReadMessage(conn)
+	// The watchdog is fed by everything the connections receive, including the pings and pongs their read
+	// goroutines handle while the stream itself is quiet
+	var watchdog *streamWatchdog
+	var stalled <-chan time.Time
+	if StreamIdleTimeout > 0 {
+		watchdog = newStreamWatchdog(StreamIdleTimeout, NowFunc())
+		defer watchdog.Stop()
+		stalled = watchdog.C()
+	}
+	touch := func() {
+		if watchdog != nil {
+			watchdog.Touch(NowFunc())
+		}
+	}
 
-			// If an error occurs, send it down the channel and break out
-			if err != nil {
-				readCh <- readResult{mt: mt, msg: msg, err: err}
-				return
-			}
+	cur, err := dialWSConn(ctx, url, stream, streams(), requests, touch)
+	if err != nil {
+		return err
+	}
+	// next is the replacement connection during a planned reconnect, see handover
+	var next *wsConn
+	var h *handover
+	defer func() {
+		cur.close()
+		next.close()
+		if h != nil {
+			h.timer.Stop()
+		}
+	}()
 
-			// Otherwise, send the successfully read message
-			readCh <- readResult{mt: mt, msg: msg, err: nil}
+	var pings <-chan time.Time
+	if PingInterval > 0 {
+		ticker := DefaultClock.NewTicker(PingInterval)
+		defer ticker.Stop()
+		pings = ticker.C()
+	}
+	var lifetime Timer
+	var planned <-chan time.Time
+	plan := func(d time.Duration) {
+		if ConnectionLifetime > 0 {
+			lifetime = DefaultClock.NewTimer(d)
+			planned = lifetime.C()
+		}
+	}
+	plan(ConnectionLifetime)
+	defer func() {
+		if lifetime != nil {
+			lifetime.Stop()
 		}
 	}()
 
+	deliver := func(msg []byte, session WSSession) {
+		DefaultFrameDebugger.Log(stream, msg, session)
+		recordMessage(stream)
+		if err := handler(msg, session); err != nil {
+			log.Printf("handler error: %v", err)
+		}
+	}
+	// abandon drops the replacement connection of a failed planned reconnect and tries again later
+	abandon := func(reason error) {
+		log.Printf("Planned reconnect of %s failed: %v; keeping session %s", url, reason, cur.session.ID)
+		next.close()
+		h.timer.Stop()
+		next, h = nil, nil
+		plan(ReconnectDelay)
+	}
+	// takeOver completes a planned reconnect: the old connection is closed and the new one's buffered messages
+	// are delivered
+	takeOver := func(rest [][]byte) {
+		old := cur
+		cur, next = next, nil
+		h.timer.Stop()
+		h = nil
+		old.close()
+		recordDisconnect(stream, nil)
+		log.Printf("Handed %s over from session %s to session %s", url, old.session.ID, cur.session.ID)
+		for _, msg := range rest {
+			deliver(msg, cur.session)
+		}
+		plan(ConnectionLifetime)
+	}
+
 	for {
+		var handoverTimeout <-chan time.Time
+		if h != nil {
+			handoverTimeout = h.timer.C()
+		}
 		select {
 		case <-ctx.Done():
 			// Context canceled; return
+			recordDisconnect(stream, nil)
 			return ctx.Err()
 
-		case rr, ok := <-readCh:
+		case <-stalled:
+			if !watchdog.Expired(NowFunc()) {
+				stalled = watchdog.C()
+				continue
+			}
+			DefaultMetrics.Add("binance_ws_stalls_total", Labels{"stream": stream}, 1)
+			err := fmt.Errorf("%s: %w", url, ErrStreamStalled)
+			recordDisconnect(stream, err)
+			return err
+
+		case <-pings:
+			cur.ping()
+			next.ping()
+
+		case <-planned:
+			// Connect the replacement while the current connection keeps delivering
+			planned = nil
+			conn, err := dialWSConn(ctx, url, stream, streams(), requests, touch)
+			if err != nil {
+				log.Printf("Planned reconnect of %s failed: %v; keeping session %s", url, err, cur.session.ID)
+				plan(ReconnectDelay)
+				continue
+			}
+			next, h = conn, newHandover()
+
+		case req := <-control:
+			cur.request(req.method, req.params, req.done)
+			// A replacement being handed over subscribed to the set as it was and needs the change too
+			if next != nil {
+				next.request(req.method, req.params, make(chan error, 1))
+			}
+
+		case <-cur.ackC():
+			// Fails the subscription, which is reported below
+			cur.pending.Expire(NowFunc())
+
+		case err := <-cur.subscribedC():
+			cur.acknowledged()
+			if err != nil {
+				err = fmt.Errorf("subscription to %v failed: %w", streams(), err)
+				recordDisconnect(stream, err)
+				return err
+			}
+			log.Printf("Subscribed to %v on %s", streams(), url)
+
+		case <-next.ackC():
+			next.pending.Expire(NowFunc())
+
+		case err := <-next.subscribedC():
+			next.acknowledged()
+			if err != nil {
+				abandon(fmt.Errorf("subscription to %v failed: %w", streams(), err))
+			}
+
+		case <-handoverTimeout:
+			log.Printf("No overlap between sessions %s and %s of %s within %s; switching anyway", cur.session.ID, next.session.ID, url, HandoverTimeout)
+			takeOver(h.buffered)
+
+		case rr, ok := <-next.readC():
+			if !ok {
+				abandon(errors.New("read goroutine ended unexpectedly"))
+				continue
+			}
+			if rr.err != nil {
+				abandon(rr.err)
+				continue
+			}
+			touch()
+			if next.pending != nil {
+				handled, err := next.pending.Resolve(rr.msg)
+				if err != nil {
+					abandon(err)
+					continue
+				}
+				if handled {
+					continue
+				}
+			}
+			if caughtUp, rest := h.fromNew(rr.msg); caughtUp {
+				takeOver(rest)
+			}
+
+		case rr, ok := <-cur.readC():
 			if !ok {
-				return fmt.Errorf("Websocket read goroutine for %s ended unexpectedly", url)
+				err := fmt.Errorf("Websocket read goroutine for %s ended unexpectedly", url)
+				recordDisconnect(stream, err)
+				return err
 			}
 
 			// If the read result had an error, handle it
 			if rr.err != nil {
 				log.Printf("Websocket read error: %v", rr.err)
+				recordDisconnect(stream, rr.err)
 				return rr.err
 			}
+			touch()
+
+			if cur.pending != nil {
+				handled, err := cur.pending.Resolve(rr.msg)
+				if err != nil {
+					recordDisconnect(stream, err)
+					return fmt.Errorf("error on %s: %w", url, err)
+				}
+				if handled {
+					continue
+				}
+			}
 
 			// No error, so handle the message
-			// log.Printf("Read message: %s", string(rr.msg))
-			if err := handler(rr.msg); err != nil {
-				log.Printf("handler error: %v", err)
+			deliver(rr.msg, cur.session)
+			if h != nil {
+				if caughtUp, rest := h.fromOld(rr.msg); caughtUp {
+					takeOver(rest)
+				}
 			}
 		}
 	}
@@ -100,8 +305,18 @@ func listenWebSocket(ctx context.Context, url string, handler func([]byte) error
 // ListenTrade subscribes to Binance trade events for the given symbol using a dedicated WebSocket connection.
 // Incoming messages are unmarshaled into Trade structs (defined in binance_types.go) and pushed onto the provided channel.
 func ListenTrade(ctx context.Context, symbol string, out chan<- Trade) error {
-	url := fmt.Sprintf("wss://%s:9443/ws/%s@trade", BASE_STREAM, strings.ToLower(symbol))
-	return listenWebSocket(ctx, url, func(msg []byte) error {
+	return listenTrade(ctx, StreamBaseURL, symbol, out)
+}
+
+// listenTrade is ListenTrade against the given stream base URL.
+func listenTrade(ctx context.Context, base string, symbol string, out chan<- Trade) error {
+	url := fmt.Sprintf("%s/ws/%s@trade", base, strings.ToLower(symbol))
+	return listenWebSocket(ctx, url, tradeHandler(url, out))
+}
+
+// tradeHandler returns the handler decoding trade messages received from source, a stream URL or name, into out.
+func tradeHandler(source string, out chan<- Trade) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
 		var combined struct {
 			Stream string          `json:"stream"`
 			Data   json.RawMessage `json:"data"`
@@ -109,6 +324,9 @@ func ListenTrade(ctx context.Context, symbol string, out chan<- Trade) error {
 		if err := json.Unmarshal(msg, &combined); err == nil && combined.Stream != "" {
 			msg = combined.Data
 		}
+		if !acceptStrict(source, "trade", msg) {
+			return nil
+		}
 		var trade Trade
 		if err := json.Unmarshal(msg, &trade); err != nil {
 			return fmt.Errorf("failed to unmarshal Trade: %w, raw message: %s", err, msg)
@@ -116,46 +334,172 @@ func ListenTrade(ctx context.Context, symbol string, out chan<- Trade) error {
 		if trade.EventType != "trade" {
 			return nil
 		}
+		trade.ConnID, trade.ConnGeneration = session.ID, session.Generation
 		out <- trade
 		return nil
-	})
+	}
 }
 
 // ListenAggTrade subscribes to Binance aggregated trade events for the given symbol.
 func ListenAggTrade(ctx context.Context, symbol string, out chan<- AggTrade) error {
-	url := fmt.Sprintf("wss://%s:9443/ws/%s@aggTrade", BASE_STREAM, strings.ToLower(symbol))
-	return listenWebSocket(ctx, url, func(msg []byte) error {
+	return listenAggTrade(ctx, StreamBaseURL, symbol, out)
+}
+
+// listenAggTrade is ListenAggTrade against the given stream base URL.
+func listenAggTrade(ctx context.Context, base string, symbol string, out chan<- AggTrade) error {
+	url := fmt.Sprintf("%s/ws/%s@aggTrade", base, strings.ToLower(symbol))
+	return listenWebSocket(ctx, url, aggTradeHandler(url, out))
+}
+
+// aggTradeHandler returns the handler decoding aggregate trade messages received from source, a stream URL or name,
+// into out.
+func aggTradeHandler(source string, out chan<- AggTrade) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
+		if !acceptStrict(source, "aggTrade", msg) {
+			return nil
+		}
 		var aggTrade AggTrade
 		if err := json.Unmarshal(msg, &aggTrade); err != nil {
 			return fmt.Errorf("failed to unmarshal AggTrade: %w, raw message: %s", err, msg)
 		}
+		aggTrade.ConnID, aggTrade.ConnGeneration = session.ID, session.Generation
 		out <- aggTrade
 		return nil
-	})
+	}
 }
 
 // ListenOrderBookDiff subscribes to Binance order book diff events for the given symbol.
 func ListenOrderBookDiff(ctx context.Context, symbol string, out chan<- OrderBookDiff) error {
-	url := fmt.Sprintf("wss://%s:9443/ws/%s@depth", BASE_STREAM, strings.ToLower(symbol))
-	return listenWebSocket(ctx, url, func(msg []byte) error {
-		var diff OrderBookDiff
+	return listenOrderBookDiff(ctx, StreamBaseURL, symbol, out)
+}
+
+// listenOrderBookDiff is ListenOrderBookDiff against the given stream base URL.
+func listenOrderBookDiff(ctx context.Context, base string, symbol string, out chan<- OrderBookDiff) error {
+	url := fmt.Sprintf("%s/ws/%s@depth", base, strings.ToLower(symbol))
+	return listenWebSocket(ctx, url, orderBookDiffHandler(url, out))
+}
+
+// orderBookDiffHandler returns the handler decoding order book diff messages received from source, a stream URL or name,
+// into out.
+func orderBookDiffHandler(source string, out chan<- OrderBookDiff) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
+		if !acceptStrict(source, "depthUpdate", msg) {
+			return nil
+		}
+		// Decoded into pooled slices, which are reused once the diff is released (see ReleaseOrderBookDiff)
+		diff := OrderBookDiff{Bids: acquireLevels(), Asks: acquireLevels()}
 		if err := json.Unmarshal(msg, &diff); err != nil {
+			ReleaseOrderBookDiff(diff)
 			return fmt.Errorf("failed to unmarshal OrderBookDiff: %w, raw message: %s", err, msg)
 		}
+		diff.ConnID, diff.ConnGeneration = session.ID, session.Generation
 		out <- diff
 		return nil
-	})
+	}
 }
 
 // ListenBestPrice subscribes to Binance best price (book ticker) events for the given symbol.
 func ListenBestPrice(ctx context.Context, symbol string, out chan<- BestPrice) error {
-	url := fmt.Sprintf("wss://%s:9443/ws/%s@bookTicker", BASE_STREAM, strings.ToLower(symbol))
-	return listenWebSocket(ctx, url, func(msg []byte) error {
+	return listenBestPrice(ctx, StreamBaseURL, symbol, out)
+}
+
+// listenBestPrice is ListenBestPrice against the given stream base URL.
+func listenBestPrice(ctx context.Context, base string, symbol string, out chan<- BestPrice) error {
+	url := fmt.Sprintf("%s/ws/%s@bookTicker", base, strings.ToLower(symbol))
+	return listenWebSocket(ctx, url, bestPriceHandler(url, out))
+}
+
+// bestPriceHandler returns the handler decoding book ticker messages received from source, a stream URL or name,
+// into out.
+func bestPriceHandler(source string, out chan<- BestPrice) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
+		if !acceptStrict(source, "bookTicker", msg) {
+			return nil
+		}
 		var best BestPrice
 		if err := json.Unmarshal(msg, &best); err != nil {
 			return fmt.Errorf("failed to unmarshal BestPrice: %w, raw message: %s", err, msg)
 		}
+		best.ConnID, best.ConnGeneration = session.ID, session.Generation
 		out <- best
 		return nil
+	}
+}
+
+// AllBookTickersStream is the stream carrying the book ticker updates of every symbol of the market.
+const AllBookTickersStream = "!bookTicker"
+
+// ListenAllBookTickers subscribes to the book tickers of every symbol over one connection and passes each update to
+// route with the symbol's own stream name, e.g. "btcusdt@bookTicker", so it can be handed to that symbol's pipeline.
+func ListenAllBookTickers(ctx context.Context, route func(stream string, msg []byte, session WSSession) error) error {
+	return listenAllBookTickers(ctx, StreamBaseURL, route)
+}
+
+// listenAllBookTickers is ListenAllBookTickers against the given stream base URL.
+func listenAllBookTickers(ctx context.Context, base string, route func(stream string, msg []byte, session WSSession) error) error {
+	return listenWebSocket(ctx, base+"/ws/"+AllBookTickersStream, func(msg []byte, session WSSession) error {
+		var head struct {
+			Symbol string `json:"s"`
+		}
+		if err := json.Unmarshal(msg, &head); err != nil || head.Symbol == "" {
+			return fmt.Errorf("book ticker without a symbol: %s", msg)
+		}
+		return route(strings.ToLower(head.Symbol)+"@bookTicker", msg, session)
 	})
 }
+
+// ListenMarkPrice subscribes to USD-M futures mark price updates for the given symbol, sent once per second.
+func ListenMarkPrice(ctx context.Context, symbol string, out chan<- MarkPrice) error {
+	return listenMarkPrice(ctx, FuturesStreamBaseURL, symbol, out)
+}
+
+// listenMarkPrice is ListenMarkPrice against the given stream base URL.
+func listenMarkPrice(ctx context.Context, base string, symbol string, out chan<- MarkPrice) error {
+	url := fmt.Sprintf("%s/ws/%s@markPrice@1s", base, strings.ToLower(symbol))
+	return listenWebSocket(ctx, url, markPriceHandler(url, out))
+}
+
+// markPriceHandler returns the handler decoding mark price updates received from source, a stream URL or name,
+// into out.
+func markPriceHandler(source string, out chan<- MarkPrice) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
+		if !acceptStrict(source, "markPriceUpdate", msg) {
+			return nil
+		}
+		var markPrice MarkPrice
+		if err := json.Unmarshal(msg, &markPrice); err != nil {
+			return fmt.Errorf("failed to unmarshal MarkPrice: %w, raw message: %s", err, msg)
+		}
+		markPrice.ConnID, markPrice.ConnGeneration = session.ID, session.Generation
+		out <- markPrice
+		return nil
+	}
+}
+
+// ListenAvgPrice subscribes to spot average price updates for the given symbol, sent once per second.
+func ListenAvgPrice(ctx context.Context, symbol string, out chan<- AvgPrice) error {
+	return listenAvgPrice(ctx, StreamBaseURL, symbol, out)
+}
+
+// listenAvgPrice is ListenAvgPrice against the given stream base URL.
+func listenAvgPrice(ctx context.Context, base string, symbol string, out chan<- AvgPrice) error {
+	url := fmt.Sprintf("%s/ws/%s@avgPrice", base, strings.ToLower(symbol))
+	return listenWebSocket(ctx, url, avgPriceHandler(url, out))
+}
+
+// avgPriceHandler returns the handler decoding average price updates received from source, a stream URL or name,
+// into out.
+func avgPriceHandler(source string, out chan<- AvgPrice) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
+		if !acceptStrict(source, "avgPrice", msg) {
+			return nil
+		}
+		var avgPrice AvgPrice
+		if err := json.Unmarshal(msg, &avgPrice); err != nil {
+			return fmt.Errorf("failed to unmarshal AvgPrice: %w, raw message: %s", err, msg)
+		}
+		avgPrice.ConnID, avgPrice.ConnGeneration = session.ID, session.Generation
+		out <- avgPrice
+		return nil
+	}
+}
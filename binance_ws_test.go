@@ -1,15 +1,22 @@
-package main
+package gobinapi
 
 import (
 	"context"
+	"net/http"
 	"strconv"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
+
+	"gobinapi_o3/internal/mockbinance"
 )
 
-// TestListenTradeReceivesValidData connects to Binance's trade websocket for BTCUSDT,
+// TestListenTradeReceivesValidData connects to the mock server's trade websocket for BTCUSDT,
 // waits up to 10 seconds for a Trade message, and validates that key fields are non-empty and sane.
 func TestListenTradeReceivesValidData(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 5000001, "95000.00", "0.012"))
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -46,6 +53,8 @@ func TestListenTradeReceivesValidData(t *testing.T) {
 
 // TestListenAggTradeReceivesData tests the aggregated trade websocket for BTCUSDT.
 func TestListenAggTradeReceivesData(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@aggTrade", mockbinance.AggTradeMessage("BTCUSDT", 3000001, "95000.00", "0.012"))
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -88,6 +97,9 @@ func TestListenAggTradeReceivesData(t *testing.T) {
 }
 
 func TestListenOrderBookDiffReceivesValidData(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@depth", mockbinance.DepthUpdateMessage("BTCUSDT", 1001, 1003,
+		[]mockbinance.Level{{"95000.00", "0.5"}}, []mockbinance.Level{{"95000.01", "1.2"}}))
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -120,9 +132,11 @@ func TestListenOrderBookDiffReceivesValidData(t *testing.T) {
 	}
 }
 
-// TestListenBestPriceReceivesValidData subscribes to Binance's best price websocket for BTCUSDT,
+// TestListenBestPriceReceivesValidData subscribes to the mock server's best price websocket for BTCUSDT,
 // waits up to 10 seconds for a BestPrice message, and asserts that key fields are valid and parseable.
 func TestListenBestPriceReceivesValidData(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@bookTicker", mockbinance.BookTickerMessage("BTCUSDT", 400900217, "95000.00", "0.5", "95000.01", "1.2"))
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -172,6 +186,8 @@ func TestListenBestPriceReceivesValidData(t *testing.T) {
 
 // TestWebSocketContextCancellation verifies that the websocket listener exits cleanly when its context is cancelled.
 func TestWebSocketContextCancellation(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade")
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -193,3 +209,164 @@ func TestWebSocketContextCancellation(t *testing.T) {
 		t.Fatalf("Expected error to be context.Canceled, got: %v", err)
 	}
 }
+
+// useMockServer starts a mock Binance server and points the listeners and REST calls at it for the test's duration.
+func useMockServer(t *testing.T) *mockbinance.Server {
+	t.Helper()
+	srv := mockbinance.NewServer()
+	oldStream, oldREST := StreamBaseURL, RESTBaseURL
+	oldFuturesStream, oldFuturesREST := FuturesStreamBaseURL, FuturesRESTBaseURL
+	oldOptionsStream, oldUserDataStream := OptionsStreamBaseURL, UserDataStreamBaseURL
+	StreamBaseURL, RESTBaseURL = srv.WSURL(), srv.URL()
+	FuturesStreamBaseURL, FuturesRESTBaseURL = srv.WSURL(), srv.URL()
+	OptionsStreamBaseURL, UserDataStreamBaseURL = srv.WSURL(), srv.WSURL()
+	t.Cleanup(func() {
+		StreamBaseURL, RESTBaseURL = oldStream, oldREST
+		FuturesStreamBaseURL, FuturesRESTBaseURL = oldFuturesStream, oldFuturesREST
+		OptionsStreamBaseURL, UserDataStreamBaseURL = oldOptionsStream, oldUserDataStream
+		srv.Close()
+	})
+	return srv
+}
+
+func TestListenTrade_MockServer(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 7, "100.5", "0.25"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	tradeChan := make(chan Trade, 1)
+	done := make(chan struct{})
+	go func() { defer close(done); ListenTrade(ctx, "BTCUSDT", tradeChan) }()
+	defer func() { cancel(); <-done }()
+
+	select {
+	case trade := <-tradeChan:
+		if trade.TradeID != 7 || trade.Price != "100.5" || trade.Quantity != "0.25" || trade.EventType != "trade" {
+			t.Errorf("unexpected trade: %+v", trade)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for a trade message from the mock server")
+	}
+}
+
+func TestListenBestPrice_MockServer(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("ethusdt@bookTicker", mockbinance.BookTickerMessage("ETHUSDT", 11, "2000.1", "3", "2000.2", "4"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	bestPriceChan := make(chan BestPrice, 1)
+	done := make(chan struct{})
+	go func() { defer close(done); ListenBestPrice(ctx, "ETHUSDT", bestPriceChan) }()
+	defer func() { cancel(); <-done }()
+
+	select {
+	case bp := <-bestPriceChan:
+		if bp.UpdateID != 11 || bp.Symbol != "ETHUSDT" || bp.BidPrice != "2000.1" || bp.AskQty != "4" {
+			t.Errorf("unexpected best price: %+v", bp)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for a best price message from the mock server")
+	}
+}
+
+func TestListenWebSocket_MockServerDisconnect(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@aggTrade", mockbinance.AggTradeMessage("BTCUSDT", 1, "100", "1"))
+	srv.SetCloseAfterFrames(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	aggChan := make(chan AggTrade, 1)
+	err := ListenAggTrade(ctx, "BTCUSDT", aggChan)
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expected a going-away close error, got %v", err)
+	}
+	if agg := <-aggChan; agg.AggTradeID != 1 {
+		t.Errorf("expected aggTrade 1 before the disconnect, got %+v", agg)
+	}
+}
+
+// TestOrderBookDiffPipeline_MockGap runs the depth listener and diff subscriber against the mock server with a
+// missing update, and checks that only the contiguous updates are recorded and a resync snapshot is requested.
+func TestOrderBookDiffPipeline_MockGap(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetSnapshot("BTCUSDT", mockbinance.SnapshotMessage(100, nil, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	snapshotCh := make(chan OrderBookSnapshot, 10)
+	snapshotRequest := func() {
+		go func() {
+			snapshot, err := FetchOrderBookSnapshot(client, "BTCUSDT")
+			if err != nil {
+				t.Errorf("snapshot request failed: %v", err)
+				return
+			}
+			snapshotCh <- *snapshot
+		}()
+	}
+
+	// Only start streaming depth once the subscriber has its initial snapshot
+	diffCh := make(chan OrderBookDiff, 10)
+	recorder := &FakeDiffRecorder{}
+	go SubscribeOrderBookDiff(diffCh, snapshotCh, recorder, snapshotRequest, &FakeLogger{})
+	for srv.SnapshotRequests("BTCUSDT") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	srv.SetStream("btcusdt@depth", mockbinance.DepthSequence("BTCUSDT", 101, 5, 104)...)
+	done := make(chan struct{})
+	go func() { defer close(done); ListenOrderBookDiff(ctx, "BTCUSDT", diffCh) }()
+	defer func() { cancel(); <-done }()
+
+	for srv.SnapshotRequests("BTCUSDT") < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("gap did not trigger a resync snapshot request")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	records := recorder.GetRecords()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 contiguous diffs before the gap, got %d", len(records))
+	}
+	for i, d := range records {
+		if d.FirstUpdateID != int64(101+i) {
+			t.Errorf("expected update %d at index %d, got %d", 101+i, i, d.FirstUpdateID)
+		}
+	}
+}
+
+func TestListenTrade_StampsSessionIntoRecords(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("sessusdt@trade", mockbinance.TradeMessage("SESSUSDT", 1, "1.0", "1"))
+	srv.SetCloseAfterFrames(true)
+
+	// The mock closes each connection after its frame, so every call is a new session of the same stream
+	var trades []Trade
+	for i := 0; i < 2; i++ {
+		tradeChan := make(chan Trade, 1)
+		ListenTrade(context.Background(), "SESSUSDT", tradeChan)
+		select {
+		case trade := <-tradeChan:
+			trades = append(trades, trade)
+		default:
+			t.Fatalf("expected a trade on connection %d", i+1)
+		}
+	}
+	if trades[0].ConnID == "" || trades[0].ConnID == trades[1].ConnID {
+		t.Errorf("expected distinct session IDs, got %q and %q", trades[0].ConnID, trades[1].ConnID)
+	}
+	if trades[1].ConnGeneration != trades[0].ConnGeneration+1 {
+		t.Errorf("expected the reconnect to bump the generation, got %d then %d", trades[0].ConnGeneration, trades[1].ConnGeneration)
+	}
+	for _, s := range WebSocketStats() {
+		if s.Stream == "sessusdt@trade" && s.SessionID != trades[1].ConnID {
+			t.Errorf("expected the latest session in ConnStats, got %q", s.SessionID)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package gobinapi
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// BookFeaturesDataType is the data type of order book features sampled from a LocalOrderBook (see RunBookFeatures).
+const BookFeaturesDataType = "bookFeatures"
+
+// bookFeatureLevels is the depth of the deepest feature, so the number of levels taken from the book per sample.
+const bookFeatureLevels = 10
+
+// BookFeatures are depth and imbalance features of a symbol's local order book at one instant, for ML pipelines that
+// want them without replaying the diffs. Volumes are in base units and summed over the best 5 and 10 levels of a side,
+// or fewer if the book has fewer.
+type BookFeatures struct {
+	// Time, EventTime and LastUpdateID are those of the BookTop the features were computed from.
+	Time         int64   `json:"time" timestamp:"millis" parquet:"name=time, type=INT64"`
+	EventTime    int64   `json:"event_time" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	LastUpdateID int64   `json:"last_update_id" parquet:"name=last_update_id, type=INT64"`
+	BestBid      float64 `json:"best_bid" parquet:"name=best_bid, type=DOUBLE"`
+	BestAsk      float64 `json:"best_ask" parquet:"name=best_ask, type=DOUBLE"`
+	Mid          float64 `json:"mid" parquet:"name=mid, type=DOUBLE"`
+	Spread       float64 `json:"spread" parquet:"name=spread, type=DOUBLE"`
+	// WeightedMid weighs the best bid and ask by the size on the opposite side, like MidPrice.Microprice.
+	WeightedMid float64 `json:"weighted_mid" parquet:"name=weighted_mid, type=DOUBLE"`
+	BidVolume5  float64 `json:"bid_volume_5" parquet:"name=bid_volume_5, type=DOUBLE"`
+	AskVolume5  float64 `json:"ask_volume_5" parquet:"name=ask_volume_5, type=DOUBLE"`
+	// Imbalance5 and Imbalance10 are (bid volume - ask volume) / (bid volume + ask volume) over the levels, from -1
+	// with only asks to 1 with only bids.
+	Imbalance5  float64 `json:"imbalance_5" parquet:"name=imbalance_5, type=DOUBLE"`
+	BidVolume10 float64 `json:"bid_volume_10" parquet:"name=bid_volume_10, type=DOUBLE"`
+	AskVolume10 float64 `json:"ask_volume_10" parquet:"name=ask_volume_10, type=DOUBLE"`
+	Imbalance10 float64 `json:"imbalance_10" parquet:"name=imbalance_10, type=DOUBLE"`
+}
+
+// ComputeBookFeatures is a pure function computing the features of a book top taken with at least 10 levels per
+// side. It returns false if either side is empty or a level does not parse.
+func ComputeBookFeatures(top BookTop) (BookFeatures, bool) {
+	bids, ok := parseLevels(top.Bids)
+	if !ok {
+		return BookFeatures{}, false
+	}
+	asks, ok := parseLevels(top.Asks)
+	if !ok {
+		return BookFeatures{}, false
+	}
+	bestBid, bestAsk := bids[0], asks[0]
+	f := BookFeatures{
+		Time:         top.Time,
+		EventTime:    top.EventTime,
+		LastUpdateID: top.LastUpdateID,
+		BestBid:      bestBid[0],
+		BestAsk:      bestAsk[0],
+		Mid:          (bestBid[0] + bestAsk[0]) / 2,
+		Spread:       bestAsk[0] - bestBid[0],
+		WeightedMid:  (bestBid[0]*bestAsk[1] + bestAsk[0]*bestBid[1]) / (bestBid[1] + bestAsk[1]),
+		BidVolume5:   levelVolume(bids, 5),
+		AskVolume5:   levelVolume(asks, 5),
+		BidVolume10:  levelVolume(bids, 10),
+		AskVolume10:  levelVolume(asks, 10),
+	}
+	f.Imbalance5 = (f.BidVolume5 - f.AskVolume5) / (f.BidVolume5 + f.AskVolume5)
+	f.Imbalance10 = (f.BidVolume10 - f.AskVolume10) / (f.BidVolume10 + f.AskVolume10)
+	return f, true
+}
+
+// parseLevels parses price levels into price and quantity pairs. It returns false if there are none or one does not
+// parse.
+func parseLevels(levels []PriceLevel) ([][2]float64, bool) {
+	if len(levels) == 0 {
+		return nil, false
+	}
+	out := make([][2]float64, len(levels))
+	for i, l := range levels {
+		price, err := strconv.ParseFloat(l.Price, 64)
+		if err != nil {
+			return nil, false
+		}
+		qty, err := strconv.ParseFloat(l.Quantity, 64)
+		if err != nil {
+			return nil, false
+		}
+		out[i] = [2]float64{price, qty}
+	}
+	return out, true
+}
+
+// levelVolume sums the quantity of the first n levels.
+func levelVolume(levels [][2]float64, n int) float64 {
+	var sum float64
+	for _, l := range levels[:min(n, len(levels))] {
+		sum += l[1]
+	}
+	return sum
+}
+
+// RunBookFeatures writes the features of book to recorder every interval until ctx is cancelled. Ticks while the
+// book is not synchronised or has an empty side are skipped.
+func RunBookFeatures(ctx context.Context, book *LocalOrderBook, interval time.Duration, recorder RecorderWriter[BookFeatures], logger LoggerInterface) {
+	ticker := DefaultClock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			top, ok := book.Top(bookFeatureLevels, NowFunc().UnixMilli())
+			if !ok {
+				continue
+			}
+			features, ok := ComputeBookFeatures(top)
+			if !ok {
+				continue
+			}
+			if err := recorder.Write(features); err != nil {
+				logger.Errorf("error writing book features: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package gobinapi
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type bookFeaturesWriter chan BookFeatures
+
+func (w bookFeaturesWriter) Write(record BookFeatures) error {
+	w <- record
+	return nil
+}
+
+func TestComputeBookFeatures(t *testing.T) {
+	top := BookTop{Time: 1000, EventTime: 990, LastUpdateID: 7}
+	for i := 0; i < 12; i++ {
+		top.Bids = append(top.Bids, PriceLevel{Price: strconv.Itoa(99 - i), Quantity: "2"})
+		top.Asks = append(top.Asks, PriceLevel{Price: strconv.Itoa(101 + i), Quantity: "1"})
+	}
+	top.Asks[0].Quantity = "6"
+	f, ok := ComputeBookFeatures(top)
+	if !ok {
+		t.Fatal("expected features")
+	}
+	if f.Time != 1000 || f.EventTime != 990 || f.LastUpdateID != 7 || f.BestBid != 99 || f.BestAsk != 101 || f.Mid != 100 || f.Spread != 2 {
+		t.Errorf("unexpected prices %+v", f)
+	}
+	// The ask's size leans the weighted mid towards the bid: (99×6 + 101×2) / 8
+	if math.Abs(f.WeightedMid-99.5) > 1e-9 {
+		t.Errorf("expected weighted mid 99.5, got %v", f.WeightedMid)
+	}
+	if f.BidVolume5 != 10 || f.AskVolume5 != 10 || f.Imbalance5 != 0 {
+		t.Errorf("unexpected top-5 features %+v", f)
+	}
+	if f.BidVolume10 != 20 || f.AskVolume10 != 15 || math.Abs(f.Imbalance10-5.0/35) > 1e-9 {
+		t.Errorf("unexpected top-10 features %+v", f)
+	}
+
+	short, ok := ComputeBookFeatures(BookTop{Bids: []PriceLevel{{"10", "3"}}, Asks: []PriceLevel{{"11", "1"}}})
+	if !ok || short.BidVolume10 != 3 || short.Imbalance5 != 0.5 {
+		t.Errorf("expected features over the levels there are, got %+v", short)
+	}
+	if _, ok := ComputeBookFeatures(BookTop{Bids: []PriceLevel{{"10", "1"}}}); ok {
+		t.Error("expected no features without asks")
+	}
+}
+
+func TestRunBookFeatures_WritesOnTick(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	clock := useFakeClock(t, start)
+	book := NewLocalOrderBook()
+	out := make(bookFeaturesWriter, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { defer close(done); RunBookFeatures(ctx, book, time.Second, out, &FakeLogger{}) }()
+	waitForWaiters(t, clock, 1)
+
+	book.ApplySnapshot(OrderBookSnapshot{LastUpdateID: 1, Bids: []PriceLevel{{"10", "1"}, {"9", "1"}}, Asks: []PriceLevel{{"11", "1"}}})
+	clock.Advance(time.Second)
+	select {
+	case f := <-out:
+		if f.Time != start.Add(time.Second).UnixMilli() || f.BidVolume5 != 2 || f.LastUpdateID != 1 {
+			t.Errorf("unexpected book features %+v", f)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for book features")
+	}
+	cancel()
+	<-done
+}
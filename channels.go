@@ -0,0 +1,139 @@
+package gobinapi
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ChannelBufferSizes sets the in-memory buffer size of each stream type's channel between listener and recorder.
+// Depth diffs arrive at up to ten per second per symbol and need far more headroom than the once-a-minute
+// snapshots. Streams backed by a SpillQueue spill to disk rather than block once their buffer is full,
+// unless Config.OverflowPolicies says otherwise.
+type ChannelBufferSizes struct {
+	Trade     int `json:"trade" yaml:"trade"`
+	AggTrade  int `json:"agg_trade" yaml:"agg_trade"`
+	Depth     int `json:"depth" yaml:"depth"`
+	BestPrice int `json:"best_price" yaml:"best_price"`
+	Snapshot  int `json:"snapshot" yaml:"snapshot"`
+	MarkPrice int `json:"mark_price" yaml:"mark_price"`
+	Ticker    int `json:"ticker" yaml:"ticker"`
+	AvgPrice  int `json:"avg_price" yaml:"avg_price"`
+	Raw       int `json:"raw" yaml:"raw"`
+}
+
+// DefaultChannelBufferSizes returns the buffer sizes used by DefaultConfig.
+func DefaultChannelBufferSizes() ChannelBufferSizes {
+	return ChannelBufferSizes{
+		Trade:     100,
+		AggTrade:  100,
+		Depth:     1000,
+		BestPrice: 100,
+		Snapshot:  10,
+		MarkPrice: 100,
+		Ticker:    100,
+		AvgPrice:  100,
+		Raw:       1000,
+	}
+}
+
+// Over returns b with its zero sizes taken from base.
+func (b ChannelBufferSizes) Over(base ChannelBufferSizes) ChannelBufferSizes {
+	for _, s := range []struct{ size, base *int }{
+		{&b.Trade, &base.Trade},
+		{&b.AggTrade, &base.AggTrade},
+		{&b.Depth, &base.Depth},
+		{&b.BestPrice, &base.BestPrice},
+		{&b.Snapshot, &base.Snapshot},
+		{&b.MarkPrice, &base.MarkPrice},
+		{&b.Ticker, &base.Ticker},
+		{&b.AvgPrice, &base.AvgPrice},
+		{&b.Raw, &base.Raw},
+	} {
+		if *s.size == 0 {
+			*s.size = *s.base
+		}
+	}
+	return b
+}
+
+// Validate checks that every buffer size is positive.
+func (b ChannelBufferSizes) Validate() error {
+	for _, s := range []struct {
+		name string
+		size int
+	}{
+		{"trade", b.Trade},
+		{"aggTrade", b.AggTrade},
+		{"depth", b.Depth},
+		{"bestPrice", b.BestPrice},
+		{"snapshot", b.Snapshot},
+		{"markPrice", b.MarkPrice},
+		{"ticker", b.Ticker},
+		{"avgPrice", b.AvgPrice},
+		{"raw", b.Raw},
+	} {
+		if s.size <= 0 {
+			return fmt.Errorf("config: %s channel buffer size must be positive, got %d", s.name, s.size)
+		}
+	}
+	return nil
+}
+
+func init() {
+	DefaultMetrics.Describe("binance_channel_occupancy", "gauge", "Items currently buffered in memory per symbol and stream channel.")
+	DefaultMetrics.Describe("binance_channel_capacity", "gauge", "Configured in-memory buffer size per symbol and stream channel.")
+}
+
+// registerChannelOccupancy exports the occupancy and capacity of one channel as metrics and through Introspect, with
+// occupancy evaluated at read time.
+func registerChannelOccupancy(symbol, stream string, capacity int, occupancy func() int) {
+	introspectChannel(symbol, stream, capacity, occupancy)
+	labels := Labels{"symbol": symbol, "stream": stream}
+	DefaultMetrics.Set("binance_channel_capacity", labels, float64(capacity))
+	DefaultMetrics.SetFunc("binance_channel_occupancy", labels, func() float64 {
+		return float64(occupancy())
+	})
+}
+
+// OverflowPolicy says what a stream's queue does with messages arriving while its in-memory buffer is full, e.g.
+// because the recorder stalls on a slow disk.
+type OverflowPolicy string
+
+// Overflow policies. OverflowSpill, the default, keeps every message by spilling to disk. OverflowBlock waits for
+// room, which stops the WebSocket reader and can get the connection dropped by the exchange. The drop policies keep
+// the reader going at the cost of discarding the oldest buffered or the arriving message, counted as drops of the
+// stream (see RecordDrop).
+const (
+	OverflowSpill      OverflowPolicy = "spill"
+	OverflowBlock      OverflowPolicy = "block"
+	OverflowDropOldest OverflowPolicy = "dropOldest"
+	OverflowDropNewest OverflowPolicy = "dropNewest"
+)
+
+// queuedDataTypes are the data types whose streams pass through a queue with an overflow policy.
+var queuedDataTypes = []string{"trade", "aggTrade", "orderBookDiff", "bestPrice", "markPrice", "ticker", "ticker_1h", "ticker_4h", "ticker_1d", "avgPrice", "raw"}
+
+// ValidateOverflowPolicies checks that policies, keyed by data type, name queued data types and known policies.
+func ValidateOverflowPolicies(policies map[string]OverflowPolicy) error {
+	for dataType, policy := range policies {
+		if !slices.Contains(queuedDataTypes, dataType) {
+			return fmt.Errorf("config: overflow policy for unknown data type %q, want one of %s", dataType, strings.Join(queuedDataTypes, ", "))
+		}
+		switch policy {
+		case OverflowSpill, OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+		default:
+			return fmt.Errorf("config: unknown overflow policy %q for %s, expected %q, %q, %q or %q", policy, dataType, OverflowSpill, OverflowBlock, OverflowDropOldest, OverflowDropNewest)
+		}
+	}
+	return nil
+}
+
+// OverflowPolicyFor returns the overflow policy of dataType's queues, OverflowSpill unless Config.OverflowPolicies
+// sets one.
+func (cfg Config) OverflowPolicyFor(dataType string) OverflowPolicy {
+	if policy, ok := cfg.OverflowPolicies[dataType]; ok {
+		return policy
+	}
+	return OverflowSpill
+}
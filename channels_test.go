@@ -0,0 +1,43 @@
+package gobinapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChannelBufferSizes_Validate(t *testing.T) {
+	if err := DefaultChannelBufferSizes().Validate(); err != nil {
+		t.Fatalf("default buffer sizes rejected: %v", err)
+	}
+	b := DefaultChannelBufferSizes()
+	b.Snapshot = -1
+	err := b.Validate()
+	if err == nil || !strings.Contains(err.Error(), "snapshot") {
+		t.Fatalf("expected snapshot buffer size error, got %v", err)
+	}
+}
+
+func TestRegisterChannelOccupancy_ReportsSpillQueueBuffer(t *testing.T) {
+	q, err := NewSpillQueue[Trade](t.TempDir(), "TESTOCC_trade", 8)
+	if err != nil {
+		t.Fatalf("NewSpillQueue: %v", err)
+	}
+	defer close(q.In())
+	registerChannelOccupancy("TESTOCC", "trade", 8, q.Buffered)
+
+	for i := 0; i < 3; i++ {
+		q.push(Trade{TradeID: int64(i)})
+	}
+	labels := Labels{"symbol": "TESTOCC", "stream": "trade"}
+	if got := DefaultMetrics.Value("binance_channel_occupancy", labels); got != 3 {
+		t.Errorf("expected occupancy 3, got %v", got)
+	}
+	if got := DefaultMetrics.Value("binance_channel_capacity", labels); got != 8 {
+		t.Errorf("expected capacity 8, got %v", got)
+	}
+
+	<-q.Out()
+	if got := DefaultMetrics.Value("binance_channel_occupancy", labels); got != 2 {
+		t.Errorf("expected occupancy 2 after a receive, got %v", got)
+	}
+}
@@ -0,0 +1,763 @@
+package gobinapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClickHouseConfig configures the ClickHouse sink.
+type ClickHouseConfig struct {
+	// Addr is the native protocol address as host:port, usually port 9000.
+	Addr     string `json:"addr" yaml:"addr"`
+	Database string `json:"database,omitempty" yaml:"database"`
+	User     string `json:"user,omitempty" yaml:"user"`
+	// Password defaults to the CLICKHOUSE_PASSWORD environment variable.
+	Password      string        `json:"-" yaml:"-"`
+	TradeTable    string        `json:"trade_table,omitempty" yaml:"trade_table"`
+	BookTable     string        `json:"book_table,omitempty" yaml:"book_table"`
+	BatchSize     int           `json:"batch_size,omitempty" yaml:"batch_size"`
+	FlushInterval time.Duration `json:"flush_interval,omitempty" yaml:"flush_interval"`
+	// AsyncInsert lets the server buffer inserts and write them in larger parts, which suits frequent small
+	// batches. WaitForAsyncInsert makes each insert wait until its rows are written, so failures are reported.
+	AsyncInsert        bool `json:"async_insert" yaml:"async_insert"`
+	WaitForAsyncInsert bool `json:"wait_for_async_insert" yaml:"wait_for_async_insert"`
+	// CreateSchema creates the tables on startup.
+	CreateSchema bool `json:"create_schema" yaml:"create_schema"`
+}
+
+// Validate checks the settings.
+func (c ClickHouseConfig) Validate() error {
+	if c.Addr == "" {
+		return errors.New("clickhouse: address is required")
+	}
+	if c.BatchSize < 0 || c.FlushInterval < 0 {
+		return errors.New("clickhouse: batch size and flush interval must not be negative")
+	}
+	return nil
+}
+
+func (c ClickHouseConfig) withDefaults() ClickHouseConfig {
+	if c.Database == "" {
+		c.Database = "default"
+	}
+	if c.User == "" {
+		c.User = "default"
+	}
+	if c.Password == "" {
+		c.Password = os.Getenv("CLICKHOUSE_PASSWORD")
+	}
+	if c.TradeTable == "" {
+		c.TradeTable = "trades"
+	}
+	if c.BookTable == "" {
+		c.BookTable = "book_ticker"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 10000
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = time.Second
+	}
+	return c
+}
+
+// ClickHouseColumn is a column of an inserted block with its ClickHouse type.
+type ClickHouseColumn struct {
+	Name string
+	Type string
+}
+
+// Prices and quantities are stored as Decimal(18, 8), which holds every Binance price and quantity exactly.
+const clickhouseDecimalScale = 8
+
+var clickhouseTradeColumns = []ClickHouseColumn{
+	{"time", "DateTime64(3, 'UTC')"},
+	{"symbol", "String"},
+	{"trade_id", "Int64"},
+	{"price", "Decimal(18, 8)"},
+	{"quantity", "Decimal(18, 8)"},
+	{"is_buyer_maker", "UInt8"},
+	{"event_time", "DateTime64(3, 'UTC')"},
+}
+
+var clickhouseBookColumns = []ClickHouseColumn{
+	{"time", "DateTime64(3, 'UTC')"},
+	{"symbol", "String"},
+	{"update_id", "Int64"},
+	{"bid_price", "Decimal(18, 8)"},
+	{"bid_qty", "Decimal(18, 8)"},
+	{"ask_price", "Decimal(18, 8)"},
+	{"ask_qty", "Decimal(18, 8)"},
+}
+
+// ClickHouseSchema returns the DDL statements creating the sink's MergeTree tables, ordered by symbol and time.
+func ClickHouseSchema(cfg ClickHouseConfig) []string {
+	cfg = cfg.withDefaults()
+	table := func(name string, columns []ClickHouseColumn) string {
+		defs := make([]string, len(columns))
+		for i, c := range columns {
+			defs[i] = "  " + c.Name + " " + c.Type
+		}
+		return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n) ENGINE = MergeTree ORDER BY (symbol, time)",
+			clickhouseIdentifier(name), strings.Join(defs, ",\n"))
+	}
+	return []string{
+		table(cfg.TradeTable, clickhouseTradeColumns),
+		table(cfg.BookTable, clickhouseBookColumns),
+	}
+}
+
+// clickhouseIdentifier quotes a table name, keeping a database prefix such as "market.trades".
+func clickhouseIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = "`" + strings.ReplaceAll(p, "`", "\\`") + "`"
+	}
+	return strings.Join(parts, ".")
+}
+
+// clickhouseInserter is the subset of *ClickHouseConn used by the sink, so tests can substitute a fake.
+type clickhouseInserter interface {
+	Insert(ctx context.Context, query string, columns []ClickHouseColumn, rows [][]any) error
+}
+
+// ClickHouseSink batch-inserts trades and best prices (bookTicker) into ClickHouse over the native protocol, for
+// teams whose research stack queries ClickHouse rather than parquet files. Records are buffered by Write and
+// inserted by the background Run loop, so database latency never stalls the subscriber goroutines.
+type ClickHouseSink struct {
+	cfg    ClickHouseConfig
+	conn   clickhouseInserter
+	logger LoggerInterface
+
+	// health holds the outcome of the last flush, see Healthy
+	health sinkHealth
+
+	mu     sync.Mutex
+	trades [][]any
+	books  [][]any
+	flush  chan struct{}
+}
+
+// NewClickHouseSink connects to the server and optionally creates the schema.
+func NewClickHouseSink(ctx context.Context, cfg ClickHouseConfig, logger LoggerInterface) (*ClickHouseSink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg = cfg.withDefaults()
+	conn := NewClickHouseConn(cfg.Addr, cfg.Database, cfg.User, cfg.Password)
+	if cfg.CreateSchema {
+		for _, stmt := range ClickHouseSchema(cfg) {
+			if err := conn.Exec(ctx, stmt); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("clickhouse: failed to create schema: %w", err)
+			}
+		}
+	}
+	return newClickHouseSink(cfg, conn, logger), nil
+}
+
+func newClickHouseSink(cfg ClickHouseConfig, conn clickhouseInserter, logger LoggerInterface) *ClickHouseSink {
+	return &ClickHouseSink{
+		cfg:    cfg.withDefaults(),
+		conn:   conn,
+		logger: logger,
+		flush:  make(chan struct{}, 1),
+	}
+}
+
+// ForSymbol returns a RecorderWriter that writes records of any type for the given symbol into the sink. This is
+// needed because Trade records do not carry their symbol. Use UntypedWriter to combine it with a typed Recorder.
+func (s *ClickHouseSink) ForSymbol(symbol string) RecorderWriter[any] {
+	return clickhouseSymbolWriter{sink: s, symbol: strings.ToUpper(symbol)}
+}
+
+type clickhouseSymbolWriter struct {
+	sink   *ClickHouseSink
+	symbol string
+}
+
+func (w clickhouseSymbolWriter) Write(record interface{}) error {
+	return w.sink.write(w.symbol, record, NowFunc())
+}
+
+// write converts a record to a row and buffers it. Unsupported record types are ignored.
+func (s *ClickHouseSink) write(symbol string, record interface{}, received time.Time) error {
+	var row []any
+	var isTrade bool
+	switch r := record.(type) {
+	case Trade:
+		values, err := clickhouseDecimals(r.Price, r.Quantity)
+		if err != nil {
+			return err
+		}
+		row = []any{time.UnixMilli(r.TradeTime), symbol, r.TradeID, values[0], values[1], r.IsBuyerMaker, time.UnixMilli(r.EventTime)}
+		isTrade = true
+	case BestPrice:
+		values, err := clickhouseDecimals(r.BidPrice, r.BidQty, r.AskPrice, r.AskQty)
+		if err != nil {
+			return err
+		}
+		row = []any{received, symbol, r.UpdateID, values[0], values[1], values[2], values[3]}
+	default:
+		return nil
+	}
+
+	s.mu.Lock()
+	if isTrade {
+		s.trades = append(s.trades, row)
+	} else {
+		s.books = append(s.books, row)
+	}
+	full := len(s.trades) >= s.cfg.BatchSize || len(s.books) >= s.cfg.BatchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// clickhouseDecimal is a Decimal(18, 8) value, scaled by 10^8.
+type clickhouseDecimal int64
+
+func clickhouseDecimals(values ...string) ([]any, error) {
+	out := make([]any, len(values))
+	for i, v := range values {
+		d, err := ParseScaledDecimal(v, clickhouseDecimalScale)
+		if err != nil {
+			return nil, fmt.Errorf("clickhouse: %w", err)
+		}
+		out[i] = clickhouseDecimal(d)
+	}
+	return out, nil
+}
+
+// ParseScaledDecimal is a pure function parsing a decimal string such as "-12.345" into an integer scaled by
+// 10^scale, failing if it has more fractional digits than scale or overflows.
+func ParseScaledDecimal(s string, scale int) (int64, error) {
+	digits, negative := s, false
+	if strings.HasPrefix(digits, "-") {
+		digits, negative = digits[1:], true
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	frac = strings.TrimRight(frac, "0")
+	if whole == "" && frac == "" || len(frac) > scale {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	var v int64
+	for _, c := range whole + frac + strings.Repeat("0", scale-len(frac)) {
+		if c < '0' || c > '9' || v > (math.MaxInt64-9)/10 {
+			return 0, fmt.Errorf("invalid decimal %q", s)
+		}
+		v = v*10 + int64(c-'0')
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// Healthy returns the error of the last flush, or nil if it succeeded.
+func (s *ClickHouseSink) Healthy() error {
+	return s.health.Healthy()
+}
+
+// Run inserts buffered rows every FlushInterval, or sooner when a batch fills up, until the context is cancelled.
+// Remaining rows are inserted on exit.
+func (s *ClickHouseSink) Run(ctx context.Context) error {
+	ticker := DefaultClock.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(context.Background()); err != nil {
+				s.logger.Errorf("clickhouse: final flush failed: %v", err)
+			}
+			return ctx.Err()
+		case <-ticker.C():
+		case <-s.flush:
+		}
+		if err := s.Flush(ctx); err != nil {
+			s.logger.Errorf("clickhouse: flush failed: %v", err)
+		}
+	}
+}
+
+// Flush inserts all buffered rows into their tables. Rows that fail to insert are put back at the front of the
+// buffer so they are retried on the next flush, up to a limit of 100 batches per table beyond which the oldest rows
+// are dropped to bound memory during a long database outage.
+func (s *ClickHouseSink) Flush(ctx context.Context) (err error) {
+	defer func() { s.health.record(err) }()
+	s.mu.Lock()
+	trades, books := s.trades, s.books
+	s.trades, s.books = nil, nil
+	s.mu.Unlock()
+
+	var firstErr error
+	if len(trades) > 0 {
+		if err := s.conn.Insert(ctx, s.insertQuery(s.cfg.TradeTable, clickhouseTradeColumns), clickhouseTradeColumns, trades); err != nil {
+			firstErr = fmt.Errorf("clickhouse: insert into %s failed: %w", s.cfg.TradeTable, err)
+			s.mu.Lock()
+			s.trades = s.requeue(s.cfg.TradeTable, trades, s.trades)
+			s.mu.Unlock()
+		}
+	}
+	if len(books) > 0 {
+		if err := s.conn.Insert(ctx, s.insertQuery(s.cfg.BookTable, clickhouseBookColumns), clickhouseBookColumns, books); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("clickhouse: insert into %s failed: %w", s.cfg.BookTable, err)
+			}
+			s.mu.Lock()
+			s.books = s.requeue(s.cfg.BookTable, books, s.books)
+			s.mu.Unlock()
+		}
+	}
+	return firstErr
+}
+
+// requeue puts failed rows back in front of rows buffered since the flush started. It must be called with mu held.
+func (s *ClickHouseSink) requeue(table string, failed, buffered [][]any) [][]any {
+	rows := append(failed, buffered...)
+	if limit := s.cfg.BatchSize * 100; len(rows) > limit {
+		s.logger.Errorf("clickhouse: dropping %d buffered rows for %s after repeated insert failures", len(rows)-limit, table)
+		rows = rows[len(rows)-limit:]
+	}
+	return rows
+}
+
+// insertQuery returns the INSERT statement for a table, with the async insert settings.
+func (s *ClickHouseSink) insertQuery(table string, columns []ClickHouseColumn) string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s)", clickhouseIdentifier(table), strings.Join(names, ", "))
+	if s.cfg.AsyncInsert {
+		wait := 0
+		if s.cfg.WaitForAsyncInsert {
+			wait = 1
+		}
+		query += fmt.Sprintf(" SETTINGS async_insert = 1, wait_for_async_insert = %d", wait)
+	}
+	return query + " VALUES"
+}
+
+// Native protocol packet types.
+const (
+	chClientHello = 0
+	chClientQuery = 1
+	chClientData  = 2
+
+	chServerHello       = 0
+	chServerData        = 1
+	chServerException   = 2
+	chServerProgress    = 3
+	chServerEndOfStream = 5
+	chServerProfileInfo = 6
+)
+
+// clickhouseRevision is the protocol revision the client speaks. Servers answer in the lower of their and the
+// client's revision, so pinning an old one keeps the packet layout small and stable.
+const clickhouseRevision = 54213
+
+// chStageComplete asks the server to process a query completely.
+const chStageComplete = 2
+
+// ClickHouseConn is a minimal, uncompressed ClickHouse native protocol client. It connects on first use and
+// reconnects on the next call after any failure.
+type ClickHouseConn struct {
+	addr, database, user, password string
+
+	mu       sync.Mutex
+	conn     net.Conn
+	r        *bufio.Reader
+	revision uint64
+}
+
+// NewClickHouseConn creates a client for the server at addr.
+func NewClickHouseConn(addr, database, user, password string) *ClickHouseConn {
+	return &ClickHouseConn{addr: addr, database: database, user: user, password: password}
+}
+
+// Close closes the connection.
+func (c *ClickHouseConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Exec runs a statement that returns no data, such as DDL.
+func (c *ClickHouseConn) Exec(ctx context.Context, query string) error {
+	return c.do(ctx, func() error {
+		if err := c.sendQuery(query); err != nil {
+			return err
+		}
+		return c.readUntilEnd()
+	})
+}
+
+// Insert sends rows, whose values are ordered and typed as columns, as one block for an INSERT ... VALUES query.
+func (c *ClickHouseConn) Insert(ctx context.Context, query string, columns []ClickHouseColumn, rows [][]any) error {
+	return c.do(ctx, func() error {
+		if err := c.sendQuery(query); err != nil {
+			return err
+		}
+		header, err := c.readHeader()
+		if err != nil {
+			return err
+		}
+		if len(header) != len(columns) {
+			return fmt.Errorf("server expects %d columns, got %d", len(header), len(columns))
+		}
+		for i, col := range columns {
+			if header[i] != col {
+				return fmt.Errorf("server expects column %s %s, got %s %s", header[i].Name, header[i].Type, col.Name, col.Type)
+			}
+		}
+		block, err := encodeClickHouseBlock(columns, rows)
+		if err != nil {
+			return err
+		}
+		if _, err := c.conn.Write(block); err != nil {
+			return err
+		}
+		if err := c.sendEmptyBlock(); err != nil {
+			return err
+		}
+		return c.readUntilEnd()
+	})
+}
+
+// do runs fn on a connection with ctx's deadline, dropping the connection if fn fails.
+func (c *ClickHouseConn) do(ctx context.Context, fn func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return err
+		}
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(30 * time.Second)
+	}
+	c.conn.SetDeadline(deadline)
+	if err := fn(); err != nil {
+		var exception *ClickHouseException
+		if !errors.As(err, &exception) {
+			// The stream may be out of step; exceptions end the query cleanly
+			c.conn.Close()
+			c.conn = nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *ClickHouseConn) connect(ctx context.Context) error {
+	d := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+	var e chEncoder
+	e.uvarint(chClientHello)
+	e.string("gobinapi")
+	e.uvarint(1)
+	e.uvarint(0)
+	e.uvarint(clickhouseRevision)
+	e.string(c.database)
+	e.string(c.user)
+	e.string(c.password)
+	if _, err := conn.Write(e.buf); err != nil {
+		conn.Close()
+		return err
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	if err := c.readHello(); err != nil {
+		conn.Close()
+		c.conn = nil
+		return fmt.Errorf("handshake with %s failed: %w", c.addr, err)
+	}
+	return nil
+}
+
+func (c *ClickHouseConn) readHello() error {
+	d := chDecoder{r: c.r}
+	switch packet := d.uvarint(); packet {
+	case chServerHello:
+	case chServerException:
+		return d.exception()
+	default:
+		if d.err == nil {
+			return fmt.Errorf("unexpected packet %d", packet)
+		}
+	}
+	d.string()  // server name
+	d.uvarint() // major version
+	d.uvarint() // minor version
+	c.revision = min(d.uvarint(), clickhouseRevision)
+	if c.revision >= 54058 {
+		d.string() // time zone
+	}
+	return d.err
+}
+
+// sendQuery sends a query followed by the empty block that ends the (absent) external tables.
+func (c *ClickHouseConn) sendQuery(query string) error {
+	var e chEncoder
+	e.uvarint(chClientQuery)
+	e.string("") // query ID
+	// Client info
+	e.uvarint(1) // initial query
+	e.string("") // initial user
+	e.string("") // initial query ID
+	e.string("[::ffff:127.0.0.1]:0")
+	e.uvarint(1) // TCP interface
+	e.string("") // OS user
+	e.string("") // client host name
+	e.string("gobinapi")
+	e.uvarint(1)
+	e.uvarint(0)
+	e.uvarint(clickhouseRevision)
+	e.string("") // quota key
+	e.string("") // end of settings
+	e.uvarint(chStageComplete)
+	e.uvarint(0) // no compression
+	e.string(query)
+	if _, err := c.conn.Write(e.buf); err != nil {
+		return err
+	}
+	return c.sendEmptyBlock()
+}
+
+func (c *ClickHouseConn) sendEmptyBlock() error {
+	block, _ := encodeClickHouseBlock(nil, nil)
+	_, err := c.conn.Write(block)
+	return err
+}
+
+// readHeader reads packets until the server sends the block structure it expects for an insert.
+func (c *ClickHouseConn) readHeader() ([]ClickHouseColumn, error) {
+	d := chDecoder{r: c.r}
+	for {
+		switch packet := d.uvarint(); {
+		case d.err != nil:
+			return nil, d.err
+		case packet == chServerData:
+			return d.blockHeader()
+		case packet == chServerException:
+			return nil, d.exception()
+		case packet == chServerProgress:
+			d.progress()
+		case packet == chServerEndOfStream:
+			return nil, errors.New("query returned no insert header")
+		default:
+			return nil, fmt.Errorf("unexpected packet %d", packet)
+		}
+	}
+}
+
+// readUntilEnd reads packets until the end of the query's stream.
+func (c *ClickHouseConn) readUntilEnd() error {
+	d := chDecoder{r: c.r}
+	for {
+		switch packet := d.uvarint(); {
+		case d.err != nil:
+			return d.err
+		case packet == chServerEndOfStream:
+			return nil
+		case packet == chServerException:
+			return d.exception()
+		case packet == chServerProgress:
+			d.progress()
+		case packet == chServerProfileInfo:
+			d.uvarint() // rows
+			d.uvarint() // blocks
+			d.uvarint() // bytes
+			d.uint8()   // applied limit
+			d.uvarint() // rows before limit
+			d.uint8()   // calculated rows before limit
+		case packet == chServerData:
+			if _, err := d.blockHeader(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected packet %d", packet)
+		}
+	}
+}
+
+// ClickHouseException is an error reported by the server.
+type ClickHouseException struct {
+	Code    int32
+	Name    string
+	Message string
+}
+
+func (e *ClickHouseException) Error() string {
+	return fmt.Sprintf("code %d: %s", e.Code, e.Message)
+}
+
+// encodeClickHouseBlock encodes rows as a Data packet holding one block of columns.
+func encodeClickHouseBlock(columns []ClickHouseColumn, rows [][]any) ([]byte, error) {
+	var e chEncoder
+	e.uvarint(chClientData)
+	e.string("") // table name
+	e.uvarint(1) // block info: is_overflows
+	e.uint8(0)
+	e.uvarint(2) // block info: bucket_num
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, math.MaxUint32)
+	e.uvarint(0)
+	e.uvarint(uint64(len(columns)))
+	e.uvarint(uint64(len(rows)))
+	for i, col := range columns {
+		e.string(col.Name)
+		e.string(col.Type)
+		for _, row := range rows {
+			switch v := row[i].(type) {
+			case string:
+				e.string(v)
+			case int64:
+				e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(v))
+			case clickhouseDecimal:
+				e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(v))
+			case time.Time:
+				e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(v.UnixMilli()))
+			case bool:
+				if v {
+					e.uint8(1)
+				} else {
+					e.uint8(0)
+				}
+			default:
+				return nil, fmt.Errorf("unsupported value %T for column %s", v, col.Name)
+			}
+		}
+	}
+	return e.buf, nil
+}
+
+// chEncoder appends native protocol primitives.
+type chEncoder struct {
+	buf []byte
+}
+
+func (e *chEncoder) uvarint(v uint64) { e.buf = binary.AppendUvarint(e.buf, v) }
+func (e *chEncoder) uint8(v uint8)    { e.buf = append(e.buf, v) }
+
+func (e *chEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// chDecoder reads native protocol primitives, recording the first error.
+type chDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *chDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *chDecoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > 1<<30 {
+		d.err = fmt.Errorf("implausible length %d", n)
+		return nil
+	}
+	b := make([]byte, n)
+	_, d.err = io.ReadFull(d.r, b)
+	return b
+}
+
+func (d *chDecoder) uint8() uint8 {
+	if b := d.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *chDecoder) int32() int32 {
+	if b := d.bytes(4); b != nil {
+		return int32(binary.LittleEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *chDecoder) string() string {
+	return string(d.bytes(d.uvarint()))
+}
+
+// exception reads an Exception packet, keeping the outermost exception's details.
+func (d *chDecoder) exception() error {
+	e := &ClickHouseException{Code: d.int32(), Name: d.string(), Message: d.string()}
+	d.string() // stack trace
+	if nested := d.uint8(); nested != 0 && d.err == nil {
+		d.exception()
+	}
+	if d.err != nil {
+		return d.err
+	}
+	return e
+}
+
+func (d *chDecoder) progress() {
+	d.uvarint() // rows
+	d.uvarint() // bytes
+	d.uvarint() // total rows
+}
+
+// blockHeader reads a Data packet whose block has no rows and returns its columns.
+func (d *chDecoder) blockHeader() ([]ClickHouseColumn, error) {
+	d.string() // table name
+	for field := d.uvarint(); field != 0 && d.err == nil; field = d.uvarint() {
+		switch field {
+		case 1:
+			d.uint8()
+		case 2:
+			d.int32()
+		default:
+			return nil, fmt.Errorf("unknown block info field %d", field)
+		}
+	}
+	count, rows := d.uvarint(), d.uvarint()
+	if rows != 0 && d.err == nil {
+		return nil, fmt.Errorf("unexpected block with %d rows", rows)
+	}
+	var columns []ClickHouseColumn
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		columns = append(columns, ClickHouseColumn{Name: d.string(), Type: d.string()})
+	}
+	return columns, d.err
+}
@@ -0,0 +1,324 @@
+package gobinapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseScaledDecimal(t *testing.T) {
+	for in, want := range map[string]int64{
+		"100.25":      10025000000,
+		"0.00000001":  1,
+		"-1.5":        -150000000,
+		"42":          4200000000,
+		"0.123400000": 12340000,
+	} {
+		if got, err := ParseScaledDecimal(in, 8); err != nil || got != want {
+			t.Errorf("ParseScaledDecimal(%q) = %d, %v, expected %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "-", "1.000000001", "1e5", "abc", "99999999999999999999"} {
+		if _, err := ParseScaledDecimal(in, 8); err == nil {
+			t.Errorf("expected %q to be rejected", in)
+		}
+	}
+}
+
+// fakeClickHouse is a native protocol server accepting CREATE and INSERT queries. Inserts get header as the block
+// structure, and their rows are decoded with integers, decimals and times as int64, strings as string and UInt8 as
+// bool.
+type fakeClickHouse struct {
+	t        *testing.T
+	listener net.Listener
+	header   []ClickHouseColumn
+
+	mu       sync.Mutex
+	accepted int
+	user     string
+	queries  []string
+	rows     [][]any
+	fail     string
+}
+
+func newFakeClickHouse(t *testing.T, header []ClickHouseColumn) *fakeClickHouse {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeClickHouse{t: t, listener: l, header: header}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.accepted++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeClickHouse) serve(conn net.Conn) {
+	defer conn.Close()
+	d := &chDecoder{r: bufio.NewReader(conn)}
+	if d.uvarint() != chClientHello {
+		return
+	}
+	d.string() // client name
+	d.uvarint()
+	d.uvarint()
+	d.uvarint() // revision
+	d.string()  // database
+	user := d.string()
+	d.string() // password
+	s.mu.Lock()
+	s.user = user
+	s.mu.Unlock()
+	var hello chEncoder
+	hello.uvarint(chServerHello)
+	hello.string("ClickHouse")
+	hello.uvarint(24)
+	hello.uvarint(3)
+	hello.uvarint(54466)
+	hello.string("UTC")
+	conn.Write(hello.buf)
+
+	for d.err == nil {
+		if d.uvarint() != chClientQuery {
+			return
+		}
+		query := s.readQuery(d)
+		s.readBlock(d, nil) // external tables
+		var resp chEncoder
+		s.mu.Lock()
+		s.queries = append(s.queries, query)
+		fail := s.fail
+		s.mu.Unlock()
+		if fail != "" {
+			resp.uvarint(chServerException)
+			resp.buf = binary.LittleEndian.AppendUint32(resp.buf, 60)
+			resp.string("DB::Exception")
+			resp.string(fail)
+			resp.string("")
+			resp.uint8(0)
+			conn.Write(resp.buf)
+			continue
+		}
+		if strings.HasPrefix(query, "INSERT") {
+			header, _ := encodeClickHouseBlock(s.header, nil)
+			header[0] = chServerData
+			conn.Write(header)
+			rows := s.readBlock(d, s.header)
+			s.readBlock(d, nil) // end of data
+			s.mu.Lock()
+			s.rows = append(s.rows, rows...)
+			s.mu.Unlock()
+			resp.uvarint(chServerProgress)
+			resp.uvarint(uint64(len(rows)))
+			resp.uvarint(0)
+			resp.uvarint(0)
+		}
+		resp.uvarint(chServerEndOfStream)
+		conn.Write(resp.buf)
+	}
+}
+
+// readQuery reads the rest of a Query packet as sent by ClickHouseConn and returns the query.
+func (s *fakeClickHouse) readQuery(d *chDecoder) string {
+	d.string()  // query ID
+	d.uvarint() // query kind
+	d.string()
+	d.string()
+	d.string()  // address
+	d.uvarint() // interface
+	d.string()
+	d.string()
+	d.string() // client name
+	d.uvarint()
+	d.uvarint()
+	d.uvarint() // revision
+	d.string()  // quota key
+	for d.string() != "" && d.err == nil {
+	}
+	if stage := d.uvarint(); stage != chStageComplete {
+		s.t.Errorf("unexpected stage %d", stage)
+	}
+	d.uvarint() // compression
+	return d.string()
+}
+
+// readBlock reads a client Data packet whose columns must match expected.
+func (s *fakeClickHouse) readBlock(d *chDecoder, expected []ClickHouseColumn) [][]any {
+	if packet := d.uvarint(); packet != chClientData && d.err == nil {
+		s.t.Errorf("expected a data packet, got %d", packet)
+		return nil
+	}
+	d.string() // table name
+	for field := d.uvarint(); field != 0 && d.err == nil; field = d.uvarint() {
+		if field == 1 {
+			d.uint8()
+		} else {
+			d.int32()
+		}
+	}
+	count, n := d.uvarint(), d.uvarint()
+	if count == 0 {
+		return nil
+	}
+	if int(count) != len(expected) {
+		s.t.Errorf("expected %d columns, got %d", len(expected), count)
+		return nil
+	}
+	rows := make([][]any, n)
+	for i := range rows {
+		rows[i] = make([]any, count)
+	}
+	for c := range expected {
+		if name, typ := d.string(), d.string(); name != expected[c].Name || typ != expected[c].Type {
+			s.t.Errorf("unexpected column %s %s", name, typ)
+		}
+		for r := range rows {
+			switch expected[c].Type {
+			case "String":
+				rows[r][c] = d.string()
+			case "UInt8":
+				rows[r][c] = d.uint8() == 1
+			default:
+				rows[r][c] = int64(binary.LittleEndian.Uint64(d.bytes(8)))
+			}
+		}
+	}
+	return rows
+}
+
+func TestClickHouseConn_InsertsBlock(t *testing.T) {
+	server := newFakeClickHouse(t, clickhouseTradeColumns)
+	conn := NewClickHouseConn(server.listener.Addr().String(), "market", "recorder", "secret")
+	defer conn.Close()
+	sink := newClickHouseSink(ClickHouseConfig{Addr: "unused", AsyncInsert: true}, conn, &FakeLogger{})
+	w := sink.ForSymbol("btcusdt")
+	w.Write(Trade{TradeID: 7, Price: "100.25", Quantity: "0.5", TradeTime: 1700000000000, EventTime: 1700000000001, IsBuyerMaker: true})
+	w.Write(Trade{TradeID: 8, Price: "100.5", Quantity: "2", TradeTime: 1700000000002, EventTime: 1700000000003})
+
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	expectedQuery := "INSERT INTO `trades` (time, symbol, trade_id, price, quantity, is_buyer_maker, event_time) " +
+		"SETTINGS async_insert = 1, wait_for_async_insert = 0 VALUES"
+	if len(server.queries) != 1 || server.queries[0] != expectedQuery {
+		t.Errorf("unexpected queries %q", server.queries)
+	}
+	if server.user != "recorder" {
+		t.Errorf("expected to log in as recorder, got %q", server.user)
+	}
+	if len(server.rows) != 2 {
+		t.Fatalf("expected 2 rows, got %v", server.rows)
+	}
+	first := []any{int64(1700000000000), "BTCUSDT", int64(7), int64(10025000000), int64(50000000), true, int64(1700000000001)}
+	for i, v := range first {
+		if server.rows[0][i] != v {
+			t.Errorf("column %s: expected %v, got %v", clickhouseTradeColumns[i].Name, v, server.rows[0][i])
+		}
+	}
+}
+
+func TestClickHouseConn_ReportsExceptionsOnSameConnection(t *testing.T) {
+	server := newFakeClickHouse(t, clickhouseBookColumns)
+	server.fail = "Table default.book_ticker does not exist"
+	conn := NewClickHouseConn(server.listener.Addr().String(), "default", "default", "")
+	defer conn.Close()
+	rows := [][]any{{time.UnixMilli(1), "BTCUSDT", int64(1), clickhouseDecimal(1), clickhouseDecimal(2), clickhouseDecimal(3), clickhouseDecimal(4)}}
+
+	err := conn.Insert(context.Background(), "INSERT INTO book_ticker VALUES", clickhouseBookColumns, rows)
+	var exception *ClickHouseException
+	if !errors.As(err, &exception) || !strings.Contains(exception.Message, "does not exist") {
+		t.Fatalf("expected the server exception, got %v", err)
+	}
+	server.mu.Lock()
+	server.fail = ""
+	server.mu.Unlock()
+	if err := conn.Insert(context.Background(), "INSERT INTO book_ticker VALUES", clickhouseBookColumns, rows); err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.accepted != 1 || len(server.rows) != 1 {
+		t.Errorf("expected one connection and one inserted row, got %d connections and rows %v", server.accepted, server.rows)
+	}
+}
+
+func TestClickHouseConn_RejectsMismatchedColumns(t *testing.T) {
+	server := newFakeClickHouse(t, clickhouseTradeColumns)
+	conn := NewClickHouseConn(server.listener.Addr().String(), "default", "default", "")
+	defer conn.Close()
+	err := conn.Insert(context.Background(), "INSERT INTO trades VALUES", clickhouseBookColumns, nil)
+	if err == nil || !strings.Contains(err.Error(), "server expects column") {
+		t.Errorf("expected a column mismatch error, got %v", err)
+	}
+}
+
+func TestNewClickHouseSink_CreatesSchema(t *testing.T) {
+	server := newFakeClickHouse(t, nil)
+	_, err := NewClickHouseSink(context.Background(), ClickHouseConfig{
+		Addr: server.listener.Addr().String(), TradeTable: "market.trades", CreateSchema: true,
+	}, &FakeLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.queries) != 2 || !strings.HasPrefix(server.queries[0], "CREATE TABLE IF NOT EXISTS `market`.`trades` (") {
+		t.Errorf("unexpected schema queries %q", server.queries)
+	}
+}
+
+// fakeClickHouseInserter records inserted rows by query, failing while fail is set.
+type fakeClickHouseInserter struct {
+	rows map[string][][]any
+	fail bool
+}
+
+func (f *fakeClickHouseInserter) Insert(ctx context.Context, query string, columns []ClickHouseColumn, rows [][]any) error {
+	if f.fail {
+		return errors.New("connection refused")
+	}
+	f.rows[query] = append(f.rows[query], rows...)
+	return nil
+}
+
+func TestClickHouseSink_RetriesFailedInserts(t *testing.T) {
+	conn := &fakeClickHouseInserter{rows: map[string][][]any{}, fail: true}
+	sink := newClickHouseSink(ClickHouseConfig{Addr: "unused"}, conn, &FakeLogger{})
+	w := sink.ForSymbol("ETHUSDT")
+	w.Write(BestPrice{UpdateID: 1, BidPrice: "1", BidQty: "1", AskPrice: "2", AskQty: "1"})
+	w.Write(AggTrade{})
+
+	if err := sink.Flush(context.Background()); err == nil {
+		t.Fatal("expected the failed insert to be reported")
+	}
+	conn.fail = false
+	w.Write(BestPrice{UpdateID: 2, BidPrice: "1", BidQty: "1", AskPrice: "2", AskQty: "1"})
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	books := conn.rows[sink.insertQuery("book_ticker", clickhouseBookColumns)]
+	if len(books) != 2 || books[0][2] != int64(1) || books[1][2] != int64(2) {
+		t.Errorf("expected both best prices in order, got %v", books)
+	}
+	if err := w.Write(Trade{Price: "n/a", Quantity: "1"}); err == nil {
+		t.Error("expected a non-numeric price to be rejected")
+	}
+}
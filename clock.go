@@ -0,0 +1,172 @@
+package gobinapi
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for everything time-dependent in the package: timestamps, file rotation, flush and
+// heartbeat tickers, schedulers and retry timers. Production code uses SystemClock; tests install a FakeClock as
+// DefaultClock to drive that behavior deterministically.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the subset of *time.Timer used by the package.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker is the subset of *time.Ticker used by the package.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// DefaultClock is the clock used by the package. NowFunc reads it unless overridden itself.
+var DefaultClock Clock = SystemClock{}
+
+// NowFunc returns the current time. It is kept as a shorthand for DefaultClock.Now and may still be overridden on
+// its own where only timestamps matter.
+var NowFunc = func() time.Time {
+	return DefaultClock.Now()
+}
+
+// SystemClock is the real wall clock.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// NewTimer wraps time.NewTimer.
+func (SystemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+// NewTicker wraps time.NewTicker.
+func (SystemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+type systemTimer struct{ t *time.Timer }
+
+func (s systemTimer) C() <-chan time.Time { return s.t.C }
+func (s systemTimer) Stop() bool          { return s.t.Stop() }
+
+type systemTicker struct{ t *time.Ticker }
+
+func (s systemTicker) C() <-chan time.Time { return s.t.C }
+func (s systemTicker) Stop()               { s.t.Stop() }
+
+// FakeClock is a manually advanced Clock for tests. Timers and tickers fire only when Advance moves the clock past
+// their deadline; like the real ones, their channels hold one pending tick and further ticks are dropped.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+// NewFakeClock creates a FakeClock reading start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the fake current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates a timer firing once the clock has advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	return fakeTimer{c, c.add(d, 0)}
+}
+
+// NewTicker creates a ticker firing every d of fake time.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	return fakeTicker{c, c.add(d, d)}
+}
+
+// Waiters returns the number of active timers and tickers, so tests can wait for the code under test to start
+// waiting before advancing the clock.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker that falls due on the way in deadline order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+		if len(c.waiters) == 0 || c.waiters[0].at.After(end) {
+			break
+		}
+		w := c.waiters[0]
+		c.now = w.at
+		select {
+		case w.ch <- w.at:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			c.waiters = c.waiters[1:]
+		}
+	}
+	c.now = end
+}
+
+func (c *FakeClock) add(d, period time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	if d <= 0 && period == 0 {
+		w.ch <- c.now
+		return w
+	}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+// remove unregisters w and reports whether it was still active.
+func (c *FakeClock) remove(w *fakeWaiter) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.waiters {
+		if other == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	c *FakeClock
+	w *fakeWaiter
+}
+
+func (t fakeTimer) C() <-chan time.Time { return t.w.ch }
+func (t fakeTimer) Stop() bool          { return t.c.remove(t.w) }
+
+type fakeTicker struct {
+	c *FakeClock
+	w *fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.w.ch }
+func (t fakeTicker) Stop()               { t.c.remove(t.w) }
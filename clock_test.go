@@ -0,0 +1,97 @@
+package gobinapi
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useFakeClock installs a FakeClock as DefaultClock for the duration of the test.
+func useFakeClock(t *testing.T, start time.Time) *FakeClock {
+	t.Helper()
+	clock := NewFakeClock(start)
+	old := DefaultClock
+	DefaultClock = clock
+	t.Cleanup(func() { DefaultClock = old })
+	return clock
+}
+
+// waitForWaiters blocks until the code under test has started n timers or tickers on clock.
+func waitForWaiters(t *testing.T, clock *FakeClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for clock.Waiters() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d waiters, have %d", n, clock.Waiters())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFakeClock_TimersAndTickers(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	timer := c.NewTimer(10 * time.Second)
+	ticker := c.NewTicker(3 * time.Second)
+	stopped := c.NewTimer(time.Second)
+	if !stopped.Stop() {
+		t.Errorf("expected Stop to report an active timer")
+	}
+
+	c.Advance(5 * time.Second)
+	if got := <-ticker.C(); !got.Equal(start.Add(3 * time.Second)) {
+		t.Errorf("expected the first tick at +3s, got %s", got)
+	}
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	case <-stopped.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	c.Advance(5 * time.Second)
+	if got := <-timer.C(); !got.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("expected the timer at +10s, got %s", got)
+	}
+	// Ticks at +6s and +9s collapse into the single buffered slot, like time.Ticker
+	if got := <-ticker.C(); !got.Equal(start.Add(6 * time.Second)) {
+		t.Errorf("expected the buffered tick at +6s, got %s", got)
+	}
+	if !c.Now().Equal(start.Add(10 * time.Second)) {
+		t.Errorf("expected now at +10s, got %s", c.Now())
+	}
+	if timer.Stop() {
+		t.Errorf("expected Stop on a fired timer to return false")
+	}
+	ticker.Stop()
+	if c.Waiters() != 0 {
+		t.Errorf("expected no waiters left, got %d", c.Waiters())
+	}
+}
+
+func TestRunHeartbeatWriter_FakeClock(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	clock := useFakeClock(t, start)
+	path := filepath.Join(t.TempDir(), "heartbeat.json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { defer close(done); RunHeartbeatWriter(ctx, path, "run-1", 5*time.Second, &FakeLogger{}) }()
+	defer func() { cancel(); <-done }()
+
+	waitForWaiters(t, clock, 1)
+	clock.Advance(5 * time.Second)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hb, err := ReadHeartbeat(path)
+		if err == nil && hb.Time.Equal(start.Add(5*time.Second)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a heartbeat stamped with the fake time, got %+v (%v)", hb, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
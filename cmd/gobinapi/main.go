@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	gobinapi "gobinapi_o3"
+)
+
+func main() {
+	// Subcommands run on their own without starting the recorder
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "merge":
+			os.Exit(runMerge(os.Args[2:]))
+		case "tail":
+			os.Exit(runTail(os.Args[2:]))
+		case "query":
+			os.Exit(runQuery(os.Args[2:]))
+		case "stats":
+			os.Exit(runStats(os.Args[2:]))
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		case "backfill":
+			os.Exit(runBackfill(os.Args[2:]))
+		case "klines":
+			os.Exit(runKlines(os.Args[2:]))
+		case "vision":
+			os.Exit(runVision(os.Args[2:]))
+		}
+	}
+
+	// The recorder itself takes an optional config file; without one it records DefaultConfig
+	fs := flag.NewFlagSet("gobinapi", flag.ExitOnError)
+	configPath := fs.String("config", "", "YAML config file with instruments, streams, batch size and snapshot intervals")
+	fs.Parse(os.Args[1:])
+	cfg := gobinapi.DefaultConfig()
+	if *configPath != "" {
+		var err error
+		if cfg, err = gobinapi.LoadConfigFile(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	// Create a cancellable context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Set up signal channel to gracefully shut down on SIGINT or SIGTERM
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	// Initialize logger
+	logger, err := gobinapi.NewFileLogger()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Wait for termination signal in the background and stop the recorder when it arrives
+	go func() {
+		<-sigChan
+		logger.Infof("Shutdown signal received. Cancelling context and closing application.")
+		cancel()
+	}()
+
+	cfg.Logger = logger
+	if err := gobinapi.Run(ctx, cfg); err != nil {
+		logger.Errorf("Recorder stopped with error: %v", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
@@ -2,14 +2,15 @@ package main
 
 import (
 	"bytes"
-	"context"
-	"net/http"
 	"os"
 	"os/exec"
 	"strings"
 	"syscall"
 	"testing"
 	"time"
+
+	gobinapi "gobinapi_o3"
+	"gobinapi_o3/internal/mockbinance"
 )
 
 // TestMainfulShutdown simulates a SIGINT to the application and confirms that it shuts down gracefully.
@@ -24,6 +25,8 @@ func TestMainfulShutdown(t *testing.T) {
 	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcessMain")
 	// Set an environment variable so the helper process knows to run main()
 	cmd.Env = append(os.Environ(), "GO_TEST_MAIN=1")
+	// The recorder writes its files to the working directory
+	cmd.Dir = t.TempDir()
 
 	// Capture output for verification
 	var output bytes.Buffer
@@ -80,55 +83,17 @@ func TestHelperProcessMain(t *testing.T) {
 	if os.Getenv("GO_TEST_MAIN") != "1" {
 		return
 	}
+	// main() parses the command line itself, which here holds the test binary's -test.* flags
+	os.Args = []string{os.Args[0]}
+	// Record from a local stand-in for the exchange, so the test runs offline
+	srv := mockbinance.NewServer()
+	defer srv.Close()
+	srv.SetStream("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 1, "95000.00", "0.01"))
+	gobinapi.StreamBaseURL, gobinapi.RESTBaseURL = srv.WSURL(), srv.URL()
+	gobinapi.FuturesStreamBaseURL, gobinapi.FuturesRESTBaseURL = srv.WSURL(), srv.URL()
+	gobinapi.OptionsStreamBaseURL, gobinapi.UserDataStreamBaseURL = srv.WSURL(), srv.WSURL()
 	// Run the main application. It will block until it receives a SIGINT signal.
 	main()
 	// Normally, main() will call os.Exit(0), so the following line may never be reached.
 	os.Exit(0)
 }
-
-func TestMainTradeWebSocketIntegration(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	tradeCh := make(chan Trade, 1)
-	go func() {
-		if err := ListenTrade(ctx, "BTCUSDT", tradeCh); err != nil {
-			t.Logf("ListenTrade error: %v", err) // Log error if ListenTrade returns before a trade is received
-		}
-	}()
-	select {
-	case trade := <-tradeCh:
-		t.Logf("Received trade: %+v", trade)
-		if trade.EventType != "trade" {
-			t.Errorf("Expected EventType 'trade', got %s", trade.EventType)
-		}
-		if trade.TradeID <= 0 {
-			t.Errorf("TradeID should be positive, got %d", trade.TradeID)
-		}
-		if trade.Price == "" {
-			t.Errorf("Price should not be empty")
-		}
-	case <-ctx.Done():
-		t.Fatalf("Timeout waiting for trade message")
-	}
-}
-
-// TestMainOrderBookSnapshotIntegration calls the Binance REST API snapshot endpoint for BTCUSDT, waits up to 10 seconds for a response,
-// and verifies that the parsed OrderBookSnapshot contains a valid non-zero LastUpdateID with non-empty bid and ask lists.
-func TestMainOrderBookSnapshotIntegration(t *testing.T) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	// Call the REST API snapshot endpoint for BTCUSDT
-	snapshot, err := FetchOrderBookSnapshot(client, "BTCUSDT")
-	if err != nil {
-		t.Fatalf("Failed to fetch snapshot: %v", err)
-	}
-	if snapshot.LastUpdateID <= 0 {
-		t.Fatalf("Invalid LastUpdateID: %d", snapshot.LastUpdateID)
-	}
-	if len(snapshot.Bids) == 0 {
-		t.Fatalf("Empty Bids in snapshot")
-	}
-	if len(snapshot.Asks) == 0 {
-		t.Fatalf("Empty Asks in snapshot")
-	}
-	t.Logf("Fetched snapshot for BTCUSDT: LastUpdateID = %d, %d bids, %d asks", snapshot.LastUpdateID, len(snapshot.Bids), len(snapshot.Asks))
-}
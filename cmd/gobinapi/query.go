@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	gobinapi "gobinapi_o3"
+)
+
+// runQuery implements the "query" subcommand, which prints or exports recorded rows of one symbol and data type
+// over a time range, or a simple aggregation of them, without needing a notebook.
+func runQuery(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "directory containing the recorded parquet files")
+	symbol := fs.String("symbol", "", "symbol to query, e.g. BTCUSDT")
+	dataType := fs.String("type", "trade", "data type: trade, aggTrade, orderBookDiff, bestPrice or snapshot")
+	from := fs.String("from", "", "start of the range (RFC 3339 or YYYY-MM-DD, inclusive); defaults to today 00:00 UTC")
+	to := fs.String("to", "", "end of the range (RFC 3339 or YYYY-MM-DD, exclusive); defaults to one day after -from")
+	agg := fs.String("agg", "", "print an aggregation instead of rows: count or vwap")
+	format := fs.String("format", "json", "row output format: json (one object per line) or csv")
+	out := fs.String("out", "", "write rows to this file instead of standard output")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gobinapi query -symbol <symbol> [-type trade] [-from ...] [-to ...] [-agg count|vwap] [-format json|csv] [-out file]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *symbol == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	q := gobinapi.Query{Dir: *dir, Symbol: *symbol, DataType: *dataType}
+	var err error
+	if q.From, err = parseQueryTime(*from, time.Now().UTC().Truncate(24*time.Hour)); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -from: %v\n", err)
+		return 2
+	}
+	if q.To, err = parseQueryTime(*to, q.From.Add(24*time.Hour)); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -to: %v\n", err)
+		return 2
+	}
+
+	records, err := q.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "query failed: %v\n", err)
+		return 1
+	}
+
+	switch *agg {
+	case "":
+	case "count":
+		fmt.Println(len(records))
+		return 0
+	case "vwap":
+		vwap, volume, ok := gobinapi.VWAP(records)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "no traded volume in range")
+			return 1
+		}
+		fmt.Printf("vwap %g volume %g\n", vwap, volume)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown aggregation %q\n", *agg)
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s: %v\n", *out, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	switch *format {
+	case "json":
+		err = gobinapi.WriteRecordsJSON(w, records)
+	case "csv":
+		err = gobinapi.WriteRecordsCSV(w, records)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write rows: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseQueryTime parses an RFC 3339 time or a YYYY-MM-DD date (midnight UTC), returning def for an empty value.
+func parseQueryTime(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
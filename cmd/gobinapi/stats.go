@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	gobinapi "gobinapi_o3"
+)
+
+// runStats implements the "stats" subcommand, which prints per-file row counts, time coverage, ID ranges and
+// detected gaps for the recordings in a directory, for quick operational spot checks.
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "directory containing the recorded parquet files")
+	date := fs.String("date", "", "only check files of this date (YYYY-MM-DD); defaults to all files")
+	asJSON := fs.Bool("json", false, "print the statistics as JSON instead of a table")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gobinapi stats [-dir .] [-date YYYY-MM-DD] [-json]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	var day time.Time
+	if *date != "" {
+		var err error
+		if day, err = time.Parse("2006-01-02", *date); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -date: %v\n", err)
+			return 2
+		}
+	}
+
+	stats, err := gobinapi.CheckDirIntegrity(*dir, day)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stats failed: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(stats); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode statistics: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tROWS\tFIRST\tLAST\tMIN ID\tMAX ID\tGAPS\tMISSING\tOUT OF ORDER")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%d\t%d\t%d\t%d\n", filepath.Base(s.File), s.Rows,
+			formatStatsTime(s.FirstTime), formatStatsTime(s.LastTime), s.MinID, s.MaxID, len(s.Gaps), s.MissingIDs(), s.OutOfOrder)
+	}
+	tw.Flush()
+	for _, s := range stats {
+		for _, g := range s.Gaps {
+			fmt.Printf("%s: gap after %d before %d (%d missing)\n", filepath.Base(s.File), g.After, g.Before, g.Missing())
+		}
+	}
+	return 0
+}
+
+func formatStatsTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("15:04:05.000")
+}
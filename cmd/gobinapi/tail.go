@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	gobinapi "gobinapi_o3"
+)
+
+// runTail implements the "tail" subcommand, which connects to the live streams and pretty-prints decoded events for
+// quick eyeballing of the feed. It runs until interrupted.
+func runTail(args []string) int {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	symbols := fs.String("symbols", strings.Join(gobinapi.DefaultConfig().Instruments, ","), "comma-separated symbols to follow")
+	types := fs.String("types", "trade", "comma-separated stream types: "+strings.Join(gobinapi.TailTypes, ", "))
+	minSize := fs.Float64("min-size", 0, "only print events with at least this quantity")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gobinapi tail [-symbols BTCUSDT,ETHUSDT] [-types trade,bookTicker] [-min-size 0.5]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	filter := gobinapi.TailFilter{MinSize: *minSize}
+	if err := gobinapi.Tail(ctx, splitList(strings.ToUpper(*symbols)), splitList(*types), filter, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "tail failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
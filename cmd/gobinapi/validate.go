@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	gobinapi "gobinapi_o3"
+)
+
+// runValidate implements the "validate" subcommand, which checks the recordings of one day for missing IDs, depth
+// update discontinuities, event times going backwards, empty periods and duplicate rows, and writes the findings as a
+// JSON QA report. It exits 3 when issues are found, so scripts can tell them from failures to run the check.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "directory containing the recorded parquet files")
+	date := fs.String("date", "", "day to validate (YYYY-MM-DD); defaults to yesterday (UTC)")
+	maxEmpty := fs.Duration("max-empty", gobinapi.DefaultMaxEmptyPeriod, "report periods without records longer than this; 0 disables the check")
+	out := fs.String("out", "", "write the report to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gobinapi validate [-dir .] [-date YYYY-MM-DD] [-max-empty 1m] [-out report.json]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	if *date != "" {
+		var err error
+		if day, err = time.Parse("2006-01-02", *date); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -date: %v\n", err)
+			return 2
+		}
+	}
+
+	report, err := gobinapi.CheckDayQuality(*dir, day, *maxEmpty)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "validate failed: %v\n", err)
+		return 1
+	}
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create report: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write report: %v\n", err)
+		return 1
+	}
+	if report.Issues > 0 {
+		fmt.Fprintf(os.Stderr, "%d issues found on %s\n", report.Issues, report.Date)
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	gobinapi "gobinapi_o3"
+)
+
+// runVision implements the "vision" subcommand, which converts the bulk archives of data.binance.vision into the
+// recordings' file layout, filling the days the recorder missed.
+func runVision(args []string) int {
+	fs := flag.NewFlagSet("vision", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "output directory of the recordings to fill")
+	hive := fs.Bool("hive", false, "the recordings use Hive-style partition directories")
+	symbols := fs.String("symbols", "", "comma-separated symbols, e.g. BTCUSDT,ETHUSDT")
+	types := fs.String("types", "trade", "comma-separated data types: trade, aggTrade or kline_<interval>, e.g. kline_1m")
+	market := fs.String("market", gobinapi.MarketSpot, "market: spot or usdm")
+	from := fs.String("from", "", "start of the range (RFC 3339 or YYYY-MM-DD, inclusive)")
+	to := fs.String("to", "", "end of the range (RFC 3339 or YYYY-MM-DD, exclusive); defaults to one day after -from")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gobinapi vision -symbols <symbols> -from <start> [-to <end>] [-types trade,aggTrade,kline_1m] [-dir .] [-hive] [-market spot|usdm]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *symbols == "" || *from == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := gobinapi.ValidateMarket(*market); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -market: %v\n", err)
+		return 2
+	}
+	for _, dataType := range strings.Split(*types, ",") {
+		if _, ok := gobinapi.KlineInterval(dataType); !ok && dataType != "trade" && dataType != "aggTrade" {
+			fmt.Fprintf(os.Stderr, "invalid -types: unsupported data type %q\n", dataType)
+			return 2
+		}
+	}
+	start, err := parseQueryTime(*from, time.Time{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -from: %v\n", err)
+		return 2
+	}
+	end, err := parseQueryTime(*to, start.Add(24*time.Hour))
+	if err != nil || !end.After(start) {
+		fmt.Fprintf(os.Stderr, "invalid -to: must be after -from\n")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	d := gobinapi.NewVisionDownloader(&http.Client{}, *market, gobinapi.NewLogger(os.Stderr))
+	layout := gobinapi.FileLayout{Root: *dir, Hive: *hive}
+	for _, symbol := range strings.Split(*symbols, ",") {
+		for _, dataType := range strings.Split(*types, ",") {
+			stats, err := d.Download(ctx, layout, dataType, symbol, start.UTC(), end.UTC())
+			for _, file := range stats.Files {
+				fmt.Printf("wrote %s\n", file)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "converting %s %s archives failed: %v\n", symbol, dataType, err)
+				return 1
+			}
+			fmt.Printf("%s %s: read %d records, %d were missing and recorded\n", symbol, dataType, stats.Fetched, stats.Recorded)
+			if len(stats.Unavailable) > 0 {
+				fmt.Printf("%s %s: no archives for %s\n", symbol, dataType, strings.Join(stats.Unavailable, ", "))
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,288 @@
+package gobinapi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// Encodings of prices and quantities in ParquetOptions.Numbers. NumbersString, the default, stores them as the
+// UTF8 strings Binance sends. NumbersDecimal stores them as DECIMAL columns backed by INT64 with
+// ParquetOptions.DecimalScale digits after the point, which makes files much smaller and numeric queries faster.
+const (
+	NumbersString  = "string"
+	NumbersDecimal = "decimal"
+)
+
+// Encodings of times in ParquetOptions.Timestamps. TimestampsInt64, the default, stores them as plain INT64
+// milliseconds. TimestampsMillis and TimestampsMicros store them as TIMESTAMP columns (UTC) of that unit, which
+// DuckDB, Spark and pandas read as timestamps without being told.
+const (
+	TimestampsInt64  = "int64"
+	TimestampsMillis = "millis"
+	TimestampsMicros = "micros"
+)
+
+// DefaultDecimalScale is the number of digits after the point of decimal columns if ParquetOptions.DecimalScale is
+// unset, Binance's precision for spot prices and quantities. It limits values to about 92 billion.
+const DefaultDecimalScale = 8
+
+// decimalPrecision is the number of digits an INT64 DECIMAL column holds.
+const decimalPrecision = 18
+
+// columnEncoding says how the tagged fields of records are stored in a file: string fields tagged decimal:"true"
+// as DECIMAL with scale digits after the point if decimals is set, and int64 fields tagged timestamp:"millis" as
+// TIMESTAMP columns of unit timestamps (TimestampsMillis or TimestampsMicros) if that is set. The zero value
+// stores records as they are.
+type columnEncoding struct {
+	decimals   bool
+	scale      int
+	timestamps string
+}
+
+// codec returns the codec converting records of type t to the encoding, or nil if they are written as they are.
+func (e columnEncoding) codec(t reflect.Type) *columnCodec {
+	if e == (columnEncoding{}) {
+		return nil
+	}
+	key := columnCodecKey{t, e}
+	c, ok := columnCodecs.Load(key)
+	if !ok {
+		c, _ = columnCodecs.LoadOrStore(key, newColumnCodec(t, e))
+	}
+	if codec := c.(*columnCodec); codec.mirror != t {
+		return codec
+	}
+	return nil
+}
+
+// columnCodec converts records to and from their mirror type, a struct with the record's parquet fields in which
+// those tagged for the encoding are stored as it says, recursing into slices of structs such as price levels.
+type columnCodec struct {
+	encoding columnEncoding
+	mirror   reflect.Type
+	fields   []columnField
+}
+
+// columnField maps a field of the record type to the field of the same index in the mirror type.
+type columnField struct {
+	index     int
+	decimal   bool
+	timestamp bool
+	// elem converts the elements of a slice of structs with converted fields
+	elem *columnCodec
+}
+
+// columnCodecs caches the codecs by record type and encoding, as building one takes reflection.
+var columnCodecs sync.Map
+
+type columnCodecKey struct {
+	t        reflect.Type
+	encoding columnEncoding
+}
+
+// newColumnCodec builds the codec of struct type t. Fields without a parquet tag are not written, so the mirror
+// type leaves them out. If no field is converted, the mirror type is t itself.
+func newColumnCodec(t reflect.Type, e columnEncoding) *columnCodec {
+	c := &columnCodec{encoding: e}
+	var fields []reflect.StructField
+	converts := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("parquet")
+		if !ok || !f.IsExported() {
+			continue
+		}
+		kv, _ := parseParquetTag(tag)
+		field := columnField{index: i}
+		mirror := reflect.StructField{Name: f.Name, Type: f.Type, Tag: reflect.StructTag(`parquet:"` + tag + `"`)}
+		switch {
+		case e.decimals && f.Tag.Get("decimal") == "true" && f.Type.Kind() == reflect.String:
+			field.decimal = true
+			mirror.Type = reflect.TypeFor[int64]()
+			mirror.Tag = reflect.StructTag(fmt.Sprintf(`parquet:"name=%s, type=INT64, convertedtype=DECIMAL, scale=%d, precision=%d"`,
+				kv["name"], e.scale, decimalPrecision))
+		case e.timestamps != "" && f.Tag.Get("timestamp") == "millis" && f.Type.Kind() == reflect.Int64:
+			field.timestamp = true
+			unit := strings.ToUpper(e.timestamps)
+			mirror.Tag = reflect.StructTag(fmt.Sprintf(`parquet:"name=%s, type=INT64, convertedtype=TIMESTAMP_%s, `+
+				`logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=%s"`, kv["name"], unit, unit))
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Struct:
+			if elem := newColumnCodec(f.Type.Elem(), e); elem.mirror != f.Type.Elem() {
+				field.elem = elem
+				mirror.Type = reflect.SliceOf(elem.mirror)
+			}
+		}
+		converts = converts || field.decimal || field.timestamp || field.elem != nil
+		c.fields = append(c.fields, field)
+		fields = append(fields, mirror)
+	}
+	c.mirror = t
+	if converts {
+		c.mirror = reflect.StructOf(fields)
+	}
+	return c
+}
+
+// prototype returns a new mirror record, for creating parquet writers and readers.
+func (c *columnCodec) prototype() interface{} {
+	return reflect.New(c.mirror).Interface()
+}
+
+// encode converts a record into its mirror.
+func (c *columnCodec) encode(record reflect.Value) (reflect.Value, error) {
+	out := reflect.New(c.mirror).Elem()
+	for i, f := range c.fields {
+		src, dst := record.Field(f.index), out.Field(i)
+		switch {
+		case f.decimal:
+			v, err := parseDecimal(src.String(), c.encoding.scale)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("%s: %w", record.Type().Field(f.index).Name, err)
+			}
+			dst.SetInt(v)
+		case f.timestamp && c.encoding.timestamps == TimestampsMicros:
+			dst.SetInt(src.Int() * 1000)
+		case f.elem != nil:
+			if src.IsNil() {
+				continue
+			}
+			elems := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+			for j := 0; j < src.Len(); j++ {
+				v, err := f.elem.encode(src.Index(j))
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				elems.Index(j).Set(v)
+			}
+			dst.Set(elems)
+		default:
+			dst.Set(src)
+		}
+	}
+	return out, nil
+}
+
+// decode converts a mirror record back into a record of type t, with decimals formatted with the encoding's scale
+// and times in milliseconds.
+func (c *columnCodec) decode(mirror reflect.Value, t reflect.Type) reflect.Value {
+	out := reflect.New(t).Elem()
+	for i, f := range c.fields {
+		src, dst := mirror.Field(i), out.Field(f.index)
+		switch {
+		case f.decimal:
+			dst.SetString(formatDecimal(src.Int(), c.encoding.scale))
+		case f.timestamp && c.encoding.timestamps == TimestampsMicros:
+			dst.SetInt(src.Int() / 1000)
+		case f.elem != nil:
+			if src.IsNil() {
+				continue
+			}
+			elems := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+			for j := 0; j < src.Len(); j++ {
+				elems.Index(j).Set(f.elem.decode(src.Index(j), dst.Type().Elem()))
+			}
+			dst.Set(elems)
+		default:
+			dst.Set(src)
+		}
+	}
+	return out
+}
+
+// parseDecimal parses a decimal number like "-12.3400" into an integer with scale digits after the point. An empty
+// string is zero. Digits after the scale must be zeros, so no value is rounded.
+func parseDecimal(s string, scale int) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	digits, negative := strings.CutPrefix(s, "-")
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	if len(frac) > scale {
+		if strings.Trim(frac[scale:], "0") != "" {
+			return 0, fmt.Errorf("decimal %q has more than %d digits after the point", s, scale)
+		}
+		frac = frac[:scale]
+	}
+	frac += strings.Repeat("0", scale-len(frac))
+	for _, part := range []string{whole, frac} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, fmt.Errorf("invalid decimal %q", s)
+		}
+	}
+	significant := strings.TrimLeft(whole+frac, "0")
+	if len(significant) > decimalPrecision {
+		return 0, fmt.Errorf("decimal %q does not fit %d digits with %d after the point", s, decimalPrecision, scale)
+	}
+	if significant == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(significant, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal %q: %w", s, err)
+	}
+	if negative {
+		n = -n
+	}
+	return n, nil
+}
+
+// formatDecimal formats an integer with scale digits after the point as a decimal number, e.g. "12.34000000".
+func formatDecimal(v int64, scale int) string {
+	if scale == 0 {
+		return strconv.FormatInt(v, 10)
+	}
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign, u = "-", -u
+	}
+	digits := strconv.FormatUint(u, 10)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// fileColumnEncoding returns how the parquet file at filePath stores its tagged columns, judged from the decimal
+// and timestamp columns in its schema.
+func fileColumnEncoding(filePath string) (columnEncoding, error) {
+	fr, err := local.NewLocalFileReader(filePath)
+	if err != nil {
+		return columnEncoding{}, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		return columnEncoding{}, fmt.Errorf("failed to read parquet footer of %s: %w", filePath, err)
+	}
+	defer pr.ReadStop()
+	var e columnEncoding
+	for _, el := range pr.Footer.Schema {
+		if el.ConvertedType == nil {
+			continue
+		}
+		switch *el.ConvertedType {
+		case parquet.ConvertedType_DECIMAL:
+			if el.Scale == nil {
+				return columnEncoding{}, errors.New("decimal column without a scale")
+			}
+			e.decimals, e.scale = true, int(*el.Scale)
+		case parquet.ConvertedType_TIMESTAMP_MILLIS:
+			e.timestamps = TimestampsMillis
+		case parquet.ConvertedType_TIMESTAMP_MICROS:
+			e.timestamps = TimestampsMicros
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,135 @@
+package gobinapi
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDecimal_RoundTrips(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		scale int
+		want  int64
+		out   string
+	}{
+		{"100.50000000", 8, 10050000000, "100.50000000"},
+		{"0.00001234", 8, 1234, "0.00001234"},
+		{"-0.5", 2, -50, "-0.50"},
+		{"42", 0, 42, "42"},
+		{"7.10000000", 2, 710, "7.10"},
+		{"", 8, 0, "0.00000000"},
+	} {
+		got, err := parseDecimal(tc.in, tc.scale)
+		if err != nil || got != tc.want {
+			t.Errorf("parseDecimal(%q, %d) = %d, %v; want %d", tc.in, tc.scale, got, err, tc.want)
+			continue
+		}
+		if out := formatDecimal(got, tc.scale); out != tc.out {
+			t.Errorf("formatDecimal(%d, %d) = %q, want %q", got, tc.scale, out, tc.out)
+		}
+	}
+	for _, in := range []string{"1.005", "1e5", "abc", ".", "1234567890123.45678901"} {
+		if _, err := parseDecimal(in, 2); err == nil {
+			t.Errorf("expected parseDecimal(%q, 2) to fail", in)
+		}
+	}
+}
+
+// TestRecorder_WritesDecimalColumns records diffs with decimal numbers and checks that the file stores them as
+// DECIMAL columns, reads back as the strings written, and leaves out a diff whose numbers do not fit.
+func TestRecorder_WritesDecimalColumns(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	useFakeClock(t, start)
+	t.Chdir(t.TempDir())
+	old := ParquetOptionsByType
+	ParquetOptionsByType = map[string]ParquetOptions{"orderBookDiff": {Numbers: NumbersDecimal, DecimalScale: 4}}
+	t.Cleanup(func() { ParquetOptionsByType = old })
+
+	r, err := NewRecorder[OrderBookDiff]("DECUSDT", "orderBookDiff", 2)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	summary := NewSummaryCollector(&FakeLogger{})
+	r.SetSummary(summary)
+	// Rotating hourly lists the part in the day's part index
+	r.SetRotationInterval(time.Hour)
+	first, last := start.Add(-time.Second), start.Add(time.Second)
+	diffs := []OrderBookDiff{
+		{FirstUpdateID: 1, FinalUpdateID: 1, EventTime: first.UnixMilli(), Bids: []PriceLevel{{Price: "100.5000", Quantity: "0.0010"}}},
+		{FirstUpdateID: 2, FinalUpdateID: 2, EventTime: start.Add(-time.Minute).UnixMilli(), Asks: []PriceLevel{{Price: "100.12345678", Quantity: "1"}}},
+		{FirstUpdateID: 3, FinalUpdateID: 3, EventTime: last.UnixMilli(), Asks: []PriceLevel{{Price: "101.2500", Quantity: "3.0000"}, {Price: "102.0000", Quantity: "0.0000"}}},
+	}
+	for _, diff := range diffs {
+		if err := r.Write(diff); err != nil {
+			t.Fatalf("failed to write diff: %v", err)
+		}
+	}
+	// The dropped diff, the earliest of all, counts neither as a row nor towards the part's times or the summary
+	if stats := r.Stats(); stats.Rows != 2 || stats.FileRows != 2 {
+		t.Errorf("expected 2 rows, got %d rows and %d in the file", stats.Rows, stats.FileRows)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+	index, err := ReadPartIndex(DefaultFileLayout.IndexPath("orderBookDiff", "DECUSDT", start))
+	if err != nil {
+		t.Fatalf("failed to read the part index: %v", err)
+	}
+	if len(index) != 1 || !index[0].FirstTime.Equal(first) || !index[0].LastTime.Equal(last) || index[0].Rows != 2 {
+		t.Errorf("expected one part of 2 rows from %s to %s, got %+v", first, last, index)
+	}
+	if err := summary.Close(); err != nil {
+		t.Fatalf("failed to close summaries: %v", err)
+	}
+	rows, err := ReadParquetFile[DailySummary](DefaultFileLayout.FilePath(SummaryDataType, "DECUSDT", start, 0))
+	if err != nil {
+		t.Fatalf("failed to read summary: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Messages != 2 || rows[0].FirstEventTime != first.UnixMilli() || rows[0].LastEventTime != last.UnixMilli() {
+		t.Errorf("expected a summary of 2 messages from %s to %s, got %+v", first, last, rows)
+	}
+
+	if e, err := fileColumnEncoding(r.filePath); err != nil || e != (columnEncoding{decimals: true, scale: 4}) {
+		t.Fatalf("expected decimal columns with scale 4, got %+v, %v", e, err)
+	}
+	got, err := ReadParquetFile[OrderBookDiff](r.filePath)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", r.filePath, err)
+	}
+	// Compared as text, as empty sides may read back as nil or empty slices
+	want := []OrderBookDiff{diffs[0], diffs[2]}
+	if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if rows, ok := parquetRowCount[OrderBookDiff](r.filePath); !ok || rows != 2 {
+		t.Errorf("expected the footer to count 2 rows, got %d, %v", rows, ok)
+	}
+}
+
+// TestWriteParquetFile_TimestampColumns writes trades with their times as TIMESTAMP columns of either unit and checks
+// the columns' types and that the times read back as the milliseconds written.
+func TestWriteParquetFile_TimestampColumns(t *testing.T) {
+	trades := []Trade{
+		{EventTime: 1739966400123, TradeID: 1, Price: "100.0", Quantity: "1.0", TradeTime: 1739966400120},
+		{EventTime: 1739966400456, TradeID: 2, Price: "100.5", Quantity: "2.0", TradeTime: 1739966400450},
+	}
+	for _, unit := range []string{TimestampsMillis, TimestampsMicros} {
+		filePath := filepath.Join(t.TempDir(), "trades.parquet")
+		if err := writeParquetFile(filePath, trades, ParquetOptions{Timestamps: unit}); err != nil {
+			t.Fatalf("%s: failed to write %s: %v", unit, filePath, err)
+		}
+		if e, err := fileColumnEncoding(filePath); err != nil || e != (columnEncoding{timestamps: unit}) {
+			t.Errorf("%s: expected timestamp columns, got %+v, %v", unit, e, err)
+		}
+		got, err := ReadParquetFile[Trade](filePath)
+		if err != nil {
+			t.Fatalf("%s: failed to read %s: %v", unit, filePath, err)
+		}
+		if !reflect.DeepEqual(got, trades) {
+			t.Errorf("%s: expected %+v, got %+v", unit, trades, got)
+		}
+	}
+}
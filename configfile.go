@@ -0,0 +1,324 @@
+package gobinapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the on-disk layout read by LoadConfigFile. Settings that are left out keep their DefaultConfig
+// values. Durations are written like "30s" or "1m".
+type configFile struct {
+	Market                *string                   `yaml:"market"`
+	Testnet               *bool                     `yaml:"testnet"`
+	StreamEndpoints       []string                  `yaml:"stream_endpoints"`
+	FailoverAfter         *int                      `yaml:"failover_after"`
+	RESTEndpoints         []string                  `yaml:"rest_endpoints"`
+	EndpointProbeInterval *time.Duration            `yaml:"endpoint_probe_interval"`
+	MaxEndpointLatency    *time.Duration            `yaml:"max_endpoint_latency"`
+	Proxies               []string                  `yaml:"proxies"`
+	Instruments           []instrumentConfig        `yaml:"instruments"`
+	Discover              *DiscoverConfig           `yaml:"discover"`
+	OutputDir             *string                   `yaml:"output_dir"`
+	HivePartitioning      *bool                     `yaml:"hive_partitioning"`
+	BatchSize             *int                      `yaml:"batch_size"`
+	FlushInterval         *time.Duration            `yaml:"flush_interval"`
+	WriteQueue            *int                      `yaml:"write_queue"`
+	RotateEvery           *time.Duration            `yaml:"rotate_every"`
+	MaxFileSize           *int64                    `yaml:"max_file_size"`
+	OnExistingFile        *string                   `yaml:"on_existing_file"`
+	Manifest              *bool                     `yaml:"manifest"`
+	Checksums             *bool                     `yaml:"checksums"`
+	VerifyFiles           *bool                     `yaml:"verify_files"`
+	DailySummary          *bool                     `yaml:"daily_summary"`
+	Parquet               *ParquetOptions           `yaml:"parquet"`
+	ParquetByType         map[string]ParquetOptions `yaml:"parquet_by_type"`
+	SnapshotInterval      *time.Duration            `yaml:"snapshot_interval"`
+	SnapshotLimit         *int                      `yaml:"snapshot_limit"`
+	SnapshotStagger       *bool                     `yaml:"snapshot_stagger"`
+	SnapshotGapsOnly      *bool                     `yaml:"snapshot_gaps_only"`
+	TopOfBookInterval     *time.Duration            `yaml:"top_of_book_interval"`
+	TopOfBookLevels       *int                      `yaml:"top_of_book_levels"`
+	BookTopInterval       *time.Duration            `yaml:"book_top_interval"`
+	BookTopLevels         *int                      `yaml:"book_top_levels"`
+	BookFeatureInterval   *time.Duration            `yaml:"book_feature_interval"`
+	RESTWeightLimit       *int                      `yaml:"rest_weight_limit"`
+	RESTWorkers           *int                      `yaml:"rest_workers"`
+	StreamIdleTimeout     *time.Duration            `yaml:"stream_idle_timeout"`
+	PingInterval          *time.Duration            `yaml:"ping_interval"`
+	ConnectionLifetime    *time.Duration            `yaml:"connection_lifetime"`
+	MultiplexStreams      *bool                     `yaml:"multiplex_streams"`
+	AllBookTickers        *bool                     `yaml:"all_book_tickers"`
+	ArchiveRaw            *bool                     `yaml:"archive_raw"`
+	AddressFamily         *string                   `yaml:"address_family"`
+	EnrichBestPrice       *bool                     `yaml:"enrich_best_price"`
+	BestPriceChangeOnly   *bool                     `yaml:"best_price_change_only"`
+	BestPriceKeyframe     *time.Duration            `yaml:"best_price_keyframe"`
+	BestPriceMaxPerSec    *int                      `yaml:"best_price_max_per_second"`
+	TickerArrays          []string                  `yaml:"ticker_arrays"`
+	FuturesStats          *FuturesStatsConfig       `yaml:"futures_stats"`
+	ReferenceKlines       []string                  `yaml:"reference_klines"`
+	Options               *OptionsConfig            `yaml:"options"`
+	UserData              *UserDataConfig           `yaml:"user_data"`
+	RecordFilters         map[string]RecordFilter   `yaml:"record_filters"`
+	Bars                  []string                  `yaml:"bars"`
+	MidPrice              *MidPriceConfig           `yaml:"mid_price"`
+	DebugStreams          map[string][]string       `yaml:"debug_streams"`
+	Alerts                *AlertConfig              `yaml:"alerts"`
+	ExchangeInfo          *bool                     `yaml:"exchange_info"`
+	BackfillGaps          *bool                     `yaml:"backfill_gaps"`
+	StrictValidation      *bool                     `yaml:"strict_validation"`
+	QuarantineFile        *string                   `yaml:"quarantine_file"`
+	MaxClockSkew          *time.Duration            `yaml:"max_clock_skew"`
+	HAMode                *string                   `yaml:"ha_mode"`
+	HeartbeatFile         *string                   `yaml:"heartbeat_file"`
+	HeartbeatInterval     *time.Duration            `yaml:"heartbeat_interval"`
+	HeartbeatTimeout      *time.Duration            `yaml:"heartbeat_timeout"`
+	Influx                *InfluxConfig             `yaml:"influx"`
+	Timescale             *TimescaleConfig          `yaml:"timescale"`
+	ClickHouse            *ClickHouseConfig         `yaml:"clickhouse"`
+	Kafka                 *KafkaConfig              `yaml:"kafka"`
+	Sinks                 map[string][]string       `yaml:"sinks"`
+	ChannelBuffers        *ChannelBufferSizes       `yaml:"channel_buffers"`
+	OverflowPolicies      map[string]OverflowPolicy `yaml:"overflow_policies"`
+	DropJournalInterval   *time.Duration            `yaml:"drop_journal_interval"`
+	Upload                *UploadConfig             `yaml:"upload"`
+	Retention             *RetentionConfig          `yaml:"retention"`
+	DiskGuard             *DiskGuardConfig          `yaml:"disk_guard"`
+	SpillDir              *string                   `yaml:"spill_dir"`
+	MetricsAddr           *string                   `yaml:"metrics_addr"`
+	DebugAddr             *string                   `yaml:"debug_addr"`
+	AdminAddr             *string                   `yaml:"admin_addr"`
+	HealthAddr            *string                   `yaml:"health_addr"`
+	HealthDegraded        *time.Duration            `yaml:"health_degraded_after"`
+	HealthUnhealthy       *time.Duration            `yaml:"health_unhealthy_after"`
+	ShutdownTimeout       *time.Duration            `yaml:"shutdown_timeout"`
+}
+
+// instrumentConfig is one entry of the instruments list: either a bare symbol, which records every stream, or a
+// mapping with the symbol, the streams to record and how to conflate its book ticker.
+type instrumentConfig struct {
+	Symbol     string               `yaml:"symbol"`
+	Streams    []string             `yaml:"streams"`
+	BookTicker *BestPriceConflation `yaml:"book_ticker"`
+}
+
+func (ic *instrumentConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&ic.Symbol)
+	}
+	type plain instrumentConfig
+	return node.Decode((*plain)(ic))
+}
+
+// LoadConfigFile reads a YAML config file (JSON works too, being valid YAML) on top of DefaultConfig and validates
+// the result. A minimal file looks like:
+//
+//	instruments:
+//	  - BTCUSDT
+//	  - symbol: ETHUSDT
+//	    streams: [trade, bookTicker]
+//	batch_size: 100
+//	snapshot_interval: 30s
+//
+// Unknown keys are rejected so that typos do not silently fall back to defaults.
+func LoadConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseConfig is LoadConfigFile for config data already in memory.
+func ParseConfig(data []byte) (Config, error) {
+	var file configFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Config{}, errors.New("config: file is empty")
+		}
+		return Config{}, fmt.Errorf("config: %w", err)
+	}
+
+	cfg := DefaultConfig()
+	setIfPresent(&cfg.Market, file.Market)
+	setIfPresent(&cfg.Testnet, file.Testnet)
+	if file.StreamEndpoints != nil {
+		cfg.StreamEndpoints = file.StreamEndpoints
+	}
+	setIfPresent(&cfg.FailoverAfter, file.FailoverAfter)
+	if file.RESTEndpoints != nil {
+		cfg.RESTEndpoints = file.RESTEndpoints
+	}
+	setIfPresent(&cfg.EndpointProbeInterval, file.EndpointProbeInterval)
+	setIfPresent(&cfg.MaxEndpointLatency, file.MaxEndpointLatency)
+	if file.Proxies != nil {
+		cfg.Proxies = file.Proxies
+	}
+	if file.Instruments != nil {
+		cfg.Instruments = nil
+		for _, ic := range file.Instruments {
+			cfg.Instruments = append(cfg.Instruments, ic.Symbol)
+			if ic.Streams != nil {
+				if cfg.Streams == nil {
+					cfg.Streams = make(map[string][]string)
+				}
+				cfg.Streams[ic.Symbol] = ic.Streams
+			}
+			if ic.BookTicker != nil {
+				if cfg.BestPriceConflation == nil {
+					cfg.BestPriceConflation = make(map[string]BestPriceConflation)
+				}
+				cfg.BestPriceConflation[ic.Symbol] = *ic.BookTicker
+			}
+		}
+	}
+	if file.Discover != nil {
+		// Discovered instruments replace the default ones unless instruments are listed as well
+		cfg.Discover = file.Discover
+		if file.Instruments == nil {
+			cfg.Instruments = nil
+		}
+	}
+	setIfPresent(&cfg.OutputDir, file.OutputDir)
+	setIfPresent(&cfg.HivePartitioning, file.HivePartitioning)
+	setIfPresent(&cfg.BatchSize, file.BatchSize)
+	setIfPresent(&cfg.FlushInterval, file.FlushInterval)
+	setIfPresent(&cfg.WriteQueue, file.WriteQueue)
+	setIfPresent(&cfg.RotateEvery, file.RotateEvery)
+	setIfPresent(&cfg.MaxFileSize, file.MaxFileSize)
+	setIfPresent(&cfg.OnExistingFile, file.OnExistingFile)
+	setIfPresent(&cfg.Manifest, file.Manifest)
+	setIfPresent(&cfg.Checksums, file.Checksums)
+	setIfPresent(&cfg.VerifyFiles, file.VerifyFiles)
+	setIfPresent(&cfg.DailySummary, file.DailySummary)
+	if file.Parquet != nil {
+		// Unset fields keep their defaults rather than reverting to zero
+		cfg.Parquet = file.Parquet.Over(cfg.Parquet)
+	}
+	if file.ParquetByType != nil {
+		cfg.ParquetByType = file.ParquetByType
+	}
+	setIfPresent(&cfg.SnapshotInterval, file.SnapshotInterval)
+	setIfPresent(&cfg.SnapshotLimit, file.SnapshotLimit)
+	setIfPresent(&cfg.SnapshotStagger, file.SnapshotStagger)
+	setIfPresent(&cfg.SnapshotGapsOnly, file.SnapshotGapsOnly)
+	setIfPresent(&cfg.TopOfBookInterval, file.TopOfBookInterval)
+	setIfPresent(&cfg.TopOfBookLevels, file.TopOfBookLevels)
+	setIfPresent(&cfg.BookTopInterval, file.BookTopInterval)
+	setIfPresent(&cfg.BookTopLevels, file.BookTopLevels)
+	setIfPresent(&cfg.BookFeatureInterval, file.BookFeatureInterval)
+	setIfPresent(&cfg.RESTWeightLimit, file.RESTWeightLimit)
+	setIfPresent(&cfg.RESTWorkers, file.RESTWorkers)
+	setIfPresent(&cfg.StreamIdleTimeout, file.StreamIdleTimeout)
+	setIfPresent(&cfg.PingInterval, file.PingInterval)
+	setIfPresent(&cfg.ConnectionLifetime, file.ConnectionLifetime)
+	setIfPresent(&cfg.MultiplexStreams, file.MultiplexStreams)
+	setIfPresent(&cfg.AllBookTickers, file.AllBookTickers)
+	setIfPresent(&cfg.ArchiveRaw, file.ArchiveRaw)
+	setIfPresent(&cfg.AddressFamily, file.AddressFamily)
+	setIfPresent(&cfg.EnrichBestPrice, file.EnrichBestPrice)
+	setIfPresent(&cfg.BestPriceChangeOnly, file.BestPriceChangeOnly)
+	setIfPresent(&cfg.BestPriceKeyframe, file.BestPriceKeyframe)
+	setIfPresent(&cfg.BestPriceMaxPerSecond, file.BestPriceMaxPerSec)
+	if file.TickerArrays != nil {
+		cfg.TickerArrays = file.TickerArrays
+	}
+	if file.RecordFilters != nil {
+		cfg.RecordFilters = file.RecordFilters
+	}
+	if file.Bars != nil {
+		cfg.Bars = file.Bars
+	}
+	if file.MidPrice != nil {
+		cfg.MidPrice = file.MidPrice
+	}
+	if file.FuturesStats != nil {
+		cfg.FuturesStats = file.FuturesStats
+	}
+	if file.ReferenceKlines != nil {
+		cfg.ReferenceKlines = file.ReferenceKlines
+	}
+	if file.Options != nil {
+		cfg.Options = file.Options
+	}
+	if file.UserData != nil {
+		cfg.UserData = file.UserData
+	}
+	if file.DebugStreams != nil {
+		cfg.DebugStreams = file.DebugStreams
+	}
+	setIfPresent(&cfg.ExchangeInfo, file.ExchangeInfo)
+	setIfPresent(&cfg.BackfillGaps, file.BackfillGaps)
+	setIfPresent(&cfg.StrictValidation, file.StrictValidation)
+	setIfPresent(&cfg.QuarantineFile, file.QuarantineFile)
+	setIfPresent(&cfg.MaxClockSkew, file.MaxClockSkew)
+	setIfPresent(&cfg.HAMode, file.HAMode)
+	setIfPresent(&cfg.HeartbeatFile, file.HeartbeatFile)
+	setIfPresent(&cfg.HeartbeatInterval, file.HeartbeatInterval)
+	setIfPresent(&cfg.HeartbeatTimeout, file.HeartbeatTimeout)
+	if file.Influx != nil {
+		cfg.Influx = file.Influx
+	}
+	if file.Timescale != nil {
+		cfg.Timescale = file.Timescale
+	}
+	if file.ClickHouse != nil {
+		cfg.ClickHouse = file.ClickHouse
+	}
+	if file.Kafka != nil {
+		cfg.Kafka = file.Kafka
+	}
+	if file.Alerts != nil {
+		cfg.Alerts = file.Alerts
+	}
+	if file.Sinks != nil {
+		cfg.Sinks = file.Sinks
+	}
+	if file.ChannelBuffers != nil {
+		// Unset sizes keep their defaults rather than failing validation as zero
+		cfg.ChannelBuffers = file.ChannelBuffers.Over(cfg.ChannelBuffers)
+	}
+	if file.OverflowPolicies != nil {
+		cfg.OverflowPolicies = file.OverflowPolicies
+	}
+	setIfPresent(&cfg.DropJournalInterval, file.DropJournalInterval)
+	if file.Upload != nil {
+		cfg.Upload = file.Upload
+	}
+	if file.Retention != nil {
+		cfg.Retention = file.Retention
+	}
+	if file.DiskGuard != nil {
+		cfg.DiskGuard = file.DiskGuard
+	}
+	setIfPresent(&cfg.SpillDir, file.SpillDir)
+	setIfPresent(&cfg.MetricsAddr, file.MetricsAddr)
+	setIfPresent(&cfg.DebugAddr, file.DebugAddr)
+	setIfPresent(&cfg.AdminAddr, file.AdminAddr)
+	setIfPresent(&cfg.HealthAddr, file.HealthAddr)
+	setIfPresent(&cfg.HealthDegradedAfter, file.HealthDegraded)
+	setIfPresent(&cfg.HealthUnhealthyAfter, file.HealthUnhealthy)
+	setIfPresent(&cfg.ShutdownTimeout, file.ShutdownTimeout)
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func setIfPresent[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
@@ -0,0 +1,380 @@
+package gobinapi
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFile_AppliesSettingsOverDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorder.yaml")
+	data := `
+instruments:
+  - BTCUSDT
+  - symbol: ETHUSDT
+    streams: [trade, bookTicker]
+    book_ticker:
+      max_per_second: 10
+batch_size: 100
+write_queue: 64
+flush_interval: 5s
+rotate_every: 1h
+max_file_size: 1000000
+manifest: true
+checksums: true
+verify_files: true
+daily_summary: true
+best_price_change_only: true
+best_price_keyframe: 30s
+ticker_arrays: [miniTicker]
+all_book_tickers: true
+record_filters:
+  trade:
+    min_notional: 10000
+    side: buy
+bars: [1s, 1m]
+mid_price:
+  interval: 100ms
+  change_only: true
+snapshot_interval: 30s
+snapshot_stagger: false
+snapshot_gaps_only: true
+top_of_book_interval: 2s
+book_feature_interval: 500ms
+exchange_info: false
+health_addr: :8080
+health_degraded_after: 2m
+backfill_gaps: true
+debug_streams:
+  ETHUSDT: [trade]
+clickhouse:
+  addr: clickhouse:9000
+  async_insert: true
+kafka:
+  brokers: [kafka-1:9092, kafka-2:9092]
+  topic_prefix: binance.
+  format: avro
+sinks:
+  orderBookDiff: [parquet]
+  trade: [kafka, clickhouse, parquet]
+overflow_policies:
+  bestPrice: dropOldest
+upload:
+  s3:
+    bucket: market-data
+    region: eu-west-1
+    prefix: binance/
+  delete_uploaded: true
+disk_guard:
+  min_free: 5368709120
+  shed: [orderBookDiff]
+retention:
+  max_age_days: 30
+  uploaded_only: true
+  dry_run: true
+alerts:
+  webhook_url: https://hooks.example.com/alerts
+  min_interval: 10m
+  reconnect_threshold: 5
+  telegram:
+    chat_id: "42"
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatalf("LoadConfigFile: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.Instruments, []string{"BTCUSDT", "ETHUSDT"}) {
+		t.Errorf("unexpected instruments %v", cfg.Instruments)
+	}
+	if cfg.BatchSize != 100 || cfg.WriteQueue != 64 || cfg.FlushInterval != 5*time.Second || cfg.RotateEvery != time.Hour ||
+		cfg.MaxFileSize != 1000000 || cfg.SnapshotInterval != 30*time.Second || cfg.TopOfBookInterval != 2*time.Second ||
+		cfg.SnapshotStagger || !cfg.SnapshotGapsOnly || cfg.ExchangeInfo || !cfg.BackfillGaps || !cfg.Manifest ||
+		!cfg.Checksums || !cfg.VerifyFiles || !cfg.DailySummary || !reflect.DeepEqual(cfg.Bars, []string{"1s", "1m"}) {
+		t.Errorf("settings not applied: %+v", cfg)
+	}
+	if !cfg.BestPriceChangeOnly || cfg.BestPriceKeyframe != 30*time.Second || cfg.BestPriceMaxPerSecond != 0 ||
+		cfg.BestPriceConflationFor("ETHUSDT") != (BestPriceConflation{MaxPerSecond: 10}) ||
+		cfg.BestPriceConflationFor("BTCUSDT") != (BestPriceConflation{ChangeOnly: true}) {
+		t.Errorf("best price conflation not applied: %+v", cfg.BestPriceConflation)
+	}
+	if !reflect.DeepEqual(cfg.TickerArrays, []string{"miniTicker"}) || !cfg.AllBookTickers {
+		t.Errorf("ticker arrays not applied: %v", cfg.TickerArrays)
+	}
+	if !reflect.DeepEqual(cfg.RecordFilters, map[string]RecordFilter{"trade": {MinNotional: 10000, Side: "buy"}}) {
+		t.Errorf("record filters not applied: %+v", cfg.RecordFilters)
+	}
+	if cfg.BookFeatureInterval != 500*time.Millisecond {
+		t.Errorf("expected a book feature interval of 500ms, got %s", cfg.BookFeatureInterval)
+	}
+	if cfg.MidPrice == nil || *cfg.MidPrice != (MidPriceConfig{Interval: 100 * time.Millisecond, ChangeOnly: true}) {
+		t.Errorf("mid price settings not applied: %+v", cfg.MidPrice)
+	}
+	if cfg.HealthAddr != ":8080" || cfg.HealthDegradedAfter != 2*time.Minute || cfg.HealthUnhealthyAfter != 5*time.Minute {
+		t.Errorf("health settings not applied: %s, %s, %s", cfg.HealthAddr, cfg.HealthDegradedAfter, cfg.HealthUnhealthyAfter)
+	}
+	if !reflect.DeepEqual(cfg.DebugStreams, map[string][]string{"ETHUSDT": {"trade"}}) {
+		t.Errorf("unexpected debug streams %v", cfg.DebugStreams)
+	}
+	if cfg.ClickHouse == nil || cfg.ClickHouse.Addr != "clickhouse:9000" || !cfg.ClickHouse.AsyncInsert {
+		t.Errorf("clickhouse settings not applied: %+v", cfg.ClickHouse)
+	}
+	if cfg.Kafka == nil || len(cfg.Kafka.Brokers) != 2 || cfg.Kafka.Format != KafkaFormatAvro {
+		t.Errorf("kafka settings not applied: %+v", cfg.Kafka)
+	}
+	if got := cfg.SinksFor("orderBookDiff"); !reflect.DeepEqual(got, []string{"parquet"}) {
+		t.Errorf("unexpected orderBookDiff sinks %v", got)
+	}
+	if got := cfg.SinksFor("bestPrice"); !reflect.DeepEqual(got, []string{"parquet", "clickhouse", "kafka"}) {
+		t.Errorf("expected best prices to go to every sink by default, got %v", got)
+	}
+	if cfg.OverflowPolicyFor("bestPrice") != OverflowDropOldest || cfg.OverflowPolicyFor("trade") != OverflowSpill {
+		t.Errorf("unexpected overflow policies %v", cfg.OverflowPolicies)
+	}
+	if cfg.Alerts == nil || cfg.Alerts.WebhookURL != "https://hooks.example.com/alerts" ||
+		cfg.Alerts.MinInterval != 10*time.Minute || cfg.Alerts.ReconnectThreshold != 5 ||
+		cfg.Alerts.Telegram == nil || cfg.Alerts.Telegram.ChatID != "42" {
+		t.Errorf("alert settings not applied: %+v", cfg.Alerts)
+	}
+	if cfg.Upload == nil || cfg.Upload.S3 == nil || cfg.Upload.S3.Bucket != "market-data" || !cfg.Upload.DeleteUploaded {
+		t.Errorf("upload settings not applied: %+v", cfg.Upload)
+	}
+	if cfg.Retention == nil || cfg.Retention.MaxAgeDays != 30 || !cfg.Retention.UploadedOnly || !cfg.Retention.DryRun {
+		t.Errorf("retention settings not applied: %+v", cfg.Retention)
+	}
+	if cfg.DiskGuard == nil || cfg.DiskGuard.MinFree != 5<<30 || !reflect.DeepEqual(cfg.DiskGuard.Shed, []string{"orderBookDiff"}) {
+		t.Errorf("disk guard settings not applied: %+v", cfg.DiskGuard)
+	}
+	if cfg.SnapshotLimit != DefaultConfig().SnapshotLimit {
+		t.Errorf("expected unset settings to keep their defaults, got snapshot limit %d", cfg.SnapshotLimit)
+	}
+	if got := cfg.StreamsFor("ETHUSDT"); len(got) != 2 || !got[StreamTrade] || !got[StreamBookTicker] {
+		t.Errorf("unexpected ETHUSDT streams %v", got)
+	}
+	if got := cfg.StreamsFor("BTCUSDT"); len(got) != len(AllStreams) {
+		t.Errorf("expected BTCUSDT to record every stream, got %v", got)
+	}
+}
+
+func TestParseConfig_ParquetOptions(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+parquet:
+  compression: gzip
+parquet_by_type:
+  orderBookDiff:
+    compression: zstd
+    row_group_size: 1048576
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.Parquet.Compression != CompressionGzip || cfg.Parquet.PageSize != DefaultPageSize {
+		t.Errorf("expected gzip with the default page size, got %+v", cfg.Parquet)
+	}
+	diff := cfg.ParquetByType["orderBookDiff"].Over(cfg.Parquet)
+	if diff != (ParquetOptions{Compression: CompressionZstd, RowGroupSize: 1 << 20, PageSize: DefaultPageSize}) {
+		t.Errorf("unexpected orderBookDiff options %+v", diff)
+	}
+}
+
+func TestParseConfig_FuturesMarket(t *testing.T) {
+	cfg, err := ParseConfig([]byte("market: usdm\ninstruments: [BTCUSDT]\nsnapshot_limit: 1000\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.Market != MarketUSDM {
+		t.Errorf("expected the usdm market, got %q", cfg.Market)
+	}
+	if got := cfg.StreamsFor("BTCUSDT"); len(got) != len(FuturesStreams) || !got[StreamMarkPrice] || got[StreamTrade] {
+		t.Errorf("expected BTCUSDT to record every futures stream, got %v", got)
+	}
+}
+
+func TestParseConfig_Discover(t *testing.T) {
+	cfg, err := ParseConfig([]byte("discover:\n  quote_asset: USDT\n  top: 50\n  streams: [trade]\n  refresh: true\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if len(cfg.Instruments) != 0 {
+		t.Errorf("expected the discovery to replace the default instruments, got %v", cfg.Instruments)
+	}
+	if d := cfg.Discover; d == nil || d.QuoteAsset != "USDT" || d.Top != 50 || len(d.Streams) != 1 || !d.Refresh {
+		t.Errorf("unexpected discovery %+v", cfg.Discover)
+	}
+	cfg, err = ParseConfig([]byte("instruments: [BTCUSDT]\ndiscover:\n  symbols: ['*USDC']\n"))
+	if err != nil || len(cfg.Instruments) != 1 || cfg.Discover.Symbols[0] != "*USDC" {
+		t.Errorf("expected listed and discovered instruments, got %+v, %v", cfg, err)
+	}
+}
+
+func TestParseConfig_Sinks(t *testing.T) {
+	t.Setenv("INFLUX_TOKEN", "")
+	cfg, err := ParseConfig([]byte(`
+influx:
+  url: http://influx:8086
+  org: market
+  bucket: binance
+  batch_size: 1000
+  flush_interval: 2s
+timescale:
+  trade_table: spot_trades
+  book_table: spot_book
+  batch_size: 5000
+  flush_interval: 500ms
+  create_schema: true
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	wantInflux := InfluxConfig{URL: "http://influx:8086", Org: "market", Bucket: "binance", BatchSize: 1000, FlushInterval: 2 * time.Second}
+	if cfg.Influx == nil || *cfg.Influx != wantInflux {
+		t.Errorf("influx settings not applied: %+v", cfg.Influx)
+	}
+	wantTimescale := TimescaleConfig{TradeTable: "spot_trades", BookTable: "spot_book", BatchSize: 5000, FlushInterval: 500 * time.Millisecond, CreateSchema: true}
+	if cfg.Timescale == nil || *cfg.Timescale != wantTimescale {
+		t.Errorf("timescale settings not applied: %+v", cfg.Timescale)
+	}
+	if got := cfg.SinksFor("trade"); !reflect.DeepEqual(got, []string{"parquet", "influx", "timescale"}) {
+		t.Errorf("expected trades to go to both sinks, got %v", got)
+	}
+	if _, err := ParseConfig([]byte("influx:\n  url: http://influx:8086\n  bucket: b\n  token: secret\n")); err == nil {
+		t.Error("expected the token to be rejected in the file")
+	}
+}
+
+func TestParseConfig_HighAvailability(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+ha_mode: standby
+heartbeat_file: /shared/recorder.heartbeat
+heartbeat_interval: 2s
+heartbeat_timeout: 10s
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.HAMode != HAModeStandby || cfg.HeartbeatFile != "/shared/recorder.heartbeat" ||
+		cfg.HeartbeatInterval != 2*time.Second || cfg.HeartbeatTimeout != 10*time.Second {
+		t.Errorf("HA settings not applied: %q %q %s %s", cfg.HAMode, cfg.HeartbeatFile, cfg.HeartbeatInterval, cfg.HeartbeatTimeout)
+	}
+}
+
+func TestParseConfig_IngestAndRuntime(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+strict_validation: true
+quarantine_file: /var/log/quarantine.jsonl
+max_clock_skew: 2m
+channel_buffers:
+  depth: 5000
+  raw: 2000
+drop_journal_interval: 30s
+enrich_best_price: true
+address_family: ipv4
+rest_weight_limit: 1200
+rest_workers: 8
+shutdown_timeout: 1m
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if !cfg.StrictValidation || cfg.QuarantineFile != "/var/log/quarantine.jsonl" || cfg.MaxClockSkew != 2*time.Minute {
+		t.Errorf("validation settings not applied: %v %q %s", cfg.StrictValidation, cfg.QuarantineFile, cfg.MaxClockSkew)
+	}
+	wantBuffers := DefaultChannelBufferSizes()
+	wantBuffers.Depth, wantBuffers.Raw = 5000, 2000
+	if cfg.ChannelBuffers != wantBuffers {
+		t.Errorf("expected the unset buffer sizes to keep their defaults, got %+v", cfg.ChannelBuffers)
+	}
+	if cfg.DropJournalInterval != 30*time.Second || !cfg.EnrichBestPrice || cfg.AddressFamily != AddressFamilyIPv4 {
+		t.Errorf("unexpected drop journal interval %s, enrichment %v or address family %q", cfg.DropJournalInterval, cfg.EnrichBestPrice, cfg.AddressFamily)
+	}
+	if cfg.RESTWeightLimit != 1200 || cfg.RESTWorkers != 8 || cfg.ShutdownTimeout != time.Minute {
+		t.Errorf("unexpected REST weight limit %d, workers %d or shutdown timeout %s", cfg.RESTWeightLimit, cfg.RESTWorkers, cfg.ShutdownTimeout)
+	}
+}
+
+func TestParseConfig_RejectsInvalidFiles(t *testing.T) {
+	for name, tc := range map[string]struct{ data, want string }{
+		"empty":              {"", "empty"},
+		"unknown key":        {"instruments: [BTCUSDT]\nbatch_sise: 10\n", "batch_sise"},
+		"bad duration":       {"snapshot_interval: soon\n", "line 1"},
+		"unknown stream":     {"instruments:\n  - symbol: BTCUSDT\n    streams: [trades]\n", `unknown stream "trades"`},
+		"no streams":         {"instruments:\n  - symbol: BTCUSDT\n    streams: []\n", "no streams"},
+		"lower case":         {"instruments: [btcusdt]\n", "invalid instrument"},
+		"duplicate":          {"instruments: [BTCUSDT, BTCUSDT]\n", "listed twice"},
+		"no instruments":     {"instruments: []\n", "at least one instrument"},
+		"top without tick":   {"instruments:\n  - symbol: BTCUSDT\n    streams: [snapshotTop]\ntop_of_book_interval: 0s\n", "top-of-book interval"},
+		"bad batch size":     {"batch_size: 0\n", "batch size"},
+		"bad write queue":    {"write_queue: -1\n", "write queue"},
+		"bad flush":          {"flush_interval: -1s\n", "flush interval"},
+		"uneven rotation":    {"rotate_every: 7h\n", "divide a day"},
+		"bad file size":      {"max_file_size: -1\n", "max file size"},
+		"bad resume":         {"on_existing_file: overwrite\n", "existing file policy"},
+		"no influx bucket":   {"influx:\n  url: http://influx:8086\n", "url and bucket are required"},
+		"no heartbeat file":  {"ha_mode: active\n", "heartbeat file is required"},
+		"bad channel buffer": {"channel_buffers:\n  depth: -1\n", "depth channel buffer size"},
+		"bad address family": {"address_family: ipv5\n", `unknown address family "ipv5"`},
+		"no clickhouse addr": {"clickhouse:\n  database: market\n", "address is required"},
+		"no kafka brokers":   {"kafka:\n  format: json\n", "at least one broker"},
+		"bad kafka format":   {"kafka:\n  brokers: [k:9092]\n  format: protobuf\n", `unknown format "protobuf"`},
+		"unconfigured sink":  {"sinks:\n  trade: [parquet, kafka]\n", `sink "kafka" for trade is not configured`},
+		"unknown sink":       {"sinks:\n  trade: [s3]\n", `unknown sink "s3"`},
+		"bad sink type":      {"sinks:\n  markPrice: [parquet]\n", `unknown data type "markPrice"`},
+		"no sinks":           {"sinks:\n  trade: []\n", "no sinks for trade"},
+		"no upload backend":  {"upload:\n  delete_uploaded: true\n", "no upload backend"},
+		"no bucket":          {"upload:\n  s3:\n    region: eu-west-1\n", "bucket and region"},
+		"two backends":       {"upload:\n  s3:\n    bucket: b\n    region: r\n  gcs:\n    bucket: b\n", "only one upload backend"},
+		"bad gcs chunk":      {"upload:\n  gcs:\n    bucket: b\n    chunk_size: 1000\n", "chunk size"},
+		"no retention age":   {"retention:\n  dry_run: true\n", "max_age_days"},
+		"retention upload":   {"retention:\n  max_age_days: 7\n  uploaded_only: true\n", "requires upload"},
+		"no disk minimum":    {"disk_guard:\n  shed: [raw]\n", "min_free"},
+		"unknown bar":        {"bars: [1m, 7m]\n", `unknown bar interval "7m"`},
+		"duplicate bar":      {"bars: [1m, 1m]\n", `bar interval "1m" listed twice`},
+		"bad mid interval":   {"mid_price:\n  interval: -1s\n", "interval must not be negative"},
+		"bad feature tick":   {"book_feature_interval: -1s\n", "book feature interval must not be negative"},
+		"bad ticker rate":    {"instruments:\n  - symbol: BTCUSDT\n    book_ticker:\n      max_per_second: -1\n", "max per second of BTCUSDT"},
+		"bad ticker array":   {"ticker_arrays: [bookTicker]\n", `unknown ticker array "bookTicker"`},
+		"spot futures stats": {"futures_stats:\n  interval: 5m\n", "futures stats need the USD-M futures market"},
+		"spot ref klines":    {"reference_klines: [1m]\n", "reference klines need the USD-M futures market"},
+		"bad ref interval":   {"market: usdm\nreference_klines: [1s]\n", `unknown reference kline interval "1s"`},
+		"bad stats period":   {"market: usdm\nfutures_stats:\n  interval: 5m\n  period: 3m\n", `unknown period "3m"`},
+		"bad stream host":    {"stream_endpoints: [\"https://stream.binance.com:443\"]\n", `must be a wss or ws URL`},
+		"bad rest endpoint":  {"rest_endpoints: [https://api1.binance.com/]\n", "must not end in a slash"},
+		"bad proxy scheme":   {"proxies: [\"https://proxy.internal:3128\"]\n", "must be an http:// or socks5:// URL"},
+		"bad probe interval": {"endpoint_probe_interval: -1m\n", "must not be negative"},
+		"no option streams":  {"options:\n  symbols: []\n", "no symbols, underlyings or expirations"},
+		"bad option symbol":  {"options:\n  symbols: [BTC-250328-C]\n", `malformed option symbol "BTC-250328-C"`},
+		"bad expiration":     {"options:\n  expirations: [BTC@250328]\n", `malformed expiration "BTC@250328"`},
+		"futures user data":  {"market: usdm\nuser_data: {}\n", "user data stream needs the spot market"},
+		"bad keep alive":     {"user_data:\n  keep_alive: 90m\n", "below the listen key's 60 minute expiry"},
+		"bad filter type":    {"record_filters:\n  bestPrice:\n    min_quantity: 1\n", `cannot filter "bestPrice" records`},
+		"bad filter side":    {"record_filters:\n  trade:\n    side: long\n", `unknown side "long"`},
+		"ticker keyframe":    {"instruments:\n  - symbol: BTCUSDT\n    book_ticker:\n      change_only: true\nbest_price_keyframe: 0s\n", "keyframe must be positive"},
+		"bad shed type":      {"disk_guard:\n  min_free: 1024\n  shed: [depth]\n", "unknown data type"},
+		"bad codec":          {"parquet:\n  compression: brotli\n", `unknown compression "brotli"`},
+		"bad numbers":        {"parquet:\n  numbers: float\n", `unknown numbers "float"`},
+		"bad timestamps":     {"parquet:\n  timestamps: nanos\n", `unknown timestamps "nanos"`},
+		"bad codec type":     {"parquet_by_type:\n  depth:\n    compression: zstd\n", `unknown data type "depth"`},
+		"bad market":         {"market: coinm\n", `unknown market "coinm"`},
+		"futures limit":      {"market: usdm\nsnapshot_limit: 5000\n", "USD-M futures"},
+		"debug snapshot":     {"debug_streams:\n  BTCUSDT: [snapshot]\n", `cannot debug the "snapshot" stream`},
+		"empty discover":     {"discover:\n  top: 10\n", "symbol patterns or a quote asset"},
+		"no webhook":         {"alerts:\n  min_interval: 1m\n", "webhook URL is required"},
+		"bad webhook":        {"alerts:\n  webhook_url: hooks.example.com\n", "not an http or https URL"},
+		"no telegram chat":   {"alerts:\n  telegram: {}\n", "chat ID is required"},
+		"health thresholds":  {"health_addr: :8080\nhealth_degraded_after: 10m\n", "degraded (10m0s) before unhealthy"},
+		"bad overflow":       {"overflow_policies:\n  trade: discard\n", `unknown overflow policy "discard"`},
+		"overflow type":      {"overflow_policies:\n  snapshot: block\n", `unknown data type "snapshot"`},
+		"bad pattern":        {"discover:\n  symbols: ['[USDT']\n", "invalid discover pattern"},
+		"discover stream":    {"discover:\n  quote_asset: USDT\n  streams: [markPrice]\n", `unknown discover stream "markPrice"`},
+	} {
+		_, err := ParseConfig([]byte(tc.data))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error mentioning %q, got %v", name, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request weights of the 24 hour ticker endpoints for every symbol.
+const (
+	tickers24hWeight        = 80
+	futuresTickers24hWeight = 40
+)
+
+// DiscoverConfig selects instruments from the exchange info at startup, so hundreds of symbols need not be listed
+// by hand. Only TRADING symbols are discovered, and they are recorded alongside Config.Instruments.
+type DiscoverConfig struct {
+	// Symbols are patterns matched against symbol names as by path.Match, e.g. "*USDT" or "BTC*". Empty matches
+	// every symbol.
+	Symbols []string `json:"symbols,omitempty" yaml:"symbols"`
+	// QuoteAsset, if set, keeps only the symbols quoted in this asset, e.g. "USDT".
+	QuoteAsset string `json:"quote_asset,omitempty" yaml:"quote_asset"`
+	// Top, if positive, keeps only this many of the matching symbols, those with the highest 24 hour quote volume.
+	Top int `json:"top,omitempty" yaml:"top"`
+	// Streams selects the streams recorded for discovered instruments; empty records every stream of the market.
+	Streams []string `json:"streams,omitempty" yaml:"streams"`
+	// Refresh repeats the discovery after every UTC midnight, starting the symbols that match now and stopping the
+	// discovered ones that no longer do, e.g. after a delisting or when they drop out of the top.
+	Refresh bool `json:"refresh,omitempty" yaml:"refresh"`
+}
+
+// Validate checks that the discovery selects something and that its patterns and streams are valid for market.
+func (d DiscoverConfig) Validate(market string) error {
+	if len(d.Symbols) == 0 && d.QuoteAsset == "" {
+		return errors.New("discover needs symbol patterns or a quote asset")
+	}
+	for _, pattern := range d.Symbols {
+		if _, err := path.Match(pattern, ""); err != nil || strings.ToUpper(pattern) != pattern {
+			return fmt.Errorf("invalid discover pattern %q, patterns are upper case like *USDT", pattern)
+		}
+	}
+	if d.Top < 0 {
+		return fmt.Errorf("discover top must not be negative, got %d", d.Top)
+	}
+	for _, s := range d.Streams {
+		if !isStream(market, s) {
+			return fmt.Errorf("unknown discover stream %q, want one of %s", s, strings.Join(marketStreams(market), ", "))
+		}
+	}
+	return nil
+}
+
+// match reports whether the TRADING symbol info is selected, before the Top cut.
+func (d DiscoverConfig) match(info SymbolInfo) bool {
+	if info.Status != SymbolStatusTrading || (d.QuoteAsset != "" && info.QuoteAsset != d.QuoteAsset) {
+		return false
+	}
+	if len(d.Symbols) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(d.Symbols, func(pattern string) bool {
+		ok, _ := path.Match(pattern, info.Symbol)
+		return ok
+	})
+}
+
+// DiscoverSymbols is a pure function returning the symbols of infos that d selects, sorted by name. volumes holds
+// the 24 hour quote volume by symbol (see FetchQuoteVolumes) and is only needed if d.Top is set; symbols without a
+// volume rank last.
+func DiscoverSymbols(infos []SymbolInfo, d DiscoverConfig, volumes map[string]float64) []string {
+	var symbols []string
+	for _, info := range infos {
+		if d.match(info) {
+			symbols = append(symbols, info.Symbol)
+		}
+	}
+	if d.Top > 0 && len(symbols) > d.Top {
+		slices.SortStableFunc(symbols, func(a, b string) int {
+			if volumes[a] != volumes[b] {
+				if volumes[a] > volumes[b] {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(a, b)
+		})
+		symbols = symbols[:d.Top]
+	}
+	slices.Sort(symbols)
+	return symbols
+}
+
+// FetchQuoteVolumes fetches the 24 hour quote volume of every symbol of market (MarketSpot or MarketUSDM), waiting
+// for its request weight in DefaultWeightTracker.
+func FetchQuoteVolumes(ctx context.Context, client *http.Client, market string) (map[string]float64, error) {
+	url, weight := RESTBaseURL+"/api/v3/ticker/24hr", tickers24hWeight
+	if market == MarketUSDM {
+		url, weight = FuturesRESTBaseURL+"/fapi/v1/ticker/24hr", futuresTickers24hWeight
+	}
+	if err := DefaultWeightTracker.Wait(ctx, weight); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch 24h tickers: %w", err)
+	}
+	defer resp.Body.Close()
+	DefaultWeightTracker.ObserveResponse(resp, NowFunc())
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("24h ticker request returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read 24h tickers: %w", err)
+	}
+	var tickers []struct {
+		Symbol      string `json:"symbol"`
+		QuoteVolume string `json:"quoteVolume"`
+	}
+	if err := json.Unmarshal(data, &tickers); err != nil {
+		return nil, fmt.Errorf("failed to parse 24h tickers: %w", err)
+	}
+	volumes := make(map[string]float64, len(tickers))
+	for _, t := range tickers {
+		volume, err := strconv.ParseFloat(t.QuoteVolume, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed quote volume of %s: %w", t.Symbol, err)
+		}
+		volumes[t.Symbol] = volume
+	}
+	return volumes, nil
+}
+
+// discoverInstruments returns the symbols of infos that d selects, fetching the 24 hour volumes if d ranks them.
+func discoverInstruments(ctx context.Context, client *http.Client, market string, d DiscoverConfig, infos []SymbolInfo) ([]string, error) {
+	var volumes map[string]float64
+	if d.Top > 0 {
+		var err error
+		if volumes, err = FetchQuoteVolumes(ctx, client, market); err != nil {
+			return nil, err
+		}
+	}
+	return DiscoverSymbols(infos, d, volumes), nil
+}
+
+// withDiscovered returns cfg recording discovered as well as its own instruments, with the discovery's streams
+// selected for those it adds.
+func (cfg Config) withDiscovered(discovered []string) Config {
+	instruments := slices.Clone(cfg.Instruments)
+	streams := make(map[string][]string, len(cfg.Streams))
+	for instrument, selected := range cfg.Streams {
+		streams[instrument] = selected
+	}
+	for _, symbol := range discovered {
+		if slices.Contains(instruments, symbol) {
+			continue
+		}
+		instruments = append(instruments, symbol)
+		if len(cfg.Discover.Streams) > 0 {
+			streams[symbol] = cfg.Discover.Streams
+		}
+	}
+	cfg.Instruments, cfg.Streams = instruments, streams
+	if len(streams) == 0 {
+		cfg.Streams = nil
+	}
+	return cfg
+}
+
+// refreshDiscovered repeats the discovery of cfg, the configuration before discovery, after every UTC midnight
+// until ctx is cancelled. The symbols that match now are started and those of discovered that no longer do are
+// stopped, unless cfg lists them. Failures are logged and retried after retryDelay; a discovery that finds nothing
+// at all is taken for a bad response and changes nothing.
+func refreshDiscovered(ctx context.Context, cfg Config, env *pipelineEnv, client *http.Client, discovered []string, retryDelay time.Duration) {
+	next := NowFunc().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	for {
+		if err := sleepContext(ctx, next.Sub(NowFunc())); err != nil {
+			return
+		}
+		infos, err := FetchExchangeInfo(ctx, client, cfg.Market)
+		var symbols []string
+		if err == nil {
+			symbols, err = discoverInstruments(ctx, client, cfg.Market, *cfg.Discover, infos)
+		}
+		if err == nil && len(symbols) == 0 {
+			err = errors.New("no symbol matches")
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			env.logger.Errorf("Failed to refresh the discovered instruments: %v; retrying in %s", err, retryDelay)
+			next = NowFunc().Add(retryDelay)
+			continue
+		}
+		discovered = env.applyDiscovered(ctx, cfg, discovered, symbols)
+		next = NowFunc().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	}
+}
+
+// applyDiscovered starts the symbols not recorded yet and stops those of discovered missing from symbols, leaving
+// the instruments cfg lists alone. It returns the symbols now recorded because they were discovered.
+func (env *pipelineEnv) applyDiscovered(ctx context.Context, cfg Config, discovered, symbols []string) []string {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.closed {
+		return discovered
+	}
+	var current []string
+	for _, symbol := range symbols {
+		if slices.Contains(cfg.Instruments, symbol) {
+			continue
+		}
+		current = append(current, symbol)
+		if _, ok := env.pipelines[symbol]; ok {
+			continue
+		}
+		if err := startInstrument(ctx, cfg.withDiscovered([]string{symbol}), symbol, env); err != nil {
+			env.logger.Errorf("Failed to start discovered instrument %s: %v", symbol, err)
+			continue
+		}
+		env.logger.Infof("Started recording newly discovered %s", symbol)
+	}
+	for _, symbol := range discovered {
+		if slices.Contains(current, symbol) || slices.Contains(cfg.Instruments, symbol) {
+			continue
+		}
+		if err := stopInstrument(env, symbol); err == nil {
+			env.logger.Infof("Stopped recording %s, which no longer matches the discovery", symbol)
+		}
+	}
+	return current
+}
@@ -0,0 +1,106 @@
+package gobinapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestDiscoverSymbols(t *testing.T) {
+	infos := []SymbolInfo{
+		{Symbol: "ETHUSDT", Status: "TRADING", QuoteAsset: "USDT"},
+		{Symbol: "BTCUSDT", Status: "TRADING", QuoteAsset: "USDT"},
+		{Symbol: "LUNAUSDT", Status: "BREAK", QuoteAsset: "USDT"},
+		{Symbol: "DOGEUSDT", Status: "TRADING", QuoteAsset: "USDT"},
+		{Symbol: "ETHBTC", Status: "TRADING", QuoteAsset: "BTC"},
+		{Symbol: "BTCUSDC", Status: "TRADING", QuoteAsset: "USDC"},
+	}
+	for name, tc := range map[string]struct {
+		d    DiscoverConfig
+		want []string
+	}{
+		"pattern":        {DiscoverConfig{Symbols: []string{"*USDT"}}, []string{"BTCUSDT", "DOGEUSDT", "ETHUSDT"}},
+		"two patterns":   {DiscoverConfig{Symbols: []string{"BTC*", "ETH*"}}, []string{"BTCUSDC", "BTCUSDT", "ETHBTC", "ETHUSDT"}},
+		"quote asset":    {DiscoverConfig{QuoteAsset: "BTC"}, []string{"ETHBTC"}},
+		"both":           {DiscoverConfig{Symbols: []string{"BTC*"}, QuoteAsset: "USDC"}, []string{"BTCUSDC"}},
+		"top by volume":  {DiscoverConfig{QuoteAsset: "USDT", Top: 2}, []string{"BTCUSDT", "DOGEUSDT"}},
+		"top above size": {DiscoverConfig{QuoteAsset: "USDT", Top: 10}, []string{"BTCUSDT", "DOGEUSDT", "ETHUSDT"}},
+	} {
+		volumes := map[string]float64{"BTCUSDT": 3e9, "DOGEUSDT": 2e9, "ETHUSDT": 1e9, "LUNAUSDT": 5e9}
+		if got := DiscoverSymbols(infos, tc.d, volumes); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, got)
+		}
+	}
+}
+
+func TestFetchQuoteVolumes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fapi/v1/ticker/24hr" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `[{"symbol":"BTCUSDT","volume":"10","quoteVolume":"1000000.5"},{"symbol":"ETHUSDT","quoteVolume":"20"}]`)
+	}))
+	defer srv.Close()
+	old := FuturesRESTBaseURL
+	FuturesRESTBaseURL = srv.URL
+	defer func() { FuturesRESTBaseURL = old }()
+
+	volumes, err := FetchQuoteVolumes(context.Background(), http.DefaultClient, MarketUSDM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 2 || volumes["BTCUSDT"] != 1000000.5 || volumes["ETHUSDT"] != 20 {
+		t.Errorf("unexpected volumes %v", volumes)
+	}
+}
+
+func TestRun_RecordsDiscoveredInstruments(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetExchangeInfo(mockbinance.ExchangeInfoMessage(map[string]string{
+		"DISCAUSDT": "TRADING", "DISCBUSDT": "TRADING", "DISCCUSDT": "BREAK", "OTHERUSDT": "TRADING",
+	}))
+	t.Chdir(t.TempDir())
+
+	cfg := DefaultConfig()
+	cfg.Instruments = nil
+	cfg.Discover = &DiscoverConfig{Symbols: []string{"DISC*"}, Streams: []string{StreamTrade}}
+	cfg.Logger = NewLogger(io.Discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var recorded []string
+		for _, s := range Introspect().Recorders {
+			if strings.HasPrefix(s.Instrument, "DISC") {
+				if s.DataType != "trade" {
+					t.Errorf("expected only the discovery's streams, got %s of %s", s.DataType, s.Instrument)
+				}
+				recorded = append(recorded, s.Instrument)
+			}
+		}
+		slices.Sort(recorded)
+		if slices.Equal(recorded, []string{"DISCAUSDT", "DISCBUSDT"}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for the discovered instruments, recording %v", recorded)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+}
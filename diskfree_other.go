@@ -0,0 +1,10 @@
+//go:build !unix
+
+package gobinapi
+
+import "errors"
+
+// freeDiskSpace is not supported on this platform.
+func freeDiskSpace(dir string) (int64, error) {
+	return 0, errors.New("free disk space is not supported on this platform")
+}
@@ -0,0 +1,130 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+func init() {
+	DefaultMetrics.Describe("binance_disk_degraded", "gauge", "1 while recording is degraded for lack of disk space, 0 otherwise.")
+}
+
+// DefaultShedDataTypes are the data types a DiskGuard stops recording unless configured otherwise: full depth and
+// the raw archive, which take most of the space, while trades and best prices carry on.
+var DefaultShedDataTypes = []string{"orderBookDiff", "snapshot", "raw"}
+
+// diskResumeFactor is how far above the threshold free space must rise before a degraded recorder resumes, so it
+// does not flap around the threshold.
+const diskResumeFactor = 1.25
+
+// DiskGuardConfig configures degrading recording gracefully when the output volume runs out of space, instead of
+// failing with parquet write errors.
+type DiskGuardConfig struct {
+	// MinFree is the number of bytes available below which recording is degraded. It resumes once a quarter more is
+	// available again.
+	MinFree int64 `json:"min_free" yaml:"min_free"`
+	// Shed lists the data types (e.g. "orderBookDiff") not recorded while degraded (default DefaultShedDataTypes).
+	Shed []string `json:"shed,omitempty" yaml:"shed"`
+	// Interval is how often free space is checked (default 10s).
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval"`
+}
+
+// Validate checks the settings.
+func (c DiskGuardConfig) Validate() error {
+	if c.MinFree <= 0 {
+		return errors.New("min_free must be positive")
+	}
+	if c.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	for _, dataType := range c.Shed {
+		if !isRecordingDataType(dataType) {
+			return fmt.Errorf("unknown data type %q to shed", dataType)
+		}
+	}
+	return nil
+}
+
+// DiskGuard watches the free space of the output directory's file system and degrades recording while it is low:
+// recorders of the shed data types then discard their records (counted as drops, see RecordDrop) rather than
+// writing them. Entering and leaving the degraded mode raises an AlertLowDisk alert.
+type DiskGuard struct {
+	dir      string
+	config   DiskGuardConfig
+	logger   LoggerInterface
+	degraded atomic.Bool
+	// free returns the bytes available under dir; tests replace it
+	free func(dir string) (int64, error)
+}
+
+// NewDiskGuard creates a guard for the file system holding dir.
+func NewDiskGuard(dir string, config DiskGuardConfig, logger LoggerInterface) *DiskGuard {
+	if dir == "" {
+		dir = "."
+	}
+	if config.Shed == nil {
+		config.Shed = DefaultShedDataTypes
+	}
+	if config.Interval == 0 {
+		config.Interval = 10 * time.Second
+	}
+	return &DiskGuard{dir: dir, config: config, logger: logger, free: freeDiskSpace}
+}
+
+// Run checks the free space every interval until ctx is cancelled.
+func (g *DiskGuard) Run(ctx context.Context) error {
+	for {
+		free, err := g.free(g.dir)
+		if err != nil {
+			g.logger.Errorf("Failed to check the free disk space of %s: %v", g.dir, err)
+		} else {
+			g.Check(free)
+		}
+		if err := sleepContext(ctx, g.config.Interval); err != nil {
+			return nil
+		}
+	}
+}
+
+// Check updates the mode for free bytes available, returning whether it changed.
+func (g *DiskGuard) Check(free int64) bool {
+	shed := strings.Join(g.config.Shed, ", ")
+	if !g.degraded.Load() && free < g.config.MinFree {
+		g.degraded.Store(true)
+		DefaultMetrics.Set("binance_disk_degraded", nil, 1)
+		message := fmt.Sprintf("%s has %d MiB free, below %d MiB: stopped recording %s", g.dir, free>>20, g.config.MinFree>>20, shed)
+		g.logger.Errorf("%s", message)
+		DefaultAlerts.Raise(Alert{Kind: AlertLowDisk, Message: message})
+		return true
+	}
+	if g.degraded.Load() && float64(free) >= float64(g.config.MinFree)*diskResumeFactor {
+		g.degraded.Store(false)
+		DefaultMetrics.Set("binance_disk_degraded", nil, 0)
+		message := fmt.Sprintf("%s has %d MiB free again: resumed recording %s", g.dir, free>>20, shed)
+		g.logger.Infof("%s", message)
+		DefaultAlerts.Raise(Alert{Kind: AlertLowDisk, Message: message})
+		return true
+	}
+	return false
+}
+
+// Degraded reports whether recording is degraded.
+func (g *DiskGuard) Degraded() bool {
+	return g.degraded.Load()
+}
+
+// Sheds reports whether records of dataType are currently discarded.
+func (g *DiskGuard) Sheds(dataType string) bool {
+	return g.degraded.Load() && slices.Contains(g.config.Shed, dataType)
+}
+
+// SetDiskGuard makes the recorder discard its records, counting them as drops, while guard sheds its data type. It
+// must be called before the first Write.
+func (r *Recorder[T]) SetDiskGuard(guard *DiskGuard) {
+	r.diskGuard = guard
+}
@@ -0,0 +1,73 @@
+package gobinapi
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDiskGuard_ShedsDataTypesWhileLow checks that the guard degrades below the threshold, stays degraded until free
+// space recovers past the resume margin, and that a shed recorder drops its records while others keep writing.
+func TestDiskGuard_ShedsDataTypesWhileLow(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	guard := NewDiskGuard("", DiskGuardConfig{MinFree: 1000}, &FakeLogger{})
+	diffs, err := NewRecorder[OrderBookDiff]("BTCUSDT", "orderBookDiff", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	trades, err := NewRecorder[Trade]("BTCUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	diffs.SetDiskGuard(guard)
+	trades.SetDiskGuard(guard)
+	dropped := func() float64 {
+		return DefaultMetrics.Value("binance_messages_dropped_total", Labels{"stream": "BTCUSDT_orderBookDiff"})
+	}
+	before := dropped()
+
+	write := func(id int64) {
+		t.Helper()
+		if err := diffs.Write(OrderBookDiff{FirstUpdateID: id, FinalUpdateID: id}); err != nil {
+			t.Fatalf("failed to write diff: %v", err)
+		}
+		if err := trades.Write(Trade{TradeID: id, Price: "1", Quantity: "1"}); err != nil {
+			t.Fatalf("failed to write trade: %v", err)
+		}
+	}
+	write(1)
+	for _, step := range []struct {
+		free     int64
+		changed  bool
+		degraded bool
+	}{
+		{2000, false, false},
+		{999, true, true},
+		{1200, false, true},
+		{1250, true, false},
+	} {
+		if changed := guard.Check(step.free); changed != step.changed || guard.Degraded() != step.degraded {
+			t.Errorf("%d bytes free: expected changed %v and degraded %v, got %v and %v", step.free, step.changed, step.degraded, changed, guard.Degraded())
+		}
+		if step.free == 999 {
+			write(2)
+		}
+	}
+	write(3)
+	if err := diffs.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+	if err := trades.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	if got, ok := parquetRowCount[OrderBookDiff](diffs.filePath); !ok || got != 2 {
+		t.Errorf("expected the diff written while degraded to be dropped, got %d rows", got)
+	}
+	if got, ok := parquetRowCount[Trade](trades.filePath); !ok || got != 3 {
+		t.Errorf("expected every trade to be recorded, got %d rows", got)
+	}
+	if got := dropped() - before; got != 1 {
+		t.Errorf("expected 1 dropped diff, got %v", got)
+	}
+}
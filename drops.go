@@ -0,0 +1,116 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DropStats describes the messages of one stream discarded since the last journal entry, so data consumers can
+// see exactly what was lost and when.
+type DropStats struct {
+	Stream string
+	Count  int64
+	// FirstAt and LastAt are when the first and last drop in the window happened.
+	FirstAt time.Time
+	LastAt  time.Time
+	// MinID and MaxID bound the IDs (see RecordKey) of the dropped messages that carry one. HasIDs is false when
+	// none did, e.g. when a spill file became unreadable and its content was lost unseen.
+	MinID  int64
+	MaxID  int64
+	HasIDs bool
+}
+
+var dropStats = struct {
+	mu      sync.Mutex
+	pending map[string]*DropStats
+}{pending: make(map[string]*DropStats)}
+
+func init() {
+	DefaultMetrics.Describe("binance_messages_dropped_total", "counter", "Messages discarded by an overflow or drop policy, per stream.")
+}
+
+// RecordDrop counts a message discarded on stream, tracking its ID for the next journal entry.
+func RecordDrop(stream string, record interface{}) {
+	id, err := RecordKey(record)
+	recordDrops(stream, 1, id, err == nil)
+}
+
+// RecordDropCount counts n messages discarded on stream whose content is unknown.
+func RecordDropCount(stream string, n int64) {
+	recordDrops(stream, n, 0, false)
+}
+
+func recordDrops(stream string, n int64, id int64, hasID bool) {
+	if n <= 0 {
+		return
+	}
+	now := NowFunc().UTC()
+	DefaultMetrics.Add("binance_messages_dropped_total", Labels{"stream": stream}, float64(n))
+
+	dropStats.mu.Lock()
+	defer dropStats.mu.Unlock()
+	s, ok := dropStats.pending[stream]
+	if !ok {
+		s = &DropStats{Stream: stream, FirstAt: now}
+		dropStats.pending[stream] = s
+	}
+	s.Count += n
+	s.LastAt = now
+	if hasID {
+		if !s.HasIDs || id < s.MinID {
+			s.MinID = id
+		}
+		if !s.HasIDs || id > s.MaxID {
+			s.MaxID = id
+		}
+		s.HasIDs = true
+	}
+}
+
+// TakeDrops returns the drops recorded since the previous call, sorted by stream, and starts a new window.
+func TakeDrops() []DropStats {
+	dropStats.mu.Lock()
+	defer dropStats.mu.Unlock()
+	stats := make([]DropStats, 0, len(dropStats.pending))
+	for _, s := range dropStats.pending {
+		stats = append(stats, *s)
+	}
+	dropStats.pending = make(map[string]*DropStats)
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Stream < stats[j].Stream })
+	return stats
+}
+
+// FormatDropEvent is a pure function returning the journal line for one window of drops.
+func FormatDropEvent(s DropStats) string {
+	msg := fmt.Sprintf("Dropped %d messages on %s between %s and %s", s.Count, s.Stream,
+		s.FirstAt.Format(time.RFC3339Nano), s.LastAt.Format(time.RFC3339Nano))
+	if s.HasIDs {
+		msg += fmt.Sprintf(" (IDs %d-%d)", s.MinID, s.MaxID)
+	}
+	return msg
+}
+
+// RunDropJournal journals the drops of every stream once per interval until ctx is cancelled, and once more on
+// the way out so nothing recorded before shutdown goes unreported.
+func RunDropJournal(ctx context.Context, interval time.Duration, logger LoggerInterface) {
+	ticker := DefaultClock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			journalDrops(logger)
+			return
+		case <-ticker.C():
+			journalDrops(logger)
+		}
+	}
+}
+
+func journalDrops(logger LoggerInterface) {
+	for _, s := range TakeDrops() {
+		logger.Errorf("%s", FormatDropEvent(s))
+	}
+}
@@ -0,0 +1,92 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger captures journaled lines for assertions.
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) error {
+	return l.Errorf(format, args...)
+}
+
+func TestRecordDrop_TracksCountAndIDRange(t *testing.T) {
+	TakeDrops()
+	base := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	now := base
+	origNow := NowFunc
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = origNow }()
+
+	before := DefaultMetrics.Value("binance_messages_dropped_total", Labels{"stream": "DROPUSDT_trade"})
+	RecordDrop("DROPUSDT_trade", Trade{TradeID: 42})
+	now = base.Add(time.Second)
+	RecordDrop("DROPUSDT_trade", Trade{TradeID: 40})
+	RecordDropCount("DROPUSDT_trade", 3)
+	RecordDropCount("DROPUSDT_aggTrade", 2)
+
+	if got := DefaultMetrics.Value("binance_messages_dropped_total", Labels{"stream": "DROPUSDT_trade"}) - before; got != 5 {
+		t.Errorf("expected the counter to grow by 5, got %v", got)
+	}
+
+	stats := TakeDrops()
+	if len(stats) != 2 {
+		t.Fatalf("expected drops on two streams, got %+v", stats)
+	}
+	agg, trade := stats[0], stats[1]
+	if agg.Stream != "DROPUSDT_aggTrade" || agg.Count != 2 || agg.HasIDs {
+		t.Errorf("unexpected aggTrade drops: %+v", agg)
+	}
+	want := DropStats{Stream: "DROPUSDT_trade", Count: 5, FirstAt: base, LastAt: base.Add(time.Second), MinID: 40, MaxID: 42, HasIDs: true}
+	if trade != want {
+		t.Errorf("expected %+v, got %+v", want, trade)
+	}
+	if left := TakeDrops(); len(left) != 0 {
+		t.Errorf("expected TakeDrops to start a new window, got %+v", left)
+	}
+}
+
+func TestFormatDropEvent(t *testing.T) {
+	at := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	got := FormatDropEvent(DropStats{Stream: "BTCUSDT_trade", Count: 3, FirstAt: at, LastAt: at, MinID: 7, MaxID: 9, HasIDs: true})
+	want := "Dropped 3 messages on BTCUSDT_trade between 2025-02-19T12:00:00Z and 2025-02-19T12:00:00Z (IDs 7-9)"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRunDropJournal_JournalsOnShutdown(t *testing.T) {
+	TakeDrops()
+	logger := &recordingLogger{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunDropJournal(ctx, time.Hour, logger)
+		close(done)
+	}()
+	RecordDrop("DROPUSDT_bestPrice", BestPrice{UpdateID: 5})
+	cancel()
+	<-done
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.lines) != 1 || !strings.Contains(logger.lines[0], "DROPUSDT_bestPrice") || !strings.Contains(logger.lines[0], "IDs 5-5") {
+		t.Errorf("unexpected journal: %q", logger.lines)
+	}
+}
@@ -0,0 +1,175 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// SymbolStatusTrading is the exchange info status of a symbol that is open for trading.
+const SymbolStatusTrading = "TRADING"
+
+// Request weights of the exchange info endpoints.
+const (
+	exchangeInfoWeight        = 20
+	futuresExchangeInfoWeight = 1
+)
+
+// exchangeInfoResponse is the part of the exchange info response that is recorded.
+type exchangeInfoResponse struct {
+	Symbols []struct {
+		Symbol     string            `json:"symbol"`
+		Status     string            `json:"status"`
+		BaseAsset  string            `json:"baseAsset"`
+		QuoteAsset string            `json:"quoteAsset"`
+		Filters    []json.RawMessage `json:"filters"`
+	} `json:"symbols"`
+}
+
+// symbolFilter holds the fields of the filters SymbolInfo picks out. Spot calls the minimum notional minNotional in
+// both its MIN_NOTIONAL and NOTIONAL filters, futures calls it notional.
+type symbolFilter struct {
+	FilterType  string `json:"filterType"`
+	TickSize    string `json:"tickSize"`
+	StepSize    string `json:"stepSize"`
+	MinQty      string `json:"minQty"`
+	MaxQty      string `json:"maxQty"`
+	MinNotional string `json:"minNotional"`
+	Notional    string `json:"notional"`
+}
+
+// parseExchangeInfo is a pure function decoding an exchange info response into one SymbolInfo per symbol, stamped
+// with fetchTime in milliseconds.
+func parseExchangeInfo(data []byte, fetchTime int64) ([]SymbolInfo, error) {
+	var resp exchangeInfoResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse exchange info: %w", err)
+	}
+	infos := make([]SymbolInfo, len(resp.Symbols))
+	for i, s := range resp.Symbols {
+		info := SymbolInfo{Symbol: s.Symbol, Status: s.Status, BaseAsset: s.BaseAsset, QuoteAsset: s.QuoteAsset, FetchTime: fetchTime}
+		for _, raw := range s.Filters {
+			var f symbolFilter
+			if err := json.Unmarshal(raw, &f); err != nil {
+				return nil, fmt.Errorf("malformed filter of %s: %w", s.Symbol, err)
+			}
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				info.TickSize = f.TickSize
+			case "LOT_SIZE":
+				info.StepSize, info.MinQty, info.MaxQty = f.StepSize, f.MinQty, f.MaxQty
+			case "MIN_NOTIONAL", "NOTIONAL":
+				info.MinNotional = f.MinNotional
+				if info.MinNotional == "" {
+					info.MinNotional = f.Notional
+				}
+			}
+		}
+		filters, err := json.Marshal(s.Filters)
+		if err != nil {
+			return nil, err
+		}
+		info.Filters = string(filters)
+		infos[i] = info
+	}
+	return infos, nil
+}
+
+// FetchExchangeInfo fetches the exchange info of every symbol of market (MarketSpot or MarketUSDM), waiting for
+// its request weight in DefaultWeightTracker.
+func FetchExchangeInfo(ctx context.Context, client *http.Client, market string) ([]SymbolInfo, error) {
+	url, weight := RESTBaseURL+"/api/v3/exchangeInfo", exchangeInfoWeight
+	if market == MarketUSDM {
+		url, weight = FuturesRESTBaseURL+"/fapi/v1/exchangeInfo", futuresExchangeInfoWeight
+	}
+	if err := DefaultWeightTracker.Wait(ctx, weight); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch exchange info: %w", err)
+	}
+	defer resp.Body.Close()
+	DefaultWeightTracker.ObserveResponse(resp, NowFunc())
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange info request returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exchange info: %w", err)
+	}
+	return parseExchangeInfo(data, NowFunc().UnixMilli())
+}
+
+// ValidateSymbols is a pure function checking that every symbol is listed in infos with status TRADING. The error
+// names every symbol that is not.
+func ValidateSymbols(infos []SymbolInfo, symbols []string) error {
+	var errs []error
+	for _, symbol := range symbols {
+		i := slices.IndexFunc(infos, func(info SymbolInfo) bool { return info.Symbol == symbol })
+		switch {
+		case i < 0:
+			errs = append(errs, fmt.Errorf("symbol %s is not listed on the exchange", symbol))
+		case infos[i].Status != SymbolStatusTrading:
+			errs = append(errs, fmt.Errorf("symbol %s is %s, not %s", symbol, infos[i].Status, SymbolStatusTrading))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RecordExchangeInfo writes the SymbolInfo of each of symbols to its "exchangeInfo" file under layout for the UTC
+// day of its FetchTime, and returns the files written. Days that already have a file, e.g. after a restart, are
+// left as they are; symbols missing from infos are skipped.
+func RecordExchangeInfo(layout FileLayout, infos []SymbolInfo, symbols []string) ([]string, error) {
+	var files []string
+	for _, info := range infos {
+		if !slices.Contains(symbols, info.Symbol) {
+			continue
+		}
+		fetched := time.UnixMilli(info.FetchTime).UTC()
+		day := fetched.Truncate(24 * time.Hour)
+		if FileExists(layout.FilePath("exchangeInfo", info.Symbol, day, 0)) {
+			continue
+		}
+		filePath, err := writeDayPart(layout, "exchangeInfo", info.Symbol, day, []SymbolInfo{info}, fetched, fetched)
+		if err != nil {
+			return files, err
+		}
+		files = append(files, filePath)
+	}
+	return files, nil
+}
+
+// RunExchangeInfoRecorder fetches the exchange info of market shortly after every UTC midnight and records it for
+// the symbols instruments returns at the time, until ctx is cancelled. Failures are logged and retried after
+// retryDelay, so a day still gets its file.
+func RunExchangeInfoRecorder(ctx context.Context, client *http.Client, market string, layout FileLayout, instruments func() []string, retryDelay time.Duration, logger LoggerInterface) {
+	next := NowFunc().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	for {
+		if err := sleepContext(ctx, next.Sub(NowFunc())); err != nil {
+			return
+		}
+		infos, err := FetchExchangeInfo(ctx, client, market)
+		if err == nil {
+			_, err = RecordExchangeInfo(layout, infos, instruments())
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Errorf("Failed to record the exchange info: %v; retrying in %s", err, retryDelay)
+			next = NowFunc().Add(retryDelay)
+			continue
+		}
+		next = NowFunc().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	}
+}
@@ -0,0 +1,121 @@
+package gobinapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestParseExchangeInfo(t *testing.T) {
+	data := `{"symbols":[
+{"symbol":"BTCUSDT","status":"TRADING","baseAsset":"BTC","quoteAsset":"USDT","filters":[
+ {"filterType":"PRICE_FILTER","minPrice":"0.01","maxPrice":"1000000.00","tickSize":"0.01"},
+ {"filterType":"LOT_SIZE","minQty":"0.00001","maxQty":"9000.00000","stepSize":"0.00001"},
+ {"filterType":"NOTIONAL","minNotional":"5.00","applyMinToMarket":true}]},
+{"symbol":"ETHUSDT","status":"TRADING","baseAsset":"ETH","quoteAsset":"USDT","filters":[
+ {"filterType":"PRICE_FILTER","tickSize":"0.01"},
+ {"filterType":"MIN_NOTIONAL","notional":"20"}]}]}`
+	infos, err := parseExchangeInfo([]byte(data), 1700000000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 symbols, got %d", len(infos))
+	}
+	btc := infos[0]
+	if btc.Symbol != "BTCUSDT" || btc.BaseAsset != "BTC" || btc.TickSize != "0.01" || btc.StepSize != "0.00001" ||
+		btc.MinQty != "0.00001" || btc.MaxQty != "9000.00000" || btc.MinNotional != "5.00" || btc.FetchTime != 1700000000000 {
+		t.Errorf("unexpected spot symbol %+v", btc)
+	}
+	if !strings.Contains(btc.Filters, `"applyMinToMarket":true`) {
+		t.Errorf("expected the filters to be kept whole, got %s", btc.Filters)
+	}
+	// Futures name the minimum notional "notional"
+	if infos[1].MinNotional != "20" {
+		t.Errorf("unexpected futures minimum notional %q", infos[1].MinNotional)
+	}
+	if _, err := parseExchangeInfo([]byte(`{"symbols":[{"symbol":"X","filters":[1]}]}`), 0); err == nil {
+		t.Error("expected a malformed filter to be rejected")
+	}
+}
+
+func TestValidateSymbols(t *testing.T) {
+	infos := []SymbolInfo{{Symbol: "BTCUSDT", Status: "TRADING"}, {Symbol: "LUNAUSDT", Status: "BREAK"}}
+	if err := ValidateSymbols(infos, []string{"BTCUSDT"}); err != nil {
+		t.Errorf("expected a trading symbol to pass, got %v", err)
+	}
+	err := ValidateSymbols(infos, []string{"BTCUSDT", "LUNAUSDT", "BTCUSTD"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, msg := range []string{"LUNAUSDT is BREAK, not TRADING", "BTCUSTD is not listed"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in %v", msg, err)
+		}
+	}
+}
+
+func TestRecordExchangeInfo_WritesOncePerDay(t *testing.T) {
+	layout := FileLayout{Root: t.TempDir()}
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	infos := []SymbolInfo{
+		{Symbol: "BTCUSDT", Status: "TRADING", TickSize: "0.01", FetchTime: day.Add(time.Minute).UnixMilli()},
+		{Symbol: "ETHUSDT", Status: "TRADING", TickSize: "0.01", FetchTime: day.Add(time.Minute).UnixMilli()},
+	}
+	files, err := RecordExchangeInfo(layout, infos, []string{"BTCUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0] != layout.FilePath("exchangeInfo", "BTCUSDT", day, 0) {
+		t.Fatalf("expected only the recorded symbol's file, got %v", files)
+	}
+
+	// A restart later the same day leaves the day's file as it is
+	infos[0].TickSize, infos[0].FetchTime = "0.1", day.Add(time.Hour).UnixMilli()
+	if files, err := RecordExchangeInfo(layout, infos, []string{"BTCUSDT"}); err != nil || len(files) != 0 {
+		t.Fatalf("expected nothing to be written again, got %v, %v", files, err)
+	}
+	records, err := Query{Dir: layout.Root, Symbol: "BTCUSDT", DataType: "exchangeInfo", From: day, To: day.Add(24 * time.Hour)}.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].(SymbolInfo).TickSize != "0.01" {
+		t.Errorf("expected the first fetch of the day through Query, got %+v", records)
+	}
+}
+
+func TestFetchExchangeInfo_FromMockServer(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetExchangeInfo(mockbinance.ExchangeInfoMessage(map[string]string{"BTCUSDT": "TRADING"}))
+	infos, err := FetchExchangeInfo(context.Background(), http.DefaultClient, MarketSpot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].StepSize != "0.00001" || infos[0].MinNotional != "5.00" {
+		t.Errorf("unexpected symbols %+v", infos)
+	}
+}
+
+func TestRun_RejectsSymbolsThatAreNotTrading(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetExchangeInfo(mockbinance.ExchangeInfoMessage(map[string]string{"BTCUSDT": "TRADING", "HALTUSDT": "HALT"}))
+	t.Chdir(t.TempDir())
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"BTCUSDT", "HALTUSDT"}
+	cfg.Logger = NewLogger(io.Discard)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := Run(ctx, cfg)
+	if err == nil || !strings.Contains(err.Error(), "symbol HALTUSDT is HALT, not TRADING") {
+		t.Fatalf("expected the halted symbol to be rejected, got %v", err)
+	}
+	if FileExists(BuildFileName("exchangeInfo", "BTCUSDT", NowFunc())) {
+		t.Error("expected nothing to be recorded when the config is rejected")
+	}
+}
@@ -0,0 +1,183 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStreamEndpoints lists the public market data WebSocket bases, in order of preference.
+var DefaultStreamEndpoints = []string{
+	"wss://data-stream.binance.vision:9443",
+	"wss://stream.binance.com:9443",
+	"wss://stream.binance.com:443",
+}
+
+// MinHealthySession is how long a WebSocket session must last to count as healthy. Shorter sessions, including
+// failed dials, count towards failing over to the next endpoint.
+var MinHealthySession = 30 * time.Second
+
+// ReconnectDelay is how long ListenWithFailover waits before reconnecting after a session ends.
+var ReconnectDelay = time.Second
+
+// StreamEndpoints rotates through a list of WebSocket or REST base URLs, moving to the next one after a number of
+// consecutive failed sessions or requests on the current one. After the last endpoint it wraps around to the first.
+// With a prober set, endpoints failing their health probes are left right away and skipped.
+type StreamEndpoints struct {
+	bases       []string
+	maxFailures int
+	prober      *EndpointProber
+
+	mu       sync.Mutex
+	current  int
+	failures int
+}
+
+// NewStreamEndpoints creates a rotation over bases that fails over after maxFailures consecutive failures.
+func NewStreamEndpoints(bases []string, maxFailures int) *StreamEndpoints {
+	return &StreamEndpoints{bases: append([]string(nil), bases...), maxFailures: maxFailures}
+}
+
+// SetProber makes the rotation avoid the endpoints prober finds unhealthy. It must be called before the endpoints
+// are used.
+func (e *StreamEndpoints) SetProber(prober *EndpointProber) {
+	e.prober = prober
+}
+
+// Current returns the endpoint to connect to. If the prober finds the current endpoint unhealthy while another is
+// healthy, the rotation moves on to that one first.
+func (e *StreamEndpoints) Current() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.prober != nil && !e.prober.Healthy(e.bases[e.current]) {
+		if next := e.nextHealthy(); e.prober.Healthy(e.bases[next]) {
+			e.current, e.failures = next, 0
+		}
+	}
+	return e.bases[e.current]
+}
+
+// nextHealthy returns the index of the first endpoint after the current one that the prober finds healthy, or of
+// the next one if there is no prober or none is healthy. It must be called with e.mu held.
+func (e *StreamEndpoints) nextHealthy() int {
+	for i := 1; e.prober != nil && i < len(e.bases); i++ {
+		if next := (e.current + i) % len(e.bases); e.prober.Healthy(e.bases[next]) {
+			return next
+		}
+	}
+	return (e.current + 1) % len(e.bases)
+}
+
+// ReportSuccess resets the failure count of the current endpoint.
+func (e *StreamEndpoints) ReportSuccess() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = 0
+}
+
+// ReportFailure counts a failed session on the current endpoint. If that makes maxFailures in a row, it moves on
+// to the next endpoint and returns true.
+func (e *StreamEndpoints) ReportFailure() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	if e.failures < e.maxFailures || len(e.bases) < 2 {
+		return false
+	}
+	e.failures = 0
+	e.current = e.nextHealthy()
+	return true
+}
+
+// ListenWithFailover keeps stream connected until ctx is cancelled: it runs listen against the current endpoint,
+// and whenever the session ends it reports the session's health to endpoints and reconnects after ReconnectDelay,
+// failing over to the next endpoint on repeated failures. Which endpoint served each session is recorded in the
+// stream's ConnStats and the binance_ws_endpoint_connects_total metric.
+func ListenWithFailover(ctx context.Context, stream string, endpoints *StreamEndpoints, listen func(ctx context.Context, base string) error, logger LoggerInterface) error {
+	for {
+		base := endpoints.Current()
+		start := NowFunc()
+		err := listen(ctx, base)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if NowFunc().Sub(start) >= MinHealthySession {
+			endpoints.ReportSuccess()
+			logger.Errorf("Stream %s on %s ended: %v; reconnecting", stream, base, err)
+		} else if endpoints.ReportFailure() {
+			logger.Errorf("Stream %s failed on %s: %v; failing over to %s", stream, base, err, endpoints.Current())
+		} else {
+			logger.Errorf("Stream %s failed on %s: %v; retrying", stream, base, err)
+		}
+
+		timer := DefaultClock.NewTimer(ReconnectDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
+
+// restFailoverTransport sends the requests for the primary REST base to the current endpoint of a rotation
+// instead, and reports every such request's outcome to it: transport errors, 5xx responses and, if maxLatency is
+// positive, responses slower than maxLatency count as failures. Requests for other hosts pass through untouched.
+type restFailoverTransport struct {
+	primary    string
+	endpoints  *StreamEndpoints
+	maxLatency time.Duration
+	next       http.RoundTripper
+	logger     LoggerInterface
+}
+
+func (t *restFailoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rawURL := req.URL.String()
+	rest, ok := strings.CutPrefix(rawURL, t.primary)
+	if !ok || rest != "" && rest[0] != '/' && rest[0] != '?' {
+		return t.next.RoundTrip(req)
+	}
+	base := t.endpoints.Current()
+	if base != t.primary {
+		u, err := url.Parse(base + rest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewrite %s for endpoint %s: %w", rawURL, base, err)
+		}
+		req = req.Clone(req.Context())
+		req.URL, req.Host = u, u.Host
+	}
+	start := NowFunc()
+	resp, err := t.next.RoundTrip(req)
+	latency := NowFunc().Sub(start)
+	var problem error
+	switch {
+	case err != nil:
+		problem = err
+	case resp.StatusCode >= 500:
+		problem = fmt.Errorf("status %d", resp.StatusCode)
+	case t.maxLatency > 0 && latency > t.maxLatency:
+		problem = fmt.Errorf("took %s, over the %s limit", latency, t.maxLatency)
+	}
+	if problem == nil {
+		t.endpoints.ReportSuccess()
+	} else if t.endpoints.ReportFailure() {
+		t.logger.Errorf("REST request to %s failed: %v; failing over to %s", base, problem, t.endpoints.Current())
+	}
+	return resp, err
+}
+
+// withRESTFailover returns a copy of client whose requests for primary go to the current endpoint of endpoints, see
+// restFailoverTransport.
+func withRESTFailover(client *http.Client, primary string, endpoints *StreamEndpoints, maxLatency time.Duration, logger LoggerInterface) *http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	failover := *client
+	failover.Transport = &restFailoverTransport{primary: primary, endpoints: endpoints, maxLatency: maxLatency, next: next, logger: logger}
+	return &failover
+}
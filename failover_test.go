@@ -0,0 +1,125 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamEndpoints_FailsOverAndWraps(t *testing.T) {
+	e := NewStreamEndpoints([]string{"wss://a", "wss://b"}, 2)
+	if e.ReportFailure() || e.Current() != "wss://a" {
+		t.Fatalf("expected to stay on the first endpoint after one failure, got %s", e.Current())
+	}
+	e.ReportSuccess()
+	if e.ReportFailure() {
+		t.Fatalf("expected a success to reset the failure count")
+	}
+	if !e.ReportFailure() || e.Current() != "wss://b" {
+		t.Fatalf("expected to fail over to the second endpoint, got %s", e.Current())
+	}
+	e.ReportFailure()
+	if !e.ReportFailure() || e.Current() != "wss://a" {
+		t.Fatalf("expected to wrap around to the first endpoint, got %s", e.Current())
+	}
+
+	single := NewStreamEndpoints([]string{"wss://only"}, 1)
+	if single.ReportFailure() || single.Current() != "wss://only" {
+		t.Errorf("expected a single endpoint never to fail over")
+	}
+}
+
+func TestListenWithFailover_SwitchesEndpointOnRepeatedFailures(t *testing.T) {
+	oldDelay := ReconnectDelay
+	ReconnectDelay = time.Millisecond
+	t.Cleanup(func() { ReconnectDelay = oldDelay })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	var bases []string
+	listen := func(ctx context.Context, base string) error {
+		mu.Lock()
+		bases = append(bases, base)
+		mu.Unlock()
+		if base == "wss://down" {
+			return errors.New("dial refused")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	done := make(chan error, 1)
+	endpoints := NewStreamEndpoints([]string{"wss://down", "wss://up"}, 3)
+	go func() { done <- ListenWithFailover(ctx, "trade", endpoints, listen, &FakeLogger{}) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(bases)
+		mu.Unlock()
+		if n >= 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for failover")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"wss://down", "wss://down", "wss://down", "wss://up"}
+	if len(bases) != len(want) {
+		t.Fatalf("expected sessions on %v, got %v", want, bases)
+	}
+	for i := range want {
+		if bases[i] != want[i] {
+			t.Errorf("session %d: expected %s, got %s", i, want[i], bases[i])
+		}
+	}
+}
+
+func TestRESTFailover_MovesRequestsToTheNextEndpoint(t *testing.T) {
+	var hits []string
+	handler := func(name string, status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits = append(hits, name+" "+r.URL.RequestURI())
+			w.WriteHeader(status)
+		})
+	}
+	primary := httptest.NewServer(handler("primary", http.StatusBadGateway))
+	defer primary.Close()
+	mirror := httptest.NewServer(handler("mirror", http.StatusOK))
+	defer mirror.Close()
+	other := httptest.NewServer(handler("other", http.StatusOK))
+	defer other.Close()
+
+	endpoints := NewStreamEndpoints([]string{primary.URL, mirror.URL}, 2)
+	client := withRESTFailover(http.DefaultClient, primary.URL, endpoints, 0, &FakeLogger{})
+	for _, u := range []string{primary.URL + "/api/v3/depth?symbol=BTCUSDT", primary.URL + "/api/v3/depth?symbol=BTCUSDT",
+		primary.URL + "/api/v3/depth?symbol=ETHUSDT", other.URL + "/write"} {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatalf("request to %s failed: %v", u, err)
+		}
+		resp.Body.Close()
+	}
+	want := []string{"primary /api/v3/depth?symbol=BTCUSDT", "primary /api/v3/depth?symbol=BTCUSDT",
+		"mirror /api/v3/depth?symbol=ETHUSDT", "other /write"}
+	if fmt.Sprint(hits) != fmt.Sprint(want) {
+		t.Errorf("expected requests %v, got %v", want, hits)
+	}
+	if http.DefaultClient.Transport != nil {
+		t.Error("expected the original client to be left alone")
+	}
+}
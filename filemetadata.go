@@ -0,0 +1,115 @@
+package gobinapi
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// SchemaVersion is the version of the record types' parquet schemas, written into every file's footer. It is
+// increased whenever a column of a recorded type is added, removed, renamed or changes its meaning, so loaders can
+// tell files of different layouts apart.
+const SchemaVersion = "1"
+
+// Keys of the key-value metadata in the footer of every recorded parquet file, see ReadFileMetadata.
+const (
+	MetadataRecorderVersion = "gobinapi.recorder_version"
+	MetadataSchemaVersion   = "gobinapi.schema_version"
+	MetadataSymbol          = "gobinapi.symbol"
+	MetadataStream          = "gobinapi.stream"
+	MetadataUpdateSpeed     = "gobinapi.update_speed"
+	MetadataHost            = "gobinapi.host"
+	MetadataRunID           = "gobinapi.run_id"
+)
+
+// UpdateSpeedRealtime is the update speed of streams that push every event as it happens.
+const UpdateSpeedRealtime = "realtime"
+
+// FileMetadata returns the footer metadata describing the run, for Recorder.SetMetadata.
+func (s *SessionInfo) FileMetadata() map[string]string {
+	return map[string]string{
+		MetadataRecorderVersion: s.GitVersion,
+		MetadataHost:            s.Host,
+		MetadataRunID:           s.RunID,
+	}
+}
+
+// UpdateSpeed returns how often the data of dataType (as recorded, e.g. "bestPrice" or "orderBookDiff") is updated:
+// UpdateSpeedRealtime for event streams, the subscribed interval of interval streams and the configured interval of
+// polled and derived data. It is empty for the raw archive, which holds every stream.
+func (cfg Config) UpdateSpeed(dataType string) string {
+	switch dataType {
+	case "trade", "aggTrade", "bestPrice", OptionTradeDataType, ExecutionReportDataType, AccountPositionDataType, BalanceUpdateDataType:
+		return UpdateSpeedRealtime
+	case "orderBookDiff":
+		// Subscribed as <symbol>@depth, which Binance pushes every second
+		return "1000ms"
+	case "markPrice", "ticker", "ticker_1h", "ticker_4h", "ticker_1d", "avgPrice", OptionTickerDataType, OptionMarkPriceDataType:
+		return "1s"
+	case OptionOpenInterestDataType:
+		return "1m"
+	case "snapshot":
+		return cfg.SnapshotInterval.String()
+	case "snapshotTop":
+		return cfg.TopOfBookInterval.String()
+	case "bookTop":
+		return cfg.BookTopInterval.String()
+	}
+	return ""
+}
+
+// SetMetadata adds metadata, e.g. SessionInfo.FileMetadata, to the key-value metadata in the footer of the
+// recorder's files, which always holds its symbol, stream and SchemaVersion. It must be called before the first
+// Write.
+func (r *Recorder[T]) SetMetadata(metadata map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metadata = maps.Clone(metadata)
+}
+
+// footerMetadata returns the key-value metadata of the recorder's files.
+func (r *Recorder[T]) footerMetadata() map[string]string {
+	metadata := map[string]string{
+		MetadataSchemaVersion: SchemaVersion,
+		MetadataSymbol:        r.instrument,
+		MetadataStream:        r.dataType,
+	}
+	maps.Copy(metadata, r.metadata)
+	return metadata
+}
+
+// keyValueMetadata converts metadata to parquet footer entries, sorted by key so equal metadata gives equal footers.
+func keyValueMetadata(metadata map[string]string) []*parquet.KeyValue {
+	kvs := make([]*parquet.KeyValue, 0, len(metadata))
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		value := metadata[key]
+		kvs = append(kvs, &parquet.KeyValue{Key: key, Value: &value})
+	}
+	return kvs
+}
+
+// ReadFileMetadata returns the key-value metadata in the footer of the parquet file at filePath, e.g. the symbol
+// under MetadataSymbol. Files written before SchemaVersion was introduced have none.
+func ReadFileMetadata(filePath string) (map[string]string, error) {
+	fr, err := local.NewLocalFileReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parquet footer of %s: %w", filePath, err)
+	}
+	defer pr.ReadStop()
+	metadata := make(map[string]string, len(pr.Footer.KeyValueMetadata))
+	for _, kv := range pr.Footer.KeyValueMetadata {
+		if kv.Value != nil {
+			metadata[kv.Key] = *kv.Value
+		}
+	}
+	return metadata, nil
+}
@@ -0,0 +1,78 @@
+package gobinapi
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRecorder_WritesFooterMetadata checks that a recorded file's footer describes its symbol, stream, schema and the
+// run metadata it was given.
+func TestRecorder_WritesFooterMetadata(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	r, err := NewRecorder[Trade]("BTCUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	session := &SessionInfo{RunID: "run1", GitVersion: "abc123", Host: "recorder-1"}
+	metadata := session.FileMetadata()
+	metadata[MetadataUpdateSpeed] = Config{}.UpdateSpeed("trade")
+	r.SetMetadata(metadata)
+	if err := r.Write(Trade{TradeID: 1, Price: "100", Quantity: "1"}); err != nil {
+		t.Fatalf("failed to write trade: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	got, err := ReadFileMetadata(r.filePath)
+	if err != nil {
+		t.Fatalf("failed to read metadata: %v", err)
+	}
+	want := map[string]string{
+		MetadataRecorderVersion: "abc123",
+		MetadataSchemaVersion:   SchemaVersion,
+		MetadataSymbol:          "BTCUSDT",
+		MetadataStream:          "trade",
+		MetadataUpdateSpeed:     UpdateSpeedRealtime,
+		MetadataHost:            "recorder-1",
+		MetadataRunID:           "run1",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("expected %s=%q, got %q", key, value, got[key])
+		}
+	}
+}
+
+// TestWriteParquetFile_WritesVersions checks that files written in one go carry the recorder and schema version.
+func TestWriteParquetFile_WritesVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.parquet")
+	if err := WriteParquetFile(path, []Trade{{TradeID: 1}}); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	got, err := ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("failed to read metadata: %v", err)
+	}
+	if got[MetadataSchemaVersion] != SchemaVersion || got[MetadataRecorderVersion] != GitVersion() {
+		t.Errorf("unexpected metadata %v", got)
+	}
+}
+
+func TestConfig_UpdateSpeed(t *testing.T) {
+	cfg := Config{SnapshotInterval: time.Minute, BookTopInterval: 100 * time.Millisecond}
+	for dataType, want := range map[string]string{
+		"bestPrice":     UpdateSpeedRealtime,
+		"orderBookDiff": "1000ms",
+		"markPrice":     "1s",
+		"snapshot":      "1m0s",
+		"bookTop":       "100ms",
+		"raw":           "",
+	} {
+		if got := cfg.UpdateSpeed(dataType); got != want {
+			t.Errorf("expected update speed %q for %s, got %q", want, dataType, got)
+		}
+	}
+}
@@ -1,8 +1,9 @@
-package main
+package gobinapi
 
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -15,6 +16,79 @@ func BuildFileName(dataType string, instrument string, t time.Time) string {
 	return fmt.Sprintf("%s_%s_%s.parquet", instrument, dataType, utcDate)
 }
 
+// BuildPartFileName constructs the file name of an intra-day part file. Part 0 is the day's regular file as built
+// by BuildFileName; later parts append a zero-padded part number, e.g. "BTCUSDT_trade_2023-10-15_part002.parquet".
+func BuildPartFileName(dataType string, instrument string, t time.Time, part int) string {
+	if part == 0 {
+		return BuildFileName(dataType, instrument, t)
+	}
+	utcDate := t.UTC().Format("2006-01-02")
+	return fmt.Sprintf("%s_%s_%s_part%03d.parquet", instrument, dataType, utcDate, part)
+}
+
+// BuildPartIndexFileName constructs the name of the part index listing a day's part files, e.g.
+// "BTCUSDT_trade_2023-10-15.index.json".
+func BuildPartIndexFileName(dataType string, instrument string, t time.Time) string {
+	utcDate := t.UTC().Format("2006-01-02")
+	return fmt.Sprintf("%s_%s_%s.index.json", instrument, dataType, utcDate)
+}
+
+// FileLayout decides where recording files are written. The zero value writes flat file names (see BuildFileName)
+// into the working directory.
+type FileLayout struct {
+	// Root is the directory recordings are written under. Empty means the working directory.
+	Root string `json:"root,omitempty"`
+	// Hive writes each instrument and day into its own partition directory, with files named after the data type,
+	// e.g. "symbol=BTCUSDT/date=2024-05-01/trade.parquet", so query engines such as Spark or DuckDB can prune
+	// partitions by symbol and date.
+	Hive bool `json:"hive,omitempty"`
+}
+
+// Policies for a recording file that already exists when a Recorder starts a day, see DefaultExistingFilePolicy.
+const (
+	// ExistingFileFail refuses to record, so existing data is never mixed with a new recording.
+	ExistingFileFail = "fail"
+	// ExistingFileNewPart resumes the day in the next unused part file (see BuildPartFileName), e.g. after a
+	// restart in the middle of the day.
+	ExistingFileNewPart = "newPart"
+)
+
+// DefaultExistingFilePolicy is how NewRecorder, and recorders starting a new day, treat an existing file. Run sets
+// it from Config.OnExistingFile.
+var DefaultExistingFilePolicy = ExistingFileFail
+
+// DefaultFileLayout is the layout NewRecorder writes to. Run sets it from Config.OutputDir and
+// Config.HivePartitioning.
+var DefaultFileLayout FileLayout
+
+// Dir returns the directory holding the files of instrument for the UTC date of t.
+func (l FileLayout) Dir(instrument string, t time.Time) string {
+	if !l.Hive {
+		return l.Root
+	}
+	return filepath.Join(l.Root, "symbol="+instrument, "date="+t.UTC().Format("2006-01-02"))
+}
+
+// FilePath returns the path of a recording file; part 0 is the day's regular file (see BuildPartFileName).
+func (l FileLayout) FilePath(dataType string, instrument string, t time.Time, part int) string {
+	if !l.Hive {
+		return filepath.Join(l.Root, BuildPartFileName(dataType, instrument, t, part))
+	}
+	name := dataType + ".parquet"
+	if part > 0 {
+		name = fmt.Sprintf("%s_part%03d.parquet", dataType, part)
+	}
+	return filepath.Join(l.Dir(instrument, t), name)
+}
+
+// IndexPath returns the path of the part index of a data type's day (see BuildPartIndexFileName).
+func (l FileLayout) IndexPath(dataType string, instrument string, t time.Time) string {
+	if !l.Hive {
+		return filepath.Join(l.Root, BuildPartIndexFileName(dataType, instrument, t))
+	}
+	return filepath.Join(l.Dir(instrument, t), dataType+".index.json")
+}
+
 // FileExists checks if the specified file exists at filePath.
 // It returns true if the file exists, and false otherwise.
 // This function wraps the os.Stat call, providing an imperative shell for IO,
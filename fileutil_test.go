@@ -1,4 +1,4 @@
-package main
+package gobinapi
 
 import (
 	"os"
@@ -47,3 +47,26 @@ func TestFileExistsReturnsTrueForExistingFile(t *testing.T) {
 		t.Errorf("failed to remove temp file %q: %v", tempFileName, err)
 	}
 }
+
+func TestFileLayout_Paths(t *testing.T) {
+	day := time.Date(2024, time.May, 1, 23, 59, 0, 0, time.UTC)
+
+	flat := FileLayout{Root: "/data"}
+	if actual := flat.FilePath("trade", "BTCUSDT", day, 2); actual != "/data/BTCUSDT_trade_2024-05-01_part002.parquet" {
+		t.Errorf("unexpected flat path %s", actual)
+	}
+	if actual := (FileLayout{}).FilePath("trade", "BTCUSDT", day, 0); actual != BuildFileName("trade", "BTCUSDT", day) {
+		t.Errorf("expected the zero layout to keep flat names in the working directory, got %s", actual)
+	}
+
+	hive := FileLayout{Root: "/data", Hive: true}
+	for _, c := range []struct{ actual, expected string }{
+		{hive.FilePath("trade", "BTCUSDT", day, 0), "/data/symbol=BTCUSDT/date=2024-05-01/trade.parquet"},
+		{hive.FilePath("orderBookDiff", "BTCUSDT", day, 3), "/data/symbol=BTCUSDT/date=2024-05-01/orderBookDiff_part003.parquet"},
+		{hive.IndexPath("trade", "BTCUSDT", day), "/data/symbol=BTCUSDT/date=2024-05-01/trade.index.json"},
+	} {
+		if c.actual != c.expected {
+			t.Errorf("expected %s, got %s", c.expected, c.actual)
+		}
+	}
+}
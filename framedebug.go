@@ -0,0 +1,81 @@
+package gobinapi
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// FrameDebugger selects the WebSocket streams whose frames are written to the log as received, before they are
+// decoded, for troubleshooting the parsing of one stream without logging every frame of every stream. Streams are
+// named as subscribed, e.g. "btcusdt@depth". Checking a stream takes no lock, so every frame can be checked.
+type FrameDebugger struct {
+	mu      sync.Mutex
+	streams atomic.Pointer[map[string]bool]
+	logger  atomic.Pointer[LoggerInterface]
+}
+
+// DefaultFrameDebugger is checked by every WebSocket connection. Run sets its streams from Config.DebugStreams,
+// and the admin API switches them at runtime.
+var DefaultFrameDebugger = &FrameDebugger{}
+
+// SetLogger makes d write frames to logger instead of the standard library's log package.
+func (d *FrameDebugger) SetLogger(logger LoggerInterface) {
+	d.logger.Store(&logger)
+}
+
+// Set switches the logging of stream's frames on or off.
+func (d *FrameDebugger) Set(stream string, on bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	streams := make(map[string]bool)
+	if current := d.streams.Load(); current != nil {
+		for s := range *current {
+			streams[s] = true
+		}
+	}
+	if on {
+		streams[stream] = true
+	} else {
+		delete(streams, stream)
+	}
+	d.streams.Store(&streams)
+}
+
+// Reset switches the logging of every stream off.
+func (d *FrameDebugger) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.streams.Store(nil)
+}
+
+// Enabled reports whether the frames of stream are logged.
+func (d *FrameDebugger) Enabled(stream string) bool {
+	streams := d.streams.Load()
+	return streams != nil && (*streams)[stream]
+}
+
+// Streams returns the streams whose frames are logged, sorted.
+func (d *FrameDebugger) Streams() []string {
+	streams := []string{}
+	if current := d.streams.Load(); current != nil {
+		for s := range *current {
+			streams = append(streams, s)
+		}
+	}
+	sort.Strings(streams)
+	return streams
+}
+
+// Log writes msg, received on stream in session, to the log if the frames of stream are logged.
+func (d *FrameDebugger) Log(stream string, msg []byte, session WSSession) {
+	if !d.Enabled(stream) {
+		return
+	}
+	if logger := d.logger.Load(); logger != nil {
+		(*logger).Infof("Frame of %s (session %s): %s", stream, session.ID, msg)
+		return
+	}
+	log.Printf("Frame of %s (session %s): %s", stream, session.ID, msg)
+}
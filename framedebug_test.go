@@ -0,0 +1,114 @@
+package gobinapi
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+// lockedWriter is a bytes.Buffer safe to write from the recorder's goroutines while a test reads it.
+type lockedWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *lockedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// messageLogger collects the messages logged to it.
+type messageLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *messageLogger) Errorf(format string, args ...interface{}) error {
+	return l.Infof(format, args...)
+}
+
+func (l *messageLogger) Infof(format string, args ...interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func TestFrameDebugger_LogsOnlySelectedStreams(t *testing.T) {
+	d := &FrameDebugger{}
+	var logger messageLogger
+	d.SetLogger(&logger)
+	d.Set("btcusdt@depth", true)
+	d.Set("ethusdt@trade", true)
+	d.Set("ethusdt@trade", false)
+
+	session := WSSession{ID: "s1"}
+	d.Log("btcusdt@depth", []byte(`{"e":"depthUpdate"}`), session)
+	d.Log("btcusdt@trade", []byte(`{"e":"trade"}`), session)
+	d.Log("ethusdt@trade", []byte(`{"e":"trade"}`), session)
+	if len(logger.messages) != 1 || logger.messages[0] != `Frame of btcusdt@depth (session s1): {"e":"depthUpdate"}` {
+		t.Errorf("expected only the depth frame to be logged, got %q", logger.messages)
+	}
+	if streams := d.Streams(); !reflect.DeepEqual(streams, []string{"btcusdt@depth"}) {
+		t.Errorf("unexpected streams %v", streams)
+	}
+	d.Reset()
+	if d.Enabled("btcusdt@depth") || len(d.Streams()) != 0 {
+		t.Errorf("expected every stream to be off after Reset, got %v", d.Streams())
+	}
+}
+
+func TestRun_LogsFramesOfDebugStreams(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("dbgusdt@trade",
+		mockbinance.TradeMessage("DBGUSDT", 1, "1.0", "1"),
+		mockbinance.TradeMessage("DBGUSDT", 2, "1.1", "1"))
+	srv.SetStream("dbgusdt@aggTrade",
+		mockbinance.AggTradeMessage("DBGUSDT", 1, "1.0", "1"),
+		mockbinance.AggTradeMessage("DBGUSDT", 2, "1.1", "1"))
+	t.Chdir(t.TempDir())
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"DBGUSDT"}
+	cfg.Streams = map[string][]string{"DBGUSDT": {StreamTrade, StreamAggTrade}}
+	cfg.DebugStreams = map[string][]string{"DBGUSDT": {StreamTrade}}
+	var logs lockedWriter
+	cfg.Logger = NewLogger(&logs)
+	base, stop := startAdminRun(t, cfg)
+	defer stop()
+
+	waitForRows(t, "DBGUSDT", "trade", 2)
+	waitForRows(t, "DBGUSDT", "aggTrade", 2)
+	if n := strings.Count(logs.String(), "Frame of dbgusdt@trade"); n != 2 {
+		t.Errorf("expected both trade frames in the log, got %d:\n%s", n, logs.String())
+	}
+	if strings.Contains(logs.String(), "Frame of dbgusdt@aggTrade") {
+		t.Errorf("expected no aggregate trade frames in the log:\n%s", logs.String())
+	}
+
+	var streams []string
+	adminRequest(t, http.MethodPut, base+"/symbols/DBGUSDT/streams/aggTrade/debug", http.StatusOK, &streams)
+	if !reflect.DeepEqual(streams, []string{"dbgusdt@aggTrade", "dbgusdt@trade"}) {
+		t.Errorf("unexpected debugged streams %v", streams)
+	}
+	adminRequest(t, http.MethodDelete, base+"/symbols/DBGUSDT/streams/trade/debug", http.StatusOK, &streams)
+	adminRequest(t, http.MethodGet, base+"/debug", http.StatusOK, &streams)
+	if !reflect.DeepEqual(streams, []string{"dbgusdt@aggTrade"}) {
+		t.Errorf("unexpected debugged streams %v", streams)
+	}
+	adminRequest(t, http.MethodPut, base+"/symbols/DBGUSDT/streams/depth/debug", http.StatusConflict, nil)
+	adminRequest(t, http.MethodPut, base+"/symbols/NOPEUSDT/streams/trade/debug", http.StatusNotFound, nil)
+}
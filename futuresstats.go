@@ -0,0 +1,293 @@
+package gobinapi
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Data types of the USD-M futures statistics recorded by a FuturesStatsPoller.
+const (
+	OpenInterestDataType              = "openInterest"
+	OpenInterestHistDataType          = "openInterestHist"
+	TopLongShortPositionRatioDataType = "topLongShortPositionRatio"
+	TopLongShortAccountRatioDataType  = "topLongShortAccountRatio"
+)
+
+// FuturesStatsPeriods are the periods the futures statistics history endpoints aggregate over.
+var FuturesStatsPeriods = []string{"5m", "15m", "30m", "1h", "2h", "4h", "6h", "12h", "1d"}
+
+// futuresStatsHistoryLimit is the most rows a statistics history request returns.
+const futuresStatsHistoryLimit = 500
+
+// futuresStatsWeight is the request weight of each statistics endpoint.
+const futuresStatsWeight = 1
+
+// OpenInterest is a symbol's open interest, in contracts, at the time the exchange reported it.
+type OpenInterest struct {
+	Time         int64  `json:"time" timestamp:"millis" parquet:"name=time, type=INT64"`
+	Symbol       string `json:"symbol" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	OpenInterest string `json:"openInterest" decimal:"true" parquet:"name=open_interest, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// OpenInterestHist is a symbol's open interest at the end of one period of the open interest history, in contracts
+// and in quote units.
+type OpenInterestHist struct {
+	Time                 int64  `json:"time" timestamp:"millis" parquet:"name=time, type=INT64"`
+	Symbol               string `json:"symbol" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Period               string `json:"period" parquet:"name=period, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	SumOpenInterest      string `json:"sum_open_interest" decimal:"true" parquet:"name=sum_open_interest, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SumOpenInterestValue string `json:"sum_open_interest_value" decimal:"true" parquet:"name=sum_open_interest_value, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// LongShortRatio is the long/short ratio of a symbol's top traders for one period, by position size
+// (TopLongShortPositionRatioDataType) or by number of accounts (TopLongShortAccountRatioDataType). LongAccount and
+// ShortAccount are the long and short shares, which add up to 1.
+type LongShortRatio struct {
+	Time           int64  `json:"time" timestamp:"millis" parquet:"name=time, type=INT64"`
+	Symbol         string `json:"symbol" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Period         string `json:"period" parquet:"name=period, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	LongShortRatio string `json:"long_short_ratio" decimal:"true" parquet:"name=long_short_ratio, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LongAccount    string `json:"long_account" decimal:"true" parquet:"name=long_account, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ShortAccount   string `json:"short_account" decimal:"true" parquet:"name=short_account, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// FuturesStatsConfig configures the polling of USD-M futures statistics, which the exchange keeps only for the last
+// 30 days and has no stream for.
+type FuturesStatsConfig struct {
+	// Interval is how often every instrument's statistics are fetched.
+	Interval time.Duration `json:"interval" yaml:"interval"`
+	// Period is the period of the history rows, one of FuturesStatsPeriods; empty means 5m.
+	Period string `json:"period,omitempty" yaml:"period"`
+}
+
+// Validate checks the settings.
+func (c FuturesStatsConfig) Validate() error {
+	if c.Interval < time.Second {
+		return errors.New("interval must be at least 1s")
+	}
+	if c.Period != "" && !slices.Contains(FuturesStatsPeriods, c.Period) {
+		return fmt.Errorf("unknown period %q", c.Period)
+	}
+	return nil
+}
+
+// period returns the configured period or its default.
+func (c FuturesStatsConfig) period() string {
+	if c.Period == "" {
+		return "5m"
+	}
+	return c.Period
+}
+
+// FuturesStatsRecorders are the recorders of one symbol's futures statistics.
+type FuturesStatsRecorders struct {
+	OpenInterest     RecorderWriter[OpenInterest]
+	OpenInterestHist RecorderWriter[OpenInterestHist]
+	PositionRatio    RecorderWriter[LongShortRatio]
+	AccountRatio     RecorderWriter[LongShortRatio]
+}
+
+// FuturesStatsPoller fetches the open interest, open interest history and top trader long/short ratios of USD-M
+// futures symbols. Each poll records the current open interest plus the history rows that are newer than those of
+// the previous poll; the first poll of a symbol records only the newest history row of each kind.
+type FuturesStatsPoller struct {
+	client *http.Client
+	config FuturesStatsConfig
+	logger LoggerInterface
+
+	// last holds the time of the newest history row recorded per symbol and data type
+	last map[[2]string]int64
+}
+
+// NewFuturesStatsPoller creates a poller using client.
+func NewFuturesStatsPoller(client *http.Client, config FuturesStatsConfig, logger LoggerInterface) *FuturesStatsPoller {
+	return &FuturesStatsPoller{client: client, config: config, logger: logger, last: make(map[[2]string]int64)}
+}
+
+// Run polls the statistics of the symbols instruments returns every interval, starting right away, until ctx is
+// cancelled. recorders returns the recorders of a symbol. Failures are logged and the symbol is polled again on the
+// next tick.
+func (p *FuturesStatsPoller) Run(ctx context.Context, instruments func() []string, recorders func(symbol string) (FuturesStatsRecorders, error)) {
+	ticker := DefaultClock.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		for _, symbol := range instruments() {
+			rec, err := recorders(symbol)
+			if err == nil {
+				err = p.Poll(ctx, symbol, rec)
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				p.logger.Errorf("Failed to poll the futures statistics of %s: %v", symbol, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Poll fetches and records the statistics of symbol once.
+func (p *FuturesStatsPoller) Poll(ctx context.Context, symbol string, rec FuturesStatsRecorders) error {
+	data, err := p.get(ctx, "/fapi/v1/openInterest", url.Values{"symbol": {symbol}})
+	if err != nil {
+		return err
+	}
+	var oi OpenInterest
+	if err := json.Unmarshal(data, &oi); err != nil {
+		return fmt.Errorf("failed to parse open interest: %w", err)
+	}
+	if err := rec.OpenInterest.Write(oi); err != nil {
+		return fmt.Errorf("failed to record open interest: %w", err)
+	}
+	ratioTime := func(r LongShortRatio) int64 { return r.Time }
+	return errors.Join(
+		pollHistory(ctx, p, symbol, OpenInterestHistDataType, "/futures/data/openInterestHist", parseOpenInterestHist,
+			func(r OpenInterestHist) int64 { return r.Time }, rec.OpenInterestHist),
+		pollHistory(ctx, p, symbol, TopLongShortPositionRatioDataType, "/futures/data/topLongShortPositionRatio",
+			parseLongShortRatios, ratioTime, rec.PositionRatio),
+		pollHistory(ctx, p, symbol, TopLongShortAccountRatioDataType, "/futures/data/topLongShortAccountRatio",
+			parseLongShortRatios, ratioTime, rec.AccountRatio),
+	)
+}
+
+// historyLimit returns how many history rows to request for a symbol whose newest recorded row is at last, zero if
+// none is: enough to cover the time since then, or just the newest row on the first poll.
+func (p *FuturesStatsPoller) historyLimit(last int64) int {
+	if last == 0 {
+		return 1
+	}
+	n := int(NowFunc().Sub(time.UnixMilli(last))/BarIntervals[p.config.period()]) + 1
+	return min(max(n, 1), futuresStatsHistoryLimit)
+}
+
+// pollHistory fetches the newest rows of a statistics history endpoint and records, in time order, those newer than
+// the symbol's last recorded row.
+func pollHistory[T any](ctx context.Context, p *FuturesStatsPoller, symbol, dataType, path string,
+	parse func([]byte, string) ([]T, error), timeOf func(T) int64, rec RecorderWriter[T]) error {
+	key := [2]string{symbol, dataType}
+	last := p.last[key]
+	period := p.config.period()
+	q := url.Values{"symbol": {symbol}, "period": {period}, "limit": {strconv.Itoa(p.historyLimit(last))}}
+	data, err := p.get(ctx, path, q)
+	if err != nil {
+		return err
+	}
+	rows, err := parse(data, period)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", dataType, err)
+	}
+	rows = newHistoryRows(rows, last, timeOf)
+	if last == 0 && len(rows) > 1 {
+		rows = rows[len(rows)-1:]
+	}
+	for _, row := range rows {
+		if err := rec.Write(row); err != nil {
+			return fmt.Errorf("failed to record %s: %w", dataType, err)
+		}
+		p.last[key] = timeOf(row)
+	}
+	return nil
+}
+
+// newHistoryRows is a pure function returning the rows newer than last, sorted by time.
+func newHistoryRows[T any](rows []T, last int64, timeOf func(T) int64) []T {
+	rows = slices.DeleteFunc(rows, func(r T) bool { return timeOf(r) <= last })
+	slices.SortStableFunc(rows, func(a, b T) int { return cmp.Compare(timeOf(a), timeOf(b)) })
+	return rows
+}
+
+// get fetches a statistics endpoint of the USD-M futures REST API, waiting for its request weight in
+// DefaultWeightTracker, and returns the response body.
+func (p *FuturesStatsPoller) get(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	if err := DefaultWeightTracker.Wait(ctx, futuresStatsWeight); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, FuturesRESTBaseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	DefaultWeightTracker.ObserveResponse(resp, NowFunc())
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s request returned %s", path, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// statsRow is a row of a statistics history response, which sends the time as a number or a numeric string.
+type statsRow struct {
+	Symbol               string      `json:"symbol"`
+	Timestamp            json.Number `json:"timestamp"`
+	SumOpenInterest      string      `json:"sumOpenInterest"`
+	SumOpenInterestValue string      `json:"sumOpenInterestValue"`
+	LongShortRatio       string      `json:"longShortRatio"`
+	LongAccount          string      `json:"longAccount"`
+	ShortAccount         string      `json:"shortAccount"`
+}
+
+// parseStatsRows is a pure function decoding a statistics history response.
+func parseStatsRows(data []byte) ([]statsRow, []int64, error) {
+	var rows []statsRow
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, nil, err
+	}
+	times := make([]int64, len(rows))
+	for i, row := range rows {
+		ms, err := row.Timestamp.Int64()
+		if err != nil {
+			return nil, nil, fmt.Errorf("malformed timestamp %q of %s", row.Timestamp, row.Symbol)
+		}
+		times[i] = ms
+	}
+	return rows, times, nil
+}
+
+// parseOpenInterestHist is a pure function decoding an open interest history response of the given period.
+func parseOpenInterestHist(data []byte, period string) ([]OpenInterestHist, error) {
+	rows, times, err := parseStatsRows(data)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]OpenInterestHist, len(rows))
+	for i, r := range rows {
+		out[i] = OpenInterestHist{Time: times[i], Symbol: r.Symbol, Period: period, SumOpenInterest: r.SumOpenInterest,
+			SumOpenInterestValue: r.SumOpenInterestValue}
+	}
+	return out, nil
+}
+
+// parseLongShortRatios is a pure function decoding a long/short ratio history response of the given period.
+func parseLongShortRatios(data []byte, period string) ([]LongShortRatio, error) {
+	rows, times, err := parseStatsRows(data)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]LongShortRatio, len(rows))
+	for i, r := range rows {
+		out[i] = LongShortRatio{Time: times[i], Symbol: r.Symbol, Period: period, LongShortRatio: r.LongShortRatio,
+			LongAccount: r.LongAccount, ShortAccount: r.ShortAccount}
+	}
+	return out, nil
+}
@@ -0,0 +1,99 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseFuturesStatsHistory(t *testing.T) {
+	hist, err := parseOpenInterestHist([]byte(`[{"symbol":"BTCUSDT","sumOpenInterest":"20403.637","sumOpenInterestValue":"150570784.078","timestamp":"1583127900000"},`+
+		`{"symbol":"BTCUSDT","sumOpenInterest":"20401.360","sumOpenInterestValue":"149940752.144","timestamp":1583128200000}]`), "5m")
+	if err != nil {
+		t.Fatalf("failed to parse open interest history: %v", err)
+	}
+	want := OpenInterestHist{Time: 1583127900000, Symbol: "BTCUSDT", Period: "5m", SumOpenInterest: "20403.637", SumOpenInterestValue: "150570784.078"}
+	if len(hist) != 2 || hist[0] != want || hist[1].Time != 1583128200000 {
+		t.Errorf("expected string and number timestamps to decode, got %+v", hist)
+	}
+	ratios, err := parseLongShortRatios([]byte(`[{"symbol":"BTCUSDT","longShortRatio":"1.4342","longAccount":"0.5891","shortAccount":"0.4108","timestamp":"1583139600000"}]`), "1h")
+	if err != nil || len(ratios) != 1 || ratios[0] != (LongShortRatio{Time: 1583139600000, Symbol: "BTCUSDT", Period: "1h",
+		LongShortRatio: "1.4342", LongAccount: "0.5891", ShortAccount: "0.4108"}) {
+		t.Errorf("unexpected long/short ratios %+v (%v)", ratios, err)
+	}
+	if _, err := parseLongShortRatios([]byte(`[{"symbol":"BTCUSDT","timestamp":"soon"}]`), "5m"); err == nil {
+		t.Error("expected a malformed timestamp to be rejected")
+	}
+}
+
+func TestFuturesStatsPoller_RecordsNewHistoryRows(t *testing.T) {
+	now := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	clock := useFakeClock(t, now)
+	minute := func(m int) int64 { return now.Add(time.Duration(m) * time.Minute).UnixMilli() }
+	// The history holds rows 5 minutes apart up to rows, newest last, like the exchange's
+	rows := 2
+	limits := make(map[string][]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits[r.URL.Path] = append(limits[r.URL.Path], r.URL.Query().Get("limit"))
+		if r.URL.Path == "/fapi/v1/openInterest" {
+			fmt.Fprintf(w, `{"openInterest":"10659.509","symbol":%q,"time":%d}`, r.URL.Query().Get("symbol"), minute(0))
+			return
+		}
+		io.WriteString(w, "[")
+		for i := 0; i < rows; i++ {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			fmt.Fprintf(w, `{"symbol":"BTCUSDT","sumOpenInterest":"%d","sumOpenInterestValue":"1","longShortRatio":"%d","longAccount":"0.5","shortAccount":"0.5","timestamp":%d}`,
+				i, i, minute(5*(i-rows)))
+		}
+		io.WriteString(w, "]")
+	}))
+	defer srv.Close()
+	old := FuturesRESTBaseURL
+	FuturesRESTBaseURL = srv.URL
+	defer func() { FuturesRESTBaseURL = old }()
+
+	oi, hist, position, account := &fakeSink{}, &fakeSink{}, &fakeSink{}, &fakeSink{}
+	rec := FuturesStatsRecorders{OpenInterest: UntypedSink[OpenInterest](oi), OpenInterestHist: UntypedSink[OpenInterestHist](hist),
+		PositionRatio: UntypedSink[LongShortRatio](position), AccountRatio: UntypedSink[LongShortRatio](account)}
+	p := NewFuturesStatsPoller(http.DefaultClient, FuturesStatsConfig{Interval: 10 * time.Minute}, &FakeLogger{})
+	if err := p.Poll(context.Background(), "BTCUSDT", rec); err != nil {
+		t.Fatalf("first poll failed: %v", err)
+	}
+	if len(oi.records) != 1 || oi.records[0].(OpenInterest).OpenInterest != "10659.509" {
+		t.Fatalf("expected the open interest to be recorded, got %+v", oi.records)
+	}
+	if len(hist.records) != 1 || hist.records[0].(OpenInterestHist).Time != minute(-5) || hist.records[0].(OpenInterestHist).Period != "5m" {
+		t.Fatalf("expected only the newest history row on the first poll, got %+v", hist.records)
+	}
+
+	// Ten minutes and two periods later
+	clock.Advance(10 * time.Minute)
+	now = now.Add(10 * time.Minute)
+	rows = 4
+	if err := p.Poll(context.Background(), "BTCUSDT", rec); err != nil {
+		t.Fatalf("second poll failed: %v", err)
+	}
+	var times []int64
+	for _, r := range hist.records {
+		times = append(times, r.(OpenInterestHist).Time)
+	}
+	if len(times) != 3 || times[1] != minute(-10) || times[2] != minute(-5) {
+		t.Errorf("expected the two new history rows once each, got %v", times)
+	}
+	if len(position.records) != 3 || len(account.records) != 3 || len(oi.records) != 2 {
+		t.Errorf("expected the ratios to be polled like the history, got %d and %d", len(position.records), len(account.records))
+	}
+	if got := limits["/futures/data/openInterestHist"]; len(got) != 2 || got[0] != "1" || got[1] != "4" {
+		t.Errorf("expected the limit to cover the time since the last row, got %v", got)
+	}
+
+	if err := (FuturesStatsConfig{Interval: time.Minute, Period: "3m"}).Validate(); err == nil {
+		t.Error("expected an unknown period to be rejected")
+	}
+}
@@ -0,0 +1,101 @@
+package gobinapi
+
+import "context"
+
+// MaxGapFill is the largest gap, in trade IDs, a GapFiller fetches from the REST API. Larger gaps, e.g. after an
+// outage of hours, are logged and left to the backfill command, which pages through them without holding up the
+// live stream.
+var MaxGapFill int64 = 100000
+
+func init() {
+	DefaultMetrics.Describe("binance_gap_filled_total", "counter", "Records fetched from the REST API to fill gaps across reconnects, per symbol and stream.")
+	DefaultMetrics.Describe("binance_gaps_total", "counter", "ID gaps seen across reconnects, per symbol and stream.")
+}
+
+// GapFiller is a RecorderWriter that passes trades or aggregate trades on to its next writer and fills the gap a
+// reconnect leaves in them. When the first record of a new WebSocket session (a new ConnID) does not follow the
+// last record passed on, the records in between are fetched from the REST API and written first, so the recording
+// stays complete and in ID order. The fetch runs on the writing goroutine; the spill queue in front of it buffers
+// the live records meanwhile.
+type GapFiller[T any] struct {
+	ctx    context.Context
+	next   RecorderWriter[T]
+	key    func(T) (id int64, connID string)
+	fetch  func(ctx context.Context, fromID, toID int64, handle func([]T) error) error
+	labels Labels
+	logger LoggerInterface
+
+	lastID     int64
+	lastConnID string
+}
+
+// NewTradeGapFiller creates a GapFiller of symbol's trades writing to next and fetching gaps through b until ctx is
+// cancelled.
+func NewTradeGapFiller(ctx context.Context, next RecorderWriter[Trade], b *Backfiller, symbol string, logger LoggerInterface) *GapFiller[Trade] {
+	return &GapFiller[Trade]{
+		ctx:  ctx,
+		next: next,
+		key:  func(t Trade) (int64, string) { return t.TradeID, t.ConnID },
+		fetch: func(ctx context.Context, fromID, toID int64, handle func([]Trade) error) error {
+			return b.TradesBetween(ctx, symbol, fromID, toID, handle)
+		},
+		labels: Labels{"symbol": symbol, "stream": "trade"},
+		logger: logger,
+	}
+}
+
+// NewAggTradeGapFiller creates a GapFiller of symbol's aggregate trades writing to next and fetching gaps through b
+// until ctx is cancelled.
+func NewAggTradeGapFiller(ctx context.Context, next RecorderWriter[AggTrade], b *Backfiller, symbol string, logger LoggerInterface) *GapFiller[AggTrade] {
+	return &GapFiller[AggTrade]{
+		ctx:  ctx,
+		next: next,
+		key:  func(t AggTrade) (int64, string) { return t.AggTradeID, t.ConnID },
+		fetch: func(ctx context.Context, fromID, toID int64, handle func([]AggTrade) error) error {
+			return b.AggTradesBetween(ctx, symbol, fromID, toID, handle)
+		},
+		labels: Labels{"symbol": symbol, "stream": "aggTrade"},
+		logger: logger,
+	}
+}
+
+// Write fills the gap before record if it starts a new session after a gap, then writes record to the next writer.
+// A failed fill is logged and the gap left for the backfill command; it does not stop the live records.
+func (g *GapFiller[T]) Write(record T) error {
+	id, connID := g.key(record)
+	if g.lastID > 0 && connID != g.lastConnID && id > g.lastID+1 {
+		g.fill(g.lastID+1, id-1)
+	}
+	g.lastID = max(g.lastID, id)
+	g.lastConnID = connID
+	return g.next.Write(record)
+}
+
+// fill fetches and writes the records with IDs fromID to toID.
+func (g *GapFiller[T]) fill(fromID, toID int64) {
+	DefaultMetrics.Add("binance_gaps_total", g.labels, 1)
+	missing := toID - fromID + 1
+	if missing > MaxGapFill {
+		g.logger.Errorf("Not filling %d %s IDs %d-%d of %s missed across a reconnect, more than %d; run backfill for them",
+			missing, g.labels["stream"], fromID, toID, g.labels["symbol"], MaxGapFill)
+		return
+	}
+	filled := 0
+	err := g.fetch(g.ctx, fromID, toID, func(page []T) error {
+		for _, record := range page {
+			if err := g.next.Write(record); err != nil {
+				return err
+			}
+			filled++
+		}
+		return nil
+	})
+	DefaultMetrics.Add("binance_gap_filled_total", g.labels, float64(filled))
+	if err != nil {
+		g.logger.Errorf("Filled %d of %d %s IDs %d-%d of %s missed across a reconnect: %v",
+			filled, missing, g.labels["stream"], fromID, toID, g.labels["symbol"], err)
+		return
+	}
+	g.logger.Infof("Filled %s IDs %d-%d of %s missed across a reconnect with %d records from the REST API",
+		g.labels["stream"], fromID, toID, g.labels["symbol"], filled)
+}
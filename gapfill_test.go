@@ -0,0 +1,77 @@
+package gobinapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// tradeCollector collects the trades written to it.
+type tradeCollector []Trade
+
+func (w *tradeCollector) Write(record Trade) error {
+	*w = append(*w, record)
+	return nil
+}
+
+func TestGapFiller_FillsTradesMissedAcrossReconnect(t *testing.T) {
+	history := newFakeTradeHistory(t, 20)
+	b := NewBackfiller(http.DefaultClient, MarketSpot, NewWeightTracker(DefaultRESTWeightLimit), &FakeLogger{})
+	var written tradeCollector
+	g := NewTradeGapFiller(context.Background(), &written, b, "BTCUSDT", &FakeLogger{})
+
+	live := []Trade{
+		{TradeID: 1, ConnID: "a"}, {TradeID: 2, ConnID: "a"}, {TradeID: 3, ConnID: "a"},
+		// Trades 4 to 7 were missed while reconnecting
+		{TradeID: 8, ConnID: "b"}, {TradeID: 9, ConnID: "b"},
+		// A planned reconnect that loses nothing needs no fill
+		{TradeID: 10, ConnID: "c"},
+	}
+	for _, trade := range live {
+		if err := g.Write(trade); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(written) != 10 {
+		t.Fatalf("expected trades 1 to 10, got %d trades", len(written))
+	}
+	for i, trade := range written {
+		if trade.TradeID != int64(i+1) {
+			t.Fatalf("expected trades in ID order, got %d at %d", trade.TradeID, i)
+		}
+		filled := trade.TradeID >= 4 && trade.TradeID <= 7
+		if filled != (trade.Source == SourceREST) {
+			t.Errorf("unexpected source %q of trade %d", trade.Source, trade.TradeID)
+		}
+	}
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	if len(history.requests) != 1 || history.requests[0] != "/api/v3/historicalTrades?fromId=4&limit=1000&symbol=BTCUSDT" {
+		t.Errorf("expected one request for the missed trades, got %v", history.requests)
+	}
+}
+
+func TestGapFiller_LeavesLargeGapsToBackfill(t *testing.T) {
+	history := newFakeTradeHistory(t, 20)
+	old := MaxGapFill
+	MaxGapFill = 3
+	t.Cleanup(func() { MaxGapFill = old })
+	b := NewBackfiller(http.DefaultClient, MarketSpot, NewWeightTracker(DefaultRESTWeightLimit), &FakeLogger{})
+	var written FakeRecorder
+	g := NewAggTradeGapFiller(context.Background(), &written, b, "BTCUSDT", &FakeLogger{})
+
+	for _, trade := range []AggTrade{{AggTradeID: 1, ConnID: "a"}, {AggTradeID: 6, ConnID: "b"}, {AggTradeID: 9, ConnID: "c"}} {
+		if err := g.Write(trade); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := written.GetRecords()
+	if len(records) != 5 || records[1].AggTradeID != 6 || records[2].AggTradeID != 7 || records[2].Source != SourceREST {
+		t.Errorf("expected the gap of 4 to be left and the gap of 2 filled, got %+v", records)
+	}
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	if len(history.requests) != 1 || history.requests[0] != "/api/v3/aggTrades?fromId=7&limit=1000&symbol=BTCUSDT" {
+		t.Errorf("unexpected requests %v", history.requests)
+	}
+}
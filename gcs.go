@@ -0,0 +1,305 @@
+package gobinapi
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Chunk sizes of GCS resumable uploads, which must be multiples of GCSChunkMultiple.
+const (
+	DefaultGCSChunkSize = 32 << 20
+	GCSChunkMultiple    = 256 << 10
+)
+
+// GCSEndpoint is the Cloud Storage API base URL.
+const GCSEndpoint = "https://storage.googleapis.com"
+
+// gcsScope is the OAuth2 scope uploads are authorized for.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// GCSConfig configures the Google Cloud Storage upload backend.
+type GCSConfig struct {
+	Bucket string `json:"bucket" yaml:"bucket"`
+	// Prefix is prepended to every object name, e.g. "binance/"; the rest of the name is the file's path below the
+	// output directory.
+	Prefix string `json:"prefix,omitempty" yaml:"prefix"`
+	// CredentialsFile is a service account JSON key. Defaults to the GOOGLE_APPLICATION_CREDENTIALS environment
+	// variable.
+	CredentialsFile string `json:"credentials_file,omitempty" yaml:"credentials_file"`
+	// Endpoint replaces GCSEndpoint, e.g. for an emulator.
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint"`
+	// ChunkSize is the size of the chunks a resumable upload sends. Defaults to DefaultGCSChunkSize; it must be a
+	// multiple of GCSChunkMultiple.
+	ChunkSize int64 `json:"chunk_size,omitempty" yaml:"chunk_size"`
+}
+
+// Validate checks the settings that do not depend on the environment.
+func (c GCSConfig) Validate() error {
+	if c.Bucket == "" {
+		return errors.New("gcs: bucket is required")
+	}
+	if c.ChunkSize < 0 || c.ChunkSize%GCSChunkMultiple != 0 {
+		return fmt.Errorf("gcs: chunk size must be a multiple of %d bytes, got %d", GCSChunkMultiple, c.ChunkSize)
+	}
+	return nil
+}
+
+// ServiceAccountKey is the part of a service account JSON key needed to obtain access tokens.
+type ServiceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// GCSUploader is an Uploader storing files in a GCS bucket. It authenticates as a service account, exchanging a
+// signed JWT for access tokens, and sends every file as a resumable upload in chunks, so a large file is not held
+// in memory. Uploads overwrite the object, so they are idempotent as the UploadQueue requires.
+type GCSUploader struct {
+	cfg    GCSConfig
+	root   string
+	client *http.Client
+	key    ServiceAccountKey
+	signer *rsa.PrivateKey
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewGCSUploader creates an uploader for cfg, reading the service account key. Object names are the uploaded
+// files' paths relative to root, the recorder's output directory.
+func NewGCSUploader(cfg GCSConfig, root string, client *http.Client) (*GCSUploader, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = DefaultGCSChunkSize
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = GCSEndpoint
+	}
+	if cfg.CredentialsFile == "" {
+		cfg.CredentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if cfg.CredentialsFile == "" {
+		return nil, errors.New("gcs: no credentials file configured")
+	}
+	data, err := os.ReadFile(cfg.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("gcs: failed to read credentials: %w", err)
+	}
+	var key ServiceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("gcs: failed to parse credentials %s: %w", cfg.CredentialsFile, err)
+	}
+	signer, err := parseRSAPrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("gcs: credentials %s: %w", cfg.CredentialsFile, err)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &GCSUploader{cfg: cfg, root: root, client: client, key: key, signer: signer}, nil
+}
+
+// parseRSAPrivateKey decodes a PEM encoded PKCS #8 or PKCS #1 RSA key.
+func parseRSAPrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// Object returns the object name filePath is uploaded to.
+func (u *GCSUploader) Object(filePath string) string {
+	return u.cfg.Prefix + remotePath(u.root, filePath)
+}
+
+// Upload stores filePath under its object name.
+func (u *GCSUploader) Upload(ctx context.Context, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	name := u.Object(filePath)
+	session, err := u.startUpload(ctx, name)
+	if err != nil {
+		return fmt.Errorf("gcs: failed to start upload of %s: %w", name, err)
+	}
+	if err := u.sendChunks(ctx, session, f, info.Size()); err != nil {
+		return fmt.Errorf("gcs: failed to upload %s: %w", name, err)
+	}
+	return nil
+}
+
+// startUpload opens a resumable upload session and returns its URI.
+func (u *GCSUploader) startUpload(ctx context.Context, name string) (string, error) {
+	target := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=resumable&name=%s",
+		strings.TrimSuffix(u.cfg.Endpoint, "/"), url.PathEscape(u.cfg.Bucket), url.QueryEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Upload-Content-Type", "application/octet-stream")
+	resp, err := u.send(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+	}
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return "", errors.New("no upload session returned")
+	}
+	return session, nil
+}
+
+// sendChunks sends the size bytes of r to the upload session in chunks of ChunkSize. GCS answers 308 for every
+// chunk but the last.
+func (u *GCSUploader) sendChunks(ctx context.Context, session string, r io.Reader, size int64) error {
+	buf := make([]byte, u.cfg.ChunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, session, bytes.NewReader(buf[:n]))
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		} else {
+			req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(n)-1, size))
+		}
+		resp, err := u.send(ctx, req)
+		if err != nil {
+			return err
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		offset += int64(n)
+		switch {
+		case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+			if offset != size {
+				return fmt.Errorf("upload finished after %d of %d bytes", offset, size)
+			}
+			return nil
+		case resp.StatusCode == http.StatusPermanentRedirect && offset < size:
+		default:
+			return fmt.Errorf("chunk at offset %d: unexpected status %s: %s", offset-int64(n), resp.Status, body)
+		}
+	}
+}
+
+// send authorizes req with an access token and sends it.
+func (u *GCSUploader) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	token, err := u.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return u.client.Do(req)
+}
+
+// accessToken returns a cached access token, exchanging a new signed JWT for one shortly before it expires.
+func (u *GCSUploader) accessToken(ctx context.Context) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := NowFunc()
+	if u.token != "" && now.Before(u.tokenExpiry.Add(-time.Minute)) {
+		return u.token, nil
+	}
+	assertion, err := SignServiceAccountJWT(u.key, u.signer, gcsScope, now)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.key.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request access token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request access token: unexpected status %s: %s", resp.Status, body)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("unexpected access token response: %s", body)
+	}
+	u.token, u.tokenExpiry = token.AccessToken, now.Add(time.Duration(token.ExpiresIn)*time.Second)
+	return u.token, nil
+}
+
+// SignServiceAccountJWT returns an RS256 signed JWT asserting key's identity for scope, valid for an hour from now,
+// to be exchanged for an access token at key.TokenURI.
+func SignServiceAccountJWT(key ServiceAccountKey, signer *rsa.PrivateKey, scope string, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": key.PrivateKeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   key.ClientEmail,
+		"scope": scope,
+		"aud":   key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, signer, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,189 @@
+package gobinapi
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGCS is a minimal Cloud Storage server with a token endpoint, supporting resumable uploads.
+type fakeGCS struct {
+	publicKey *rsa.PublicKey
+	mu        sync.Mutex
+	tokens    int
+	objects   map[string][]byte
+	sessions  map[string][]byte
+	ranges    []string
+}
+
+func (s *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	if r.URL.Path == "/token" {
+		form, _ := url.ParseQuery(string(body))
+		if err := s.verifyJWT(form.Get("assertion")); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		s.tokens++
+		fmt.Fprint(w, `{"access_token":"token-1","expires_in":3600,"token_type":"Bearer"}`)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token-1" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Query().Get("uploadType") == "resumable":
+		bucket := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/upload/storage/v1/b/"), "/o")
+		name := "/" + bucket + "/" + r.URL.Query().Get("name")
+		s.sessions[name] = nil
+		w.Header().Set("Location", "http://"+r.Host+"/session"+name)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/session/"):
+		name := strings.TrimPrefix(r.URL.Path, "/session")
+		contentRange := r.Header.Get("Content-Range")
+		s.ranges = append(s.ranges, contentRange)
+		var start, end, total int64
+		if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &total); err != nil {
+			fmt.Sscanf(contentRange, "bytes */%d", &total)
+			start, end = total, total-1
+		}
+		if start != int64(len(s.sessions[name])) {
+			http.Error(w, "unexpected offset", http.StatusBadRequest)
+			return
+		}
+		s.sessions[name] = append(s.sessions[name], body...)
+		if end+1 < total {
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		s.objects[name] = s.sessions[name]
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// verifyJWT checks the assertion's RS256 signature and audience.
+func (s *fakeGCS) verifyJWT(assertion string) error {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed JWT %q", assertion)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(s.publicKey, crypto.SHA256, digest[:], signature); err != nil {
+		return err
+	}
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var c struct{ Iss, Aud, Scope string }
+	json.Unmarshal(claims, &c)
+	if c.Iss != "recorder@project.iam.gserviceaccount.com" || !strings.HasSuffix(c.Aud, "/token") || c.Scope != gcsScope {
+		return fmt.Errorf("unexpected claims %s", claims)
+	}
+	return nil
+}
+
+func newTestGCSUploader(t *testing.T, root string) (*GCSUploader, *fakeGCS) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcs := &fakeGCS{publicKey: &key.PublicKey, objects: make(map[string][]byte), sessions: make(map[string][]byte)}
+	srv := httptest.NewServer(gcs)
+	t.Cleanup(srv.Close)
+
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	credentials, _ := json.Marshal(ServiceAccountKey{
+		ClientEmail:  "recorder@project.iam.gserviceaccount.com",
+		PrivateKeyID: "key-1",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:     srv.URL + "/token",
+	})
+	credentialsFile := filepath.Join(t.TempDir(), "service-account.json")
+	os.WriteFile(credentialsFile, credentials, 0600)
+
+	u, err := NewGCSUploader(GCSConfig{
+		Bucket: "market-data", Prefix: "binance/", CredentialsFile: credentialsFile, Endpoint: srv.URL,
+		ChunkSize: GCSChunkMultiple,
+	}, root, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u, gcs
+}
+
+func TestGCSUploader_UploadsInChunksWithOneToken(t *testing.T) {
+	root := t.TempDir()
+	small := filepath.Join(root, "BTCUSDT", "trade.parquet")
+	large := filepath.Join(root, "BTCUSDT", "depth.parquet")
+	os.MkdirAll(filepath.Dir(small), 0755)
+	os.WriteFile(small, []byte("parquet data"), 0644)
+	data := bytes.Repeat([]byte("x"), 2*GCSChunkMultiple+100)
+	os.WriteFile(large, data, 0644)
+	u, gcs := newTestGCSUploader(t, root)
+
+	for _, file := range []string{small, large} {
+		if err := u.Upload(context.Background(), file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := string(gcs.objects["/market-data/binance/BTCUSDT/trade.parquet"]); got != "parquet data" {
+		t.Errorf("unexpected small object %q", got)
+	}
+	if !bytes.Equal(gcs.objects["/market-data/binance/BTCUSDT/depth.parquet"], data) {
+		t.Errorf("expected the chunks to be joined into the object, ranges %v", gcs.ranges)
+	}
+	expected := []string{
+		"bytes 0-11/12",
+		fmt.Sprintf("bytes 0-%d/%d", GCSChunkMultiple-1, len(data)),
+		fmt.Sprintf("bytes %d-%d/%d", GCSChunkMultiple, 2*GCSChunkMultiple-1, len(data)),
+		fmt.Sprintf("bytes %d-%d/%d", 2*GCSChunkMultiple, len(data)-1, len(data)),
+	}
+	if fmt.Sprint(gcs.ranges) != fmt.Sprint(expected) {
+		t.Errorf("expected ranges %v, got %v", expected, gcs.ranges)
+	}
+	if gcs.tokens != 1 {
+		t.Errorf("expected the access token to be reused, got %d token requests", gcs.tokens)
+	}
+}
+
+func TestGCSUploader_UploadsEmptyFile(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "trade.parquet")
+	os.WriteFile(file, nil, 0644)
+	u, gcs := newTestGCSUploader(t, root)
+
+	if err := u.Upload(context.Background(), file); err != nil {
+		t.Fatal(err)
+	}
+	if object, ok := gcs.objects["/market-data/binance/trade.parquet"]; !ok || len(object) != 0 {
+		t.Errorf("expected an empty object, got ranges %v", gcs.ranges)
+	}
+}
+
+func TestGCSConfig_ValidateChunkSize(t *testing.T) {
+	if err := (GCSConfig{Bucket: "b", ChunkSize: GCSChunkMultiple + 1}).Validate(); err == nil {
+		t.Error("expected a chunk size that is not a multiple of 256 KiB to be rejected")
+	}
+	if err := (GCSConfig{Bucket: "b", ChunkSize: 4 * GCSChunkMultiple}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
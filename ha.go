@@ -0,0 +1,177 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// High-availability recording runs two instances against the same symbols: the active instance periodically writes a
+// heartbeat file (typically on shared storage) and the standby instance watches it. Both record continuously, but
+// the standby suppresses finalization of its files (they are left with a ".standby" suffix) unless the active's
+// heartbeat disappeared at some point while the file was open, in which case the standby's copy is needed to cover
+// the gap and is finalized normally.
+
+const (
+	HAModeNone    = ""
+	HAModeActive  = "active"
+	HAModeStandby = "standby"
+)
+
+// StandbySuffix is appended to files whose finalization was suppressed by a standby instance.
+const StandbySuffix = ".standby"
+
+// Heartbeat is the content of the heartbeat file written by the active instance.
+type Heartbeat struct {
+	RunID string    `json:"run_id"`
+	Host  string    `json:"host"`
+	Time  time.Time `json:"time"`
+}
+
+// WriteHeartbeat writes a heartbeat to filePath, replacing it atomically so a reader never sees a partial file.
+func WriteHeartbeat(filePath string, hb Heartbeat) error {
+	data, err := json.Marshal(hb)
+	if err != nil {
+		return fmt.Errorf("failed to encode heartbeat: %w", err)
+	}
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write heartbeat %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename heartbeat to %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// ReadHeartbeat reads the heartbeat file written by WriteHeartbeat.
+func ReadHeartbeat(filePath string) (*Heartbeat, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var hb Heartbeat
+	if err := json.Unmarshal(data, &hb); err != nil {
+		return nil, fmt.Errorf("failed to parse heartbeat %s: %w", filePath, err)
+	}
+	return &hb, nil
+}
+
+// RunHeartbeatWriter writes a heartbeat for the active instance every interval until the context is cancelled.
+// On a clean stop the heartbeat file is removed, so the standby takes over immediately rather than after a timeout.
+func RunHeartbeatWriter(ctx context.Context, filePath string, runID string, interval time.Duration, logger LoggerInterface) error {
+	host, _ := os.Hostname()
+	write := func() {
+		if err := WriteHeartbeat(filePath, Heartbeat{RunID: runID, Host: host, Time: NowFunc().UTC()}); err != nil {
+			logger.Errorf("Failed to write heartbeat: %v", err)
+		}
+	}
+	write()
+	ticker := DefaultClock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			os.Remove(filePath)
+			return ctx.Err()
+		case <-ticker.C():
+			write()
+		}
+	}
+}
+
+// FinalizeGate decides whether a Recorder may finalize a file that was opened at the given time.
+type FinalizeGate interface {
+	ShouldFinalize(since time.Time) bool
+}
+
+// StandbyMonitor watches the active instance's heartbeat and remembers when it was missing. It implements
+// FinalizeGate: a file may be finalized if the active was considered dead at any time since the file was opened.
+type StandbyMonitor struct {
+	filePath string
+	timeout  time.Duration
+	logger   LoggerInterface
+
+	mu          sync.Mutex
+	activeAlive bool
+	outageStart time.Time
+	lastOutage  time.Time
+	checked     bool
+}
+
+// NewStandbyMonitor creates a monitor for the heartbeat file at filePath. The active is considered dead when its
+// heartbeat is older than timeout or missing altogether.
+func NewStandbyMonitor(filePath string, timeout time.Duration, logger LoggerInterface) *StandbyMonitor {
+	return &StandbyMonitor{filePath: filePath, timeout: timeout, logger: logger}
+}
+
+// HeartbeatAlive is a pure function reporting whether a heartbeat is recent enough at the given time.
+func HeartbeatAlive(hb *Heartbeat, now time.Time, timeout time.Duration) bool {
+	if hb == nil {
+		return false
+	}
+	return now.Sub(hb.Time) <= timeout
+}
+
+// Check reads the heartbeat file once and updates the monitor's view of the active instance.
+func (m *StandbyMonitor) Check(now time.Time) {
+	hb, err := ReadHeartbeat(m.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		m.logger.Errorf("Failed to read heartbeat: %v", err)
+	}
+	m.observe(HeartbeatAlive(hb, now, m.timeout), now)
+}
+
+// observe records whether the active was alive at the given time and logs takeover/handback transitions.
+func (m *StandbyMonitor) observe(alive bool, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !alive {
+		if m.activeAlive || !m.checked {
+			m.outageStart = now
+			m.logger.Errorf("Active heartbeat lost; standby will finalize its files")
+		}
+		m.lastOutage = now
+	} else if m.checked && !m.activeAlive {
+		m.lastOutage = now
+		m.logger.Infof("Active heartbeat restored after %s; standby resumes suppressing finalization", now.Sub(m.outageStart))
+	}
+	m.checked = true
+	m.activeAlive = alive
+}
+
+// ActiveAlive reports whether the active instance was alive at the last check.
+func (m *StandbyMonitor) ActiveAlive() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.activeAlive
+}
+
+// ShouldFinalize reports whether the active's heartbeat was missing at any time since the given time.
+func (m *StandbyMonitor) ShouldFinalize(since time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.activeAlive {
+		return true
+	}
+	return !m.lastOutage.IsZero() && !m.lastOutage.Before(since)
+}
+
+// Run polls the heartbeat file every interval until the context is cancelled.
+func (m *StandbyMonitor) Run(ctx context.Context, interval time.Duration) error {
+	m.Check(NowFunc())
+	ticker := DefaultClock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+			m.Check(NowFunc())
+		}
+	}
+}
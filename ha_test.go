@@ -0,0 +1,104 @@
+package gobinapi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heartbeat.json")
+	now := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	if err := WriteHeartbeat(path, Heartbeat{RunID: "run1", Host: "host-a", Time: now}); err != nil {
+		t.Fatalf("WriteHeartbeat returned error: %v", err)
+	}
+	hb, err := ReadHeartbeat(path)
+	if err != nil {
+		t.Fatalf("ReadHeartbeat returned error: %v", err)
+	}
+	if hb.RunID != "run1" || hb.Host != "host-a" || !hb.Time.Equal(now) {
+		t.Errorf("unexpected heartbeat: %+v", hb)
+	}
+	if !HeartbeatAlive(hb, now.Add(10*time.Second), 30*time.Second) {
+		t.Errorf("expected heartbeat 10s old to be alive with a 30s timeout")
+	}
+	if HeartbeatAlive(hb, now.Add(31*time.Second), 30*time.Second) {
+		t.Errorf("expected heartbeat 31s old to be dead with a 30s timeout")
+	}
+	if HeartbeatAlive(nil, now, 30*time.Second) {
+		t.Errorf("expected missing heartbeat to be dead")
+	}
+}
+
+func TestStandbyMonitor_ShouldFinalize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heartbeat.json")
+	m := NewStandbyMonitor(path, 30*time.Second, &FakeLogger{})
+	base := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+
+	// Active healthy: the standby must not finalize a file opened at base
+	WriteHeartbeat(path, Heartbeat{RunID: "active", Time: base})
+	m.Check(base.Add(5 * time.Second))
+	if !m.ActiveAlive() {
+		t.Fatalf("expected active to be alive")
+	}
+	if m.ShouldFinalize(base) {
+		t.Errorf("standby should not finalize while the active is healthy")
+	}
+
+	// Heartbeat goes stale: finalization is allowed
+	m.Check(base.Add(time.Minute))
+	if m.ActiveAlive() {
+		t.Fatalf("expected active to be considered dead")
+	}
+	if !m.ShouldFinalize(base) {
+		t.Errorf("standby should finalize while the active is dead")
+	}
+
+	// Active comes back: a file opened before the outage still needs finalizing, a file opened after does not
+	WriteHeartbeat(path, Heartbeat{RunID: "active", Time: base.Add(2 * time.Minute)})
+	m.Check(base.Add(2 * time.Minute))
+	if !m.ActiveAlive() {
+		t.Fatalf("expected active to be alive again")
+	}
+	if !m.ShouldFinalize(base) {
+		t.Errorf("file spanning the outage should be finalized")
+	}
+	if m.ShouldFinalize(base.Add(3 * time.Minute)) {
+		t.Errorf("file opened after the outage should not be finalized")
+	}
+}
+
+type fixedGate bool
+
+func (g fixedGate) ShouldFinalize(since time.Time) bool { return bool(g) }
+
+func TestRecorder_FinalizeGateSuppressesFinalization(t *testing.T) {
+	type Dummy struct {
+		A int `parquet:"name=a, type=INT32"`
+	}
+	instrument := "TEST-INSTR-STANDBY"
+	filePath := BuildFileName("testdata", instrument, time.Now().UTC())
+	os.Remove(filePath)
+
+	r, err := NewRecorder[Dummy](instrument, "testdata", 1)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	r.SetFinalizeGate(fixedGate(false))
+	if err := r.Write(Dummy{A: 1}); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+	defer os.Remove(filePath + StandbySuffix)
+
+	if FileExists(filePath) {
+		os.Remove(filePath)
+		t.Errorf("expected %s not to be finalized by a standby", filePath)
+	}
+	if !FileExists(filePath + StandbySuffix) {
+		t.Errorf("expected standby copy %s to exist", filePath+StandbySuffix)
+	}
+}
@@ -0,0 +1,183 @@
+package gobinapi
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Health classifications of a stream, and of the recorder as a whole, which is as bad as its worst stream.
+const (
+	HealthOK        = "ok"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
+// lastMessages holds the receive time of the latest message of every WebSocket stream as an *atomic.Int64 of Unix
+// nanoseconds, so every message can be recorded without a lock.
+var lastMessages sync.Map
+
+// recordMessage records that a message of stream was received now.
+func recordMessage(stream string) {
+	v, ok := lastMessages.Load(stream)
+	if !ok {
+		v, _ = lastMessages.LoadOrStore(stream, new(atomic.Int64))
+	}
+	v.(*atomic.Int64).Store(NowFunc().UnixNano())
+}
+
+// lastMessage returns when the latest message of stream was received, or the zero time if none was.
+func lastMessage(stream string) time.Time {
+	v, ok := lastMessages.Load(stream)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(0, v.(*atomic.Int64).Load())
+}
+
+// streamDataTypes maps the listener keys of WebSocket streams (see listenerKey) to the data types they record.
+var streamDataTypes = map[string]string{
+	StreamTrade:             "trade",
+	StreamAggTrade:          "aggTrade",
+	StreamDepth:             "orderBookDiff",
+	StreamBookTicker:        "bestPrice",
+	StreamMarkPrice + "@1s": "markPrice",
+	StreamAvgPrice:          "avgPrice",
+	StreamTicker:            "ticker",
+	StreamTicker1h:          "ticker_1h",
+	StreamTicker4h:          "ticker_4h",
+	StreamTicker1d:          "ticker_1d",
+}
+
+// StreamHealth is the health of one WebSocket stream of an instrument.
+type StreamHealth struct {
+	Symbol string `json:"symbol"`
+	Stream string `json:"stream"`
+	Status string `json:"status"`
+	// LastMessage is when the stream's latest message was received, LastWrite when its recorder last took a row.
+	// Either is omitted if there was none yet.
+	LastMessage time.Time `json:"last_message,omitzero"`
+	LastWrite   time.Time `json:"last_write,omitzero"`
+	// Silent is how long the stream has received nothing, counted from when it was first checked if it never has.
+	Silent     string `json:"silent"`
+	Reconnects int64  `json:"reconnects"`
+}
+
+// HealthReport is the answer of /healthz.
+type HealthReport struct {
+	Status  string         `json:"status"`
+	Time    time.Time      `json:"time"`
+	Streams []StreamHealth `json:"streams"`
+}
+
+// healthChecker classifies the streams of the running instruments by how long they have been silent.
+type healthChecker struct {
+	env            *pipelineEnv
+	degradedAfter  time.Duration
+	unhealthyAfter time.Duration
+
+	mu sync.Mutex
+	// firstSeen holds when each stream was first checked, forgotten when it is not recorded any more so a stream
+	// switched back on is not judged by its old messages
+	firstSeen map[string]time.Time
+}
+
+func newHealthChecker(env *pipelineEnv, degradedAfter, unhealthyAfter time.Duration) *healthChecker {
+	return &healthChecker{
+		env:            env,
+		degradedAfter:  degradedAfter,
+		unhealthyAfter: unhealthyAfter,
+		firstSeen:      make(map[string]time.Time),
+	}
+}
+
+// streams returns the symbol and listener key of every WebSocket stream switched on.
+func (h *healthChecker) streams() [][2]string {
+	h.env.mu.Lock()
+	defer h.env.mu.Unlock()
+	var streams [][2]string
+	for symbol, p := range h.env.pipelines {
+		p.mu.Lock()
+		for key := range p.running {
+			streams = append(streams, [2]string{symbol, key})
+		}
+		p.mu.Unlock()
+	}
+	return streams
+}
+
+// Check returns the health of every stream, sorted by symbol and stream.
+func (h *healthChecker) Check() HealthReport {
+	now := NowFunc()
+	report := HealthReport{Status: HealthOK, Time: now.UTC(), Streams: []StreamHealth{}}
+	writes := make(map[[2]string]time.Time)
+	for _, r := range Introspect().Recorders {
+		writes[[2]string{r.Instrument, r.DataType}] = r.LastWrite
+	}
+	reconnects := make(map[string]int64)
+	for _, s := range WebSocketStats() {
+		reconnects[s.Stream] = s.Reconnects
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	seen := make(map[string]time.Time)
+	for _, s := range h.streams() {
+		symbol, key := s[0], s[1]
+		stream := strings.ToLower(symbol) + "@" + key
+		first, ok := h.firstSeen[stream]
+		if !ok {
+			first = now
+		}
+		seen[stream] = first
+		health := StreamHealth{
+			Symbol:      symbol,
+			Stream:      stream,
+			LastMessage: lastMessage(stream),
+			LastWrite:   writes[[2]string{symbol, streamDataTypes[key]}],
+			Reconnects:  reconnects[stream],
+		}
+		since := first
+		if health.LastMessage.After(since) {
+			since = health.LastMessage
+		}
+		silent := now.Sub(since)
+		health.Silent = silent.Round(time.Millisecond).String()
+		switch {
+		case silent >= h.unhealthyAfter:
+			health.Status = HealthUnhealthy
+			report.Status = HealthUnhealthy
+		case silent >= h.degradedAfter:
+			health.Status = HealthDegraded
+			if report.Status == HealthOK {
+				report.Status = HealthDegraded
+			}
+		default:
+			health.Status = HealthOK
+		}
+		report.Streams = append(report.Streams, health)
+	}
+	h.firstSeen = seen
+	sort.Slice(report.Streams, func(i, j int) bool {
+		a, b := report.Streams[i], report.Streams[j]
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		return a.Stream < b.Stream
+	})
+	return report
+}
+
+// ServeHTTP answers /healthz with the HealthReport, with status 503 if any stream is unhealthy so that liveness and
+// readiness probes fail, and 200 otherwise.
+func (h *healthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.Check()
+	status := http.StatusOK
+	if report.Status == HealthUnhealthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
@@ -0,0 +1,95 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestHealthChecker_ClassifiesSilentStreams(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	oldNow := NowFunc
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = oldNow }()
+
+	env := &pipelineEnv{pipelines: map[string]*instrumentPipeline{
+		"HLTHUSDT": {running: map[string]context.CancelFunc{StreamTrade: nil, StreamDepth: nil}},
+	}}
+	h := newHealthChecker(env, time.Minute, 5*time.Minute)
+	recordConnect("hlthusdt@trade")
+	recordConnect("hlthusdt@trade")
+	recordMessage("hlthusdt@trade")
+	report := h.Check()
+	if report.Status != HealthOK || len(report.Streams) != 2 {
+		t.Fatalf("expected two healthy streams, got %+v", report)
+	}
+	trade := report.Streams[1]
+	if trade.Stream != "hlthusdt@trade" || !trade.LastMessage.Equal(now) || trade.Reconnects != 1 {
+		t.Errorf("unexpected trade health %+v", trade)
+	}
+
+	// Only the stream still receiving messages stays healthy
+	now = now.Add(2 * time.Minute)
+	recordMessage("hlthusdt@trade")
+	report = h.Check()
+	if report.Status != HealthDegraded || report.Streams[0].Status != HealthDegraded || report.Streams[1].Status != HealthOK {
+		t.Errorf("expected the silent depth stream to be degraded, got %+v", report)
+	}
+	if report.Streams[0].Silent != "2m0s" {
+		t.Errorf("expected depth to be silent since it was first checked, got %s", report.Streams[0].Silent)
+	}
+
+	now = now.Add(4 * time.Minute)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 with an unhealthy stream, got %d", rec.Code)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != HealthUnhealthy || report.Streams[0].Status != HealthUnhealthy || report.Streams[1].Status != HealthDegraded {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestRun_ServesHealthz(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("hltrusdt@trade", mockbinance.TradeMessage("HLTRUSDT", 1, "1.0", "1"))
+	dir := t.TempDir()
+	t.Chdir(dir)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"HLTRUSDT"}
+	cfg.Streams = map[string][]string{"HLTRUSDT": {StreamTrade}}
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.HealthAddr = addr
+	cfg.Logger = NewLogger(io.Discard)
+	_, stop := startAdminRun(t, cfg)
+	defer stop()
+	waitForRows(t, "HLTRUSDT", "trade", 1)
+
+	var report HealthReport
+	adminRequest(t, http.MethodGet, "http://"+addr+"/healthz", http.StatusOK, &report)
+	if report.Status != HealthOK || len(report.Streams) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	s := report.Streams[0]
+	if s.Symbol != "HLTRUSDT" || s.Stream != "hltrusdt@trade" || s.LastMessage.IsZero() || s.LastWrite.IsZero() {
+		t.Errorf("unexpected stream health %+v", s)
+	}
+}
@@ -0,0 +1,203 @@
+package gobinapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InfluxConfig configures the InfluxDB line-protocol sink. The sink targets the InfluxDB 2.x /api/v2/write endpoint;
+// for InfluxDB 1.x set Bucket to "<database>/<retention policy>" and leave Org empty, which the 1.x compatibility
+// API accepts.
+type InfluxConfig struct {
+	URL    string `json:"url" yaml:"url"`
+	Org    string `json:"org" yaml:"org"`
+	Bucket string `json:"bucket" yaml:"bucket"`
+	// Token defaults to the INFLUX_TOKEN environment variable.
+	Token         string        `json:"-" yaml:"-"`
+	BatchSize     int           `json:"batch_size" yaml:"batch_size"`
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"`
+}
+
+// Validate checks the settings.
+func (c InfluxConfig) Validate() error {
+	if c.URL == "" || c.Bucket == "" {
+		return fmt.Errorf("influx: url and bucket are required")
+	}
+	return nil
+}
+
+// InfluxSink converts selected records into InfluxDB line protocol and writes them in batches, so live dashboards
+// (e.g. Grafana) can be fed directly from the recorder. It implements RecorderWriter[any] and is meant to be
+// combined with a parquet Recorder using FanOutWriter and UntypedWriter. Records of types without a line-protocol
+// mapping are ignored.
+type InfluxSink struct {
+	cfg    InfluxConfig
+	client *http.Client
+	logger LoggerInterface
+
+	// health holds the outcome of the last flush, see Healthy
+	health sinkHealth
+
+	mu    sync.Mutex
+	lines []string
+	flush chan struct{}
+}
+
+// NewInfluxSink creates an InfluxSink. Call Run to start the background flush loop.
+func NewInfluxSink(cfg InfluxConfig, client *http.Client, logger LoggerInterface) (*InfluxSink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Token == "" {
+		cfg.Token = os.Getenv("INFLUX_TOKEN")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	return &InfluxSink{
+		cfg:    cfg,
+		client: client,
+		logger: logger,
+		lines:  make([]string, 0, cfg.BatchSize),
+		flush:  make(chan struct{}, 1),
+	}, nil
+}
+
+// Write converts a record to line protocol and buffers it. It never performs network I/O itself, so a slow InfluxDB
+// does not stall the subscriber goroutine.
+func (s *InfluxSink) Write(record interface{}) error {
+	line, ok, err := FormatInfluxLine(record, NowFunc())
+	if err != nil || !ok {
+		return err
+	}
+	s.mu.Lock()
+	s.lines = append(s.lines, line)
+	full := len(s.lines) >= s.cfg.BatchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// ForSymbol returns the sink itself, since best prices carry their symbol.
+func (s *InfluxSink) ForSymbol(symbol string) RecorderWriter[any] {
+	return s
+}
+
+// Healthy returns the error of the last flush, or nil if it succeeded.
+func (s *InfluxSink) Healthy() error {
+	return s.health.Healthy()
+}
+
+// Run flushes buffered lines every FlushInterval, or sooner when a batch fills up, until the context is cancelled.
+// Remaining lines are flushed on exit.
+func (s *InfluxSink) Run(ctx context.Context) error {
+	ticker := DefaultClock.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(context.Background()); err != nil {
+				s.logger.Errorf("influx: final flush failed: %v", err)
+			}
+			return ctx.Err()
+		case <-ticker.C():
+		case <-s.flush:
+		}
+		if err := s.Flush(ctx); err != nil {
+			s.logger.Errorf("influx: flush failed: %v", err)
+		}
+	}
+}
+
+// Flush writes all buffered lines to InfluxDB. Lines are dropped if the write fails, since live dashboards only
+// care about recent data and the parquet files remain the archival path.
+func (s *InfluxSink) Flush(ctx context.Context) (err error) {
+	defer func() { s.health.record(err) }()
+	s.mu.Lock()
+	if len(s.lines) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	body := strings.Join(s.lines, "\n")
+	s.lines = s.lines[:0]
+	s.mu.Unlock()
+
+	q := url.Values{}
+	q.Set("bucket", s.cfg.Bucket)
+	if s.cfg.Org != "" {
+		q.Set("org", s.cfg.Org)
+	}
+	q.Set("precision", "ns")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(s.cfg.URL, "/")+"/api/v2/write?"+q.Encode(), bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.cfg.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("influx write failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influx write returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// FormatInfluxLine is the pure core of the sink: it maps a record to a single line of InfluxDB line protocol.
+// The second return value is false for record types that are not exported to InfluxDB.
+func FormatInfluxLine(record interface{}, received time.Time) (string, bool, error) {
+	switch r := record.(type) {
+	case BestPrice:
+		bid, err := strconv.ParseFloat(r.BidPrice, 64)
+		if err != nil {
+			return "", false, fmt.Errorf("influx: invalid bid price %q: %w", r.BidPrice, err)
+		}
+		ask, err := strconv.ParseFloat(r.AskPrice, 64)
+		if err != nil {
+			return "", false, fmt.Errorf("influx: invalid ask price %q: %w", r.AskPrice, err)
+		}
+		fields := []string{
+			"bid=" + r.BidPrice,
+			"ask=" + r.AskPrice,
+			"bid_qty=" + r.BidQty,
+			"ask_qty=" + r.AskQty,
+			"mid=" + formatInfluxFloat((bid+ask)/2),
+			"spread=" + formatInfluxFloat(ask-bid),
+			"update_id=" + strconv.FormatInt(r.UpdateID, 10) + "i",
+		}
+		return fmt.Sprintf("best_price,symbol=%s %s %d", escapeInfluxTag(r.Symbol), strings.Join(fields, ","), received.UnixNano()), true, nil
+	default:
+		return "", false, nil
+	}
+}
+
+func formatInfluxFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// escapeInfluxTag escapes commas, equals signs and spaces in tag values as required by the line protocol.
+func escapeInfluxTag(v string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(v)
+}
@@ -0,0 +1,81 @@
+package gobinapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatInfluxLine_BestPrice(t *testing.T) {
+	ts := time.Unix(1700000000, 123)
+	bp := BestPrice{UpdateID: 42, Symbol: "BTCUSDT", BidPrice: "100.0", BidQty: "1.5", AskPrice: "100.5", AskQty: "2"}
+	line, ok, err := FormatInfluxLine(bp, ts)
+	if err != nil || !ok {
+		t.Fatalf("FormatInfluxLine returned ok=%v err=%v", ok, err)
+	}
+	expected := "best_price,symbol=BTCUSDT bid=100.0,ask=100.5,bid_qty=1.5,ask_qty=2,mid=100.25,spread=0.5,update_id=42i 1700000000000000123"
+	if line != expected {
+		t.Errorf("unexpected line:\n got %s\nwant %s", line, expected)
+	}
+
+	if _, ok, _ := FormatInfluxLine(Trade{}, ts); ok {
+		t.Errorf("expected trades not to be exported")
+	}
+	if _, _, err := FormatInfluxLine(BestPrice{BidPrice: "x", AskPrice: "1"}, ts); err == nil {
+		t.Errorf("expected error for non-numeric bid price")
+	}
+}
+
+func TestInfluxSink_FlushPostsBatch(t *testing.T) {
+	var gotBody, gotAuth, gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody, gotAuth, gotQuery = string(body), r.Header.Get("Authorization"), r.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink, err := NewInfluxSink(InfluxConfig{URL: srv.URL, Org: "research", Bucket: "market", Token: "secret"}, srv.Client(), &FakeLogger{})
+	if err != nil {
+		t.Fatalf("NewInfluxSink returned error: %v", err)
+	}
+	for _, sym := range []string{"BTCUSDT", "ETHUSDT"} {
+		if err := sink.Write(BestPrice{Symbol: sym, BidPrice: "1", AskPrice: "2", BidQty: "1", AskQty: "1"}); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush returned error: %v", err)
+	}
+	if lines := strings.Split(gotBody, "\n"); len(lines) != 2 || !strings.Contains(lines[1], "symbol=ETHUSDT") {
+		t.Errorf("unexpected body: %q", gotBody)
+	}
+	if gotAuth != "Token secret" {
+		t.Errorf("unexpected Authorization header: %q", gotAuth)
+	}
+	if !strings.Contains(gotQuery, "bucket=market") || !strings.Contains(gotQuery, "org=research") {
+		t.Errorf("unexpected query: %q", gotQuery)
+	}
+
+	// A second flush with nothing buffered must not hit the server
+	gotBody = ""
+	if err := sink.Flush(context.Background()); err != nil || gotBody != "" {
+		t.Errorf("expected empty flush to be a no-op, got err=%v body=%q", err, gotBody)
+	}
+}
+
+func TestInfluxSink_FlushReportsServerErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	sink, _ := NewInfluxSink(InfluxConfig{URL: srv.URL, Bucket: "missing"}, srv.Client(), &FakeLogger{})
+	sink.Write(BestPrice{BidPrice: "1", AskPrice: "2"})
+	if err := sink.Flush(context.Background()); err == nil || !strings.Contains(err.Error(), "bucket not found") {
+		t.Errorf("expected server error to be reported, got %v", err)
+	}
+}
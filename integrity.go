@@ -0,0 +1,229 @@
+package gobinapi
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IDGap is a break in a file's ID sequence: IDs strictly between After and Before are missing.
+type IDGap struct {
+	After  int64 `json:"after"`
+	Before int64 `json:"before"`
+}
+
+// Missing returns how many IDs the gap spans.
+func (g IDGap) Missing() int64 {
+	return g.Before - g.After - 1
+}
+
+// FileStats summarizes the content of one recorded file for operational spot checks.
+type FileStats struct {
+	File     string `json:"file"`
+	Symbol   string `json:"symbol"`
+	DataType string `json:"data_type"`
+	Rows     int    `json:"rows"`
+	// FirstTime and LastTime are the earliest and latest exchange times, zero for types without one.
+	FirstTime time.Time `json:"first_time"`
+	LastTime  time.Time `json:"last_time"`
+	MinID     int64     `json:"min_id"`
+	MaxID     int64     `json:"max_id"`
+	// Gaps lists breaks in the ID sequence for types whose IDs are contiguous (trades, aggregate trades and order
+	// book diffs). Out-of-order or duplicate IDs are counted separately.
+	Gaps       []IDGap `json:"gaps,omitempty"`
+	OutOfOrder int     `json:"out_of_order"`
+}
+
+// MissingIDs returns the total number of IDs missing across all gaps.
+func (s FileStats) MissingIDs() int64 {
+	var n int64
+	for _, g := range s.Gaps {
+		n += g.Missing()
+	}
+	return n
+}
+
+// ParseFileName is the inverse of BuildFileName and BuildPartFileName: it splits
+// "<instrument>_<dataType>_<YYYY-MM-DD>[_partNNN].parquet" into its parts, ignoring the part number. Paths in a hive
+// layout, "symbol=<instrument>/date=<YYYY-MM-DD>/<dataType>[_partNNN].parquet", are recognised too.
+func ParseFileName(name string) (instrument string, dataType string, date time.Time, err error) {
+	instrument, dataType, date, _, err = ParsePartFileName(name)
+	return instrument, dataType, date, err
+}
+
+// ParsePartFileName is like ParseFileName but also returns the part number, which is 0 for a day's regular file.
+func ParsePartFileName(name string) (instrument string, dataType string, date time.Time, part int, err error) {
+	base := strings.TrimSuffix(filepath.Base(name), ".parquet")
+	if base == filepath.Base(name) {
+		return "", "", time.Time{}, 0, fmt.Errorf("%s is not a recording file name", name)
+	}
+	dateDir := filepath.Dir(name)
+	if symbol, ok := strings.CutPrefix(filepath.Base(filepath.Dir(dateDir)), "symbol="); ok {
+		if day, ok := strings.CutPrefix(filepath.Base(dateDir), "date="); ok {
+			return parseHivePartFileName(name, base, symbol, day)
+		}
+	}
+	parts := strings.Split(base, "_")
+	if len(parts) > 3 && strings.HasPrefix(parts[len(parts)-1], "part") {
+		if part, err = strconv.Atoi(strings.TrimPrefix(parts[len(parts)-1], "part")); err != nil || part <= 0 {
+			return "", "", time.Time{}, 0, fmt.Errorf("%s has a malformed part number", name)
+		}
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) < 3 {
+		return "", "", time.Time{}, 0, fmt.Errorf("%s is not a recording file name", name)
+	}
+	date, err = time.Parse("2006-01-02", parts[len(parts)-1])
+	if err != nil {
+		return "", "", time.Time{}, 0, fmt.Errorf("%s is not a recording file name: %w", name, err)
+	}
+	return strings.Join(parts[:len(parts)-2], "_"), parts[len(parts)-2], date, part, nil
+}
+
+// parseHivePartFileName parses the "<dataType>[_partNNN]" base name of a file in a hive partition directory.
+func parseHivePartFileName(name, base, symbol, day string) (instrument string, dataType string, date time.Time, part int, err error) {
+	date, err = time.Parse("2006-01-02", day)
+	if err != nil || symbol == "" {
+		return "", "", time.Time{}, 0, fmt.Errorf("%s is not in a symbol=/date= partition: %v", name, err)
+	}
+	dataType = base
+	if i := strings.LastIndex(base, "_part"); i > 0 {
+		if part, err = strconv.Atoi(base[i+len("_part"):]); err != nil || part <= 0 {
+			return "", "", time.Time{}, 0, fmt.Errorf("%s has a malformed part number", name)
+		}
+		dataType = base[:i]
+	}
+	return symbol, dataType, date, part, nil
+}
+
+// sequenceIDs returns the first and last ID of a record whose IDs form a contiguous sequence across records, i.e.
+// the next record's first ID should be this record's last ID plus one.
+func sequenceIDs(record interface{}) (first, last int64, ok bool) {
+	switch r := record.(type) {
+	case Trade:
+		return r.TradeID, r.TradeID, true
+	case AggTrade:
+		return r.AggTradeID, r.AggTradeID, true
+	case OrderBookDiff:
+		return r.FirstUpdateID, r.FinalUpdateID, true
+	}
+	return 0, 0, false
+}
+
+// ComputeFileStats is the pure core of the integrity check: it computes row counts, time coverage, ID range and
+// sequence gaps for the records of one file, in file order.
+func ComputeFileStats(records []interface{}) FileStats {
+	var s FileStats
+	s.Rows = len(records)
+	var prevLast int64
+	havePrev := false
+	for _, record := range records {
+		if t, ok := RecordTime(record); ok {
+			if s.FirstTime.IsZero() || t.Before(s.FirstTime) {
+				s.FirstTime = t
+			}
+			if t.After(s.LastTime) {
+				s.LastTime = t
+			}
+		}
+		id, err := RecordKey(record)
+		if err == nil {
+			if s.MinID == 0 || id < s.MinID {
+				s.MinID = id
+			}
+			if id > s.MaxID {
+				s.MaxID = id
+			}
+		}
+		first, last, ok := sequenceIDs(record)
+		if !ok {
+			continue
+		}
+		if havePrev {
+			switch {
+			case first == prevLast+1:
+			case first > prevLast+1:
+				s.Gaps = append(s.Gaps, IDGap{After: prevLast, Before: first})
+			default:
+				s.OutOfOrder++
+				continue
+			}
+		}
+		prevLast = last
+		havePrev = true
+	}
+	return s
+}
+
+// CheckFileIntegrity reads a recording file and computes its FileStats. The symbol and data type are taken from
+// the file name.
+func CheckFileIntegrity(filePath string) (FileStats, error) {
+	symbol, dataType, _, err := ParseFileName(filePath)
+	if err != nil {
+		return FileStats{}, err
+	}
+	records, err := ReadRecordingFile(dataType, filePath)
+	if err != nil {
+		return FileStats{}, err
+	}
+	s := ComputeFileStats(records)
+	s.File, s.Symbol, s.DataType = filePath, symbol, dataType
+	return s, nil
+}
+
+// CheckDirIntegrity runs CheckFileIntegrity on every recording file in dir, including those in hive partition
+// directories below it, optionally restricted to one date (a zero date selects all). Files are returned sorted by
+// path.
+func CheckDirIntegrity(dir string, date time.Time) ([]FileStats, error) {
+	names, err := listRecordingFiles(dir, date)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []FileStats
+	for _, name := range names {
+		s, err := CheckFileIntegrity(filepath.Join(dir, name))
+		if err != nil {
+			return stats, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// listRecordingFiles returns the paths relative to dir of the recording files below it, optionally restricted to one
+// date (a zero date selects all), sorted.
+func listRecordingFiles(dir string, date time.Time) ([]string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	var names []string
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		_, _, fileDate, err := ParseFileName(rel)
+		if err != nil || (!date.IsZero() && !fileDate.Equal(date)) {
+			return nil
+		}
+		names = append(names, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,74 @@
+package gobinapi
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseFileName(t *testing.T) {
+	day := time.Date(2023, 10, 15, 0, 0, 0, 0, time.UTC)
+	symbol, dataType, date, err := ParseFileName("/data/" + BuildFileName("orderBookDiff", "BTCUSDT", day))
+	if err != nil || symbol != "BTCUSDT" || dataType != "orderBookDiff" || !date.Equal(day) {
+		t.Errorf("unexpected parse result %q %q %v %v", symbol, dataType, date, err)
+	}
+	hive := FileLayout{Root: "/data", Hive: true}
+	symbol, dataType, date, part, err := ParsePartFileName(hive.FilePath("snapshotTop", "BTCUSDT", day, 4))
+	if err != nil || symbol != "BTCUSDT" || dataType != "snapshotTop" || !date.Equal(day) || part != 4 {
+		t.Errorf("unexpected hive parse result %q %q %v %d %v", symbol, dataType, date, part, err)
+	}
+	for _, bad := range []string{"journal.txt", "symbol=BTCUSDT/date=notadate/trade.parquet", "session_2023-10-15T12-00-00Z_abc.json", "BTCUSDT_2023-10-15.parquet", "a_b_notadate.parquet"} {
+		if _, _, _, err := ParseFileName(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestComputeFileStats_DetectsGaps(t *testing.T) {
+	base := time.Date(2023, 10, 15, 12, 0, 0, 0, time.UTC)
+	records := []interface{}{
+		OrderBookDiff{FirstUpdateID: 10, FinalUpdateID: 12, EventTime: base.UnixMilli()},
+		OrderBookDiff{FirstUpdateID: 13, FinalUpdateID: 13, EventTime: base.Add(time.Second).UnixMilli()},
+		OrderBookDiff{FirstUpdateID: 17, FinalUpdateID: 20, EventTime: base.Add(2 * time.Second).UnixMilli()},
+		OrderBookDiff{FirstUpdateID: 15, FinalUpdateID: 15, EventTime: base.Add(3 * time.Second).UnixMilli()},
+		OrderBookDiff{FirstUpdateID: 21, FinalUpdateID: 22, EventTime: base.Add(4 * time.Second).UnixMilli()},
+	}
+	s := ComputeFileStats(records)
+	if s.Rows != 5 || s.MinID != 12 || s.MaxID != 22 {
+		t.Errorf("unexpected counts: %+v", s)
+	}
+	if !s.FirstTime.Equal(base) || !s.LastTime.Equal(base.Add(4*time.Second)) {
+		t.Errorf("unexpected coverage %v - %v", s.FirstTime, s.LastTime)
+	}
+	if len(s.Gaps) != 1 || s.Gaps[0] != (IDGap{After: 13, Before: 17}) || s.MissingIDs() != 3 {
+		t.Errorf("expected one gap of 3 IDs after 13, got %+v", s.Gaps)
+	}
+	if s.OutOfOrder != 1 {
+		t.Errorf("expected 1 out-of-order record, got %d", s.OutOfOrder)
+	}
+}
+
+func TestCheckDirIntegrity(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2023, 10, 15, 0, 0, 0, 0, time.UTC)
+	if err := WriteParquetFile(filepath.Join(dir, BuildFileName("trade", "BTCUSDT", day)), []Trade{{TradeID: 1}, {TradeID: 2}, {TradeID: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteParquetFile(filepath.Join(dir, BuildFileName("trade", "BTCUSDT", day.Add(24*time.Hour))), []Trade{{TradeID: 6}}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := CheckDirIntegrity(dir, day)
+	if err != nil {
+		t.Fatalf("CheckDirIntegrity returned error: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected only the requested date, got %d files", len(stats))
+	}
+	if s := stats[0]; s.Symbol != "BTCUSDT" || s.DataType != "trade" || s.Rows != 3 || s.MissingIDs() != 2 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+	if all, _ := CheckDirIntegrity(dir, time.Time{}); len(all) != 2 {
+		t.Errorf("expected 2 files without a date filter, got %d", len(all))
+	}
+}
@@ -0,0 +1,150 @@
+package mockbinance
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// A replay archive is a JSON-lines file in which every line is either a WebSocket frame wrapped in Binance's
+// combined-stream envelope, {"stream":"btcusdt@depth","data":{...}}, or a REST depth snapshot response,
+// {"snapshot":"BTCUSDT","data":{...}}, in the order in which they were originally received.
+//
+// Replaying preserves that order across endpoints where it matters for the pipeline: a frame that was recorded after
+// a snapshot response is held back until the mock server has served that snapshot (plus a short settle delay for
+// the client to process it), so listener → gap logic → recorder runs produce the same output every time.
+
+// DefaultSnapshotSettle is the default delay between serving a snapshot and releasing the frames recorded after it.
+const DefaultSnapshotSettle = 50 * time.Millisecond
+
+// ArchiveEntry is one line of a replay archive. Exactly one of Stream and Snapshot is set.
+type ArchiveEntry struct {
+	Stream   string          `json:"stream,omitempty"`
+	Snapshot string          `json:"snapshot,omitempty"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// ReadArchive parses a replay archive.
+func ReadArchive(r io.Reader) ([]ArchiveEntry, error) {
+	var entries []ArchiveEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var entry ArchiveEntry
+		if err := json.Unmarshal([]byte(text), &entry); err != nil {
+			return nil, fmt.Errorf("archive line %d: %w", line, err)
+		}
+		if (entry.Stream == "") == (entry.Snapshot == "") {
+			return nil, fmt.Errorf("archive line %d: exactly one of stream and snapshot must be set", line)
+		}
+		if len(entry.Data) == 0 {
+			return nil, fmt.Errorf("archive line %d: missing data", line)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return entries, nil
+}
+
+// LoadArchive reads the replay archive at filePath.
+func LoadArchive(filePath string) ([]ArchiveEntry, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", filePath, err)
+	}
+	defer f.Close()
+	entries, err := ReadArchive(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return entries, nil
+}
+
+// WriteArchive writes entries in the replay archive format, one JSON object per line.
+func WriteArchive(w io.Writer, entries []ArchiveEntry) error {
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("failed to write archive entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// NewReplayServer starts a mock server that replays the archive at filePath. Connections are closed once each
+// stream's frames have been sent, so listeners return and a test knows the replay is complete.
+func NewReplayServer(filePath string) (*Server, error) {
+	entries, err := LoadArchive(filePath)
+	if err != nil {
+		return nil, err
+	}
+	s := NewServer()
+	s.Replay(entries)
+	s.SetCloseAfterFrames(true)
+	return s, nil
+}
+
+// Replay replaces the server's streams and snapshots with the contents of an archive. Each stream's frames are sent
+// in archive order; each frame is released only after every snapshot that precedes it in the archive has been
+// served. Snapshot responses are served per symbol in archive order.
+func (s *Server) Replay(entries []ArchiveEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams = make(map[string][][]byte)
+	s.gates = make(map[string][]int)
+	s.snapshots = make(map[string][][]byte)
+	s.servedAt = nil
+	if s.settle == 0 {
+		s.settle = DefaultSnapshotSettle
+	}
+	snapshots := 0
+	for _, entry := range entries {
+		if entry.Snapshot != "" {
+			symbol := strings.ToUpper(entry.Snapshot)
+			s.snapshots[symbol] = append(s.snapshots[symbol], []byte(entry.Data))
+			snapshots++
+			continue
+		}
+		s.streams[entry.Stream] = append(s.streams[entry.Stream], []byte(entry.Data))
+		s.gates[entry.Stream] = append(s.gates[entry.Stream], snapshots)
+	}
+}
+
+// SetSnapshotSettle sets how long frames gated on a snapshot are held after that snapshot has been served.
+func (s *Server) SetSnapshotSettle(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settle = d
+}
+
+// waitForSnapshots blocks until at least n snapshots have been served and the settle delay has passed since the
+// n-th one. It returns false if the client went away first.
+func (s *Server) waitForSnapshots(r *http.Request, n int) bool {
+	for n > 0 {
+		s.mu.Lock()
+		ready := len(s.servedAt) >= n && time.Since(s.servedAt[n-1]) >= s.settle
+		s.mu.Unlock()
+		if ready {
+			break
+		}
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return true
+}
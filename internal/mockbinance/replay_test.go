@@ -0,0 +1,80 @@
+package mockbinance
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	entries := []ArchiveEntry{
+		{Snapshot: "BTCUSDT", Data: SnapshotMessage(100, nil, nil)},
+		{Stream: "btcusdt@depth", Data: DepthUpdateMessage("BTCUSDT", 101, 101, nil, nil)},
+	}
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, entries); err != nil {
+		t.Fatalf("WriteArchive returned error: %v", err)
+	}
+	read, err := ReadArchive(&buf)
+	if err != nil {
+		t.Fatalf("ReadArchive returned error: %v", err)
+	}
+	if len(read) != 2 || read[0].Snapshot != "BTCUSDT" || read[1].Stream != "btcusdt@depth" ||
+		!bytes.Equal(read[1].Data, entries[1].Data) {
+		t.Errorf("unexpected entries: %+v", read)
+	}
+
+	if _, err := ReadArchive(strings.NewReader(`{"stream":"a","snapshot":"B","data":{}}`)); err == nil {
+		t.Errorf("expected an entry with both stream and snapshot to be rejected")
+	}
+	if _, err := ReadArchive(strings.NewReader(`{"stream":"a"}`)); err == nil {
+		t.Errorf("expected an entry without data to be rejected")
+	}
+}
+
+// TestReplay_HoldsFramesUntilSnapshotServed checks that frames recorded after a snapshot are not sent before that
+// snapshot has been served, and that queued snapshots are served in archive order.
+func TestReplay_HoldsFramesUntilSnapshotServed(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.Replay([]ArchiveEntry{
+		{Stream: "btcusdt@depth", Data: DepthUpdateMessage("BTCUSDT", 1, 1, nil, nil)},
+		{Snapshot: "BTCUSDT", Data: SnapshotMessage(10, nil, nil)},
+		{Stream: "btcusdt@depth", Data: DepthUpdateMessage("BTCUSDT", 11, 11, nil, nil)},
+		{Snapshot: "BTCUSDT", Data: SnapshotMessage(20, nil, nil)},
+	})
+	srv.SetSnapshotSettle(time.Millisecond)
+
+	conn, _, err := websocket.DefaultDialer.Dial(srv.WSURL()+"/ws/btcusdt@depth", nil)
+	if err != nil {
+		t.Fatalf("failed to dial replay server: %v", err)
+	}
+	defer conn.Close()
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("expected the first frame immediately: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, msg, err := conn.ReadMessage(); err == nil {
+		t.Fatalf("frame %s was released before its snapshot was served", msg)
+	}
+
+	fetch := func() string {
+		resp, err := http.Get(srv.URL() + "/api/v3/depth?symbol=BTCUSDT")
+		if err != nil {
+			t.Fatalf("failed to fetch snapshot: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	for _, want := range []string{`"lastUpdateId":10`, `"lastUpdateId":20`, `"lastUpdateId":20`} {
+		if body := fetch(); !strings.Contains(body, want) {
+			t.Errorf("expected snapshot containing %s, got %s", want, body)
+		}
+	}
+}
@@ -0,0 +1,690 @@
+// Package mockbinance implements a local stand-in for the Binance market data endpoints, so the listeners and
+// pipelines can be tested deterministically without hitting the live exchange. It serves canned WebSocket frames per
+// stream (e.g. "btcusdt@trade") on /ws/<stream>, or wrapped in the combined stream envelope on
+// /stream?streams=<stream>/<stream>, and canned REST depth snapshots on /api/v3/depth, or on
+// /fapi/v1/depth for USD-M futures, which share the canned snapshots and weight accounting, and canned exchange info
+// (see SetExchangeInfo). It hands out ListenKey on /api/v3/userDataStream, so the user data stream is served on
+// /ws/<ListenKey> like any other stream. It can also replay a recorded archive (see Replay and NewReplayServer) for
+// end-to-end regression tests. Connections can be pinged (see SetPingInterval), left without pong replies (see SetIgnorePings)
+// and dropped at any time (see Disconnect), to exercise the clients' liveness and reconnect handling.
+//
+// Typical use from a test in the root package:
+//
+//	srv := mockbinance.NewServer()
+//	defer srv.Close()
+//	srv.SetStream("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 1, "100.0", "0.5"))
+//	StreamBaseURL, RESTBaseURL = srv.WSURL(), srv.URL()
+package mockbinance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Server is a mock Binance market data server backed by httptest.Server.
+type Server struct {
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu           sync.Mutex
+	streams      map[string][][]byte
+	gates        map[string][]int
+	snapshots    map[string][][]byte
+	exchange     []byte
+	servedAt     []time.Time
+	settle       time.Duration
+	interval     time.Duration
+	closeAfter   bool
+	connections  map[string]int
+	snapshotReq  map[string]int
+	listenKeyReq map[string]int
+	usedWeight   int
+	ignoreReqs   bool
+	pingEvery    time.Duration
+	ignorePings  bool
+	pongs        map[string]int
+	live         map[string]map[*liveConn]bool
+}
+
+// liveConn is an open stream connection that published frames are written to.
+type liveConn struct {
+	mu       sync.Mutex
+	conn     *websocket.Conn
+	combined bool
+}
+
+// write sends a frame of stream, wrapped in the combined stream envelope on combined stream connections.
+func (lc *liveConn) write(stream string, frame []byte) error {
+	if lc.combined {
+		frame = CombinedMessage(stream, frame)
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.conn.WriteMessage(websocket.TextMessage, frame)
+}
+
+// NewServer starts a mock server listening on a random local port.
+func NewServer() *Server {
+	s := &Server{
+		streams:      make(map[string][][]byte),
+		gates:        make(map[string][]int),
+		snapshots:    make(map[string][][]byte),
+		connections:  make(map[string]int),
+		snapshotReq:  make(map[string]int),
+		listenKeyReq: make(map[string]int),
+		pongs:        make(map[string]int),
+		live:         make(map[string]map[*liveConn]bool),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/", s.handleStream)
+	mux.HandleFunc("/ws", s.handleSubscribe)
+	mux.HandleFunc("/stream", s.handleCombined)
+	mux.HandleFunc("/api/v3/depth", s.handleDepth)
+	mux.HandleFunc("/fapi/v1/depth", s.handleDepth)
+	mux.HandleFunc("/api/v3/exchangeInfo", s.handleExchangeInfo)
+	mux.HandleFunc("/fapi/v1/exchangeInfo", s.handleExchangeInfo)
+	mux.HandleFunc("/api/v3/ping", handlePing)
+	mux.HandleFunc("/api/v3/userDataStream", s.handleUserDataStream)
+	mux.HandleFunc("/fapi/v1/ping", handlePing)
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+// URL returns the HTTP base URL of the server, suitable for RESTBaseURL.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// WSURL returns the WebSocket base URL of the server, suitable for StreamBaseURL.
+func (s *Server) WSURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+// Close shuts the server down, closing any open connections.
+func (s *Server) Close() {
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// SetStream sets the frames sent, in order, to every client connecting to the given stream.
+func (s *Server) SetStream(stream string, frames ...[]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams[stream] = frames
+	delete(s.gates, stream)
+}
+
+// SetSnapshot sets the JSON body returned by /api/v3/depth for symbol (see SnapshotMessage).
+func (s *Server) SetSnapshot(symbol string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[strings.ToUpper(symbol)] = [][]byte{body}
+}
+
+// SetExchangeInfo sets the JSON body returned by /api/v3/exchangeInfo (see ExchangeInfoMessage). Until it is set,
+// exchange info requests are answered 404.
+func (s *Server) SetExchangeInfo(body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exchange = body
+}
+
+// SetFrameInterval sets the delay between frames sent to a client. The default is to send frames back to back.
+func (s *Server) SetFrameInterval(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = d
+}
+
+// SetCloseAfterFrames makes the server close each connection with a "going away" close frame once its canned
+// frames have been sent, simulating an exchange-side disconnect. By default connections are held open.
+func (s *Server) SetCloseAfterFrames(closeAfter bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeAfter = closeAfter
+}
+
+// SetIgnoreRequests makes the server leave SUBSCRIBE requests on the raw /ws endpoint unanswered, simulating lost
+// acknowledgements.
+func (s *Server) SetIgnoreRequests(ignore bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignoreReqs = ignore
+}
+
+// SetPingInterval makes the server send a ping frame every d on each stream connection, as the exchange does, and
+// count the pongs received (see Pongs). By default the server does not ping.
+func (s *Server) SetPingInterval(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pingEvery = d
+}
+
+// SetIgnorePings makes the server leave the pings of stream connections unanswered, simulating a connection that
+// stalled without closing.
+func (s *Server) SetIgnorePings(ignore bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignorePings = ignore
+}
+
+// Pongs returns how many pongs connections to stream have sent in reply to the server's pings.
+func (s *Server) Pongs(stream string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pongs[stream]
+}
+
+// Disconnect drops every open connection receiving published frames of stream without a close frame, simulating a
+// network failure, and returns how many were dropped.
+func (s *Server) Disconnect(stream string) int {
+	s.mu.Lock()
+	conns := make([]*liveConn, 0, len(s.live[stream]))
+	for lc := range s.live[stream] {
+		conns = append(conns, lc)
+	}
+	s.mu.Unlock()
+	for _, lc := range conns {
+		lc.conn.Close()
+	}
+	return len(conns)
+}
+
+// Connections returns how many clients have connected to the given stream.
+func (s *Server) Connections(stream string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections[stream]
+}
+
+// SnapshotRequests returns how many depth snapshots have been requested for symbol.
+func (s *Server) SnapshotRequests(symbol string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshotReq[strings.ToUpper(symbol)]
+}
+
+// ListenKeyRequests returns how many listen key requests of method (POST to create, PUT to keep alive and DELETE
+// to close) the server has answered.
+func (s *Server) ListenKeyRequests(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listenKeyReq[method]
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	s.serveStreams(w, r, []string{strings.TrimPrefix(r.URL.Path, "/ws/")}, false)
+}
+
+// handleCombined serves /stream?streams=a/b, sending the frames of every stream wrapped as
+// {"stream":"a","data":{...}}, the canned frames of each stream in turn.
+func (s *Server) handleCombined(w http.ResponseWriter, r *http.Request) {
+	streams := strings.Split(r.URL.Query().Get("streams"), "/")
+	s.serveStreams(w, r, streams, true)
+}
+
+// serveStreams sends the canned frames of streams to a new connection, then either closes it or holds it open,
+// passing on published frames, until the client goes away.
+func (s *Server) serveStreams(w http.ResponseWriter, r *http.Request, streams []string, combined bool) {
+	s.mu.Lock()
+	frames := make([][][]byte, len(streams))
+	gates := make([][]int, len(streams))
+	for i, stream := range streams {
+		f, ok := s.streams[stream]
+		if !ok {
+			s.mu.Unlock()
+			http.Error(w, fmt.Sprintf("unknown stream %q", stream), http.StatusNotFound)
+			return
+		}
+		frames[i], gates[i] = f, s.gates[stream]
+	}
+	for _, stream := range streams {
+		s.connections[stream]++
+	}
+	interval, closeAfter := s.interval, s.closeAfter
+	pingEvery, ignorePings := s.pingEvery, s.ignorePings
+	s.mu.Unlock()
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if ignorePings {
+		conn.SetPingHandler(func(string) error { return nil })
+	}
+	conn.SetPongHandler(func(string) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, stream := range streams {
+			s.pongs[stream]++
+		}
+		return nil
+	})
+	// Read from the start, so control frames are handled while the canned frames are written
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	if pingEvery > 0 {
+		go pingConn(conn, pingEvery, done)
+	}
+
+	lc := &liveConn{conn: conn, combined: combined}
+	for i, stream := range streams {
+		for j, frame := range frames[i] {
+			if j < len(gates[i]) && !s.waitForSnapshots(r, gates[i][j]) {
+				return
+			}
+			if err := lc.write(stream, frame); err != nil {
+				return
+			}
+			if interval > 0 {
+				time.Sleep(interval)
+			}
+		}
+	}
+	if closeAfter {
+		msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "mock server closing")
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		return
+	}
+	// Hold the connection open until the client goes away, passing on published frames
+	s.mu.Lock()
+	for _, stream := range streams {
+		if s.live[stream] == nil {
+			s.live[stream] = make(map[*liveConn]bool)
+		}
+		s.live[stream][lc] = true
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		for _, stream := range streams {
+			delete(s.live[stream], lc)
+		}
+		s.mu.Unlock()
+	}()
+	<-done
+}
+
+// pingConn sends a ping frame on conn every interval until done is closed or a ping fails.
+func pingConn(conn *websocket.Conn, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Subscribers returns how many connections to stream are currently open and receiving published frames.
+func (s *Server) Subscribers(stream string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.live[stream])
+}
+
+// Publish writes frame to every connection currently open on stream (after its canned frames), like a live feed
+// that connections only see from the moment they joined.
+func (s *Server) Publish(stream string, frame []byte) {
+	s.mu.Lock()
+	conns := make([]*liveConn, 0, len(s.live[stream]))
+	for lc := range s.live[stream] {
+		conns = append(conns, lc)
+	}
+	s.mu.Unlock()
+	for _, lc := range conns {
+		lc.write(stream, frame)
+	}
+}
+
+// handleSubscribe serves the raw /ws endpoint, where clients choose streams with SUBSCRIBE and UNSUBSCRIBE requests.
+// Each request is acknowledged with {"result":null,"id":N}; a SUBSCRIBE is followed by the canned frames of its
+// streams, after which the connection receives their published frames until it unsubscribes. A request naming an
+// unknown stream is answered with an error payload instead.
+func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	lc := &liveConn{conn: conn}
+	defer func() {
+		s.mu.Lock()
+		for _, conns := range s.live {
+			delete(conns, lc)
+		}
+		s.mu.Unlock()
+	}()
+
+	for {
+		var req struct {
+			Method string   `json:"method"`
+			Params []string `json:"params"`
+			ID     int64    `json:"id"`
+		}
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		s.mu.Lock()
+		ignore := s.ignoreReqs
+		var frames [][]byte
+		var unknown string
+		for _, stream := range req.Params {
+			f, ok := s.streams[stream]
+			if !ok {
+				unknown = stream
+				break
+			}
+			if req.Method == "SUBSCRIBE" {
+				s.connections[stream]++
+				frames = append(frames, f...)
+			}
+		}
+		s.mu.Unlock()
+		if ignore {
+			continue
+		}
+
+		var reply []byte
+		switch {
+		case req.Method != "SUBSCRIBE" && req.Method != "UNSUBSCRIBE":
+			reply = mustJSON(map[string]interface{}{"error": map[string]interface{}{"code": 2, "msg": "Invalid request: unknown method " + req.Method}, "id": req.ID})
+			frames = nil
+		case unknown != "":
+			reply = mustJSON(map[string]interface{}{"error": map[string]interface{}{"code": 2, "msg": "Invalid request: unknown stream " + unknown}, "id": req.ID})
+			frames = nil
+		default:
+			reply = mustJSON(map[string]interface{}{"result": nil, "id": req.ID})
+		}
+		// The subscriptions change before the reply, and published frames wait until the canned ones are written
+		lc.mu.Lock()
+		if unknown == "" {
+			s.mu.Lock()
+			for _, stream := range req.Params {
+				switch req.Method {
+				case "SUBSCRIBE":
+					if s.live[stream] == nil {
+						s.live[stream] = make(map[*liveConn]bool)
+					}
+					s.live[stream][lc] = true
+				case "UNSUBSCRIBE":
+					delete(s.live[stream], lc)
+				}
+			}
+			s.mu.Unlock()
+		}
+		err := conn.WriteMessage(websocket.TextMessage, reply)
+		for _, frame := range frames {
+			if err == nil {
+				err = conn.WriteMessage(websocket.TextMessage, frame)
+			}
+		}
+		lc.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handleDepth(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.ToUpper(r.URL.Query().Get("symbol"))
+	s.mu.Lock()
+	queue := s.snapshots[symbol]
+	s.snapshotReq[symbol]++
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	s.usedWeight += depthWeight(limit)
+	usedWeight := s.usedWeight
+	var body []byte
+	if len(queue) > 0 {
+		// Serve queued snapshots in order, repeating the last one once the queue is exhausted
+		body = queue[0]
+		if len(queue) > 1 {
+			s.snapshots[symbol] = queue[1:]
+		}
+		s.servedAt = append(s.servedAt, time.Now())
+	}
+	s.mu.Unlock()
+	w.Header().Set("X-MBX-USED-WEIGHT-1M", strconv.Itoa(usedWeight))
+	if body == nil {
+		http.Error(w, `{"code":-1121,"msg":"Invalid symbol."}`, http.StatusBadRequest)
+		return
+	}
+	if limit > 0 {
+		body = truncateDepth(body, limit)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func (s *Server) handleExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	body := s.exchange
+	s.mu.Unlock()
+	if body == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// ListenKey is the listen key the server hands out; set the frames of the user data stream with SetStream(ListenKey).
+const ListenKey = "pqia91ma19a5s61cv6a81va65sdf19v8a65a1a5s61cv6a81va65sdf19v8a65a1"
+
+// handleUserDataStream answers listen key requests like the exchange, which requires an API key header on them.
+func (s *Server) handleUserDataStream(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-MBX-APIKEY") == "" {
+		http.Error(w, `{"code":-2014,"msg":"API-key format invalid."}`, http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost && r.URL.Query().Get("listenKey") != ListenKey {
+		http.Error(w, `{"code":-1125,"msg":"This listenKey does not exist."}`, http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.listenKeyReq[r.Method]++
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		w.Write(mustJSON(map[string]string{"listenKey": ListenKey}))
+		return
+	}
+	w.Write([]byte("{}"))
+}
+
+// truncateDepth keeps the best limit levels per side of a snapshot body, as the exchange does. Bodies that are not
+// snapshots are returned unchanged.
+func truncateDepth(body []byte, limit int) []byte {
+	var snap map[string]json.RawMessage
+	if err := json.Unmarshal(body, &snap); err != nil {
+		return body
+	}
+	for _, side := range []string{"bids", "asks"} {
+		var levels []json.RawMessage
+		if err := json.Unmarshal(snap[side], &levels); err != nil {
+			return body
+		}
+		if len(levels) > limit {
+			snap[side] = mustJSON(levels[:limit])
+		}
+	}
+	return mustJSON(snap)
+}
+
+// depthWeight mirrors the exchange's request weight of a depth snapshot by limit.
+func depthWeight(limit int) int {
+	switch {
+	case limit <= 100:
+		return 5
+	case limit <= 500:
+		return 25
+	case limit <= 1000:
+		return 50
+	default:
+		return 250
+	}
+}
+
+// UsedWeight returns the total request weight of the depth snapshots served so far, which is also reported to
+// clients in the X-MBX-USED-WEIGHT-1M header. Unlike the exchange, the mock never resets it.
+func (s *Server) UsedWeight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usedWeight
+}
+
+// Level is a price level in canned depth messages.
+type Level [2]string
+
+func mustJSON(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// TradeMessage returns a canned trade event frame.
+func TradeMessage(symbol string, tradeID int64, price, qty string) []byte {
+	return mustJSON(map[string]interface{}{
+		"e": "trade", "E": 1700000000000 + tradeID, "s": symbol, "t": tradeID, "p": price, "q": qty,
+		"b": tradeID * 10, "a": tradeID*10 + 1, "T": 1700000000000 + tradeID, "m": tradeID%2 == 0, "M": true,
+	})
+}
+
+// AggTradeMessage returns a canned aggregate trade event frame.
+func AggTradeMessage(symbol string, aggID int64, price, qty string) []byte {
+	return mustJSON(map[string]interface{}{
+		"e": "aggTrade", "E": 1700000000000 + aggID, "s": symbol, "a": aggID, "p": price, "q": qty,
+		"f": aggID * 10, "l": aggID*10 + 2, "T": 1700000000000 + aggID, "m": aggID%2 == 0, "M": true,
+	})
+}
+
+// DepthUpdateMessage returns a canned diff depth event frame covering update IDs first..final.
+func DepthUpdateMessage(symbol string, first, final int64, bids, asks []Level) []byte {
+	if bids == nil {
+		bids = []Level{}
+	}
+	if asks == nil {
+		asks = []Level{}
+	}
+	return mustJSON(map[string]interface{}{
+		"e": "depthUpdate", "E": 1700000000000 + final, "s": symbol, "U": first, "u": final, "b": bids, "a": asks,
+	})
+}
+
+// FuturesDepthUpdateMessage returns a canned USD-M futures diff depth event frame covering update IDs first..final,
+// whose previous event ended at update ID prev.
+func FuturesDepthUpdateMessage(symbol string, first, final, prev int64, bids, asks []Level) []byte {
+	if bids == nil {
+		bids = []Level{}
+	}
+	if asks == nil {
+		asks = []Level{}
+	}
+	return mustJSON(map[string]interface{}{
+		"e": "depthUpdate", "E": 1700000000000 + final, "T": 1700000000000 + final, "s": symbol,
+		"U": first, "u": final, "pu": prev, "b": bids, "a": asks,
+	})
+}
+
+// MarkPriceMessage returns a canned USD-M futures mark price frame, the seq-th of a once-per-second stream.
+func MarkPriceMessage(symbol string, seq int64, markPrice, fundingRate string) []byte {
+	return mustJSON(map[string]interface{}{
+		"e": "markPriceUpdate", "E": 1700000000000 + seq*1000, "s": symbol, "p": markPrice, "i": markPrice,
+		"P": markPrice, "r": fundingRate, "T": 1700006400000,
+	})
+}
+
+// AvgPriceMessage returns a canned spot average price frame, the seq-th of a once-per-second stream.
+func AvgPriceMessage(symbol string, seq int64, price string) []byte {
+	return mustJSON(map[string]interface{}{
+		"e": "avgPrice", "E": 1700000000000 + seq*1000, "s": symbol, "i": "5m", "w": price, "T": 1700000000000 + seq*1000 - 1,
+	})
+}
+
+// BookTickerMessage returns a canned book ticker frame.
+func BookTickerMessage(symbol string, updateID int64, bid, bidQty, ask, askQty string) []byte {
+	return mustJSON(map[string]interface{}{
+		"u": updateID, "s": symbol, "b": bid, "B": bidQty, "a": ask, "A": askQty,
+	})
+}
+
+// CombinedMessage wraps frame of stream in the envelope of the combined stream endpoint.
+func CombinedMessage(stream string, frame []byte) []byte {
+	return mustJSON(map[string]interface{}{"stream": stream, "data": json.RawMessage(frame)})
+}
+
+// SnapshotMessage returns a canned /api/v3/depth response body.
+func SnapshotMessage(lastUpdateID int64, bids, asks []Level) []byte {
+	if bids == nil {
+		bids = []Level{}
+	}
+	if asks == nil {
+		asks = []Level{}
+	}
+	return mustJSON(map[string]interface{}{"lastUpdateId": lastUpdateID, "bids": bids, "asks": asks})
+}
+
+// ExchangeInfoMessage returns an exchange info body listing each symbol with its status, e.g. "TRADING" or "BREAK",
+// and price and lot size filters.
+func ExchangeInfoMessage(statuses map[string]string) []byte {
+	symbols := make([]map[string]interface{}, 0, len(statuses))
+	for symbol, status := range statuses {
+		symbols = append(symbols, map[string]interface{}{
+			"symbol":     symbol,
+			"status":     status,
+			"baseAsset":  strings.TrimSuffix(symbol, "USDT"),
+			"quoteAsset": "USDT",
+			"filters": []map[string]string{
+				{"filterType": "PRICE_FILTER", "minPrice": "0.01", "maxPrice": "1000000.00", "tickSize": "0.01"},
+				{"filterType": "LOT_SIZE", "minQty": "0.00001", "maxQty": "9000.00000", "stepSize": "0.00001"},
+				{"filterType": "NOTIONAL", "minNotional": "5.00", "maxNotional": "9000000.00"},
+			},
+		})
+	}
+	return mustJSON(map[string]interface{}{"timezone": "UTC", "symbols": symbols})
+}
+
+// DepthSequence returns n consecutive single-update depth frames starting at update ID start. If gapAt is
+// positive, the update ID gapAt is skipped, simulating a lost message that must trigger a resync.
+func DepthSequence(symbol string, start int64, n int, gapAt int64) [][]byte {
+	frames := make([][]byte, 0, n)
+	id := start
+	for len(frames) < n {
+		if id == gapAt {
+			id++
+			continue
+		}
+		frames = append(frames, DepthUpdateMessage(symbol, id, id, []Level{{"100.00", "1.0"}}, []Level{{"100.10", "2.0"}}))
+		id++
+	}
+	return frames
+}
+
+// handlePing answers connectivity checks like the exchange, with an empty object.
+func handlePing(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("{}"))
+}
@@ -0,0 +1,190 @@
+package mockbinance
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestServer_ServesCannedFramesAndSnapshots(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetStream("btcusdt@trade", TradeMessage("BTCUSDT", 1, "100.0", "0.5"), TradeMessage("BTCUSDT", 2, "100.1", "0.1"))
+	srv.SetSnapshot("BTCUSDT", SnapshotMessage(42, []Level{{"99.9", "1"}}, nil))
+	srv.SetCloseAfterFrames(true)
+
+	conn, _, err := websocket.DefaultDialer.Dial(srv.WSURL()+"/ws/btcusdt@trade", nil)
+	if err != nil {
+		t.Fatalf("failed to dial mock server: %v", err)
+	}
+	defer conn.Close()
+	for want := int64(1); want <= 2; want++ {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read frame %d: %v", want, err)
+		}
+		var trade struct {
+			ID int64 `json:"t"`
+		}
+		if err := json.Unmarshal(msg, &trade); err != nil || trade.ID != want {
+			t.Fatalf("unexpected frame %s (err %v)", msg, err)
+		}
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected going-away close after canned frames, got %v", err)
+	}
+	if srv.Connections("btcusdt@trade") != 1 {
+		t.Errorf("expected 1 connection, got %d", srv.Connections("btcusdt@trade"))
+	}
+
+	resp, err := http.Get(srv.URL() + "/api/v3/depth?symbol=BTCUSDT&limit=100")
+	if err != nil {
+		t.Fatalf("failed to fetch snapshot: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `{"asks":[],"bids":[["99.9","1"]],"lastUpdateId":42}` {
+		t.Errorf("unexpected snapshot response %d: %s", resp.StatusCode, body)
+	}
+	if srv.SnapshotRequests("btcusdt") != 1 {
+		t.Errorf("expected 1 snapshot request, got %d", srv.SnapshotRequests("btcusdt"))
+	}
+}
+
+func TestDepthSequence_SkipsGap(t *testing.T) {
+	frames := DepthSequence("BTCUSDT", 101, 4, 103)
+	var ids []int64
+	for _, f := range frames {
+		var d struct {
+			U int64 `json:"U"`
+		}
+		json.Unmarshal(f, &d)
+		ids = append(ids, d.U)
+	}
+	if len(ids) != 4 || ids[0] != 101 || ids[1] != 102 || ids[2] != 104 || ids[3] != 105 {
+		t.Errorf("unexpected update IDs %v", ids)
+	}
+}
+
+func TestServer_CombinedStreamsWrapFrames(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetStream("btcusdt@trade", TradeMessage("BTCUSDT", 1, "100.0", "0.5"))
+	srv.SetStream("btcusdt@bookTicker", BookTickerMessage("BTCUSDT", 7, "99.9", "1", "100.1", "2"))
+
+	conn, _, err := websocket.DefaultDialer.Dial(srv.WSURL()+"/stream?streams=btcusdt@trade/btcusdt@bookTicker", nil)
+	if err != nil {
+		t.Fatalf("failed to dial combined stream: %v", err)
+	}
+	defer conn.Close()
+	for deadline := time.Now().Add(2 * time.Second); srv.Subscribers("btcusdt@bookTicker") < 1; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the combined connection to go live")
+		}
+	}
+	srv.Publish("btcusdt@trade", TradeMessage("BTCUSDT", 2, "100.1", "0.1"))
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read frame %d: %v", i, err)
+		}
+		var envelope struct {
+			Stream string          `json:"stream"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(msg, &envelope); err != nil || len(envelope.Data) == 0 {
+			t.Fatalf("expected a combined stream envelope, got %s (err %v)", msg, err)
+		}
+		got = append(got, envelope.Stream)
+	}
+	if want := []string{"btcusdt@trade", "btcusdt@bookTicker", "btcusdt@trade"}; !slices.Equal(got, want) {
+		t.Errorf("expected frames of %v, got %v", want, got)
+	}
+	if srv.Connections("btcusdt@trade") != 1 || srv.Connections("btcusdt@bookTicker") != 1 {
+		t.Errorf("expected the combined connection to count for both streams")
+	}
+
+	resp, err := http.Get(srv.URL() + "/stream?streams=btcusdt@trade/ethusdt@trade")
+	if err != nil {
+		t.Fatalf("failed to request unknown stream: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown stream, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_PingsAndForcedDisconnects(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetStream("btcusdt@trade")
+	srv.SetPingInterval(5 * time.Millisecond)
+
+	conn, _, err := websocket.DefaultDialer.Dial(srv.WSURL()+"/ws/btcusdt@trade", nil)
+	if err != nil {
+		t.Fatalf("failed to dial mock server: %v", err)
+	}
+	defer conn.Close()
+	// The default ping handler answers with pongs while the client reads
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+	for deadline := time.Now().Add(2 * time.Second); srv.Pongs("btcusdt@trade") < 2 || srv.Subscribers("btcusdt@trade") < 1; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for pongs, got %d", srv.Pongs("btcusdt@trade"))
+		}
+	}
+
+	if n := srv.Disconnect("btcusdt@trade"); n != 1 {
+		t.Fatalf("expected 1 connection dropped, got %d", n)
+	}
+	select {
+	case err := <-readErr:
+		if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+			t.Errorf("expected the connection to drop without a close frame, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the connection to drop")
+	}
+}
+
+func TestServer_IgnorePingsLeavesClientPingsUnanswered(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetStream("btcusdt@trade")
+	srv.SetIgnorePings(true)
+
+	conn, _, err := websocket.DefaultDialer.Dial(srv.WSURL()+"/ws/btcusdt@trade", nil)
+	if err != nil {
+		t.Fatalf("failed to dial mock server: %v", err)
+	}
+	defer conn.Close()
+	pongs := make(chan struct{}, 1)
+	conn.SetPongHandler(func(string) error {
+		pongs <- struct{}{}
+		return nil
+	})
+	if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("failed to ping: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	conn.ReadMessage()
+	select {
+	case <-pongs:
+		t.Error("expected the ping to be left unanswered")
+	default:
+	}
+}
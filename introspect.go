@@ -0,0 +1,127 @@
+package gobinapi
+
+import (
+	"expvar"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Introspection is a point-in-time view of the recorder's internal state, published through expvar as "gobinapi"
+// so operators can inspect a running recorder on /debug/vars without a metrics stack.
+type Introspection struct {
+	Time       time.Time           `json:"time"`
+	Goroutines int                 `json:"goroutines"`
+	Streams    []StreamState       `json:"streams"`
+	Channels   []ChannelState      `json:"channels"`
+	Recorders  []RecorderStats     `json:"recorders"`
+	LastErrors []IntrospectedError `json:"last_errors"`
+}
+
+// StreamState is a WebSocket stream's lifecycle metrics plus whether it is currently connected.
+type StreamState struct {
+	ConnStats
+	Active bool `json:"active"`
+}
+
+// ChannelState is the occupancy of one registered pipeline channel (see registerChannelOccupancy).
+type ChannelState struct {
+	Symbol    string `json:"symbol"`
+	Stream    string `json:"stream"`
+	Capacity  int    `json:"capacity"`
+	Occupancy int    `json:"occupancy"`
+}
+
+// IntrospectedError is an error message logged through a Logger.
+type IntrospectedError struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// maxIntrospectedErrors is how many of the most recent logged errors Introspect reports.
+const maxIntrospectedErrors = 20
+
+var introspection = struct {
+	mu        sync.Mutex
+	channels  map[string]*channelGauge
+	recorders map[fileRecorder]struct{}
+	errors    []IntrospectedError
+}{
+	channels:  make(map[string]*channelGauge),
+	recorders: make(map[fileRecorder]struct{}),
+}
+
+type channelGauge struct {
+	symbol, stream string
+	capacity       int
+	occupancy      func() int
+}
+
+func init() {
+	expvar.Publish("gobinapi", expvar.Func(func() interface{} { return Introspect() }))
+}
+
+// Introspect collects the current Introspection.
+func Introspect() Introspection {
+	state := Introspection{
+		Time:       NowFunc().UTC(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+	for _, s := range WebSocketStats() {
+		state.Streams = append(state.Streams, StreamState{ConnStats: s, Active: s.LastConnect.After(s.LastClose)})
+	}
+
+	introspection.mu.Lock()
+	gauges := make([]*channelGauge, 0, len(introspection.channels))
+	for _, g := range introspection.channels {
+		gauges = append(gauges, g)
+	}
+	recorders := make([]fileRecorder, 0, len(introspection.recorders))
+	for r := range introspection.recorders {
+		recorders = append(recorders, r)
+	}
+	state.LastErrors = append([]IntrospectedError(nil), introspection.errors...)
+	introspection.mu.Unlock()
+
+	for _, g := range gauges {
+		state.Channels = append(state.Channels, ChannelState{Symbol: g.symbol, Stream: g.stream, Capacity: g.capacity, Occupancy: g.occupancy()})
+	}
+	sort.Slice(state.Channels, func(i, j int) bool {
+		a, b := state.Channels[i], state.Channels[j]
+		return a.Symbol < b.Symbol || (a.Symbol == b.Symbol && a.Stream < b.Stream)
+	})
+	for _, r := range recorders {
+		state.Recorders = append(state.Recorders, r.Stats())
+	}
+	sort.Slice(state.Recorders, func(i, j int) bool {
+		a, b := state.Recorders[i], state.Recorders[j]
+		return a.Instrument < b.Instrument || (a.Instrument == b.Instrument && a.DataType < b.DataType)
+	})
+	return state
+}
+
+func introspectChannel(symbol, stream string, capacity int, occupancy func() int) {
+	introspection.mu.Lock()
+	defer introspection.mu.Unlock()
+	introspection.channels[symbol+"/"+stream] = &channelGauge{symbol: symbol, stream: stream, capacity: capacity, occupancy: occupancy}
+}
+
+func introspectRecorder(r fileRecorder, open bool) {
+	introspection.mu.Lock()
+	defer introspection.mu.Unlock()
+	if open {
+		introspection.recorders[r] = struct{}{}
+	} else {
+		delete(introspection.recorders, r)
+	}
+}
+
+func introspectError(at time.Time, message string) {
+	introspection.mu.Lock()
+	defer introspection.mu.Unlock()
+	introspection.errors = append(introspection.errors, IntrospectedError{Time: at, Message: message})
+	if n := len(introspection.errors); n > maxIntrospectedErrors {
+		introspection.errors = append([]IntrospectedError(nil), introspection.errors[n-maxIntrospectedErrors:]...)
+	}
+}
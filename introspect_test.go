@@ -0,0 +1,67 @@
+package gobinapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestIntrospect_ReportsRecordersChannelsAndErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+	r, err := NewRecorder[Trade]("INTROUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := r.Write(Trade{TradeID: int64(i)}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	registerChannelOccupancy("INTROUSDT", "trade", 5, func() int { return 2 })
+	NewLogger(&bytes.Buffer{}).Errorf("introspection test error %d", 42)
+
+	state := Introspect()
+	var found bool
+	for _, s := range state.Recorders {
+		if s.Instrument == "INTROUSDT" && s.DataType == "trade" {
+			found = true
+			if s.Rows != 3 || s.FileRows != 3 || s.File != BuildFileName("trade", "INTROUSDT", NowFunc().UTC()) {
+				t.Errorf("unexpected recorder stats: %+v", s)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected the open recorder to be listed, got %+v", state.Recorders)
+	}
+	found = false
+	for _, c := range state.Channels {
+		if c.Symbol == "INTROUSDT" && c.Stream == "trade" {
+			found = c.Capacity == 5 && c.Occupancy == 2
+		}
+	}
+	if !found {
+		t.Errorf("expected the channel at 2/5, got %+v", state.Channels)
+	}
+	if n := len(state.LastErrors); n == 0 || state.LastErrors[n-1].Message != "introspection test error 42" {
+		t.Errorf("expected the logged error last, got %+v", state.LastErrors)
+	}
+
+	// The same state is published through expvar
+	var published Introspection
+	if err := json.Unmarshal([]byte(expvar.Get("gobinapi").String()), &published); err != nil {
+		t.Fatalf("expvar output is not valid JSON: %v", err)
+	}
+	if len(published.Recorders) != len(state.Recorders) {
+		t.Errorf("expected %d recorders in expvar, got %d", len(state.Recorders), len(published.Recorders))
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for _, s := range Introspect().Recorders {
+		if s.Instrument == "INTROUSDT" {
+			t.Errorf("expected a closed recorder to be unlisted, got %+v", s)
+		}
+	}
+}
@@ -0,0 +1,648 @@
+package gobinapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formats the Kafka sink publishes records in.
+const (
+	KafkaFormatJSON = "json"
+	KafkaFormatAvro = "avro"
+)
+
+// KafkaConfig configures the Kafka sink.
+type KafkaConfig struct {
+	// Brokers are the bootstrap brokers as host:port; the partition leaders are discovered from them.
+	Brokers []string `json:"brokers" yaml:"brokers"`
+	// TopicPrefix is prepended to the data type to form the topic, e.g. "binance." publishes trades to
+	// "binance.trade". Defaults to no prefix.
+	TopicPrefix string `json:"topic_prefix,omitempty" yaml:"topic_prefix"`
+	// Format is KafkaFormatJSON (the record's JSON encoding) or KafkaFormatAvro (single-object encoding, see
+	// EncodeAvro). Defaults to JSON.
+	Format string `json:"format,omitempty" yaml:"format"`
+	// Acks is the number of acknowledgements the leader waits for: 1 for the leader only, -1 for all in-sync
+	// replicas, 0 for none. Defaults to 1.
+	Acks          *int          `json:"acks,omitempty" yaml:"acks"`
+	ClientID      string        `json:"client_id,omitempty" yaml:"client_id"`
+	BatchSize     int           `json:"batch_size,omitempty" yaml:"batch_size"`
+	FlushInterval time.Duration `json:"flush_interval,omitempty" yaml:"flush_interval"`
+}
+
+// Validate checks the settings.
+func (c KafkaConfig) Validate() error {
+	if len(c.Brokers) == 0 {
+		return errors.New("kafka: at least one broker is required")
+	}
+	if c.Format != "" && c.Format != KafkaFormatJSON && c.Format != KafkaFormatAvro {
+		return fmt.Errorf("kafka: unknown format %q, expected %q or %q", c.Format, KafkaFormatJSON, KafkaFormatAvro)
+	}
+	if c.Acks != nil && (*c.Acks < -1 || *c.Acks > 1) {
+		return fmt.Errorf("kafka: acks must be -1, 0 or 1, got %d", *c.Acks)
+	}
+	if c.BatchSize < 0 || c.FlushInterval < 0 {
+		return errors.New("kafka: batch size and flush interval must not be negative")
+	}
+	return nil
+}
+
+func (c KafkaConfig) withDefaults() KafkaConfig {
+	if c.Format == "" {
+		c.Format = KafkaFormatJSON
+	}
+	if c.Acks == nil {
+		acks := 1
+		c.Acks = &acks
+	}
+	if c.ClientID == "" {
+		c.ClientID = "gobinapi"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 1000
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 100 * time.Millisecond
+	}
+	return c
+}
+
+// KafkaMessage is a record encoded for publishing.
+type KafkaMessage struct {
+	Topic string
+	Key   []byte
+	Value []byte
+	Time  time.Time
+}
+
+// kafkaProducer is the subset of *KafkaProducer used by the sink, so tests can substitute a fake.
+type kafkaProducer interface {
+	Produce(ctx context.Context, msgs []KafkaMessage) error
+}
+
+// KafkaSink publishes trades, aggregate trades, order book diffs and best prices to Kafka, one topic per data type
+// keyed by symbol, so live consumers can use the feed while parquet remains the archival path. Records are
+// buffered by Write and published by the background Run loop, so broker latency never stalls the subscribers.
+type KafkaSink struct {
+	cfg      KafkaConfig
+	producer kafkaProducer
+	logger   LoggerInterface
+
+	// health holds the outcome of the last flush, see Healthy
+	health sinkHealth
+
+	mu      sync.Mutex
+	pending []KafkaMessage
+	flush   chan struct{}
+}
+
+// NewKafkaSink creates a sink publishing to cfg.Brokers. Brokers are contacted on the first publish.
+func NewKafkaSink(cfg KafkaConfig, logger LoggerInterface) (*KafkaSink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg = cfg.withDefaults()
+	return newKafkaSink(cfg, NewKafkaProducer(cfg.Brokers, cfg.ClientID, *cfg.Acks), logger), nil
+}
+
+func newKafkaSink(cfg KafkaConfig, producer kafkaProducer, logger LoggerInterface) *KafkaSink {
+	return &KafkaSink{
+		cfg:      cfg.withDefaults(),
+		producer: producer,
+		logger:   logger,
+		flush:    make(chan struct{}, 1),
+	}
+}
+
+// ForSymbol returns a RecorderWriter that publishes records of any type for the given symbol, which becomes the
+// message key. Use UntypedWriter to combine it with a typed Recorder.
+func (s *KafkaSink) ForSymbol(symbol string) RecorderWriter[any] {
+	return kafkaSymbolWriter{sink: s, key: []byte(strings.ToUpper(symbol))}
+}
+
+type kafkaSymbolWriter struct {
+	sink *KafkaSink
+	key  []byte
+}
+
+func (w kafkaSymbolWriter) Write(record interface{}) error {
+	return w.sink.write(w.key, record, NowFunc())
+}
+
+// write encodes a record and buffers it for its data type's topic. Unsupported record types are ignored.
+func (s *KafkaSink) write(key []byte, record interface{}, received time.Time) error {
+	var dataType string
+	switch record.(type) {
+	case Trade:
+		dataType = "trade"
+	case AggTrade:
+		dataType = "aggTrade"
+	case OrderBookDiff:
+		dataType = "orderBookDiff"
+	case BestPrice:
+		dataType = "bestPrice"
+	default:
+		return nil
+	}
+	var value []byte
+	var err error
+	if s.cfg.Format == KafkaFormatAvro {
+		value, err = EncodeAvro(record)
+	} else {
+		value, err = json.Marshal(record)
+	}
+	if err != nil {
+		return fmt.Errorf("kafka: failed to encode %s: %w", dataType, err)
+	}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, KafkaMessage{Topic: s.cfg.TopicPrefix + dataType, Key: key, Value: value, Time: received})
+	full := len(s.pending) >= s.cfg.BatchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Healthy returns the error of the last flush, or nil if it succeeded.
+func (s *KafkaSink) Healthy() error {
+	return s.health.Healthy()
+}
+
+// Run publishes buffered messages every FlushInterval, or sooner when a batch fills up, until the context is
+// cancelled. Remaining messages are published on exit.
+func (s *KafkaSink) Run(ctx context.Context) error {
+	ticker := DefaultClock.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := s.Flush(flushCtx); err != nil {
+				s.logger.Errorf("kafka: final flush failed: %v", err)
+			}
+			cancel()
+			return ctx.Err()
+		case <-ticker.C():
+		case <-s.flush:
+		}
+		if err := s.Flush(ctx); err != nil {
+			s.logger.Errorf("kafka: flush failed: %v", err)
+		}
+	}
+}
+
+// Flush publishes all buffered messages. If publishing fails they are put back at the front of the buffer so they
+// are retried on the next flush, up to a limit of 100 batches beyond which the oldest messages are dropped to bound
+// memory during a long broker outage. Messages of a failed flush may have been published in part, so consumers can
+// see duplicates.
+func (s *KafkaSink) Flush(ctx context.Context) (err error) {
+	defer func() { s.health.record(err) }()
+	s.mu.Lock()
+	msgs := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if len(msgs) == 0 {
+		return nil
+	}
+	if err := s.producer.Produce(ctx, msgs); err != nil {
+		s.mu.Lock()
+		msgs = append(msgs, s.pending...)
+		if limit := s.cfg.BatchSize * 100; len(msgs) > limit {
+			s.logger.Errorf("kafka: dropping %d buffered messages after repeated publish failures", len(msgs)-limit)
+			msgs = msgs[len(msgs)-limit:]
+		}
+		s.pending = msgs
+		s.mu.Unlock()
+		return fmt.Errorf("kafka: publish failed: %w", err)
+	}
+	return nil
+}
+
+// Kafka API keys and the versions the producer speaks: Metadata v1 and Produce v3, the first with record batches.
+const (
+	kafkaProduceKey      = 0
+	kafkaProduceVersion  = 3
+	kafkaMetadataKey     = 3
+	kafkaMetadataVersion = 1
+)
+
+// kafkaTimeout bounds every broker request without a context deadline.
+const kafkaTimeout = 10 * time.Second
+
+// KafkaProducer is a minimal Kafka producer speaking the wire protocol directly. It discovers partition leaders
+// from the bootstrap brokers, assigns messages to partitions by key with the murmur2 hash of the Java client's
+// default partitioner, so a symbol lands on the same partition as with other clients, and sends one produce
+// request per leader. Metadata and connections are dropped after a failure and rebuilt on the next call.
+type KafkaProducer struct {
+	bootstrap []string
+	clientID  string
+	acks      int16
+
+	mu            sync.Mutex
+	correlationID int32
+	brokers       map[int32]string
+	leaders       map[string][]int32
+	conns         map[string]*kafkaConn
+}
+
+// NewKafkaProducer creates a producer for the bootstrap brokers. acks is -1, 0 or 1 as in KafkaConfig.
+func NewKafkaProducer(brokers []string, clientID string, acks int) *KafkaProducer {
+	return &KafkaProducer{
+		bootstrap: brokers,
+		clientID:  clientID,
+		acks:      int16(acks),
+		brokers:   make(map[int32]string),
+		leaders:   make(map[string][]int32),
+		conns:     make(map[string]*kafkaConn),
+	}
+}
+
+// Produce publishes msgs, returning once every leader has acknowledged them as configured.
+func (p *KafkaProducer) Produce(ctx context.Context, msgs []KafkaMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.produce(ctx, msgs)
+	if err != nil {
+		p.reset()
+	}
+	return err
+}
+
+func (p *KafkaProducer) produce(ctx context.Context, msgs []KafkaMessage) error {
+	var missing []string
+	for _, m := range msgs {
+		if _, ok := p.leaders[m.Topic]; !ok {
+			missing = append(missing, m.Topic)
+		}
+	}
+	if len(missing) > 0 {
+		if err := p.refreshMetadata(ctx, missing); err != nil {
+			return err
+		}
+	}
+
+	// Group the messages by leader, topic and partition, keeping their order
+	batches := make(map[int32]map[string]map[int32][]KafkaMessage)
+	for _, m := range msgs {
+		leaders := p.leaders[m.Topic]
+		partition := int32(KafkaPartition(m.Key, len(leaders)))
+		leader := leaders[partition]
+		if batches[leader] == nil {
+			batches[leader] = make(map[string]map[int32][]KafkaMessage)
+		}
+		if batches[leader][m.Topic] == nil {
+			batches[leader][m.Topic] = make(map[int32][]KafkaMessage)
+		}
+		batches[leader][m.Topic][partition] = append(batches[leader][m.Topic][partition], m)
+	}
+	for leader, topics := range batches {
+		addr, ok := p.brokers[leader]
+		if !ok {
+			return fmt.Errorf("no address for broker %d", leader)
+		}
+		if err := p.sendProduce(ctx, addr, topics); err != nil {
+			return fmt.Errorf("broker %s: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+// reset closes the connections and forgets the metadata.
+func (p *KafkaProducer) reset() {
+	for addr, c := range p.conns {
+		c.Close()
+		delete(p.conns, addr)
+	}
+	p.leaders = make(map[string][]int32)
+}
+
+// refreshMetadata asks the bootstrap brokers in turn for the leaders of topics.
+func (p *KafkaProducer) refreshMetadata(ctx context.Context, topics []string) error {
+	var req kafkaEncoder
+	req.int32(int32(len(topics)))
+	for _, t := range topics {
+		req.string(t)
+	}
+	var lastErr error
+	for _, addr := range p.bootstrap {
+		resp, err := p.roundTrip(ctx, addr, kafkaMetadataKey, kafkaMetadataVersion, req.buf, true)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return p.parseMetadata(resp)
+	}
+	return fmt.Errorf("failed to fetch metadata: %w", lastErr)
+}
+
+func (p *KafkaProducer) parseMetadata(resp *kafkaDecoder) error {
+	for n := resp.int32(); n > 0 && resp.err == nil; n-- {
+		id, host, port := resp.int32(), resp.string(), resp.int32()
+		resp.string() // rack
+		p.brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	resp.int32() // controller
+	for n := resp.int32(); n > 0 && resp.err == nil; n-- {
+		errCode, topic := resp.int16(), resp.string()
+		resp.int8() // is internal
+		count := resp.int32()
+		if errCode != 0 {
+			return fmt.Errorf("metadata for topic %s: error code %d", topic, errCode)
+		}
+		leaders := make([]int32, count)
+		for i := int32(0); i < count && resp.err == nil; i++ {
+			resp.int16() // partition error code
+			index, leader := resp.int32(), resp.int32()
+			resp.int32Array() // replicas
+			resp.int32Array() // in-sync replicas
+			if index < 0 || index >= count {
+				return fmt.Errorf("metadata for topic %s: invalid partition %d", topic, index)
+			}
+			leaders[index] = leader
+		}
+		if count == 0 {
+			return fmt.Errorf("metadata for topic %s: no partitions", topic)
+		}
+		p.leaders[topic] = leaders
+	}
+	return resp.err
+}
+
+// sendProduce sends one produce request for the messages of a leader and checks the partition error codes.
+func (p *KafkaProducer) sendProduce(ctx context.Context, addr string, topics map[string]map[int32][]KafkaMessage) error {
+	var req kafkaEncoder
+	req.int16(-1) // no transactional ID
+	req.int16(p.acks)
+	req.int32(int32(kafkaTimeout / time.Millisecond))
+	req.int32(int32(len(topics)))
+	for topic, partitions := range topics {
+		req.string(topic)
+		req.int32(int32(len(partitions)))
+		for partition, msgs := range partitions {
+			req.int32(partition)
+			batch := EncodeKafkaRecordBatch(msgs)
+			req.int32(int32(len(batch)))
+			req.buf = append(req.buf, batch...)
+		}
+	}
+	resp, err := p.roundTrip(ctx, addr, kafkaProduceKey, kafkaProduceVersion, req.buf, p.acks != 0)
+	if err != nil || resp == nil {
+		return err
+	}
+	for n := resp.int32(); n > 0 && resp.err == nil; n-- {
+		topic := resp.string()
+		for m := resp.int32(); m > 0 && resp.err == nil; m-- {
+			partition, errCode := resp.int32(), resp.int16()
+			resp.int64() // base offset
+			resp.int64() // log append time
+			if errCode != 0 && resp.err == nil {
+				return fmt.Errorf("produce to %s/%d: error code %d", topic, partition, errCode)
+			}
+		}
+	}
+	return resp.err
+}
+
+// roundTrip sends a request to addr and, if expectResponse is set, reads its response.
+func (p *KafkaProducer) roundTrip(ctx context.Context, addr string, apiKey, apiVersion int16, body []byte, expectResponse bool) (*kafkaDecoder, error) {
+	c, ok := p.conns[addr]
+	if !ok {
+		var err error
+		if c, err = dialKafka(ctx, addr); err != nil {
+			return nil, err
+		}
+		p.conns[addr] = c
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(kafkaTimeout)
+	}
+	c.SetDeadline(deadline)
+
+	p.correlationID++
+	var req kafkaEncoder
+	req.int32(0) // size, set below
+	req.int16(apiKey)
+	req.int16(apiVersion)
+	req.int32(p.correlationID)
+	req.string(p.clientID)
+	req.buf = append(req.buf, body...)
+	binary.BigEndian.PutUint32(req.buf, uint32(len(req.buf)-4))
+	if _, err := c.Write(req.buf); err != nil {
+		return nil, err
+	}
+	if !expectResponse {
+		return nil, nil
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.r, size[:]); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, err
+	}
+	resp := &kafkaDecoder{buf: data}
+	if id := resp.int32(); id != p.correlationID {
+		return nil, fmt.Errorf("unexpected correlation ID %d, expected %d", id, p.correlationID)
+	}
+	return resp, nil
+}
+
+// kafkaConn is a broker connection with a buffered reader.
+type kafkaConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func dialKafka(ctx context.Context, addr string) (*kafkaConn, error) {
+	d := net.Dialer{Timeout: kafkaTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// EncodeKafkaRecordBatch encodes msgs as an uncompressed record batch (magic 2), the message format of Kafka 0.11
+// and later.
+func EncodeKafkaRecordBatch(msgs []KafkaMessage) []byte {
+	first, last := msgs[0].Time.UnixMilli(), msgs[0].Time.UnixMilli()
+	for _, m := range msgs {
+		first, last = min(first, m.Time.UnixMilli()), max(last, m.Time.UnixMilli())
+	}
+	var records []byte
+	for i, m := range msgs {
+		var r []byte
+		r = append(r, 0) // attributes
+		r = binary.AppendVarint(r, m.Time.UnixMilli()-first)
+		r = binary.AppendVarint(r, int64(i))
+		if m.Key == nil {
+			r = binary.AppendVarint(r, -1)
+		} else {
+			r = binary.AppendVarint(r, int64(len(m.Key)))
+			r = append(r, m.Key...)
+		}
+		r = binary.AppendVarint(r, int64(len(m.Value)))
+		r = append(r, m.Value...)
+		r = binary.AppendVarint(r, 0) // headers
+		records = binary.AppendVarint(records, int64(len(r)))
+		records = append(records, r...)
+	}
+
+	// The CRC covers everything from the attributes on
+	var tail kafkaEncoder
+	tail.int16(0) // attributes: no compression, create time
+	tail.int32(int32(len(msgs) - 1))
+	tail.int64(first)
+	tail.int64(last)
+	tail.int64(-1) // producer ID
+	tail.int16(-1) // producer epoch
+	tail.int32(-1) // base sequence
+	tail.int32(int32(len(msgs)))
+	tail.buf = append(tail.buf, records...)
+
+	var batch kafkaEncoder
+	batch.int64(0)                                // base offset
+	batch.int32(int32(4 + 1 + 4 + len(tail.buf))) // length after this field
+	batch.int32(-1)                               // partition leader epoch
+	batch.int8(2)                                 // magic
+	batch.int32(int32(crc32.Checksum(tail.buf, crc32c)))
+	batch.buf = append(batch.buf, tail.buf...)
+	return batch.buf
+}
+
+// KafkaPartition is a pure function returning the partition of key among n partitions, as assigned by the Java
+// client's default partitioner.
+func KafkaPartition(key []byte, n int) int {
+	return int(murmur2(key)&0x7fffffff) % n
+}
+
+// murmur2 is the variant of MurmurHash2 Kafka's partitioner uses.
+func murmur2(data []byte) int32 {
+	const seed, m, r = 0x9747b28c, 0x5bd1e995, 24
+	length := len(data)
+	h := uint32(seed) ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// kafkaEncoder appends big-endian protocol primitives.
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *kafkaEncoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// kafkaDecoder reads big-endian protocol primitives, recording the first error.
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = errors.New("kafka: truncated response")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string reads a nullable string, returning "" for null.
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *kafkaDecoder) int32Array() []int32 {
+	n := d.int32()
+	var values []int32
+	for i := int32(0); i < n && d.err == nil; i++ {
+		values = append(values, d.int32())
+	}
+	return values
+}
@@ -0,0 +1,306 @@
+package gobinapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMurmur2_MatchesJavaClient(t *testing.T) {
+	// Test vectors of the Java client's Utils.murmur2
+	for key, want := range map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	} {
+		if got := murmur2([]byte(key)); got != want {
+			t.Errorf("murmur2(%q) = %d, expected %d", key, got, want)
+		}
+	}
+}
+
+// fakeKafkaBroker is a single broker answering Metadata v1 and Produce v3 requests. Every topic has partitions
+// partitions led by the broker itself.
+type fakeKafkaBroker struct {
+	t          *testing.T
+	listener   net.Listener
+	partitions int32
+
+	mu       sync.Mutex
+	produced map[string][]KafkaMessage // by "topic/partition"
+	acks     []int16
+}
+
+func newFakeKafkaBroker(t *testing.T, partitions int32) *fakeKafkaBroker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeKafkaBroker{t: t, listener: l, partitions: partitions, produced: make(map[string][]KafkaMessage)}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeKafkaBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return
+		}
+		req := &kafkaDecoder{buf: data}
+		apiKey, _, correlationID := req.int16(), req.int16(), req.int32()
+		req.string() // client ID
+		var resp kafkaEncoder
+		resp.int32(0)
+		resp.int32(correlationID)
+		switch apiKey {
+		case kafkaMetadataKey:
+			b.metadata(req, &resp)
+		case kafkaProduceKey:
+			if !b.produce(req, &resp) {
+				continue
+			}
+		default:
+			b.t.Errorf("unexpected API key %d", apiKey)
+			return
+		}
+		binary.BigEndian.PutUint32(resp.buf, uint32(len(resp.buf)-4))
+		conn.Write(resp.buf)
+	}
+}
+
+func (b *fakeKafkaBroker) metadata(req *kafkaDecoder, resp *kafkaEncoder) {
+	host, port, _ := net.SplitHostPort(b.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	resp.int32(1)
+	resp.int32(7) // node ID
+	resp.string(host)
+	resp.int32(int32(portNumber))
+	resp.int16(-1) // rack
+	resp.int32(7)  // controller
+	n := req.int32()
+	resp.int32(n)
+	for i := int32(0); i < n; i++ {
+		resp.int16(0)
+		resp.string(req.string())
+		resp.int8(0)
+		resp.int32(b.partitions)
+		for p := int32(0); p < b.partitions; p++ {
+			resp.int16(0)
+			resp.int32(p)
+			resp.int32(7)
+			resp.int32(1)
+			resp.int32(7)
+			resp.int32(1)
+			resp.int32(7)
+		}
+	}
+}
+
+// produce records the request's messages and writes the response, reporting whether one is expected.
+func (b *fakeKafkaBroker) produce(req *kafkaDecoder, resp *kafkaEncoder) bool {
+	req.string() // transactional ID
+	acks := req.int16()
+	req.int32() // timeout
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.acks = append(b.acks, acks)
+	topics := req.int32()
+	resp.int32(topics)
+	for i := int32(0); i < topics; i++ {
+		topic := req.string()
+		resp.string(topic)
+		partitions := req.int32()
+		resp.int32(partitions)
+		for j := int32(0); j < partitions; j++ {
+			partition := req.int32()
+			batch := req.take(int(req.int32()))
+			msgs, err := decodeKafkaRecordBatch(batch)
+			if err != nil {
+				b.t.Errorf("invalid record batch for %s/%d: %v", topic, partition, err)
+			}
+			key := topic + "/" + strconv.Itoa(int(partition))
+			for k := range msgs {
+				msgs[k].Topic = topic
+			}
+			b.produced[key] = append(b.produced[key], msgs...)
+			resp.int32(partition)
+			resp.int16(0)
+			resp.int64(0)
+			resp.int64(-1)
+		}
+	}
+	resp.int32(0) // throttle time
+	return acks != 0
+}
+
+// decodeKafkaRecordBatch decodes a record batch written by EncodeKafkaRecordBatch, checking its length and CRC.
+func decodeKafkaRecordBatch(data []byte) ([]KafkaMessage, error) {
+	d := &kafkaDecoder{buf: data}
+	d.int64() // base offset
+	if length := d.int32(); int(length) != len(d.buf) {
+		return nil, errors.New("batch length mismatch")
+	}
+	d.int32() // partition leader epoch
+	if magic := d.int8(); magic != 2 {
+		return nil, errors.New("unexpected magic")
+	}
+	if crc := uint32(d.int32()); crc != crc32.Checksum(d.buf, crc32c) {
+		return nil, errors.New("CRC mismatch")
+	}
+	d.int16() // attributes
+	d.int32() // last offset delta
+	first := d.int64()
+	d.int64() // max timestamp
+	d.int64() // producer ID
+	d.int16() // producer epoch
+	d.int32() // base sequence
+	count := d.int32()
+	var msgs []KafkaMessage
+	varint := func() int64 {
+		v, n := binary.Varint(d.buf)
+		d.buf = d.buf[n:]
+		return v
+	}
+	for i := int32(0); i < count; i++ {
+		varint() // length
+		d.int8() // attributes
+		delta := varint()
+		varint() // offset delta
+		key := d.take(int(varint()))
+		value := d.take(int(varint()))
+		varint() // headers
+		msgs = append(msgs, KafkaMessage{Key: key, Value: value, Time: time.UnixMilli(first + delta)})
+	}
+	return msgs, d.err
+}
+
+func TestKafkaProducer_PublishesToKeyPartitions(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 3)
+	p := NewKafkaProducer([]string{broker.listener.Addr().String()}, "test", 1)
+	start := time.UnixMilli(1700000000000)
+	msgs := []KafkaMessage{
+		{Topic: "binance.trade", Key: []byte("BTCUSDT"), Value: []byte("t1"), Time: start},
+		{Topic: "binance.bestPrice", Key: []byte("ETHUSDT"), Value: []byte("b1"), Time: start.Add(time.Millisecond)},
+		{Topic: "binance.trade", Key: []byte("BTCUSDT"), Value: []byte("t2"), Time: start.Add(2 * time.Millisecond)},
+	}
+	if err := p.Produce(context.Background(), msgs); err != nil {
+		t.Fatal(err)
+	}
+	// A second call reuses the metadata and connection
+	if err := p.Produce(context.Background(), msgs[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	trades := broker.produced["binance.trade/"+strconv.Itoa(KafkaPartition([]byte("BTCUSDT"), 3))]
+	if len(trades) != 3 || string(trades[0].Value) != "t1" || string(trades[1].Value) != "t2" || string(trades[2].Value) != "t1" {
+		t.Fatalf("expected the trades in order on BTCUSDT's partition, got %+v", broker.produced)
+	}
+	if string(trades[1].Key) != "BTCUSDT" || !trades[1].Time.Equal(start.Add(2*time.Millisecond)) {
+		t.Errorf("unexpected key or timestamp %+v", trades[1])
+	}
+	books := broker.produced["binance.bestPrice/"+strconv.Itoa(KafkaPartition([]byte("ETHUSDT"), 3))]
+	if len(books) != 1 || string(books[0].Value) != "b1" {
+		t.Errorf("expected the best price on ETHUSDT's partition, got %+v", broker.produced)
+	}
+	if len(broker.acks) != 2 || broker.acks[0] != 1 {
+		t.Errorf("expected two produce requests with acks 1, got %v", broker.acks)
+	}
+}
+
+func TestKafkaProducer_FailsWithoutBrokers(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+	p := NewKafkaProducer([]string{addr}, "test", 1)
+	err := p.Produce(context.Background(), []KafkaMessage{{Topic: "trade", Value: []byte("x"), Time: time.Now()}})
+	if err == nil {
+		t.Fatal("expected an error without a reachable broker")
+	}
+}
+
+// fakeKafkaProducer records published messages, failing the first failures calls.
+type fakeKafkaProducer struct {
+	failures  int
+	calls     int
+	published []KafkaMessage
+}
+
+func (p *fakeKafkaProducer) Produce(ctx context.Context, msgs []KafkaMessage) error {
+	p.calls++
+	if p.calls <= p.failures {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, msgs...)
+	return nil
+}
+
+func TestKafkaSink_PublishesByDataTypeAndRetries(t *testing.T) {
+	producer := &fakeKafkaProducer{failures: 1}
+	sink := newKafkaSink(KafkaConfig{Brokers: []string{"kafka:9092"}, TopicPrefix: "binance."}, producer, &FakeLogger{})
+	w := sink.ForSymbol("btcusdt")
+	w.Write(Trade{TradeID: 1, Price: "100.5"})
+	w.Write(BestPrice{UpdateID: 2, BidPrice: "100.4"})
+	w.Write(BookTop{})
+
+	if err := sink.Flush(context.Background()); err == nil {
+		t.Fatal("expected the first flush to fail")
+	}
+	w.Write(AggTrade{AggTradeID: 3})
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	topics := []string{"binance.trade", "binance.bestPrice", "binance.aggTrade"}
+	if len(producer.published) != len(topics) {
+		t.Fatalf("expected %d messages, got %+v", len(topics), producer.published)
+	}
+	for i, m := range producer.published {
+		if m.Topic != topics[i] || string(m.Key) != "BTCUSDT" {
+			t.Errorf("message %d: expected topic %s keyed BTCUSDT, got %s %s", i, topics[i], m.Topic, m.Key)
+		}
+	}
+	var trade Trade
+	if err := json.Unmarshal(producer.published[0].Value, &trade); err != nil || trade.Price != "100.5" {
+		t.Errorf("expected the trade as JSON, got %s", producer.published[0].Value)
+	}
+}
+
+func TestKafkaSink_PublishesAvro(t *testing.T) {
+	producer := &fakeKafkaProducer{}
+	sink := newKafkaSink(KafkaConfig{Brokers: []string{"kafka:9092"}, Format: KafkaFormatAvro}, producer, &FakeLogger{})
+	sink.ForSymbol("BTCUSDT").Write(Trade{TradeID: 1})
+	sink.Flush(context.Background())
+	expected, _ := EncodeAvro(Trade{TradeID: 1})
+	if len(producer.published) != 1 || string(producer.published[0].Value) != string(expected) {
+		t.Errorf("expected the trade in Avro single-object encoding, got %+v", producer.published)
+	}
+}
@@ -0,0 +1,293 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KlineIntervals are the kline intervals the exchange serves.
+var KlineIntervals = []string{"1s", "1m", "3m", "5m", "15m", "30m", "1h", "2h", "4h", "6h", "8h", "12h", "1d", "3d", "1w", "1M"}
+
+// klineDataTypePrefix starts the data type of klines, which is followed by their interval.
+const klineDataTypePrefix = "kline_"
+
+// KlineDataType returns the data type klines of interval are stored as, e.g. "kline_1m", so a day's 1m klines of
+// BTCUSDT go to BTCUSDT_kline_1m_2024-05-01.parquet.
+func KlineDataType(interval string) string {
+	return klineDataTypePrefix + interval
+}
+
+// KlineInterval returns the interval of a data type returned by KlineDataType, and false for other data types.
+func KlineInterval(dataType string) (string, bool) {
+	interval, ok := strings.CutPrefix(dataType, klineDataTypePrefix)
+	return interval, ok && slices.Contains(KlineIntervals, interval)
+}
+
+// Kinds of reference price klines, which USD-M futures serve over REST only. They have no trades, so their volumes
+// and trade counts are zero.
+const (
+	MarkPriceKlines    = "markPrice"
+	IndexPriceKlines   = "indexPrice"
+	PremiumIndexKlines = "premiumIndex"
+)
+
+// ReferenceKlineKinds lists the kinds of reference price klines.
+var ReferenceKlineKinds = []string{MarkPriceKlines, IndexPriceKlines, PremiumIndexKlines}
+
+// referenceKlineEndpoints maps each kind of reference kline to its endpoint and the query parameter naming the
+// symbol; index prices are per pair, which is the symbol of a perpetual contract.
+var referenceKlineEndpoints = map[string][2]string{
+	MarkPriceKlines:    {"/fapi/v1/markPriceKlines", "symbol"},
+	IndexPriceKlines:   {"/fapi/v1/indexPriceKlines", "pair"},
+	PremiumIndexKlines: {"/fapi/v1/premiumIndexKlines", "symbol"},
+}
+
+// ReferenceKlineDataType returns the data type reference klines of kind and interval are stored as, e.g.
+// "premiumIndexKline_1m".
+func ReferenceKlineDataType(kind, interval string) string {
+	return kind + "Kline_" + interval
+}
+
+// ReferenceKlineKind returns the kind and interval of a data type returned by ReferenceKlineDataType, and false for
+// other data types.
+func ReferenceKlineKind(dataType string) (kind, interval string, ok bool) {
+	kind, interval, ok = strings.Cut(dataType, "Kline_")
+	return kind, interval, ok && slices.Contains(ReferenceKlineKinds, kind) && slices.Contains(KlineIntervals, interval)
+}
+
+// klinesPageSize is the number of klines requested per page, the spot endpoint's maximum.
+const klinesPageSize = 1000
+
+// Request weights of a full page of klines.
+const (
+	klinesWeight        = 2
+	futuresKlinesWeight = 5
+)
+
+// parseKlines is a pure function decoding the arrays returned by the klines endpoint: open time, open, high, low,
+// close, volume, close time, quote volume, number of trades, taker buy volume and taker buy quote volume.
+func parseKlines(data []byte, symbol, interval string) ([]Kline, error) {
+	var rows [][]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("failed to parse klines: %w", err)
+	}
+	klines := make([]Kline, len(rows))
+	for i, row := range rows {
+		if len(row) < 11 {
+			return nil, fmt.Errorf("malformed kline with %d fields", len(row))
+		}
+		k := Kline{Symbol: symbol, Interval: interval}
+		ints := []*int64{&k.OpenTime, &k.CloseTime, &k.Trades}
+		strs := []*string{&k.Open, &k.High, &k.Low, &k.Close, &k.Volume, &k.QuoteVolume, &k.TakerBuyVolume, &k.TakerBuyQuoteVolume}
+		for j, field := range []int{0, 6, 8} {
+			if err := json.Unmarshal(row[field], ints[j]); err != nil {
+				return nil, fmt.Errorf("malformed kline field %d: %w", field, err)
+			}
+		}
+		for j, field := range []int{1, 2, 3, 4, 5, 7, 9, 10} {
+			if err := json.Unmarshal(row[field], strs[j]); err != nil {
+				return nil, fmt.Errorf("malformed kline field %d: %w", field, err)
+			}
+		}
+		klines[i] = k
+	}
+	return klines, nil
+}
+
+// Klines fetches the klines of symbol and interval opened in [start, end), passing them to handle a page at a time
+// in time order.
+func (b *Backfiller) Klines(ctx context.Context, symbol, interval string, start, end time.Time, handle func([]Kline) error) error {
+	endpoint, weight := RESTBaseURL+"/api/v3/klines", klinesWeight
+	if b.market == MarketUSDM {
+		endpoint, weight = FuturesRESTBaseURL+"/fapi/v1/klines", futuresKlinesWeight
+	}
+	return b.klines(ctx, endpoint, weight, "symbol", symbol, interval, start, end, handle)
+}
+
+// ReferenceKlines is Klines for the reference price klines of kind, which only USD-M futures have.
+func (b *Backfiller) ReferenceKlines(ctx context.Context, kind, symbol, interval string, start, end time.Time, handle func([]Kline) error) error {
+	endpoint, ok := referenceKlineEndpoints[kind]
+	if !ok {
+		return fmt.Errorf("unknown reference kline kind %q", kind)
+	}
+	if b.market != MarketUSDM {
+		return fmt.Errorf("%s klines are only available on USD-M futures", kind)
+	}
+	return b.klines(ctx, FuturesRESTBaseURL+endpoint[0], futuresKlinesWeight, endpoint[1], symbol, interval, start, end, handle)
+}
+
+// klines pages through a klines endpoint, passing symbol in the query parameter param.
+func (b *Backfiller) klines(ctx context.Context, endpoint string, weight int, param, symbol, interval string, start, end time.Time, handle func([]Kline) error) error {
+	if !slices.Contains(KlineIntervals, interval) {
+		return fmt.Errorf("unknown kline interval %q", interval)
+	}
+	for from := start.UnixMilli(); from < end.UnixMilli(); {
+		q := url.Values{}
+		q.Set(param, symbol)
+		q.Set("interval", interval)
+		q.Set("startTime", strconv.FormatInt(from, 10))
+		q.Set("endTime", strconv.FormatInt(end.UnixMilli()-1, 10))
+		q.Set("limit", strconv.Itoa(klinesPageSize))
+		var raw json.RawMessage
+		if err := b.get(ctx, endpoint, q, weight, &raw); err != nil {
+			return err
+		}
+		page, err := parseKlines(raw, symbol, interval)
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+		if err := handle(page); err != nil {
+			return err
+		}
+		if len(page) < klinesPageSize {
+			return nil
+		}
+		from = page[len(page)-1].OpenTime + 1
+	}
+	return nil
+}
+
+// BackfillKlines downloads the klines of symbol and interval opened in [start, end) into one file per UTC day under
+// layout, named after KlineDataType. Klines already in a day's file are kept and the file is replaced by the merged
+// result, so ranges can be downloaded in any order and an interrupted download is completed by running it again.
+func (b *Backfiller) BackfillKlines(ctx context.Context, layout FileLayout, symbol, interval string, start, end time.Time) (BackfillStats, error) {
+	return backfillKlines(layout, KlineDataType(interval), symbol, start, end, func(from, to time.Time, handle func([]Kline) error) error {
+		return b.Klines(ctx, symbol, interval, from, to, handle)
+	})
+}
+
+// BackfillReferenceKlines is BackfillKlines for the reference price klines of kind, stored as
+// ReferenceKlineDataType.
+func (b *Backfiller) BackfillReferenceKlines(ctx context.Context, layout FileLayout, kind, symbol, interval string, start, end time.Time) (BackfillStats, error) {
+	return backfillKlines(layout, ReferenceKlineDataType(kind, interval), symbol, start, end, func(from, to time.Time, handle func([]Kline) error) error {
+		return b.ReferenceKlines(ctx, kind, symbol, interval, from, to, handle)
+	})
+}
+
+// backfillKlines downloads the klines fetch returns for [start, end) into the day files of dataType, as
+// BackfillKlines describes.
+func backfillKlines(layout FileLayout, dataType, symbol string, start, end time.Time, fetch func(from, to time.Time, handle func([]Kline) error) error) (BackfillStats, error) {
+	var stats BackfillStats
+	for day := start.UTC().Truncate(24 * time.Hour); day.Before(end); day = day.Add(24 * time.Hour) {
+		from, to := day, day.Add(24*time.Hour)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		var fetched []Kline
+		err := fetch(from, to, func(page []Kline) error {
+			fetched = append(fetched, page...)
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+		stats.Fetched += len(fetched)
+		if len(fetched) == 0 {
+			continue
+		}
+		added, filePath, err := mergeDayFile(layout, dataType, symbol, day, fetched, func(k Kline) int64 { return k.OpenTime })
+		if err != nil {
+			return stats, err
+		}
+		if added > 0 {
+			stats.Recorded += added
+			stats.Files = append(stats.Files, filePath)
+		}
+	}
+	return stats, nil
+}
+
+// mergeDayFile merges records into the single file of symbol's dataType on day, keyed by key, and returns how many
+// of them were new along with the file's path. The file is left alone when nothing is new.
+func mergeDayFile[T any](layout FileLayout, dataType, symbol string, day time.Time, records []T, key func(T) int64) (int, string, error) {
+	filePath := layout.FilePath(dataType, symbol, day, 0)
+	var existing []T
+	if FileExists(filePath) {
+		var err error
+		if existing, err = ReadParquetFile[T](filePath); err != nil {
+			return 0, filePath, err
+		}
+	}
+	merged, _, mergeStats := MergeRecords(existing, records, key)
+	if mergeStats.RowsOut == len(existing) {
+		return 0, filePath, nil
+	}
+	if err := replaceParquetFile(filePath, merged, ParquetOptionsFor(dataType)); err != nil {
+		return 0, filePath, err
+	}
+	return mergeStats.RowsOut - len(existing), filePath, nil
+}
+
+// replaceParquetFile writes records to a temporary file next to filePath and renames it over filePath, so readers
+// see either the old or the new file.
+func replaceParquetFile[T any](filePath string, records []T, options ParquetOptions) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	tmpPath := filePath + ".tmp"
+	if err := writeParquetFile(tmpPath, records, options); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// RunReferenceKlines records the closed reference price klines of every kind and of each of intervals for the symbols
+// instruments returns, checking once a minute until ctx is cancelled. The first check of a symbol downloads the
+// current UTC day so far, so a restart leaves no gap within the day; later ones download the klines closed since.
+// Failures are logged and the range is tried again at the next check.
+func RunReferenceKlines(ctx context.Context, b *Backfiller, layout FileLayout, instruments func() []string, intervals []string, logger LoggerInterface) {
+	ticker := DefaultClock.NewTicker(time.Minute)
+	defer ticker.Stop()
+	// recorded holds the end of the range recorded so far per symbol, kind and interval
+	recorded := make(map[[3]string]time.Time)
+	for {
+		now := NowFunc().UTC()
+		for _, symbol := range instruments() {
+			for _, kind := range ReferenceKlineKinds {
+				for _, interval := range intervals {
+					key := [3]string{symbol, kind, interval}
+					start, ok := recorded[key]
+					if !ok {
+						start = now.Truncate(24 * time.Hour)
+					}
+					end := now.Truncate(BarIntervals[interval])
+					if !end.After(start) {
+						continue
+					}
+					if _, err := b.BackfillReferenceKlines(ctx, layout, kind, symbol, interval, start, end); err != nil {
+						if ctx.Err() != nil {
+							return
+						}
+						logger.Errorf("Failed to record the %s %s klines of %s: %v", kind, interval, symbol, err)
+						continue
+					}
+					recorded[key] = end
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseKlines(t *testing.T) {
+	data := `[[1499040000000,"0.01634790","0.80000000","0.01575800","0.01577100","148976.11427815",1499644799999,"2434.19055334",308,"1756.87402397","28.46694368","0"]]`
+	klines, err := parseKlines([]byte(data), "BNBBTC", "1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Kline{
+		Symbol: "BNBBTC", Interval: "1d", OpenTime: 1499040000000, CloseTime: 1499644799999,
+		Open: "0.01634790", High: "0.80000000", Low: "0.01575800", Close: "0.01577100", Volume: "148976.11427815",
+		QuoteVolume: "2434.19055334", Trades: 308, TakerBuyVolume: "1756.87402397", TakerBuyQuoteVolume: "28.46694368",
+	}
+	if len(klines) != 1 || klines[0] != expected {
+		t.Errorf("unexpected klines %+v", klines)
+	}
+	if _, err := parseKlines([]byte(`[[1499040000000,"1"]]`), "BNBBTC", "1d"); err == nil {
+		t.Error("expected a short kline to be rejected")
+	}
+}
+
+// serveMinuteKlines answers klines requests with one 1m kline per minute in the requested range, counting requests.
+func serveMinuteKlines(t *testing.T) *int {
+	var mu sync.Mutex
+	requests := new(int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*requests++
+		mu.Unlock()
+		q := r.URL.Query()
+		start, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("endTime"), 10, 64)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		var rows [][]any
+		for open := (start + 59999) / 60000 * 60000; open <= end && len(rows) < limit; open += 60000 {
+			rows = append(rows, []any{open, "1", "2", "0.5", "1.5", "10", open + 59999, "15", 3, "4", "6", "0"})
+		}
+		json.NewEncoder(w).Encode(rows)
+	}))
+	t.Cleanup(srv.Close)
+	old := RESTBaseURL
+	RESTBaseURL = srv.URL
+	t.Cleanup(func() { RESTBaseURL = old })
+	return requests
+}
+
+func TestBackfillKlines_WritesDailyFilesAndMergesExisting(t *testing.T) {
+	requests := serveMinuteKlines(t)
+	layout := FileLayout{Root: t.TempDir(), Hive: true}
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBackfiller(http.DefaultClient, MarketSpot, NewWeightTracker(DefaultRESTWeightLimit), &FakeLogger{})
+
+	// The first half of the first day, then the rest of it and the next day
+	stats, err := b.BackfillKlines(context.Background(), layout, "BTCUSDT", "1m", day, day.Add(12*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Recorded != 720 || *requests != 1 {
+		t.Fatalf("expected 720 klines from one request, got %+v from %d requests", stats, *requests)
+	}
+	stats, err = b.BackfillKlines(context.Background(), layout, "BTCUSDT", "1m", day.Add(6*time.Hour), day.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Fetched != 2520 || stats.Recorded != 2160 || len(stats.Files) != 2 {
+		t.Fatalf("expected 2160 new klines in 2 files, got %+v", stats)
+	}
+	first, err := ReadParquetFile[Kline](layout.FilePath("kline_1m", "BTCUSDT", day, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 1440 || first[0].OpenTime != day.UnixMilli() || first[1439].OpenTime != day.Add(1439*time.Minute).UnixMilli() {
+		t.Errorf("expected the whole first day in order, got %d klines", len(first))
+	}
+	records, err := Query{Dir: layout.Root, Symbol: "BTCUSDT", DataType: "kline_1m", From: day.Add(24 * time.Hour), To: day.Add(25 * time.Hour)}.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 60 || records[0].(Kline).Volume != "10" {
+		t.Errorf("expected the second day's first hour through Query, got %d records", len(records))
+	}
+}
+
+func TestKlineInterval(t *testing.T) {
+	if interval, ok := KlineInterval(KlineDataType("1h")); !ok || interval != "1h" {
+		t.Errorf("expected 1h, got %q", interval)
+	}
+	for _, dataType := range []string{"kline_2m", "trade", "kline_"} {
+		if _, ok := KlineInterval(dataType); ok {
+			t.Errorf("expected %q not to be a kline data type", dataType)
+		}
+	}
+}
+
+func TestRunReferenceKlines_RecordsClosedKlinesOfTheDay(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := useFakeClock(t, day.Add(10*time.Minute+30*time.Second))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		param := "symbol"
+		if r.URL.Path == "/fapi/v1/indexPriceKlines" {
+			param = "pair"
+		}
+		if q.Get(param) != "BTCUSDT" {
+			http.Error(w, "missing "+param, http.StatusBadRequest)
+			return
+		}
+		start, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("endTime"), 10, 64)
+		var rows [][]any
+		for open := start; open <= end; open += 60000 {
+			rows = append(rows, []any{open, "1", "2", "0.5", "1.5", "0", open + 59999, "0", 60, "0", "0", "0"})
+		}
+		json.NewEncoder(w).Encode(rows)
+	}))
+	defer srv.Close()
+	old := FuturesRESTBaseURL
+	FuturesRESTBaseURL = srv.URL
+	defer func() { FuturesRESTBaseURL = old }()
+
+	layout := FileLayout{Root: t.TempDir()}
+	b := NewBackfiller(http.DefaultClient, MarketUSDM, NewWeightTracker(DefaultRESTWeightLimit), &FakeLogger{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunReferenceKlines(ctx, b, layout, func() []string { return []string{"BTCUSDT"} }, []string{"1m"}, &FakeLogger{})
+	}()
+	waitForWaiters(t, clock, 1)
+	clock.Advance(2 * time.Minute)
+
+	// The first check records the 10 klines closed since midnight, the next the 2 closed since
+	deadline := time.Now().Add(2 * time.Second)
+	for _, kind := range ReferenceKlineKinds {
+		path := layout.FilePath(ReferenceKlineDataType(kind, "1m"), "BTCUSDT", day, 0)
+		for {
+			klines, _ := ReadParquetFile[Kline](path)
+			if len(klines) == 12 && klines[11].OpenTime == day.Add(11*time.Minute).UnixMilli() {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected 12 closed %s klines, got %d", kind, len(klines))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	cancel()
+	<-done
+
+	if kind, interval, ok := ReferenceKlineKind("premiumIndexKline_1m"); !ok || kind != PremiumIndexKlines || interval != "1m" {
+		t.Errorf("expected a premium index kline data type, got %q %q %v", kind, interval, ok)
+	}
+	spot := NewBackfiller(http.DefaultClient, MarketSpot, NewWeightTracker(DefaultRESTWeightLimit), &FakeLogger{})
+	if err := spot.ReferenceKlines(context.Background(), IndexPriceKlines, "BTCUSDT", "1m", day, day.Add(time.Hour), nil); err == nil {
+		t.Error("expected reference klines to be rejected on spot")
+	}
+}
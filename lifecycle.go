@@ -0,0 +1,71 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// taskGroup owns a set of named goroutines sharing one context, like errgroup, so that shutdown stops and waits for
+// every one of them rather than leaving them running behind Run's back. A task's error, unless the group was
+// stopped, goes to the group's onError, which Run points at its fail.
+type taskGroup struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	onError func(error)
+	wg      sync.WaitGroup
+
+	mu sync.Mutex
+	// running counts the running tasks by name, for reporting those a shutdown timed out waiting for
+	running map[string]int
+}
+
+// newTaskGroup creates a group whose tasks stop when parent is cancelled or Stop is called.
+func newTaskGroup(parent context.Context, onError func(error)) *taskGroup {
+	ctx, cancel := context.WithCancel(parent)
+	return &taskGroup{ctx: ctx, cancel: cancel, onError: onError, running: make(map[string]int)}
+}
+
+// Go runs task in a new goroutine with the group's context.
+func (g *taskGroup) Go(name string, task func(ctx context.Context) error) {
+	g.mu.Lock()
+	g.running[name]++
+	g.mu.Unlock()
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			if g.running[name]--; g.running[name] == 0 {
+				delete(g.running, name)
+			}
+		}()
+		if err := task(g.ctx); err != nil && g.ctx.Err() == nil && !errors.Is(err, context.Canceled) {
+			g.onError(err)
+		}
+	}()
+}
+
+// Stop cancels the group's context.
+func (g *taskGroup) Stop() {
+	g.cancel()
+}
+
+// Wait waits for every task to return.
+func (g *taskGroup) Wait() {
+	g.wg.Wait()
+}
+
+// Running returns the names of the tasks still running, sorted, e.g. to report those a shutdown gave up on.
+func (g *taskGroup) Running() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	names := make([]string, 0, len(g.running))
+	for name := range g.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
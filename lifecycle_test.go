@@ -0,0 +1,59 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTaskGroup_StopsAndWaitsForTasks(t *testing.T) {
+	var errs []error
+	g := newTaskGroup(context.Background(), func(err error) { errs = append(errs, err) })
+	release := make(chan struct{})
+	finished := make(chan string, 3)
+	g.Go("sink", func(ctx context.Context) error {
+		<-ctx.Done()
+		finished <- "sink"
+		return ctx.Err()
+	})
+	g.Go("uploader", func(ctx context.Context) error {
+		<-release
+		finished <- "uploader"
+		return nil
+	})
+	if got := g.Running(); !reflect.DeepEqual(got, []string{"sink", "uploader"}) {
+		t.Errorf("expected both tasks running, got %v", got)
+	}
+
+	g.Stop()
+	<-finished
+	close(release)
+	g.Wait()
+	if len(finished) != 1 || len(g.Running()) != 0 {
+		t.Errorf("expected every task to have returned, still running %v", g.Running())
+	}
+	if len(errs) != 0 {
+		t.Errorf("expected errors after Stop to be ignored, got %v", errs)
+	}
+}
+
+func TestTaskGroup_ReportsTaskErrors(t *testing.T) {
+	reported := make(chan error, 1)
+	g := newTaskGroup(context.Background(), func(err error) { reported <- err })
+	g.Go("listener", func(ctx context.Context) error { return errors.New("unrecoverable") })
+	g.Wait()
+	if err := <-reported; err.Error() != "unrecoverable" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// Cancelling the parent stops the tasks like Stop
+	ctx, cancel := context.WithCancel(context.Background())
+	g = newTaskGroup(ctx, func(err error) { t.Errorf("unexpected error %v", err) })
+	g.Go("scheduler", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	cancel()
+	g.Wait()
+}
@@ -1,4 +1,4 @@
-package main
+package gobinapi
 
 import (
 	"fmt"
@@ -40,7 +40,10 @@ func FormatLog(level, message string, ts time.Time) string {
 
 // Log writes a log entry with the specified level and message. It obtains the current UTC timestamp and writes the log entry followed by a newline.
 func (l *Logger) Log(level, message string) error {
-	timestamp := time.Now().UTC()
+	timestamp := NowFunc().UTC()
+	if level == "ERROR" {
+		introspectError(timestamp, message)
+	}
 	entry := FormatLog(level, message, timestamp)
 	l.mu.Lock()
 	defer l.mu.Unlock()
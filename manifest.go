@@ -0,0 +1,136 @@
+package gobinapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ManifestEntry describes one finished recording file in a day's manifest, so loaders can discover and validate a
+// day's data without listing directories or opening files. Event times are exchange times where the record type has
+// them, and local write times otherwise.
+type ManifestEntry struct {
+	// File is the file's path relative to the layout root, with forward slashes
+	File         string    `json:"file"`
+	Symbol       string    `json:"symbol"`
+	DataType     string    `json:"data_type"`
+	Part         int       `json:"part"`
+	Rows         int64     `json:"rows"`
+	MinEventTime time.Time `json:"min_event_time"`
+	MaxEventTime time.Time `json:"max_event_time"`
+	Bytes        int64     `json:"bytes"`
+	// SHA256 is the hex encoded SHA-256 of the file's contents
+	SHA256 string `json:"sha256"`
+}
+
+// BuildManifestFileName constructs the name of the manifest listing the files of the UTC date of t, e.g.
+// "manifest_2023-10-15.json".
+func BuildManifestFileName(t time.Time) string {
+	return fmt.Sprintf("manifest_%s.json", t.UTC().Format("2006-01-02"))
+}
+
+// ManifestPath returns the path of the manifest of the UTC date of t. It is placed in the root for either layout,
+// since it lists the files of every instrument.
+func (l FileLayout) ManifestPath(t time.Time) string {
+	return filepath.Join(l.Root, BuildManifestFileName(t))
+}
+
+// manifestMu serializes updates of manifests, which the recorders of every instrument and data type share.
+var manifestMu sync.Mutex
+
+// ReadManifest reads a manifest file written by AppendManifest.
+func ReadManifest(filePath string) ([]ManifestEntry, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ManifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", filePath, err)
+	}
+	return entries, nil
+}
+
+// AppendManifest adds an entry to the manifest at filePath, creating it if necessary, or replaces the entry of the
+// same file. Like the part index, the manifest is replaced atomically so readers never see a partial file.
+func AppendManifest(filePath string, entry ManifestEntry) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	entries, err := ReadManifest(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	replaced := false
+	for i := range entries {
+		if entries[i].File == entry.File {
+			entries[i], replaced = entry, true
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename manifest to %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// FileChecksum returns the size and hex encoded SHA-256 of the file at filePath.
+func FileChecksum(filePath string) (int64, string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	return n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// SetManifest makes the recorder list every file it finishes under its final name in the day's manifest (see
+// FileLayout.ManifestPath). It must be called before the first Write.
+func (r *Recorder[T]) SetManifest(enabled bool) {
+	r.manifest = enabled
+}
+
+// appendManifest lists the just finished current file, of size bytes with checksum sum, in its day's manifest.
+func (r *Recorder[T]) appendManifest(size int64, sum string) error {
+	root := r.layout.Root
+	if root == "" {
+		root = "."
+	}
+	rel, err := filepath.Rel(root, r.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to locate %s in %s: %w", r.filePath, root, err)
+	}
+	entry := ManifestEntry{
+		File:         filepath.ToSlash(rel),
+		Symbol:       r.instrument,
+		DataType:     r.dataType,
+		Part:         r.part,
+		Rows:         r.partRows,
+		MinEventTime: r.partFirst,
+		MaxEventTime: r.partLast,
+		Bytes:        size,
+		SHA256:       sum,
+	}
+	return AppendManifest(r.layout.ManifestPath(r.fileStart), entry)
+}
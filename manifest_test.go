@@ -0,0 +1,87 @@
+package gobinapi
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRecorder_ListsFinishedFilesInManifest records two parts of a day in the hive layout and checks that the
+// manifest lists both with the rows, event times, size and checksum of the file on disk.
+func TestRecorder_ListsFinishedFilesInManifest(t *testing.T) {
+	day := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	useFakeClock(t, day)
+	root := t.TempDir()
+	oldLayout := DefaultFileLayout
+	defer func() { DefaultFileLayout = oldLayout }()
+	DefaultFileLayout = FileLayout{Root: root, Hive: true}
+
+	r, err := NewRecorder[Trade]("BTCUSDT", "trade", 2)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	r.SetManifest(true)
+	for id := int64(1); id <= 5; id++ {
+		if id == 4 {
+			if err := r.Rotate(); err != nil {
+				t.Fatalf("failed to rotate: %v", err)
+			}
+		}
+		trade := Trade{TradeID: id, Price: "100", Quantity: "1", TradeTime: day.Add(time.Duration(id) * time.Second).UnixMilli()}
+		if err := r.Write(trade); err != nil {
+			t.Fatalf("failed to write trade: %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	entries, err := ReadManifest(filepath.Join(root, "manifest_2025-02-19.json"))
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	want := []struct {
+		file     string
+		part     int
+		rows     int64
+		min, max int64
+	}{
+		{"symbol=BTCUSDT/date=2025-02-19/trade.parquet", 0, 3, 1, 3},
+		{"symbol=BTCUSDT/date=2025-02-19/trade_part001.parquet", 1, 2, 4, 5},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d manifest entries, got %+v", len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.File != w.file || e.Symbol != "BTCUSDT" || e.DataType != "trade" || e.Part != w.part || e.Rows != w.rows {
+			t.Errorf("unexpected entry %+v, want %+v", e, w)
+		}
+		if !e.MinEventTime.Equal(day.Add(time.Duration(w.min)*time.Second)) || !e.MaxEventTime.Equal(day.Add(time.Duration(w.max)*time.Second)) {
+			t.Errorf("unexpected event times %v to %v for %s", e.MinEventTime, e.MaxEventTime, e.File)
+		}
+		size, sum, err := FileChecksum(filepath.Join(root, filepath.FromSlash(e.File)))
+		if err != nil {
+			t.Fatalf("failed to checksum %s: %v", e.File, err)
+		}
+		if e.Bytes != size || e.SHA256 != sum {
+			t.Errorf("expected %d bytes with checksum %s for %s, got %d and %s", size, sum, e.File, e.Bytes, e.SHA256)
+		}
+	}
+}
+
+func TestAppendManifest_ReplacesEntryOfSameFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	for _, entry := range []ManifestEntry{{File: "a.parquet", Rows: 1}, {File: "b.parquet", Rows: 2}, {File: "a.parquet", Rows: 3}} {
+		if err := AppendManifest(path, entry); err != nil {
+			t.Fatalf("failed to append %s: %v", entry.File, err)
+		}
+	}
+	entries, err := ReadManifest(path)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	if len(entries) != 2 || entries[0].File != "a.parquet" || entries[0].Rows != 3 || entries[1].Rows != 2 {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+}
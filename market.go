@@ -0,0 +1,164 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Markets selectable through Config.Market.
+const (
+	// MarketSpot records the spot market from data-stream.binance.vision and api.binance.com.
+	MarketSpot = "spot"
+	// MarketUSDM records USD-M futures from fstream.binance.com and fapi.binance.com.
+	MarketUSDM = "usdm"
+)
+
+// FuturesStreamBaseURL and FuturesRESTBaseURL are the USD-M futures counterparts of StreamBaseURL and RESTBaseURL.
+// Tests point them at a local mock server.
+var (
+	FuturesStreamBaseURL = "wss://fstream.binance.com"
+	FuturesRESTBaseURL   = "https://fapi.binance.com"
+)
+
+// SpotTestnetStreamBaseURL, SpotTestnetRESTBaseURL, FuturesTestnetStreamBaseURL and FuturesTestnetRESTBaseURL are
+// the testnet counterparts of the markets' bases, selected by Config.Testnet.
+var (
+	SpotTestnetStreamBaseURL    = "wss://stream.testnet.binance.vision"
+	SpotTestnetRESTBaseURL      = "https://testnet.binance.vision"
+	FuturesTestnetStreamBaseURL = "wss://fstream.binancefuture.com"
+	FuturesTestnetRESTBaseURL   = "https://testnet.binancefuture.com"
+)
+
+// Endpoints returns the WebSocket and REST bases Run connects to, in order of preference: StreamEndpoints and
+// RESTEndpoints if set, otherwise the market's testnet bases if Testnet is set, and otherwise the market's
+// StreamBaseURL and RESTBaseURL or FuturesStreamBaseURL and FuturesRESTBaseURL.
+func (cfg Config) Endpoints() (streamBases, restBases []string) {
+	stream, rest := StreamBaseURL, RESTBaseURL
+	switch {
+	case cfg.Testnet && cfg.Market == MarketUSDM:
+		stream, rest = FuturesTestnetStreamBaseURL, FuturesTestnetRESTBaseURL
+	case cfg.Testnet:
+		stream, rest = SpotTestnetStreamBaseURL, SpotTestnetRESTBaseURL
+	case cfg.Market == MarketUSDM:
+		stream, rest = FuturesStreamBaseURL, FuturesRESTBaseURL
+	}
+	streamBases = cfg.StreamEndpoints
+	if len(streamBases) == 0 {
+		streamBases = []string{stream}
+	}
+	restBases = cfg.RESTEndpoints
+	if len(restBases) == 0 {
+		restBases = []string{rest}
+	}
+	return streamBases, restBases
+}
+
+// validateEndpoint checks that endpoint is a base URL with one of schemes and a host, such as
+// "wss://stream.binance.com:443" or "https://api1.binance.com". Paths are built onto it, so it must not end in a
+// slash or carry a query.
+func validateEndpoint(endpoint string, schemes ...string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("malformed endpoint %q: %w", endpoint, err)
+	}
+	if !slices.Contains(schemes, u.Scheme) || u.Host == "" {
+		return fmt.Errorf("endpoint %q must be a %s URL with a host", endpoint, strings.Join(schemes, " or "))
+	}
+	if strings.HasSuffix(u.Path, "/") || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("endpoint %q must not end in a slash or carry a query", endpoint)
+	}
+	return nil
+}
+
+// FuturesRESTWeightLimit is the USD-M futures per-minute request weight limit, lower than spot's.
+const FuturesRESTWeightLimit = 2400
+
+// futuresDepthLimits are the only depth snapshot limits the futures REST API accepts.
+var futuresDepthLimits = []int{5, 10, 20, 50, 100, 500, 1000}
+
+// ValidateMarket checks that market is MarketSpot or MarketUSDM.
+func ValidateMarket(market string) error {
+	switch market {
+	case MarketSpot, MarketUSDM:
+		return nil
+	}
+	return fmt.Errorf("unknown market %q, expected %q or %q", market, MarketSpot, MarketUSDM)
+}
+
+// validateDepthLimit checks that limit is a depth snapshot limit market accepts.
+func validateDepthLimit(market string, limit int) error {
+	if market != MarketUSDM {
+		if limit <= 0 || limit > 5000 {
+			return fmt.Errorf("must be between 1 and 5000, got %d", limit)
+		}
+		return nil
+	}
+	for _, l := range futuresDepthLimits {
+		if l == limit {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %v for USD-M futures, got %d", futuresDepthLimits, limit)
+}
+
+// FuturesDepthWeight is DepthWeight for the USD-M futures depth endpoint.
+func FuturesDepthWeight(limit int) int {
+	switch {
+	case limit <= 50:
+		return 2
+	case limit <= 100:
+		return 5
+	case limit <= 500:
+		return 10
+	default:
+		return 20
+	}
+}
+
+// FetchFuturesOrderBookSnapshot is FetchOrderBookSnapshotLimit against the USD-M futures REST API.
+func FetchFuturesOrderBookSnapshot(client *http.Client, instrument string, limit int) (*OrderBookSnapshot, error) {
+	return FetchFuturesOrderBookSnapshotContext(context.Background(), client, instrument, limit)
+}
+
+// FetchFuturesOrderBookSnapshotContext is FetchOrderBookSnapshotContext against the USD-M futures REST API.
+func FetchFuturesOrderBookSnapshotContext(ctx context.Context, client *http.Client, instrument string, limit int) (*OrderBookSnapshot, error) {
+	url := fmt.Sprintf("%s/fapi/v1/depth?symbol=%s&limit=%d", FuturesRESTBaseURL, instrument, limit)
+	return fetchOrderBookSnapshot(ctx, client, url, FuturesDepthWeight(limit))
+}
+
+// DiffSequencer decides whether an order book diff continues the sequence, given the last update ID of the snapshot
+// the book was synchronised from and of the last diff applied since. It returns whether to apply the diff, the new
+// last processed update ID and whether a gap was detected. ProcessOrderBookDiffMessage implements the spot rules.
+type DiffSequencer func(diff OrderBookDiff, lastSnapshotId, lastProcessedId int64) (bool, int64, bool)
+
+// ProcessFuturesOrderBookDiffMessage is ProcessOrderBookDiffMessage for USD-M futures. Diffs ending before the
+// snapshot are outdated, the first diff applied must span the snapshot's update ID, and every later diff must name
+// the previous diff's final update ID as its pu.
+func ProcessFuturesOrderBookDiffMessage(diff OrderBookDiff, lastSnapshotId, lastProcessedId int64) (bool, int64, bool) {
+	if diff.FinalUpdateID < lastSnapshotId {
+		return false, lastProcessedId, false
+	}
+	if lastProcessedId == lastSnapshotId && diff.PrevFinalUpdateID != lastProcessedId {
+		// No diff applied yet, unless the last one happened to end exactly at the snapshot
+		if diff.FirstUpdateID > lastSnapshotId {
+			return false, lastProcessedId, true
+		}
+		return true, diff.FinalUpdateID, false
+	}
+	if diff.PrevFinalUpdateID != lastProcessedId {
+		return false, lastProcessedId, true
+	}
+	return true, diff.FinalUpdateID, false
+}
+
+// SequencerFor returns the diff sequencing rules of market.
+func SequencerFor(market string) DiffSequencer {
+	if market == MarketUSDM {
+		return ProcessFuturesOrderBookDiffMessage
+	}
+	return ProcessOrderBookDiffMessage
+}
@@ -0,0 +1,95 @@
+package gobinapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func futuresDiff(first, final, prev int64) OrderBookDiff {
+	return OrderBookDiff{EventType: "depthUpdate", FirstUpdateID: first, FinalUpdateID: final, PrevFinalUpdateID: prev}
+}
+
+func TestProcessFuturesOrderBookDiffMessage(t *testing.T) {
+	cases := []struct {
+		name               string
+		diff               OrderBookDiff
+		snapshotID, lastID int64
+		wantOK             bool
+		wantLastID         int64
+		wantGap            bool
+	}{
+		{"outdated", futuresDiff(90, 99, 89), 100, 100, false, 100, false},
+		{"first spans snapshot", futuresDiff(95, 105, 94), 100, 100, true, 105, false},
+		{"first ends at snapshot", futuresDiff(95, 100, 94), 100, 100, true, 100, false},
+		{"first after snapshot", futuresDiff(102, 105, 101), 100, 100, false, 100, true},
+		{"continues", futuresDiff(106, 110, 105), 100, 105, true, 110, false},
+		{"continues after diff ending at snapshot", futuresDiff(101, 103, 100), 100, 100, true, 103, false},
+		// Futures IDs are not contiguous, so only pu links one diff to the next
+		{"continues with ID jump", futuresDiff(120, 130, 110), 100, 110, true, 130, false},
+		{"lost diff", futuresDiff(120, 130, 115), 100, 110, false, 110, true},
+	}
+	for _, tc := range cases {
+		ok, lastID, gap := ProcessFuturesOrderBookDiffMessage(tc.diff, tc.snapshotID, tc.lastID)
+		if ok != tc.wantOK || lastID != tc.wantLastID || gap != tc.wantGap {
+			t.Errorf("%s: got (%v, %d, %v), want (%v, %d, %v)", tc.name, ok, lastID, gap, tc.wantOK, tc.wantLastID, tc.wantGap)
+		}
+	}
+}
+
+func TestValidateDepthLimit(t *testing.T) {
+	if err := validateDepthLimit(MarketSpot, 5000); err != nil {
+		t.Errorf("expected 5000 to be a valid spot limit, got %v", err)
+	}
+	if err := validateDepthLimit(MarketUSDM, 1000); err != nil {
+		t.Errorf("expected 1000 to be a valid futures limit, got %v", err)
+	}
+	if err := validateDepthLimit(MarketUSDM, 200); err == nil || !strings.Contains(err.Error(), "USD-M futures") {
+		t.Errorf("expected 200 to be rejected for futures, got %v", err)
+	}
+	if err := ValidateMarket("coinm"); err == nil {
+		t.Errorf("expected an unknown market to be rejected")
+	}
+}
+
+func TestLocalOrderBook_FuturesSequencer(t *testing.T) {
+	book := NewLocalOrderBook()
+	book.SetSequencer(SequencerFor(MarketUSDM))
+	book.ApplyDiff(OrderBookDiff{FirstUpdateID: 8, FinalUpdateID: 12, PrevFinalUpdateID: 7, Bids: []PriceLevel{{"10", "2"}}})
+	if err := book.ApplySnapshot(OrderBookSnapshot{LastUpdateID: 10, Bids: []PriceLevel{{"10", "1"}}, Asks: []PriceLevel{{"11", "1"}}}); err != nil {
+		t.Fatalf("ApplySnapshot returned error: %v", err)
+	}
+	if err := book.ApplyDiff(OrderBookDiff{FirstUpdateID: 20, FinalUpdateID: 25, PrevFinalUpdateID: 12, Asks: []PriceLevel{{"11", "3"}}}); err != nil {
+		t.Fatalf("ApplyDiff returned error: %v", err)
+	}
+	top, ok := book.Top(1, 0)
+	if !ok || top.LastUpdateID != 25 || top.Bids[0].Quantity != "2" || top.Asks[0].Quantity != "3" {
+		t.Errorf("unexpected book top %+v (ok=%v)", top, ok)
+	}
+	if err := book.ApplyDiff(OrderBookDiff{FirstUpdateID: 30, FinalUpdateID: 31, PrevFinalUpdateID: 28}); err == nil || book.Synced() {
+		t.Errorf("expected a broken pu chain to drop the book, got %v", err)
+	}
+}
+
+func TestConfigEndpoints(t *testing.T) {
+	cfg := DefaultConfig()
+	if streams, rest := cfg.Endpoints(); len(streams) != 1 || streams[0] != StreamBaseURL || len(rest) != 1 || rest[0] != RESTBaseURL {
+		t.Errorf("expected the spot bases by default, got %v and %q", streams, rest)
+	}
+	cfg.Market, cfg.Testnet = MarketUSDM, true
+	if streams, rest := cfg.Endpoints(); len(streams) != 1 || streams[0] != FuturesTestnetStreamBaseURL || len(rest) != 1 || rest[0] != FuturesTestnetRESTBaseURL {
+		t.Errorf("expected the futures testnet, got %v and %q", streams, rest)
+	}
+	cfg.StreamEndpoints = []string{"wss://mirror.example.com:443"}
+	cfg.RESTEndpoints = []string{"https://mirror.example.com/binance", "https://api1.binance.com"}
+	if streams, rest := cfg.Endpoints(); len(streams) != 1 || streams[0] != cfg.StreamEndpoints[0] || len(rest) != 2 || rest[0] != cfg.RESTEndpoints[0] {
+		t.Errorf("expected the configured endpoints to win over the testnet, got %v and %q", streams, rest)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected mirror endpoints to be valid, got %v", err)
+	}
+	for _, endpoint := range []string{"stream.binance.com:9443", "wss://", "wss://stream.binance.com/", "wss://stream.binance.com?x=1"} {
+		if err := validateEndpoint(endpoint, "wss", "ws"); err == nil {
+			t.Errorf("expected %q to be rejected", endpoint)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package gobinapi
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MergeStats summarizes the result of merging two recordings of the same day/symbol/data type.
+type MergeStats struct {
+	RowsA      int
+	RowsB      int
+	UniqueA    int
+	UniqueB    int
+	Duplicates int
+	RowsOut    int
+	Primary    string
+}
+
+// RecordKey returns the exchange-assigned sequence ID used to deduplicate records of the given data type:
+// trade ID for trades, aggregate trade ID for aggTrades, final update ID for order book diffs, update ID for best
+// prices and last update ID for snapshots. Mark prices carry no sequence ID and are keyed by event time, of which
+// there is one per second, and klines by open time.
+func RecordKey(record interface{}) (int64, error) {
+	switch r := record.(type) {
+	case Trade:
+		return r.TradeID, nil
+	case AggTrade:
+		return r.AggTradeID, nil
+	case OrderBookDiff:
+		return r.FinalUpdateID, nil
+	case BestPrice:
+		return r.UpdateID, nil
+	case OrderBookSnapshot:
+		return r.LastUpdateID, nil
+	case MarkPrice:
+		return r.EventTime, nil
+	case AvgPrice:
+		return r.EventTime, nil
+	case OptionTrade:
+		return r.TradeID, nil
+	case Kline:
+		return r.OpenTime, nil
+	default:
+		return 0, fmt.Errorf("no deduplication key for record type %T", record)
+	}
+}
+
+// MergeRecords is the pure core of the merge tool. It deduplicates the union of a and b by key and returns the
+// result sorted by key. The input with more distinct keys (better coverage) is treated as primary: when both inputs
+// contain the same key, the primary's copy is kept. The returned bool is true when b was chosen as primary.
+func MergeRecords[T any](a, b []T, key func(T) int64) ([]T, bool, MergeStats) {
+	uniqueA := distinctKeys(a, key)
+	uniqueB := distinctKeys(b, key)
+	primary, secondary := a, b
+	bPrimary := len(uniqueB) > len(uniqueA)
+	if bPrimary {
+		primary, secondary = b, a
+	}
+
+	seen := make(map[int64]struct{}, len(a)+len(b))
+	merged := make([]T, 0, len(a)+len(b))
+	duplicates := 0
+	for _, records := range [][]T{primary, secondary} {
+		for _, rec := range records {
+			k := key(rec)
+			if _, ok := seen[k]; ok {
+				duplicates++
+				continue
+			}
+			seen[k] = struct{}{}
+			merged = append(merged, rec)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return key(merged[i]) < key(merged[j]) })
+
+	return merged, bPrimary, MergeStats{
+		RowsA:      len(a),
+		RowsB:      len(b),
+		UniqueA:    len(uniqueA),
+		UniqueB:    len(uniqueB),
+		Duplicates: duplicates,
+		RowsOut:    len(merged),
+	}
+}
+
+func distinctKeys[T any](records []T, key func(T) int64) map[int64]struct{} {
+	keys := make(map[int64]struct{}, len(records))
+	for _, rec := range records {
+		keys[key(rec)] = struct{}{}
+	}
+	return keys
+}
+
+// MergeRecordingFiles merges two parquet recordings of the same data type (e.g. from an active and a standby
+// instance) into a single canonical file at outPath.
+func MergeRecordingFiles(dataType string, pathA, pathB, outPath string) (MergeStats, error) {
+	switch dataType {
+	case "trade":
+		return mergeFiles[Trade](pathA, pathB, outPath)
+	case "aggTrade":
+		return mergeFiles[AggTrade](pathA, pathB, outPath)
+	case "orderBookDiff":
+		return mergeFiles[OrderBookDiff](pathA, pathB, outPath)
+	case "bestPrice":
+		return mergeFiles[BestPrice](pathA, pathB, outPath)
+	case "markPrice":
+		return mergeFiles[MarkPrice](pathA, pathB, outPath)
+	case "avgPrice":
+		return mergeFiles[AvgPrice](pathA, pathB, outPath)
+	case OptionTradeDataType:
+		return mergeFiles[OptionTrade](pathA, pathB, outPath)
+	case "snapshot", "snapshotTop":
+		return mergeFiles[OrderBookSnapshot](pathA, pathB, outPath)
+	default:
+		// Klines of every interval, including the reference price klines, are keyed by their open time
+		if _, ok := KlineInterval(dataType); ok {
+			return mergeFiles[Kline](pathA, pathB, outPath)
+		}
+		if _, _, ok := ReferenceKlineKind(dataType); ok {
+			return mergeFiles[Kline](pathA, pathB, outPath)
+		}
+		return MergeStats{}, fmt.Errorf("unsupported data type for merge: %s", dataType)
+	}
+}
+
+func mergeFiles[T any](pathA, pathB, outPath string) (MergeStats, error) {
+	if FileExists(outPath) {
+		return MergeStats{}, fmt.Errorf("output file %s already exists, refusing to overwrite", outPath)
+	}
+	a, err := ReadParquetFile[T](pathA)
+	if err != nil {
+		return MergeStats{}, err
+	}
+	b, err := ReadParquetFile[T](pathB)
+	if err != nil {
+		return MergeStats{}, err
+	}
+	key := func(rec T) int64 {
+		k, _ := RecordKey(rec)
+		return k
+	}
+	merged, bPrimary, stats := MergeRecords(a, b, key)
+	stats.Primary = pathA
+	if bPrimary {
+		stats.Primary = pathB
+	}
+	if err := WriteParquetFile(outPath, merged); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,121 @@
+package gobinapi
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeRecords_DeduplicatesAndPrefersBetterCoverage(t *testing.T) {
+	// a is missing trades 3 and 4 (e.g. a reconnect gap), b is missing trade 6
+	a := []Trade{{TradeID: 1, Price: "a"}, {TradeID: 2, Price: "a"}, {TradeID: 5, Price: "a"}, {TradeID: 6, Price: "a"}}
+	b := []Trade{{TradeID: 1, Price: "b"}, {TradeID: 2, Price: "b"}, {TradeID: 3, Price: "b"}, {TradeID: 4, Price: "b"}, {TradeID: 5, Price: "b"}}
+
+	merged, bPrimary, stats := MergeRecords(a, b, func(tr Trade) int64 { return tr.TradeID })
+	if !bPrimary {
+		t.Errorf("expected b to be primary as it has better coverage")
+	}
+	if len(merged) != 6 {
+		t.Fatalf("expected 6 merged trades, got %d", len(merged))
+	}
+	for i, tr := range merged {
+		if tr.TradeID != int64(i+1) {
+			t.Errorf("expected trade %d at index %d, got %d", i+1, i, tr.TradeID)
+		}
+	}
+	if merged[0].Price != "b" || merged[5].Price != "a" {
+		t.Errorf("expected duplicates to come from the primary and gaps filled from the secondary: %+v", merged)
+	}
+	if stats.Duplicates != 3 || stats.RowsOut != 6 || stats.UniqueA != 4 || stats.UniqueB != 5 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestMergeRecordingFiles_WritesCanonicalFile(t *testing.T) {
+	dir := t.TempDir()
+	pathA := filepath.Join(dir, "a.parquet")
+	pathB := filepath.Join(dir, "b.parquet")
+	out := filepath.Join(dir, "merged.parquet")
+
+	diffs := func(ids ...int64) []OrderBookDiff {
+		var res []OrderBookDiff
+		for _, id := range ids {
+			res = append(res, OrderBookDiff{
+				EventType:     "depthUpdate",
+				Symbol:        "BTCUSDT",
+				FirstUpdateID: id,
+				FinalUpdateID: id,
+				Bids:          []PriceLevel{{Price: "100", Quantity: "1"}},
+				Asks:          []PriceLevel{{Price: "101", Quantity: "2"}},
+			})
+		}
+		return res
+	}
+	if err := WriteParquetFile(pathA, diffs(10, 11, 13)); err != nil {
+		t.Fatalf("failed to write input a: %v", err)
+	}
+	if err := WriteParquetFile(pathB, diffs(11, 12)); err != nil {
+		t.Fatalf("failed to write input b: %v", err)
+	}
+
+	stats, err := MergeRecordingFiles("orderBookDiff", pathA, pathB, out)
+	if err != nil {
+		t.Fatalf("MergeRecordingFiles returned error: %v", err)
+	}
+	if stats.Primary != pathA {
+		t.Errorf("expected %s to be primary, got %s", pathA, stats.Primary)
+	}
+	merged, err := ReadParquetFile[OrderBookDiff](out)
+	if err != nil {
+		t.Fatalf("failed to read merged file: %v", err)
+	}
+	if len(merged) != 4 {
+		t.Fatalf("expected 4 merged diffs, got %d", len(merged))
+	}
+	for i, d := range merged {
+		if d.FinalUpdateID != int64(10+i) {
+			t.Errorf("expected update %d at index %d, got %d", 10+i, i, d.FinalUpdateID)
+		}
+		if len(d.Bids) != 1 || d.Bids[0].Price != "100" || len(d.Asks) != 1 || d.Asks[0].Quantity != "2" {
+			t.Errorf("price levels not preserved for update %d: %+v", d.FinalUpdateID, d)
+		}
+	}
+
+	if _, err := MergeRecordingFiles("orderBookDiff", pathA, pathB, out); err == nil {
+		t.Errorf("expected merge to refuse overwriting an existing output file")
+	}
+	if _, err := MergeRecordingFiles("unknown", pathA, pathB, filepath.Join(dir, "x.parquet")); err == nil {
+		t.Errorf("expected error for unsupported data type")
+	}
+}
+
+func TestMergeRecordingFiles_MergesKlines(t *testing.T) {
+	dir := t.TempDir()
+	pathA := filepath.Join(dir, "a.parquet")
+	pathB := filepath.Join(dir, "b.parquet")
+	out := filepath.Join(dir, "merged.parquet")
+	klines := func(openTimes ...int64) []Kline {
+		var res []Kline
+		for _, openTime := range openTimes {
+			res = append(res, Kline{OpenTime: openTime, CloseTime: openTime + 59999, Open: "100", Close: "101"})
+		}
+		return res
+	}
+	if err := WriteParquetFile(pathA, klines(1739966400000, 1739966460000)); err != nil {
+		t.Fatalf("failed to write input a: %v", err)
+	}
+	if err := WriteParquetFile(pathB, klines(1739966460000, 1739966520000)); err != nil {
+		t.Fatalf("failed to write input b: %v", err)
+	}
+
+	stats, err := MergeRecordingFiles(KlineDataType("1m"), pathA, pathB, out)
+	if err != nil {
+		t.Fatalf("MergeRecordingFiles returned error: %v", err)
+	}
+	merged, err := ReadParquetFile[Kline](out)
+	if err != nil {
+		t.Fatalf("failed to read merged file: %v", err)
+	}
+	if len(merged) != 3 || stats.Duplicates != 1 || merged[2].OpenTime != 1739966520000 {
+		t.Errorf("expected 3 klines in open time order with 1 duplicate, got %+v (%+v)", merged, stats)
+	}
+}
@@ -0,0 +1,202 @@
+package gobinapi
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MetricsRegistry is a minimal, dependency-free metrics registry that renders the Prometheus text exposition
+// format. Counters and gauges are identified by a metric name plus a set of labels; gauge functions are evaluated at
+// scrape time, which suits values such as "seconds since last reconnect".
+type MetricsRegistry struct {
+	mu     sync.Mutex
+	help   map[string]string
+	kinds  map[string]string
+	values map[string]map[string]float64
+	funcs  map[string]map[string]func() float64
+}
+
+// Labels identifies one series of a metric, e.g. Labels{"stream": "btcusdt@trade"}.
+type Labels map[string]string
+
+// DefaultMetrics is the registry used by the recorder's components and served on /metrics.
+var DefaultMetrics = NewMetricsRegistry()
+
+// NewMetricsRegistry creates an empty registry.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		help:   make(map[string]string),
+		kinds:  make(map[string]string),
+		values: make(map[string]map[string]float64),
+		funcs:  make(map[string]map[string]func() float64),
+	}
+}
+
+// Describe sets the help text and type ("counter" or "gauge") reported for a metric.
+func (r *MetricsRegistry) Describe(name, kind, help string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.help[name] = help
+	r.kinds[name] = kind
+}
+
+// Add increments a counter series by delta.
+func (r *MetricsRegistry) Add(name string, labels Labels, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	series := r.series(name)
+	series[labels.key()] += delta
+}
+
+// Set sets a gauge series to value.
+func (r *MetricsRegistry) Set(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	series := r.series(name)
+	series[labels.key()] = value
+}
+
+// SetFunc registers a gauge series whose value is computed by fn at scrape time, replacing any previous function
+// for the same series.
+func (r *MetricsRegistry) SetFunc(name string, labels Labels, fn func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.funcs[name] == nil {
+		r.funcs[name] = make(map[string]func() float64)
+	}
+	r.funcs[name][labels.key()] = fn
+}
+
+// Value returns the current value of a counter or gauge series, evaluating gauge functions.
+func (r *MetricsRegistry) Value(name string, labels Labels) float64 {
+	r.mu.Lock()
+	key := labels.key()
+	if fn, ok := r.funcs[name][key]; ok {
+		r.mu.Unlock()
+		return fn()
+	}
+	defer r.mu.Unlock()
+	return r.values[name][key]
+}
+
+func (r *MetricsRegistry) series(name string) map[string]float64 {
+	series, ok := r.values[name]
+	if !ok {
+		series = make(map[string]float64)
+		r.values[name] = series
+	}
+	return series
+}
+
+// WritePrometheus renders every metric in the Prometheus text exposition format, sorted by name and labels.
+func (r *MetricsRegistry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	names := make(map[string]struct{})
+	samples := make(map[string]map[string]float64)
+	for name, series := range r.values {
+		names[name] = struct{}{}
+		samples[name] = make(map[string]float64, len(series))
+		for k, v := range series {
+			samples[name][k] = v
+		}
+	}
+	funcs := make(map[string]map[string]func() float64)
+	for name, series := range r.funcs {
+		names[name] = struct{}{}
+		funcs[name] = make(map[string]func() float64, len(series))
+		for k, fn := range series {
+			funcs[name][k] = fn
+		}
+	}
+	help := make(map[string]string, len(r.help))
+	kinds := make(map[string]string, len(r.kinds))
+	for k, v := range r.help {
+		help[k] = v
+	}
+	for k, v := range r.kinds {
+		kinds[k] = v
+	}
+	r.mu.Unlock()
+
+	// Gauge functions are evaluated outside the lock, as they may call back into other components
+	for name, series := range funcs {
+		if samples[name] == nil {
+			samples[name] = make(map[string]float64, len(series))
+		}
+		for k, fn := range series {
+			samples[name][k] = fn()
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		if h, ok := help[name]; ok {
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n", name, h); err != nil {
+				return err
+			}
+		}
+		if k, ok := kinds[name]; ok {
+			if _, err := fmt.Fprintf(w, "# TYPE %s %s\n", name, k); err != nil {
+				return err
+			}
+		}
+		keys := make([]string, 0, len(samples[name]))
+		for k := range samples[name] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", name, k, formatMetricValue(samples[name][k])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Handler returns an http.Handler serving the registry in the Prometheus text format.
+func (r *MetricsRegistry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WritePrometheus(w)
+	})
+}
+
+// key renders labels in canonical (sorted) Prometheus form, e.g. {stream="btcusdt@trade"}.
+func (l Labels) key() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.Quote(l[name])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,54 @@
+package gobinapi
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsRegistry_WritePrometheus(t *testing.T) {
+	r := NewMetricsRegistry()
+	r.Describe("requests_total", "counter", "Requests served.")
+	r.Add("requests_total", Labels{"stream": "b", "kind": "x"}, 2)
+	r.Add("requests_total", Labels{"stream": "a"}, 1)
+	r.Add("requests_total", Labels{"stream": "a"}, 1)
+	r.Set("depth", nil, 3.5)
+	r.SetFunc("uptime_seconds", Labels{"stream": "a"}, func() float64 { return 42 })
+
+	var buf bytes.Buffer
+	if err := r.WritePrometheus(&buf); err != nil {
+		t.Fatalf("WritePrometheus returned error: %v", err)
+	}
+	expected := strings.Join([]string{
+		"depth 3.5",
+		"# HELP requests_total Requests served.",
+		"# TYPE requests_total counter",
+		`requests_total{kind="x",stream="b"} 2`,
+		`requests_total{stream="a"} 2`,
+		`uptime_seconds{stream="a"} 42`,
+		"",
+	}, "\n")
+	if buf.String() != expected {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+	if v := r.Value("requests_total", Labels{"stream": "a"}); v != 2 {
+		t.Errorf("expected counter value 2, got %v", v)
+	}
+	if v := r.Value("uptime_seconds", Labels{"stream": "a"}); v != 42 {
+		t.Errorf("expected gauge function value 42, got %v", v)
+	}
+}
+
+func TestMetricsRegistry_Handler(t *testing.T) {
+	r := NewMetricsRegistry()
+	r.Set("up", nil, 1)
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("unexpected content type %q", rec.Header().Get("Content-Type"))
+	}
+	if rec.Body.String() != "up 1\n" {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,140 @@
+package gobinapi
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// MidPriceDataType is the data type of the mid price series derived from best prices (see MidPriceSampler).
+const MidPriceDataType = "midPrice"
+
+// MidPrice is a sample of a symbol's top of book, derived from its best prices for research that needs a compact
+// price series rather than every book ticker.
+type MidPrice struct {
+	// Time is the exchange event time of the best price sampled or, for spot, which sends none, the local time it was
+	// received at, in milliseconds.
+	Time     int64   `json:"time" timestamp:"millis" parquet:"name=time, type=INT64"`
+	UpdateID int64   `json:"update_id" parquet:"name=update_id, type=INT64"`
+	Mid      float64 `json:"mid" parquet:"name=mid, type=DOUBLE"`
+	Spread   float64 `json:"spread" parquet:"name=spread, type=DOUBLE"`
+	// SpreadBps is the spread in basis points of the mid price.
+	SpreadBps float64 `json:"spread_bps" parquet:"name=spread_bps, type=DOUBLE"`
+	// Microprice weighs the bid and ask prices by the size on the opposite side, (bid × askQty + ask × bidQty) /
+	// (bidQty + askQty), leaning towards the side more likely to be traded through next.
+	Microprice float64 `json:"microprice" parquet:"name=microprice, type=DOUBLE"`
+}
+
+// MidPriceConfig configures the mid price series.
+type MidPriceConfig struct {
+	// Interval conflates the series to at most one sample per interval, e.g. 100ms: the last best price of each
+	// interval since the epoch that had updates. Zero samples every best price.
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval"`
+	// ChangeOnly skips samples whose mid price, spread and microprice equal the previous sample's.
+	ChangeOnly bool `json:"change_only" yaml:"change_only"`
+}
+
+// Validate checks the settings.
+func (c MidPriceConfig) Validate() error {
+	if c.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	if c.Interval > 0 && c.Interval < time.Millisecond {
+		return errors.New("interval must be at least 1ms")
+	}
+	return nil
+}
+
+// ComputeMidPrice is a pure function deriving the mid price sample of a best price received at receivedAt. It
+// returns false if the best price has no valid two-sided book.
+func ComputeMidPrice(bp BestPrice, receivedAt time.Time) (MidPrice, bool) {
+	bid, err1 := strconv.ParseFloat(bp.BidPrice, 64)
+	ask, err2 := strconv.ParseFloat(bp.AskPrice, 64)
+	bidQty, err3 := strconv.ParseFloat(bp.BidQty, 64)
+	askQty, err4 := strconv.ParseFloat(bp.AskQty, 64)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || bid <= 0 || ask <= 0 || bidQty+askQty <= 0 {
+		return MidPrice{}, false
+	}
+	at := bp.EventTime
+	if at == 0 {
+		at = receivedAt.UnixMilli()
+	}
+	mid := (bid + ask) / 2
+	spread := ask - bid
+	return MidPrice{
+		Time:       at,
+		UpdateID:   bp.UpdateID,
+		Mid:        mid,
+		Spread:     spread,
+		SpreadBps:  spread / mid * 1e4,
+		Microprice: (bid*askQty + ask*bidQty) / (bidQty + askQty),
+	}, true
+}
+
+// MidPriceSampler derives the mid price series of a symbol from its best prices and writes it to the next sink. With
+// an interval, the sample of an interval is written once a best price of a later interval arrives, and the one in
+// progress on Close; an interval without updates has no sample, the previous one still holding.
+//
+// MidPriceSampler is a Sink[BestPrice], so it can be added to a book ticker stream's sinks; its other Sink methods
+// pass through to next.
+type MidPriceSampler struct {
+	Sink[MidPrice]
+	config MidPriceConfig
+
+	// pending is the latest sample of the current interval, not yet written
+	pending    MidPrice
+	hasPending bool
+	last       MidPrice
+	written    bool
+}
+
+// NewMidPriceSampler creates a sampler writing to next.
+func NewMidPriceSampler(config MidPriceConfig, next Sink[MidPrice]) *MidPriceSampler {
+	return &MidPriceSampler{Sink: next, config: config}
+}
+
+// Write samples a best price. Best prices without a valid two-sided book are skipped.
+func (s *MidPriceSampler) Write(bp BestPrice) error {
+	sample, ok := ComputeMidPrice(bp, NowFunc())
+	if !ok {
+		return nil
+	}
+	if s.config.Interval <= 0 {
+		return s.emit(sample)
+	}
+	interval := s.config.Interval.Milliseconds()
+	var err error
+	if s.hasPending && sample.Time/interval != s.pending.Time/interval {
+		err = s.flush()
+	}
+	s.pending, s.hasPending = sample, true
+	return err
+}
+
+// Close writes the sample in progress and closes the next sink.
+func (s *MidPriceSampler) Close() error {
+	err := s.flush()
+	if closeErr := s.Sink.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// flush writes the pending sample, if any.
+func (s *MidPriceSampler) flush() error {
+	if !s.hasPending {
+		return nil
+	}
+	s.hasPending = false
+	return s.emit(s.pending)
+}
+
+// emit writes a sample unless it is unchanged in change-only mode.
+func (s *MidPriceSampler) emit(sample MidPrice) error {
+	if s.config.ChangeOnly && s.written && sample.Mid == s.last.Mid && sample.Spread == s.last.Spread &&
+		sample.Microprice == s.last.Microprice {
+		return nil
+	}
+	s.last, s.written = sample, true
+	return s.Sink.Write(sample)
+}
@@ -0,0 +1,100 @@
+package gobinapi
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var _ Sink[BestPrice] = (*MidPriceSampler)(nil)
+
+func TestComputeMidPrice(t *testing.T) {
+	received := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	got, ok := ComputeMidPrice(BestPrice{UpdateID: 7, BidPrice: "99", BidQty: "3", AskPrice: "101", AskQty: "1"}, received)
+	if !ok {
+		t.Fatal("expected a mid price")
+	}
+	if got.Time != received.UnixMilli() || got.UpdateID != 7 || got.Mid != 100 || got.Spread != 2 || got.SpreadBps != 200 {
+		t.Errorf("unexpected mid price %+v", got)
+	}
+	// Three times the size on the bid leans the microprice towards the ask: (99×1 + 101×3) / 4
+	if math.Abs(got.Microprice-100.5) > 1e-9 {
+		t.Errorf("expected microprice 100.5, got %v", got.Microprice)
+	}
+	if got, _ := ComputeMidPrice(BestPrice{EventTime: 5, BidPrice: "1", BidQty: "1", AskPrice: "2", AskQty: "1"}, received); got.Time != 5 {
+		t.Errorf("expected the event time to be used, got %d", got.Time)
+	}
+	for _, bp := range []BestPrice{
+		{BidPrice: "0", BidQty: "1", AskPrice: "101", AskQty: "1"},
+		{BidPrice: "99", BidQty: "0", AskPrice: "101", AskQty: "0"},
+		{BidPrice: "", BidQty: "1", AskPrice: "101", AskQty: "1"},
+	} {
+		if _, ok := ComputeMidPrice(bp, received); ok {
+			t.Errorf("expected %+v to have no mid price", bp)
+		}
+	}
+}
+
+func TestMidPriceSampler_ConflatesPerInterval(t *testing.T) {
+	next := &fakeSink{}
+	s := NewMidPriceSampler(MidPriceConfig{Interval: 100 * time.Millisecond, ChangeOnly: true}, UntypedSink[MidPrice](next))
+	base := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC).UnixMilli()
+	quote := func(at int64, bid string) BestPrice {
+		return BestPrice{EventTime: base + at, UpdateID: at, BidPrice: bid, BidQty: "1", AskPrice: "101", AskQty: "1"}
+	}
+	for _, bp := range []BestPrice{
+		quote(10, "99"), quote(50, "100"), // the first interval's last update is kept
+		quote(150, "100"), // unchanged, so dropped
+		quote(420, "98"),
+		quote(430, "0"), // no bid, skipped
+		quote(520, "97"),
+	} {
+		if err := s.Write(bp); err != nil {
+			t.Fatalf("failed to write best price: %v", err)
+		}
+	}
+	if len(next.records) != 2 || next.records[0].(MidPrice).UpdateID != 50 {
+		t.Fatalf("expected the samples of the finished intervals, got %+v", next.records)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("failed to close sampler: %v", err)
+	}
+	var ids []int64
+	for _, r := range next.records {
+		ids = append(ids, r.(MidPrice).UpdateID)
+	}
+	if len(ids) != 3 || ids[1] != 420 || ids[2] != 520 || len(next.calls) != 1 || next.calls[0] != "close" {
+		t.Errorf("expected samples 50, 420 and 520 before closing, got %v and calls %v", ids, next.calls)
+	}
+}
+
+func TestMidPriceSampler_RecordsEveryUpdateWithoutInterval(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	if err := (MidPriceConfig{Interval: -time.Second}).Validate(); err == nil {
+		t.Error("expected a negative interval to be rejected")
+	}
+	rec, err := NewRecorder[MidPrice]("BTCUSDT", MidPriceDataType, 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	s := NewMidPriceSampler(MidPriceConfig{}, rec)
+	for i := int64(1); i <= 3; i++ {
+		if err := s.Write(BestPrice{UpdateID: i, BidPrice: "100", BidQty: "1", AskPrice: "100.1", AskQty: "2"}); err != nil {
+			t.Fatalf("failed to write best price: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("failed to close sampler: %v", err)
+	}
+
+	path := filepath.Join(".", BuildFileName(MidPriceDataType, "BTCUSDT", time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)))
+	records, err := ReadRecordingFile(MidPriceDataType, path)
+	if err != nil {
+		t.Fatalf("failed to read mid prices: %v", err)
+	}
+	if len(records) != 3 || records[2].(MidPrice).UpdateID != 3 || records[0].(MidPrice).Time == 0 {
+		t.Errorf("expected every update sampled at the receive time, got %+v", records)
+	}
+}
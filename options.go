@@ -0,0 +1,326 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// OptionsStreamBaseURL is the scheme and host that Binance Options (EAPI) stream URLs are built from. Tests point it
+// at a local mock server.
+var OptionsStreamBaseURL = "wss://nbstream.binance.com/eoptions"
+
+// Data types of the Binance Options streams.
+const (
+	OptionTradeDataType        = "optionTrade"
+	OptionTickerDataType       = "optionTicker"
+	OptionMarkPriceDataType    = "optionMarkPrice"
+	OptionOpenInterestDataType = "optionOpenInterest"
+)
+
+// OptionTrade is a trade of an option contract.
+type OptionTrade struct {
+	EventType string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventTime int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	Symbol    string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	TradeID   int64  `json:"t" parquet:"name=trade_id, type=INT64"`
+	Price     string `json:"p" decimal:"true" parquet:"name=price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// Quantity is in contracts.
+	Quantity    string `json:"q" decimal:"true" parquet:"name=quantity, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BuyOrderID  int64  `json:"b" parquet:"name=buy_order_id, type=INT64"`
+	SellOrderID int64  `json:"a" parquet:"name=sell_order_id, type=INT64"`
+	TradeTime   int64  `json:"T" timestamp:"millis" parquet:"name=trade_time, type=INT64"`
+	// Direction is the taker's side, 1 if they bought and -1 if they sold.
+	Direction int64 `json:"S" parquet:"name=direction, type=INT64"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// UnmarshalJSON decodes an option trade, whose IDs and direction the exchange sends as numbers or numeric strings.
+func (t *OptionTrade) UnmarshalJSON(data []byte) error {
+	type plain OptionTrade
+	wire := struct {
+		*plain
+		TradeID     json.Number `json:"t"`
+		BuyOrderID  json.Number `json:"b"`
+		SellOrderID json.Number `json:"a"`
+		Direction   json.Number `json:"S"`
+	}{plain: (*plain)(t)}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	return parseNumbers(map[string]*int64{"t": &t.TradeID, "b": &t.BuyOrderID, "a": &t.SellOrderID, "S": &t.Direction},
+		map[string]json.Number{"t": wire.TradeID, "b": wire.BuyOrderID, "a": wire.SellOrderID, "S": wire.Direction})
+}
+
+// OptionTicker is an option contract's 24 hour ticker, sent once a second, with its best prices, mark price and
+// greeks.
+type OptionTicker struct {
+	EventType       string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventTime       int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	TransactionTime int64  `json:"T" timestamp:"millis" parquet:"name=transaction_time, type=INT64"`
+	Symbol          string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	OpenPrice       string `json:"o" decimal:"true" parquet:"name=open_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	HighPrice       string `json:"h" decimal:"true" parquet:"name=high_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LowPrice        string `json:"l" decimal:"true" parquet:"name=low_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LastPrice       string `json:"c" decimal:"true" parquet:"name=last_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// Volume is in contracts and Amount in quote units.
+	Volume             string `json:"V" decimal:"true" parquet:"name=volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Amount             string `json:"A" decimal:"true" parquet:"name=amount, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PriceChangePercent string `json:"P" decimal:"true" parquet:"name=price_change_percent, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PriceChange        string `json:"p" decimal:"true" parquet:"name=price_change, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LastQty            string `json:"Q" decimal:"true" parquet:"name=last_qty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	FirstTradeID       int64  `json:"F" parquet:"name=first_trade_id, type=INT64"`
+	LastTradeID        int64  `json:"L" parquet:"name=last_trade_id, type=INT64"`
+	Trades             int64  `json:"n" parquet:"name=trades, type=INT64"`
+	BidPrice           string `json:"bo" decimal:"true" parquet:"name=bid_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AskPrice           string `json:"ao" decimal:"true" parquet:"name=ask_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BidQty             string `json:"bq" decimal:"true" parquet:"name=bid_qty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AskQty             string `json:"aq" decimal:"true" parquet:"name=ask_qty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// BidIV and AskIV are the implied volatilities of the best bid and ask, ImpliedVolatility that of the mark price.
+	BidIV             string `json:"b" decimal:"true" parquet:"name=bid_iv, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AskIV             string `json:"a" decimal:"true" parquet:"name=ask_iv, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ImpliedVolatility string `json:"vo" decimal:"true" parquet:"name=implied_volatility, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Delta             string `json:"d" decimal:"true" parquet:"name=delta, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Theta             string `json:"t" decimal:"true" parquet:"name=theta, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Gamma             string `json:"g" decimal:"true" parquet:"name=gamma, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Vega              string `json:"v" decimal:"true" parquet:"name=vega, type=BYTE_ARRAY, convertedtype=UTF8"`
+	MarkPrice         string `json:"mp" decimal:"true" parquet:"name=mark_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// HighPriceLimit and LowPriceLimit bound the prices orders may be placed at.
+	HighPriceLimit string `json:"hl" decimal:"true" parquet:"name=high_price_limit, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LowPriceLimit  string `json:"ll" decimal:"true" parquet:"name=low_price_limit, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// ExercisePrice is the estimated settlement price, set in the hour before expiry.
+	ExercisePrice string `json:"eep" decimal:"true" parquet:"name=exercise_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// UnmarshalJSON decodes an option ticker, whose trade IDs the exchange sends as numbers or numeric strings.
+func (t *OptionTicker) UnmarshalJSON(data []byte) error {
+	type plain OptionTicker
+	wire := struct {
+		*plain
+		FirstTradeID json.Number `json:"F"`
+		LastTradeID  json.Number `json:"L"`
+	}{plain: (*plain)(t)}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	return parseNumbers(map[string]*int64{"F": &t.FirstTradeID, "L": &t.LastTradeID},
+		map[string]json.Number{"F": wire.FirstTradeID, "L": wire.LastTradeID})
+}
+
+// OptionMarkPrice is the mark price of one option contract, from the mark prices of every option of an underlying
+// asset sent once a second.
+type OptionMarkPrice struct {
+	EventType string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventTime int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	Symbol    string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	MarkPrice string `json:"mp" decimal:"true" parquet:"name=mark_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// OptionOpenInterest is the open interest of one option contract, from the open interest of every option of an
+// underlying asset and expiration date sent every minute.
+type OptionOpenInterest struct {
+	EventType string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventTime int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	Symbol    string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	// OpenInterest is in contracts and OpenInterestValue in quote units.
+	OpenInterest      string `json:"o" decimal:"true" parquet:"name=open_interest, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OpenInterestValue string `json:"h" decimal:"true" parquet:"name=open_interest_value, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// parseNumbers sets each of dst to its number in src, leaving those the message did not have at zero.
+func parseNumbers(dst map[string]*int64, src map[string]json.Number) error {
+	for field, n := range src {
+		if n == "" {
+			continue
+		}
+		v, err := n.Int64()
+		if err != nil {
+			return fmt.Errorf("field %q must be an integer, got %q", field, n)
+		}
+		*dst[field] = v
+	}
+	return nil
+}
+
+// OptionsConfig selects the Binance Options streams to record. Each stream has its own connection to
+// OptionsStreamBaseURL and records until Run stops.
+type OptionsConfig struct {
+	// Symbols are option contracts, like BTC-250328-100000-C, whose trades (OptionTradeDataType) and tickers with
+	// greeks (OptionTickerDataType) are recorded.
+	Symbols []string `json:"symbols,omitempty" yaml:"symbols"`
+	// Underlyings are underlying assets, like BTC, the mark prices of whose options are recorded
+	// (OptionMarkPriceDataType) to one file per underlying.
+	Underlyings []string `json:"underlyings,omitempty" yaml:"underlyings"`
+	// Expirations are underlying assets and expiration dates, like BTC-250328, the open interest of whose options is
+	// recorded (OptionOpenInterestDataType) to one file per expiration.
+	Expirations []string `json:"expirations,omitempty" yaml:"expirations"`
+}
+
+var (
+	optionSymbolPattern     = regexp.MustCompile(`^[A-Z0-9]+-\d{6}-\d+(\.\d+)?-[CP]$`)
+	optionUnderlyingPattern = regexp.MustCompile(`^[A-Z0-9]+$`)
+	optionExpirationPattern = regexp.MustCompile(`^[A-Z0-9]+-\d{6}$`)
+)
+
+// Validate checks the settings.
+func (c OptionsConfig) Validate() error {
+	if len(c.Symbols)+len(c.Underlyings)+len(c.Expirations) == 0 {
+		return errors.New("no symbols, underlyings or expirations to record")
+	}
+	for _, check := range []struct {
+		values  []string
+		pattern *regexp.Regexp
+		what    string
+	}{
+		{c.Symbols, optionSymbolPattern, "option symbol"},
+		{c.Underlyings, optionUnderlyingPattern, "underlying"},
+		{c.Expirations, optionExpirationPattern, "expiration"},
+	} {
+		for _, v := range check.values {
+			if !check.pattern.MatchString(v) {
+				return fmt.Errorf("malformed %s %q", check.what, v)
+			}
+		}
+	}
+	return nil
+}
+
+// OptionTradeStream returns the name of an option contract's trade stream, e.g. "BTC-250328-100000-C@trade".
+func OptionTradeStream(symbol string) string {
+	return symbol + "@trade"
+}
+
+// OptionTickerStream returns the name of an option contract's ticker stream.
+func OptionTickerStream(symbol string) string {
+	return symbol + "@ticker"
+}
+
+// OptionMarkPriceStream returns the name of the stream of the mark prices of an underlying's options, e.g.
+// "BTC@markPrice".
+func OptionMarkPriceStream(underlying string) string {
+	return underlying + "@markPrice"
+}
+
+// OptionOpenInterestStream returns the name of the stream of the open interest of an expiration's options, e.g.
+// "BTC@openInterest@250328" for BTC-250328.
+func OptionOpenInterestStream(expiration string) string {
+	underlying, date, _ := strings.Cut(expiration, "-")
+	return underlying + "@openInterest@" + date
+}
+
+// decodeOptionRows is a pure function decoding an options stream message, a single event or an array of them,
+// bare or wrapped in a combined stream message, into its rows.
+func decodeOptionRows[T any](msg []byte) ([]T, error) {
+	var combined struct {
+		Stream string          `json:"stream"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if len(msg) > 0 && msg[0] == '{' {
+		if err := json.Unmarshal(msg, &combined); err == nil && combined.Stream != "" {
+			msg = combined.Data
+		}
+	}
+	var rows []T
+	if len(msg) > 0 && msg[0] == '[' {
+		if err := json.Unmarshal(msg, &rows); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %T array: %w, raw message: %s", *new(T), err, msg)
+		}
+		return rows, nil
+	}
+	var row T
+	if err := json.Unmarshal(msg, &row); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %T: %w, raw message: %s", row, err, msg)
+	}
+	return []T{row}, nil
+}
+
+// optionHandler returns the handler decoding the messages of an options stream and writing their rows, stamped
+// with the session by stamp, to recorder.
+func optionHandler[T any](recorder RecorderWriter[T], stamp func(*T, WSSession)) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
+		rows, err := decodeOptionRows[T](msg)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			stamp(&row, session)
+			if err := recorder.Write(row); err != nil {
+				return fmt.Errorf("error writing %T: %w", row, err)
+			}
+		}
+		return nil
+	}
+}
+
+// listenOption listens to an options stream against the given stream base URL, writing its rows to recorder.
+func listenOption[T any](ctx context.Context, base, stream string, recorder RecorderWriter[T], stamp func(*T, WSSession)) error {
+	return listenWebSocket(ctx, base+"/ws/"+stream, optionHandler(recorder, stamp))
+}
+
+// ListenOptionTrades subscribes to the trades of an option contract and writes them to recorder.
+func ListenOptionTrades(ctx context.Context, symbol string, recorder RecorderWriter[OptionTrade]) error {
+	return listenOptionTrades(ctx, OptionsStreamBaseURL, symbol, recorder)
+}
+
+// listenOptionTrades is ListenOptionTrades against the given stream base URL.
+func listenOptionTrades(ctx context.Context, base, symbol string, recorder RecorderWriter[OptionTrade]) error {
+	return listenOption(ctx, base, OptionTradeStream(symbol), recorder, func(t *OptionTrade, s WSSession) {
+		t.ConnID, t.ConnGeneration = s.ID, s.Generation
+	})
+}
+
+// ListenOptionTickers subscribes to the ticker of an option contract and writes it to recorder.
+func ListenOptionTickers(ctx context.Context, symbol string, recorder RecorderWriter[OptionTicker]) error {
+	return listenOptionTickers(ctx, OptionsStreamBaseURL, symbol, recorder)
+}
+
+// listenOptionTickers is ListenOptionTickers against the given stream base URL.
+func listenOptionTickers(ctx context.Context, base, symbol string, recorder RecorderWriter[OptionTicker]) error {
+	return listenOption(ctx, base, OptionTickerStream(symbol), recorder, func(t *OptionTicker, s WSSession) {
+		t.ConnID, t.ConnGeneration = s.ID, s.Generation
+	})
+}
+
+// ListenOptionMarkPrices subscribes to the mark prices of every option of underlying and writes a row per option
+// to recorder.
+func ListenOptionMarkPrices(ctx context.Context, underlying string, recorder RecorderWriter[OptionMarkPrice]) error {
+	return listenOptionMarkPrices(ctx, OptionsStreamBaseURL, underlying, recorder)
+}
+
+// listenOptionMarkPrices is ListenOptionMarkPrices against the given stream base URL.
+func listenOptionMarkPrices(ctx context.Context, base, underlying string, recorder RecorderWriter[OptionMarkPrice]) error {
+	return listenOption(ctx, base, OptionMarkPriceStream(underlying), recorder, func(p *OptionMarkPrice, s WSSession) {
+		p.ConnID, p.ConnGeneration = s.ID, s.Generation
+	})
+}
+
+// ListenOptionOpenInterest subscribes to the open interest of every option of an expiration, like BTC-250328, and
+// writes a row per option to recorder.
+func ListenOptionOpenInterest(ctx context.Context, expiration string, recorder RecorderWriter[OptionOpenInterest]) error {
+	return listenOptionOpenInterest(ctx, OptionsStreamBaseURL, expiration, recorder)
+}
+
+// listenOptionOpenInterest is ListenOptionOpenInterest against the given stream base URL.
+func listenOptionOpenInterest(ctx context.Context, base, expiration string, recorder RecorderWriter[OptionOpenInterest]) error {
+	return listenOption(ctx, base, OptionOpenInterestStream(expiration), recorder, func(oi *OptionOpenInterest, s WSSession) {
+		oi.ConnID, oi.ConnGeneration = s.ID, s.Generation
+	})
+}
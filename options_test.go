@@ -0,0 +1,98 @@
+package gobinapi
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDecodeOptionRows(t *testing.T) {
+	trades, err := decodeOptionRows[OptionTrade]([]byte(`{"stream":"BTC-250328-100000-C@trade","data":{"e":"trade","E":1739966400000,"s":"BTC-250328-100000-C",` +
+		`"t":"20","p":"1285","q":"-0.01","b":4611781675939004417,"a":"4611781675939004418","T":1739966399990,"S":"-1"}}`))
+	if err != nil {
+		t.Fatalf("failed to decode trade: %v", err)
+	}
+	want := OptionTrade{EventType: "trade", EventTime: 1739966400000, Symbol: "BTC-250328-100000-C", TradeID: 20, Price: "1285",
+		Quantity: "-0.01", BuyOrderID: 4611781675939004417, SellOrderID: 4611781675939004418, TradeTime: 1739966399990, Direction: -1}
+	if len(trades) != 1 || trades[0] != want {
+		t.Errorf("expected string and number IDs to decode, got %+v", trades)
+	}
+
+	tickers, err := decodeOptionRows[OptionTicker]([]byte(`[{"e":"24hrTicker","E":1739966400000,"T":1739966399000,"s":"BTC-250328-100000-C",` +
+		`"o":"1200","h":"1300","l":"1100","c":"1285","V":"12","A":"15420","P":"0.07","p":"85","Q":"0.01","F":"1","L":20,"n":20,` +
+		`"bo":"1280","ao":"1290","bq":"2","aq":"3","b":"0.58","a":"0.61","d":"0.2812","t":"-120.7","g":"0.00002","v":"95.4","vo":"0.59",` +
+		`"mp":"1284","hl":"2500","ll":"5","eep":"0"}]`))
+	if err != nil || len(tickers) != 1 {
+		t.Fatalf("failed to decode ticker array: %+v (%v)", tickers, err)
+	}
+	got := tickers[0]
+	if got.FirstTradeID != 1 || got.LastTradeID != 20 || got.Delta != "0.2812" || got.Theta != "-120.7" || got.Gamma != "0.00002" ||
+		got.Vega != "95.4" || got.BidIV != "0.58" || got.AskIV != "0.61" || got.ImpliedVolatility != "0.59" || got.MarkPrice != "1284" {
+		t.Errorf("fields decoded into the wrong columns: %+v", got)
+	}
+
+	if _, err := decodeOptionRows[OptionTrade]([]byte(`{"e":"trade","t":"twenty"}`)); err == nil {
+		t.Error("expected a malformed trade ID to be rejected")
+	}
+	if err := (OptionsConfig{Underlyings: []string{"btc"}}).Validate(); err == nil {
+		t.Error("expected a lower case underlying to be rejected")
+	}
+	if got := OptionOpenInterestStream("BTC-250328"); got != "BTC@openInterest@250328" {
+		t.Errorf("unexpected open interest stream %q", got)
+	}
+}
+
+func TestRun_RecordsOptions(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade", []byte(`{"e":"trade","E":1700000000000,"s":"BTCUSDT","t":1,"p":"100.00","q":"1","T":1700000000000,"m":false,"M":true}`))
+	srv.SetStream("BTC@markPrice", []byte(`[{"e":"markPrice","E":1739966400000,"s":"BTC-250328-100000-C","mp":"1284"},`+
+		`{"e":"markPrice","E":1739966400000,"s":"BTC-250328-100000-P","mp":"4120"}]`))
+	srv.SetStream("BTC@openInterest@250328", []byte(`[{"e":"openInterest","E":1739966400000,"s":"BTC-250328-100000-C","o":"152.3","h":"14690311.2"}]`))
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"BTCUSDT"}
+	cfg.Streams = map[string][]string{"BTCUSDT": {StreamTrade}}
+	cfg.Options = &OptionsConfig{Underlyings: []string{"BTC"}, Expirations: []string{"BTC-250328"}}
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rows := make(map[string]int64)
+		for _, s := range Introspect().Recorders {
+			rows[s.Instrument+" "+s.DataType] = s.Rows
+		}
+		if rows["BTC optionMarkPrice"] >= 2 && rows["BTC-250328 optionOpenInterest"] >= 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for options data to be recorded, got %v", rows)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	marks, err := ReadRecordingFile(OptionMarkPriceDataType, BuildFileName(OptionMarkPriceDataType, "BTC", NowFunc()))
+	if err != nil || len(marks) != 2 {
+		t.Fatalf("expected a row per option of the underlying, got %d (%v)", len(marks), err)
+	}
+	if got := marks[1].(OptionMarkPrice); got.Symbol != "BTC-250328-100000-P" || got.MarkPrice != "4120" || got.ConnID == "" {
+		t.Errorf("unexpected mark price %+v", got)
+	}
+	oi, err := ReadRecordingFile(OptionOpenInterestDataType, BuildFileName(OptionOpenInterestDataType, "BTC-250328", NowFunc()))
+	if err != nil || len(oi) != 1 || oi[0].(OptionOpenInterest).OpenInterestValue != "14690311.2" {
+		t.Errorf("expected the open interest to be recorded, got %+v (%v)", oi, err)
+	}
+}
@@ -0,0 +1,252 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxPendingDiffs bounds how many diffs a LocalOrderBook buffers while it waits for a snapshot to synchronise with.
+const maxPendingDiffs = 1000
+
+// BookTop is a top-of-book state derived from a LocalOrderBook rather than fetched over REST, recorded as "bookTop".
+type BookTop struct {
+	// Time is the local time in milliseconds at which the state was taken.
+	Time int64 `json:"time" timestamp:"millis" parquet:"name=time, type=INT64"`
+	// EventTime and LastUpdateID identify the last order book diff applied to the book, or the snapshot it was
+	// synchronised from if no diff has been applied since (EventTime is zero then).
+	EventTime    int64        `json:"event_time" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	LastUpdateID int64        `json:"last_update_id" parquet:"name=last_update_id, type=INT64"`
+	Bids         []PriceLevel `json:"bids" parquet:"name=bids, repetitiontype=REPEATED"`
+	Asks         []PriceLevel `json:"asks" parquet:"name=asks, repetitiontype=REPEATED"`
+}
+
+// bookLevel is a price level as received, with the price parsed for ordering.
+type bookLevel struct {
+	level PriceLevel
+	price float64
+}
+
+// LocalOrderBook maintains an order book in memory from REST snapshots and the diffs that follow them, as described
+// in Binance's "how to manage a local order book" guide. Diffs received before a usable snapshot are buffered, and a
+// gap in the diff sequence drops the book until the next snapshot. It is safe for concurrent use.
+type LocalOrderBook struct {
+	mu           sync.Mutex
+	sequence     DiffSequencer
+	synced       bool
+	snapshotID   int64
+	lastUpdateID int64
+	eventTime    int64
+	bids, asks   map[string]bookLevel
+	pending      []OrderBookDiff
+}
+
+// NewLocalOrderBook creates an empty book that becomes usable with its first snapshot. Diffs are sequenced by the
+// spot rules unless SetSequencer is called.
+func NewLocalOrderBook() *LocalOrderBook {
+	return &LocalOrderBook{
+		sequence: ProcessOrderBookDiffMessage,
+		bids:     make(map[string]bookLevel),
+		asks:     make(map[string]bookLevel),
+	}
+}
+
+// SetSequencer sets the rules deciding whether a diff continues the book, e.g. SequencerFor(MarketUSDM).
+func (b *LocalOrderBook) SetSequencer(sequence DiffSequencer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sequence = sequence
+}
+
+// Write implements RecorderWriter[any], so the book can be fed alongside a recorder: snapshots are passed to
+// ApplySnapshot and diffs to ApplyDiff. Other records are ignored.
+func (b *LocalOrderBook) Write(record interface{}) error {
+	switch r := record.(type) {
+	case OrderBookSnapshot:
+		return b.ApplySnapshot(r)
+	case OrderBookDiff:
+		return b.ApplyDiff(r)
+	}
+	return nil
+}
+
+// Synced reports whether the book is synchronised with the diff stream.
+func (b *LocalOrderBook) Synced() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.synced
+}
+
+// ApplySnapshot synchronises the book with snapshot and replays the buffered diffs that follow it. A snapshot no
+// newer than a synchronised book is ignored. It returns an error if the buffered diffs do not continue from the
+// snapshot, in which case the book waits for the next one.
+func (b *LocalOrderBook) ApplySnapshot(snapshot OrderBookSnapshot) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.synced && snapshot.LastUpdateID <= b.lastUpdateID {
+		return nil
+	}
+	b.bids, b.asks = make(map[string]bookLevel, len(snapshot.Bids)), make(map[string]bookLevel, len(snapshot.Asks))
+	if err := setLevels(b.bids, snapshot.Bids); err != nil {
+		b.synced = false
+		return err
+	}
+	if err := setLevels(b.asks, snapshot.Asks); err != nil {
+		b.synced = false
+		return err
+	}
+	b.synced, b.snapshotID, b.lastUpdateID, b.eventTime = true, snapshot.LastUpdateID, snapshot.LastUpdateID, 0
+
+	pending := b.pending
+	b.pending = nil
+	for i, diff := range pending {
+		if err := b.apply(diff); err != nil {
+			// Keep the diffs for a later snapshot, which may cover the gap
+			b.pending = pending[i:]
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyDiff applies diff to a synchronised book, skipping diffs the book already covers, and buffers it otherwise.
+// It returns an error if diff does not continue the sequence, which drops the book until the next snapshot.
+func (b *LocalOrderBook) ApplyDiff(diff OrderBookDiff) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.synced {
+		b.pending = append(b.pending, diff)
+		if len(b.pending) > maxPendingDiffs {
+			b.pending = b.pending[len(b.pending)-maxPendingDiffs:]
+		}
+		return nil
+	}
+	if err := b.apply(diff); err != nil {
+		b.pending = append(b.pending[:0], diff)
+		return err
+	}
+	return nil
+}
+
+// apply applies diff to the synchronised book, or marks the book unsynchronised on a sequence gap. The caller
+// holds mu.
+func (b *LocalOrderBook) apply(diff OrderBookDiff) error {
+	ok, lastUpdateID, gap := b.sequence(diff, b.snapshotID, b.lastUpdateID)
+	if gap {
+		b.synced = false
+		return fmt.Errorf("local order book gap after update %d: got %d-%d", b.lastUpdateID, diff.FirstUpdateID, diff.FinalUpdateID)
+	}
+	if !ok {
+		return nil
+	}
+	if err := setLevels(b.bids, diff.Bids); err != nil {
+		b.synced = false
+		return err
+	}
+	if err := setLevels(b.asks, diff.Asks); err != nil {
+		b.synced = false
+		return err
+	}
+	b.lastUpdateID, b.eventTime = lastUpdateID, diff.EventTime
+	return nil
+}
+
+// setLevels sets the quantity of each level in side, removing levels whose quantity is zero.
+func setLevels(side map[string]bookLevel, levels []PriceLevel) error {
+	for _, l := range levels {
+		qty, err := strconv.ParseFloat(l.Quantity, 64)
+		if err != nil {
+			return fmt.Errorf("invalid quantity %q at price %s: %w", l.Quantity, l.Price, err)
+		}
+		if qty == 0 {
+			delete(side, l.Price)
+			continue
+		}
+		price, err := strconv.ParseFloat(l.Price, 64)
+		if err != nil {
+			return fmt.Errorf("invalid price %q: %w", l.Price, err)
+		}
+		side[l.Price] = bookLevel{level: l, price: price}
+	}
+	return nil
+}
+
+// Top returns up to levels price levels per side, best first, stamped with timeMs. ok is false while the book is
+// not synchronised.
+func (b *LocalOrderBook) Top(levels int, timeMs int64) (top BookTop, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.synced {
+		return BookTop{}, false
+	}
+	return BookTop{
+		Time:         timeMs,
+		EventTime:    b.eventTime,
+		LastUpdateID: b.lastUpdateID,
+		Bids:         topLevels(b.bids, levels, true),
+		Asks:         topLevels(b.asks, levels, false),
+	}, true
+}
+
+// topLevels returns the n best levels of side.
+func topLevels(side map[string]bookLevel, n int, descending bool) []PriceLevel {
+	sorted := make([]bookLevel, 0, len(side))
+	for _, l := range side {
+		sorted = append(sorted, l)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if descending {
+			return sorted[i].price > sorted[j].price
+		}
+		return sorted[i].price < sorted[j].price
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	out := make([]PriceLevel, len(sorted))
+	for i, l := range sorted {
+		out[i] = l.level
+	}
+	return out
+}
+
+// FeedLocalOrderBook applies every diff from in to book and then passes it on to the returned channel, which is
+// closed once in is. The book sees the diffs before any downstream filtering, so it can buffer those that precede
+// its first snapshot.
+func FeedLocalOrderBook(in <-chan OrderBookDiff, book *LocalOrderBook, logger LoggerInterface) <-chan OrderBookDiff {
+	out := make(chan OrderBookDiff)
+	go func() {
+		defer close(out)
+		for diff := range in {
+			if err := book.ApplyDiff(diff); err != nil {
+				logger.Errorf("Local order book waiting for a new snapshot: %v", err)
+			}
+			out <- diff
+		}
+	}()
+	return out
+}
+
+// RunBookTop writes the top levels of book to recorder every interval until ctx is cancelled. Ticks while the book
+// is not synchronised are skipped.
+func RunBookTop(ctx context.Context, book *LocalOrderBook, interval time.Duration, levels int, recorder RecorderWriter[BookTop], logger LoggerInterface) {
+	ticker := DefaultClock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			top, ok := book.Top(levels, NowFunc().UnixMilli())
+			if !ok {
+				continue
+			}
+			if err := recorder.Write(top); err != nil {
+				logger.Errorf("error writing book top: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package gobinapi
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bookDiff(first, final int64, bids, asks []PriceLevel) OrderBookDiff {
+	return OrderBookDiff{EventType: "depthUpdate", EventTime: 1700000000000 + final, FirstUpdateID: first, FinalUpdateID: final, Bids: bids, Asks: asks}
+}
+
+func TestLocalOrderBook_BuffersDiffsUntilSnapshot(t *testing.T) {
+	book := NewLocalOrderBook()
+	// Diffs arriving before the snapshot are buffered; those it already covers are dropped when it arrives
+	book.ApplyDiff(bookDiff(9, 10, []PriceLevel{{"99.0", "5"}}, nil))
+	book.ApplyDiff(bookDiff(11, 12, []PriceLevel{{"100.5", "1"}, {"99.5", "0"}}, nil))
+	if _, ok := book.Top(5, 0); ok {
+		t.Fatalf("expected no top of book before the first snapshot")
+	}
+
+	err := book.ApplySnapshot(OrderBookSnapshot{
+		LastUpdateID: 11,
+		Bids:         []PriceLevel{{"100.0", "2"}, {"99.5", "3"}, {"99.0", "4"}},
+		Asks:         []PriceLevel{{"101.0", "1"}, {"102.0", "2"}},
+	})
+	if err != nil {
+		t.Fatalf("ApplySnapshot returned error: %v", err)
+	}
+	if err := book.ApplyDiff(bookDiff(13, 13, nil, []PriceLevel{{"100.8", "7"}, {"102.0", "0"}})); err != nil {
+		t.Fatalf("ApplyDiff returned error: %v", err)
+	}
+
+	top, ok := book.Top(2, 42)
+	if !ok {
+		t.Fatalf("expected a synchronised book")
+	}
+	expected := BookTop{
+		Time:         42,
+		EventTime:    1700000000013,
+		LastUpdateID: 13,
+		Bids:         []PriceLevel{{"100.5", "1"}, {"100.0", "2"}},
+		Asks:         []PriceLevel{{"100.8", "7"}, {"101.0", "1"}},
+	}
+	if !reflect.DeepEqual(top, expected) {
+		t.Errorf("expected %+v, got %+v", expected, top)
+	}
+}
+
+func TestLocalOrderBook_GapWaitsForNextSnapshot(t *testing.T) {
+	book := NewLocalOrderBook()
+	book.ApplySnapshot(OrderBookSnapshot{LastUpdateID: 5, Bids: []PriceLevel{{"10", "1"}}, Asks: []PriceLevel{{"11", "1"}}})
+	if err := book.ApplyDiff(bookDiff(8, 8, []PriceLevel{{"10", "2"}}, nil)); err == nil || !strings.Contains(err.Error(), "gap after update 5") {
+		t.Fatalf("expected a gap error, got %v", err)
+	}
+	if book.Synced() {
+		t.Fatalf("expected the book to be dropped after a gap")
+	}
+	book.ApplyDiff(bookDiff(9, 9, nil, []PriceLevel{{"11", "3"}}))
+
+	// A snapshot older than the buffered diffs cannot resynchronise the book
+	if err := book.ApplySnapshot(OrderBookSnapshot{LastUpdateID: 6}); err == nil || book.Synced() {
+		t.Fatalf("expected a snapshot short of the buffered diffs to be rejected, got %v", err)
+	}
+	if err := book.ApplySnapshot(OrderBookSnapshot{LastUpdateID: 8, Bids: []PriceLevel{{"10", "2"}}, Asks: []PriceLevel{{"11", "1"}}}); err != nil {
+		t.Fatalf("ApplySnapshot returned error: %v", err)
+	}
+	top, ok := book.Top(20, 0)
+	if !ok || top.LastUpdateID != 9 || top.Asks[0] != (PriceLevel{"11", "3"}) {
+		t.Errorf("expected the buffered diff to be replayed, got %+v (ok=%v)", top, ok)
+	}
+
+	// An older snapshot does not roll a synchronised book back
+	book.ApplySnapshot(OrderBookSnapshot{LastUpdateID: 7})
+	if top, _ := book.Top(20, 0); top.LastUpdateID != 9 || len(top.Bids) != 1 {
+		t.Errorf("expected the book to ignore an older snapshot, got %+v", top)
+	}
+}
+
+type bookTopWriter chan BookTop
+
+func (w bookTopWriter) Write(record BookTop) error {
+	w <- record
+	return nil
+}
+
+func TestRunBookTop_WritesOnTick(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	clock := useFakeClock(t, start)
+	book := NewLocalOrderBook()
+	out := make(bookTopWriter, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { defer close(done); RunBookTop(ctx, book, 100*time.Millisecond, 1, out, &FakeLogger{}) }()
+	waitForWaiters(t, clock, 1)
+
+	book.ApplySnapshot(OrderBookSnapshot{LastUpdateID: 1, Bids: []PriceLevel{{"10", "1"}, {"9", "1"}}, Asks: []PriceLevel{{"11", "1"}}})
+	clock.Advance(100 * time.Millisecond)
+	select {
+	case top := <-out:
+		if top.Time != start.Add(100*time.Millisecond).UnixMilli() || len(top.Bids) != 1 || top.Bids[0].Price != "10" {
+			t.Errorf("unexpected book top %+v", top)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for a book top")
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,269 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Order sides and the common order types and time in force values. The exchange accepts more types, such as
+// STOP_LOSS_LIMIT on spot or TRAILING_STOP_MARKET on USD-M futures, which NewOrderRequest passes on as given.
+const (
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+
+	OrderTypeLimit  = "LIMIT"
+	OrderTypeMarket = "MARKET"
+
+	TimeInForceGTC = "GTC"
+	TimeInForceIOC = "IOC"
+	TimeInForceFOK = "FOK"
+)
+
+// Request weights of the order endpoints, following the exchange's published tables. Listing the open orders of
+// every symbol costs more than those of one.
+const (
+	newOrderWeight             = 1
+	cancelOrderWeight          = 1
+	queryOrderWeight           = 4
+	openOrdersWeight           = 6
+	allOpenOrdersWeight        = 80
+	futuresNewOrderWeight      = 1
+	futuresCancelOrderWeight   = 1
+	futuresQueryOrderWeight    = 1
+	futuresOpenOrdersWeight    = 1
+	futuresAllOpenOrdersWeight = 40
+)
+
+// Paths of the order endpoints of each market.
+const (
+	orderPath             = "/api/v3/order"
+	openOrdersPath        = "/api/v3/openOrders"
+	futuresOrderPath      = "/fapi/v1/order"
+	futuresOpenOrdersPath = "/fapi/v1/openOrders"
+)
+
+// NewOrderRequest is an order to place. Prices and quantities are decimal strings, as everywhere in the API, so they
+// reach the exchange exactly as given.
+type NewOrderRequest struct {
+	Symbol string
+	// Side is SideBuy or SideSell and Type an order type like OrderTypeLimit.
+	Side string
+	Type string
+	// TimeInForce is required for limit orders, e.g. TimeInForceGTC.
+	TimeInForce string
+	Quantity    string
+	// QuoteOrderQty places a spot market order for an amount of the quote asset instead of Quantity.
+	QuoteOrderQty string
+	Price         string
+	StopPrice     string
+	// NewClientOrderID identifies the order in later requests and on the user data stream; the exchange generates
+	// one if empty.
+	NewClientOrderID string
+	// ReduceOnly and PositionSide ("BOTH", "LONG" or "SHORT" in hedge mode) apply to USD-M futures only.
+	ReduceOnly   bool
+	PositionSide string
+}
+
+// params validates the request and returns its query parameters for market.
+func (r NewOrderRequest) params(market string) (url.Values, error) {
+	if r.Symbol == "" {
+		return nil, errors.New("new order: symbol is required")
+	}
+	if r.Side != SideBuy && r.Side != SideSell {
+		return nil, fmt.Errorf("new order: side must be %s or %s, got %q", SideBuy, SideSell, r.Side)
+	}
+	if r.Type == "" {
+		return nil, errors.New("new order: type is required")
+	}
+	if r.Quantity == "" && r.QuoteOrderQty == "" {
+		return nil, errors.New("new order: quantity is required")
+	}
+	if market == MarketUSDM && r.QuoteOrderQty != "" {
+		return nil, errors.New("new order: USD-M futures orders have no quote order quantity")
+	}
+	if market != MarketUSDM && (r.ReduceOnly || r.PositionSide != "") {
+		return nil, errors.New("new order: reduce only and position side apply to USD-M futures only")
+	}
+	q := url.Values{"symbol": {r.Symbol}, "side": {r.Side}, "type": {r.Type}}
+	for key, value := range map[string]string{"timeInForce": r.TimeInForce, "quantity": r.Quantity,
+		"quoteOrderQty": r.QuoteOrderQty, "price": r.Price, "stopPrice": r.StopPrice,
+		"newClientOrderId": r.NewClientOrderID, "positionSide": r.PositionSide} {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	if r.ReduceOnly {
+		q.Set("reduceOnly", "true")
+	}
+	// Spot answers market orders with the FULL response, listing every fill, unless asked otherwise; RESULT returns
+	// the order as on USD-M futures
+	q.Set("newOrderRespType", "RESULT")
+	return q, nil
+}
+
+// OrderRef identifies an existing order of Symbol by the exchange's OrderID or, if that is zero, by the
+// ClientOrderID it was placed with.
+type OrderRef struct {
+	Symbol        string
+	OrderID       int64
+	ClientOrderID string
+}
+
+// params validates the reference and returns its query parameters.
+func (r OrderRef) params() (url.Values, error) {
+	if r.Symbol == "" || (r.OrderID == 0 && r.ClientOrderID == "") {
+		return nil, errors.New("order reference needs a symbol and an order ID or client order ID")
+	}
+	q := url.Values{"symbol": {r.Symbol}}
+	if r.OrderID != 0 {
+		q.Set("orderId", strconv.FormatInt(r.OrderID, 10))
+	} else {
+		q.Set("origClientOrderId", r.ClientOrderID)
+	}
+	return q, nil
+}
+
+// Order is the state of an order as the order endpoints of both markets return it.
+type Order struct {
+	Symbol        string `json:"symbol"`
+	OrderID       int64  `json:"orderId"`
+	ClientOrderID string `json:"clientOrderId"`
+	Price         string `json:"price"`
+	OrigQty       string `json:"origQty"`
+	ExecutedQty   string `json:"executedQty"`
+	// CumulativeQuoteQty is the quote asset amount filled so far, "cummulativeQuoteQty" on spot and "cumQuote" on
+	// USD-M futures.
+	CumulativeQuoteQty string `json:"cummulativeQuoteQty"`
+	// AvgPrice is the average fill price, reported on USD-M futures only.
+	AvgPrice    string `json:"avgPrice,omitempty"`
+	Status      string `json:"status"`
+	TimeInForce string `json:"timeInForce"`
+	Type        string `json:"type"`
+	Side        string `json:"side"`
+	StopPrice   string `json:"stopPrice"`
+	// Time is when the order was placed, "transactTime" in the responses to new orders, and UpdateTime when it last
+	// changed.
+	Time         int64  `json:"time"`
+	UpdateTime   int64  `json:"updateTime"`
+	ReduceOnly   bool   `json:"reduceOnly,omitempty"`
+	PositionSide string `json:"positionSide,omitempty"`
+}
+
+// UnmarshalJSON decodes an order of either market, folding the fields the markets name differently into one.
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type plain Order
+	var aux struct {
+		plain
+		CumQuote     string `json:"cumQuote"`
+		TransactTime int64  `json:"transactTime"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*o = Order(aux.plain)
+	if o.CumulativeQuoteQty == "" {
+		o.CumulativeQuoteQty = aux.CumQuote
+	}
+	if o.Time == 0 {
+		o.Time = aux.TransactTime
+	}
+	if o.UpdateTime == 0 {
+		o.UpdateTime = o.Time
+	}
+	return nil
+}
+
+// BinanceRESTClient places and manages orders on the REST API of the spot or USD-M futures market, signing its
+// requests with a SignedClient, so they share its clock correction, and pacing them through the weight budget of
+// DefaultWeightTracker. The exchange's order count limits are enforced by the exchange; its 429 responses hold up
+// every request until the backoff has passed and fail orders rather than resending them.
+type BinanceRESTClient struct {
+	signed *SignedClient
+	market string
+}
+
+// NewBinanceRESTClient creates a client for market (MarketSpot or MarketUSDM) signing with creds, which must hold a
+// secret or private key (see CredentialsFromEnv).
+func NewBinanceRESTClient(client *http.Client, market string, creds Credentials) (*BinanceRESTClient, error) {
+	if creds.APIKey == "" || !creds.CanSign() {
+		return nil, errors.New("trading needs an API key and a secret or private key")
+	}
+	return &BinanceRESTClient{signed: NewSignedClient(client, market, creds, DefaultWeightTracker), market: market}, nil
+}
+
+// SignedClient returns the client the requests are signed with, e.g. to call SyncTime up front or set the
+// recvWindow.
+func (c *BinanceRESTClient) SignedClient() *SignedClient {
+	return c.signed
+}
+
+// NewOrder places an order and returns it as the exchange accepted it.
+func (c *BinanceRESTClient) NewOrder(ctx context.Context, req NewOrderRequest) (Order, error) {
+	params, err := req.params(c.market)
+	if err != nil {
+		return Order{}, err
+	}
+	path, weight := orderPath, newOrderWeight
+	if c.market == MarketUSDM {
+		path, weight = futuresOrderPath, futuresNewOrderWeight
+	}
+	var order Order
+	err = c.signed.Do(ctx, http.MethodPost, path, params, SecuritySigned, weight, &order)
+	return order, err
+}
+
+// CancelOrder cancels an open order and returns its final state.
+func (c *BinanceRESTClient) CancelOrder(ctx context.Context, ref OrderRef) (Order, error) {
+	path, weight := orderPath, cancelOrderWeight
+	if c.market == MarketUSDM {
+		path, weight = futuresOrderPath, futuresCancelOrderWeight
+	}
+	return c.orderRequest(ctx, http.MethodDelete, path, weight, ref)
+}
+
+// QueryOrder returns the current state of an order.
+func (c *BinanceRESTClient) QueryOrder(ctx context.Context, ref OrderRef) (Order, error) {
+	path, weight := orderPath, queryOrderWeight
+	if c.market == MarketUSDM {
+		path, weight = futuresOrderPath, futuresQueryOrderWeight
+	}
+	return c.orderRequest(ctx, http.MethodGet, path, weight, ref)
+}
+
+// orderRequest sends a request about the order ref and decodes the order it returns.
+func (c *BinanceRESTClient) orderRequest(ctx context.Context, method, path string, weight int, ref OrderRef) (Order, error) {
+	params, err := ref.params()
+	if err != nil {
+		return Order{}, err
+	}
+	var order Order
+	err = c.signed.Do(ctx, method, path, params, SecuritySigned, weight, &order)
+	return order, err
+}
+
+// OpenOrders returns the open orders of symbol, or of every symbol if symbol is empty, which costs a much higher
+// request weight.
+func (c *BinanceRESTClient) OpenOrders(ctx context.Context, symbol string) ([]Order, error) {
+	path, weight := openOrdersPath, openOrdersWeight
+	if c.market == MarketUSDM {
+		path, weight = futuresOpenOrdersPath, futuresOpenOrdersWeight
+	}
+	params := url.Values{}
+	if symbol != "" {
+		params.Set("symbol", symbol)
+	} else if c.market == MarketUSDM {
+		weight = futuresAllOpenOrdersWeight
+	} else {
+		weight = allOpenOrdersWeight
+	}
+	var orders []Order
+	if err := c.signed.Do(ctx, http.MethodGet, path, params, SecuritySigned, weight, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
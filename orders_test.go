@@ -0,0 +1,120 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// orderServer stands in for the order endpoints of both markets, checking every request's signature and recording
+// its method, path and parameters.
+func orderServer(t *testing.T, creds Credentials, responses map[string]string) *[]string {
+	t.Helper()
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.RawQuery
+		i := strings.LastIndex(query, "&signature=")
+		if r.Header.Get("X-MBX-APIKEY") != creds.APIKey || i < 0 || query[i+len("&signature="):] != creds.Sign(query[:i]) {
+			http.Error(w, `{"code":-1022,"msg":"Signature for this request is not valid."}`, http.StatusBadRequest)
+			return
+		}
+		params, _ := url.ParseQuery(query[:i])
+		params.Del("timestamp")
+		params.Del("recvWindow")
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+params.Encode())
+		body, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.Error(w, `{"code":-2011,"msg":"Unknown order sent."}`, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	oldREST, oldFuturesREST, oldTracker := RESTBaseURL, FuturesRESTBaseURL, DefaultWeightTracker
+	RESTBaseURL, FuturesRESTBaseURL, DefaultWeightTracker = srv.URL, srv.URL, NewWeightTracker(DefaultRESTWeightLimit)
+	t.Cleanup(func() { RESTBaseURL, FuturesRESTBaseURL, DefaultWeightTracker = oldREST, oldFuturesREST, oldTracker })
+	return &requests
+}
+
+func TestBinanceRESTClient_Spot(t *testing.T) {
+	creds := Credentials{APIKey: "key", Secret: "secret"}
+	requests := orderServer(t, creds, map[string]string{
+		"POST /api/v3/order": `{"symbol":"BTCUSDT","orderId":28,"orderListId":-1,"clientOrderId":"grid-1","transactTime":1739966400000,` +
+			`"price":"95000.00","origQty":"0.01","executedQty":"0.00","origQuoteOrderQty":"0.000000","cummulativeQuoteQty":"0.00",` +
+			`"status":"NEW","timeInForce":"GTC","type":"LIMIT","side":"BUY","workingTime":1739966400000,"selfTradePreventionMode":"NONE"}`,
+		"GET /api/v3/openOrders": `[]`,
+	})
+	c, err := NewBinanceRESTClient(http.DefaultClient, MarketSpot, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	order, err := c.NewOrder(ctx, NewOrderRequest{Symbol: "BTCUSDT", Side: SideBuy, Type: OrderTypeLimit, TimeInForce: TimeInForceGTC,
+		Quantity: "0.01", Price: "95000.00", NewClientOrderID: "grid-1"})
+	if err != nil {
+		t.Fatalf("NewOrder failed: %v", err)
+	}
+	if order.OrderID != 28 || order.Status != "NEW" || order.Time != 1739966400000 || order.UpdateTime != order.Time || order.CumulativeQuoteQty != "0.00" {
+		t.Errorf("unexpected order %+v", order)
+	}
+	if _, err := c.OpenOrders(ctx, ""); err != nil {
+		t.Fatalf("OpenOrders failed: %v", err)
+	}
+	_, err = c.CancelOrder(ctx, OrderRef{Symbol: "BTCUSDT", ClientOrderID: "grid-1"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -2011 {
+		t.Errorf("expected the exchange's error as an APIError, got %v", err)
+	}
+
+	want := []string{
+		"POST /api/v3/order newClientOrderId=grid-1&newOrderRespType=RESULT&price=95000.00&quantity=0.01&side=BUY&symbol=BTCUSDT&timeInForce=GTC&type=LIMIT",
+		"GET /api/v3/openOrders ",
+		"DELETE /api/v3/order origClientOrderId=grid-1&symbol=BTCUSDT",
+	}
+	if strings.Join(*requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected requests:\n%s", strings.Join(*requests, "\n"))
+	}
+	if used := DefaultWeightTracker.Used(time.Now()); used != newOrderWeight+allOpenOrdersWeight+cancelOrderWeight {
+		t.Errorf("expected the requests' weights to be tracked, got %d", used)
+	}
+}
+
+func TestBinanceRESTClient_Futures(t *testing.T) {
+	creds := Credentials{APIKey: "key", Secret: "secret"}
+	requests := orderServer(t, creds, map[string]string{
+		"GET /fapi/v1/order": `{"avgPrice":"95010.5","clientOrderId":"hedge-7","cumQuote":"950.105","executedQty":"0.010","orderId":1917641,` +
+			`"origQty":"0.010","origType":"MARKET","price":"0","reduceOnly":true,"side":"SELL","positionSide":"BOTH","status":"FILLED",` +
+			`"stopPrice":"0","closePosition":false,"symbol":"BTCUSDT","time":1739966400000,"timeInForce":"GTC","type":"MARKET",` +
+			`"updateTime":1739966400050,"workingType":"CONTRACT_PRICE","priceProtect":false}`,
+	})
+	c, err := NewBinanceRESTClient(http.DefaultClient, MarketUSDM, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := c.QueryOrder(context.Background(), OrderRef{Symbol: "BTCUSDT", OrderID: 1917641})
+	if err != nil {
+		t.Fatalf("QueryOrder failed: %v", err)
+	}
+	if order.CumulativeQuoteQty != "950.105" || order.AvgPrice != "95010.5" || !order.ReduceOnly || order.UpdateTime != 1739966400050 {
+		t.Errorf("futures fields decoded into the wrong columns: %+v", order)
+	}
+	if got := (*requests)[0]; got != "GET /fapi/v1/order orderId=1917641&symbol=BTCUSDT" {
+		t.Errorf("unexpected request %s", got)
+	}
+
+	if _, err := c.NewOrder(context.Background(), NewOrderRequest{Symbol: "BTCUSDT", Side: SideSell, Type: OrderTypeMarket, QuoteOrderQty: "100"}); err == nil {
+		t.Error("expected a quote order quantity to be rejected on futures")
+	}
+	if _, err := c.NewOrder(context.Background(), NewOrderRequest{Symbol: "BTCUSDT", Side: "SHORT", Type: OrderTypeMarket, Quantity: "1"}); err == nil {
+		t.Error("expected an unknown side to be rejected")
+	}
+	if _, err := NewBinanceRESTClient(http.DefaultClient, MarketSpot, Credentials{APIKey: "key"}); err == nil {
+		t.Error("expected credentials that cannot sign to be rejected")
+	}
+}
@@ -0,0 +1,266 @@
+package gobinapi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Compression codec names accepted in ParquetOptions.
+const (
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+	CompressionGzip   = "gzip"
+	CompressionLZ4    = "lz4"
+	CompressionNone   = "none"
+)
+
+var compressionCodecs = map[string]parquet.CompressionCodec{
+	CompressionSnappy: parquet.CompressionCodec_SNAPPY,
+	CompressionZstd:   parquet.CompressionCodec_ZSTD,
+	CompressionGzip:   parquet.CompressionCodec_GZIP,
+	CompressionLZ4:    parquet.CompressionCodec_LZ4,
+	CompressionNone:   parquet.CompressionCodec_UNCOMPRESSED,
+}
+
+// Defaults for zero ParquetOptions fields.
+const (
+	DefaultRowGroupSize = 128 * 1024 * 1024 // 128 MB
+	DefaultPageSize     = 8 * 1024          // 8 KB
+)
+
+// ParquetOptions controls how parquet files are encoded. Zero fields fall back to SNAPPY compression,
+// DefaultRowGroupSize, DefaultPageSize, string prices and quantities and INT64 times.
+type ParquetOptions struct {
+	// Compression is one of "snappy", "zstd", "gzip", "lz4" or "none" (case-insensitive). ZSTD typically makes order
+	// book diff files about half the size of SNAPPY ones, at more CPU per row group.
+	Compression  string `json:"compression,omitempty" yaml:"compression"`
+	RowGroupSize int64  `json:"row_group_size,omitempty" yaml:"row_group_size"`
+	PageSize     int64  `json:"page_size,omitempty" yaml:"page_size"`
+	// Numbers is how the fields tagged decimal:"true", prices and quantities, are stored: NumbersString (the default)
+	// or NumbersDecimal, with DecimalScale digits after the point (default DefaultDecimalScale). ReadParquetFile
+	// reads either back into strings.
+	Numbers      string `json:"numbers,omitempty" yaml:"numbers"`
+	DecimalScale int    `json:"decimal_scale,omitempty" yaml:"decimal_scale"`
+	// Timestamps is how the fields tagged timestamp:"millis", event and other times, are stored: TimestampsInt64
+	// (the default), TimestampsMillis or TimestampsMicros. ReadParquetFile reads either back into milliseconds.
+	Timestamps string `json:"timestamps,omitempty" yaml:"timestamps"`
+}
+
+// DefaultParquetOptions are the options Recorders and WriteParquetFile encode with. Run sets them from
+// Config.Parquet.
+var DefaultParquetOptions ParquetOptions
+
+// ParquetOptionsByType overrides DefaultParquetOptions per data type (e.g. "orderBookDiff"). Run sets it from
+// Config.ParquetByType.
+var ParquetOptionsByType map[string]ParquetOptions
+
+// ParquetOptionsFor returns the options a Recorder of dataType encodes with: its entry in ParquetOptionsByType,
+// with unset fields taken from DefaultParquetOptions.
+func ParquetOptionsFor(dataType string) ParquetOptions {
+	return ParquetOptionsByType[dataType].Over(DefaultParquetOptions)
+}
+
+// Over returns o with its zero fields taken from base.
+func (o ParquetOptions) Over(base ParquetOptions) ParquetOptions {
+	if o.Compression == "" {
+		o.Compression = base.Compression
+	}
+	if o.RowGroupSize == 0 {
+		o.RowGroupSize = base.RowGroupSize
+	}
+	if o.PageSize == 0 {
+		o.PageSize = base.PageSize
+	}
+	if o.Numbers == "" {
+		o.Numbers = base.Numbers
+	}
+	if o.DecimalScale == 0 {
+		o.DecimalScale = base.DecimalScale
+	}
+	if o.Timestamps == "" {
+		o.Timestamps = base.Timestamps
+	}
+	return o
+}
+
+// Codec returns the parquet compression codec selected by o.
+func (o ParquetOptions) Codec() (parquet.CompressionCodec, error) {
+	if o.Compression == "" {
+		return parquet.CompressionCodec_SNAPPY, nil
+	}
+	codec, ok := compressionCodecs[strings.ToLower(o.Compression)]
+	if !ok {
+		return 0, fmt.Errorf("unknown compression %q, expected snappy, zstd, gzip, lz4 or none", o.Compression)
+	}
+	return codec, nil
+}
+
+// Validate checks that o names a supported codec and encodings of numbers and times and has no negative sizes.
+func (o ParquetOptions) Validate() error {
+	if _, err := o.Codec(); err != nil {
+		return err
+	}
+	if o.RowGroupSize < 0 || o.PageSize < 0 {
+		return fmt.Errorf("row group size and page size must not be negative, got %d and %d", o.RowGroupSize, o.PageSize)
+	}
+	if o.Numbers != "" && !strings.EqualFold(o.Numbers, NumbersString) && !strings.EqualFold(o.Numbers, NumbersDecimal) {
+		return fmt.Errorf("unknown numbers %q, expected string or decimal", o.Numbers)
+	}
+	if o.DecimalScale < 0 || o.DecimalScale > decimalPrecision {
+		return fmt.Errorf("decimal scale must be between 0 and %d, got %d", decimalPrecision, o.DecimalScale)
+	}
+	switch strings.ToLower(o.Timestamps) {
+	case "", TimestampsInt64, TimestampsMillis, TimestampsMicros:
+	default:
+		return fmt.Errorf("unknown timestamps %q, expected int64, millis or micros", o.Timestamps)
+	}
+	return nil
+}
+
+// columnEncoding returns how files encoded according to o store prices, quantities and times.
+func (o ParquetOptions) columnEncoding() columnEncoding {
+	var e columnEncoding
+	if strings.EqualFold(o.Numbers, NumbersDecimal) {
+		e.decimals, e.scale = true, o.DecimalScale
+		if e.scale == 0 {
+			e.scale = DefaultDecimalScale
+		}
+	}
+	if unit := strings.ToLower(o.Timestamps); unit == TimestampsMillis || unit == TimestampsMicros {
+		e.timestamps = unit
+	}
+	return e
+}
+
+// newParquetWriter creates a parquet writer for prototype's schema on file, encoded according to o.
+func newParquetWriter(file source.ParquetFile, prototype interface{}, np int64, o ParquetOptions) (*writer.ParquetWriter, error) {
+	codec, err := o.Codec()
+	if err != nil {
+		return nil, err
+	}
+	pw, err := writer.NewParquetWriter(file, prototype, np)
+	if err != nil {
+		return nil, err
+	}
+	o = o.Over(ParquetOptions{RowGroupSize: DefaultRowGroupSize, PageSize: DefaultPageSize})
+	pw.RowGroupSize = o.RowGroupSize
+	pw.PageSize = o.PageSize
+	pw.CompressionType = codec
+	return pw, nil
+}
+
+// ReadParquetFile reads every row of the parquet file at filePath into a slice of T. T must carry the same parquet
+// tags that were used to write the file (e.g. one of the types in binance_types.go). Prices and quantities stored as
+// decimals (see NumbersDecimal) are formatted as strings with the file's scale, and times stored as timestamps (see
+// TimestampsMillis) are read as milliseconds.
+func ReadParquetFile[T any](filePath string) ([]T, error) {
+	encoding, err := fileColumnEncoding(filePath)
+	if err != nil {
+		return nil, err
+	}
+	t := reflect.TypeFor[T]()
+	codec := encoding.codec(t)
+	if codec == nil {
+		var records []T
+		if err := readParquetRows(filePath, new(T), &records); err != nil {
+			return nil, err
+		}
+		return records, nil
+	}
+
+	rows := reflect.New(reflect.SliceOf(codec.mirror))
+	if err := readParquetRows(filePath, codec.prototype(), rows.Interface()); err != nil {
+		return nil, err
+	}
+	records := make([]T, rows.Elem().Len())
+	for i := range records {
+		records[i] = codec.decode(rows.Elem().Index(i), t).Interface().(T)
+	}
+	return records, nil
+}
+
+// readParquetRows reads every row of the parquet file at filePath, with the schema of prototype, into the slice dst
+// points to.
+func readParquetRows(filePath string, prototype, dst interface{}) error {
+	fr, err := local.NewLocalFileReader(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer fr.Close()
+
+	pr, err := reader.NewParquetReader(fr, prototype, 4)
+	if err != nil {
+		return fmt.Errorf("failed to read parquet footer of %s: %w", filePath, err)
+	}
+	defer pr.ReadStop()
+
+	rows := reflect.ValueOf(dst).Elem()
+	n := int(pr.GetNumRows())
+	rows.Set(reflect.MakeSlice(rows.Type(), n, n))
+	if n == 0 {
+		return nil
+	}
+	if err := pr.Read(dst); err != nil {
+		return fmt.Errorf("failed to read rows from %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// WriteParquetFile writes records to a new parquet file at filePath, encoded with DefaultParquetOptions, with the
+// recorder and schema version in its footer metadata. It is intended for offline tools that produce a complete file
+// in one go.
+func WriteParquetFile[T any](filePath string, records []T) error {
+	return writeParquetFile(filePath, records, DefaultParquetOptions)
+}
+
+// writeParquetFile is WriteParquetFile with the given options.
+func writeParquetFile[T any](filePath string, records []T, options ParquetOptions) error {
+	lf, err := local.NewLocalFileWriter(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filePath, err)
+	}
+	codec := options.columnEncoding().codec(reflect.TypeFor[T]())
+	var prototype interface{} = new(T)
+	if codec != nil {
+		prototype = codec.prototype()
+	}
+	pw, err := newParquetWriter(lf, prototype, 4, options)
+	if err != nil {
+		lf.Close()
+		return fmt.Errorf("failed to create parquet writer for %s: %w", filePath, err)
+	}
+
+	for i := range records {
+		var row interface{} = records[i]
+		if codec != nil {
+			v, err := codec.encode(reflect.ValueOf(records[i]))
+			if err != nil {
+				pw.WriteStop()
+				lf.Close()
+				return fmt.Errorf("failed to encode row %d for %s: %w", i, filePath, err)
+			}
+			row = v.Interface()
+		}
+		if err := pw.Write(row); err != nil {
+			pw.WriteStop()
+			lf.Close()
+			return fmt.Errorf("failed to write row %d to %s: %w", i, filePath, err)
+		}
+	}
+	pw.Footer.KeyValueMetadata = keyValueMetadata(map[string]string{
+		MetadataRecorderVersion: GitVersion(),
+		MetadataSchemaVersion:   SchemaVersion,
+	})
+	if err := pw.WriteStop(); err != nil {
+		lf.Close()
+		return fmt.Errorf("failed to finalize %s: %w", filePath, err)
+	}
+	return lf.Close()
+}
@@ -0,0 +1,111 @@
+package gobinapi
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestWriteAndReadParquetFile_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.parquet")
+	trades := []Trade{
+		{EventType: "trade", TradeID: 1, Price: "100.5", Quantity: "0.1", IsBuyerMaker: true},
+		{EventType: "trade", TradeID: 2, Price: "100.6", Quantity: "0.2"},
+	}
+	if err := WriteParquetFile(path, trades); err != nil {
+		t.Fatalf("WriteParquetFile returned error: %v", err)
+	}
+	read, err := ReadParquetFile[Trade](path)
+	if err != nil {
+		t.Fatalf("ReadParquetFile returned error: %v", err)
+	}
+	if len(read) != len(trades) {
+		t.Fatalf("expected %d rows, got %d", len(trades), len(read))
+	}
+	for i := range trades {
+		if read[i] != trades[i] {
+			t.Errorf("row %d mismatch: expected %+v, got %+v", i, trades[i], read[i])
+		}
+	}
+}
+
+func TestReadParquetFile_EmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.parquet")
+	if err := WriteParquetFile[BestPrice](path, nil); err != nil {
+		t.Fatalf("WriteParquetFile returned error: %v", err)
+	}
+	read, err := ReadParquetFile[BestPrice](path)
+	if err != nil {
+		t.Fatalf("ReadParquetFile returned error: %v", err)
+	}
+	if len(read) != 0 {
+		t.Errorf("expected no rows, got %d", len(read))
+	}
+}
+
+func TestWriteParquetFile_CompressionCodecs(t *testing.T) {
+	defer func(old ParquetOptions) { DefaultParquetOptions = old }(DefaultParquetOptions)
+	trades := []Trade{
+		{EventType: "trade", TradeID: 1, Price: "100.5", Quantity: "0.1"},
+		{EventType: "trade", TradeID: 2, Price: "100.6", Quantity: "0.2"},
+	}
+	for name, codec := range map[string]parquet.CompressionCodec{
+		"":     parquet.CompressionCodec_SNAPPY,
+		"ZSTD": parquet.CompressionCodec_ZSTD,
+		"gzip": parquet.CompressionCodec_GZIP,
+		"lz4":  parquet.CompressionCodec_LZ4,
+		"none": parquet.CompressionCodec_UNCOMPRESSED,
+	} {
+		DefaultParquetOptions = ParquetOptions{Compression: name, PageSize: 1024}
+		path := filepath.Join(t.TempDir(), "trades.parquet")
+		if err := WriteParquetFile(path, trades); err != nil {
+			t.Fatalf("%q: WriteParquetFile returned error: %v", name, err)
+		}
+		if actual := fileCodec(t, path); actual != codec {
+			t.Errorf("%q: expected codec %v, got %v", name, codec, actual)
+		}
+		read, err := ReadParquetFile[Trade](path)
+		if err != nil || len(read) != len(trades) || read[1] != trades[1] {
+			t.Errorf("%q: round trip failed: %+v %v", name, read, err)
+		}
+	}
+
+	DefaultParquetOptions = ParquetOptions{Compression: "brotli"}
+	if err := WriteParquetFile(filepath.Join(t.TempDir(), "bad.parquet"), trades); err == nil {
+		t.Errorf("expected an unsupported codec to be rejected")
+	}
+}
+
+func TestParquetOptionsFor_FallsBackToDefaults(t *testing.T) {
+	defer func(old ParquetOptions, oldByType map[string]ParquetOptions) {
+		DefaultParquetOptions, ParquetOptionsByType = old, oldByType
+	}(DefaultParquetOptions, ParquetOptionsByType)
+	DefaultParquetOptions = ParquetOptions{Compression: CompressionSnappy, PageSize: 4096}
+	ParquetOptionsByType = map[string]ParquetOptions{"orderBookDiff": {Compression: CompressionZstd}}
+
+	if o := ParquetOptionsFor("orderBookDiff"); o != (ParquetOptions{Compression: CompressionZstd, PageSize: 4096}) {
+		t.Errorf("unexpected orderBookDiff options %+v", o)
+	}
+	if o := ParquetOptionsFor("trade"); o != DefaultParquetOptions {
+		t.Errorf("expected trade to use the defaults, got %+v", o)
+	}
+}
+
+// fileCodec returns the compression codec of the first column chunk of a parquet file.
+func fileCodec(t *testing.T, path string) parquet.CompressionCodec {
+	t.Helper()
+	fr, err := local.NewLocalFileReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	return pr.Footer.RowGroups[0].Columns[0].MetaData.Codec
+}
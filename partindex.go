@@ -0,0 +1,67 @@
+package gobinapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// PartIndexEntry describes one part file of a day that was split by size or time, so consumers can pick the parts
+// covering a time range without opening each one. Times are exchange times where the record type has them, and
+// local write times otherwise.
+type PartIndexEntry struct {
+	Part      int       `json:"part"`
+	File      string    `json:"file"`
+	FirstTime time.Time `json:"first_time"`
+	LastTime  time.Time `json:"last_time"`
+	Rows      int64     `json:"rows"`
+}
+
+// ReadPartIndex reads a part index file written by AppendPartIndex.
+func ReadPartIndex(filePath string) ([]PartIndexEntry, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var entries []PartIndexEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse part index %s: %w", filePath, err)
+	}
+	return entries, nil
+}
+
+// AppendPartIndex adds an entry to the part index at filePath, creating it if necessary. The index is replaced
+// atomically so readers never see a partial file.
+func AppendPartIndex(filePath string, entry PartIndexEntry) error {
+	entries, err := ReadPartIndex(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	entries = append(entries, entry)
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode part index: %w", err)
+	}
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write part index %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename part index to %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// SelectParts is a pure function returning the entries whose time range overlaps [from, to).
+func SelectParts(entries []PartIndexEntry, from, to time.Time) []PartIndexEntry {
+	var selected []PartIndexEntry
+	for _, e := range entries {
+		if e.LastTime.Before(from) || !e.FirstTime.Before(to) {
+			continue
+		}
+		selected = append(selected, e)
+	}
+	return selected
+}
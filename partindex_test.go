@@ -0,0 +1,230 @@
+package gobinapi
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildPartFileName(t *testing.T) {
+	day := time.Date(2023, 10, 15, 8, 0, 0, 0, time.UTC)
+	if name := BuildPartFileName("trade", "BTCUSDT", day, 0); name != BuildFileName("trade", "BTCUSDT", day) {
+		t.Errorf("expected part 0 to use the regular file name, got %s", name)
+	}
+	name := BuildPartFileName("trade", "BTCUSDT", day, 2)
+	if name != "BTCUSDT_trade_2023-10-15_part002.parquet" {
+		t.Errorf("unexpected part file name %s", name)
+	}
+	symbol, dataType, date, part, err := ParsePartFileName(name)
+	if err != nil || symbol != "BTCUSDT" || dataType != "trade" || part != 2 || !date.Equal(day.Truncate(24*time.Hour)) {
+		t.Errorf("unexpected parse result %q %q %v %d %v", symbol, dataType, date, part, err)
+	}
+}
+
+func TestSelectParts(t *testing.T) {
+	base := time.Date(2023, 10, 15, 0, 0, 0, 0, time.UTC)
+	entries := []PartIndexEntry{
+		{Part: 0, FirstTime: base, LastTime: base.Add(time.Hour)},
+		{Part: 1, FirstTime: base.Add(time.Hour), LastTime: base.Add(2 * time.Hour)},
+		{Part: 2, FirstTime: base.Add(2 * time.Hour), LastTime: base.Add(3 * time.Hour)},
+	}
+	selected := SelectParts(entries, base.Add(90*time.Minute), base.Add(2*time.Hour))
+	if len(selected) != 1 || selected[0].Part != 1 {
+		t.Errorf("expected only part 1, got %+v", selected)
+	}
+}
+
+// TestRecorder_SplitsBySizeAndIndexesParts forces a split after every record and checks the part files, the index
+// and that a query only needs the parts overlapping its range.
+func TestRecorder_SplitsBySizeAndIndexesParts(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	day := time.Date(2023, 10, 15, 0, 0, 0, 0, time.UTC)
+	oldNow := NowFunc
+	NowFunc = func() time.Time { return day.Add(12 * time.Hour) }
+	defer func() { NowFunc = oldNow }()
+
+	r, err := NewRecorder[Trade]("BTCUSDT", "trade", 1)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	r.SetMaxFileSize(1)
+	for i := int64(1); i <= 3; i++ {
+		if err := r.Write(Trade{TradeID: i, TradeTime: day.Add(time.Duration(i) * time.Hour).UnixMilli()}); err != nil {
+			t.Fatalf("failed to write trade %d: %v", i, err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	index, err := ReadPartIndex(BuildPartIndexFileName("trade", "BTCUSDT", day))
+	if err != nil {
+		t.Fatalf("failed to read part index: %v", err)
+	}
+	if len(index) != 3 {
+		t.Fatalf("expected 3 index entries, got %+v", index)
+	}
+	for i, e := range index {
+		if e.Part != i || e.File != BuildPartFileName("trade", "BTCUSDT", day, i) || e.Rows != 1 ||
+			!e.FirstTime.Equal(day.Add(time.Duration(i+1)*time.Hour)) {
+			t.Errorf("unexpected index entry %d: %+v", i, e)
+		}
+		if !FileExists(e.File) {
+			t.Errorf("part file %s is missing", e.File)
+		}
+	}
+
+	q := Query{Dir: dir, Symbol: "BTCUSDT", DataType: "trade", From: day.Add(2 * time.Hour), To: day.Add(3 * time.Hour)}
+	if files := q.files(); len(files) != 1 {
+		t.Errorf("expected the query to open a single part, got %v", files)
+	}
+	records, err := q.Run()
+	if err != nil || len(records) != 1 || records[0].(Trade).TradeID != 2 {
+		t.Errorf("expected trade 2, got %+v (err %v)", records, err)
+	}
+}
+
+func TestRecorder_RotateStartsIndexedPart(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	day := time.Date(2023, 10, 15, 0, 0, 0, 0, time.UTC)
+	oldNow := NowFunc
+	NowFunc = func() time.Time { return day.Add(12 * time.Hour) }
+	defer func() { NowFunc = oldNow }()
+
+	r, err := NewRecorder[Trade]("BTCUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	if err := r.Rotate(); err != nil {
+		t.Fatalf("failed to rotate an empty file: %v", err)
+	}
+	if r.Stats().Files != 0 {
+		t.Fatalf("expected rotating an empty file to do nothing")
+	}
+	for i := int64(1); i <= 3; i++ {
+		if err := r.Write(Trade{TradeID: i, TradeTime: day.Add(time.Duration(i) * time.Hour).UnixMilli()}); err != nil {
+			t.Fatalf("failed to write trade %d: %v", i, err)
+		}
+		if i == 2 {
+			if err := r.Rotate(); err != nil {
+				t.Fatalf("failed to rotate: %v", err)
+			}
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+	if err := r.Rotate(); err != nil {
+		t.Errorf("expected rotating a closed recorder to do nothing, got %v", err)
+	}
+
+	index, err := ReadPartIndex(BuildPartIndexFileName("trade", "BTCUSDT", day))
+	if err != nil {
+		t.Fatalf("failed to read part index: %v", err)
+	}
+	if len(index) != 2 || index[0].Rows != 2 || index[1].Rows != 1 || index[1].File != BuildPartFileName("trade", "BTCUSDT", day, 1) {
+		t.Fatalf("expected parts of 2 and 1 rows, got %+v", index)
+	}
+	trades, err := ReadParquetFile[Trade](BuildPartFileName("trade", "BTCUSDT", day, 1))
+	if err != nil || len(trades) != 1 || trades[0].TradeID != 3 {
+		t.Errorf("expected the trade after the rotation in part 1, got %+v (%v)", trades, err)
+	}
+}
+
+func TestRecorder_RotationIntervalSplitsByPeriod(t *testing.T) {
+	t.Chdir(t.TempDir())
+	day := time.Date(2023, 10, 15, 0, 0, 0, 0, time.UTC)
+	now := day.Add(10*time.Hour + 30*time.Minute)
+	oldNow := NowFunc
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = oldNow }()
+
+	r, err := NewRecorder[Trade]("BTCUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	r.SetRotationInterval(time.Hour)
+	// Two trades in the 10:00 hour, none in the 11:00 hour and one in the 12:00 hour
+	for i, at := range []time.Duration{10*time.Hour + 30*time.Minute, 10*time.Hour + 59*time.Minute, 12*time.Hour + time.Minute} {
+		now = day.Add(at)
+		if err := r.Write(Trade{TradeID: int64(i + 1), TradeTime: now.UnixMilli()}); err != nil {
+			t.Fatalf("failed to write trade %d: %v", i+1, err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	index, err := ReadPartIndex(BuildPartIndexFileName("trade", "BTCUSDT", day))
+	if err != nil {
+		t.Fatalf("failed to read part index: %v", err)
+	}
+	if len(index) != 2 || index[0].Rows != 2 || index[1].Rows != 1 || !index[1].FirstTime.Equal(day.Add(12*time.Hour+time.Minute)) {
+		t.Fatalf("expected an hourly part of 2 rows and one of 1 row, got %+v", index)
+	}
+}
+
+func TestNewRecorder_ResumesExistingDayInNewPart(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	day := time.Date(2023, 10, 15, 0, 0, 0, 0, time.UTC)
+	oldNow, oldPolicy := NowFunc, DefaultExistingFilePolicy
+	NowFunc = func() time.Time { return day.Add(12 * time.Hour) }
+	DefaultExistingFilePolicy = ExistingFileNewPart
+	defer func() { NowFunc, DefaultExistingFilePolicy = oldNow, oldPolicy }()
+
+	record := func(ids ...int64) string {
+		t.Helper()
+		r, err := NewRecorder[Trade]("BTCUSDT", "trade", 10)
+		if err != nil {
+			t.Fatalf("failed to create recorder: %v", err)
+		}
+		for _, id := range ids {
+			if err := r.Write(Trade{TradeID: id, TradeTime: day.Add(time.Duration(id) * time.Hour).UnixMilli()}); err != nil {
+				t.Fatalf("failed to write trade %d: %v", id, err)
+			}
+		}
+		if err := r.Close(); err != nil {
+			t.Fatalf("failed to close recorder: %v", err)
+		}
+		return r.filePath
+	}
+	if got := record(1, 2); got != BuildFileName("trade", "BTCUSDT", day) {
+		t.Fatalf("expected the first recording to use the day's file, got %s", got)
+	}
+	if got := record(3); got != BuildPartFileName("trade", "BTCUSDT", day, 1) {
+		t.Fatalf("expected the restarted recording to continue in part 1, got %s", got)
+	}
+	// A part left unreadable, e.g. by a crash, is skipped but not indexed
+	if err := os.WriteFile(BuildPartFileName("trade", "BTCUSDT", day, 2), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := record(4); got != BuildPartFileName("trade", "BTCUSDT", day, 3) {
+		t.Fatalf("expected the next recording to continue in part 3, got %s", got)
+	}
+
+	index, err := ReadPartIndex(BuildPartIndexFileName("trade", "BTCUSDT", day))
+	if err != nil {
+		t.Fatalf("failed to read part index: %v", err)
+	}
+	var parts []int
+	for _, e := range index {
+		parts = append(parts, e.Part)
+	}
+	if fmt.Sprint(parts) != "[0 1 3]" || index[0].Rows != 2 || !index[0].FirstTime.Equal(day) {
+		t.Fatalf("expected parts 0, 1 and 3 to be indexed, got %+v", index)
+	}
+	records, err := Query{Dir: dir, Symbol: "BTCUSDT", DataType: "trade", From: day, To: day.Add(24 * time.Hour)}.Run()
+	if err != nil || len(records) != 4 {
+		t.Errorf("expected all 4 trades through the index, got %d (err %v)", len(records), err)
+	}
+
+	DefaultExistingFilePolicy = ExistingFileFail
+	if _, err := NewRecorder[Trade]("BTCUSDT", "trade", 10); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected the fail policy to refuse the existing file, got %v", err)
+	}
+}
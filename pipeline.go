@@ -0,0 +1,212 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// instrumentPipeline is the running state of one instrument's pipelines, kept so they can be changed while Run is
+// recording (see adminAPI).
+type instrumentPipeline struct {
+	instrument string
+	// streams is the instrument's stream selection, see Config.StreamsFor
+	streams map[string]bool
+	// listeners are the instrument's WebSocket streams, keyed by their stream name suffix (see listenerKey)
+	listeners map[string]streamListener
+	recorders []fileRecorder
+	// ctx is cancelled to stop the instrument; stopped is closed once its queues are closed
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+	// removed is closed if the instrument is stopped while Run keeps recording, which also stops its snapshots
+	removed chan struct{}
+
+	mu sync.Mutex
+	// running holds the listeners that are switched on, with the function stopping each one
+	running map[string]context.CancelFunc
+	// listening tracks the goroutines of per-stream listeners
+	listening sync.WaitGroup
+}
+
+// listenerKey returns the stream name suffix a WebSocket stream selected in Config.Streams is subscribed with, or
+// "" for streams that do not come from a WebSocket.
+func listenerKey(stream string) string {
+	switch stream {
+	case StreamTrade, StreamAggTrade, StreamDepth, StreamBookTicker, StreamTicker, StreamTicker1h, StreamTicker4h, StreamTicker1d, StreamAvgPrice:
+		return stream
+	case StreamMarkPrice:
+		return StreamMarkPrice + "@1s"
+	}
+	return ""
+}
+
+// startListener starts the per-stream listener of key, which reconnects on its own, failing over between the
+// configured endpoints, and is restarted by Supervise if it fails otherwise, e.g. by a panic while decoding. The
+// caller holds p.mu.
+func (p *instrumentPipeline) startListener(key string, cfg Config, env *pipelineEnv) {
+	l := p.listeners[key]
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.running[key] = cancel
+	stream := strings.ToLower(p.instrument) + "@" + key
+	endpoints := env.newStreamEndpoints(cfg.FailoverAfter)
+	p.listening.Add(1)
+	go func() {
+		defer p.listening.Done()
+		listen := func(ctx context.Context) error {
+			return ListenWithFailover(ctx, stream, endpoints, l.listen, env.logger)
+		}
+		if err := Supervise(ctx, "listener for "+stream, DefaultSupervisorPolicy, listen, env.logger); err != nil {
+			env.fail(err)
+		}
+	}()
+}
+
+// stopWhenDone waits for the instrument to be stopped, with runCtx or on its own, and then closes its queues once no
+// listener writes to them any more. The queues drain into the recorders, which their subscription handlers close.
+func (p *instrumentPipeline) stopWhenDone(runCtx context.Context, env *pipelineEnv) {
+	<-p.ctx.Done()
+	// From here on no listener is switched on any more
+	p.mu.Lock()
+	p.running = nil
+	p.mu.Unlock()
+	if env.manager != nil {
+		// Run's shutdown stops the multiplexed connection itself
+		if runCtx.Err() == nil && slices.Contains(env.manager.Symbols(), p.instrument) {
+			if err := env.manager.RemoveSymbol(p.instrument); err != nil {
+				env.logger.Errorf("Failed to unsubscribe %s: %v", p.instrument, err)
+			}
+		}
+		for key := range p.listeners {
+			env.router.remove(strings.ToLower(p.instrument) + "@" + key)
+		}
+	} else {
+		p.listening.Wait()
+		for _, l := range p.listeners {
+			l.done()
+		}
+	}
+	if env.bookTickers != nil {
+		env.bookTickers.remove(strings.ToLower(p.instrument) + "@bookTicker")
+	}
+	close(p.stopped)
+}
+
+// setStream switches the WebSocket stream of an instrument on or off. While off, its recorder stays open and
+// receives nothing; the order book diff subscription resynchronises from a new snapshot when depth is switched back
+// on.
+func (p *instrumentPipeline) setStream(stream string, on bool, cfg Config, env *pipelineEnv) error {
+	key := listenerKey(stream)
+	if stream == StreamBookTicker && p.streams[stream] && env.bookTickers != nil {
+		return fmt.Errorf("%s receives its book tickers from the %s stream, which cannot be switched", p.instrument, AllBookTickersStream)
+	}
+	if _, ok := p.listeners[key]; !ok {
+		return fmt.Errorf("%s does not record the %s WebSocket stream", p.instrument, stream)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running == nil {
+		return fmt.Errorf("%s is being stopped", p.instrument)
+	}
+	stop, running := p.running[key]
+	if running == on {
+		return nil
+	}
+	if env.manager != nil {
+		var err error
+		if on {
+			err = env.manager.AddStreams(p.instrument, key)
+		} else {
+			err = env.manager.RemoveStreams(p.instrument, key)
+		}
+		if err != nil {
+			return err
+		}
+		if on {
+			p.running[key] = func() {}
+		} else {
+			delete(p.running, key)
+		}
+		return nil
+	}
+	if on {
+		p.startListener(key, cfg, env)
+	} else {
+		stop()
+		delete(p.running, key)
+	}
+	return nil
+}
+
+// InstrumentStatus describes a recorded instrument for the admin API.
+type InstrumentStatus struct {
+	Instrument string `json:"instrument"`
+	// Streams lists the recorded streams, see Config.StreamsFor
+	Streams []string `json:"streams"`
+	// Listening lists the WebSocket streams that are switched on
+	Listening []string `json:"listening"`
+}
+
+func (p *instrumentPipeline) status() InstrumentStatus {
+	status := InstrumentStatus{Instrument: p.instrument, Streams: []string{}, Listening: []string{}}
+	for stream := range p.streams {
+		status.Streams = append(status.Streams, stream)
+	}
+	p.mu.Lock()
+	for stream := range p.streams {
+		if key := listenerKey(stream); key != "" && p.running[key] != nil {
+			status.Listening = append(status.Listening, stream)
+		}
+	}
+	p.mu.Unlock()
+	sort.Strings(status.Streams)
+	sort.Strings(status.Listening)
+	return status
+}
+
+// stopInstrument stops recording instrument: its snapshots are no longer scheduled, its listeners stop and its
+// queues drain into its recorders, which are then closed. It returns once the queues are closed. The caller holds
+// env.mu.
+func stopInstrument(env *pipelineEnv, instrument string) error {
+	p, ok := env.pipelines[instrument]
+	if !ok {
+		return fmt.Errorf("%s is not being recorded", instrument)
+	}
+	delete(env.pipelines, instrument)
+	env.snapshots.Remove(instrument)
+	if env.topSnapshots != nil {
+		env.topSnapshots.Remove(instrument)
+	}
+	close(p.removed)
+	p.cancel()
+	<-p.stopped
+	return nil
+}
+
+// forwardSnapshots copies snapshots from src to dst until src is closed or removed is, and then closes dst. src is
+// closed by Run once the snapshot schedulers have stopped; snapshots delivered after removal are dropped.
+func forwardSnapshots(removed <-chan struct{}, src <-chan OrderBookSnapshot, dst chan<- OrderBookSnapshot) {
+	defer func() {
+		close(dst)
+		for range src {
+		}
+	}()
+	for {
+		select {
+		case <-removed:
+			return
+		case snapshot, ok := <-src:
+			if !ok {
+				return
+			}
+			select {
+			case dst <- snapshot:
+			case <-removed:
+				return
+			}
+		}
+	}
+}
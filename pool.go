@@ -0,0 +1,45 @@
+package gobinapi
+
+import "sync"
+
+// Order book diffs carry their price levels in slices that encoding/json would allocate afresh for every message,
+// which for full-depth streams of many symbols makes up most of the garbage the recorder produces. levelPool keeps
+// the backing arrays of released diffs for the next ones to be decoded into. The diff structs themselves travel the
+// pipelines by value and allocate nothing.
+var levelPool = sync.Pool{
+	New: func() any {
+		levels := make([]PriceLevel, 0, pooledLevelsCap)
+		return &levels
+	},
+}
+
+// pooledLevelsCap is the capacity of new pooled slices, enough for a 100ms diff of a busy symbol. maxPooledLevels
+// keeps the rare huge slices, e.g. of deep snapshots, from being held by the pool.
+const (
+	pooledLevelsCap = 64
+	maxPooledLevels = 1024
+)
+
+// acquireLevels returns an empty slice from the pool to decode price levels into.
+func acquireLevels() []PriceLevel {
+	return (*levelPool.Get().(*[]PriceLevel))[:0]
+}
+
+// ReleaseLevels hands the backing array of levels back to the pool. levels and every slice sharing its array must
+// not be used afterwards.
+func ReleaseLevels(levels []PriceLevel) {
+	if cap(levels) == 0 || cap(levels) > maxPooledLevels {
+		return
+	}
+	// Drop the strings, so the pool does not keep them alive
+	clear(levels[:cap(levels)])
+	levels = levels[:0]
+	levelPool.Put(&levels)
+}
+
+// ReleaseOrderBookDiff releases the price levels of diff (see ReleaseLevels) once nothing holds the diff any more,
+// e.g. as the release function of its Recorder (see Recorder.SetRelease).
+func ReleaseOrderBookDiff(diff OrderBookDiff) {
+	ReleaseLevels(diff.Bids)
+	ReleaseLevels(diff.Asks)
+}
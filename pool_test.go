@@ -0,0 +1,78 @@
+package gobinapi
+
+import (
+	"testing"
+	"time"
+)
+
+// TestOrderBookDiffHandler_DecodesIntoReleasedLevels decodes a diff into slices released by a larger one and checks
+// that none of the larger diff's levels show through.
+func TestOrderBookDiffHandler_DecodesIntoReleasedLevels(t *testing.T) {
+	out := make(chan OrderBookDiff, 2)
+	handle := orderBookDiffHandler("btcusdt@depth", out)
+	session := WSSession{ID: "s1", Generation: 1}
+	big := `{"e":"depthUpdate","s":"BTCUSDT","U":1,"u":2,"b":[["1","1"],["2","2"],["3","3"]],"a":[["4","4"],["5","5"]]}`
+	if err := handle([]byte(big), session); err != nil {
+		t.Fatalf("failed to decode diff: %v", err)
+	}
+	ReleaseOrderBookDiff(<-out)
+
+	small := `{"e":"depthUpdate","s":"BTCUSDT","U":3,"u":3,"b":[["6","0.5"]],"a":[]}`
+	if err := handle([]byte(small), session); err != nil {
+		t.Fatalf("failed to decode diff: %v", err)
+	}
+	diff := <-out
+	if len(diff.Bids) != 1 || diff.Bids[0] != (PriceLevel{Price: "6", Quantity: "0.5"}) || len(diff.Asks) != 0 {
+		t.Errorf("unexpected levels bids %v asks %v", diff.Bids, diff.Asks)
+	}
+}
+
+// TestRecorder_ReleasesRecordsOnceEncoded overwrites every released diff's levels, as their next use would, and checks
+// that diffs are only released once encoded, so the file holds the levels as written.
+func TestRecorder_ReleasesRecordsOnceEncoded(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	r, err := NewRecorder[OrderBookDiff]("BTCUSDT", "orderBookDiff", 2)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	released := 0
+	r.SetRelease(func(diff OrderBookDiff) {
+		for i := range diff.Bids {
+			diff.Bids[i] = PriceLevel{Price: "reused", Quantity: "reused"}
+		}
+		released++
+	})
+
+	for id := int64(1); id <= 5; id++ {
+		bids := append(acquireLevels(), PriceLevel{Price: "100", Quantity: "1"})
+		if err := r.Write(OrderBookDiff{FirstUpdateID: id, FinalUpdateID: id, Bids: bids}); err != nil {
+			t.Fatalf("failed to write diff: %v", err)
+		}
+	}
+	if released != 0 {
+		t.Fatalf("expected no diff released before the parquet writer encodes them, got %d", released)
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	if released != 5 {
+		t.Errorf("expected every diff released once flushed, got %d", released)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	diffs, err := ReadParquetFile[OrderBookDiff](r.filePath)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", r.filePath, err)
+	}
+	if len(diffs) != 5 {
+		t.Fatalf("expected 5 diffs, got %d", len(diffs))
+	}
+	for _, diff := range diffs {
+		if len(diff.Bids) != 1 || diff.Bids[0].Price != "100" {
+			t.Errorf("diff %d was changed after its release: %v", diff.FirstUpdateID, diff.Bids)
+		}
+	}
+}
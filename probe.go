@@ -0,0 +1,160 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+func init() {
+	DefaultMetrics.Describe("binance_endpoint_probe_seconds", "gauge", "Duration of the latest successful health probe per endpoint.")
+	DefaultMetrics.Describe("binance_endpoint_probe_failures_total", "counter", "Health probes per endpoint that failed or exceeded the latency limit.")
+	DefaultMetrics.Describe("binance_endpoint_healthy", "gauge", "1 if the endpoint passes its health probes, 0 if it is avoided.")
+}
+
+// ProbeTimeout bounds a single health probe.
+var ProbeTimeout = 10 * time.Second
+
+// EndpointHealth is what the health probes of one endpoint found.
+type EndpointHealth struct {
+	Endpoint string
+	// Latency is how long the latest successful probe took.
+	Latency time.Duration
+	// Failures counts the consecutive probes that failed or took longer than the prober's latency limit.
+	Failures  int
+	LastError string
+	LastProbe time.Time
+}
+
+// EndpointProber probes endpoints in the background, so that StreamEndpoints consulting it (see SetProber) leave an
+// endpoint that fails or is slow before the streams and requests on it do.
+type EndpointProber struct {
+	probe       func(ctx context.Context, endpoint string) error
+	maxLatency  time.Duration
+	maxFailures int
+
+	mu     sync.Mutex
+	health map[string]*EndpointHealth
+}
+
+// NewEndpointProber creates a prober checking endpoints with probe. An endpoint becomes unhealthy after maxFailures
+// consecutive probes that failed or, if maxLatency is positive, took longer than maxLatency, and healthy again with
+// the next good probe.
+func NewEndpointProber(probe func(ctx context.Context, endpoint string) error, maxLatency time.Duration, maxFailures int) *EndpointProber {
+	return &EndpointProber{probe: probe, maxLatency: maxLatency, maxFailures: maxFailures, health: make(map[string]*EndpointHealth)}
+}
+
+// Run probes endpoints right away and then every interval until ctx is cancelled.
+func (p *EndpointProber) Run(ctx context.Context, endpoints []string, interval time.Duration) {
+	ticker := DefaultClock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.Probe(ctx, endpoints)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Probe probes each of endpoints once, one after the other.
+func (p *EndpointProber) Probe(ctx context.Context, endpoints []string) {
+	for _, endpoint := range endpoints {
+		if ctx.Err() != nil {
+			return
+		}
+		probeCtx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+		start := NowFunc()
+		err := p.probe(probeCtx, endpoint)
+		latency := NowFunc().Sub(start)
+		cancel()
+		if err == nil && p.maxLatency > 0 && latency > p.maxLatency {
+			err = fmt.Errorf("took %s, over the %s limit", latency, p.maxLatency)
+		}
+		p.record(endpoint, latency, err)
+	}
+}
+
+// record updates endpoint's health with the outcome of a probe.
+func (p *EndpointProber) record(endpoint string, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.health[endpoint]
+	if !ok {
+		h = &EndpointHealth{Endpoint: endpoint}
+		p.health[endpoint] = h
+	}
+	h.LastProbe = NowFunc()
+	labels := Labels{"endpoint": endpoint}
+	if err != nil {
+		h.Failures++
+		h.LastError = err.Error()
+		DefaultMetrics.Add("binance_endpoint_probe_failures_total", labels, 1)
+	} else {
+		h.Failures, h.LastError, h.Latency = 0, "", latency
+		DefaultMetrics.Set("binance_endpoint_probe_seconds", labels, latency.Seconds())
+	}
+	healthy := 0.0
+	if h.Failures < p.maxFailures {
+		healthy = 1
+	}
+	DefaultMetrics.Set("binance_endpoint_healthy", labels, healthy)
+}
+
+// Healthy reports whether endpoint passes its probes. Endpoints not probed yet count as healthy.
+func (p *EndpointProber) Healthy(endpoint string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.health[endpoint]
+	return !ok || h.Failures < p.maxFailures
+}
+
+// Health returns the health of every endpoint probed so far, sorted by endpoint.
+func (p *EndpointProber) Health() []EndpointHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]EndpointHealth, 0, len(p.health))
+	for _, h := range p.health {
+		out = append(out, *h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Endpoint < out[j].Endpoint })
+	return out
+}
+
+// ProbeStreamEndpoint checks a WebSocket base by completing a handshake on its raw stream endpoint without
+// subscribing to anything, then closing the connection.
+func ProbeStreamEndpoint(ctx context.Context, base string) error {
+	conn, _, err := streamDialer.DialContext(ctx, base+"/ws", nil)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// RESTProbe returns a probe checking a REST base of market with client, by requesting its ping endpoint, which costs
+// a request weight of 1.
+func RESTProbe(client *http.Client, market string) func(ctx context.Context, base string) error {
+	path := "/api/v3/ping"
+	if market == MarketUSDM {
+		path = "/fapi/v1/ping"
+	}
+	return func(ctx context.Context, base string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("ping returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,77 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEndpointProber_AvoidsFailingAndSlowEndpoints(t *testing.T) {
+	clock := useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	slow := map[string]time.Duration{"wss://a": 10 * time.Millisecond, "wss://b": 10 * time.Millisecond}
+	down := map[string]bool{}
+	prober := NewEndpointProber(func(ctx context.Context, endpoint string) error {
+		clock.Advance(slow[endpoint])
+		if down[endpoint] {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, 100*time.Millisecond, 2)
+	endpoints := NewStreamEndpoints([]string{"wss://a", "wss://b", "wss://c"}, 3)
+	endpoints.SetProber(prober)
+	bases := []string{"wss://a", "wss://b", "wss://c"}
+
+	prober.Probe(context.Background(), bases)
+	if endpoints.Current() != "wss://a" {
+		t.Fatalf("expected to stay on a healthy endpoint, got %s", endpoints.Current())
+	}
+
+	// a turns slow and b goes down: one bad probe is not enough to leave a
+	slow["wss://a"] = 200 * time.Millisecond
+	down["wss://b"] = true
+	prober.Probe(context.Background(), bases)
+	if endpoints.Current() != "wss://a" {
+		t.Fatalf("expected one bad probe to be tolerated, got %s", endpoints.Current())
+	}
+	prober.Probe(context.Background(), bases)
+	if endpoints.Current() != "wss://c" {
+		t.Fatalf("expected to skip the unhealthy b for c, got %s", endpoints.Current())
+	}
+	health := prober.Health()
+	if len(health) != 3 || health[0].Failures != 2 || health[1].LastError != "connection refused" || health[2].Latency != 0 {
+		t.Errorf("unexpected health %+v", health)
+	}
+	if v := DefaultMetrics.Value("binance_endpoint_healthy", Labels{"endpoint": "wss://a"}); v != 0 {
+		t.Errorf("expected a to be reported unhealthy, got %v", v)
+	}
+
+	// Failing over on repeated session failures skips unhealthy endpoints as well
+	slow["wss://a"] = 0
+	prober.Probe(context.Background(), bases)
+	for range 3 {
+		endpoints.ReportFailure()
+	}
+	if endpoints.Current() != "wss://a" {
+		t.Errorf("expected to fail over from c to the recovered a past b, got %s", endpoints.Current())
+	}
+}
+
+func TestRESTProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fapi/v1/ping" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	if err := RESTProbe(srv.Client(), MarketUSDM)(context.Background(), srv.URL); err != nil {
+		t.Errorf("expected the futures ping to succeed, got %v", err)
+	}
+	if err := RESTProbe(srv.Client(), MarketSpot)(context.Background(), srv.URL); err == nil {
+		t.Error("expected a 404 to fail the probe")
+	}
+}
@@ -0,0 +1,98 @@
+package gobinapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testProxy is an HTTP proxy tunnelling CONNECT requests and forwarding plain ones, counting both.
+type testProxy struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests int
+}
+
+func newTestProxy(t *testing.T) *testProxy {
+	p := &testProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.requests++
+		p.mu.Unlock()
+		if r.Method != http.MethodConnect {
+			r.RequestURI = ""
+			resp, err := http.DefaultTransport.RoundTrip(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() { io.Copy(upstream, conn); upstream.Close() }()
+		go func() { io.Copy(conn, upstream); conn.Close() }()
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *testProxy) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests
+}
+
+func TestProxyRotation_SpreadsConnectionsOverProxies(t *testing.T) {
+	srv := useMockServer(t)
+	a, b := newTestProxy(t), newTestProxy(t)
+	proxies, err := NewProxyRotation([]string{a.URL, b.URL})
+	if err != nil {
+		t.Fatalf("failed to create rotation: %v", err)
+	}
+	old := DialProxy
+	DialProxy = proxies.Proxy
+	t.Cleanup(func() { DialProxy = old })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for range 2 {
+		if err := ProbeStreamEndpoint(ctx, srv.WSURL()); err != nil {
+			t.Fatalf("WebSocket handshake through the proxy failed: %v", err)
+		}
+	}
+	if a.count() != 1 || b.count() != 1 {
+		t.Fatalf("expected one WebSocket connection per proxy, got %d and %d", a.count(), b.count())
+	}
+
+	client := proxiedClient(proxies.Proxy, 5*time.Second)
+	for range 2 {
+		if err := RESTProbe(client, MarketSpot)(ctx, srv.URL()); err != nil {
+			t.Fatalf("REST request through the proxy failed: %v", err)
+		}
+	}
+	if a.count() != 2 || b.count() != 2 {
+		t.Errorf("expected REST requests to take turns as well, got %d and %d", a.count(), b.count())
+	}
+
+	if _, err := NewProxyRotation([]string{"socks4://10.0.0.2:1080"}); err == nil {
+		t.Error("expected an unsupported proxy scheme to be rejected")
+	}
+}
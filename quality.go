@@ -0,0 +1,277 @@
+package gobinapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultMaxEmptyPeriod is how long a series may go without records before CheckDayQuality reports the period.
+const DefaultMaxEmptyPeriod = time.Minute
+
+// QualityReport is the machine-readable result of CheckDayQuality: the data quality of every symbol and data type
+// recorded on one day.
+type QualityReport struct {
+	Date string `json:"date"`
+	Dir  string `json:"dir"`
+	// MaxEmptySeconds is the longest period without records that is not reported.
+	MaxEmptySeconds float64         `json:"max_empty_seconds"`
+	Series          []SeriesQuality `json:"series"`
+	// Issues is the number of problems found across all series; the day passed if it is zero.
+	Issues int `json:"issues"`
+}
+
+// SeriesQuality is the data quality of one symbol's data type over a day, across all of its part files. IDs are
+// checked over the whole day, so records a backfill appended to a later part fill the gaps they were fetched for,
+// while the order of IDs and event times is checked within each file.
+type SeriesQuality struct {
+	Symbol   string   `json:"symbol"`
+	DataType string   `json:"data_type"`
+	Files    []string `json:"files"`
+	Rows     int      `json:"rows"`
+	// FirstTime and LastTime are the earliest and latest event times, zero for types without one.
+	FirstTime time.Time `json:"first_time"`
+	LastTime  time.Time `json:"last_time"`
+	// IDGaps lists the trade IDs, aggregate trade IDs or spot depth update IDs missing from the day.
+	IDGaps     []IDGap `json:"id_gaps,omitempty"`
+	MissingIDs int64   `json:"missing_ids"`
+	// OutOfOrderIDs counts records whose IDs do not follow the previous record's, other than duplicates.
+	OutOfOrderIDs int `json:"out_of_order_ids"`
+	// ChainBreaks counts USD-M futures diffs whose previous final update ID is not the final update ID of the diff
+	// before them, the futures form of a depth discontinuity.
+	ChainBreaks int `json:"chain_breaks"`
+	// TimeRegressions counts records whose event time is before the previous record's.
+	TimeRegressions int `json:"time_regressions"`
+	// EmptyPeriods lists the stretches longer than the maximum without records, including those at the start and
+	// end of the day.
+	EmptyPeriods []EmptyPeriod `json:"empty_periods,omitempty"`
+	// Duplicates counts records repeating an earlier one: the same trade, aggregate trade or diff IDs, or an
+	// identical row for the other types.
+	Duplicates int `json:"duplicates"`
+}
+
+// Issues returns the number of problems found in the series.
+func (q SeriesQuality) Issues() int {
+	return len(q.IDGaps) + q.OutOfOrderIDs + q.ChainBreaks + q.TimeRegressions + len(q.EmptyPeriods) + q.Duplicates
+}
+
+// EmptyPeriod is a stretch of time without records.
+type EmptyPeriod struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Seconds float64   `json:"seconds"`
+}
+
+// CheckDayQuality validates the recordings of one day in dir, including those in hive partition directories below
+// it, reporting periods without records longer than maxEmpty (zero disables the check). The end of a day that is not
+// over yet is only checked up to now.
+func CheckDayQuality(dir string, date time.Time, maxEmpty time.Duration) (QualityReport, error) {
+	date = date.UTC().Truncate(24 * time.Hour)
+	report := QualityReport{Date: date.Format("2006-01-02"), Dir: dir, MaxEmptySeconds: maxEmpty.Seconds()}
+	names, err := listRecordingFiles(dir, date)
+	if err != nil {
+		return report, err
+	}
+
+	// Group the files by series, ordering each series' parts by part number
+	type partFile struct {
+		path string
+		part int
+	}
+	series := make(map[[2]string][]partFile)
+	var keys [][2]string
+	for _, name := range names {
+		symbol, dataType, _, part, err := ParsePartFileName(name)
+		if err != nil {
+			continue
+		}
+		key := [2]string{symbol, dataType}
+		if series[key] == nil {
+			keys = append(keys, key)
+		}
+		series[key] = append(series[key], partFile{filepath.Join(dir, name), part})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	to := date.Add(24 * time.Hour)
+	if now := NowFunc(); now.Before(to) {
+		to = now
+	}
+	for _, key := range keys {
+		parts := series[key]
+		sort.SliceStable(parts, func(i, j int) bool { return parts[i].part < parts[j].part })
+		var files [][]interface{}
+		var paths []string
+		for _, p := range parts {
+			records, err := ReadRecordingFile(key[1], p.path)
+			if err != nil {
+				return report, fmt.Errorf("failed to read %s: %w", p.path, err)
+			}
+			files = append(files, records)
+			paths = append(paths, p.path)
+		}
+		q := ComputeSeriesQuality(files, date, to, maxEmpty)
+		q.Symbol, q.DataType, q.Files = key[0], key[1], paths
+		report.Series = append(report.Series, q)
+		report.Issues += q.Issues()
+	}
+	return report, nil
+}
+
+// ComputeSeriesQuality is the pure core of CheckDayQuality: it checks the records of one series, given per file in
+// part order. Empty periods longer than maxEmpty are looked for between from and to; zero bounds only check between
+// records.
+func ComputeSeriesQuality(files [][]interface{}, from, to time.Time, maxEmpty time.Duration) SeriesQuality {
+	var q SeriesQuality
+	seen := make(map[uint64]bool)
+	var ranges [][2]int64
+	var times []time.Time
+	for _, records := range files {
+		var prevTime time.Time
+		var prevLast int64
+		havePrev := false
+		for _, record := range records {
+			q.Rows++
+			key := qualityRowKey(record)
+			if seen[key] {
+				q.Duplicates++
+				continue
+			}
+			seen[key] = true
+
+			if t, ok := recordEventTime(record); ok {
+				if !prevTime.IsZero() && t.Before(prevTime) {
+					q.TimeRegressions++
+				} else {
+					prevTime = t
+				}
+				times = append(times, t)
+			}
+			first, last, ok := sequenceIDs(record)
+			if !ok {
+				continue
+			}
+			if diff, isDiff := record.(OrderBookDiff); isDiff && diff.PrevFinalUpdateID != 0 {
+				// Futures update IDs are not contiguous; each diff names the final update ID of the one before
+				if havePrev && diff.PrevFinalUpdateID != prevLast {
+					q.ChainBreaks++
+				}
+				prevLast, havePrev = last, true
+				continue
+			}
+			if havePrev && first <= prevLast {
+				q.OutOfOrderIDs++
+			} else {
+				prevLast, havePrev = last, true
+			}
+			ranges = append(ranges, [2]int64{first, last})
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i][0] > ranges[i-1][1]+1 {
+			gap := IDGap{After: ranges[i-1][1], Before: ranges[i][0]}
+			q.IDGaps = append(q.IDGaps, gap)
+			q.MissingIDs += gap.Missing()
+		}
+		// Carry the highest ID seen forward, so an overlapping range does not open a false gap
+		ranges[i][1] = max(ranges[i][1], ranges[i-1][1])
+	}
+
+	if len(times) == 0 {
+		return q
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	q.FirstTime, q.LastTime = times[0], times[len(times)-1]
+	if maxEmpty <= 0 {
+		return q
+	}
+	empty := func(a, b time.Time) {
+		if b.Sub(a) > maxEmpty {
+			q.EmptyPeriods = append(q.EmptyPeriods, EmptyPeriod{From: a, To: b, Seconds: b.Sub(a).Seconds()})
+		}
+	}
+	if !from.IsZero() {
+		empty(from, times[0])
+	}
+	for i := 1; i < len(times); i++ {
+		empty(times[i-1], times[i])
+	}
+	if !to.IsZero() {
+		empty(times[len(times)-1], to)
+	}
+	return q
+}
+
+// recordEventTime returns the event time of records of the streamed types that carry one, or for book tops the time
+// they were taken.
+func recordEventTime(record interface{}) (time.Time, bool) {
+	var ms int64
+	switch r := record.(type) {
+	case Trade:
+		ms = r.EventTime
+	case AggTrade:
+		ms = r.EventTime
+	case OrderBookDiff:
+		ms = r.EventTime
+	case BestPrice:
+		ms = r.EventTime
+	case MarkPrice:
+		ms = r.EventTime
+	case AvgPrice:
+		ms = r.EventTime
+	case BookTop:
+		ms = r.Time
+	case MidPrice:
+		ms = r.Time
+	case BookFeatures:
+		ms = r.Time
+	case Ticker24h:
+		ms = r.EventTime
+	case OpenInterest:
+		ms = r.Time
+	case OpenInterestHist:
+		ms = r.Time
+	case LongShortRatio:
+		ms = r.Time
+	case OptionTrade:
+		ms = r.EventTime
+	case OptionTicker:
+		ms = r.EventTime
+	case OptionMarkPrice:
+		ms = r.EventTime
+	case OptionOpenInterest:
+		ms = r.EventTime
+	case ExecutionReport:
+		ms = r.EventTime
+	case AccountBalance:
+		ms = r.EventTime
+	case BalanceUpdate:
+		ms = r.EventTime
+	}
+	if ms == 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms).UTC(), true
+}
+
+// qualityRowKey returns the key duplicate records share: the final ID of types with an ID sequence, so an event
+// received on two connections counts as a duplicate, and a hash of the whole row otherwise.
+func qualityRowKey(record interface{}) uint64 {
+	if _, last, ok := sequenceIDs(record); ok {
+		return uint64(last)
+	}
+	data, _ := json.Marshal(record)
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
@@ -0,0 +1,108 @@
+package gobinapi
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestComputeSeriesQuality_FindsIssues(t *testing.T) {
+	base := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) int64 { return base.Add(time.Duration(seconds) * time.Second).UnixMilli() }
+	files := [][]interface{}{
+		{
+			Trade{TradeID: 1, EventTime: at(0)},
+			Trade{TradeID: 2, EventTime: at(1)},
+			Trade{TradeID: 2, EventTime: at(1), ConnID: "other"},
+			Trade{TradeID: 5, EventTime: at(120)},
+			Trade{TradeID: 6, EventTime: at(119)},
+			Trade{TradeID: 4, EventTime: at(121)},
+		},
+		// A backfilled part filling most of the gap, out of order relative to the first file
+		{Trade{TradeID: 3, EventTime: at(2)}},
+	}
+	q := ComputeSeriesQuality(files, time.Time{}, time.Time{}, time.Minute)
+	if q.Rows != 7 || q.Duplicates != 1 {
+		t.Errorf("expected 7 rows with 1 duplicate, got %d rows and %d duplicates", q.Rows, q.Duplicates)
+	}
+	if len(q.IDGaps) != 0 {
+		t.Errorf("expected the backfilled part to close the gap, got %+v", q.IDGaps)
+	}
+	if q.OutOfOrderIDs != 1 || q.TimeRegressions != 1 {
+		t.Errorf("expected 1 out-of-order ID and 1 time regression, got %d and %d", q.OutOfOrderIDs, q.TimeRegressions)
+	}
+	if len(q.EmptyPeriods) != 1 || !q.EmptyPeriods[0].From.Equal(base.Add(2*time.Second)) || q.EmptyPeriods[0].Seconds != 117 {
+		t.Errorf("expected one empty period of 117s after 12:00:02, got %+v", q.EmptyPeriods)
+	}
+	if q.Issues() != 4 {
+		t.Errorf("expected 4 issues, got %d", q.Issues())
+	}
+}
+
+func TestComputeSeriesQuality_DepthDiscontinuities(t *testing.T) {
+	spot := [][]interface{}{{
+		OrderBookDiff{FirstUpdateID: 10, FinalUpdateID: 12},
+		OrderBookDiff{FirstUpdateID: 13, FinalUpdateID: 15},
+		OrderBookDiff{FirstUpdateID: 19, FinalUpdateID: 20},
+	}}
+	q := ComputeSeriesQuality(spot, time.Time{}, time.Time{}, 0)
+	if len(q.IDGaps) != 1 || q.IDGaps[0] != (IDGap{After: 15, Before: 19}) || q.MissingIDs != 3 {
+		t.Errorf("expected update IDs 16-18 missing, got %+v", q.IDGaps)
+	}
+
+	futures := [][]interface{}{{
+		OrderBookDiff{FirstUpdateID: 100, FinalUpdateID: 110, PrevFinalUpdateID: 95},
+		OrderBookDiff{FirstUpdateID: 115, FinalUpdateID: 120, PrevFinalUpdateID: 110},
+		OrderBookDiff{FirstUpdateID: 131, FinalUpdateID: 140, PrevFinalUpdateID: 125},
+	}}
+	q = ComputeSeriesQuality(futures, time.Time{}, time.Time{}, 0)
+	if q.ChainBreaks != 1 || len(q.IDGaps) != 0 {
+		t.Errorf("expected 1 chain break and no ID gaps for futures diffs, got %d and %+v", q.ChainBreaks, q.IDGaps)
+	}
+}
+
+func TestCheckDayQuality(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 20, 1, 0, 0, 0, time.UTC))
+	dir := t.TempDir()
+	day := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+	// Trades every 30 seconds over the whole day, missing trade 10
+	var trades []Trade
+	for i := int64(0); i < 2880; i++ {
+		if i != 10 {
+			trades = append(trades, Trade{TradeID: i + 1, EventTime: day.Add(time.Duration(i) * 30 * time.Second).UnixMilli()})
+		}
+	}
+	if err := WriteParquetFile(filepath.Join(dir, BuildFileName("trade", "BTCUSDT", day)), trades); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteParquetFile(filepath.Join(dir, BuildFileName("trade", "BTCUSDT", day.Add(24*time.Hour))), []Trade{{TradeID: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := CheckDayQuality(dir, day, time.Minute)
+	if err != nil {
+		t.Fatalf("quality check failed: %v", err)
+	}
+	if report.Date != "2025-02-19" || len(report.Series) != 1 {
+		t.Fatalf("expected one series on 2025-02-19, got %+v", report)
+	}
+	q := report.Series[0]
+	if q.Symbol != "BTCUSDT" || q.DataType != "trade" || q.Rows != 2879 || len(q.Files) != 1 {
+		t.Errorf("unexpected series %s %s with %d rows in %v", q.Symbol, q.DataType, q.Rows, q.Files)
+	}
+	if len(q.IDGaps) != 1 || q.IDGaps[0] != (IDGap{After: 10, Before: 12}) {
+		t.Errorf("expected trade 11 missing, got %+v", q.IDGaps)
+	}
+	// The missing trade leaves a minute without trades, which is not longer than the maximum
+	if len(q.EmptyPeriods) != 0 || report.Issues != 1 {
+		t.Errorf("expected only the ID gap, got %+v and %d issues", q.EmptyPeriods, report.Issues)
+	}
+
+	report, err = CheckDayQuality(dir, day, 50*time.Second)
+	if err != nil {
+		t.Fatalf("quality check failed: %v", err)
+	}
+	if q := report.Series[0]; len(q.EmptyPeriods) != 1 || q.EmptyPeriods[0].Seconds != 60 {
+		t.Errorf("expected one empty minute with a shorter maximum, got %+v", q.EmptyPeriods)
+	}
+}
@@ -0,0 +1,316 @@
+package gobinapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ReadRecordingFile reads a parquet file written by a Recorder for the given data type ("trade", "aggTrade",
+// "orderBookDiff", "bestPrice", "markPrice", "avgPrice", "snapshot", "snapshotTop", "bookTop", "raw", "exchangeInfo",
+// "summary", "midPrice", "bookFeatures", "ticker", "miniTicker", "ticker_<window>" or one of the futures statistics
+// like OpenInterestDataType), an options data type like OptionTradeDataType, a user data type like
+// ExecutionReportDataType, klines downloaded as KlineDataType or ReferenceKlineDataType or bars built as BarDataType,
+// and returns its rows as values of the corresponding struct.
+func ReadRecordingFile(dataType string, filePath string) ([]interface{}, error) {
+	switch dataType {
+	case "trade":
+		return readRecordsAs[Trade](filePath)
+	case "aggTrade":
+		return readRecordsAs[AggTrade](filePath)
+	case "orderBookDiff":
+		return readRecordsAs[OrderBookDiff](filePath)
+	case "bestPrice":
+		return readRecordsAs[BestPrice](filePath)
+	case "markPrice":
+		return readRecordsAs[MarkPrice](filePath)
+	case "avgPrice":
+		return readRecordsAs[AvgPrice](filePath)
+	case "snapshot", "snapshotTop":
+		return readRecordsAs[OrderBookSnapshot](filePath)
+	case "bookTop":
+		return readRecordsAs[BookTop](filePath)
+	case "raw":
+		return readRecordsAs[RawMessage](filePath)
+	case "exchangeInfo":
+		return readRecordsAs[SymbolInfo](filePath)
+	case SummaryDataType:
+		return readRecordsAs[DailySummary](filePath)
+	case MidPriceDataType:
+		return readRecordsAs[MidPrice](filePath)
+	case BookFeaturesDataType:
+		return readRecordsAs[BookFeatures](filePath)
+	case TickerDataType, MiniTickerDataType, "ticker_1h", "ticker_4h", "ticker_1d":
+		return readRecordsAs[Ticker24h](filePath)
+	case OpenInterestDataType:
+		return readRecordsAs[OpenInterest](filePath)
+	case OpenInterestHistDataType:
+		return readRecordsAs[OpenInterestHist](filePath)
+	case TopLongShortPositionRatioDataType, TopLongShortAccountRatioDataType:
+		return readRecordsAs[LongShortRatio](filePath)
+	case OptionTradeDataType:
+		return readRecordsAs[OptionTrade](filePath)
+	case OptionTickerDataType:
+		return readRecordsAs[OptionTicker](filePath)
+	case OptionMarkPriceDataType:
+		return readRecordsAs[OptionMarkPrice](filePath)
+	case OptionOpenInterestDataType:
+		return readRecordsAs[OptionOpenInterest](filePath)
+	case ExecutionReportDataType:
+		return readRecordsAs[ExecutionReport](filePath)
+	case AccountPositionDataType:
+		return readRecordsAs[AccountBalance](filePath)
+	case BalanceUpdateDataType:
+		return readRecordsAs[BalanceUpdate](filePath)
+	default:
+		if _, ok := KlineInterval(dataType); ok {
+			return readRecordsAs[Kline](filePath)
+		}
+		if _, _, ok := ReferenceKlineKind(dataType); ok {
+			return readRecordsAs[Kline](filePath)
+		}
+		if _, ok := BarInterval(dataType); ok {
+			return readRecordsAs[Bar](filePath)
+		}
+		return nil, fmt.Errorf("unsupported data type: %s", dataType)
+	}
+}
+
+// recordingDataTypes lists the data types ReadRecordingFile accepts, which are those Run records.
+var recordingDataTypes = []string{"trade", "aggTrade", "orderBookDiff", "bestPrice", "markPrice", "avgPrice", "snapshot", "snapshotTop", "bookTop", "raw", "exchangeInfo", "midPrice", "bookFeatures", "ticker", "miniTicker", "ticker_1h", "ticker_4h", "ticker_1d", "openInterest", "openInterestHist", "topLongShortPositionRatio", "topLongShortAccountRatio", "optionTrade", "optionTicker", "optionMarkPrice", "optionOpenInterest", "executionReport", "outboundAccountPosition", "balanceUpdate"}
+
+func isRecordingDataType(dataType string) bool {
+	for _, t := range recordingDataTypes {
+		if t == dataType {
+			return true
+		}
+	}
+	return false
+}
+
+func readRecordsAs[T any](filePath string) ([]interface{}, error) {
+	rows, err := ReadParquetFile[T](filePath)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]interface{}, len(rows))
+	for i, row := range rows {
+		records[i] = row
+	}
+	return records, nil
+}
+
+// RecordingFileNames is a pure function returning the daily file names (see BuildFileName) that can hold records of
+// symbol and dataType between from (inclusive) and to (exclusive).
+func RecordingFileNames(symbol string, dataType string, from, to time.Time) []string {
+	var names []string
+	day := from.UTC().Truncate(24 * time.Hour)
+	for day.Before(to) {
+		names = append(names, BuildFileName(dataType, symbol, day))
+		day = day.Add(24 * time.Hour)
+	}
+	return names
+}
+
+// RecordTime returns the exchange time of a record, if its type carries one. Book tickers and snapshots do not;
+// book tops derived from the local order book carry the local time they were taken at, raw messages the local time
+// they were received at and exchange info the time it was fetched at.
+func RecordTime(record interface{}) (time.Time, bool) {
+	switch r := record.(type) {
+	case Trade:
+		return time.UnixMilli(r.TradeTime).UTC(), true
+	case AggTrade:
+		return time.UnixMilli(r.TradeTime).UTC(), true
+	case OrderBookDiff:
+		return time.UnixMilli(r.EventTime).UTC(), true
+	case MarkPrice:
+		return time.UnixMilli(r.EventTime).UTC(), true
+	case AvgPrice:
+		return time.UnixMilli(r.EventTime).UTC(), true
+	case OpenInterest:
+		return time.UnixMilli(r.Time).UTC(), true
+	case OpenInterestHist:
+		return time.UnixMilli(r.Time).UTC(), true
+	case LongShortRatio:
+		return time.UnixMilli(r.Time).UTC(), true
+	case Ticker24h:
+		return time.UnixMilli(r.EventTime).UTC(), true
+	case OptionTrade:
+		return time.UnixMilli(r.TradeTime).UTC(), true
+	case OptionTicker:
+		return time.UnixMilli(r.EventTime).UTC(), true
+	case OptionMarkPrice:
+		return time.UnixMilli(r.EventTime).UTC(), true
+	case OptionOpenInterest:
+		return time.UnixMilli(r.EventTime).UTC(), true
+	case ExecutionReport:
+		return time.UnixMilli(r.TransactionTime).UTC(), true
+	case AccountBalance:
+		return time.UnixMilli(r.EventTime).UTC(), true
+	case BalanceUpdate:
+		return time.UnixMilli(r.EventTime).UTC(), true
+	case BookTop:
+		return time.UnixMilli(r.Time).UTC(), true
+	case RawMessage:
+		return time.UnixMilli(r.ReceiveTime).UTC(), true
+	case Kline:
+		return time.UnixMilli(r.OpenTime).UTC(), true
+	case SymbolInfo:
+		return time.UnixMilli(r.FetchTime).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// Query selects recorded rows of one symbol and data type over a time range.
+type Query struct {
+	Dir      string
+	Symbol   string
+	DataType string
+	// From and To bound the range, inclusive and exclusive respectively. Records without an exchange time are
+	// selected by the date of the file they are in.
+	From, To time.Time
+}
+
+// Run reads every daily file overlapping the query range that exists in Dir, flat or in hive partitions, and
+// returns the matching rows in file order. Missing days are skipped. Days that were split into part files are read
+// through their part index, so only the parts overlapping the range are opened.
+func (q Query) Run() ([]interface{}, error) {
+	var records []interface{}
+	for _, filePath := range q.files() {
+		rows, err := ReadRecordingFile(q.DataType, filePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if t, ok := RecordTime(row); ok && (t.Before(q.From) || !t.Before(q.To)) {
+				continue
+			}
+			records = append(records, row)
+		}
+	}
+	return records, nil
+}
+
+// files returns the existing files that may hold rows in the query range.
+func (q Query) files() []string {
+	var files []string
+	day := q.From.UTC().Truncate(24 * time.Hour)
+	for ; day.Before(q.To); day = day.Add(24 * time.Hour) {
+		for _, layout := range []FileLayout{{Root: q.Dir}, {Root: q.Dir, Hive: true}} {
+			indexPath := layout.IndexPath(q.DataType, q.Symbol, day)
+			index, err := ReadPartIndex(indexPath)
+			if err != nil {
+				if filePath := layout.FilePath(q.DataType, q.Symbol, day, 0); FileExists(filePath) {
+					files = append(files, filePath)
+				}
+				continue
+			}
+			for _, entry := range SelectParts(index, q.From, q.To) {
+				files = append(files, filepath.Join(filepath.Dir(indexPath), entry.File))
+			}
+		}
+	}
+	return files
+}
+
+// VWAP is a pure function returning the volume-weighted average price and total volume of the trades and
+// aggregate trades among records. ok is false when there is no traded volume.
+func VWAP(records []interface{}) (vwap float64, volume float64, ok bool) {
+	var notional float64
+	for _, record := range records {
+		var price, qty string
+		switch r := record.(type) {
+		case Trade:
+			price, qty = r.Price, r.Quantity
+		case AggTrade:
+			price, qty = r.Price, r.Quantity
+		default:
+			continue
+		}
+		p, err1 := strconv.ParseFloat(price, 64)
+		q, err2 := strconv.ParseFloat(qty, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		notional += p * q
+		volume += q
+	}
+	if volume == 0 {
+		return 0, 0, false
+	}
+	return notional / volume, volume, true
+}
+
+// WriteRecordsJSON writes records as JSON lines.
+func WriteRecordsJSON(w io.Writer, records []interface{}) error {
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteRecordsCSV writes records as CSV with a header of parquet column names. Repeated fields such as price levels
+// are written as JSON arrays. All records must have the same type.
+func WriteRecordsCSV(w io.Writer, records []interface{}) error {
+	cw := csv.NewWriter(w)
+	if len(records) > 0 {
+		t := reflect.TypeOf(records[0])
+		header := make([]string, t.NumField())
+		for i := range header {
+			header[i] = t.Field(i).Name
+			if kv, err := parseParquetTag(t.Field(i).Tag.Get("parquet")); err == nil && kv["name"] != "" {
+				header[i] = kv["name"]
+			}
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		row := make([]string, len(header))
+		for _, record := range records {
+			v := reflect.ValueOf(record)
+			if v.Type() != t {
+				return fmt.Errorf("mixed record types %s and %s", t, v.Type())
+			}
+			for i := range row {
+				row[i] = formatCSVField(v.Field(i))
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCSVField(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Ptr:
+		// Optional columns: null is an empty cell
+		if v.IsNil() {
+			return ""
+		}
+		return formatCSVField(v.Elem())
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return string(data)
+}
@@ -0,0 +1,77 @@
+package gobinapi
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordingFileNames(t *testing.T) {
+	from := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	names := RecordingFileNames("BTCUSDT", "trade", from, from.Add(24*time.Hour))
+	if strings.Join(names, ",") != "BTCUSDT_trade_2025-02-19.parquet,BTCUSDT_trade_2025-02-20.parquet" {
+		t.Errorf("unexpected file names: %v", names)
+	}
+}
+
+func TestQueryRun_FiltersByTimeAcrossDays(t *testing.T) {
+	dir := t.TempDir()
+	day1 := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	trade := func(id int64, at time.Time, price, qty string) Trade {
+		return Trade{EventType: "trade", TradeID: id, TradeTime: at.UnixMilli(), Price: price, Quantity: qty}
+	}
+	if err := WriteParquetFile(filepath.Join(dir, BuildFileName("trade", "BTCUSDT", day1)), []Trade{
+		trade(1, day1.Add(22*time.Hour), "100", "1"),
+		trade(2, day1.Add(23*time.Hour), "110", "3"),
+	}); err != nil {
+		t.Fatalf("failed to write day 1: %v", err)
+	}
+	if err := WriteParquetFile(filepath.Join(dir, BuildFileName("trade", "BTCUSDT", day2)), []Trade{
+		trade(3, day2.Add(time.Hour), "120", "1"),
+		trade(4, day2.Add(3*time.Hour), "130", "1"),
+	}); err != nil {
+		t.Fatalf("failed to write day 2: %v", err)
+	}
+
+	q := Query{Dir: dir, Symbol: "BTCUSDT", DataType: "trade", From: day1.Add(23 * time.Hour), To: day2.Add(2 * time.Hour)}
+	records, err := q.Run()
+	if err != nil {
+		t.Fatalf("query returned error: %v", err)
+	}
+	if len(records) != 2 || records[0].(Trade).TradeID != 2 || records[1].(Trade).TradeID != 3 {
+		t.Fatalf("expected trades 2 and 3, got %+v", records)
+	}
+
+	vwap, volume, ok := VWAP(records)
+	if !ok || volume != 4 || math.Abs(vwap-112.5) > 1e-9 {
+		t.Errorf("expected vwap 112.5 over volume 4, got %v over %v (ok=%v)", vwap, volume, ok)
+	}
+
+	var csvOut bytes.Buffer
+	if err := WriteRecordsCSV(&csvOut, records); err != nil {
+		t.Fatalf("WriteRecordsCSV returned error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "event_type,event_time,trade_id,price,quantity") ||
+		!strings.HasPrefix(lines[1], "trade,0,2,110,3,") {
+		t.Errorf("unexpected CSV output:\n%s", csvOut.String())
+	}
+
+	var jsonOut bytes.Buffer
+	if err := WriteRecordsJSON(&jsonOut, records); err != nil {
+		t.Fatalf("WriteRecordsJSON returned error: %v", err)
+	}
+	if n := strings.Count(jsonOut.String(), "\n"); n != 2 {
+		t.Errorf("expected 2 JSON lines, got %d", n)
+	}
+}
+
+func TestVWAP_NoVolume(t *testing.T) {
+	if _, _, ok := VWAP([]interface{}{BestPrice{BidPrice: "1", BidQty: "1"}}); ok {
+		t.Errorf("expected no VWAP without trades")
+	}
+}
@@ -0,0 +1,40 @@
+package gobinapi
+
+// RawMessage is a WebSocket frame recorded untouched by the raw archive (see Config.ArchiveRaw), so recordings can be
+// rebuilt from the exchange's own messages after a parsing bug has been fixed.
+type RawMessage struct {
+	// Stream is the full name of the stream the frame belongs to, e.g. btcusdt@trade
+	Stream string `json:"stream" parquet:"name=stream, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	// ReceiveTime is the local time the frame was read, in Unix milliseconds.
+	ReceiveTime int64 `json:"receive_time" timestamp:"millis" parquet:"name=receive_time, type=INT64"`
+	// Payload is the frame as received, before any decoding.
+	Payload string `json:"payload" parquet:"name=payload, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the frame was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// archiveFrames returns a handler copying every frame of stream to out, stamped with its receive time, before
+// passing it on to next. Frames are archived even if next fails to decode them.
+func archiveFrames(stream string, out chan<- RawMessage, next func(msg []byte, session WSSession) error) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
+		out <- RawMessage{
+			Stream:         stream,
+			ReceiveTime:    NowFunc().UnixMilli(),
+			Payload:        string(msg),
+			ConnID:         session.ID,
+			ConnGeneration: session.Generation,
+		}
+		return next(msg, session)
+	}
+}
+
+// SubscribeRawMessages listens to the raw frame channel and writes each RawMessage to the provided RecorderWriter.
+func SubscribeRawMessages(rawCh <-chan RawMessage, recorder RecorderWriter[RawMessage], logger LoggerInterface) {
+	for raw := range rawCh {
+		if err := recorder.Write(raw); err != nil {
+			logger.Errorf("error writing raw message: %v", err)
+		}
+	}
+}
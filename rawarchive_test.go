@@ -0,0 +1,32 @@
+package gobinapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestArchiveFrames_CopiesFramesBeforeDecoding(t *testing.T) {
+	oldNow := NowFunc
+	NowFunc = func() time.Time { return time.UnixMilli(1700000000123) }
+	defer func() { NowFunc = oldNow }()
+
+	out := make(chan RawMessage, 1)
+	decodeErr := errors.New("bad frame")
+	var decoded []byte
+	handle := archiveFrames("btcusdt@trade", out, func(msg []byte, session WSSession) error {
+		decoded = msg
+		return decodeErr
+	})
+	msg := []byte(`{"e":"trade"}`)
+	if err := handle(msg, WSSession{ID: "c1", Generation: 2}); err != decodeErr {
+		t.Fatalf("expected the decoder's error, got %v", err)
+	}
+	// The archived payload is a copy, so reusing the read buffer cannot change it
+	msg[2] = 'x'
+	raw := <-out
+	want := RawMessage{Stream: "btcusdt@trade", ReceiveTime: 1700000000123, Payload: `{"e":"trade"}`, ConnID: "c1", ConnGeneration: 2}
+	if raw != want || decoded == nil {
+		t.Errorf("expected %+v to be archived and the frame decoded, got %+v", want, raw)
+	}
+}
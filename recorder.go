@@ -1,24 +1,34 @@
-package main
+package gobinapi
 
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
 	"github.com/xitongsys/parquet-go/writer"
-	"github.com/xitongsys/parquet-go/parquet"
 )
 
-var NowFunc = time.Now
-
 // Recorder encapsulates a parquet-go writer and a local file handle.
 // It enforces a naming convention (one file per instrument per UTC date with data type in the filename),
 // checks for existing files to prevent resuming, rotates files when a new UTC day starts, and batches writes
 // to minimize dynamic allocations.
 // This implementation follows a functional core, imperative shell approach to facilitate unit testing.
+// T is the record type, whose struct tags define the parquet schema.
 
-type Recorder struct {
+type Recorder[T any] struct {
+	// mu serializes Write, Rotate and Close, which may be called from different goroutines
+	mu          sync.Mutex
+	layout      FileLayout
+	options     ParquetOptions
+	// codec converts records to the columns options ask for, if they differ from the record type's
+	codec       *columnCodec
+	existing    string
 	instrument  string
 	dataType    string
 	batchSize   int
@@ -26,58 +36,155 @@ type Recorder struct {
 	filePath    string
 	localFile   *local.LocalFile
 	pw          *writer.ParquetWriter
-	batchBuffer []interface{}
-	prototype   interface{}
+	batchBuffer []T
+	// rows holds the batchBuffer records converted by codec, if it is set
+	rows        []interface{}
+	fileStart   time.Time
+	gate        FinalizeGate
+	onFinalize  func(filePath string)
+	// release, see SetRelease, gets the records in encoding once the parquet writer has encoded them
+	release     func(T)
+	encoding    []T
+	// metadata, see SetMetadata, is added to the key-value metadata in each file's footer
+	metadata    map[string]string
+	// manifest, see SetManifest, lists finished files in the day's manifest
+	manifest    bool
+	// checksums and verify, see SetChecksums and SetVerify, write checksum sidecars and check finished files
+	checksums   bool
+	verify      bool
+	// diskGuard, see SetDiskGuard, sheds records while disk space is low
+	diskGuard   *DiskGuard
+	// summary, see SetSummary, collects the daily summary of the written records
+	summary     *SummaryCollector
+
+	// Size- and time-based splitting into part files, see SetMaxFileSize and SetRotationInterval. partWrite is
+	// the local time of the last write into the current part.
+	maxFileSize int64
+	rotateEvery time.Duration
+	partWrite   time.Time
+	part        int
+	partRows    int64
+	partFirst   time.Time
+	partLast    time.Time
+	// split is set once the day has been split by Rotate, so its parts are indexed like size-based ones
+	split       bool
+	closed      bool
+
+	// Time-based flushing, see SetFlushInterval. dirty is set while written rows are not yet on disk, and flushErr
+	// holds a failed background flush until the next Write reports it.
+	flushStop   chan struct{}
+	dirty       bool
+	flushErr    error
+
+	// Asynchronous writing, see SetWriteQueue. queue carries records to the writer goroutine, which closes
+	// queueDone when it returns. queueMu guards the counts of records enqueued and written, which queueCond signals
+	// and Flush, Rotate and Close wait on, and queueErr, a failed queued write not yet reported by Write.
+	queue       chan queuedRecord[T]
+	queueDone   chan struct{}
+	queueMu     sync.Mutex
+	queueCond   *sync.Cond
+	enqueued    int64
+	written     int64
+	queueErr    error
+	queueClosed bool
+
+	statsMu sync.Mutex
+	stats   RecorderStats
 }
 
-// NewRecorder creates a new Recorder for the given instrument and data type using the provided prototype
-// (which defines the parquet schema) and batchSize. It builds the file name based on the current UTC date,
-// and returns an error if a file for the current day already exists (to avoid resuming).
-func NewRecorder(instrument string, dataType string, prototype interface{}, batchSize int) (*Recorder, error) {
-	now := NowFunc().UTC()
-	currentDate := now.Format("2006-01-02")
-	fileName := BuildFileName(dataType, instrument, now)
-	if FileExists(fileName) {
-		return nil, fmt.Errorf("file %s already exists, not resuming recording", fileName)
-	}
+// fileRecorder is the part of a Recorder that does not depend on its record type, so recorders of different types
+// can be managed together.
+type fileRecorder interface {
+	Stats() RecorderStats
+	Rotate() error
+	Close() error
+}
 
-	lf, err := local.NewLocalFileWriter(fileName)
-	if err != nil {
+// RecorderStats summarizes a Recorder's activity for introspection.
+type RecorderStats struct {
+	Instrument string    `json:"instrument"`
+	DataType   string    `json:"data_type"`
+	File       string    `json:"file"`
+	Rows       int64     `json:"rows"`
+	FileRows   int64     `json:"file_rows"`
+	Files      int64     `json:"files_finished"`
+	LastWrite  time.Time `json:"last_write"`
+}
+
+// NewRecorder creates a new Recorder of records of type T (which defines the parquet schema) for the given
+// instrument and data type, buffering batchSize records. It builds the file name based on the current UTC date.
+// If a file for the current day already exists, it returns an error or continues in a new part file, depending on
+// DefaultExistingFilePolicy. Files are placed according to DefaultFileLayout and encoded with
+// ParquetOptionsFor(dataType).
+func NewRecorder[T any](instrument string, dataType string, batchSize int) (*Recorder[T], error) {
+	r := &Recorder[T]{
+		layout:      DefaultFileLayout,
+		options:     ParquetOptionsFor(dataType),
+		existing:    DefaultExistingFilePolicy,
+		instrument:  instrument,
+		dataType:    dataType,
+		batchSize:   batchSize,
+		batchBuffer: make([]T, 0, batchSize),
+		stats:       RecorderStats{Instrument: instrument, DataType: dataType},
+	}
+	r.codec = r.options.columnEncoding().codec(reflect.TypeFor[T]())
+	if err := r.openFile(NowFunc().UTC()); err != nil {
 		return nil, err
 	}
-	lfConcrete, ok := lf.(*local.LocalFile)
-	if !ok {
-		lf.Close()
-		return nil, fmt.Errorf("failed type assertion for local file")
+	introspectRecorder(r, true)
+	return r, nil
+}
+
+// Write adds a record to the Recorder. It performs file rotation if the current UTC day has changed and batches
+// the writes. Once the batch size is reached, the buffered records are flushed to the parquet writer. Failures
+// raise an AlertWriteFailure alert. With a write queue (see SetWriteQueue), the record is only queued here, and
+// while a disk guard sheds the recorder's data type (see SetDiskGuard) it is dropped. A record whose numbers do not
+// fit the decimal columns is dropped as well (see RecordDrop), with an AlertWriteFailure alert but no error.
+func (r *Recorder[T]) Write(record T) error {
+	if r.diskGuard != nil && r.diskGuard.Sheds(r.dataType) {
+		RecordDrop(r.instrument+"_"+r.dataType, record)
+		return nil
 	}
+	if r.queue != nil {
+		return r.enqueue(record)
+	}
+	return r.writeAt(record, NowFunc().UTC())
+}
 
-	pw, err := writer.NewParquetWriter(lf, prototype, int64(batchSize))
+// writeAt writes a record written at now, raising an alert on failure.
+func (r *Recorder[T]) writeAt(record T, now time.Time) error {
+	err := r.write(record, now)
 	if err != nil {
-		lf.Close()
-		return nil, err
+		DefaultAlerts.Raise(Alert{Kind: AlertWriteFailure, Symbol: r.instrument, Stream: r.dataType, Message: err.Error()})
 	}
+	return err
+}
 
-	pw.RowGroupSize = 128 * 1024 * 1024 // 128 MB
-	pw.PageSize = 8 * 1024             // 8 KB
-	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+func (r *Recorder[T]) write(record T, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.flushErr; err != nil {
+		r.flushErr = nil
+		return err
+	}
 
-	return &Recorder{
-		instrument:  instrument,
-		dataType:    dataType,
-		batchSize:   batchSize,
-		currentDate: currentDate,
-		filePath:    fileName,
-		localFile:   lfConcrete,
-		pw:          pw,
-		batchBuffer: make([]interface{}, 0, batchSize),
-		prototype:   prototype,
-	}, nil
-}
+	// A record whose numbers do not fit the decimal columns is dropped before it counts towards the part, the stats
+	// or the summary
+	var row interface{}
+	if r.codec != nil {
+		v, err := r.codec.encode(reflect.ValueOf(record))
+		if err != nil {
+			RecordDrop(r.instrument+"_"+r.dataType, record)
+			DefaultAlerts.Raise(Alert{Kind: AlertWriteFailure, Symbol: r.instrument, Stream: r.dataType,
+				Message: fmt.Sprintf("dropped a record not fitting the decimal columns: %v", err)})
+			if r.release != nil {
+				r.release(record)
+			}
+			return nil
+		}
+		row = v.Interface()
+	}
 
-// Write adds a record to the Recorder. It performs file rotation if the current UTC day has changed and batches
-// the writes. Once the batch size is reached, the buffered records are flushed to the parquet writer.
-func (r *Recorder) Write(record interface{}) error {
-	now := NowFunc().UTC()
 	currentDay := now.Format("2006-01-02")
 	if currentDay != r.currentDate {
 		if err := r.rotate(now); err != nil {
@@ -85,76 +192,441 @@ func (r *Recorder) Write(record interface{}) error {
 		}
 	}
 
+	// Split before writing, so a part is only started when there is a record for it
+	full := r.maxFileSize > 0 && r.estimatedSize() >= r.maxFileSize
+	due := r.rotateEvery > 0 && rotationPeriod(now, r.rotateEvery) != rotationPeriod(r.partWrite, r.rotateEvery)
+	if r.partRows > 0 && (full || due) {
+		if err := r.rotatePart(now); err != nil {
+			return err
+		}
+	}
+
 	r.batchBuffer = append(r.batchBuffer, record)
+	if r.codec != nil {
+		r.rows = append(r.rows, row)
+	}
+	r.dirty = true
+	r.trackPart(record, now)
+	r.statsMu.Lock()
+	r.stats.Rows++
+	r.stats.FileRows++
+	r.stats.LastWrite = now
+	r.statsMu.Unlock()
+	if r.summary != nil {
+		r.summary.Observe(r.instrument, r.dataType, record, now)
+	}
 	if len(r.batchBuffer) >= r.batchSize {
 		return r.flushBuffer()
 	}
 	return nil
 }
 
-// flushBuffer writes all buffered records to the parquet writer and then resets the buffer.
-func (r *Recorder) flushBuffer() error {
-	for _, rec := range r.batchBuffer {
-		if err := r.pw.Write(rec); err != nil {
+// flushBuffer writes all buffered records, converted by the codec if there is one, to the parquet writer and then
+// resets the buffer.
+func (r *Recorder[T]) flushBuffer() error {
+	if len(r.batchBuffer) == 0 {
+		return nil
+	}
+	defer r.observeFlush(time.Now())
+	for i := range r.batchBuffer {
+		var row interface{} = r.batchBuffer[i]
+		if r.codec != nil {
+			row = r.rows[i]
+		}
+		if err := r.pw.Write(row); err != nil {
 			return err
 		}
 	}
+	if r.release != nil {
+		r.encoding = append(r.encoding, r.batchBuffer...)
+		// The writer holds records until a page is full, and has encoded all it was given once it holds none
+		if len(r.pw.Objs) == 0 {
+			r.releaseEncoded()
+		}
+	}
 	r.batchBuffer = r.batchBuffer[:0]
+	clear(r.rows)
+	r.rows = r.rows[:0]
 	return nil
 }
 
+// releaseEncoded passes the records the parquet writer has encoded to the release function.
+func (r *Recorder[T]) releaseEncoded() {
+	for _, record := range r.encoding {
+		r.release(record)
+	}
+	clear(r.encoding)
+	r.encoding = r.encoding[:0]
+}
+
 // rotate finalizes the current file and starts a new parquet file for the new day.
-func (r *Recorder) rotate(newTime time.Time) error {
+func (r *Recorder[T]) rotate(newTime time.Time) error {
+	if err := r.finishFile(); err != nil {
+		return err
+	}
+	r.part = 0
+	r.split = false
+	return r.openFile(newTime)
+}
+
+// Rotate finishes the current file and continues the day in the next part file (see BuildPartFileName), e.g. so the
+// data recorded so far can be picked up before the day ends. The day's parts are then listed in its part index. It
+// does nothing if the current file has no rows yet or the recorder is closed.
+func (r *Recorder[T]) Rotate() error {
+	r.awaitQueue()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.partRows == 0 {
+		return nil
+	}
+	now := NowFunc().UTC()
+	if now.Format("2006-01-02") != r.currentDate {
+		return r.rotate(now)
+	}
+	r.split = true
+	return r.rotatePart(now)
+}
+
+// rotatePart finalizes the current file once it has reached the maximum size and continues the same day in the
+// next part file.
+func (r *Recorder[T]) rotatePart(now time.Time) error {
+	if err := r.finishFile(); err != nil {
+		return err
+	}
+	r.part++
+	return r.openFile(now)
+}
+
+// finishFile flushes and closes the current file and applies finalization.
+func (r *Recorder[T]) finishFile() error {
 	if err := r.flushBuffer(); err != nil {
 		return err
 	}
+	r.pw.Footer.KeyValueMetadata = keyValueMetadata(r.footerMetadata())
 	if err := r.pw.WriteStop(); err != nil {
 		return err
 	}
+	r.releaseEncoded()
 	if err := r.localFile.Close(); err != nil {
 		return err
 	}
+	r.statsMu.Lock()
+	r.stats.Files++
+	r.statsMu.Unlock()
+	return r.finalize()
+}
 
-	newDate := newTime.Format("2006-01-02")
-	newFileName := BuildFileName(r.dataType, r.instrument, newTime)
+// openFile starts the current part file of the day of newTime. An existing file is never overwritten: depending on
+// the existing file policy, openFile fails or resumes the day in the next unused part.
+func (r *Recorder[T]) openFile(newTime time.Time) error {
+	newFileName := r.layout.FilePath(r.dataType, r.instrument, newTime, r.part)
 	if FileExists(newFileName) {
-		return errors.New(fmt.Sprintf("file %s already exists, not resuming recording", newFileName))
+		if r.existing != ExistingFileNewPart {
+			return errors.New(fmt.Sprintf("file %s already exists, not resuming recording", newFileName))
+		}
+		if err := r.resume(newTime); err != nil {
+			return err
+		}
+		newFileName = r.layout.FilePath(r.dataType, r.instrument, newTime, r.part)
+	}
+	if dir := filepath.Dir(newFileName); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory %s: %w", dir, err)
+		}
 	}
 
 	lf, err := local.NewLocalFileWriter(newFileName)
 	if err != nil {
 		return err
 	}
-	pw, err := writer.NewParquetWriter(lf, r.prototype, int64(r.batchSize))
+	var prototype interface{} = new(T)
+	if r.codec != nil {
+		prototype = r.codec.prototype()
+	}
+	pw, err := newParquetWriter(lf, prototype, int64(r.batchSize), r.options)
 	if err != nil {
 		lf.Close()
 		return err
 	}
-	pw.RowGroupSize = 128 * 1024 * 1024 // 128 MB
-	pw.PageSize = 8 * 1024             // 8 KB
-	pw.CompressionType = parquet.CompressionCodec_SNAPPY
-	
+
 	lfConcrete, ok := lf.(*local.LocalFile)
 	if !ok {
 		lf.Close()
-		return fmt.Errorf("failed type assertion for local file in rotate")
+		return fmt.Errorf("failed type assertion for local file")
 	}
 
 	r.localFile = lfConcrete
-	r.currentDate = newDate
+	r.currentDate = newTime.Format("2006-01-02")
 	r.pw = pw
 	r.filePath = newFileName
+	r.fileStart = newTime
 	r.batchBuffer = r.batchBuffer[:0]
+	r.rows = r.rows[:0]
+	r.dirty = false
+	r.partRows = 0
+	r.partFirst, r.partLast, r.partWrite = time.Time{}, time.Time{}, time.Time{}
+	r.statsMu.Lock()
+	r.stats.File, r.stats.FileRows = newFileName, 0
+	r.statsMu.Unlock()
 	return nil
 }
 
-// Close flushes any remaining buffered records, finalizes the parquet writer, and closes the underlying file.
-func (r *Recorder) Close() error {
-	if err := r.flushBuffer(); err != nil {
+// resume advances the part number past the day's existing part files and lists those not yet in the day's part
+// index there, so the day is read through its index like a split one. Their time range is not known without reading
+// them, so they are listed as covering the day up to their last modification; files that cannot be read, e.g. left
+// without a footer by a crash, are not listed.
+func (r *Recorder[T]) resume(day time.Time) error {
+	indexPath := r.layout.IndexPath(r.dataType, r.instrument, day)
+	index, err := ReadPartIndex(indexPath)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	if err := r.pw.WriteStop(); err != nil {
+	listed := make(map[string]bool, len(index))
+	for _, entry := range index {
+		listed[entry.File] = true
+	}
+	for ; ; r.part++ {
+		filePath := r.layout.FilePath(r.dataType, r.instrument, day, r.part)
+		info, err := os.Stat(filePath)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %w", filePath, err)
+		}
+		if listed[filepath.Base(filePath)] {
+			continue
+		}
+		rows, ok := parquetRowCount[T](filePath)
+		if !ok {
+			continue
+		}
+		entry := PartIndexEntry{
+			Part:      r.part,
+			File:      filepath.Base(filePath),
+			FirstTime: day.UTC().Truncate(24 * time.Hour),
+			LastTime:  info.ModTime().UTC(),
+			Rows:      rows,
+		}
+		if err := AppendPartIndex(indexPath, entry); err != nil {
+			return err
+		}
+	}
+	r.split = true
+	return nil
+}
+
+// parquetRowCount returns the number of rows in the footer of the parquet file at filePath, and false if the file
+// cannot be read.
+func parquetRowCount[T any](filePath string) (int64, bool) {
+	fr, err := local.NewLocalFileReader(filePath)
+	if err != nil {
+		return 0, false
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, new(T), 1)
+	if err != nil {
+		return 0, false
+	}
+	defer pr.ReadStop()
+	return pr.GetNumRows(), true
+}
+
+// Stats returns a snapshot of the recorder's activity. It is safe to call concurrently with Write.
+func (r *Recorder[T]) Stats() RecorderStats {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	return r.stats
+}
+
+// SetMaxFileSize enables intra-day splitting: once the current file's estimated size reaches maxBytes, it is
+// finished and recording continues in the next part file (see BuildPartFileName). Every finished file is then
+// also listed in the day's part index (see PartIndexEntry), so consumers can select parts by time without opening
+// them. Zero disables splitting.
+func (r *Recorder[T]) SetMaxFileSize(maxBytes int64) {
+	r.maxFileSize = maxBytes
+}
+
+// SetRotationInterval enables time-based splitting: the day is divided into periods of interval starting at UTC
+// midnight, e.g. hours, and every period's records go to their own part file, listed in the day's part index like
+// size-based parts. Both can be combined. interval must divide a day evenly (see Config.Validate); zero disables
+// time-based splitting.
+func (r *Recorder[T]) SetRotationInterval(interval time.Duration) {
+	r.rotateEvery = interval
+}
+
+// rotationPeriod is a pure function returning the number of the period of length interval since UTC midnight that
+// t falls in.
+func rotationPeriod(t time.Time, interval time.Duration) int64 {
+	return int64(t.Sub(t.Truncate(24*time.Hour)) / interval)
+}
+
+// estimatedSize returns the bytes written to the current file plus the data buffered for its next row group.
+func (r *Recorder[T]) estimatedSize() int64 {
+	return r.pw.Offset + r.pw.Size + r.pw.ObjsSize
+}
+
+// trackPart updates the row count and time range of the current file for the part index.
+func (r *Recorder[T]) trackPart(record T, now time.Time) {
+	t, ok := RecordTime(record)
+	if !ok {
+		t = now
+	}
+	r.partRows++
+	r.partWrite = now
+	if r.partFirst.IsZero() || t.Before(r.partFirst) {
+		r.partFirst = t
+	}
+	if t.After(r.partLast) {
+		r.partLast = t
+	}
+}
+
+// Flush writes the buffered records and the rows the parquet writer holds in memory to the current file as a row
+// group, so they are on disk even if the process dies before the file is finished. It does nothing if nothing was
+// written since the last flush or the recorder is closed.
+func (r *Recorder[T]) Flush() error {
+	r.awaitQueue()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || !r.dirty {
+		return nil
+	}
+	if err := r.flushBuffer(); err != nil {
 		return err
 	}
-	return r.localFile.Close()
+	start := time.Now()
+	if err := r.pw.Flush(true); err != nil {
+		return fmt.Errorf("failed to flush %s: %w", r.filePath, err)
+	}
+	r.observeFlush(start)
+	r.releaseEncoded()
+	r.dirty = false
+	return nil
+}
+
+// Healthy returns the error of a failed background flush or queued write not yet reported by Write, or an error
+// once the recorder is closed, making Recorder a Sink.
+func (r *Recorder[T]) Healthy() error {
+	r.queueMu.Lock()
+	queueErr := r.queueErr
+	r.queueMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return fmt.Errorf("%s recorder for %s is closed", r.dataType, r.instrument)
+	}
+	if r.flushErr != nil {
+		return r.flushErr
+	}
+	return queueErr
+}
+
+// SetFlushInterval makes the recorder Flush at least every interval until it is closed, in addition to flushing
+// full batches, which bounds how much a low-volume stream can lose on a crash. Every flush ends a row group, so
+// short intervals produce many small row groups on busy streams. A failed flush is returned by the next Write. Zero
+// leaves flushing to the batch size.
+func (r *Recorder[T]) SetFlushInterval(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if interval <= 0 || r.closed || r.flushStop != nil {
+		return
+	}
+	r.flushStop = make(chan struct{})
+	go r.flushEvery(interval, r.flushStop)
+}
+
+// flushEvery flushes the recorder on every tick of interval until stop is closed.
+func (r *Recorder[T]) flushEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := DefaultClock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+			if err := r.Flush(); err != nil {
+				r.mu.Lock()
+				r.flushErr = err
+				r.mu.Unlock()
+			}
+		}
+	}
+}
+
+// SetFinalizeGate installs a gate consulted whenever a file is finished (on rotation or Close). If the gate
+// disallows finalization, the finished file is renamed with StandbySuffix instead of keeping its final name.
+func (r *Recorder[T]) SetFinalizeGate(gate FinalizeGate) {
+	r.gate = gate
+}
+
+// SetFinalizeHook installs a function called with the path of every file that is finished under its final name,
+// e.g. to queue it for upload.
+func (r *Recorder[T]) SetFinalizeHook(hook func(filePath string)) {
+	r.onFinalize = hook
+}
+
+// SetRelease installs a function the recorder passes every record to once the parquet writer has encoded it, e.g.
+// ReleaseOrderBookDiff to reuse the record's buffers. It is only for recorders holding the last reference to their
+// records, and must be called before the first Write.
+func (r *Recorder[T]) SetRelease(release func(T)) {
+	r.release = release
+}
+
+// finalize applies the finalize gate to the file that was just closed.
+func (r *Recorder[T]) finalize() error {
+	if r.verify {
+		r.verifyFile()
+	}
+	if r.gate == nil || r.gate.ShouldFinalize(r.fileStart) {
+		if r.maxFileSize > 0 || r.rotateEvery > 0 || r.split {
+			entry := PartIndexEntry{
+				Part:      r.part,
+				File:      filepath.Base(r.filePath),
+				FirstTime: r.partFirst,
+				LastTime:  r.partLast,
+				Rows:      r.partRows,
+			}
+			if err := AppendPartIndex(r.layout.IndexPath(r.dataType, r.instrument, r.fileStart), entry); err != nil {
+				return err
+			}
+		}
+		if r.checksums || r.manifest {
+			size, sum, err := FileChecksum(r.filePath)
+			if err != nil {
+				return err
+			}
+			if r.checksums {
+				if err := WriteChecksumFile(r.filePath, sum); err != nil {
+					return err
+				}
+			}
+			if r.manifest {
+				if err := r.appendManifest(size, sum); err != nil {
+					return err
+				}
+			}
+		}
+		if r.onFinalize != nil {
+			r.onFinalize(r.filePath)
+		}
+		return nil
+	}
+	if err := os.Rename(r.filePath, r.filePath+StandbySuffix); err != nil {
+		return fmt.Errorf("failed to mark %s as standby copy: %w", r.filePath, err)
+	}
+	return nil
+}
+
+// Close writes any queued and buffered records, finalizes the parquet writer, and closes the underlying file.
+func (r *Recorder[T]) Close() error {
+	r.closeQueue()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.flushStop != nil {
+		close(r.flushStop)
+	}
+	introspectRecorder(r, false)
+	return r.finishFile()
 }
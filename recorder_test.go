@@ -1,9 +1,10 @@
-package main
+package gobinapi
 
 import (
 	"github.com/xitongsys/parquet-go-source/local"
 	"github.com/xitongsys/parquet-go/reader"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -17,7 +18,6 @@ func TestNewRecorder_CreatesNewFile(t *testing.T) {
 	type Dummy struct {
 		A int `parquet:"name=a, type=INT32"`
 	}
-	prototype := new(Dummy)
 
 	now := time.Now().UTC()
 	expectedDate := now.Format("2006-01-02")
@@ -28,7 +28,7 @@ func TestNewRecorder_CreatesNewFile(t *testing.T) {
 		os.Remove(expectedFilePath)
 	}
 
-	r, err := NewRecorder(instrument, dataType, prototype, batchSize)
+	r, err := NewRecorder[Dummy](instrument, dataType, batchSize)
 	if err != nil {
 		t.Fatalf("failed to create recorder: %v", err)
 	}
@@ -61,7 +61,6 @@ func TestNewRecorder_FileExistsError(t *testing.T) {
 	type Dummy struct {
 		A int `parquet:"name=a, type=INT32"`
 	}
-	prototype := new(Dummy)
 
 	now := time.Now().UTC()
 	filePath := BuildFileName(dataType, instrument, now)
@@ -75,7 +74,7 @@ func TestNewRecorder_FileExistsError(t *testing.T) {
 	defer os.Remove(filePath)
 
 	// Attempt to create a new recorder, expecting an error due to file existence.
-	r, err := NewRecorder(instrument, dataType, prototype, batchSize)
+	r, err := NewRecorder[Dummy](instrument, dataType, batchSize)
 	if err == nil {
 		r.Close()
 		t.Error("expected error due to existing file, but got nil")
@@ -93,7 +92,6 @@ func TestWriteFlushBatchSize(t *testing.T) {
 	type Dummy struct {
 		A int `parquet:"name=a, type=INT32"`
 	}
-	prototype := new(Dummy)
 
 	now := time.Now().UTC()
 	filePath := BuildFileName(dataType, instrument, now)
@@ -101,7 +99,7 @@ func TestWriteFlushBatchSize(t *testing.T) {
 		os.Remove(filePath)
 	}
 
-	r, err := NewRecorder(instrument, dataType, prototype, batchSize)
+	r, err := NewRecorder[Dummy](instrument, dataType, batchSize)
 	if err != nil {
 		t.Fatalf("failed to create recorder: %v", err)
 	}
@@ -111,7 +109,7 @@ func TestWriteFlushBatchSize(t *testing.T) {
 	}
 
 	// Write first record; buffer should grow to 1
-	err = r.Write(&Dummy{A: 1})
+	err = r.Write(Dummy{A: 1})
 	if err != nil {
 		t.Fatalf("unexpected error on first write: %v", err)
 	}
@@ -120,7 +118,7 @@ func TestWriteFlushBatchSize(t *testing.T) {
 	}
 
 	// Write second record; buffer should grow to 2
-	err = r.Write(&Dummy{A: 2})
+	err = r.Write(Dummy{A: 2})
 	if err != nil {
 		t.Fatalf("unexpected error on second write: %v", err)
 	}
@@ -129,7 +127,7 @@ func TestWriteFlushBatchSize(t *testing.T) {
 	}
 
 	// Write third record; should trigger flush as batchBuffer reaches batchSize (3 records), and then clear the buffer
-	err = r.Write(&Dummy{A: 3})
+	err = r.Write(Dummy{A: 3})
 	if err != nil {
 		t.Fatalf("unexpected error on third write (triggering flush): %v", err)
 	}
@@ -138,7 +136,7 @@ func TestWriteFlushBatchSize(t *testing.T) {
 	}
 
 	// Write fourth record; should be buffered normally since flush hasn't been triggered again
-	err = r.Write(&Dummy{A: 4})
+	err = r.Write(Dummy{A: 4})
 	if err != nil {
 		t.Fatalf("unexpected error on fourth write: %v", err)
 	}
@@ -176,16 +174,15 @@ func TestRecorder_RotateOnNewDay(t *testing.T) {
 	type Dummy struct {
 		A int `parquet:"name=a, type=INT32"`
 	}
-	prototype := new(Dummy)
 
-	r, err := NewRecorder(instrument, dataType, prototype, batchSize)
+	r, err := NewRecorder[Dummy](instrument, dataType, batchSize)
 	if err != nil {
 		t.Fatalf("failed to create recorder: %v", err)
 	}
 	oldFile := r.filePath
 
 	// Write one record
-	if err := r.Write(&Dummy{A: 1}); err != nil {
+	if err := r.Write(Dummy{A: 1}); err != nil {
 		t.Fatalf("failed to write first record: %v", err)
 	}
 	if len(r.batchBuffer) != 1 {
@@ -209,7 +206,7 @@ func TestRecorder_RotateOnNewDay(t *testing.T) {
 	}
 
 	// Write a new record into the new recorder; should be buffered normally
-	if err := r.Write(&Dummy{A: 2}); err != nil {
+	if err := r.Write(Dummy{A: 2}); err != nil {
 		t.Fatalf("failed to write record after rotation: %v", err)
 	}
 	if len(r.batchBuffer) != 1 {
@@ -247,23 +244,22 @@ func TestRecorder_CloseFinalizesFile(t *testing.T) {
 	type Dummy struct {
 		A int `parquet:"name=a, type=INT32"`
 	}
-	prototype := new(Dummy)
 	now := time.Now().UTC()
 	filePath := BuildFileName(dataType, instrument, now)
 	if FileExists(filePath) {
 		os.Remove(filePath)
 	}
 
-	r, err := NewRecorder(instrument, dataType, prototype, batchSize)
+	r, err := NewRecorder[Dummy](instrument, dataType, batchSize)
 	if err != nil {
 		t.Fatalf("failed to create recorder: %v", err)
 	}
 
 	// Write a couple of records which do not reach the batch size so they remain buffered
-	if err := r.Write(&Dummy{A: 100}); err != nil {
+	if err := r.Write(Dummy{A: 100}); err != nil {
 		t.Fatalf("failed to write first record: %v", err)
 	}
-	if err := r.Write(&Dummy{A: 200}); err != nil {
+	if err := r.Write(Dummy{A: 200}); err != nil {
 		t.Fatalf("failed to write second record: %v", err)
 	}
 
@@ -302,3 +298,95 @@ func TestRecorder_CloseFinalizesFile(t *testing.T) {
 	fr.Close()
 	os.Remove(filePath)
 }
+
+func TestNewRecorder_WritesIntoHivePartition(t *testing.T) {
+	oldNowFunc, oldLayout := NowFunc, DefaultFileLayout
+	defer func() { NowFunc, DefaultFileLayout = oldNowFunc, oldLayout }()
+	baseTime := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	NowFunc = func() time.Time { return baseTime }
+	root := filepath.Join(t.TempDir(), "out")
+	DefaultFileLayout = FileLayout{Root: root, Hive: true}
+
+	r, err := NewRecorder[Trade]("BTCUSDT", "trade", 1)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	if expected := filepath.Join(root, "symbol=BTCUSDT", "date=2024-05-01", "trade.parquet"); r.filePath != expected {
+		t.Errorf("expected %s, got %s", expected, r.filePath)
+	}
+	if err := r.Write(Trade{TradeID: 1}); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	nextDay := baseTime.Add(2 * time.Hour)
+	NowFunc = func() time.Time { return nextDay }
+	if err := r.Write(Trade{TradeID: 2}); err != nil {
+		t.Fatalf("failed to write record after midnight: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	stats, err := CheckDirIntegrity(root, time.Time{})
+	if err != nil {
+		t.Fatalf("CheckDirIntegrity returned error: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Symbol != "BTCUSDT" || stats[0].DataType != "trade" || stats[0].Rows != 1 ||
+		stats[1].File != filepath.Join(root, "symbol=BTCUSDT", "date=2024-05-02", "trade.parquet") {
+		t.Errorf("expected one file per date partition, got %+v", stats)
+	}
+}
+
+func TestRecorder_FlushIntervalWritesRowGroups(t *testing.T) {
+	clock := useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	r, err := NewRecorder[Trade]("BTCUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	r.SetFlushInterval(5 * time.Second)
+	waitForWaiters(t, clock, 1)
+	for id := int64(1); id <= 2; id++ {
+		if err := r.Write(Trade{TradeID: id}); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+	size := func() int64 {
+		info, err := os.Stat(r.filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+	if n := size(); n != 4 {
+		t.Fatalf("expected only the parquet header before the first flush, got %d bytes", n)
+	}
+
+	clock.Advance(5 * time.Second)
+	deadline := time.Now().Add(2 * time.Second)
+	for size() == 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the buffered rows to be flushed to disk")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := r.Write(Trade{TradeID: 3}); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	fr, err := local.NewLocalFileReader(r.filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, new(Trade), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	if rows, groups := pr.GetNumRows(), len(pr.Footer.RowGroups); rows != 3 || groups != 2 {
+		t.Errorf("expected 3 rows in 2 row groups, got %d rows in %d", rows, groups)
+	}
+}
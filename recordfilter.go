@@ -0,0 +1,85 @@
+package gobinapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+func init() {
+	DefaultMetrics.Describe("binance_filtered_records_total", "counter", "Records left out by the configured record filters, per symbol and data type.")
+}
+
+// filterableDataTypes are the data types a RecordFilter can be set for.
+var filterableDataTypes = []string{"trade", "aggTrade"}
+
+// RecordFilter selects the trades or aggregate trades worth recording, for targeted datasets on constrained storage:
+// a record is kept only if it passes every condition set. Zero values leave a condition out.
+type RecordFilter struct {
+	// MinQuantity and MinNotional are the smallest quantity, in base units, and price × quantity, in quote units.
+	MinQuantity float64 `json:"min_quantity,omitempty" yaml:"min_quantity"`
+	MinNotional float64 `json:"min_notional,omitempty" yaml:"min_notional"`
+	// MinPrice and MaxPrice bound the price.
+	MinPrice float64 `json:"min_price,omitempty" yaml:"min_price"`
+	MaxPrice float64 `json:"max_price,omitempty" yaml:"max_price"`
+	// Side keeps only trades whose taker bought ("buy") or sold ("sell").
+	Side string `json:"side,omitempty" yaml:"side"`
+}
+
+// Validate checks the settings.
+func (f RecordFilter) Validate() error {
+	if f.MinQuantity < 0 || f.MinNotional < 0 || f.MinPrice < 0 || f.MaxPrice < 0 {
+		return errors.New("thresholds must not be negative")
+	}
+	if f.MaxPrice > 0 && f.MaxPrice < f.MinPrice {
+		return fmt.Errorf("max price %v is below min price %v", f.MaxPrice, f.MinPrice)
+	}
+	if f.Side != "" && f.Side != "buy" && f.Side != "sell" {
+		return fmt.Errorf("unknown side %q, want buy or sell", f.Side)
+	}
+	return nil
+}
+
+// Keep is a pure function reporting whether a trade of price and quantity, whose buyer was the maker if buyerMaker,
+// passes the filter. Trades whose price or quantity does not parse are kept, so they are not lost silently.
+func (f RecordFilter) Keep(price, quantity string, buyerMaker bool) bool {
+	if f.Side == "buy" && buyerMaker || f.Side == "sell" && !buyerMaker {
+		return false
+	}
+	p, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return true
+	}
+	q, err := strconv.ParseFloat(quantity, 64)
+	if err != nil {
+		return true
+	}
+	return q >= f.MinQuantity && p*q >= f.MinNotional && p >= f.MinPrice && (f.MaxPrice == 0 || p <= f.MaxPrice)
+}
+
+// filteredWriter passes on the records keep accepts and counts the others.
+type filteredWriter[T any] struct {
+	next   RecorderWriter[T]
+	keep   func(T) bool
+	labels Labels
+}
+
+func (w filteredWriter[T]) Write(record T) error {
+	if !w.keep(record) {
+		DefaultMetrics.Add("binance_filtered_records_total", w.labels, 1)
+		return nil
+	}
+	return w.next.Write(record)
+}
+
+// FilterTrades returns next with symbol's trades going through filter first.
+func FilterTrades(next RecorderWriter[Trade], filter RecordFilter, symbol string) RecorderWriter[Trade] {
+	return filteredWriter[Trade]{next: next, labels: Labels{"symbol": symbol, "data_type": "trade"},
+		keep: func(t Trade) bool { return filter.Keep(t.Price, t.Quantity, t.IsBuyerMaker) }}
+}
+
+// FilterAggTrades returns next with symbol's aggregate trades going through filter first.
+func FilterAggTrades(next RecorderWriter[AggTrade], filter RecordFilter, symbol string) RecorderWriter[AggTrade] {
+	return filteredWriter[AggTrade]{next: next, labels: Labels{"symbol": symbol, "data_type": "aggTrade"},
+		keep: func(t AggTrade) bool { return filter.Keep(t.Price, t.Quantity, t.IsBuyerMaker) }}
+}
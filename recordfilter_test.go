@@ -0,0 +1,52 @@
+package gobinapi
+
+import (
+	"testing"
+)
+
+func TestRecordFilter_Keep(t *testing.T) {
+	filter := RecordFilter{MinQuantity: 0.5, MinNotional: 100, MinPrice: 50, MaxPrice: 300, Side: "sell"}
+	cases := []struct {
+		price, qty string
+		buyerMaker bool
+		want       bool
+	}{
+		{"200", "1", true, true},    // taker sold 1 at 200
+		{"200", "1", false, false},  // taker bought
+		{"200", "0.4", true, false}, // too small a quantity
+		{"150", "0.6", true, false}, // notional 90
+		{"40", "10", true, false},   // below the price range
+		{"301", "1", true, false},   // above the price range
+		{"bad", "1", true, true},    // unparsable prices are kept
+		{"300", "0.5", true, true},  // bounds are inclusive
+	}
+	for _, c := range cases {
+		if got := filter.Keep(c.price, c.qty, c.buyerMaker); got != c.want {
+			t.Errorf("Keep(%s, %s, %v) = %v, want %v", c.price, c.qty, c.buyerMaker, got, c.want)
+		}
+	}
+	if !(RecordFilter{}).Keep("1", "0.001", false) {
+		t.Error("expected an empty filter to keep every trade")
+	}
+	if err := (RecordFilter{MinPrice: 10, MaxPrice: 5}).Validate(); err == nil {
+		t.Error("expected a max price below the min price to be rejected")
+	}
+}
+
+func TestFilterAggTrades_CountsDroppedRecords(t *testing.T) {
+	labels := Labels{"symbol": "FILTERUSDT", "data_type": "aggTrade"}
+	before := DefaultMetrics.Value("binance_filtered_records_total", labels)
+	rec := &FakeRecorder{}
+	w := FilterAggTrades(rec, RecordFilter{MinQuantity: 1}, "FILTERUSDT")
+	for i, qty := range []string{"0.5", "2", "0.1", "1"} {
+		if err := w.Write(AggTrade{AggTradeID: int64(i), Price: "10", Quantity: qty}); err != nil {
+			t.Fatalf("failed to write aggregate trade: %v", err)
+		}
+	}
+	if got := rec.GetRecords(); len(got) != 2 || got[0].AggTradeID != 1 || got[1].AggTradeID != 3 {
+		t.Errorf("expected aggregate trades 1 and 3 to be kept, got %+v", got)
+	}
+	if n := DefaultMetrics.Value("binance_filtered_records_total", labels) - before; n != 2 {
+		t.Errorf("expected 2 filtered records to be counted, got %v", n)
+	}
+}
@@ -0,0 +1,218 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Replay streams the recordings of one symbol back in event-time order, e.g. to test a strategy or the local order
+// book against recorded history. Each data type is replayed on its channel, and data types without a channel are
+// not read. Records are read a day at a time from the files Query finds.
+//
+// Spot book tickers and snapshots carry no event time. They are timed by their order book update ID instead: a best
+// price is replayed right after the depth diff containing its update, and a snapshot right before the first diff
+// following it, so the symbol's diffs must have been recorded to replay them.
+type Replay struct {
+	Dir    string
+	Symbol string
+	// From and To bound the range, inclusive and exclusive respectively.
+	From, To time.Time
+	// Speed paces the records by their event times: 1 replays in real time, 10 ten times faster. Zero replays as
+	// fast as the channels are read.
+	Speed float64
+
+	Trades     chan<- Trade
+	AggTrades  chan<- AggTrade
+	Diffs      chan<- OrderBookDiff
+	BestPrices chan<- BestPrice
+	Snapshots  chan<- OrderBookSnapshot
+}
+
+// Ranks order the records of the same millisecond: a snapshot before the diffs following it, and a best price after
+// the diff containing its update.
+const (
+	replayRankSnapshot = iota
+	replayRankDiff
+	replayRankBestPrice
+	replayRankTrade
+)
+
+// replayEvent is a record scheduled for replay.
+type replayEvent struct {
+	at   time.Time
+	rank int
+	send func(ctx context.Context) error
+}
+
+// Run replays the records until the range is exhausted or ctx is cancelled, then closes the channels.
+func (r Replay) Run(ctx context.Context) error {
+	defer r.closeChannels()
+	var first, start time.Time
+	for day := r.From.UTC().Truncate(24 * time.Hour); day.Before(r.To); day = day.Add(24 * time.Hour) {
+		events, err := r.load(day)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if r.Speed > 0 {
+				if first.IsZero() {
+					first, start = event.at, DefaultClock.Now()
+				}
+				if d := replayDelay(first, event.at, start, DefaultClock.Now(), r.Speed); d > 0 {
+					if err := sleepContext(ctx, d); err != nil {
+						return err
+					}
+				}
+			}
+			if err := event.send(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replayDelay is a pure function returning how long to wait at now before replaying a record of time at, when the
+// first record, of time first, was replayed at start and records are replayed speed times faster than recorded.
+func replayDelay(first, at, start, now time.Time, speed float64) time.Duration {
+	due := start.Add(time.Duration(float64(at.Sub(first)) / speed))
+	return due.Sub(now)
+}
+
+// load reads the records of the day starting at day that are in range, sorted for replay.
+func (r Replay) load(day time.Time) ([]replayEvent, error) {
+	q := Query{Dir: r.Dir, Symbol: r.Symbol, From: maxTime(r.From, day), To: minTime(r.To, day.Add(24*time.Hour))}
+	var events []replayEvent
+	var diffs []OrderBookDiff
+	if r.Diffs != nil || r.BestPrices != nil || r.Snapshots != nil {
+		var err error
+		if diffs, err = queryRecords[OrderBookDiff](q, "orderBookDiff"); err != nil {
+			return nil, err
+		}
+		if r.Diffs != nil {
+			for _, diff := range diffs {
+				events = append(events, replayEventOf(r.Diffs, diff, time.UnixMilli(diff.EventTime), replayRankDiff))
+			}
+		}
+	}
+	// updateTime returns the event time of the first diff containing or following update ID id
+	updateTime := func(dataType string, id int64) (time.Time, error) {
+		if len(diffs) == 0 {
+			return time.Time{}, fmt.Errorf("%s %s of %s have no event times and there are no depth diffs to time them by",
+				r.Symbol, dataType, day.Format("2006-01-02"))
+		}
+		i := sort.Search(len(diffs), func(i int) bool { return diffs[i].FinalUpdateID >= id })
+		return time.UnixMilli(diffs[min(i, len(diffs)-1)].EventTime), nil
+	}
+
+	if r.Trades != nil {
+		trades, err := queryRecords[Trade](q, "trade")
+		if err != nil {
+			return nil, err
+		}
+		for _, trade := range trades {
+			events = append(events, replayEventOf(r.Trades, trade, time.UnixMilli(trade.EventTime), replayRankTrade))
+		}
+	}
+	if r.AggTrades != nil {
+		aggTrades, err := queryRecords[AggTrade](q, "aggTrade")
+		if err != nil {
+			return nil, err
+		}
+		for _, aggTrade := range aggTrades {
+			events = append(events, replayEventOf(r.AggTrades, aggTrade, time.UnixMilli(aggTrade.EventTime), replayRankTrade))
+		}
+	}
+	if r.BestPrices != nil {
+		bestPrices, err := queryRecords[BestPrice](q, "bestPrice")
+		if err != nil {
+			return nil, err
+		}
+		for _, bestPrice := range bestPrices {
+			at := time.UnixMilli(bestPrice.EventTime)
+			if bestPrice.EventTime == 0 {
+				if at, err = updateTime("bestPrice", bestPrice.UpdateID); err != nil {
+					return nil, err
+				}
+			}
+			events = append(events, replayEventOf(r.BestPrices, bestPrice, at, replayRankBestPrice))
+		}
+	}
+	if r.Snapshots != nil {
+		snapshots, err := queryRecords[OrderBookSnapshot](q, "snapshot")
+		if err != nil {
+			return nil, err
+		}
+		for _, snapshot := range snapshots {
+			at, err := updateTime("snapshot", snapshot.LastUpdateID+1)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, replayEventOf(r.Snapshots, snapshot, at, replayRankSnapshot))
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		return events[i].rank < events[j].rank
+	})
+	return events, nil
+}
+
+// replayEventOf schedules record for replay on ch at time at.
+func replayEventOf[T any](ch chan<- T, record T, at time.Time, rank int) replayEvent {
+	return replayEvent{at: at, rank: rank, send: func(ctx context.Context) error {
+		select {
+		case ch <- record:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}}
+}
+
+// queryRecords runs q for dataType and returns its records as T.
+func queryRecords[T any](q Query, dataType string) ([]T, error) {
+	q.DataType = dataType
+	rows, err := q.Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s %s: %w", q.Symbol, dataType, err)
+	}
+	records := make([]T, len(rows))
+	for i, row := range rows {
+		records[i] = row.(T)
+	}
+	return records, nil
+}
+
+// closeChannels closes the channels of the replayed data types.
+func (r Replay) closeChannels() {
+	closeIfSet(r.Trades)
+	closeIfSet(r.AggTrades)
+	closeIfSet(r.Diffs)
+	closeIfSet(r.BestPrices)
+	closeIfSet(r.Snapshots)
+}
+
+func closeIfSet[T any](ch chan<- T) {
+	if ch != nil {
+		close(ch)
+	}
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
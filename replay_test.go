@@ -0,0 +1,120 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestReplay_MergesDataTypesInEventTimeOrder records a day of trades, diffs, spot best prices and a snapshot and
+// checks that the replay interleaves them by event time, timing the best prices and the snapshot by update ID.
+func TestReplay_MergesDataTypesInEventTimeOrder(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+	ms := func(seconds int) int64 { return day.Add(time.Duration(seconds) * time.Second).UnixMilli() }
+	write := func(dataType string, records any) {
+		t.Helper()
+		path := filepath.Join(dir, BuildFileName(dataType, "BTCUSDT", day))
+		var err error
+		switch r := records.(type) {
+		case []Trade:
+			err = WriteParquetFile(path, r)
+		case []OrderBookDiff:
+			err = WriteParquetFile(path, r)
+		case []BestPrice:
+			err = WriteParquetFile(path, r)
+		case []OrderBookSnapshot:
+			err = WriteParquetFile(path, r)
+		}
+		if err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	write("trade", []Trade{{TradeID: 1, EventTime: ms(1), TradeTime: ms(1)}, {TradeID: 2, EventTime: ms(3), TradeTime: ms(3)}})
+	write("orderBookDiff", []OrderBookDiff{
+		{FirstUpdateID: 11, FinalUpdateID: 20, EventTime: ms(2)},
+		{FirstUpdateID: 21, FinalUpdateID: 30, EventTime: ms(4)},
+	})
+	write("bestPrice", []BestPrice{{UpdateID: 25}, {UpdateID: 15}})
+	write("snapshot", []OrderBookSnapshot{{LastUpdateID: 20}})
+
+	trades, diffs := make(chan Trade), make(chan OrderBookDiff)
+	bestPrices, snapshots := make(chan BestPrice), make(chan OrderBookSnapshot)
+	replay := Replay{Dir: dir, Symbol: "BTCUSDT", From: day, To: day.Add(24 * time.Hour),
+		Trades: trades, Diffs: diffs, BestPrices: bestPrices, Snapshots: snapshots}
+	errCh := make(chan error, 1)
+	go func() { errCh <- replay.Run(context.Background()) }()
+
+	var got []string
+	for trades != nil || diffs != nil || bestPrices != nil || snapshots != nil {
+		select {
+		case v, ok := <-trades:
+			if !ok {
+				trades = nil
+				continue
+			}
+			got = append(got, fmt.Sprintf("trade %d", v.TradeID))
+		case v, ok := <-diffs:
+			if !ok {
+				diffs = nil
+				continue
+			}
+			got = append(got, fmt.Sprintf("diff %d", v.FinalUpdateID))
+		case v, ok := <-bestPrices:
+			if !ok {
+				bestPrices = nil
+				continue
+			}
+			got = append(got, fmt.Sprintf("bestPrice %d", v.UpdateID))
+		case v, ok := <-snapshots:
+			if !ok {
+				snapshots = nil
+				continue
+			}
+			got = append(got, fmt.Sprintf("snapshot %d", v.LastUpdateID))
+		}
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	want := []string{"trade 1", "diff 20", "bestPrice 15", "trade 2", "snapshot 20", "diff 30", "bestPrice 25"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected replay order %v, got %v", want, got)
+	}
+}
+
+func TestReplay_UntimedBestPricesNeedDiffs(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+	if err := WriteParquetFile(filepath.Join(dir, BuildFileName("bestPrice", "BTCUSDT", day)), []BestPrice{{UpdateID: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	bestPrices := make(chan BestPrice, 1)
+	err := Replay{Dir: dir, Symbol: "BTCUSDT", From: day, To: day.Add(24 * time.Hour), BestPrices: bestPrices}.Run(context.Background())
+	if err == nil {
+		t.Error("expected best prices without event times or diffs to be rejected")
+	}
+}
+
+func TestReplayDelay(t *testing.T) {
+	first := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		at    time.Duration
+		now   time.Duration
+		speed float64
+		want  time.Duration
+	}{
+		{10 * time.Second, 0, 1, 10 * time.Second},
+		{10 * time.Second, 0, 10, time.Second},
+		{10 * time.Second, 4 * time.Second, 2, time.Second},
+		{10 * time.Second, 20 * time.Second, 1, -10 * time.Second},
+	} {
+		if got := replayDelay(first, first.Add(tc.at), start, start.Add(tc.now), tc.speed); got != tc.want {
+			t.Errorf("record at +%s, now +%s, speed %v: expected %s, got %s", tc.at, tc.now, tc.speed, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Address families accepted by DialAddressFamily and Config.AddressFamily.
+const (
+	AddressFamilyAny  = ""
+	AddressFamilyIPv4 = "ipv4"
+	AddressFamilyIPv6 = "ipv6"
+)
+
+// DialAddressFamily restricts WebSocket dials to IPv4 or IPv6 addresses. AddressFamilyAny tries every address the
+// host resolves to.
+var DialAddressFamily = AddressFamilyAny
+
+// LookupIPAddr resolves WebSocket hosts. Tests replace it to serve fixed addresses.
+var LookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// ValidateAddressFamily checks that family is one of the AddressFamily constants.
+func ValidateAddressFamily(family string) error {
+	switch family {
+	case AddressFamilyAny, AddressFamilyIPv4, AddressFamilyIPv6:
+		return nil
+	}
+	return fmt.Errorf("unknown address family %q, want %q or %q", family, AddressFamilyIPv4, AddressFamilyIPv6)
+}
+
+// failedAddrs remembers, per host, the addresses whose last dial failed, so the next resolution tries them last.
+// An edge node that stops accepting connections keeps being handed out by DNS for a while; without this every
+// reconnect would start with it again.
+var failedAddrs = struct {
+	mu    sync.Mutex
+	hosts map[string]map[string]bool
+}{hosts: make(map[string]map[string]bool)}
+
+func markAddr(host, addr string, failed bool) {
+	failedAddrs.mu.Lock()
+	defer failedAddrs.mu.Unlock()
+	if failed {
+		if failedAddrs.hosts[host] == nil {
+			failedAddrs.hosts[host] = make(map[string]bool)
+		}
+		failedAddrs.hosts[host][addr] = true
+	} else {
+		delete(failedAddrs.hosts[host], addr)
+	}
+}
+
+// orderAddrs filters addrs to family and moves addresses that failed on their last dial to the back, keeping the
+// resolver's order otherwise.
+func orderAddrs(host string, addrs []net.IPAddr, family string) []string {
+	failedAddrs.mu.Lock()
+	defer failedAddrs.mu.Unlock()
+	var good, bad []string
+	for _, a := range addrs {
+		is4 := a.IP.To4() != nil
+		if (family == AddressFamilyIPv4 && !is4) || (family == AddressFamilyIPv6 && is4) {
+			continue
+		}
+		if failedAddrs.hosts[host][a.String()] {
+			bad = append(bad, a.String())
+		} else {
+			good = append(good, a.String())
+		}
+	}
+	return append(good, bad...)
+}
+
+// resolvingDialContext resolves the host afresh on every dial rather than reusing a cached address, then tries
+// each address in turn and logs the one it connected to.
+func resolvingDialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IPAddr
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IPAddr{{IP: ip}}
+	} else if ips, err = LookupIPAddr(ctx, host); err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	candidates := orderAddrs(host, ips, DialAddressFamily)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no %s address for %s", DialAddressFamily, host)
+	}
+
+	var d net.Dialer
+	var lastErr error
+	for _, ip := range candidates {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err != nil {
+			markAddr(host, ip, true)
+			lastErr = err
+			continue
+		}
+		markAddr(host, ip, false)
+		if ip != host {
+			log.Printf("Resolved %s to %s", host, ip)
+		}
+		return conn, nil
+	}
+	return nil, lastErr
+}
+
+// streamDialer dials market data WebSockets through resolvingDialContext, via the proxy DialProxy picks if any.
+var streamDialer = &websocket.Dialer{
+	NetDialContext:   resolvingDialContext,
+	Proxy:            func(req *http.Request) (*url.URL, error) { return DialProxy(req) },
+	HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+}
@@ -0,0 +1,78 @@
+package gobinapi
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestOrderAddrs_FiltersFamilyAndDemotesFailed(t *testing.T) {
+	addrs := []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("10.0.0.2")}}
+	markAddr("order.test", "10.0.0.1", true)
+	t.Cleanup(func() { markAddr("order.test", "10.0.0.1", false) })
+
+	for family, want := range map[string]string{
+		AddressFamilyAny:  "2001:db8::1,10.0.0.2,10.0.0.1",
+		AddressFamilyIPv4: "10.0.0.2,10.0.0.1",
+		AddressFamilyIPv6: "2001:db8::1",
+	} {
+		if got := strings.Join(orderAddrs("order.test", addrs, family), ","); got != want {
+			t.Errorf("family %q: expected %s, got %s", family, want, got)
+		}
+	}
+	if err := ValidateAddressFamily("ipx"); err == nil {
+		t.Errorf("expected an unknown address family to be rejected")
+	}
+}
+
+func TestListenTrade_ReresolvesAndSkipsDeadAddress(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 7, "100.5", "0.25"))
+	u, err := url.Parse(StreamBaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	StreamBaseURL = "ws://edge.test:" + u.Port()
+
+	// The mock server listens on 127.0.0.1 only, so 127.0.0.2 refuses connections like a dead edge node
+	var lookups int
+	oldLookup := LookupIPAddr
+	LookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		lookups++
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+	t.Cleanup(func() {
+		LookupIPAddr = oldLookup
+		markAddr("edge.test", "127.0.0.2", false)
+	})
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		tradeChan := make(chan Trade, 1)
+		done := make(chan struct{})
+		go func() { defer close(done); ListenTrade(ctx, "BTCUSDT", tradeChan) }()
+		select {
+		case <-tradeChan:
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for a trade through the live address")
+		}
+		cancel()
+		<-done
+	}
+	if lookups != 2 {
+		t.Errorf("expected the host to be resolved on every connect, got %d lookups", lookups)
+	}
+	if got := orderAddrs("edge.test", []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}, {IP: net.ParseIP("127.0.0.1")}}, AddressFamilyAny); got[0] != "127.0.0.1" {
+		t.Errorf("expected the dead address to be tried last, got %v", got)
+	}
+	for _, s := range WebSocketStats() {
+		if s.Stream == "btcusdt@trade" && !strings.HasPrefix(s.Address, "127.0.0.1:") {
+			t.Errorf("expected the connected address to be recorded, got %q", s.Address)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func init() {
+	DefaultMetrics.Describe("binance_retention_files_total", "counter", "Local files removed by the retention policy, per action (delete, archive or dry_run).")
+	DefaultMetrics.Describe("binance_retention_bytes_total", "counter", "Bytes of local files removed by the retention policy, per action (delete, archive or dry_run).")
+	DefaultMetrics.Describe("binance_retention_kept_total", "counter", "Expired local files kept by the retention policy because they are not uploaded yet.")
+}
+
+// RetentionConfig configures removing old recordings from the output directory, so the recorder does not fill the
+// disk over months.
+type RetentionConfig struct {
+	// MaxAgeDays is how many days a file is kept after it was last written.
+	MaxAgeDays int `json:"max_age_days" yaml:"max_age_days"`
+	// ArchiveDir, if set, receives expired files (under their path below the output directory) instead of them
+	// being deleted, e.g. a directory on a larger, slower volume. It must be on the same file system.
+	ArchiveDir string `json:"archive_dir,omitempty" yaml:"archive_dir"`
+	// UploadedOnly keeps expired recordings and checksums until they have been uploaded (see UploadQueue.Uploaded).
+	UploadedOnly bool `json:"uploaded_only" yaml:"uploaded_only"`
+	// DryRun only logs and counts the files that would be removed.
+	DryRun bool `json:"dry_run" yaml:"dry_run"`
+	// Interval is how often the output directory is checked (default 1h).
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval"`
+}
+
+// Validate checks the retention settings.
+func (c RetentionConfig) Validate() error {
+	if c.MaxAgeDays <= 0 {
+		return errors.New("max_age_days must be positive")
+	}
+	if c.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	return nil
+}
+
+// RetentionManager removes the recordings under a directory that have expired according to a RetentionConfig:
+// parquet files, their checksum sidecars, part indexes and manifests. Other files, such as session files or the
+// upload queue, are left alone.
+type RetentionManager struct {
+	root    string
+	config  RetentionConfig
+	uploads *UploadQueue
+	logger  LoggerInterface
+}
+
+// NewRetentionManager creates a retention manager for the recordings under root. uploads is the queue files are
+// uploaded through; it is required with RetentionConfig.UploadedOnly.
+func NewRetentionManager(root string, config RetentionConfig, uploads *UploadQueue, logger LoggerInterface) (*RetentionManager, error) {
+	if config.UploadedOnly && uploads == nil {
+		return nil, errors.New("retention of uploaded files only requires uploads to be configured")
+	}
+	if root == "" {
+		root = "."
+	}
+	if config.Interval == 0 {
+		config.Interval = time.Hour
+	}
+	return &RetentionManager{root: root, config: config, uploads: uploads, logger: logger}, nil
+}
+
+// Run applies the retention policy every interval until ctx is cancelled.
+func (m *RetentionManager) Run(ctx context.Context) error {
+	for {
+		if _, err := m.Apply(NowFunc()); err != nil {
+			m.logger.Errorf("Failed to apply the retention policy to %s: %v", m.root, err)
+		}
+		if err := sleepContext(ctx, m.config.Interval); err != nil {
+			return nil
+		}
+	}
+}
+
+// Apply removes the recordings last written more than MaxAgeDays before now, or only lists them in dry-run mode, and
+// returns their paths. Partition directories left empty are removed as well.
+func (m *RetentionManager) Apply(now time.Time) ([]string, error) {
+	cutoff := now.Add(-time.Duration(m.config.MaxAgeDays) * 24 * time.Hour)
+	action := "delete"
+	switch {
+	case m.config.DryRun:
+		action = "dry_run"
+	case m.config.ArchiveDir != "":
+		action = "archive"
+	}
+	var removed, dirs []string
+	err := filepath.WalkDir(m.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if m.config.ArchiveDir != "" && filepath.Clean(path) == filepath.Clean(m.config.ArchiveDir) {
+				return filepath.SkipDir
+			}
+			if path != m.root {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		uploaded, ok := retainedKind(d.Name())
+		if !ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if uploaded && m.config.UploadedOnly && !m.uploads.Uploaded(path) {
+			DefaultMetrics.Add("binance_retention_kept_total", nil, 1)
+			return nil
+		}
+		if err := m.remove(path, action); err != nil {
+			return err
+		}
+		removed = append(removed, path)
+		DefaultMetrics.Add("binance_retention_files_total", Labels{"action": action}, 1)
+		DefaultMetrics.Add("binance_retention_bytes_total", Labels{"action": action}, float64(info.Size()))
+		return nil
+	})
+	if len(removed) > 0 && !m.config.DryRun {
+		if m.uploads != nil {
+			if err := m.uploads.Forget(removed...); err != nil {
+				m.logger.Errorf("%v", err)
+			}
+		}
+		// Deepest first, so a symbol's directory goes once its date directories have
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+				os.Remove(dirs[i])
+			}
+		}
+	}
+	if err != nil {
+		return removed, fmt.Errorf("failed to apply retention under %s: %w", m.root, err)
+	}
+	return removed, nil
+}
+
+// remove deletes or archives the file at path, or logs that it would in dry-run mode.
+func (m *RetentionManager) remove(path, action string) error {
+	switch action {
+	case "dry_run":
+		m.logger.Infof("Retention would remove %s", path)
+		return nil
+	case "archive":
+		rel, err := filepath.Rel(m.root, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(m.config.ArchiveDir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create archive directory for %s: %w", target, err)
+		}
+		if err := os.Rename(path, target); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", path, err)
+		}
+		m.logger.Infof("Archived %s to %s", path, target)
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", path, err)
+	}
+	m.logger.Infof("Deleted %s", path)
+	return nil
+}
+
+// retainedKind reports whether a file named name is a recording the retention policy applies to, and whether it is
+// one that is uploaded, i.e. a parquet file or its checksum sidecar.
+func retainedKind(name string) (uploaded bool, ok bool) {
+	switch {
+	case strings.HasSuffix(name, ".parquet"), strings.HasSuffix(name, ".parquet"+ChecksumSuffix):
+		return true, true
+	case strings.HasSuffix(name, ".index.json"), strings.HasPrefix(name, "manifest_") && strings.HasSuffix(name, ".json"):
+		return false, true
+	}
+	return false, false
+}
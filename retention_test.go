@@ -0,0 +1,118 @@
+package gobinapi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAgedFile creates the file at path, with its parent directories, last modified at modTime.
+func writeAgedFile(t *testing.T, path string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetentionManager_RemovesExpiredRecordings(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	old, recent := now.AddDate(0, 0, -31), now.AddDate(0, 0, -2)
+	oldDay := filepath.Join("symbol=BTCUSDT", "date=2025-02-28")
+	files := map[string]time.Time{
+		filepath.Join(oldDay, "trade.parquet"):         old,
+		filepath.Join(oldDay, "trade.parquet.sha256"):  old,
+		filepath.Join(oldDay, "trade.index.json"):      old,
+		"manifest_2025-02-28.json":                     old,
+		"session_2025-02-28T00-00-00Z_run.json":        old,
+		"symbol=BTCUSDT/date=2025-03-29/trade.parquet": recent,
+	}
+
+	for _, tc := range []struct {
+		name    string
+		config  RetentionConfig
+		removed bool
+	}{
+		{"delete", RetentionConfig{MaxAgeDays: 30}, true},
+		{"archive", RetentionConfig{MaxAgeDays: 30, ArchiveDir: "archive"}, true},
+		{"dry run", RetentionConfig{MaxAgeDays: 30, DryRun: true}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, modTime := range files {
+				writeAgedFile(t, filepath.Join(root, name), modTime)
+			}
+			config := tc.config
+			if config.ArchiveDir != "" {
+				config.ArchiveDir = filepath.Join(root, config.ArchiveDir)
+			}
+			m, err := NewRetentionManager(root, config, nil, &FakeLogger{})
+			if err != nil {
+				t.Fatalf("failed to create retention manager: %v", err)
+			}
+			removed, err := m.Apply(now)
+			if err != nil {
+				t.Fatalf("Apply returned error: %v", err)
+			}
+			if len(removed) != 4 {
+				t.Errorf("expected the 4 expired recording files, got %v", removed)
+			}
+			for name, modTime := range files {
+				// Session files are not recordings, so they are kept however old
+				expired := modTime.Equal(old) && !strings.HasPrefix(name, "session_")
+				if got := FileExists(filepath.Join(root, name)); got == (expired && tc.removed) {
+					t.Errorf("%s: expected exists=%v, got %v", name, !(expired && tc.removed), got)
+				}
+				if config.ArchiveDir != "" && expired && !FileExists(filepath.Join(config.ArchiveDir, name)) {
+					t.Errorf("expected %s to be archived", name)
+				}
+			}
+			if tc.removed && FileExists(filepath.Join(root, oldDay)) {
+				t.Errorf("expected the empty partition directory %s to be removed", oldDay)
+			}
+		})
+	}
+}
+
+func TestRetentionManager_UploadedOnlyKeepsPendingFiles(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	root := t.TempDir()
+	uploadedFile := filepath.Join(root, "BTCUSDT_trade_2025-02-27.parquet")
+	pendingFile := filepath.Join(root, "BTCUSDT_trade_2025-02-28.parquet")
+	writeAgedFile(t, uploadedFile, now.AddDate(0, 0, -32))
+	writeAgedFile(t, pendingFile, now.AddDate(0, 0, -31))
+
+	u := &flakyUploader{}
+	q, err := NewUploadQueue(filepath.Join(root, "uploads.json"), u, &FakeLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue(uploadedFile)
+	q.ProcessDue(context.Background(), now)
+	if _, err := NewRetentionManager(root, RetentionConfig{MaxAgeDays: 30, UploadedOnly: true}, nil, &FakeLogger{}); err == nil {
+		t.Error("expected uploaded_only without an upload queue to be rejected")
+	}
+	m, err := NewRetentionManager(root, RetentionConfig{MaxAgeDays: 30, UploadedOnly: true}, q, &FakeLogger{})
+	if err != nil {
+		t.Fatalf("failed to create retention manager: %v", err)
+	}
+	removed, err := m.Apply(now)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if !slices.Equal(removed, []string{uploadedFile}) || !FileExists(pendingFile) {
+		t.Errorf("expected only the uploaded file to be removed, got %v", removed)
+	}
+	if q.Uploaded(uploadedFile) {
+		t.Error("expected the removed file to be forgotten by the upload queue")
+	}
+}
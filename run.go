@@ -0,0 +1,2007 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config holds everything Run needs to set up the recording pipelines. The zero value is not usable; start from
+// DefaultConfig and override the fields you need.
+type Config struct {
+	// Market selects the exchange recorded: MarketSpot (default) or MarketUSDM for USD-M futures, which connects to
+	// FuturesStreamBaseURL and FuturesRESTBaseURL unless StreamEndpoints, RESTEndpoints or Testnet say otherwise
+	// (see Config.Endpoints).
+	Market string `json:"market"`
+	// Instruments lists the symbols to record, e.g. "BTCUSDT".
+	Instruments []string `json:"instruments"`
+	// Discover, if set, adds the symbols of the exchange info it selects, e.g. every USDT pair or the 50 with the
+	// highest volume, to Instruments at startup (see DiscoverConfig). Instruments may then be empty.
+	Discover *DiscoverConfig `json:"discover,omitempty"`
+	// Streams selects the streams recorded per instrument (see AllStreams and FuturesStreams). Instruments without
+	// an entry record every stream of the market.
+	Streams map[string][]string `json:"streams,omitempty"`
+	// OutputDir is the directory recordings are written under; empty means the working directory.
+	OutputDir string `json:"output_dir,omitempty"`
+	// HivePartitioning writes recordings into symbol=<SYMBOL>/date=<YYYY-MM-DD> directories under OutputDir (see
+	// FileLayout) instead of flat file names.
+	HivePartitioning bool `json:"hive_partitioning"`
+	// BatchSize is the number of records each Recorder buffers before flushing to the parquet writer.
+	BatchSize int `json:"batch_size"`
+	// FlushInterval bounds how long a recorded row may stay in memory: each recorder writes what it holds to disk as
+	// a row group at least this often (see Recorder.SetFlushInterval). Zero flushes only when a row group is full.
+	FlushInterval time.Duration `json:"flush_interval"`
+	// WriteQueue is how many records each Recorder queues for a writer goroutine of its own (see
+	// Recorder.SetWriteQueue), so a slow disk holds up the stream's consumer only once the queue is full. Zero writes
+	// on the consumer's goroutine.
+	WriteQueue int `json:"write_queue"`
+	// RotateEvery splits each day's files into parts covering this long a period from UTC midnight, e.g. an hour
+	// (see Recorder.SetRotationInterval). It must divide a day evenly; zero keeps one file per day.
+	RotateEvery time.Duration `json:"rotate_every"`
+	// MaxFileSize continues a file in the next part once it reaches about this many bytes (see
+	// Recorder.SetMaxFileSize). Zero disables size-based splitting.
+	MaxFileSize int64 `json:"max_file_size"`
+	// OnExistingFile is what a recorder does when the file it would start already exists, e.g. after a restart in
+	// the middle of the day: ExistingFileFail (the default) refuses to record it, ExistingFileNewPart continues in
+	// the next unused part file.
+	OnExistingFile string `json:"on_existing_file,omitempty"`
+	// Manifest lists every finished recording file with its row count, event time range, size and checksum in a
+	// manifest per day under OutputDir (see FileLayout.ManifestPath), so loaders can find and validate the day's
+	// data without scanning directories.
+	Manifest bool `json:"manifest"`
+	// Checksums writes a SHA-256 sidecar next to every finished recording file (see WriteChecksumFile), which is
+	// uploaded along with it.
+	Checksums bool `json:"checksums"`
+	// VerifyFiles re-opens every finished recording file and checks that it holds the rows written to it, raising an
+	// AlertCorruptFile alert if it does not (see Recorder.SetVerify).
+	VerifyFiles bool `json:"verify_files"`
+	// DailySummary writes a summary file per instrument and UTC day (data type "summary", see DailySummary) with the
+	// message counts, event time range, reconnects and gaps of each recorded data type and the prices and volume of
+	// its trades, once the day is over and for the day in progress on shutdown.
+	DailySummary bool `json:"daily_summary"`
+	// Parquet sets the compression codec, row group size and page size of recorded files. ParquetByType overrides
+	// it per data type (any ReadRecordingFile accepts, e.g. "trade" or "orderBookDiff"); fields left unset there
+	// fall back to Parquet.
+	Parquet       ParquetOptions            `json:"parquet"`
+	ParquetByType map[string]ParquetOptions `json:"parquet_by_type,omitempty"`
+	// SnapshotInterval is how often a deep REST order book snapshot is fetched per instrument. Deep snapshots are
+	// recorded as "snapshot" and also resynchronise the order book diff stream after sequence gaps.
+	SnapshotInterval time.Duration `json:"snapshot_interval"`
+	// SnapshotLimit is the number of levels per side requested in each deep snapshot (up to 5000, or one of 5, 10,
+	// 20, 50, 100, 500 and 1000 for futures). Deeper snapshots cost more request weight, see DepthWeight.
+	SnapshotLimit int `json:"snapshot_limit"`
+	// SnapshotStagger spreads the deep and top-of-book snapshots of the instruments over their intervals instead
+	// of fetching every instrument's at once (see SnapshotScheduler.SetStagger). At most RESTWorkers are in flight.
+	SnapshotStagger bool `json:"snapshot_stagger"`
+	// SnapshotGapsOnly fetches deep snapshots only when an instrument starts and when its order book diff stream
+	// has a sequence gap, never every SnapshotInterval, which saves most of their request weight. The "snapshot"
+	// data type then holds just those snapshots. Top-of-book snapshots keep their own interval.
+	SnapshotGapsOnly bool `json:"snapshot_gaps_only"`
+	// TopOfBookInterval is how often a compact snapshot of the TopOfBookLevels best levels is fetched per
+	// instrument and recorded as "snapshotTop". Zero disables top-of-book snapshots.
+	TopOfBookInterval time.Duration `json:"top_of_book_interval"`
+	TopOfBookLevels   int           `json:"top_of_book_levels"`
+	// BookTopInterval is how often the BookTopLevels best levels of the local order book, maintained from the
+	// depth stream, are recorded per instrument as "bookTop". These cost no REST weight. Zero disables them.
+	BookTopInterval time.Duration `json:"book_top_interval"`
+	BookTopLevels   int           `json:"book_top_levels"`
+	// BookFeatureInterval is how often depth and imbalance features of the local order book are recorded per
+	// instrument recording its depth, as "bookFeatures" (see BookFeatures). Zero disables them.
+	BookFeatureInterval time.Duration `json:"book_feature_interval"`
+	// RESTWeightLimit is the per-minute REST request weight the recorder allows itself. Snapshots are paced to fit.
+	// Futures are capped at FuturesRESTWeightLimit.
+	RESTWeightLimit int `json:"rest_weight_limit"`
+	// RESTWorkers bounds how many REST requests run concurrently, however many instruments are recorded.
+	RESTWorkers int `json:"rest_workers"`
+	// ExchangeInfo fetches the exchange info at startup, refuses to record symbols that are not listed or not
+	// TRADING, and records each instrument's tick size, step size and filters once a day as "exchangeInfo" (see
+	// SymbolInfo). If the exchange info cannot be fetched at startup, recording starts without the check.
+	ExchangeInfo bool `json:"exchange_info"`
+	// BackfillGaps fetches the trades and aggregate trades a reconnect missed from the REST API and records them
+	// before the first one received on the new connection (see GapFiller), within the same weight budget.
+	BackfillGaps bool `json:"backfill_gaps"`
+	// ChannelBuffers sets the in-memory buffer size per stream type.
+	ChannelBuffers ChannelBufferSizes `json:"channel_buffers"`
+	// OverflowPolicies sets, per data type, what happens to messages arriving while the buffer is full; data types
+	// not listed spill to disk (see OverflowPolicy).
+	OverflowPolicies map[string]OverflowPolicy `json:"overflow_policies,omitempty"`
+	// SpillDir is where overflow spill files are written when recorders fall behind the WebSocket feeds.
+	SpillDir string `json:"spill_dir"`
+	// DropJournalInterval is how often the count and ID range of discarded messages are journaled per stream.
+	DropJournalInterval time.Duration `json:"drop_journal_interval"`
+
+	// HAMode selects hot-standby behaviour: HAModeNone (default), HAModeActive or HAModeStandby.
+	HAMode string `json:"ha_mode"`
+	// HeartbeatFile is the heartbeat file written by the active instance and watched by the standby. It should live
+	// on storage visible to both instances.
+	HeartbeatFile string `json:"heartbeat_file"`
+	// HeartbeatInterval is how often the active writes, and the standby checks, the heartbeat.
+	HeartbeatInterval time.Duration `json:"heartbeat_interval"`
+	// HeartbeatTimeout is how old the heartbeat may get before the standby considers the active dead.
+	HeartbeatTimeout time.Duration `json:"heartbeat_timeout"`
+
+	// Influx, if set, additionally exports best prices (with mid and spread) to InfluxDB for live dashboards.
+	Influx *InfluxConfig `json:"influx,omitempty"`
+
+	// Timescale, if set, additionally copies trades and best prices into PostgreSQL/TimescaleDB.
+	Timescale *TimescaleConfig `json:"timescale,omitempty"`
+
+	// ClickHouse, if set, additionally inserts trades and best prices into ClickHouse.
+	ClickHouse *ClickHouseConfig `json:"clickhouse,omitempty"`
+
+	// Kafka, if set, additionally publishes trades, aggregate trades, order book diffs and best prices to Kafka,
+	// one topic per data type keyed by symbol.
+	Kafka *KafkaConfig `json:"kafka,omitempty"`
+
+	// Sinks selects, by data type (trade, aggTrade, orderBookDiff or bestPrice), the sinks its records are written to:
+	// "parquet" for the files, the shared sinks configured above by name (influx, timescale, clickhouse, kafka) and
+	// any sink registered in DefaultSinks. Data types not listed go to the files and every configured shared sink.
+	Sinks map[string][]string `json:"sinks,omitempty"`
+
+	// Upload, if set, uploads every finished file (after rotation or at shutdown) to remote storage such as S3,
+	// retrying failures. Files finished while Run shuts down stay queued and are uploaded by the next run.
+	Upload *UploadConfig `json:"upload,omitempty"`
+	// Retention, if set, deletes or archives recordings under OutputDir once they are old enough, optionally only
+	// after they have been uploaded (see RetentionManager).
+	Retention *RetentionConfig `json:"retention,omitempty"`
+	// DiskGuard, if set, stops recording some data types, e.g. full depth, while the output directory's file system
+	// is low on space, so trades and best prices keep being recorded (see DiskGuard).
+	DiskGuard *DiskGuardConfig `json:"disk_guard,omitempty"`
+
+	// StrictValidation rejects malformed or anomalous messages at ingest (see ValidateMessage) and appends them to
+	// QuarantineFile instead of recording them.
+	StrictValidation bool `json:"strict_validation"`
+	// QuarantineFile is the JSON-lines file rejected messages are appended to in strict mode.
+	QuarantineFile string `json:"quarantine_file"`
+	// MaxClockSkew is how far a message's event time may be from local time in strict mode.
+	MaxClockSkew time.Duration `json:"max_clock_skew"`
+
+	// EnrichBestPrice stores mid price, spread and spread in basis points with every best price record.
+	EnrichBestPrice bool `json:"enrich_best_price"`
+	// BestPriceChangeOnly records a best price only when the bid or ask price or size changed, plus a keyframe at
+	// least every BestPriceKeyframe. Live sinks still receive every update.
+	BestPriceChangeOnly bool          `json:"best_price_change_only"`
+	BestPriceKeyframe   time.Duration `json:"best_price_keyframe"`
+	// BestPriceMaxPerSecond records at most that many best prices a second per instrument, dropping the rest. Zero
+	// records every one. Live sinks still receive every update.
+	BestPriceMaxPerSecond int `json:"best_price_max_per_second,omitempty"`
+	// BestPriceConflation overrides BestPriceChangeOnly and BestPriceMaxPerSecond per instrument, e.g. to sample a
+	// busy pair while recording every tick of the others.
+	BestPriceConflation map[string]BestPriceConflation `json:"best_price_conflation,omitempty"`
+	// AllBookTickers receives the book tickers of every instrument over one connection to the all-market
+	// !bookTicker stream, handing each symbol's updates to its pipeline, instead of one bookTicker stream per
+	// instrument. Updates of symbols not being recorded are dropped. Book tickers cannot be switched off at runtime
+	// then.
+	AllBookTickers bool `json:"all_book_tickers,omitempty"`
+	// TickerArrays lists all-market 24 hour ticker arrays, "ticker" and/or "miniTicker", to record over one
+	// connection each, with the rows of every symbol of the market in one file per day (see Ticker24h and
+	// MarketSymbol).
+	TickerArrays []string `json:"ticker_arrays,omitempty"`
+	// FuturesStats, if set, polls the open interest, open interest history and top trader long/short ratios of every
+	// USD-M futures instrument and records them per symbol (see FuturesStatsPoller).
+	FuturesStats *FuturesStatsConfig `json:"futures_stats,omitempty"`
+	// ReferenceKlines lists intervals (e.g. "1m", at most "1d", see BarIntervals) at which the mark price, index price
+	// and premium index klines of every USD-M futures instrument are downloaded once closed and recorded as
+	// ReferenceKlineDataType (see RunReferenceKlines).
+	ReferenceKlines []string `json:"reference_klines,omitempty"`
+	// Options, if set, records Binance Options trades, tickers with greeks, mark prices and open interest from
+	// OptionsStreamBaseURL, whatever Market is (see OptionsConfig).
+	Options *OptionsConfig `json:"options,omitempty"`
+	// UserData, if set, records the spot account's order updates, balances and balance updates from its user data
+	// stream as "executionReport", "outboundAccountPosition" and "balanceUpdate" under AccountSymbol (see
+	// UserDataStream). It needs an API key in BINANCE_API_KEY.
+	UserData *UserDataConfig `json:"user_data,omitempty"`
+	// RecordFilters selects, per data type ("trade" or "aggTrade"), the records worth recording (see RecordFilter);
+	// the others are dropped before any sink, bar or gap filler sees them.
+	RecordFilters map[string]RecordFilter `json:"record_filters,omitempty"`
+	// Bars lists intervals (e.g. "1s", "1m" or "5m", see BarIntervals) of OHLCV bars built from the trades of every
+	// instrument recording them and recorded as "bar_<interval>" (see BarBuilder).
+	Bars []string `json:"bars,omitempty"`
+	// MidPrice, if set, derives a mid price, spread and microprice series from the best prices of every instrument
+	// recording them and records it as "midPrice" (see MidPriceSampler).
+	MidPrice *MidPriceConfig `json:"mid_price,omitempty"`
+	// ArchiveRaw additionally records every WebSocket frame of an instrument untouched, with its stream name and
+	// receive time, as "raw" (see RawMessage), so recordings can be rebuilt after a parsing bug is fixed.
+	ArchiveRaw bool `json:"archive_raw"`
+	// DebugStreams selects, per instrument, WebSocket streams whose frames are written to the log as received, for
+	// troubleshooting parse issues (see FrameDebugger). The admin API switches them at runtime.
+	DebugStreams map[string][]string `json:"debug_streams,omitempty"`
+	// Alerts, if set, posts an alert to a webhook on sequence gaps, repeated reconnects, recorder write failures
+	// and low disk space, rate limited so an outage sends a few alerts rather than a storm.
+	Alerts *AlertConfig `json:"alerts,omitempty"`
+
+	// StreamEndpoints lists the WebSocket base URLs to connect to, in order of preference (see
+	// DefaultStreamEndpoints). Streams fail over to the next one after FailoverAfter consecutive failed sessions.
+	// If empty, only the market's StreamBaseURL or FuturesStreamBaseURL is used.
+	StreamEndpoints []string `json:"stream_endpoints,omitempty"`
+	FailoverAfter   int      `json:"failover_after"`
+	// RESTEndpoints replaces the market's REST base URL, e.g. with "https://api1.binance.com" or a regional mirror,
+	// for snapshots, exchange info, backfills and every other request. With several, requests fail over to the next
+	// one after FailoverAfter consecutive failed (transport errors and 5xx responses) or, with MaxEndpointLatency,
+	// slow requests.
+	RESTEndpoints []string `json:"rest_endpoints,omitempty"`
+	// EndpointProbeInterval is how often every stream and REST endpoint is probed (see EndpointProber); an endpoint
+	// failing FailoverAfter probes in a row, or exceeding MaxEndpointLatency, is left and skipped until it recovers.
+	// Streams move over on their next reconnect. Zero disables probing.
+	EndpointProbeInterval time.Duration `json:"endpoint_probe_interval,omitempty"`
+	// MaxEndpointLatency is the slowest health probe or REST request an endpoint may take before counting as
+	// failed. Zero sets no limit.
+	MaxEndpointLatency time.Duration `json:"max_endpoint_latency,omitempty"`
+	// Proxies lists http:// or socks5:// proxy URLs, with credentials if needed, to connect through. They take
+	// turns: each WebSocket connection and REST request uses the next one. If empty, the HTTPS_PROXY, HTTP_PROXY
+	// and NO_PROXY environment variables apply.
+	Proxies []string `json:"proxies,omitempty"`
+	// Testnet connects to the market's testnet (see SpotTestnetStreamBaseURL) instead of production, unless
+	// StreamEndpoints or RESTEndpoints are set. Binance Options have no testnet and are recorded from production.
+	Testnet bool `json:"testnet,omitempty"`
+	// AddressFamily restricts WebSocket connections to "ipv4" or "ipv6" addresses; empty tries both. Hosts are
+	// re-resolved on every reconnect.
+	AddressFamily string `json:"address_family,omitempty"`
+
+	// StreamIdleTimeout is how long a WebSocket connection may receive nothing, not even a ping, before it is
+	// treated as half-dead and reconnected (see StreamIdleTimeout). Zero disables the watchdog.
+	StreamIdleTimeout time.Duration `json:"stream_idle_timeout"`
+	// PingInterval is how often every WebSocket connection is pinged (see PingInterval); zero disables client pings.
+	PingInterval time.Duration `json:"ping_interval"`
+	// ConnectionLifetime is how long a WebSocket connection is used before a planned, overlapping reconnect replaces
+	// it (see ConnectionLifetime). It must stay below the exchange's 24 hour limit; zero disables planned reconnects.
+	ConnectionLifetime time.Duration `json:"connection_lifetime"`
+	// MultiplexStreams carries the streams of every instrument over one connection per endpoint, subscribed with
+	// SUBSCRIBE requests through a StreamManager, instead of one connection per stream. At most
+	// MaxStreamsPerConnection streams can be recorded this way.
+	MultiplexStreams bool `json:"multiplex_streams,omitempty"`
+
+	// ShutdownTimeout bounds how long Run waits, once stopped, for every pipeline to drain and close its recorders.
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+
+	// MetricsAddr, if set, is the address (e.g. ":9090") of an HTTP server exposing DefaultMetrics on /metrics.
+	MetricsAddr string `json:"metrics_addr,omitempty"`
+	// AdminAddr, if set, is the address of an HTTP server through which instruments and streams are added,
+	// removed and switched on or off, snapshots requested and files rotated while recording (see adminAPI). It is
+	// unauthenticated, so bind it to a private address such as "127.0.0.1:9091".
+	AdminAddr string `json:"admin_addr,omitempty"`
+	// DebugAddr, if set, is the address of an HTTP server exposing only the expvar state (see Introspect) on
+	// /debug/vars, for introspection without a metrics stack. The metrics server serves /debug/vars as well.
+	DebugAddr string `json:"debug_addr,omitempty"`
+	// HealthAddr, if set, is the address of an HTTP server answering /healthz with the health of every WebSocket
+	// stream, for liveness and readiness probes. A stream silent for HealthDegradedAfter is degraded, and one silent
+	// for HealthUnhealthyAfter is unhealthy, which fails the probe. Illiquid symbols may need longer thresholds.
+	HealthAddr           string        `json:"health_addr,omitempty"`
+	HealthDegradedAfter  time.Duration `json:"health_degraded_after"`
+	HealthUnhealthyAfter time.Duration `json:"health_unhealthy_after"`
+
+	// Logger receives operational messages. If nil, Run opens the default journal file via NewFileLogger.
+	Logger *Logger `json:"-"`
+	// HTTPClient is used for REST API calls. If nil, a client with a 10 second timeout is used, going through
+	// Proxies if set.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// DefaultConfig returns the configuration used by the command line recorder.
+func DefaultConfig() Config {
+	return Config{
+		Market:              MarketSpot,
+		Instruments:         []string{"BTCUSDT"},
+		BatchSize:           1,
+		WriteQueue:          1024,
+		Parquet:             ParquetOptions{Compression: CompressionSnappy, RowGroupSize: DefaultRowGroupSize, PageSize: DefaultPageSize},
+		SnapshotInterval:    1 * time.Minute,
+		SnapshotLimit:       100,
+		SnapshotStagger:     true,
+		TopOfBookInterval:   10 * time.Second,
+		TopOfBookLevels:     20,
+		BookTopLevels:       20,
+		RESTWeightLimit:     DefaultRESTWeightLimit,
+		RESTWorkers:         4,
+		ExchangeInfo:        true,
+		ChannelBuffers:      DefaultChannelBufferSizes(),
+		SpillDir:            filepath.Join(os.TempDir(), "gobinapi_spill"),
+		DropJournalInterval: time.Minute,
+		HAMode:              HAModeNone,
+		HeartbeatInterval:   5 * time.Second,
+		HeartbeatTimeout:    30 * time.Second,
+		QuarantineFile:      "quarantine.jsonl",
+		MaxClockSkew:        time.Minute,
+		BestPriceKeyframe:   time.Minute,
+		FailoverAfter:       3,
+		StreamIdleTimeout:   time.Minute,
+		PingInterval:        30 * time.Second,
+		ConnectionLifetime:  23 * time.Hour,
+		ShutdownTimeout:     30 * time.Second,
+
+		HealthDegradedAfter:  time.Minute,
+		HealthUnhealthyAfter: 5 * time.Minute,
+	}
+}
+
+// Validate checks that the configuration is usable, returning a descriptive error otherwise.
+func (cfg Config) Validate() error {
+	if err := ValidateMarket(cfg.Market); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if len(cfg.Instruments) == 0 && cfg.Discover == nil {
+		return errors.New("config: at least one instrument is required")
+	}
+	if cfg.Discover != nil {
+		if err := cfg.Discover.Validate(cfg.Market); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	if err := cfg.validateStreams(); err != nil {
+		return err
+	}
+	if cfg.BatchSize <= 0 {
+		return fmt.Errorf("config: batch size must be positive, got %d", cfg.BatchSize)
+	}
+	if cfg.WriteQueue < 0 {
+		return fmt.Errorf("config: write queue must not be negative, got %d", cfg.WriteQueue)
+	}
+	if cfg.FlushInterval < 0 {
+		return fmt.Errorf("config: flush interval must not be negative, got %s", cfg.FlushInterval)
+	}
+	if cfg.RotateEvery < 0 || (cfg.RotateEvery > 0 && (24*time.Hour)%cfg.RotateEvery != 0) {
+		return fmt.Errorf("config: rotation interval must divide a day evenly, got %s", cfg.RotateEvery)
+	}
+	if cfg.MaxFileSize < 0 {
+		return fmt.Errorf("config: max file size must not be negative, got %d", cfg.MaxFileSize)
+	}
+	if cfg.OnExistingFile != "" && cfg.OnExistingFile != ExistingFileFail && cfg.OnExistingFile != ExistingFileNewPart {
+		return fmt.Errorf("config: unknown existing file policy %q, expected %q or %q", cfg.OnExistingFile, ExistingFileFail, ExistingFileNewPart)
+	}
+	if cfg.Influx != nil {
+		if err := cfg.Influx.Validate(); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	if cfg.ClickHouse != nil {
+		if err := cfg.ClickHouse.Validate(); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	if cfg.Alerts != nil {
+		if err := cfg.Alerts.Validate(); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	if cfg.Kafka != nil {
+		if err := cfg.Kafka.Validate(); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	if err := cfg.validateSinks(); err != nil {
+		return err
+	}
+	if cfg.Upload != nil {
+		if err := cfg.Upload.Validate(); err != nil {
+			return fmt.Errorf("config: upload: %w", err)
+		}
+	}
+	if cfg.DiskGuard != nil {
+		if err := cfg.DiskGuard.Validate(); err != nil {
+			return fmt.Errorf("config: disk guard: %w", err)
+		}
+	}
+	if cfg.Retention != nil {
+		if err := cfg.Retention.Validate(); err != nil {
+			return fmt.Errorf("config: retention: %w", err)
+		}
+		if cfg.Retention.UploadedOnly && cfg.Upload == nil {
+			return errors.New("config: retention: uploaded_only requires upload to be configured")
+		}
+	}
+	if err := cfg.Parquet.Validate(); err != nil {
+		return fmt.Errorf("config: parquet: %w", err)
+	}
+	for dataType, options := range cfg.ParquetByType {
+		if !isRecordingDataType(dataType) {
+			return fmt.Errorf("config: parquet options for unknown data type %q", dataType)
+		}
+		if err := options.Validate(); err != nil {
+			return fmt.Errorf("config: parquet options for %s: %w", dataType, err)
+		}
+	}
+	if cfg.SnapshotInterval <= 0 {
+		return fmt.Errorf("config: snapshot interval must be positive, got %s", cfg.SnapshotInterval)
+	}
+	if err := validateDepthLimit(cfg.Market, cfg.SnapshotLimit); err != nil {
+		return fmt.Errorf("config: snapshot limit %w", err)
+	}
+	if cfg.TopOfBookInterval < 0 {
+		return fmt.Errorf("config: top-of-book interval must not be negative, got %s", cfg.TopOfBookInterval)
+	}
+	if cfg.TopOfBookInterval > 0 {
+		if err := validateDepthLimit(cfg.Market, cfg.TopOfBookLevels); err != nil {
+			return fmt.Errorf("config: top-of-book levels %w", err)
+		}
+	}
+	if cfg.BookTopInterval < 0 {
+		return fmt.Errorf("config: book top interval must not be negative, got %s", cfg.BookTopInterval)
+	}
+	if cfg.BookTopInterval > 0 && (cfg.BookTopLevels <= 0 || cfg.BookTopLevels > cfg.SnapshotLimit) {
+		return fmt.Errorf("config: book top levels must be between 1 and the snapshot limit (%d), got %d", cfg.SnapshotLimit, cfg.BookTopLevels)
+	}
+	if cfg.BookFeatureInterval < 0 {
+		return fmt.Errorf("config: book feature interval must not be negative, got %s", cfg.BookFeatureInterval)
+	}
+	if cfg.RESTWeightLimit <= 0 {
+		return fmt.Errorf("config: REST weight limit must be positive, got %d", cfg.RESTWeightLimit)
+	}
+	if cfg.RESTWorkers <= 0 {
+		return fmt.Errorf("config: REST workers must be positive, got %d", cfg.RESTWorkers)
+	}
+	if err := cfg.ChannelBuffers.Validate(); err != nil {
+		return err
+	}
+	if err := ValidateOverflowPolicies(cfg.OverflowPolicies); err != nil {
+		return err
+	}
+	if cfg.SpillDir == "" {
+		return errors.New("config: spill directory is required")
+	}
+	if cfg.DropJournalInterval <= 0 {
+		return fmt.Errorf("config: drop journal interval must be positive, got %s", cfg.DropJournalInterval)
+	}
+	switch cfg.HAMode {
+	case HAModeNone:
+	case HAModeActive, HAModeStandby:
+		if cfg.HeartbeatFile == "" {
+			return fmt.Errorf("config: heartbeat file is required in %s mode", cfg.HAMode)
+		}
+		if cfg.HeartbeatInterval <= 0 || cfg.HeartbeatTimeout <= cfg.HeartbeatInterval {
+			return fmt.Errorf("config: heartbeat timeout (%s) must exceed a positive heartbeat interval (%s)", cfg.HeartbeatTimeout, cfg.HeartbeatInterval)
+		}
+	default:
+		return fmt.Errorf("config: unknown HA mode %q", cfg.HAMode)
+	}
+	if cfg.FailoverAfter <= 0 {
+		return fmt.Errorf("config: failover threshold must be positive, got %d", cfg.FailoverAfter)
+	}
+	for _, endpoint := range cfg.StreamEndpoints {
+		if err := validateEndpoint(endpoint, "wss", "ws"); err != nil {
+			return fmt.Errorf("config: stream %w", err)
+		}
+	}
+	for _, endpoint := range cfg.RESTEndpoints {
+		if err := validateEndpoint(endpoint, "https", "http"); err != nil {
+			return fmt.Errorf("config: REST %w", err)
+		}
+	}
+	for _, proxy := range cfg.Proxies {
+		if _, err := ParseProxyURL(proxy); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	if cfg.EndpointProbeInterval < 0 || cfg.MaxEndpointLatency < 0 {
+		return fmt.Errorf("config: endpoint probe interval (%s) and max latency (%s) must not be negative", cfg.EndpointProbeInterval, cfg.MaxEndpointLatency)
+	}
+	if cfg.StreamIdleTimeout < 0 {
+		return fmt.Errorf("config: stream idle timeout must not be negative, got %s", cfg.StreamIdleTimeout)
+	}
+	if cfg.PingInterval < 0 {
+		return fmt.Errorf("config: ping interval must not be negative, got %s", cfg.PingInterval)
+	}
+	if cfg.ConnectionLifetime < 0 || cfg.ConnectionLifetime >= 24*time.Hour {
+		return fmt.Errorf("config: connection lifetime must be below the exchange's 24h limit, got %s", cfg.ConnectionLifetime)
+	}
+	if cfg.MultiplexStreams {
+		if n := cfg.websocketStreams(); n > MaxStreamsPerConnection {
+			return fmt.Errorf("config: multiplexing %d streams exceeds the %d allowed on one connection", n, MaxStreamsPerConnection)
+		}
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		return fmt.Errorf("config: shutdown timeout must be positive, got %s", cfg.ShutdownTimeout)
+	}
+	if cfg.HealthAddr != "" && (cfg.HealthDegradedAfter <= 0 || cfg.HealthUnhealthyAfter < cfg.HealthDegradedAfter) {
+		return fmt.Errorf("config: health thresholds must be positive with degraded (%s) before unhealthy (%s)", cfg.HealthDegradedAfter, cfg.HealthUnhealthyAfter)
+	}
+	if err := ValidateAddressFamily(cfg.AddressFamily); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if cfg.BestPriceMaxPerSecond < 0 {
+		return fmt.Errorf("config: best price max per second must not be negative, got %d", cfg.BestPriceMaxPerSecond)
+	}
+	changeOnly := cfg.BestPriceChangeOnly
+	for instrument, c := range cfg.BestPriceConflation {
+		if !slices.Contains(cfg.Instruments, instrument) {
+			return fmt.Errorf("config: best price conflation set for %s, which is not a configured instrument", instrument)
+		}
+		if c.MaxPerSecond < 0 {
+			return fmt.Errorf("config: best price max per second of %s must not be negative, got %d", instrument, c.MaxPerSecond)
+		}
+		changeOnly = changeOnly || c.ChangeOnly
+	}
+	if changeOnly && cfg.BestPriceKeyframe <= 0 {
+		return fmt.Errorf("config: best price keyframe must be positive in change-only mode, got %s", cfg.BestPriceKeyframe)
+	}
+	for i, dataType := range cfg.TickerArrays {
+		if dataType != TickerDataType && dataType != MiniTickerDataType {
+			return fmt.Errorf("config: unknown ticker array %q, want %s or %s", dataType, TickerDataType, MiniTickerDataType)
+		}
+		if slices.Contains(cfg.TickerArrays[:i], dataType) {
+			return fmt.Errorf("config: ticker array %q listed twice", dataType)
+		}
+	}
+	if cfg.FuturesStats != nil {
+		if cfg.Market != MarketUSDM {
+			return errors.New("config: futures stats need the USD-M futures market")
+		}
+		if err := cfg.FuturesStats.Validate(); err != nil {
+			return fmt.Errorf("config: futures stats: %w", err)
+		}
+	}
+	if len(cfg.ReferenceKlines) > 0 && cfg.Market != MarketUSDM {
+		return errors.New("config: reference klines need the USD-M futures market")
+	}
+	for i, interval := range cfg.ReferenceKlines {
+		if _, ok := BarIntervals[interval]; !ok || interval == "1s" {
+			return fmt.Errorf("config: unknown reference kline interval %q", interval)
+		}
+		if slices.Contains(cfg.ReferenceKlines[:i], interval) {
+			return fmt.Errorf("config: reference kline interval %q listed twice", interval)
+		}
+	}
+	if cfg.Options != nil {
+		if err := cfg.Options.Validate(); err != nil {
+			return fmt.Errorf("config: options: %w", err)
+		}
+	}
+	if cfg.UserData != nil {
+		if cfg.Market != MarketSpot {
+			return errors.New("config: the user data stream needs the spot market")
+		}
+		if err := cfg.UserData.Validate(); err != nil {
+			return fmt.Errorf("config: user data: %w", err)
+		}
+	}
+	for dataType, filter := range cfg.RecordFilters {
+		if !slices.Contains(filterableDataTypes, dataType) {
+			return fmt.Errorf("config: cannot filter %q records, only %v", dataType, filterableDataTypes)
+		}
+		if err := filter.Validate(); err != nil {
+			return fmt.Errorf("config: %s filter: %w", dataType, err)
+		}
+	}
+	for i, interval := range cfg.Bars {
+		if _, ok := BarIntervals[interval]; !ok {
+			return fmt.Errorf("config: unknown bar interval %q", interval)
+		}
+		if slices.Contains(cfg.Bars[:i], interval) {
+			return fmt.Errorf("config: bar interval %q listed twice", interval)
+		}
+	}
+	if cfg.MidPrice != nil {
+		if err := cfg.MidPrice.Validate(); err != nil {
+			return fmt.Errorf("config: mid price: %w", err)
+		}
+	}
+	for instrument, streams := range cfg.DebugStreams {
+		for _, s := range streams {
+			if !isStream(cfg.Market, s) || listenerKey(s) == "" {
+				return fmt.Errorf("config: cannot debug the %q stream of %s, only WebSocket streams have frames", s, instrument)
+			}
+		}
+	}
+	if cfg.StrictValidation {
+		if cfg.QuarantineFile == "" {
+			return errors.New("config: quarantine file is required in strict validation mode")
+		}
+		if cfg.MaxClockSkew <= 0 {
+			return fmt.Errorf("config: max clock skew must be positive in strict validation mode, got %s", cfg.MaxClockSkew)
+		}
+	}
+	return nil
+}
+
+// Run performs everything the command line recorder does: it writes the session metadata file, connects to the
+// Binance WebSocket streams and REST snapshot endpoint for every configured instrument and records the data to
+// parquet files. It blocks until ctx is cancelled or a pipeline fails, so programs embedding the recorder control
+// its lifecycle through ctx. Run returns nil when stopped through ctx, and the first pipeline error otherwise.
+func Run(ctx context.Context, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := LintRecordedTypes(); err != nil {
+		return err
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		var err error
+		logger, err = NewFileLogger()
+		if err != nil {
+			return fmt.Errorf("failed to initialize logger: %w", err)
+		}
+	}
+	// WebSocket dials and, unless HTTPClient is set, REST requests rotate through the configured proxies
+	DialProxy = http.ProxyFromEnvironment
+	client := cfg.HTTPClient
+	if len(cfg.Proxies) > 0 {
+		proxies, err := NewProxyRotation(cfg.Proxies)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		DialProxy = proxies.Proxy
+		if client == nil {
+			client = proxiedClient(proxies.Proxy, 10*time.Second)
+		}
+	}
+	if client == nil {
+		client = &http.Client{
+			Timeout: 10 * time.Second,
+		}
+	}
+
+	// Every REST request shares one weight budget
+	weightLimit := cfg.RESTWeightLimit
+	if cfg.Market == MarketUSDM && weightLimit > FuturesRESTWeightLimit {
+		logger.Infof("Capping the REST weight limit at %d for USD-M futures", FuturesRESTWeightLimit)
+		weightLimit = FuturesRESTWeightLimit
+	}
+	DefaultWeightTracker.SetLimit(weightLimit)
+
+	// Every REST request of the run goes to the configured bases: URLs are built from the first, and the client
+	// sends them to whichever is current
+	streamBases, restBases := cfg.Endpoints()
+	if cfg.Market == MarketUSDM {
+		FuturesRESTBaseURL = restBases[0]
+	} else {
+		RESTBaseURL = restBases[0]
+	}
+	restEndpoints := NewStreamEndpoints(restBases, cfg.FailoverAfter)
+	var streamProber, restProber *EndpointProber
+	if cfg.EndpointProbeInterval > 0 {
+		streamProber = NewEndpointProber(ProbeStreamEndpoint, cfg.MaxEndpointLatency, cfg.FailoverAfter)
+		restProber = NewEndpointProber(RESTProbe(client, cfg.Market), cfg.MaxEndpointLatency, cfg.FailoverAfter)
+		restEndpoints.SetProber(restProber)
+	}
+	if len(restBases) > 1 {
+		client = withRESTFailover(client, restBases[0], restEndpoints, cfg.MaxEndpointLatency, logger)
+	}
+	var userData *UserDataStream
+	if cfg.UserData != nil {
+		var err error
+		if userData, err = NewUserDataStream(*cfg.UserData, client, logger); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+
+	// Check the instruments against the exchange info, which is recorded for every day, and add those it discovers
+	configured, discovered := cfg, []string(nil)
+	var infos []SymbolInfo
+	if cfg.ExchangeInfo || cfg.Discover != nil {
+		var err error
+		infos, err = FetchExchangeInfo(ctx, client, cfg.Market)
+		switch {
+		case err != nil && cfg.Discover != nil:
+			return fmt.Errorf("failed to discover instruments: %w", err)
+		case err != nil:
+			logger.Errorf("Failed to fetch the exchange info, recording without checking the instruments: %v", err)
+		default:
+			if cfg.ExchangeInfo {
+				if err := ValidateSymbols(infos, cfg.Instruments); err != nil {
+					return fmt.Errorf("config: %w", err)
+				}
+			}
+			if cfg.Discover != nil {
+				if discovered, err = discoverInstruments(ctx, client, cfg.Market, *cfg.Discover, infos); err != nil {
+					return fmt.Errorf("failed to discover instruments: %w", err)
+				}
+				cfg = cfg.withDiscovered(discovered)
+				if err := cfg.Validate(); err != nil {
+					return fmt.Errorf("discovered instruments: %w", err)
+				}
+				if len(cfg.Instruments) == 0 {
+					return errors.New("config: no instrument configured or discovered")
+				}
+				logger.Infof("Discovered %d instruments: %s", len(discovered), strings.Join(discovered, ", "))
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// fail records the first pipeline error and stops every pipeline
+	var failOnce sync.Once
+	var runErr error
+	fail := func(err error) {
+		failOnce.Do(func() {
+			logger.Errorf("%v", err)
+			runErr = err
+			cancel()
+		})
+	}
+
+	// Every goroutine Run starts belongs to a group that shutdown waits for. tasks record and stop with ctx;
+	// services such as the sinks outlive the pipelines and are only stopped once these have drained, so the rows
+	// drained after ctx is cancelled still reach them.
+	tasks := newTaskGroup(ctx, fail)
+	services := newTaskGroup(context.Background(), fail)
+	defer services.Stop()
+
+	// Write the session metadata file so recorded data can be traced back to this run and its configuration
+	session, err := NewSessionInfo(cfg.Instruments, cfg, NowFunc())
+	if err != nil {
+		return fmt.Errorf("failed to create session info: %w", err)
+	}
+	if cfg.OutputDir != "" {
+		if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+	sessionFile := filepath.Join(cfg.OutputDir, BuildSessionFileName(session.StartTime, session.RunID))
+	if err := WriteSessionFile(sessionFile, session); err != nil {
+		logger.Errorf("Failed to write session file %s: %v", sessionFile, err)
+	}
+	logger.Infof("Started recording session %s (config hash %s)", session.RunID, session.ConfigHash)
+	// The connections made from here on are the run's, listed in the session file at shutdown
+	firstConnection := connectionsLogged()
+
+	if streamProber != nil {
+		tasks.Go("stream endpoint prober", func(ctx context.Context) error {
+			streamProber.Run(ctx, streamBases, cfg.EndpointProbeInterval)
+			return nil
+		})
+		tasks.Go("REST endpoint prober", func(ctx context.Context) error {
+			restProber.Run(ctx, restBases, cfg.EndpointProbeInterval)
+			return nil
+		})
+	}
+
+	// In hot-standby mode the active publishes a heartbeat, and the standby only finalizes files when it is missing
+	var standby *StandbyMonitor
+	switch cfg.HAMode {
+	case HAModeActive:
+		tasks.Go("heartbeat writer", func(ctx context.Context) error {
+			return RunHeartbeatWriter(ctx, cfg.HeartbeatFile, session.RunID, cfg.HeartbeatInterval, logger)
+		})
+	case HAModeStandby:
+		standby = NewStandbyMonitor(cfg.HeartbeatFile, cfg.HeartbeatTimeout, logger)
+		services.Go("standby monitor", func(ctx context.Context) error {
+			return standby.Run(ctx, cfg.HeartbeatInterval)
+		})
+	}
+
+	// In strict mode every listener validates messages before decoding them and quarantines rejects
+	if cfg.StrictValidation {
+		validator, err := NewValidator(cfg.QuarantineFile, cfg.MaxClockSkew)
+		if err != nil {
+			return err
+		}
+		StrictValidator.Store(validator)
+		defer func() {
+			StrictValidator.Store(nil)
+			validator.Close()
+		}()
+		logger.Infof("Strict validation enabled; rejected messages go to %s", cfg.QuarantineFile)
+	}
+
+	// Frames of the selected streams are logged as received
+	DefaultFrameDebugger.SetLogger(logger)
+	DefaultFrameDebugger.Reset()
+	defer DefaultFrameDebugger.Reset()
+	for instrument, streams := range cfg.DebugStreams {
+		for _, s := range streams {
+			DefaultFrameDebugger.Set(strings.ToLower(instrument)+"@"+listenerKey(s), true)
+		}
+	}
+
+	// Optional alerts, raised by the pipelines through DefaultAlerts
+	if cfg.Alerts != nil {
+		alerts := cfg.Alerts.withDefaults()
+		client := &http.Client{Timeout: 10 * time.Second}
+		var alerters []Alerter
+		if alerts.WebhookURL != "" {
+			alerters = append(alerters, NewWebhookAlerter(alerts.WebhookURL, client))
+		}
+		if alerts.Telegram != nil {
+			telegram, err := NewTelegramAlerter(*alerts.Telegram, client)
+			if err != nil {
+				return err
+			}
+			alerters = append(alerters, telegram)
+		}
+		DefaultAlerts.Configure(alerts, alerters...)
+		defer DefaultAlerts.Configure(AlertConfig{})
+		services.Go("alerts", func(ctx context.Context) error {
+			DefaultAlerts.Run(ctx, logger)
+			return nil
+		})
+		if alerts.MinFreeDisk > 0 {
+			services.Go("disk monitor", func(ctx context.Context) error {
+				RunDiskMonitor(ctx, cfg.OutputDir, alerts.MinFreeDisk, alerts.DiskCheckInterval, DefaultAlerts, logger)
+				return nil
+			})
+		}
+	}
+
+	// Journal discarded messages so consumers can see what was lost and when
+	services.Go("drop journal", func(ctx context.Context) error {
+		RunDropJournal(ctx, cfg.DropJournalInterval, logger)
+		return nil
+	})
+
+	// Optional Prometheus-style metrics endpoint
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", DefaultMetrics.Handler())
+		mux.Handle("/debug/vars", expvar.Handler())
+		serveHTTP(services, cfg.MetricsAddr, mux, logger)
+		logger.Infof("Serving metrics on %s/metrics", cfg.MetricsAddr)
+	}
+
+	// Optional standalone introspection endpoint
+	if cfg.DebugAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		serveHTTP(services, cfg.DebugAddr, mux, logger)
+		logger.Infof("Serving introspection on %s/debug/vars", cfg.DebugAddr)
+	}
+
+	// The shared sinks configured below join the sinks a program registered in DefaultSinks, to be selected per
+	// data type by cfg.Sinks
+	sinks := DefaultSinks.clone()
+
+	// Optional InfluxDB export shared by all instruments
+	if cfg.Influx != nil {
+		influx, err := NewInfluxSink(*cfg.Influx, client, logger)
+		if err != nil {
+			return err
+		}
+		services.Go("influx", influx.Run)
+		registerSharedSink(sinks, "influx", influx)
+	}
+
+	// Optional PostgreSQL/TimescaleDB export shared by all instruments
+	if cfg.Timescale != nil {
+		timescale, err := NewTimescaleSink(ctx, *cfg.Timescale, logger)
+		if err != nil {
+			return err
+		}
+		services.Go("timescale", timescale.Run)
+		registerSharedSink(sinks, "timescale", timescale)
+	}
+
+	// Optional ClickHouse export shared by all instruments
+	if cfg.ClickHouse != nil {
+		clickhouse, err := NewClickHouseSink(ctx, *cfg.ClickHouse, logger)
+		if err != nil {
+			return err
+		}
+		services.Go("clickhouse", clickhouse.Run)
+		registerSharedSink(sinks, "clickhouse", clickhouse)
+	}
+
+	// Optional Kafka publishing shared by all instruments
+	if cfg.Kafka != nil {
+		kafka, err := NewKafkaSink(*cfg.Kafka, logger)
+		if err != nil {
+			return err
+		}
+		services.Go("kafka", kafka.Run)
+		registerSharedSink(sinks, "kafka", kafka)
+	}
+
+	// Optional upload of finished files, retried from a persistent queue
+	var uploads *UploadQueue
+	if cfg.Upload != nil {
+		uploader, err := cfg.Upload.NewUploader(cfg.OutputDir, client)
+		if err != nil {
+			return fmt.Errorf("failed to create uploader: %w", err)
+		}
+		// The output directory, if any, was created above; without one the state file is in the working directory
+		statePath := cfg.Upload.StateFile
+		if statePath == "" {
+			statePath = filepath.Join(cfg.OutputDir, "uploads.json")
+		}
+		uploads, err = NewUploadQueue(statePath, uploader, logger)
+		if err != nil {
+			return err
+		}
+		uploads.DeleteUploaded = cfg.Upload.DeleteUploaded
+		services.Go("uploads", uploads.Run)
+	}
+
+	// Optional removal of old recordings, so months of recording do not fill the disk
+	if cfg.Retention != nil {
+		retention, err := NewRetentionManager(cfg.OutputDir, *cfg.Retention, uploads, logger)
+		if err != nil {
+			return fmt.Errorf("failed to create retention manager: %w", err)
+		}
+		services.Go("retention", retention.Run)
+	}
+
+	// Optional degraded mode while the disk is low on space
+	var diskGuard *DiskGuard
+	if cfg.DiskGuard != nil {
+		diskGuard = NewDiskGuard(cfg.OutputDir, *cfg.DiskGuard, logger)
+		services.Go("disk guard", diskGuard.Run)
+	}
+
+	// One scheduler paces the snapshots of all instruments to fit the REST weight budget, serving snapshots
+	// requested after sequence gaps first
+	snapshotInterval := cfg.SnapshotInterval
+	if cfg.SnapshotGapsOnly {
+		snapshotInterval = 0
+	}
+	snapshots := NewSnapshotScheduler(snapshotInterval, cfg.SnapshotLimit, DefaultWeightTracker, logger)
+	restPool := NewWorkerPool("rest", cfg.RESTWorkers, cfg.RESTWorkers)
+	snapshots.SetWorkerPool(restPool)
+	snapshots.SetMarket(cfg.Market)
+	snapshots.SetStagger(cfg.SnapshotStagger)
+	var topSnapshots *SnapshotScheduler
+	if cfg.TopOfBookInterval > 0 {
+		topSnapshots = NewSnapshotScheduler(cfg.TopOfBookInterval, cfg.TopOfBookLevels, DefaultWeightTracker, logger)
+		topSnapshots.SetWorkerPool(restPool)
+		topSnapshots.SetMarket(cfg.Market)
+		topSnapshots.SetStagger(cfg.SnapshotStagger)
+	}
+
+	DialAddressFamily = cfg.AddressFamily
+	StreamIdleTimeout = cfg.StreamIdleTimeout
+	PingInterval, ConnectionLifetime = cfg.PingInterval, cfg.ConnectionLifetime
+	DefaultFileLayout = FileLayout{Root: cfg.OutputDir, Hive: cfg.HivePartitioning}
+	DefaultExistingFilePolicy = ExistingFileFail
+	if cfg.OnExistingFile != "" {
+		DefaultExistingFilePolicy = cfg.OnExistingFile
+	}
+	DefaultParquetOptions, ParquetOptionsByType = cfg.Parquet, cfg.ParquetByType
+	if cfg.ExchangeInfo && infos != nil {
+		if _, err := RecordExchangeInfo(DefaultFileLayout, infos, cfg.Instruments); err != nil {
+			logger.Errorf("Failed to record the exchange info: %v", err)
+		}
+	}
+
+	// For each instrument, set up pipelines for its selected streams
+	env := &pipelineEnv{
+		logger:       logger,
+		fail:         fail,
+		standby:      standby,
+		sinks:        sinks,
+		uploads:      uploads,
+		snapshots:    snapshots,
+		topSnapshots: topSnapshots,
+		streamBases:  streamBases,
+		streamProber: streamProber,
+		pipelines:    make(map[string]*instrumentPipeline),
+		metadata:     session.FileMetadata(),
+		diskGuard:    diskGuard,
+	}
+	if cfg.DailySummary {
+		env.summary = NewSummaryCollector(logger)
+		if uploads != nil {
+			env.summary.SetFinalizeHook(func(filePath string) {
+				if err := uploads.Enqueue(filePath); err != nil {
+					logger.Errorf("Failed to queue %s for upload: %v", filePath, err)
+				}
+			})
+		}
+	}
+	if cfg.BackfillGaps {
+		env.backfiller = NewBackfiller(client, cfg.Market, DefaultWeightTracker, logger)
+	}
+	if cfg.AllBookTickers {
+		env.bookTickers = newStreamRouter()
+	}
+	if cfg.MultiplexStreams {
+		env.router = newStreamRouter()
+		env.manager = NewStreamManager(nil, env.router.handle)
+	}
+	env.mu.Lock()
+	for _, instrument := range cfg.Instruments {
+		if err := startInstrument(ctx, cfg, instrument, env); err != nil {
+			logger.Errorf("%v", err)
+		}
+	}
+	env.mu.Unlock()
+	if cfg.ExchangeInfo {
+		tasks.Go("exchange info recorder", func(ctx context.Context) error {
+			RunExchangeInfoRecorder(ctx, client, cfg.Market, DefaultFileLayout, env.instruments, time.Minute, logger)
+			return nil
+		})
+	}
+	if cfg.Discover != nil && cfg.Discover.Refresh {
+		tasks.Go("discovery refresh", func(ctx context.Context) error {
+			refreshDiscovered(ctx, configured, env, client, discovered, time.Minute)
+			return nil
+		})
+	}
+	// Optional admin API for changing what is recorded without a restart
+	if cfg.AdminAddr != "" {
+		admin := &adminAPI{ctx: ctx, cfg: cfg, env: env}
+		serveHTTP(tasks, cfg.AdminAddr, admin.handler(), logger)
+		logger.Infof("Serving the admin API on %s", cfg.AdminAddr)
+	}
+	// Optional health endpoint for liveness and readiness probes
+	if cfg.HealthAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /healthz", newHealthChecker(env, cfg.HealthDegradedAfter, cfg.HealthUnhealthyAfter))
+		serveHTTP(services, cfg.HealthAddr, mux, logger)
+		logger.Infof("Serving health on %s/healthz", cfg.HealthAddr)
+	}
+	if env.manager != nil {
+		// The multiplexed connection reconnects and fails over like any single stream. Once it has stopped, no
+		// message is routed any more and every instrument's queues are closed.
+		endpoints := env.newStreamEndpoints(cfg.FailoverAfter)
+		listen := func(ctx context.Context) error {
+			return ListenWithFailover(ctx, "ws", endpoints, env.manager.Listen, logger)
+		}
+		tasks.Go("multiplexed listener", func(ctx context.Context) error {
+			defer env.router.closeAll()
+			return Supervise(ctx, "multiplexed listener", DefaultSupervisorPolicy, listen, logger)
+		})
+	}
+
+	if env.bookTickers != nil {
+		endpoints := env.newStreamEndpoints(cfg.FailoverAfter)
+		listen := func(ctx context.Context) error {
+			return ListenWithFailover(ctx, AllBookTickersStream, endpoints, func(ctx context.Context, base string) error {
+				return listenAllBookTickers(ctx, base, env.bookTickers.handle)
+			}, logger)
+		}
+		tasks.Go(AllBookTickersStream+" listener", func(ctx context.Context) error {
+			defer env.bookTickers.closeAll()
+			return Supervise(ctx, AllBookTickersStream+" listener", DefaultSupervisorPolicy, listen, logger)
+		})
+	}
+	// The all-market ticker arrays are not tied to an instrument and record until Run stops
+	for _, dataType := range cfg.TickerArrays {
+		var opened []fileRecorder
+		rec, err := openRecorder[Ticker24h](cfg, env, MarketSymbol, dataType, &opened)
+		if err != nil {
+			logger.Errorf("%v", err)
+			continue
+		}
+		stream := TickerArrayStream(dataType)
+		endpoints := env.newStreamEndpoints(cfg.FailoverAfter)
+		listen := func(ctx context.Context) error {
+			return ListenWithFailover(ctx, stream, endpoints, func(ctx context.Context, base string) error {
+				return listenTickerArray(ctx, base, dataType, rec)
+			}, logger)
+		}
+		tasks.Go(stream+" listener", func(ctx context.Context) error {
+			defer rec.Close()
+			return Supervise(ctx, stream+" listener", DefaultSupervisorPolicy, listen, logger)
+		})
+	}
+
+	// The options streams are not tied to an instrument either
+	if o := cfg.Options; o != nil {
+		for _, symbol := range o.Symbols {
+			recordOptionStream(tasks, cfg, env, symbol, OptionTradeDataType, OptionTradeStream(symbol), listenOptionTrades, logger)
+			recordOptionStream(tasks, cfg, env, symbol, OptionTickerDataType, OptionTickerStream(symbol), listenOptionTickers, logger)
+		}
+		for _, underlying := range o.Underlyings {
+			recordOptionStream(tasks, cfg, env, underlying, OptionMarkPriceDataType, OptionMarkPriceStream(underlying), listenOptionMarkPrices, logger)
+		}
+		for _, expiration := range o.Expirations {
+			recordOptionStream(tasks, cfg, env, expiration, OptionOpenInterestDataType, OptionOpenInterestStream(expiration), listenOptionOpenInterest, logger)
+		}
+	}
+
+	// The account's user data stream, on a listen key of its own
+	if userData != nil {
+		recordUserData(tasks, cfg, env, userData, logger)
+	}
+
+	if len(cfg.ReferenceKlines) > 0 {
+		backfiller := env.backfiller
+		if backfiller == nil {
+			backfiller = NewBackfiller(client, cfg.Market, DefaultWeightTracker, logger)
+		}
+		tasks.Go("reference klines recorder", func(ctx context.Context) error {
+			RunReferenceKlines(ctx, backfiller, DefaultFileLayout, env.instruments, cfg.ReferenceKlines, logger)
+			return nil
+		})
+	}
+	if cfg.FuturesStats != nil {
+		poller := NewFuturesStatsPoller(client, *cfg.FuturesStats, logger)
+		tasks.Go("futures stats poller", func(ctx context.Context) error {
+			var opened []fileRecorder
+			defer func() {
+				for _, rec := range opened {
+					if err := rec.Close(); err != nil {
+						logger.Errorf("Failed to close futures stats recorder: %v", err)
+					}
+				}
+			}()
+			sets := make(map[string]FuturesStatsRecorders)
+			poller.Run(ctx, env.instruments, func(symbol string) (FuturesStatsRecorders, error) {
+				if set, ok := sets[symbol]; ok {
+					return set, nil
+				}
+				var set FuturesStatsRecorders
+				var err error
+				if set.OpenInterest, err = openRecorder[OpenInterest](cfg, env, symbol, OpenInterestDataType, &opened); err != nil {
+					return set, err
+				}
+				if set.OpenInterestHist, err = openRecorder[OpenInterestHist](cfg, env, symbol, OpenInterestHistDataType, &opened); err != nil {
+					return set, err
+				}
+				if set.PositionRatio, err = openRecorder[LongShortRatio](cfg, env, symbol, TopLongShortPositionRatioDataType, &opened); err != nil {
+					return set, err
+				}
+				if set.AccountRatio, err = openRecorder[LongShortRatio](cfg, env, symbol, TopLongShortAccountRatioDataType, &opened); err != nil {
+					return set, err
+				}
+				sets[symbol] = set
+				return set, nil
+			})
+			return nil
+		})
+	}
+
+	// Deep and top-of-book snapshots share the REST worker pool and weight budget
+	for name, scheduler := range map[string]*SnapshotScheduler{"snapshot scheduler": snapshots, "top-of-book scheduler": topSnapshots} {
+		if scheduler == nil {
+			continue
+		}
+		tasks.Go(name, func(ctx context.Context) error {
+			scheduler.Run(ctx, client)
+			return nil
+		})
+	}
+
+	<-ctx.Done()
+	logger.Infof("Recording stopped. Waiting for pipelines to finish.")
+
+	// Shut down in pipeline order. The listeners stop with ctx and close their queues. Once the tasks, among them the
+	// snapshot schedulers with their in-flight fetches, are done, the snapshot channels are closed too. Every
+	// subscription handler then drains its input and closes its recorders, flushing buffered rows.
+	drained := make(chan struct{})
+	go func() {
+		tasks.Wait()
+		restPool.Close()
+		env.mu.Lock()
+		env.closed = true
+		sources := env.snapshotSources
+		env.mu.Unlock()
+		for _, ch := range sources {
+			close(ch)
+		}
+		env.consumers.Wait()
+		if env.summary != nil {
+			env.summary.Close()
+		}
+		close(drained)
+	}()
+	timer := DefaultClock.NewTimer(cfg.ShutdownTimeout)
+	clean := true
+	select {
+	case <-drained:
+		timer.Stop()
+		logger.Infof("All pipelines drained and recorders closed.")
+	case <-timer.C():
+		clean = false
+		logger.Errorf("Timed out after %s waiting for pipelines to drain; buffered rows may be lost", cfg.ShutdownTimeout)
+	}
+
+	// Then the services, whose sinks flush what they were given last
+	services.Stop()
+	stopped := make(chan struct{})
+	go func() {
+		services.Wait()
+		close(stopped)
+	}()
+	timer = DefaultClock.NewTimer(cfg.ShutdownTimeout)
+	select {
+	case <-stopped:
+		timer.Stop()
+	case <-timer.C():
+		clean = false
+		logger.Errorf("Timed out after %s waiting for %s to stop", cfg.ShutdownTimeout, strings.Join(services.Running(), ", "))
+	}
+
+	// Stop accepting pipeline errors so runErr can be read safely
+	failOnce.Do(func() {})
+	if runErr == nil && clean {
+		session.MarkCleanShutdown(NowFunc())
+	}
+	session.Connections = connectionsSince(firstConnection)
+	if err := WriteSessionFile(sessionFile, session); err != nil {
+		logger.Errorf("Failed to update session file %s: %v", sessionFile, err)
+	}
+	return runErr
+}
+
+// newStreamEndpoints returns a rotation over the stream bases for one listener, avoiding the endpoints the prober
+// finds unhealthy.
+func (env *pipelineEnv) newStreamEndpoints(failoverAfter int) *StreamEndpoints {
+	endpoints := NewStreamEndpoints(env.streamBases, failoverAfter)
+	if env.streamProber != nil {
+		endpoints.SetProber(env.streamProber)
+	}
+	return endpoints
+}
+
+// pipelineEnv holds what the pipelines of every instrument share.
+type pipelineEnv struct {
+	logger       *Logger
+	fail         func(error)
+	standby      *StandbyMonitor
+	sinks        *SinkRegistry
+	uploads      *UploadQueue
+	snapshots    *SnapshotScheduler
+	topSnapshots *SnapshotScheduler
+	streamBases  []string
+	// streamProber, if endpoint probing is on, tells the stream endpoints to avoid
+	streamProber *EndpointProber
+	// backfiller fills trade gaps across reconnects if Config.BackfillGaps is set
+	backfiller *Backfiller
+	// metadata describes the run in the footer of every recorded file
+	metadata map[string]string
+	// diskGuard sheds data types while disk space is low if Config.DiskGuard is set
+	diskGuard *DiskGuard
+	// summary collects the daily summaries if Config.DailySummary is set
+	summary *SummaryCollector
+
+	// bookTickers routes the updates of the all-market book ticker stream to the pipelines if
+	// Config.AllBookTickers is set
+	bookTickers *streamRouter
+
+	// manager multiplexes every instrument's streams over one connection if Config.MultiplexStreams is set, handing
+	// the messages to the pipelines through router
+	manager *StreamManager
+	router  *streamRouter
+
+	// mu serializes starting and stopping instruments, which the admin API does while Run is recording
+	mu sync.Mutex
+	// pipelines holds the running instruments
+	pipelines map[string]*instrumentPipeline
+	// closed is set once Run has started shutting down, after which no instrument may be started
+	closed bool
+	// consumers tracks the subscription handlers, each of which closes its recorders once its input is drained
+	consumers sync.WaitGroup
+	// snapshotSources are the channels the snapshot schedulers deliver to, closed once the schedulers have stopped
+	snapshotSources []chan OrderBookSnapshot
+}
+
+// instruments returns the instruments being recorded, sorted.
+func (env *pipelineEnv) instruments() []string {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	instruments := make([]string, 0, len(env.pipelines))
+	for instrument := range env.pipelines {
+		instruments = append(instruments, instrument)
+	}
+	sort.Strings(instruments)
+	return instruments
+}
+
+// streamListener is a WebSocket listener plus the function that closes its output once it has stopped. handle
+// decodes the stream's messages into the same output when they arrive on a multiplexed connection instead.
+type streamListener struct {
+	listen func(ctx context.Context, base string) error
+	handle func(msg []byte, session WSSession) error
+	done   func()
+}
+
+// streamRouter hands the messages of a multiplexed connection to the pipeline of their stream. A route is only
+// closed while no message is being handed to it.
+type streamRouter struct {
+	mu     sync.RWMutex
+	routes map[string]streamListener
+}
+
+func newStreamRouter() *streamRouter {
+	return &streamRouter{routes: make(map[string]streamListener)}
+}
+
+// add routes the messages of stream, a full stream name like btcusdt@trade, to l.
+func (r *streamRouter) add(stream string, l streamListener) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[stream] = l
+}
+
+func (r *streamRouter) handle(stream string, msg []byte, session WSSession) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	// The all-market book ticker stream carries symbols nobody records, which are not counted per stream
+	l, ok := r.routes[stream]
+	if !ok {
+		return nil
+	}
+	DefaultFrameDebugger.Log(stream, msg, session)
+	recordMessage(stream)
+	return l.handle(msg, session)
+}
+
+// remove stops routing stream and closes its output.
+func (r *streamRouter) remove(stream string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.routes[stream]; ok {
+		l.done()
+		delete(r.routes, stream)
+	}
+}
+
+// closeAll closes the output of every route once the multiplexed connection has stopped.
+func (r *streamRouter) closeAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for stream, l := range r.routes {
+		l.done()
+		delete(r.routes, stream)
+	}
+}
+
+// startInstrument starts the listeners, spill queues, recorders and subscription handlers of every stream selected
+// for instrument (see Config.StreamsFor) and registers the instrument in env.pipelines. The pipelines stop when ctx
+// is cancelled or the instrument is stopped (see stopInstrument). The caller holds env.mu.
+func startInstrument(ctx context.Context, cfg Config, instrument string, env *pipelineEnv) error {
+	if env.closed || ctx.Err() != nil {
+		return errors.New("recording is shutting down")
+	}
+	if _, ok := env.pipelines[instrument]; ok {
+		return fmt.Errorf("%s is already being recorded", instrument)
+	}
+	runCtx := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	removed := make(chan struct{})
+	logger := env.logger
+	want := cfg.StreamsFor(instrument)
+	if env.topSnapshots == nil {
+		delete(want, StreamSnapshotTop)
+	}
+	if cfg.BookTopInterval <= 0 || !want[StreamDepth] {
+		delete(want, StreamBookTop)
+	}
+	buffers := cfg.ChannelBuffers
+	listeners := make(map[string]streamListener)
+	var recorders []fileRecorder
+	// sinks are the sinks opened from env.sinks
+	var sinks []Sink[any]
+	// queues close the inputs of the spill queues created, which stops their goroutines and removes their files
+	var queues []func()
+	// Recorders, sinks and spill queues already created are closed again if a later stream fails to start, so no
+	// pipeline is left half set up
+	started := false
+	defer func() {
+		if !started {
+			cancel()
+			for _, rec := range recorders {
+				rec.Close()
+			}
+			for _, sink := range sinks {
+				sink.Close()
+			}
+			for _, closeQueue := range queues {
+				closeQueue()
+			}
+		}
+	}()
+
+	// consume runs a subscription handler and closes its recorder or sinks once the handler's input has been
+	// drained, so rows still buffered in the recorder reach the parquet file on shutdown
+	// Handlers are restarted if they panic; they keep draining their queues after ctx is cancelled
+	consume := func(handle func(), dataType string, out interface{ Close() error }) {
+		env.consumers.Add(1)
+		go func() {
+			defer env.consumers.Done()
+			task := func(context.Context) error {
+				handle()
+				return nil
+			}
+			if err := Supervise(context.Background(), dataType+" handler for "+instrument, DefaultSupervisorPolicy, task, logger); err != nil {
+				env.fail(err)
+			}
+			if err := out.Close(); err != nil {
+				logger.Errorf("Failed to close %s output for %s: %v", dataType, instrument, err)
+			}
+		}()
+	}
+
+	// Streams are buffered in spill queues, each holding up to its configured number of messages in memory and
+	// spilling any overflow to disk, so a stalled recorder never blocks the WebSocket readers
+	var starts []func()
+	if want[StreamTrade] {
+		q, err := NewSpillQueue[Trade](cfg.SpillDir, instrument+"_trade", buffers.Trade)
+		if err != nil {
+			return fmt.Errorf("failed to create trade spill queue for %s: %w", instrument, err)
+		}
+		queues = append(queues, func() { close(q.In()) })
+		q.SetOverflowPolicy(cfg.OverflowPolicyFor("trade"))
+		out, err := openSinks[Trade](cfg, env, instrument, "trade", &recorders, &sinks, nil)
+		if err != nil {
+			return err
+		}
+		for _, interval := range cfg.Bars {
+			rec, err := openRecorder[Bar](cfg, env, instrument, BarDataType(interval), &recorders)
+			if err != nil {
+				return err
+			}
+			bars, err := NewBarBuilder(instrument, interval, rec)
+			if err != nil {
+				return err
+			}
+			out = append(out, bars)
+		}
+		var writer RecorderWriter[Trade] = out
+		if filter, ok := cfg.RecordFilters["trade"]; ok {
+			writer = FilterTrades(out, filter, instrument)
+		}
+		if env.backfiller != nil {
+			writer = NewTradeGapFiller(ctx, writer, env.backfiller, instrument, logger)
+		}
+		registerChannelOccupancy(instrument, "trade", buffers.Trade, q.Buffered)
+		listeners["trade"] = streamListener{
+			listen: func(ctx context.Context, base string) error {
+				return listenTrade(ctx, base, instrument, q.In())
+			},
+			handle: tradeHandler(strings.ToLower(instrument)+"@trade", q.In()),
+			done:   func() { close(q.In()) },
+		}
+		starts = append(starts, func() {
+			go logSpillErrors(ctx, logger, instrument+"_trade", q.Errors())
+			consume(func() { SubscribeTrades(q.Out(), writer, logger) }, "trade", out)
+		})
+	}
+	if want[StreamAggTrade] {
+		q, err := NewSpillQueue[AggTrade](cfg.SpillDir, instrument+"_aggTrade", buffers.AggTrade)
+		if err != nil {
+			return fmt.Errorf("failed to create aggTrade spill queue for %s: %w", instrument, err)
+		}
+		queues = append(queues, func() { close(q.In()) })
+		q.SetOverflowPolicy(cfg.OverflowPolicyFor("aggTrade"))
+		out, err := openSinks[AggTrade](cfg, env, instrument, "aggTrade", &recorders, &sinks, nil)
+		if err != nil {
+			return err
+		}
+		var writer RecorderWriter[AggTrade] = out
+		if filter, ok := cfg.RecordFilters["aggTrade"]; ok {
+			writer = FilterAggTrades(out, filter, instrument)
+		}
+		if env.backfiller != nil {
+			writer = NewAggTradeGapFiller(ctx, writer, env.backfiller, instrument, logger)
+		}
+		registerChannelOccupancy(instrument, "aggTrade", buffers.AggTrade, q.Buffered)
+		listeners["aggTrade"] = streamListener{
+			listen: func(ctx context.Context, base string) error {
+				return listenAggTrade(ctx, base, instrument, q.In())
+			},
+			handle: aggTradeHandler(strings.ToLower(instrument)+"@aggTrade", q.In()),
+			done:   func() { close(q.In()) },
+		}
+		starts = append(starts, func() {
+			go logSpillErrors(ctx, logger, instrument+"_aggTrade", q.Errors())
+			consume(func() { SubscribeAggTrades(q.Out(), writer, logger) }, "aggTrade", out)
+		})
+	}
+	if want[StreamMarkPrice] {
+		q, err := NewSpillQueue[MarkPrice](cfg.SpillDir, instrument+"_markPrice", buffers.MarkPrice)
+		if err != nil {
+			return fmt.Errorf("failed to create mark price spill queue for %s: %w", instrument, err)
+		}
+		queues = append(queues, func() { close(q.In()) })
+		q.SetOverflowPolicy(cfg.OverflowPolicyFor("markPrice"))
+		rec, err := openRecorder[MarkPrice](cfg, env, instrument, "markPrice", &recorders)
+		if err != nil {
+			return err
+		}
+		registerChannelOccupancy(instrument, "markPrice", buffers.MarkPrice, q.Buffered)
+		listeners["markPrice@1s"] = streamListener{
+			listen: func(ctx context.Context, base string) error {
+				return listenMarkPrice(ctx, base, instrument, q.In())
+			},
+			handle: markPriceHandler(strings.ToLower(instrument)+"@markPrice@1s", q.In()),
+			done:   func() { close(q.In()) },
+		}
+		starts = append(starts, func() {
+			go logSpillErrors(ctx, logger, instrument+"_markPrice", q.Errors())
+			consume(func() { SubscribeMarkPrices(q.Out(), rec, logger) }, "markPrice", rec)
+		})
+	}
+	if want[StreamAvgPrice] {
+		q, err := NewSpillQueue[AvgPrice](cfg.SpillDir, instrument+"_avgPrice", buffers.AvgPrice)
+		if err != nil {
+			return fmt.Errorf("failed to create average price spill queue for %s: %w", instrument, err)
+		}
+		queues = append(queues, func() { close(q.In()) })
+		q.SetOverflowPolicy(cfg.OverflowPolicyFor("avgPrice"))
+		rec, err := openRecorder[AvgPrice](cfg, env, instrument, "avgPrice", &recorders)
+		if err != nil {
+			return err
+		}
+		registerChannelOccupancy(instrument, "avgPrice", buffers.AvgPrice, q.Buffered)
+		listeners["avgPrice"] = streamListener{
+			listen: func(ctx context.Context, base string) error {
+				return listenAvgPrice(ctx, base, instrument, q.In())
+			},
+			handle: avgPriceHandler(strings.ToLower(instrument)+"@avgPrice", q.In()),
+			done:   func() { close(q.In()) },
+		}
+		starts = append(starts, func() {
+			go logSpillErrors(ctx, logger, instrument+"_avgPrice", q.Errors())
+			consume(func() { SubscribeAvgPrices(q.Out(), rec, logger) }, "avgPrice", rec)
+		})
+	}
+	for _, stream := range TickerStreams {
+		if !want[stream] {
+			continue
+		}
+		q, err := NewSpillQueue[Ticker24h](cfg.SpillDir, instrument+"_"+stream, buffers.Ticker)
+		if err != nil {
+			return fmt.Errorf("failed to create %s spill queue for %s: %w", stream, instrument, err)
+		}
+		queues = append(queues, func() { close(q.In()) })
+		q.SetOverflowPolicy(cfg.OverflowPolicyFor(stream))
+		rec, err := openRecorder[Ticker24h](cfg, env, instrument, stream, &recorders)
+		if err != nil {
+			return err
+		}
+		registerChannelOccupancy(instrument, stream, buffers.Ticker, q.Buffered)
+		listeners[stream] = streamListener{
+			listen: func(ctx context.Context, base string) error {
+				return listenTicker(ctx, base, instrument, stream, q.In())
+			},
+			handle: tickerHandler(q.In()),
+			done:   func() { close(q.In()) },
+		}
+		starts = append(starts, func() {
+			go logSpillErrors(ctx, logger, instrument+"_"+stream, q.Errors())
+			consume(func() { SubscribeTickers(q.Out(), rec, logger) }, stream, rec)
+		})
+	}
+	if want[StreamBookTicker] {
+		q, err := NewSpillQueue[BestPrice](cfg.SpillDir, instrument+"_bestPrice", buffers.BestPrice)
+		if err != nil {
+			return fmt.Errorf("failed to create best price spill queue for %s: %w", instrument, err)
+		}
+		queues = append(queues, func() { close(q.In()) })
+		q.SetOverflowPolicy(cfg.OverflowPolicyFor("bestPrice"))
+		file := func(rec *Recorder[BestPrice]) Sink[BestPrice] {
+			if filter := cfg.ConflateBestPrices(instrument, rec); filter != nil {
+				return FilteredSink[BestPrice](rec, filter)
+			}
+			return rec
+		}
+		out, err := openSinks(cfg, env, instrument, "bestPrice", &recorders, &sinks, file)
+		if err != nil {
+			return err
+		}
+		if cfg.MidPrice != nil {
+			rec, err := openRecorder[MidPrice](cfg, env, instrument, MidPriceDataType, &recorders)
+			if err != nil {
+				return err
+			}
+			out = append(out, NewMidPriceSampler(*cfg.MidPrice, rec))
+		}
+		registerChannelOccupancy(instrument, "bestPrice", buffers.BestPrice, q.Buffered)
+		listeners["bookTicker"] = streamListener{
+			listen: func(ctx context.Context, base string) error {
+				return listenBestPrice(ctx, base, instrument, q.In())
+			},
+			handle: bestPriceHandler(strings.ToLower(instrument)+"@bookTicker", q.In()),
+			done:   func() { close(q.In()) },
+		}
+		var writer RecorderWriter[BestPrice] = out
+		if cfg.EnrichBestPrice {
+			writer = BestPriceEnricher{Next: out}
+		}
+		starts = append(starts, func() {
+			go logSpillErrors(ctx, logger, instrument+"_bestPrice", q.Errors())
+			consume(func() { SubscribeBestPrice(q.Out(), writer, logger) }, "bestPrice", out)
+		})
+	}
+
+	// Deep snapshots are fetched by the shared scheduler and fanned out to the order book diff subscription, which
+	// needs them to synchronise and requests one on every gap, and to the snapshot recorder. The diff subscription
+	// stops when its diffs are drained, not when snapshots stop, so only the recording channel is closed with the
+	// source.
+	if want[StreamDepth] || want[StreamSnapshot] {
+		rawSnapshotCh := make(chan OrderBookSnapshot, buffers.Snapshot)
+		registerChannelOccupancy(instrument, "snapshot", buffers.Snapshot, func() int { return len(rawSnapshotCh) })
+		var outs, closeWithSource []chan OrderBookSnapshot
+		// books are the local order books synchronised from the snapshots, if book tops or features are recorded
+		var books []*LocalOrderBook
+		localBook := want[StreamBookTop] || cfg.BookFeatureInterval > 0
+		if want[StreamDepth] {
+			q, err := NewSpillQueue[OrderBookDiff](cfg.SpillDir, instrument+"_orderBookDiff", buffers.Depth)
+			if err != nil {
+				return fmt.Errorf("failed to create order book diff spill queue for %s: %w", instrument, err)
+			}
+			queues = append(queues, func() { close(q.In()) })
+			q.SetOverflowPolicy(cfg.OverflowPolicyFor("orderBookDiff"))
+			// The diffs' price levels are reused once the parquet recorder has encoded them, unless something else
+			// holds on to diffs: a local book buffering them while it synchronises, or another sink
+			var release func(*Recorder[OrderBookDiff]) Sink[OrderBookDiff]
+			if !localBook && slices.Equal(cfg.SinksFor("orderBookDiff"), []string{ParquetSinkName}) {
+				release = func(rec *Recorder[OrderBookDiff]) Sink[OrderBookDiff] {
+					rec.SetRelease(ReleaseOrderBookDiff)
+					return rec
+				}
+			}
+			out, err := openSinks[OrderBookDiff](cfg, env, instrument, "orderBookDiff", &recorders, &sinks, release)
+			if err != nil {
+				return err
+			}
+			snapshotDiffCh := make(chan OrderBookSnapshot, buffers.Snapshot)
+			outs = append(outs, snapshotDiffCh)
+			registerChannelOccupancy(instrument, "depth", buffers.Depth, q.Buffered)
+			registerChannelOccupancy(instrument, "snapshotDiff", buffers.Snapshot, func() int { return len(snapshotDiffCh) })
+			listeners["depth"] = streamListener{
+				listen: func(ctx context.Context, base string) error {
+					return listenOrderBookDiff(ctx, base, instrument, q.In())
+				},
+				handle: orderBookDiffHandler(strings.ToLower(instrument)+"@depth", q.In()),
+				done:   func() { close(q.In()) },
+			}
+			snapshotRequest := func() {
+				env.snapshots.Request(instrument)
+			}
+			// The local book, if book tops or features are recorded, is fed every diff on its way to the
+			// subscription and synchronises itself from the same snapshots
+			var book *LocalOrderBook
+			if localBook {
+				book = NewLocalOrderBook()
+				book.SetSequencer(SequencerFor(cfg.Market))
+				books = append(books, book)
+			}
+			if want[StreamBookTop] {
+				topRec, err := openRecorder[BookTop](cfg, env, instrument, "bookTop", &recorders)
+				if err != nil {
+					return err
+				}
+				starts = append(starts, func() {
+					consume(func() { RunBookTop(ctx, book, cfg.BookTopInterval, cfg.BookTopLevels, topRec, logger) }, "bookTop", topRec)
+				})
+			}
+			if cfg.BookFeatureInterval > 0 {
+				featureRec, err := openRecorder[BookFeatures](cfg, env, instrument, BookFeaturesDataType, &recorders)
+				if err != nil {
+					return err
+				}
+				starts = append(starts, func() {
+					consume(func() { RunBookFeatures(ctx, book, cfg.BookFeatureInterval, featureRec, logger) }, BookFeaturesDataType, featureRec)
+				})
+			}
+			starts = append(starts, func() {
+				go logSpillErrors(ctx, logger, instrument+"_orderBookDiff", q.Errors())
+				diffs := q.Out()
+				if book != nil {
+					diffs = FeedLocalOrderBook(diffs, book, logger)
+				}
+				subscribe := SubscribeOrderBookDiff
+				if cfg.Market == MarketUSDM {
+					subscribe = SubscribeFuturesOrderBookDiff
+				}
+				consume(func() {
+					subscribe(diffs, snapshotDiffCh, out, snapshotRequest, logger)
+					// Keep the fan-out from blocking on snapshots nobody reads any more
+					go func() {
+						for range snapshotDiffCh {
+						}
+					}()
+				}, "orderBookDiff", out)
+			})
+		}
+		if want[StreamSnapshot] {
+			rec, err := openRecorder[OrderBookSnapshot](cfg, env, instrument, "snapshot", &recorders)
+			if err != nil {
+				return err
+			}
+			snapshotRecCh := make(chan OrderBookSnapshot, buffers.Snapshot)
+			outs = append(outs, snapshotRecCh)
+			closeWithSource = append(closeWithSource, snapshotRecCh)
+			registerChannelOccupancy(instrument, "snapshotRecord", buffers.Snapshot, func() int { return len(snapshotRecCh) })
+			starts = append(starts, func() {
+				consume(func() { SubscribeSnapshots(snapshotRecCh, rec, logger) }, "snapshot", rec)
+			})
+		}
+		starts = append(starts, func() {
+			go func() {
+				// A removed instrument records no more snapshots, but its diff subscription may still be draining
+				// and keeps receiving them until the source is closed
+				stopped := removed
+				for {
+					select {
+					case snapshot, ok := <-rawSnapshotCh:
+						if !ok {
+							for _, out := range closeWithSource {
+								close(out)
+							}
+							return
+						}
+						for _, book := range books {
+							if err := book.ApplySnapshot(snapshot); err != nil {
+								logger.Errorf("Local order book for %s not synchronised: %v", instrument, err)
+							}
+						}
+						for _, out := range outs {
+							out <- snapshot
+						}
+					case <-stopped:
+						stopped = nil
+						for _, out := range closeWithSource {
+							close(out)
+						}
+						outs = slices.DeleteFunc(outs, func(out chan OrderBookSnapshot) bool {
+							return slices.Contains(closeWithSource, out)
+						})
+						closeWithSource = nil
+					}
+				}
+			}()
+			env.snapshotSources = append(env.snapshotSources, rawSnapshotCh)
+			env.snapshots.Add(instrument, rawSnapshotCh, NowFunc())
+			if cfg.SnapshotGapsOnly && !want[StreamDepth] {
+				// Nothing else asks for the instrument's one snapshot
+				env.snapshots.Request(instrument)
+			}
+		})
+	}
+	if want[StreamSnapshotTop] {
+		rec, err := openRecorder[OrderBookSnapshot](cfg, env, instrument, "snapshotTop", &recorders)
+		if err != nil {
+			return err
+		}
+		rawTopCh := make(chan OrderBookSnapshot, buffers.Snapshot)
+		topSnapshotCh := make(chan OrderBookSnapshot, buffers.Snapshot)
+		registerChannelOccupancy(instrument, "snapshotTop", buffers.Snapshot, func() int { return len(topSnapshotCh) })
+		starts = append(starts, func() {
+			go forwardSnapshots(removed, rawTopCh, topSnapshotCh)
+			consume(func() { SubscribeSnapshots(topSnapshotCh, rec, logger) }, "snapshotTop", rec)
+			env.snapshotSources = append(env.snapshotSources, rawTopCh)
+			env.topSnapshots.Add(instrument, rawTopCh, NowFunc())
+		})
+	}
+
+	// The raw archive sees every frame before it is decoded; its queue is closed once every listener is done
+	if cfg.ArchiveRaw && len(listeners) > 0 {
+		q, err := NewSpillQueue[RawMessage](cfg.SpillDir, instrument+"_raw", buffers.Raw)
+		if err != nil {
+			return fmt.Errorf("failed to create raw spill queue for %s: %w", instrument, err)
+		}
+		queues = append(queues, func() { close(q.In()) })
+		q.SetOverflowPolicy(cfg.OverflowPolicyFor("raw"))
+		rec, err := openRecorder[RawMessage](cfg, env, instrument, "raw", &recorders)
+		if err != nil {
+			return err
+		}
+		registerChannelOccupancy(instrument, "raw", buffers.Raw, q.Buffered)
+		var open atomic.Int64
+		open.Store(int64(len(listeners)))
+		for key, l := range listeners {
+			stream := strings.ToLower(instrument) + "@" + key
+			done := l.done
+			l.handle = archiveFrames(stream, q.In(), l.handle)
+			l.listen = func(ctx context.Context, base string) error {
+				return listenWebSocket(ctx, base+"/ws/"+stream, l.handle)
+			}
+			l.done = func() {
+				done()
+				if open.Add(-1) == 0 {
+					close(q.In())
+				}
+			}
+			listeners[key] = l
+		}
+		starts = append(starts, func() {
+			go logSpillErrors(ctx, logger, instrument+"_raw", q.Errors())
+			consume(func() { SubscribeRawMessages(q.Out(), rec, logger) }, "raw", rec)
+		})
+	}
+
+	started = true
+	for _, start := range starts {
+		start()
+	}
+	// With the all-market book ticker stream, the instrument's book tickers arrive on that shared connection
+	if l, ok := listeners["bookTicker"]; ok && env.bookTickers != nil {
+		delete(listeners, "bookTicker")
+		env.bookTickers.add(strings.ToLower(instrument)+"@bookTicker", l)
+	}
+	p := &instrumentPipeline{
+		instrument: instrument,
+		streams:    want,
+		listeners:  listeners,
+		recorders:  recorders,
+		ctx:        ctx,
+		cancel:     cancel,
+		running:    make(map[string]context.CancelFunc),
+		stopped:    make(chan struct{}),
+		removed:    removed,
+	}
+	env.pipelines[instrument] = p
+	go p.stopWhenDone(runCtx, env)
+
+	if env.manager != nil {
+		keys := make([]string, 0, len(listeners))
+		for key, l := range listeners {
+			env.router.add(strings.ToLower(instrument)+"@"+key, l)
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if err := env.manager.AddSymbol(instrument, keys...); err != nil {
+			stopInstrument(env, instrument)
+			return fmt.Errorf("failed to subscribe %s on the multiplexed connection: %w", instrument, err)
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for _, key := range keys {
+			if p.running != nil {
+				p.running[key] = func() {}
+			}
+		}
+		return nil
+	}
+	// p is published, so a stop may already have begun; the listeners then must not be registered any more
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running == nil {
+		return nil
+	}
+	for key := range listeners {
+		p.startListener(key, cfg, env)
+	}
+	return nil
+}
+
+// recordOptionStream starts the task recording an options stream to symbol's dataType file until Run stops, with
+// listen connecting to OptionsStreamBaseURL.
+func recordOptionStream[T any](tasks *taskGroup, cfg Config, env *pipelineEnv, symbol, dataType, stream string,
+	listen func(ctx context.Context, base, symbol string, recorder RecorderWriter[T]) error, logger LoggerInterface) {
+	var opened []fileRecorder
+	rec, err := openRecorder[T](cfg, env, symbol, dataType, &opened)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return
+	}
+	endpoints := NewStreamEndpoints([]string{OptionsStreamBaseURL}, cfg.FailoverAfter)
+	supervised := func(ctx context.Context) error {
+		return ListenWithFailover(ctx, stream, endpoints, func(ctx context.Context, base string) error {
+			return listen(ctx, base, symbol, rec)
+		}, logger)
+	}
+	tasks.Go(stream+" listener", func(ctx context.Context) error {
+		defer rec.Close()
+		return Supervise(ctx, stream+" listener", DefaultSupervisorPolicy, supervised, logger)
+	})
+}
+
+// recordUserData starts the task recording the account's user data stream under AccountSymbol until Run stops.
+func recordUserData(tasks *taskGroup, cfg Config, env *pipelineEnv, stream *UserDataStream, logger LoggerInterface) {
+	var opened []fileRecorder
+	closeAll := func() {
+		for _, rec := range opened {
+			rec.Close()
+		}
+	}
+	var recorders UserDataRecorders
+	var err error
+	if recorders.Orders, err = openRecorder[ExecutionReport](cfg, env, AccountSymbol, ExecutionReportDataType, &opened); err == nil {
+		if recorders.Balances, err = openRecorder[AccountBalance](cfg, env, AccountSymbol, AccountPositionDataType, &opened); err == nil {
+			recorders.BalanceUpdates, err = openRecorder[BalanceUpdate](cfg, env, AccountSymbol, BalanceUpdateDataType, &opened)
+		}
+	}
+	if err != nil {
+		logger.Errorf("%v", err)
+		closeAll()
+		return
+	}
+	base := UserDataStreamBaseURL
+	if cfg.Testnet {
+		base = SpotTestnetStreamBaseURL
+	}
+	endpoints := NewStreamEndpoints([]string{base}, cfg.FailoverAfter)
+	listen := func(ctx context.Context) error {
+		return ListenWithFailover(ctx, "user data stream", endpoints, func(ctx context.Context, base string) error {
+			return stream.Listen(ctx, base, recorders)
+		}, logger)
+	}
+	tasks.Go("user data listener", func(ctx context.Context) error {
+		defer closeAll()
+		return Supervise(ctx, "user data listener", DefaultSupervisorPolicy, listen, logger)
+	})
+}
+
+// openRecorder creates the recorder of instrument's dataType for startInstrument and appends it to opened.
+func openRecorder[T any](cfg Config, env *pipelineEnv, instrument, dataType string, opened *[]fileRecorder) (*Recorder[T], error) {
+	rec, err := NewRecorder[T](instrument, dataType, cfg.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s recorder for %s: %w", dataType, instrument, err)
+	}
+	if env.standby != nil {
+		rec.SetFinalizeGate(env.standby)
+	}
+	if env.diskGuard != nil {
+		rec.SetDiskGuard(env.diskGuard)
+	}
+	if env.summary != nil {
+		rec.SetSummary(env.summary)
+	}
+	metadata := maps.Clone(env.metadata)
+	if speed := cfg.UpdateSpeed(dataType); speed != "" {
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[MetadataUpdateSpeed] = speed
+	}
+	rec.SetMetadata(metadata)
+	rec.SetManifest(cfg.Manifest)
+	rec.SetChecksums(cfg.Checksums)
+	rec.SetVerify(cfg.VerifyFiles)
+	rec.SetWriteQueue(cfg.WriteQueue)
+	rec.SetFlushInterval(cfg.FlushInterval)
+	rec.SetRotationInterval(cfg.RotateEvery)
+	rec.SetMaxFileSize(cfg.MaxFileSize)
+	if env.uploads != nil {
+		rec.SetFinalizeHook(func(filePath string) {
+			if err := env.uploads.Enqueue(filePath); err != nil {
+				env.logger.Errorf("Failed to queue %s for upload: %v", filePath, err)
+			}
+			if cfg.Checksums {
+				if err := env.uploads.Enqueue(filePath + ChecksumSuffix); err != nil {
+					env.logger.Errorf("Failed to queue the checksum of %s for upload: %v", filePath, err)
+				}
+			}
+		})
+	}
+	*opened = append(*opened, rec)
+	return rec, nil
+}
+
+// openSinks opens the sinks of instrument's dataType selected by Config.SinksFor for startInstrument, appending the
+// parquet recorder to recorders and the sinks opened from env.sinks to opened. file, if set, wraps the recorder,
+// e.g. in a filter.
+func openSinks[T any](cfg Config, env *pipelineEnv, instrument, dataType string, recorders *[]fileRecorder, opened *[]Sink[any], file func(*Recorder[T]) Sink[T]) (FanOutSink[T], error) {
+	var out FanOutSink[T]
+	for _, name := range cfg.SinksFor(dataType) {
+		if name == ParquetSinkName {
+			rec, err := openRecorder[T](cfg, env, instrument, dataType, recorders)
+			if err != nil {
+				return nil, err
+			}
+			if file != nil {
+				out = append(out, file(rec))
+			} else {
+				out = append(out, rec)
+			}
+			continue
+		}
+		sink, err := env.sinks.Open(name, instrument)
+		if err != nil {
+			return nil, err
+		}
+		*opened = append(*opened, sink)
+		out = append(out, UntypedSink[T](sink))
+	}
+	return out, nil
+}
+
+// serveHTTP serves handler on addr as a task of g until g is stopped, logging server errors.
+func serveHTTP(g *taskGroup, addr string, handler http.Handler, logger *Logger) {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	g.Go("http server on "+addr, func(ctx context.Context) error {
+		stop := context.AfterFunc(ctx, func() { srv.Close() })
+		defer stop()
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("HTTP server error on %s: %v", addr, err)
+		}
+		return nil
+	})
+}
+
+// logSpillErrors logs I/O errors reported by a spill queue until the context is cancelled.
+func logSpillErrors(ctx context.Context, logger *Logger, name string, errs <-chan error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errs:
+			logger.Errorf("Spill queue error for %s: %v", name, err)
+		}
+	}
+}
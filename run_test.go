@@ -0,0 +1,737 @@
+package gobinapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("DefaultConfig should be valid, got: %v", err)
+	}
+}
+
+func TestConfigValidateRejectsBadValues(t *testing.T) {
+	cases := map[string]func(*Config){
+		"instrument":         func(c *Config) { c.Instruments = nil },
+		"batch size":         func(c *Config) { c.BatchSize = 0 },
+		"snapshot interval":  func(c *Config) { c.SnapshotInterval = 0 },
+		"spill directory":    func(c *Config) { c.SpillDir = "" },
+		"depth channel":      func(c *Config) { c.ChannelBuffers.Depth = 0 },
+		"snapshot limit":     func(c *Config) { c.SnapshotLimit = 10000 },
+		"top-of-book levels": func(c *Config) { c.TopOfBookLevels = 0 },
+		"book top levels":    func(c *Config) { c.BookTopInterval, c.BookTopLevels = time.Second, 500 },
+		"derived from the depth stream": func(c *Config) {
+			c.BookTopInterval = time.Second
+			c.Streams = map[string][]string{"BTCUSDT": {StreamTrade, StreamBookTop}}
+		},
+		"REST weight limit":   func(c *Config) { c.RESTWeightLimit = 0 },
+		"REST workers":        func(c *Config) { c.RESTWorkers = 0 },
+		"keyframe":            func(c *Config) { c.BestPriceChangeOnly, c.BestPriceKeyframe = true, 0 },
+		"failover":            func(c *Config) { c.FailoverAfter = 0 },
+		"address family":      func(c *Config) { c.AddressFamily = "ipx" },
+		"stream idle":         func(c *Config) { c.StreamIdleTimeout = -time.Second },
+		"ping interval":       func(c *Config) { c.PingInterval = -time.Second },
+		"connection lifetime": func(c *Config) { c.ConnectionLifetime = 24 * time.Hour },
+		"drop journal":        func(c *Config) { c.DropJournalInterval = 0 },
+		"quarantine file":     func(c *Config) { c.StrictValidation, c.QuarantineFile = true, "" },
+		"max clock skew":      func(c *Config) { c.StrictValidation, c.MaxClockSkew = true, 0 },
+		"unknown market":      func(c *Config) { c.Market = "coinm" },
+		"USD-M futures":       func(c *Config) { c.Market, c.SnapshotLimit = MarketUSDM, 200 },
+		"unknown stream":      func(c *Config) { c.Market, c.Streams = MarketUSDM, map[string][]string{"BTCUSDT": {StreamTrade}} },
+		`unknown stream "ticker_1h"`: func(c *Config) {
+			c.Market, c.Streams = MarketUSDM, map[string][]string{"BTCUSDT": {StreamTicker1h}}
+		},
+		"one connection": func(c *Config) {
+			c.MultiplexStreams = true
+			for i := 0; i < 300; i++ {
+				c.Instruments = append(c.Instruments, "SYM"+strconv.Itoa(i)+"USDT")
+			}
+		},
+	}
+	for want, mutate := range cases {
+		cfg := DefaultConfig()
+		mutate(&cfg)
+		err := cfg.Validate()
+		if err == nil {
+			t.Errorf("expected error mentioning %q, got nil", want)
+		} else if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error mentioning %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestRunReturnsValidationError(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Instruments = nil
+	if err := Run(context.Background(), cfg); err == nil {
+		t.Fatalf("expected Run to fail fast on an invalid config")
+	}
+}
+
+func TestMainTradeWebSocketIntegration(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 5000001, "95000.00", "0.012"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tradeCh := make(chan Trade, 1)
+	go func() {
+		if err := ListenTrade(ctx, "BTCUSDT", tradeCh); err != nil {
+			t.Logf("ListenTrade error: %v", err) // Log error if ListenTrade returns before a trade is received
+		}
+	}()
+	select {
+	case trade := <-tradeCh:
+		t.Logf("Received trade: %+v", trade)
+		if trade.EventType != "trade" {
+			t.Errorf("Expected EventType 'trade', got %s", trade.EventType)
+		}
+		if trade.TradeID <= 0 {
+			t.Errorf("TradeID should be positive, got %d", trade.TradeID)
+		}
+		if trade.Price == "" {
+			t.Errorf("Price should not be empty")
+		}
+	case <-ctx.Done():
+		t.Fatalf("Timeout waiting for trade message")
+	}
+}
+
+// TestMainOrderBookSnapshotIntegration calls the mock server's REST API snapshot endpoint for BTCUSDT, waits up to 10 seconds for a response,
+// and verifies that the parsed OrderBookSnapshot contains a valid non-zero LastUpdateID with non-empty bid and ask lists.
+func TestMainOrderBookSnapshotIntegration(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetSnapshot("BTCUSDT", mockbinance.SnapshotMessage(1027024,
+		[]mockbinance.Level{{"95000.00", "0.5"}}, []mockbinance.Level{{"95000.01", "1.2"}}))
+	client := &http.Client{Timeout: 10 * time.Second}
+	// Call the REST API snapshot endpoint for BTCUSDT
+	snapshot, err := FetchOrderBookSnapshot(client, "BTCUSDT")
+	if err != nil {
+		t.Fatalf("Failed to fetch snapshot: %v", err)
+	}
+	if snapshot.LastUpdateID <= 0 {
+		t.Fatalf("Invalid LastUpdateID: %d", snapshot.LastUpdateID)
+	}
+	if len(snapshot.Bids) == 0 {
+		t.Fatalf("Empty Bids in snapshot")
+	}
+	if len(snapshot.Asks) == 0 {
+		t.Fatalf("Empty Asks in snapshot")
+	}
+	t.Logf("Fetched snapshot for BTCUSDT: LastUpdateID = %d, %d bids, %d asks", snapshot.LastUpdateID, len(snapshot.Bids), len(snapshot.Asks))
+}
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite golden files in testdata")
+
+// replayOutput is the decoded content of the parquet files written during a replay, compared against a golden file.
+type replayOutput struct {
+	Trades         []Trade             `json:"trades"`
+	OrderBookDiffs []OrderBookDiff     `json:"orderBookDiffs"`
+	Snapshots      []OrderBookSnapshot `json:"snapshots"`
+}
+
+// TestReplayPipeline_GoldenOutput replays a recorded archive through the mock server and runs the full pipeline
+// (listeners → gap logic → parquet recorders). The archive contains a stale diff, a sequence gap and a resync, and
+// the decoded parquet output must match testdata/replay_btcusdt.golden.json byte for byte.
+func TestReplayPipeline_GoldenOutput(t *testing.T) {
+	archive, err := filepath.Abs("testdata/replay_btcusdt.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := strings.TrimSuffix(archive, ".jsonl") + ".golden.json"
+
+	srv, err := mockbinance.NewReplayServer(archive)
+	if err != nil {
+		t.Fatalf("failed to start replay server: %v", err)
+	}
+	defer srv.Close()
+	oldStream, oldREST, oldNow := StreamBaseURL, RESTBaseURL, NowFunc
+	StreamBaseURL, RESTBaseURL = srv.WSURL(), srv.URL()
+	NowFunc = func() time.Time { return time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC) }
+	defer func() { StreamBaseURL, RESTBaseURL, NowFunc = oldStream, oldREST, oldNow }()
+	t.Chdir(t.TempDir())
+
+	// Session IDs are random and generations count connects made by earlier tests, so pin both
+	oldConnID := NewConnID
+	NewConnID = func() string { return "replay" }
+	defer func() { NewConnID = oldConnID }()
+	wsStats.mu.Lock()
+	delete(wsStats.streams, "btcusdt@trade")
+	delete(wsStats.streams, "btcusdt@depth")
+	wsStats.mu.Unlock()
+
+	const symbol = "BTCUSDT"
+	tradeRec, err := NewRecorder[Trade](symbol, "trade", 1)
+	if err != nil {
+		t.Fatalf("failed to create trade recorder: %v", err)
+	}
+	diffRec, err := NewRecorder[OrderBookDiff](symbol, "orderBookDiff", 1)
+	if err != nil {
+		t.Fatalf("failed to create diff recorder: %v", err)
+	}
+	snapshotRec, err := NewRecorder[OrderBookSnapshot](symbol, "snapshot", 1)
+	if err != nil {
+		t.Fatalf("failed to create snapshot recorder: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := &http.Client{Timeout: 5 * time.Second}
+	logger := &FakeLogger{}
+
+	tradeCh := make(chan Trade, 10)
+	diffCh := make(chan OrderBookDiff, 10)
+	snapshotCh := make(chan OrderBookSnapshot, 10)
+	recSnapshotCh := make(chan OrderBookSnapshot, 10)
+	var fetches sync.WaitGroup
+	snapshotRequest := func() {
+		fetches.Add(1)
+		go func() {
+			defer fetches.Done()
+			snapshot, err := FetchOrderBookSnapshot(client, symbol)
+			if err != nil {
+				t.Errorf("snapshot request failed: %v", err)
+				return
+			}
+			recSnapshotCh <- *snapshot
+			snapshotCh <- *snapshot
+		}()
+	}
+
+	var consumers sync.WaitGroup
+	consumers.Add(3)
+	go func() { defer consumers.Done(); SubscribeTrades(tradeCh, tradeRec, logger) }()
+	go func() {
+		defer consumers.Done()
+		SubscribeOrderBookDiff(diffCh, snapshotCh, diffRec, snapshotRequest, logger)
+	}()
+	go func() { defer consumers.Done(); SubscribeSnapshots(recSnapshotCh, snapshotRec, logger) }()
+
+	// The replay server closes each stream once its frames are sent, so the listeners return when the replay is done
+	var listeners sync.WaitGroup
+	listeners.Add(2)
+	go func() { defer listeners.Done(); ListenTrade(ctx, symbol, tradeCh) }()
+	go func() { defer listeners.Done(); ListenOrderBookDiff(ctx, symbol, diffCh) }()
+	listeners.Wait()
+	if ctx.Err() != nil {
+		t.Fatalf("replay did not complete: %v", ctx.Err())
+	}
+	fetches.Wait()
+	close(tradeCh)
+	close(diffCh)
+	close(recSnapshotCh)
+	consumers.Wait()
+	for _, r := range []fileRecorder{tradeRec, diffRec, snapshotRec} {
+		if err := r.Close(); err != nil {
+			t.Fatalf("failed to close recorder: %v", err)
+		}
+	}
+
+	var out replayOutput
+	now := NowFunc()
+	if out.Trades, err = ReadParquetFile[Trade](BuildFileName("trade", symbol, now)); err != nil {
+		t.Fatalf("failed to read trades: %v", err)
+	}
+	if out.OrderBookDiffs, err = ReadParquetFile[OrderBookDiff](BuildFileName("orderBookDiff", symbol, now)); err != nil {
+		t.Fatalf("failed to read diffs: %v", err)
+	}
+	if out.Snapshots, err = ReadParquetFile[OrderBookSnapshot](BuildFileName("snapshot", symbol, now)); err != nil {
+		t.Fatalf("failed to read snapshots: %v", err)
+	}
+	actual, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual = append(actual, '\n')
+
+	if *updateGolden {
+		if err := os.WriteFile(golden, actual, 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update-golden to create it): %v", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("replay output differs from %s:\n%s", golden, actual)
+	}
+}
+
+// TestRun_UploadsWithoutOutputDir records with uploads on in the default layout, with the files and the upload
+// queue's state in the working directory.
+func TestRun_UploadsWithoutOutputDir(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("upldusdt@trade", mockbinance.TradeMessage("UPLDUSDT", 1, "1.0", "1"))
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer bucket.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"UPLDUSDT"}
+	cfg.Streams = map[string][]string{"UPLDUSDT": {StreamTrade}}
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Upload = &UploadConfig{S3: &S3Config{Bucket: "market", Region: "us-east-1", Endpoint: bucket.URL}}
+	cfg.Logger = NewLogger(io.Discard)
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected uploads without an output directory to be valid, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var rows int64
+		for _, s := range Introspect().Recorders {
+			if s.Instrument == "UPLDUSDT" {
+				rows = s.Rows
+			}
+		}
+		if rows == 1 {
+			break
+		}
+		select {
+		case err := <-done:
+			cancel()
+			t.Fatalf("Run returned %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for the trade to reach the recorder")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+	if !FileExists("uploads.json") {
+		t.Errorf("expected the upload queue's state in the working directory")
+	}
+}
+
+// TestRun_ShutdownFlushesRecorders stops Run while trades are still buffered in the recorder and checks that they
+// reach the parquet file before Run returns.
+func TestRun_ShutdownFlushesRecorders(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("shutusdt@trade",
+		mockbinance.TradeMessage("SHUTUSDT", 1, "1.0", "1"),
+		mockbinance.TradeMessage("SHUTUSDT", 2, "1.1", "2"))
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"SHUTUSDT"}
+	cfg.Streams = map[string][]string{"SHUTUSDT": {StreamTrade}}
+	cfg.BatchSize = 100
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	var logs bytes.Buffer
+	cfg.Logger = NewLogger(&logs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var rows int64
+		for _, s := range Introspect().Recorders {
+			if s.Instrument == "SHUTUSDT" {
+				rows = s.Rows
+			}
+		}
+		if rows == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for trades to reach the recorder")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	start := time.Now()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected a prompt shutdown once drained, took %s", elapsed)
+	}
+
+	trades, err := ReadParquetFile[Trade](BuildFileName("trade", "SHUTUSDT", NowFunc()))
+	if err != nil {
+		t.Fatalf("failed to read trades: %v", err)
+	}
+	if len(trades) != 2 {
+		t.Errorf("expected both buffered trades to be flushed, got %d", len(trades))
+	}
+	if !strings.Contains(logs.String(), "All pipelines drained") {
+		t.Errorf("expected the drained message in the log:\n%s", logs.String())
+	}
+}
+
+func TestRun_RecordsBookTopFromLocalBook(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetSnapshot("TOPUSDT", mockbinance.SnapshotMessage(10,
+		[]mockbinance.Level{{"99.00", "1.0"}, {"98.00", "2.0"}},
+		[]mockbinance.Level{{"101.00", "1.0"}}))
+	srv.SetStream("topusdt@depth", mockbinance.DepthSequence("TOPUSDT", 11, 5, 0)...)
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"TOPUSDT"}
+	cfg.Streams = map[string][]string{"TOPUSDT": {StreamDepth, StreamBookTop}}
+	cfg.BookTopInterval = 10 * time.Millisecond
+	cfg.BookTopLevels = 2
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var rows int64
+		for _, s := range Introspect().Recorders {
+			if s.Instrument == "TOPUSDT" && s.DataType == "bookTop" {
+				rows = s.Rows
+			}
+		}
+		if rows >= 20 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for book tops to be recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	tops, err := ReadParquetFile[BookTop](BuildFileName("bookTop", "TOPUSDT", NowFunc()))
+	if err != nil || len(tops) == 0 {
+		t.Fatalf("failed to read book tops: %v", err)
+	}
+	// The snapshot's levels plus those of the diffs that followed it
+	expected := BookTop{
+		LastUpdateID: 15,
+		EventTime:    1700000000015,
+		Bids:         []PriceLevel{{"100.00", "1.0"}, {"99.00", "1.0"}},
+		Asks:         []PriceLevel{{"100.10", "2.0"}, {"101.00", "1.0"}},
+	}
+	top := tops[len(tops)-1]
+	top.Time = 0
+	if !reflect.DeepEqual(top, expected) {
+		t.Errorf("expected %+v, got %+v", expected, top)
+	}
+}
+
+func TestRun_FuturesMarketSequencesByPreviousUpdateID(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetSnapshot("FUTUSDT", mockbinance.SnapshotMessage(100,
+		[]mockbinance.Level{{"99.00", "1.0"}},
+		[]mockbinance.Level{{"101.00", "1.0"}}))
+	// Futures update IDs jump between events; only pu links each event to the previous one
+	srv.SetStream("futusdt@depth",
+		mockbinance.FuturesDepthUpdateMessage("FUTUSDT", 95, 105, 94, []mockbinance.Level{{"99.50", "1.0"}}, nil),
+		mockbinance.FuturesDepthUpdateMessage("FUTUSDT", 110, 120, 105, nil, []mockbinance.Level{{"100.50", "2.0"}}),
+		mockbinance.FuturesDepthUpdateMessage("FUTUSDT", 130, 140, 120, []mockbinance.Level{{"99.00", "0"}}, nil))
+	srv.SetStream("futusdt@markPrice@1s",
+		mockbinance.MarkPriceMessage("FUTUSDT", 1, "100.10", "0.00010000"),
+		mockbinance.MarkPriceMessage("FUTUSDT", 2, "100.20", "0.00010000"))
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Market = MarketUSDM
+	cfg.Instruments = []string{"FUTUSDT"}
+	cfg.Streams = map[string][]string{"FUTUSDT": {StreamDepth, StreamBookTop, StreamMarkPrice}}
+	cfg.BookTopInterval = 10 * time.Millisecond
+	cfg.BookTopLevels = 5
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rows := make(map[string]int64)
+		for _, s := range Introspect().Recorders {
+			if s.Instrument == "FUTUSDT" {
+				rows[s.DataType] = s.Rows
+			}
+		}
+		if rows["bookTop"] >= 20 && rows["markPrice"] >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for futures data to be recorded, got %v", rows)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	tops, err := ReadParquetFile[BookTop](BuildFileName("bookTop", "FUTUSDT", NowFunc()))
+	if err != nil || len(tops) == 0 {
+		t.Fatalf("failed to read book tops: %v", err)
+	}
+	expected := BookTop{
+		LastUpdateID: 140,
+		EventTime:    1700000000140,
+		Bids:         []PriceLevel{{"99.50", "1.0"}},
+		Asks:         []PriceLevel{{"100.50", "2.0"}, {"101.00", "1.0"}},
+	}
+	top := tops[len(tops)-1]
+	top.Time = 0
+	if !reflect.DeepEqual(top, expected) {
+		t.Errorf("expected %+v, got %+v", expected, top)
+	}
+	if n := srv.SnapshotRequests("FUTUSDT"); n != 1 {
+		t.Errorf("expected a single snapshot request, got %d", n)
+	}
+
+	marks, err := ReadParquetFile[MarkPrice](BuildFileName("markPrice", "FUTUSDT", NowFunc()))
+	if err != nil || len(marks) != 2 {
+		t.Fatalf("expected 2 mark prices, got %d (%v)", len(marks), err)
+	}
+	if marks[1].MarkPrice != "100.20" || marks[1].FundingRate != "0.00010000" || marks[1].NextFundingTime != 1700006400000 {
+		t.Errorf("unexpected mark price %+v", marks[1])
+	}
+}
+
+func TestRun_RecordsAveragePrices(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 1, "100.00", "1"))
+	srv.SetStream("btcusdt@avgPrice",
+		mockbinance.AvgPriceMessage("BTCUSDT", 1, "100.01"),
+		mockbinance.AvgPriceMessage("BTCUSDT", 2, "100.02"))
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"BTCUSDT"}
+	cfg.Streams = map[string][]string{"BTCUSDT": {StreamTrade, StreamAvgPrice}}
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rows := make(map[string]int64)
+		for _, s := range Introspect().Recorders {
+			if s.Instrument == "BTCUSDT" {
+				rows[s.DataType] = s.Rows
+			}
+		}
+		if rows["trade"] >= 1 && rows["avgPrice"] >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for average prices to be recorded, got %v", rows)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	avgs, err := ReadParquetFile[AvgPrice](BuildFileName("avgPrice", "BTCUSDT", NowFunc()))
+	if err != nil || len(avgs) != 2 {
+		t.Fatalf("expected 2 average prices, got %d (%v)", len(avgs), err)
+	}
+	if avgs[1].Price != "100.02" || avgs[1].Interval != "5m" || avgs[1].LastTradeTime != 1700000001999 || avgs[1].ConnID == "" {
+		t.Errorf("unexpected average price %+v", avgs[1])
+	}
+}
+
+func TestRun_MultiplexesStreamsOverOneConnection(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("muxausdt@trade",
+		mockbinance.TradeMessage("MUXAUSDT", 1, "1.0", "1"),
+		mockbinance.TradeMessage("MUXAUSDT", 2, "1.1", "1"))
+	srv.SetStream("muxausdt@bookTicker", mockbinance.BookTickerMessage("MUXAUSDT", 1, "0.9", "1", "1.1", "1"))
+	srv.SetStream("muxbusdt@trade", mockbinance.TradeMessage("MUXBUSDT", 1, "2.0", "1"))
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"MUXAUSDT", "MUXBUSDT"}
+	cfg.Streams = map[string][]string{
+		"MUXAUSDT": {StreamTrade, StreamBookTicker},
+		"MUXBUSDT": {StreamTrade},
+	}
+	cfg.MultiplexStreams = true
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rows := make(map[string]int64)
+		for _, s := range Introspect().Recorders {
+			rows[s.Instrument+"/"+s.DataType] = s.Rows
+		}
+		if rows["MUXAUSDT/trade"] == 2 && rows["MUXAUSDT/bestPrice"] == 1 && rows["MUXBUSDT/trade"] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for the multiplexed streams to be recorded, got %v", rows)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	trades, err := ReadParquetFile[Trade](BuildFileName("trade", "MUXBUSDT", NowFunc()))
+	if err != nil || len(trades) != 1 || trades[0].Price != "2.0" {
+		t.Fatalf("expected MUXBUSDT's trade in its own file, got %+v (%v)", trades, err)
+	}
+	for _, stats := range WebSocketStats() {
+		if strings.HasPrefix(stats.Stream, "mux") {
+			t.Errorf("expected no per-stream connections, got %s", stats.Stream)
+		}
+	}
+}
+
+func TestRun_DemultiplexesAllBookTickers(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream(AllBookTickersStream,
+		mockbinance.BookTickerMessage("ALLAUSDT", 1, "0.9", "1", "1.1", "1"),
+		mockbinance.BookTickerMessage("OTHERUSDT", 2, "5", "1", "6", "1"),
+		mockbinance.BookTickerMessage("ALLBUSDT", 3, "1.9", "1", "2.1", "1"),
+		mockbinance.BookTickerMessage("ALLAUSDT", 4, "0.95", "1", "1.1", "1"))
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"ALLAUSDT", "ALLBUSDT"}
+	cfg.Streams = map[string][]string{"ALLAUSDT": {StreamBookTicker}, "ALLBUSDT": {StreamBookTicker}}
+	cfg.AllBookTickers = true
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rows := make(map[string]int64)
+		for _, s := range Introspect().Recorders {
+			rows[s.Instrument+"/"+s.DataType] = s.Rows
+		}
+		if rows["ALLAUSDT/bestPrice"] == 2 && rows["ALLBUSDT/bestPrice"] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for the book tickers to be recorded, got %v", rows)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	prices, err := ReadParquetFile[BestPrice](BuildFileName("bestPrice", "ALLAUSDT", NowFunc()))
+	if err != nil || len(prices) != 2 || prices[0].UpdateID != 1 || prices[1].UpdateID != 4 {
+		t.Fatalf("expected ALLAUSDT's book tickers in its own file, got %+v (%v)", prices, err)
+	}
+	if srv.Connections(AllBookTickersStream) != 1 || srv.Connections("allausdt@bookTicker") != 0 {
+		t.Errorf("expected one all-market connection and none per symbol")
+	}
+}
+
+func TestRun_ArchivesRawFrames(t *testing.T) {
+	srv := useMockServer(t)
+	frame := mockbinance.TradeMessage("RAWAUSDT", 1, "1.0", "1")
+	srv.SetStream("rawausdt@trade", frame, []byte(`{"e":"trade","E":"not a number"}`))
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"RAWAUSDT"}
+	cfg.Streams = map[string][]string{"RAWAUSDT": {StreamTrade}}
+	cfg.ArchiveRaw = true
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rows := make(map[string]int64)
+		for _, s := range Introspect().Recorders {
+			rows[s.Instrument+"/"+s.DataType] = s.Rows
+		}
+		if rows["RAWAUSDT/trade"] == 1 && rows["RAWAUSDT/raw"] == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for the raw frames to be archived, got %v", rows)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	// The frame that fails to decode is archived all the same
+	raw, err := ReadParquetFile[RawMessage](BuildFileName("raw", "RAWAUSDT", NowFunc()))
+	if err != nil || len(raw) != 2 {
+		t.Fatalf("expected 2 archived frames, got %+v (%v)", raw, err)
+	}
+	if raw[0].Stream != "rawausdt@trade" || raw[0].Payload != string(frame) || raw[0].ReceiveTime == 0 || raw[0].ConnID == "" {
+		t.Errorf("expected the first frame untouched with its stream and session, got %+v", raw[0])
+	}
+	if !strings.Contains(raw[1].Payload, "not a number") {
+		t.Errorf("expected the undecodable frame to be archived, got %+v", raw[1])
+	}
+}
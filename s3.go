@@ -0,0 +1,318 @@
+package gobinapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Part sizes of S3 multipart uploads. S3 requires every part but the last to be at least MinS3PartSize.
+const (
+	DefaultS3PartSize = 64 << 20
+	MinS3PartSize     = 5 << 20
+)
+
+// S3Config configures the S3 upload backend.
+type S3Config struct {
+	Bucket string `json:"bucket" yaml:"bucket"`
+	// Prefix is prepended to every object key, e.g. "binance/"; the rest of the key is the file's path below the
+	// output directory.
+	Prefix string `json:"prefix,omitempty" yaml:"prefix"`
+	Region string `json:"region" yaml:"region"`
+	// Endpoint replaces the AWS endpoint, e.g. http://localhost:9000 for MinIO. Objects are then addressed
+	// path-style, as <endpoint>/<bucket>/<key>.
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint"`
+	// AccessKeyID, SecretAccessKey and SessionToken default to the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN environment variables.
+	AccessKeyID     string `json:"-" yaml:"-"`
+	SecretAccessKey string `json:"-" yaml:"-"`
+	SessionToken    string `json:"-" yaml:"-"`
+	// PartSize is the part size of multipart uploads, which are used for files larger than one part. Defaults to
+	// DefaultS3PartSize; it must be at least MinS3PartSize.
+	PartSize int64 `json:"part_size,omitempty" yaml:"part_size"`
+}
+
+// S3Uploader is an Uploader storing files in an S3 bucket, signed with AWS Signature Version 4. Files larger than
+// one part are uploaded with a multipart upload, which is aborted again if a part fails. Uploads overwrite the
+// object, so they are idempotent as the UploadQueue requires.
+type S3Uploader struct {
+	cfg    S3Config
+	root   string
+	client *http.Client
+}
+
+// NewS3Uploader creates an uploader for cfg. Object keys are the uploaded files' paths relative to root, the
+// recorder's output directory.
+func NewS3Uploader(cfg S3Config, root string, client *http.Client) (*S3Uploader, error) {
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, errors.New("s3: bucket and region are required")
+	}
+	if cfg.AccessKeyID == "" && cfg.SecretAccessKey == "" {
+		cfg.AccessKeyID, cfg.SecretAccessKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3: no credentials configured")
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = DefaultS3PartSize
+	}
+	if cfg.PartSize < MinS3PartSize {
+		return nil, fmt.Errorf("s3: part size must be at least %d bytes, got %d", MinS3PartSize, cfg.PartSize)
+	}
+	return &S3Uploader{cfg: cfg, root: root, client: client}, nil
+}
+
+// Key returns the object key filePath is uploaded to.
+func (u *S3Uploader) Key(filePath string) string {
+	return u.cfg.Prefix + remotePath(u.root, filePath)
+}
+
+// remotePath is a pure function returning the slash-separated path of filePath below root, or its base name if it
+// is not below root.
+func remotePath(root, filePath string) string {
+	if root == "" {
+		root = "."
+	}
+	rel, err := filepath.Rel(root, filePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(filePath)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Upload stores filePath under its key.
+func (u *S3Uploader) Upload(ctx context.Context, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	key := u.Key(filePath)
+	if info.Size() <= u.cfg.PartSize {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filePath, err)
+		}
+		if _, _, err := u.do(ctx, http.MethodPut, key, nil, data); err != nil {
+			return fmt.Errorf("s3: failed to upload %s: %w", key, err)
+		}
+		return nil
+	}
+	return u.uploadMultipart(ctx, key, f)
+}
+
+// uploadMultipart uploads r in parts of PartSize, aborting the upload if any step fails.
+func (u *S3Uploader) uploadMultipart(ctx context.Context, key string, r io.Reader) error {
+	body, _, err := u.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return fmt.Errorf("s3: failed to start multipart upload of %s: %w", key, err)
+	}
+	var started struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &started); err != nil || started.UploadID == "" {
+		return fmt.Errorf("s3: unexpected multipart upload response for %s: %s", key, body)
+	}
+
+	err = u.uploadParts(ctx, key, started.UploadID, r)
+	if err != nil {
+		if _, _, abortErr := u.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {started.UploadID}}, nil); abortErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to abort the upload: %w", abortErr))
+		}
+		return fmt.Errorf("s3: multipart upload of %s failed: %w", key, err)
+	}
+	return nil
+}
+
+// s3CompletedPart is a part listed in a CompleteMultipartUpload request.
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (u *S3Uploader) uploadParts(ctx context.Context, key, uploadID string, r io.Reader) error {
+	var completed struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}
+	buf := make([]byte, u.cfg.PartSize)
+	for number := 1; ; number++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		query := url.Values{"partNumber": {fmt.Sprint(number)}, "uploadId": {uploadID}}
+		_, header, err := u.do(ctx, http.MethodPut, key, query, buf[:n])
+		if err != nil {
+			return fmt.Errorf("part %d: %w", number, err)
+		}
+		completed.Parts = append(completed.Parts, s3CompletedPart{PartNumber: number, ETag: header.Get("ETag")})
+		if n < len(buf) {
+			break
+		}
+	}
+	data, err := xml.Marshal(completed)
+	if err != nil {
+		return err
+	}
+	body, _, err := u.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, data)
+	if err != nil {
+		return fmt.Errorf("failed to complete: %w", err)
+	}
+	// S3 may report a failed completion with a 200 status and an error document
+	if bytes.Contains(body, []byte("<Error>")) {
+		return fmt.Errorf("failed to complete: %s", body)
+	}
+	return nil
+}
+
+// do sends a signed request for key and returns the response body and headers, or an error for a non-2xx status.
+func (u *S3Uploader) do(ctx context.Context, method, key string, query url.Values, payload []byte) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.objectURL(key, query), bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, err
+	}
+	if payload == nil {
+		req.Body, req.ContentLength = http.NoBody, 0
+	}
+	SignS3Request(req, payload, u.cfg, NowFunc())
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+	}
+	return body, resp.Header, nil
+}
+
+// objectURL returns the URL of key: virtual-hosted style on AWS, path-style on a custom endpoint.
+func (u *S3Uploader) objectURL(key string, query url.Values) string {
+	path := "/" + s3Escape(key, false)
+	base := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", u.cfg.Bucket, u.cfg.Region)
+	if u.cfg.Endpoint != "" {
+		base = strings.TrimSuffix(u.cfg.Endpoint, "/")
+		path = "/" + s3Escape(u.cfg.Bucket, true) + path
+	}
+	if len(query) > 0 {
+		return base + path + "?" + canonicalQuery(query)
+	}
+	return base + path
+}
+
+// SignS3Request adds AWS Signature Version 4 headers for cfg's credentials and region to req, whose body is
+// payload. Every header already set on req is signed along with the host.
+func SignS3Request(req *http.Request, payload []byte, cfg S3Config, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256.Sum256(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := day + "/" + cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretAccessKey), day)
+	for _, part := range []string{cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key, as Signature Version 4 requires.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes s as Signature Version 4 requires: everything but unreserved characters, and slashes
+// unless escapeSlash is false.
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
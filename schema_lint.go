@@ -0,0 +1,228 @@
+package gobinapi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RecordedTypes returns a prototype of every struct written to parquet by the recorder. Types added to the
+// recording pipelines should be listed here so LintRecordedTypes covers them.
+func RecordedTypes() []interface{} {
+	return []interface{}{
+		new(Trade),
+		new(AggTrade),
+		new(OrderBookDiff),
+		new(BestPrice),
+		new(OrderBookSnapshot),
+		new(BookTop),
+		new(MarkPrice),
+		new(AvgPrice),
+		new(Ticker24h),
+		new(OpenInterest),
+		new(OpenInterestHist),
+		new(LongShortRatio),
+		new(OptionTrade),
+		new(OptionTicker),
+		new(OptionMarkPrice),
+		new(OptionOpenInterest),
+		new(ExecutionReport),
+		new(AccountBalance),
+		new(BalanceUpdate),
+		new(DailySummary),
+		new(Bar),
+		new(MidPrice),
+		new(BookFeatures),
+		new(RawMessage),
+		new(Kline),
+		new(SymbolInfo),
+	}
+}
+
+// LintRecordedTypes runs LintSchema over every recorded type. Run calls it at startup, so a malformed tag on a new
+// field fails fast instead of producing an unreadable or silently incomplete file.
+func LintRecordedTypes() error {
+	var errs []error
+	for _, prototype := range RecordedTypes() {
+		if err := LintSchema(prototype); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parquetPhysicalTypes maps Go kinds to the parquet physical types they can be written as.
+var parquetPhysicalTypes = map[reflect.Kind][]string{
+	reflect.Bool:    {"BOOLEAN"},
+	reflect.Int32:   {"INT32"},
+	reflect.Int64:   {"INT64"},
+	reflect.Float32: {"FLOAT"},
+	reflect.Float64: {"DOUBLE"},
+	reflect.String:  {"BYTE_ARRAY", "FIXED_LEN_BYTE_ARRAY"},
+}
+
+// LintSchema checks the struct tags of a recorded type (a struct or pointer to one), including nested structs:
+//   - every field carries a parquet tag made of key=value pairs with a name and either a type matching the Go
+//     type or, for slices, repetitiontype=REPEATED;
+//   - parquet names are unique within a struct;
+//   - if any field carries a json tag, every field does, and json names are unique within the struct;
+//   - decimal:"true" (see NumbersDecimal) only marks string fields, timestamp:"millis" (see TimestampsMillis) only
+//     int64 ones.
+//
+// Fields whose json tag is "-" are not recorded from JSON and are exempt from the json checks.
+func LintSchema(prototype interface{}) error {
+	t := reflect.TypeOf(prototype)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("schema lint: %T is not a struct", prototype)
+	}
+	var errs []error
+	lintStruct(t, map[reflect.Type]bool{}, &errs)
+	return errors.Join(errs...)
+}
+
+func lintStruct(t reflect.Type, seen map[reflect.Type]bool, errs *[]error) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	typeName := t.Name()
+	if typeName == "" {
+		typeName = t.String()
+	}
+	fail := func(field string, format string, args ...interface{}) {
+		*errs = append(*errs, fmt.Errorf("schema lint: %s.%s: %s", typeName, field, fmt.Sprintf(format, args...)))
+	}
+
+	usesJSON := false
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("json"); ok {
+			usesJSON = true
+		}
+	}
+
+	parquetNames := map[string]string{}
+	jsonNames := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup("parquet")
+		if !ok || strings.TrimSpace(tag) == "" {
+			fail(f.Name, "missing parquet tag")
+		} else if kv, err := parseParquetTag(tag); err != nil {
+			fail(f.Name, "malformed parquet tag %q: %v", tag, err)
+		} else {
+			name := kv["name"]
+			if name == "" {
+				fail(f.Name, "parquet tag %q has no name", tag)
+			} else if other, dup := parquetNames[strings.ToLower(name)]; dup {
+				fail(f.Name, "parquet name %q already used by %s", name, other)
+			} else {
+				parquetNames[strings.ToLower(name)] = f.Name
+			}
+			lintParquetType(f, kv, fail)
+		}
+		if decimal, ok := f.Tag.Lookup("decimal"); ok && (decimal != "true" || f.Type.Kind() != reflect.String) {
+			fail(f.Name, `decimal tag must be "true" on a string field`)
+		}
+		if timestamp, ok := f.Tag.Lookup("timestamp"); ok && (timestamp != "millis" || f.Type.Kind() != reflect.Int64) {
+			fail(f.Name, `timestamp tag must be "millis" on an int64 field`)
+		}
+
+		elem := f.Type
+		for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Slice {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct {
+			lintStruct(elem, seen, errs)
+		}
+
+		if !usesJSON {
+			continue
+		}
+		jsonTag, ok := f.Tag.Lookup("json")
+		jsonName := strings.Split(jsonTag, ",")[0]
+		switch {
+		case jsonTag == "-":
+		case !ok || jsonName == "":
+			fail(f.Name, "missing json tag while other fields have one")
+		default:
+			if other, dup := jsonNames[jsonName]; dup {
+				fail(f.Name, "json name %q already used by %s", jsonName, other)
+			} else {
+				jsonNames[jsonName] = f.Name
+			}
+		}
+	}
+}
+
+// lintParquetType checks that a field's parquet type or repetition type fits its Go type.
+func lintParquetType(f reflect.StructField, kv map[string]string, fail func(string, string, ...interface{})) {
+	typ := f.Type
+	repetition := strings.ToUpper(kv["repetitiontype"])
+	switch typ.Kind() {
+	case reflect.Slice:
+		if repetition != "REPEATED" {
+			fail(f.Name, "slice field needs repetitiontype=REPEATED")
+			return
+		}
+		typ = typ.Elem()
+	case reflect.Ptr:
+		if repetition != "OPTIONAL" {
+			fail(f.Name, "pointer field needs repetitiontype=OPTIONAL")
+			return
+		}
+		typ = typ.Elem()
+	default:
+		if repetition == "REPEATED" {
+			fail(f.Name, "repetitiontype=REPEATED on a non-slice field")
+			return
+		}
+	}
+	if typ.Kind() == reflect.Struct {
+		if kv["type"] != "" {
+			fail(f.Name, "struct field must not declare a parquet type, got %s", kv["type"])
+		}
+		return
+	}
+	allowed, ok := parquetPhysicalTypes[typ.Kind()]
+	if !ok {
+		fail(f.Name, "unsupported Go type %s", typ)
+		return
+	}
+	got := strings.ToUpper(kv["type"])
+	for _, want := range allowed {
+		if got == want {
+			return
+		}
+	}
+	if got == "" {
+		fail(f.Name, "parquet tag has no type")
+	} else {
+		fail(f.Name, "parquet type %s does not match Go type %s (want %s)", got, typ, strings.Join(allowed, " or "))
+	}
+}
+
+// parseParquetTag splits a parquet struct tag such as "name=price, type=BYTE_ARRAY" into lower-cased keys and values.
+func parseParquetTag(tag string) (map[string]string, error) {
+	kv := make(map[string]string)
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		key, value, ok := strings.Cut(part, "=")
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" || strings.Contains(value, "=") {
+			return nil, fmt.Errorf("expected comma-separated key=value pairs, got %q", part)
+		}
+		if _, dup := kv[key]; dup {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		kv[key] = value
+	}
+	return kv, nil
+}
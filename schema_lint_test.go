@@ -0,0 +1,69 @@
+package gobinapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// assertSchemaValid is the test helper form of LintSchema.
+func assertSchemaValid(t *testing.T, prototype interface{}) {
+	t.Helper()
+	if err := LintSchema(prototype); err != nil {
+		t.Errorf("%T has schema problems:\n%v", prototype, err)
+	}
+}
+
+func TestRecordedTypesPassSchemaLint(t *testing.T) {
+	for _, prototype := range RecordedTypes() {
+		assertSchemaValid(t, prototype)
+	}
+}
+
+func TestLintSchemaRejectsMalformedTags(t *testing.T) {
+	type nested struct {
+		Price string `parquet:"name=price"`
+	}
+	cases := []struct {
+		prototype interface{}
+		want      string
+	}{
+		{new(struct {
+			A int64 `json:"a"`
+		}), "missing parquet tag"},
+		{new(struct {
+			A int64 `json:"a" parquet:"name=a type=INT64"`
+		}), "malformed parquet tag"},
+		{new(struct {
+			A int64 `json:"a" parquet:"type=INT64"`
+		}), "has no name"},
+		{new(struct {
+			A int64 `json:"a" parquet:"name=x, type=INT64"`
+			B int64 `json:"b" parquet:"name=x, type=INT64"`
+		}), `parquet name "x" already used by A`},
+		// Built with reflect because go vet rejects duplicate json tags in source
+		{reflect.New(reflect.StructOf([]reflect.StructField{
+			{Name: "A", Type: reflect.TypeOf(int64(0)), Tag: `json:"a" parquet:"name=a, type=INT64"`},
+			{Name: "B", Type: reflect.TypeOf(int64(0)), Tag: `json:"a" parquet:"name=b, type=INT64"`},
+		})).Interface(), `json name "a" already used by A`},
+		{new(struct {
+			A int64 `json:"a" parquet:"name=a, type=INT64"`
+			B int64 `parquet:"name=b, type=INT64"`
+		}), "missing json tag"},
+		{new(struct {
+			A string `parquet:"name=a, type=INT64"`
+		}), "does not match Go type string"},
+		{new(struct {
+			A []nested `parquet:"name=a"`
+		}), "needs repetitiontype=REPEATED"},
+		{new(struct {
+			A []nested `parquet:"name=a, repetitiontype=REPEATED"`
+		}), "nested.Price: parquet tag has no type"},
+	}
+	for _, c := range cases {
+		err := LintSchema(c.prototype)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%T: expected error containing %q, got %v", c.prototype, c.want, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package gobinapi
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// SessionInfo describes a single run of the recorder. It is written as a JSON file next to the recorded data at
+// startup and rewritten on clean shutdown, so every data file can be traced back to the run and configuration that
+// produced it.
+type SessionInfo struct {
+	RunID         string     `json:"run_id"`
+	StartTime     time.Time  `json:"start_time"`
+	EndTime       *time.Time `json:"end_time,omitempty"`
+	ConfigHash    string     `json:"config_hash"`
+	GitVersion    string     `json:"git_version"`
+	Host          string     `json:"host"`
+	Symbols       []string   `json:"symbols"`
+	CleanShutdown bool       `json:"clean_shutdown"`
+	// Connections lists the WebSocket connections of the run and the endpoints that served them, filled in at
+	// shutdown, so a data anomaly can be traced from a record's ConnID to the endpoint it came from.
+	Connections []SessionConnection `json:"connections,omitempty"`
+}
+
+// SessionConnection is one WebSocket connection of a run.
+type SessionConnection struct {
+	ConnID      string    `json:"conn_id"`
+	Generation  int64     `json:"conn_generation"`
+	Stream      string    `json:"stream"`
+	Endpoint    string    `json:"endpoint"`
+	Address     string    `json:"address"`
+	ConnectTime time.Time `json:"connect_time"`
+}
+
+// NewSessionInfo creates a SessionInfo for a run starting at the given time. The config value is hashed (via its JSON
+// encoding) so that two runs with identical configuration produce the same ConfigHash.
+func NewSessionInfo(symbols []string, config interface{}, start time.Time) (*SessionInfo, error) {
+	hash, err := ConfigHash(config)
+	if err != nil {
+		return nil, err
+	}
+	runID, err := NewRunID()
+	if err != nil {
+		return nil, err
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &SessionInfo{
+		RunID:      runID,
+		StartTime:  start.UTC(),
+		ConfigHash: hash,
+		GitVersion: GitVersion(),
+		Host:       host,
+		Symbols:    append([]string(nil), symbols...),
+	}, nil
+}
+
+// ConfigHash is a pure function returning the hex encoded SHA-256 of the JSON encoding of config.
+func ConfigHash(config interface{}) (string, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode config for hashing: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// NewRunID returns a random 16 byte hex identifier for a recorder run.
+func NewRunID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate run id: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// GitVersion returns the VCS revision embedded in the binary at build time, suffixed with "-dirty" when the working
+// tree had local modifications. It returns "unknown" when no build information is available (e.g. under go test).
+func GitVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision := ""
+	modified := false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "unknown"
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// BuildSessionFileName constructs the session file name for a run, based on its UTC start time and run ID.
+// For example: session_2023-10-15T12-34-56Z_<runID>.json
+func BuildSessionFileName(start time.Time, runID string) string {
+	return fmt.Sprintf("session_%s_%s.json", start.UTC().Format("2006-01-02T15-04-05Z"), runID)
+}
+
+// WriteSessionFile writes the session info as indented JSON to filePath. The file is written to a temporary file
+// first and then renamed, so a crash mid-write never leaves a truncated session file behind.
+func WriteSessionFile(filePath string, info *SessionInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session info: %w", err)
+	}
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write session file %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename session file to %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// MarkCleanShutdown records the end time of the run and flags it as having shut down cleanly.
+func (s *SessionInfo) MarkCleanShutdown(end time.Time) {
+	endUTC := end.UTC()
+	s.EndTime = &endUTC
+	s.CleanShutdown = true
+}
+
+// ReadSessionFile reads a session file previously written by WriteSessionFile.
+func ReadSessionFile(filePath string) (*SessionInfo, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var info SessionInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse session file %s: %w", filePath, err)
+	}
+	return &info, nil
+}
@@ -0,0 +1,81 @@
+package gobinapi
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigHashIsDeterministic(t *testing.T) {
+	type cfg struct {
+		Instruments []string `json:"instruments"`
+		BatchSize   int      `json:"batch_size"`
+	}
+	h1, err := ConfigHash(cfg{[]string{"BTCUSDT"}, 1})
+	if err != nil {
+		t.Fatalf("ConfigHash returned error: %v", err)
+	}
+	h2, _ := ConfigHash(cfg{[]string{"BTCUSDT"}, 1})
+	h3, _ := ConfigHash(cfg{[]string{"ETHUSDT"}, 1})
+	if h1 != h2 {
+		t.Errorf("expected identical configs to hash equally, got %s and %s", h1, h2)
+	}
+	if h1 == h3 {
+		t.Errorf("expected different configs to hash differently, both got %s", h1)
+	}
+	if len(h1) != 64 {
+		t.Errorf("expected 64 hex characters, got %d", len(h1))
+	}
+}
+
+func TestBuildSessionFileName(t *testing.T) {
+	start := time.Date(2023, time.October, 15, 12, 34, 56, 0, time.UTC)
+	expected := "session_2023-10-15T12-34-56Z_abc123.json"
+	if actual := BuildSessionFileName(start, "abc123"); actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestWriteSessionFileRoundTrip(t *testing.T) {
+	start := time.Date(2023, time.October, 15, 12, 0, 0, 0, time.UTC)
+	info, err := NewSessionInfo([]string{"BTCUSDT", "ETHUSDT"}, map[string]int{"batch_size": 1}, start)
+	if err != nil {
+		t.Fatalf("NewSessionInfo returned error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), BuildSessionFileName(info.StartTime, info.RunID))
+	if err := WriteSessionFile(path, info); err != nil {
+		t.Fatalf("WriteSessionFile returned error: %v", err)
+	}
+
+	read, err := ReadSessionFile(path)
+	if err != nil {
+		t.Fatalf("ReadSessionFile returned error: %v", err)
+	}
+	if read.RunID != info.RunID || read.ConfigHash != info.ConfigHash || !read.StartTime.Equal(start) {
+		t.Errorf("session mismatch: wrote %+v, read %+v", info, read)
+	}
+	if read.EndTime != nil || read.CleanShutdown {
+		t.Errorf("expected no end time before shutdown, got %+v", read)
+	}
+	if strings.Join(read.Symbols, ",") != "BTCUSDT,ETHUSDT" {
+		t.Errorf("unexpected symbols: %v", read.Symbols)
+	}
+
+	// Simulate a clean shutdown and check that the file is updated in place
+	end := start.Add(time.Hour)
+	info.MarkCleanShutdown(end)
+	if err := WriteSessionFile(path, info); err != nil {
+		t.Fatalf("WriteSessionFile on shutdown returned error: %v", err)
+	}
+	read, err = ReadSessionFile(path)
+	if err != nil {
+		t.Fatalf("ReadSessionFile after shutdown returned error: %v", err)
+	}
+	if !read.CleanShutdown || read.EndTime == nil || !read.EndTime.Equal(end) {
+		t.Errorf("expected clean shutdown at %v, got %+v", end, read)
+	}
+	if FileExists(path + ".tmp") {
+		t.Errorf("temporary session file was left behind")
+	}
+}
@@ -0,0 +1,272 @@
+package gobinapi
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Security is the kind of authentication an endpoint requires, following the exchange's endpoint security types.
+type Security int
+
+const (
+	// SecurityNone is for public endpoints like market data.
+	SecurityNone Security = iota
+	// SecurityAPIKey sends the API key header only, as the USER_STREAM endpoints and some historical market data
+	// endpoints require.
+	SecurityAPIKey
+	// SecuritySigned additionally sends a timestamp, the recvWindow and a signature of the parameters, as the
+	// TRADE and USER_DATA endpoints require.
+	SecuritySigned
+)
+
+// DefaultRecvWindow is how long after its timestamp a signed request stays valid by default; the exchange allows at
+// most 60 seconds.
+const DefaultRecvWindow = 5 * time.Second
+
+// serverTimeWeight is the request weight of the server time endpoints.
+const serverTimeWeight = 1
+
+// errCodeTimestamp is the exchange's error code for a timestamp outside the recvWindow or ahead of its clock.
+const errCodeTimestamp = -1021
+
+// Credentials authenticate requests to the exchange: an API key and, for signed requests, either the HMAC secret
+// key generated with it or the Ed25519 private key whose public key was registered with it.
+type Credentials struct {
+	APIKey     string
+	Secret     string
+	PrivateKey ed25519.PrivateKey
+}
+
+// CredentialsFromEnv reads the API key from BINANCE_API_KEY and either the HMAC secret key from BINANCE_API_SECRET
+// or the path of a PEM encoded Ed25519 private key from BINANCE_PRIVATE_KEY_FILE. Both may be absent, for keys
+// used on SecurityAPIKey endpoints only, but not both present.
+func CredentialsFromEnv() (Credentials, error) {
+	creds := Credentials{APIKey: os.Getenv("BINANCE_API_KEY"), Secret: os.Getenv("BINANCE_API_SECRET")}
+	if creds.APIKey == "" {
+		return Credentials{}, errors.New("BINANCE_API_KEY is not set")
+	}
+	if path := os.Getenv("BINANCE_PRIVATE_KEY_FILE"); path != "" {
+		if creds.Secret != "" {
+			return Credentials{}, errors.New("set either BINANCE_API_SECRET or BINANCE_PRIVATE_KEY_FILE, not both")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to read private key: %w", err)
+		}
+		if creds.PrivateKey, err = ParseEd25519PrivateKey(data); err != nil {
+			return Credentials{}, err
+		}
+	}
+	return creds, nil
+}
+
+// ParseEd25519PrivateKey parses a PEM encoded PKCS #8 Ed25519 private key, as generated by
+// "openssl genpkey -algorithm ed25519".
+func ParseEd25519PrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is a %T, not an Ed25519 key", key)
+	}
+	return ed, nil
+}
+
+// CanSign reports whether the credentials hold a secret or private key to sign requests with.
+func (c Credentials) CanSign() bool {
+	return c.Secret != "" || c.PrivateKey != nil
+}
+
+// Sign returns the signature of payload, the encoded query string of a request: the hex encoded HMAC-SHA256 with
+// the secret key, or the base64 encoded Ed25519 signature with the private key.
+func (c Credentials) Sign(payload string) string {
+	if c.PrivateKey != nil {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(c.PrivateKey, []byte(payload)))
+	}
+	return hex.EncodeToString(hmacSHA256([]byte(c.Secret), payload))
+}
+
+// APIError is an error response of the REST API, like {"code":-2010,"msg":"Account has insufficient balance"}.
+type APIError struct {
+	Status int
+	Code   int
+	Msg    string
+	// Backoff is how long the exchange asked to wait before the next request after a 429 (rate limited) or 418
+	// (IP banned) response, during which the weight tracker holds up every request.
+	Backoff time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error %d (status %d): %s", e.Code, e.Status, e.Msg)
+}
+
+// SignedClient sends authenticated requests to the REST API of a market: it adds the API key header and, for signed
+// requests, the timestamp, recvWindow and signature, correcting the timestamp by the offset of the exchange's clock
+// measured by SyncTime. Requests are paced through a WeightTracker.
+type SignedClient struct {
+	client     *http.Client
+	market     string
+	creds      Credentials
+	tracker    *WeightTracker
+	recvWindow time.Duration
+	// offset is the exchange's clock minus the local clock, in milliseconds
+	offset atomic.Int64
+}
+
+// NewSignedClient creates a client for market (MarketSpot or MarketUSDM) whose requests share the weight budget of
+// tracker, usually DefaultWeightTracker.
+func NewSignedClient(client *http.Client, market string, creds Credentials, tracker *WeightTracker) *SignedClient {
+	return &SignedClient{client: client, market: market, creds: creds, tracker: tracker, recvWindow: DefaultRecvWindow}
+}
+
+// SetRecvWindow sets how long after its timestamp a signed request stays valid, at most 60 seconds.
+func (c *SignedClient) SetRecvWindow(d time.Duration) {
+	c.recvWindow = d
+}
+
+// TimeOffset returns the offset of the exchange's clock from the local one measured by the last SyncTime.
+func (c *SignedClient) TimeOffset() time.Duration {
+	return time.Duration(c.offset.Load()) * time.Millisecond
+}
+
+// SyncTime measures the offset of the exchange's clock from the local one, assuming the server time was taken
+// halfway through the request. Do calls it when the exchange rejects a timestamp; calling it up front avoids that
+// first rejection on hosts with a skewed clock.
+func (c *SignedClient) SyncTime(ctx context.Context) error {
+	path := "/api/v3/time"
+	if c.market == MarketUSDM {
+		path = "/fapi/v1/time"
+	}
+	start := NowFunc()
+	var resp struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := c.Do(ctx, http.MethodGet, path, nil, SecurityNone, serverTimeWeight, &resp); err != nil {
+		return fmt.Errorf("failed to sync time: %w", err)
+	}
+	end := NowFunc()
+	midpoint := start.Add(end.Sub(start) / 2)
+	c.offset.Store(resp.ServerTime - midpoint.UnixMilli())
+	return nil
+}
+
+// Do sends a request of method to path (e.g. "/api/v3/account") on the market's REST base with params, secured as
+// security, and decodes the JSON response into out unless out is nil. It waits for weight in the tracker's queue;
+// a rejected timestamp is retried once after SyncTime. A 429 response is retried once its backoff has passed only
+// for GET requests, as resending an order could place it twice; otherwise it is returned as an *APIError carrying
+// the backoff, wrapping ErrIPBanned too for a 418. Error responses are returned as *APIError.
+func (c *SignedClient) Do(ctx context.Context, method, path string, params url.Values, security Security, weight int, out interface{}) error {
+	if security != SecurityNone && c.creds.APIKey == "" {
+		return fmt.Errorf("%s %s: an API key is required", method, path)
+	}
+	if security == SecuritySigned && !c.creds.CanSign() {
+		return fmt.Errorf("%s %s: a secret or private key is required", method, path)
+	}
+	synced, retried := false, false
+	for {
+		body, status, backoff, err := c.send(ctx, method, path, params, security, weight)
+		if err != nil {
+			return err
+		}
+		if status == http.StatusTooManyRequests && method == http.MethodGet && !retried {
+			// The tracker holds up the retry until the backoff has passed
+			retried = true
+			continue
+		}
+		if status != http.StatusOK {
+			apiErr := &APIError{Status: status, Msg: strings.TrimSpace(string(body)), Backoff: backoff}
+			var payload struct {
+				Code int    `json:"code"`
+				Msg  string `json:"msg"`
+			}
+			if json.Unmarshal(body, &payload) == nil && payload.Code != 0 {
+				apiErr.Code, apiErr.Msg = payload.Code, payload.Msg
+			}
+			if apiErr.Code == errCodeTimestamp && security == SecuritySigned && !synced {
+				synced = true
+				if err := c.SyncTime(ctx); err != nil {
+					return err
+				}
+				continue
+			}
+			if status == http.StatusTeapot {
+				return fmt.Errorf("%s %s: %w: %w", method, path, ErrIPBanned, apiErr)
+			}
+			return fmt.Errorf("%s %s: %w", method, path, apiErr)
+		}
+		if out == nil {
+			return nil
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("failed to parse %s %s response: %w", method, path, err)
+		}
+		return nil
+	}
+}
+
+// send sends one attempt of a request and returns its body and status, with the backoff the exchange asked for if
+// it answered 429 or 418.
+func (c *SignedClient) send(ctx context.Context, method, path string, params url.Values, security Security, weight int) ([]byte, int, time.Duration, error) {
+	if err := c.tracker.Wait(ctx, weight); err != nil {
+		return nil, 0, 0, err
+	}
+	query := url.Values{}
+	for k, v := range params {
+		query[k] = v
+	}
+	if security == SecuritySigned {
+		query.Set("timestamp", strconv.FormatInt(NowFunc().UnixMilli()+c.offset.Load(), 10))
+		query.Set("recvWindow", strconv.FormatInt(c.recvWindow.Milliseconds(), 10))
+	}
+	encoded := query.Encode()
+	if security == SecuritySigned {
+		// The signature must come last and cover the query exactly as sent
+		encoded += "&signature=" + url.QueryEscape(c.creds.Sign(encoded))
+	}
+	endpoint := RESTBaseURL + path
+	if c.market == MarketUSDM {
+		endpoint = FuturesRESTBaseURL + path
+	}
+	if encoded != "" {
+		endpoint += "?" + encoded
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if security != SecurityNone {
+		req.Header.Set("X-MBX-APIKEY", c.creds.APIKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	backoff := c.tracker.ObserveResponse(resp, NowFunc())
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to read %s %s response: %w", method, path, err)
+	}
+	return body, resp.StatusCode, backoff, nil
+}
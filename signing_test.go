@@ -0,0 +1,180 @@
+package gobinapi
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCredentials_Sign(t *testing.T) {
+	// The example of the exchange's API documentation
+	creds := Credentials{APIKey: "vmPUZE6mv9SD5VNHk4HlWFsOr6aKE2zvsw0MuIgwCIPy6utIco14y7Ju91duEh8A",
+		Secret: "NhqPtmdSJYdKjVHjA7PZj4Mge3R5YNiP1e3UZjInClVN65XAbvqqM6A7H5fATj0j"}
+	payload := "symbol=LTCBTC&side=BUY&type=LIMIT&timeInForce=GTC&quantity=1&price=0.1&recvWindow=5000&timestamp=1499827319559"
+	if got := creds.Sign(payload); got != "c8db56825ae71d6d79447849e617115f4a920fa2acdcab2b053c4b2838bd6b71" {
+		t.Errorf("unexpected HMAC signature %s", got)
+	}
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BINANCE_API_KEY", "key")
+	t.Setenv("BINANCE_API_SECRET", "")
+	t.Setenv("BINANCE_PRIVATE_KEY_FILE", path)
+	creds, err = CredentialsFromEnv()
+	if err != nil {
+		t.Fatalf("failed to read the Ed25519 key: %v", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(creds.Sign(payload))
+	if err != nil || !ed25519.Verify(public, []byte(payload), signature) {
+		t.Errorf("expected a valid base64 Ed25519 signature (%v)", err)
+	}
+
+	t.Setenv("BINANCE_API_SECRET", "secret")
+	if _, err := CredentialsFromEnv(); err == nil {
+		t.Error("expected a secret and a private key together to be rejected")
+	}
+	if _, err := ParseEd25519PrivateKey([]byte("not a key")); err == nil {
+		t.Error("expected a key that is not PEM encoded to be rejected")
+	}
+}
+
+func TestSignedClient_CorrectsClockSkew(t *testing.T) {
+	now := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	oldNow := NowFunc
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = oldNow }()
+	// The exchange's clock runs 3 seconds ahead
+	serverTime := now.Add(3 * time.Second).UnixMilli()
+
+	creds := Credentials{APIKey: "key", Secret: "secret"}
+	var timestamps []int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/time" {
+			fmt.Fprintf(w, `{"serverTime":%d}`, serverTime)
+			return
+		}
+		query := r.URL.RawQuery
+		i := strings.LastIndex(query, "&signature=")
+		if r.Header.Get("X-MBX-APIKEY") != "key" || i < 0 || query[i+len("&signature="):] != creds.Sign(query[:i]) {
+			http.Error(w, `{"code":-1022,"msg":"Signature for this request is not valid."}`, http.StatusBadRequest)
+			return
+		}
+		ts, _ := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+		timestamps = append(timestamps, ts)
+		if ts < serverTime-1000 {
+			http.Error(w, `{"code":-1021,"msg":"Timestamp for this request is outside of the recvWindow."}`, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"balances":[]}`))
+	}))
+	defer srv.Close()
+	old := RESTBaseURL
+	RESTBaseURL = srv.URL
+	t.Cleanup(func() { RESTBaseURL = old })
+
+	c := NewSignedClient(srv.Client(), MarketSpot, creds, NewWeightTracker(DefaultRESTWeightLimit))
+	var account struct {
+		Balances []struct{} `json:"balances"`
+	}
+	params := url.Values{"omitZeroBalances": {"true"}}
+	if err := c.Do(context.Background(), http.MethodGet, "/api/v3/account", params, SecuritySigned, 20, &account); err != nil {
+		t.Fatalf("expected the request to succeed after syncing the time: %v", err)
+	}
+	if len(timestamps) != 2 || timestamps[1] != serverTime {
+		t.Errorf("expected the retry to carry the exchange's time %d, got %v", serverTime, timestamps)
+	}
+	if c.TimeOffset() != 3*time.Second {
+		t.Errorf("expected a 3s offset, got %s", c.TimeOffset())
+	}
+
+	err := NewSignedClient(srv.Client(), MarketSpot, Credentials{APIKey: "key", Secret: "wrong"}, NewWeightTracker(DefaultRESTWeightLimit)).
+		Do(context.Background(), http.MethodGet, "/api/v3/account", nil, SecuritySigned, 20, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -1022 || apiErr.Status != http.StatusBadRequest {
+		t.Errorf("expected the signature error as an APIError, got %v", err)
+	}
+	if err := NewSignedClient(srv.Client(), MarketSpot, Credentials{APIKey: "key"}, NewWeightTracker(DefaultRESTWeightLimit)).
+		Do(context.Background(), http.MethodGet, "/api/v3/account", nil, SecuritySigned, 20, nil); err == nil {
+		t.Error("expected a signed request without a secret to be refused")
+	}
+}
+
+func TestSignedClient_ResendsOnlyReadsAfterRateLimits(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method]++
+		n := requests[r.Method]
+		mu.Unlock()
+		switch {
+		case r.Method == http.MethodDelete:
+			w.Header().Set("Retry-After", "120")
+			http.Error(w, `{"code":-1003,"msg":"Way too many requests; IP banned."}`, http.StatusTeapot)
+		case n == 1 || r.Method == http.MethodPost:
+			w.Header().Set("Retry-After", "10")
+			http.Error(w, `{"code":-1003,"msg":"Too many requests."}`, http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+	clock := useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	oldURL, oldNow := RESTBaseURL, NowFunc
+	RESTBaseURL, NowFunc = srv.URL, clock.Now
+	defer func() { RESTBaseURL, NowFunc = oldURL, oldNow }()
+	c := NewSignedClient(srv.Client(), MarketSpot, Credentials{APIKey: "key", Secret: "secret"}, NewWeightTracker(DefaultRESTWeightLimit))
+	ctx := context.Background()
+
+	err := c.Do(ctx, http.MethodPost, "/api/v3/order", nil, SecuritySigned, 1, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusTooManyRequests || apiErr.Backoff != 10*time.Second {
+		t.Fatalf("expected the rate limit as an APIError carrying its backoff, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Do(ctx, http.MethodGet, "/api/v3/openOrders", nil, SecuritySigned, 6, nil) }()
+	// The first GET waits out the POST's backoff, the retry its own
+	waitForWaiters(t, clock, 1)
+	clock.Advance(10 * time.Second)
+	waitForWaiters(t, clock, 1)
+	clock.Advance(10 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the GET to succeed once the backoff had passed: %v", err)
+	}
+
+	err = c.Do(ctx, http.MethodDelete, "/api/v3/order", nil, SecuritySigned, 1, nil)
+	if !errors.Is(err, ErrIPBanned) || !errors.As(err, &apiErr) || apiErr.Backoff != 2*time.Minute {
+		t.Errorf("expected the ban as ErrIPBanned and an APIError carrying its backoff, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests[http.MethodPost] != 1 || requests[http.MethodGet] != 2 || requests[http.MethodDelete] != 1 {
+		t.Errorf("expected only the GET to be resent, got %v", requests)
+	}
+}
@@ -0,0 +1,290 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Sink is a destination for the records of one stream, like the parquet Recorder or an instrument's share of a
+// KafkaSink. Flush pushes buffered records on, Rotate starts a new file where that applies, Close flushes and releases
+// the sink once its stream has been drained, and Healthy reports why the sink is currently failing, if it is.
+type Sink[T any] interface {
+	RecorderWriter[T]
+	Flush() error
+	Rotate() error
+	Close() error
+	Healthy() error
+}
+
+// ParquetSinkName is the sink name of the parquet files, which unlike the sinks of a SinkRegistry are typed by
+// data type and opened by Run itself.
+const ParquetSinkName = "parquet"
+
+// FanOutSink writes every record to each of its Sinks. Like FanOutWriter, every sink is attempted even if one fails
+// and the first error is returned, for every method.
+type FanOutSink[T any] []Sink[T]
+
+// Write writes record to every underlying Sink.
+func (f FanOutSink[T]) Write(record T) error {
+	return f.each(func(s Sink[T]) error { return s.Write(record) })
+}
+
+// Flush flushes every underlying Sink.
+func (f FanOutSink[T]) Flush() error {
+	return f.each(Sink[T].Flush)
+}
+
+// Rotate rotates every underlying Sink.
+func (f FanOutSink[T]) Rotate() error {
+	return f.each(Sink[T].Rotate)
+}
+
+// Close closes every underlying Sink.
+func (f FanOutSink[T]) Close() error {
+	return f.each(Sink[T].Close)
+}
+
+// Healthy returns the first error reported by an underlying Sink.
+func (f FanOutSink[T]) Healthy() error {
+	return f.each(Sink[T].Healthy)
+}
+
+func (f FanOutSink[T]) each(call func(Sink[T]) error) error {
+	var firstErr error
+	for _, s := range f {
+		if err := call(s); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// untypedSink passes records of type T on to a sink accepting records of any type.
+type untypedSink[T any] struct {
+	Sink[any]
+}
+
+func (s untypedSink[T]) Write(record T) error {
+	return s.Sink.Write(record)
+}
+
+// UntypedSink adapts a sink accepting records of any type, like the sinks of a SinkRegistry, to records of type T,
+// e.g. to add it to a FanOutSink[T].
+func UntypedSink[T any](next Sink[any]) Sink[T] {
+	return untypedSink[T]{Sink: next}
+}
+
+// filteredSink writes through a filter in front of its Sink.
+type filteredSink[T any] struct {
+	Sink[T]
+	filter RecorderWriter[T]
+}
+
+func (s filteredSink[T]) Write(record T) error {
+	return s.filter.Write(record)
+}
+
+// FilteredSink returns next with its writes going through filter, which passes the records it keeps on to next,
+// e.g. a BestPriceChangeFilter in front of the parquet Recorder.
+func FilteredSink[T any](next Sink[T], filter RecorderWriter[T]) Sink[T] {
+	return filteredSink[T]{Sink: next, filter: filter}
+}
+
+// SharedSink is a sink shared by every instrument, like KafkaSink, which buffers records from all of them and
+// flushes them on its own schedule.
+type SharedSink interface {
+	ForSymbol(symbol string) RecorderWriter[any]
+	Flush(ctx context.Context) error
+	Healthy() error
+}
+
+// symbolSink is an instrument's share of a SharedSink.
+type symbolSink struct {
+	RecorderWriter[any]
+	shared SharedSink
+}
+
+func (s symbolSink) Flush() error {
+	return s.shared.Flush(context.Background())
+}
+
+func (s symbolSink) Rotate() error {
+	return nil
+}
+
+func (s symbolSink) Close() error {
+	return nil
+}
+
+func (s symbolSink) Healthy() error {
+	return s.shared.Healthy()
+}
+
+// SymbolSink returns the Sink of symbol's records in shared. Flush flushes the whole shared sink, while Rotate and
+// Close do nothing since the shared sink outlives every instrument and flushes itself when Run stops.
+func SymbolSink(shared SharedSink, symbol string) Sink[any] {
+	return symbolSink{RecorderWriter: shared.ForSymbol(symbol), shared: shared}
+}
+
+// registerSharedSink registers shared under name, opening each instrument's SymbolSink.
+func registerSharedSink(r *SinkRegistry, name string, shared SharedSink) {
+	r.Register(name, func(instrument string) (Sink[any], error) {
+		return SymbolSink(shared, instrument), nil
+	})
+}
+
+// sinkHealth remembers the outcome of a shared sink's last flush for its Healthy method.
+type sinkHealth struct {
+	mu      sync.Mutex
+	lastErr error
+}
+
+// record stores the outcome of a flush.
+func (h *sinkHealth) record(err error) {
+	h.mu.Lock()
+	h.lastErr = err
+	h.mu.Unlock()
+}
+
+// Healthy returns the error of the last flush, or nil if it succeeded.
+func (h *sinkHealth) Healthy() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastErr
+}
+
+// SinkFactory opens the sink of an instrument's records.
+type SinkFactory func(instrument string) (Sink[any], error)
+
+// SinkRegistry maps sink names, as used in Config.Sinks, to the factories opening them.
+type SinkRegistry struct {
+	mu        sync.RWMutex
+	factories map[string]SinkFactory
+}
+
+// NewSinkRegistry returns an empty SinkRegistry.
+func NewSinkRegistry() *SinkRegistry {
+	return &SinkRegistry{factories: make(map[string]SinkFactory)}
+}
+
+// DefaultSinks holds the sinks a program embedding the recorder adds to the built-in ones. Sinks registered before
+// Run is called may be named in Config.Sinks.
+var DefaultSinks = NewSinkRegistry()
+
+// Register makes factory available under name, replacing any factory registered before. The parquet files are
+// opened by Run and cannot be replaced.
+func (r *SinkRegistry) Register(name string, factory SinkFactory) error {
+	if name == "" || name == ParquetSinkName {
+		return fmt.Errorf("sink name %q is reserved", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[name] = factory
+	return nil
+}
+
+// Has reports whether a sink is registered under name.
+func (r *SinkRegistry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.factories[name]
+	return ok
+}
+
+// Names returns the registered sink names in order.
+func (r *SinkRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Open opens the sink registered under name for instrument.
+func (r *SinkRegistry) Open(name, instrument string) (Sink[any], error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q", name)
+	}
+	sink, err := factory(instrument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s sink for %s: %w", name, instrument, err)
+	}
+	return sink, nil
+}
+
+// clone returns a copy of the registry, to which Run adds the built-in sinks of its Config.
+func (r *SinkRegistry) clone() *SinkRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := NewSinkRegistry()
+	for name, factory := range r.factories {
+		c.factories[name] = factory
+	}
+	return c
+}
+
+// sinkDataTypes are the data types whose sinks Config.Sinks selects. The other data types are only recorded to
+// parquet files.
+var sinkDataTypes = []string{"trade", "aggTrade", "orderBookDiff", "bestPrice"}
+
+// builtinSinks returns the names of the shared sinks configured in cfg, in the order records are fanned out to them.
+func (cfg Config) builtinSinks() []string {
+	var names []string
+	for _, s := range []struct {
+		name       string
+		configured bool
+	}{
+		{"influx", cfg.Influx != nil},
+		{"timescale", cfg.Timescale != nil},
+		{"clickhouse", cfg.ClickHouse != nil},
+		{"kafka", cfg.Kafka != nil},
+	} {
+		if s.configured {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// SinksFor returns the names of the sinks dataType's records are written to: those listed in Sinks, or else the
+// parquet files plus every configured shared sink.
+func (cfg Config) SinksFor(dataType string) []string {
+	if names, ok := cfg.Sinks[dataType]; ok {
+		return names
+	}
+	return append([]string{ParquetSinkName}, cfg.builtinSinks()...)
+}
+
+// validateSinks checks that Sinks only lists known data types and sinks that are configured or registered in
+// DefaultSinks.
+func (cfg Config) validateSinks() error {
+	builtin := cfg.builtinSinks()
+	for dataType, names := range cfg.Sinks {
+		if !slices.Contains(sinkDataTypes, dataType) {
+			return fmt.Errorf("config: sinks for unknown data type %q, expected one of %v", dataType, sinkDataTypes)
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("config: no sinks for %s", dataType)
+		}
+		for i, name := range names {
+			switch {
+			case slices.Contains(names[:i], name):
+				return fmt.Errorf("config: sink %q listed twice for %s", name, dataType)
+			case name == ParquetSinkName || slices.Contains(builtin, name) || DefaultSinks.Has(name):
+			case slices.Contains([]string{"influx", "timescale", "clickhouse", "kafka"}, name):
+				return fmt.Errorf("config: sink %q for %s is not configured", name, dataType)
+			default:
+				return fmt.Errorf("config: unknown sink %q for %s", name, dataType)
+			}
+		}
+	}
+	return nil
+}
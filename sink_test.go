@@ -0,0 +1,128 @@
+package gobinapi
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSink records the calls made to it, failing every call once err is set.
+type fakeSink struct {
+	records []any
+	calls   []string
+	err     error
+}
+
+func (s *fakeSink) Write(record any) error {
+	s.records = append(s.records, record)
+	return s.err
+}
+
+func (s *fakeSink) Flush() error   { s.calls = append(s.calls, "flush"); return s.err }
+func (s *fakeSink) Rotate() error  { s.calls = append(s.calls, "rotate"); return s.err }
+func (s *fakeSink) Close() error   { s.calls = append(s.calls, "close"); return s.err }
+func (s *fakeSink) Healthy() error { return s.err }
+
+var _ Sink[Trade] = (*Recorder[Trade])(nil)
+
+func TestFanOutSink_CallsEverySinkAndReturnsFirstError(t *testing.T) {
+	failing := &fakeSink{err: errors.New("disk full")}
+	healthy := &fakeSink{}
+	out := FanOutSink[Trade]{UntypedSink[Trade](failing), UntypedSink[Trade](healthy)}
+
+	if err := out.Write(Trade{TradeID: 1}); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected the failing sink's error, got %v", err)
+	}
+	if err := out.Close(); err == nil {
+		t.Error("expected Close to report the failing sink")
+	}
+	if err := out.Healthy(); err == nil {
+		t.Error("expected the fan-out to be unhealthy while a sink is")
+	}
+	if len(healthy.records) != 1 || !reflect.DeepEqual(healthy.calls, []string{"close"}) {
+		t.Errorf("expected the healthy sink to get every call, got %v and %v", healthy.records, healthy.calls)
+	}
+}
+
+func TestFilteredSink_WritesThroughFilter(t *testing.T) {
+	next := &fakeSink{}
+	sink := UntypedSink[BestPrice](next)
+	filtered := FilteredSink(sink, NewBestPriceChangeFilter(sink, time.Hour))
+	filtered.Write(BestPrice{UpdateID: 1, BidPrice: "1", AskPrice: "2"})
+	filtered.Write(BestPrice{UpdateID: 2, BidPrice: "1", AskPrice: "2"})
+	filtered.Rotate()
+	if len(next.records) != 1 || !reflect.DeepEqual(next.calls, []string{"rotate"}) {
+		t.Errorf("expected the unchanged price to be filtered out, got %v and %v", next.records, next.calls)
+	}
+}
+
+func TestSinkRegistry_OpensRegisteredSinks(t *testing.T) {
+	r := NewSinkRegistry()
+	if err := r.Register(ParquetSinkName, nil); err == nil {
+		t.Error("expected the parquet sink name to be reserved")
+	}
+	var opened []string
+	r.Register("webhook", func(instrument string) (Sink[any], error) {
+		opened = append(opened, instrument)
+		return &fakeSink{}, nil
+	})
+	r.Register("broken", func(instrument string) (Sink[any], error) {
+		return nil, errors.New("no credentials")
+	})
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"broken", "webhook"}) {
+		t.Errorf("unexpected names %v", names)
+	}
+	if _, err := r.Open("webhook", "BTCUSDT"); err != nil || !reflect.DeepEqual(opened, []string{"BTCUSDT"}) {
+		t.Errorf("expected the factory to open BTCUSDT, got %v, %v", opened, err)
+	}
+	if _, err := r.Open("broken", "BTCUSDT"); err == nil || !strings.Contains(err.Error(), "failed to open broken sink for BTCUSDT") {
+		t.Errorf("expected the factory's error, got %v", err)
+	}
+	if _, err := r.Open("s3", "BTCUSDT"); err == nil || !strings.Contains(err.Error(), `unknown sink "s3"`) {
+		t.Errorf("expected an unknown sink error, got %v", err)
+	}
+	if c := r.clone(); !c.Has("webhook") || c.Register("extra", nil) != nil || r.Has("extra") {
+		t.Error("expected the clone to copy the factories without sharing later registrations")
+	}
+}
+
+func TestSymbolSink_ReportsSharedSinkHealth(t *testing.T) {
+	producer := &fakeKafkaProducer{failures: 1}
+	kafka := newKafkaSink(KafkaConfig{Brokers: []string{"kafka:9092"}}, producer, &FakeLogger{})
+	sink := SymbolSink(kafka, "btcusdt")
+
+	sink.Write(Trade{TradeID: 1})
+	if err := sink.Flush(); err == nil || sink.Healthy() == nil {
+		t.Fatalf("expected the failed publish to make the sink unhealthy, got %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing one instrument's share leaves the shared sink running
+	if err := sink.Flush(); err != nil || sink.Healthy() != nil {
+		t.Fatalf("expected the retried publish to succeed, got %v", err)
+	}
+	if len(producer.published) != 1 || string(producer.published[0].Key) != "BTCUSDT" {
+		t.Errorf("expected the trade keyed BTCUSDT, got %+v", producer.published)
+	}
+}
+
+func TestConfig_SinksFor(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Kafka = &KafkaConfig{Brokers: []string{"kafka:9092"}}
+	if got := cfg.SinksFor("aggTrade"); !reflect.DeepEqual(got, []string{"parquet", "kafka"}) {
+		t.Errorf("expected parquet and kafka by default, got %v", got)
+	}
+	DefaultSinks.Register("test-webhook", func(string) (Sink[any], error) { return &fakeSink{}, nil })
+	defer delete(DefaultSinks.factories, "test-webhook")
+	cfg.Sinks = map[string][]string{"trade": {"test-webhook", "kafka"}}
+	if err := cfg.validateSinks(); err != nil {
+		t.Errorf("expected a registered sink to be accepted, got %v", err)
+	}
+	cfg.Sinks["trade"] = []string{"kafka", "kafka"}
+	if err := cfg.validateSinks(); err == nil || !strings.Contains(err.Error(), "listed twice") {
+		t.Errorf("expected a duplicate sink to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package gobinapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SnapshotScheduler fetches REST order book snapshots for many symbols through one loop paced by a WeightTracker,
+// so deep snapshots (limit=1000 and above) for hundreds of symbols fit within the request weight budget instead of
+// firing all at once. Symbols that asked for a snapshot because of a sequence gap (see Request) are served before
+// routine refreshes, in the order they asked. With SetStagger, the refreshes of symbols added together are spread
+// over the interval, and SetWorkerPool caps how many requests run at once.
+type SnapshotScheduler struct {
+	interval time.Duration
+	limit    int
+	tracker  *WeightTracker
+	logger   LoggerInterface
+	pool     *WorkerPool
+	market   string
+	stagger  bool
+
+	mu          sync.Mutex
+	outs        map[string]chan<- OrderBookSnapshot
+	order       []string
+	lastFetched map[string]time.Time
+	urgent      []string
+	wake        chan struct{}
+	// added counts the symbols ever added, placing each one's refreshes when staggering
+	added int
+}
+
+// NewSnapshotScheduler creates a scheduler refreshing every added symbol once per interval with snapshots of limit
+// levels, pacing requests through tracker. An interval of zero makes no routine refreshes, only fetching the
+// snapshots asked for with Request.
+func NewSnapshotScheduler(interval time.Duration, limit int, tracker *WeightTracker, logger LoggerInterface) *SnapshotScheduler {
+	return &SnapshotScheduler{
+		interval:    interval,
+		limit:       limit,
+		tracker:     tracker,
+		logger:      logger,
+		outs:        make(map[string]chan<- OrderBookSnapshot),
+		lastFetched: make(map[string]time.Time),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Add schedules periodic snapshots of symbol, delivered on out. The first routine refresh is one interval after now,
+// or up to an interval after now when staggering; use Request for an immediate snapshot.
+func (s *SnapshotScheduler) Add(symbol string, out chan<- OrderBookSnapshot, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.outs[symbol]; !ok {
+		s.order = append(s.order, symbol)
+	}
+	s.outs[symbol] = out
+	s.lastFetched[symbol] = now
+	if s.stagger {
+		s.lastFetched[symbol] = now.Add(-time.Duration(staggerFraction(s.added) * float64(s.interval)))
+	}
+	s.added++
+}
+
+// staggerFraction returns the k-th element of the base 2 van der Corput sequence, 0, 1/2, 1/4, 3/4, 1/8, ..., whose
+// first n elements spread evenly over [0, 1) for any n.
+func staggerFraction(k int) float64 {
+	f, step := 0.0, 0.5
+	for ; k > 0; k >>= 1 {
+		if k&1 == 1 {
+			f += step
+		}
+		step /= 2
+	}
+	return f
+}
+
+// Remove stops scheduling snapshots of symbol and drops any pending request for it. A snapshot already being
+// fetched is still delivered on the symbol's channel.
+func (s *SnapshotScheduler) Remove(symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.outs, symbol)
+	delete(s.lastFetched, symbol)
+	for i, o := range s.order {
+		if o == symbol {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	for i, u := range s.urgent {
+		if u == symbol {
+			s.urgent = append(s.urgent[:i], s.urgent[i+1:]...)
+			break
+		}
+	}
+}
+
+// Has reports whether snapshots of symbol are scheduled.
+func (s *SnapshotScheduler) Has(symbol string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.outs[symbol]
+	return ok
+}
+
+// SetWorkerPool makes Run fetch and deliver snapshots on pool, so a slow request or a full output channel does not
+// hold up other symbols. Without a pool, snapshots are fetched one at a time by Run itself.
+func (s *SnapshotScheduler) SetWorkerPool(pool *WorkerPool) {
+	s.pool = pool
+}
+
+// SetStagger spreads the routine refreshes of the symbols over the interval, so symbols added together, e.g. at
+// startup, do not all fall due at once. Each symbol keeps its place in the interval: a snapshot fetched on Request
+// only moves its next refresh along by an interval if that refresh is less than half an interval away. It must be
+// set before symbols are added.
+func (s *SnapshotScheduler) SetStagger(stagger bool) {
+	s.stagger = stagger
+}
+
+// SetMarket makes the scheduler fetch snapshots from market's REST API (MarketSpot unless set), pacing them by that
+// endpoint's request weight.
+func (s *SnapshotScheduler) SetMarket(market string) {
+	s.market = market
+}
+
+// Request asks for a snapshot of symbol ahead of routine refreshes, e.g. after a sequence gap. Repeated requests
+// before the snapshot is fetched are merged.
+func (s *SnapshotScheduler) Request(symbol string) {
+	s.mu.Lock()
+	for _, u := range s.urgent {
+		if u == symbol {
+			s.mu.Unlock()
+			return
+		}
+	}
+	s.urgent = append(s.urgent, symbol)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Next returns the symbol to fetch at now: the oldest pending request if there is one, otherwise the most overdue
+// routine refresh. If nothing is due, it returns "" and how long until the next refresh is.
+func (s *SnapshotScheduler) Next(now time.Time) (string, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.urgent) > 0 {
+		return s.urgent[0], 0
+	}
+	if len(s.order) == 0 || s.interval <= 0 {
+		return "", time.Hour
+	}
+	next := s.order[0]
+	wait := s.lastFetched[next].Add(s.interval).Sub(now)
+	for _, symbol := range s.order[1:] {
+		if d := s.lastFetched[symbol].Add(s.interval).Sub(now); d < wait {
+			next, wait = symbol, d
+		}
+	}
+	if wait > 0 {
+		return "", wait
+	}
+	return next, 0
+}
+
+// markFetched records an attempt to fetch symbol, clearing any pending request for it. It returns nil if symbol
+// was removed in the meantime.
+func (s *SnapshotScheduler) markFetched(symbol string, now time.Time) chan<- OrderBookSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.outs[symbol]; ok && (!s.stagger || s.interval <= 0) {
+		s.lastFetched[symbol] = now
+	} else if ok {
+		// The next refresh is the first in the symbol's place that is at least half an interval away
+		last := s.lastFetched[symbol]
+		if behind := now.Add(s.interval / 2).Sub(last.Add(s.interval)); behind > 0 {
+			s.lastFetched[symbol] = last.Add((behind/s.interval + 1) * s.interval)
+		}
+	}
+	for i, u := range s.urgent {
+		if u == symbol {
+			s.urgent = append(s.urgent[:i], s.urgent[i+1:]...)
+			break
+		}
+	}
+	return s.outs[symbol]
+}
+
+// Run fetches snapshots as they fall due until ctx is cancelled, waiting whenever the next request would exceed the
+// weight budget. Failed fetches are logged and retried at the next refresh or request.
+func (s *SnapshotScheduler) Run(ctx context.Context, client *http.Client) error {
+	weight := DepthWeight(s.limit)
+	if s.market == MarketUSDM {
+		weight = FuturesDepthWeight(s.limit)
+	}
+	for {
+		now := NowFunc()
+		symbol, wait := s.Next(now)
+		if symbol != "" {
+			wait = s.tracker.Reserve(weight, now)
+		}
+		if wait > 0 {
+			timer := DefaultClock.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-s.wake:
+				timer.Stop()
+			case <-timer.C():
+			}
+			continue
+		}
+
+		out := s.markFetched(symbol, now)
+		if out == nil {
+			// Requested for a symbol that is not, or no longer, scheduled; the reserved weight goes unused
+			continue
+		}
+		fetch := func() {
+			s.fetch(ctx, client, symbol, out)
+		}
+		if s.pool == nil {
+			fetch()
+		} else if err := s.pool.Submit(ctx, fetch); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// fetch fetches one snapshot of symbol and delivers it on out.
+func (s *SnapshotScheduler) fetch(ctx context.Context, client *http.Client, symbol string, out chan<- OrderBookSnapshot) {
+	fetchSnapshot := FetchOrderBookSnapshotContext
+	if s.market == MarketUSDM {
+		fetchSnapshot = FetchFuturesOrderBookSnapshotContext
+	}
+	snapshot, err := fetchSnapshot(ctx, client, symbol, s.limit)
+	if err != nil {
+		s.logger.Errorf("Snapshot request failed for %s: %v", symbol, err)
+		return
+	}
+	select {
+	case out <- *snapshot:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,251 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestSnapshotScheduler_NextPrioritizesRequests(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	s := NewSnapshotScheduler(time.Minute, 1000, NewWeightTracker(100), &FakeLogger{})
+	s.Add("AAAUSDT", make(chan OrderBookSnapshot, 1), start)
+	s.Add("BBBUSDT", make(chan OrderBookSnapshot, 1), start.Add(10*time.Second))
+	s.Add("CCCUSDT", make(chan OrderBookSnapshot, 1), start.Add(20*time.Second))
+
+	if symbol, wait := s.Next(start.Add(30 * time.Second)); symbol != "" || wait != 30*time.Second {
+		t.Fatalf("expected nothing due for 30s, got %q after %s", symbol, wait)
+	}
+
+	// The most overdue routine refresh goes first
+	if symbol, _ := s.Next(start.Add(2 * time.Minute)); symbol != "AAAUSDT" {
+		t.Errorf("expected AAAUSDT, got %q", symbol)
+	}
+
+	// Gap requests jump the queue in the order they were made, and repeats are merged
+	s.Request("CCCUSDT")
+	s.Request("BBBUSDT")
+	s.Request("CCCUSDT")
+	now := start.Add(30 * time.Second)
+	for _, want := range []string{"CCCUSDT", "BBBUSDT"} {
+		symbol, wait := s.Next(now)
+		if symbol != want || wait != 0 {
+			t.Fatalf("expected %s now, got %q after %s", want, symbol, wait)
+		}
+		s.markFetched(symbol, now)
+	}
+	if symbol, _ := s.Next(now); symbol != "" {
+		t.Errorf("expected no more requests, got %q", symbol)
+	}
+}
+
+func TestSnapshotScheduler_PacesDeepSnapshotsToWeightBudget(t *testing.T) {
+	srv := useMockServer(t)
+	symbols := []string{"AAAUSDT", "BBBUSDT", "CCCUSDT"}
+	for i, symbol := range symbols {
+		srv.SetSnapshot(symbol, mockbinance.SnapshotMessage(int64(i+1), []mockbinance.Level{{"1.0", "1"}}, []mockbinance.Level{{"2.0", "1"}}))
+	}
+
+	// A budget of 100 fits two limit=1000 snapshots (weight 50) per minute
+	tracker := NewWeightTracker(100)
+	s := NewSnapshotScheduler(time.Hour, 1000, tracker, &FakeLogger{})
+	out := make(chan OrderBookSnapshot, len(symbols))
+	for _, symbol := range symbols {
+		s.Add(symbol, out, NowFunc())
+		s.Request(symbol)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { defer close(done); s.Run(ctx, http.DefaultClient) }()
+	defer func() { cancel(); <-done }()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case snap := <-out:
+			if snap.LastUpdateID != int64(i+1) {
+				t.Errorf("expected snapshots in request order, got %d at %d", snap.LastUpdateID, i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for snapshot %d", i)
+		}
+	}
+	select {
+	case snap := <-out:
+		// Only possible if the test happened to straddle a minute boundary
+		if time.Now().Second() > 5 {
+			t.Errorf("expected the third snapshot to wait for the next minute, got %+v", snap)
+		}
+	case <-time.After(200 * time.Millisecond):
+	}
+	if srv.UsedWeight() > 100 && time.Now().Second() > 5 {
+		t.Errorf("expected at most 100 weight used, server saw %d", srv.UsedWeight())
+	}
+}
+
+func TestSnapshotScheduler_FetchesOnWorkerPool(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetSnapshot("AAAUSDT", mockbinance.SnapshotMessage(9, []mockbinance.Level{{"1.0", "1"}}, []mockbinance.Level{{"2.0", "1"}}))
+
+	s := NewSnapshotScheduler(time.Hour, 100, NewWeightTracker(1000), &FakeLogger{})
+	pool := NewWorkerPool("test_snapshots", 2, 2)
+	s.SetWorkerPool(pool)
+	out := make(chan OrderBookSnapshot, 1)
+	s.Add("AAAUSDT", out, NowFunc())
+	s.Request("AAAUSDT")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { defer close(done); s.Run(ctx, http.DefaultClient); pool.Close() }()
+	defer func() { cancel(); <-done }()
+
+	select {
+	case snap := <-out:
+		if snap.LastUpdateID != 9 {
+			t.Errorf("unexpected snapshot %+v", snap)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for snapshot")
+	}
+}
+
+func TestSnapshotScheduler_TopOfBookAndDeepShareBudget(t *testing.T) {
+	srv := useMockServer(t)
+	var bids, asks []mockbinance.Level
+	for i := 0; i < 50; i++ {
+		bids = append(bids, mockbinance.Level{fmt.Sprintf("%d.0", 100-i), "1"})
+		asks = append(asks, mockbinance.Level{fmt.Sprintf("%d.0", 101+i), "1"})
+	}
+	srv.SetSnapshot("AAAUSDT", mockbinance.SnapshotMessage(5, bids, asks))
+
+	tracker := NewWeightTracker(1000)
+	pool := NewWorkerPool("test_top_snapshots", 2, 2)
+	deep := NewSnapshotScheduler(time.Hour, 1000, tracker, &FakeLogger{})
+	top := NewSnapshotScheduler(time.Hour, 20, tracker, &FakeLogger{})
+	deepOut, topOut := make(chan OrderBookSnapshot, 1), make(chan OrderBookSnapshot, 1)
+	for s, out := range map[*SnapshotScheduler]chan OrderBookSnapshot{deep: deepOut, top: topOut} {
+		s.SetWorkerPool(pool)
+		s.Add("AAAUSDT", out, NowFunc())
+		s.Request("AAAUSDT")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, s := range []*SnapshotScheduler{deep, top} {
+		wg.Add(1)
+		go func() { defer wg.Done(); s.Run(ctx, http.DefaultClient) }()
+	}
+	defer func() { cancel(); wg.Wait(); pool.Close() }()
+
+	for name, c := range map[string]struct {
+		out    chan OrderBookSnapshot
+		levels int
+	}{"deep": {deepOut, 50}, "top": {topOut, 20}} {
+		select {
+		case snap := <-c.out:
+			if len(snap.Bids) != c.levels || len(snap.Asks) != c.levels {
+				t.Errorf("%s: expected %d levels per side, got %d bids and %d asks", name, c.levels, len(snap.Bids), len(snap.Asks))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: timed out waiting for snapshot", name)
+		}
+	}
+	if used := tracker.Used(NowFunc()); used != DepthWeight(1000)+DepthWeight(20) {
+		t.Errorf("expected both snapshots charged to the shared budget, got %d", used)
+	}
+}
+
+func TestSnapshotScheduler_RemoveDropsSymbolAndRequests(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	s := NewSnapshotScheduler(time.Minute, 1000, NewWeightTracker(100), &FakeLogger{})
+	s.Add("AAAUSDT", make(chan OrderBookSnapshot, 1), start)
+	s.Add("BBBUSDT", make(chan OrderBookSnapshot, 1), start.Add(10*time.Second))
+	s.Request("AAAUSDT")
+	s.Remove("AAAUSDT")
+
+	if s.Has("AAAUSDT") || !s.Has("BBBUSDT") {
+		t.Fatalf("expected only BBBUSDT to be scheduled")
+	}
+	if symbol, _ := s.Next(start.Add(2 * time.Minute)); symbol != "BBBUSDT" {
+		t.Errorf("expected the removed symbol's request and refreshes to be dropped, got %q", symbol)
+	}
+	// A request that raced with the removal is not fetched
+	s.Request("AAAUSDT")
+	if out := s.markFetched("AAAUSDT", start); out != nil {
+		t.Errorf("expected no output for a removed symbol")
+	}
+}
+
+func TestSnapshotScheduler_StaggersRefreshesOverInterval(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	s := NewSnapshotScheduler(time.Minute, 1000, NewWeightTracker(100), &FakeLogger{})
+	s.SetStagger(true)
+	for _, symbol := range []string{"AAAUSDT", "BBBUSDT", "CCCUSDT", "DDDUSDT"} {
+		s.Add(symbol, make(chan OrderBookSnapshot, 1), start)
+	}
+
+	// Symbols added together fall due a quarter of the interval apart
+	if symbol, wait := s.Next(start); symbol != "" || wait != 15*time.Second {
+		t.Fatalf("expected the first refresh after 15s, got %q after %s", symbol, wait)
+	}
+	for i, want := range []string{"DDDUSDT", "BBBUSDT", "CCCUSDT", "AAAUSDT"} {
+		now := start.Add(time.Duration(i+1) * 15 * time.Second)
+		if symbol, _ := s.Next(now); symbol != want {
+			t.Fatalf("expected %s due at %s, got %q", want, now, symbol)
+		}
+		s.markFetched(want, now)
+	}
+
+	// Each symbol keeps its place: DDDUSDT next falls due at 1m15s. A requested snapshot shortly before moves the
+	// refresh to the next slot, one further ahead does not.
+	s.Request("DDDUSDT")
+	s.Request("AAAUSDT")
+	now := start.Add(70 * time.Second)
+	for range 2 {
+		symbol, _ := s.Next(now)
+		s.markFetched(symbol, now)
+	}
+	if symbol, wait := s.Next(now); symbol != "" || wait != 20*time.Second {
+		t.Errorf("expected BBBUSDT due in 20s, got %q after %s", symbol, wait)
+	}
+	if symbol, _ := s.Next(start.Add(2 * time.Minute)); symbol != "BBBUSDT" {
+		t.Errorf("expected BBBUSDT, got %q", symbol)
+	}
+	s.markFetched("BBBUSDT", start.Add(2*time.Minute))
+	for _, due := range []struct {
+		symbol string
+		at     time.Duration
+	}{{"CCCUSDT", 105 * time.Second}, {"AAAUSDT", 2 * time.Minute}, {"DDDUSDT", 135 * time.Second}} {
+		s.mu.Lock()
+		at := s.lastFetched[due.symbol].Add(s.interval).Sub(start)
+		s.mu.Unlock()
+		if at != due.at {
+			t.Errorf("expected %s due at %s, got %s", due.symbol, due.at, at)
+		}
+	}
+}
+
+func TestSnapshotScheduler_ZeroIntervalOnlyFetchesRequests(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	s := NewSnapshotScheduler(0, 1000, NewWeightTracker(100), &FakeLogger{})
+	s.SetStagger(true)
+	s.Add("AAAUSDT", make(chan OrderBookSnapshot, 1), start)
+	s.Add("BBBUSDT", make(chan OrderBookSnapshot, 1), start)
+
+	if symbol, _ := s.Next(start.Add(24 * time.Hour)); symbol != "" {
+		t.Fatalf("expected no routine refresh, got %q", symbol)
+	}
+	s.Request("BBBUSDT")
+	if symbol, wait := s.Next(start); symbol != "BBBUSDT" || wait != 0 {
+		t.Fatalf("expected the requested snapshot now, got %q after %s", symbol, wait)
+	}
+	s.markFetched("BBBUSDT", start)
+	if symbol, _ := s.Next(start.Add(24 * time.Hour)); symbol != "" {
+		t.Errorf("expected nothing more after the request, got %q", symbol)
+	}
+}
@@ -0,0 +1,258 @@
+package gobinapi
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// SpillQueue is an order-preserving queue between a producer (typically a WebSocket listener) and a consumer
+// (typically a Subscribe* function writing to a Recorder). Items are handed to the consumer through a bounded
+// in-memory channel; when that channel is full (e.g. during a long parquet stall or a disk hiccup) further items are
+// spilled to a temporary on-disk file and drained back into the channel once the consumer catches up.
+// Producers therefore never block and no data is dropped. Other overflow policies can be chosen with
+// SetOverflowPolicy.
+//
+// Once spilling has started, every new item goes to disk until the spill file has been fully drained, so the
+// consumer always sees items in the order in which they were pushed.
+type SpillQueue[T any] struct {
+	in  chan T
+	out chan T
+
+	mu      sync.Mutex
+	policy  OverflowPolicy
+	name    string
+	path    string
+	wf      *os.File
+	rf      *os.File
+	reader  *bufio.Reader
+	enc     *gob.Encoder
+	dec     *gob.Decoder
+	pending int
+	spilled int64
+	closed  bool
+	notify  chan struct{}
+	errs    chan error
+}
+
+// NewSpillQueue creates a SpillQueue whose in-memory stage holds up to capacity items. Overflow items are spilled to
+// a file named <name>_<random>.spill inside dir, which is created if necessary and removed when the queue is drained
+// after the input channel is closed. The random part keeps a queue replacing one of the same name, e.g. when an
+// instrument is removed and added again, from sharing the old queue's file.
+func NewSpillQueue[T any](dir string, name string, capacity int) (*SpillQueue[T], error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spill directory %s: %w", dir, err)
+	}
+	wf, err := os.CreateTemp(dir, name+"_*.spill")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill file for %s in %s: %w", name, dir, err)
+	}
+	path := wf.Name()
+	rf, err := os.Open(path)
+	if err != nil {
+		wf.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to open spill file %s for reading: %w", path, err)
+	}
+	q := &SpillQueue[T]{
+		policy: OverflowSpill,
+		in:     make(chan T, capacity),
+		out:    make(chan T, capacity),
+		name:   name,
+		path:   path,
+		wf:     wf,
+		rf:     rf,
+		notify: make(chan struct{}, 1),
+		errs:   make(chan error, 1),
+	}
+	q.resetCodecs()
+	go q.forward()
+	go q.drain()
+	return q, nil
+}
+
+// SetOverflowPolicy sets what happens to items arriving while the in-memory stage is full. It must be called before
+// the first item is sent.
+func (q *SpillQueue[T]) SetOverflowPolicy(policy OverflowPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.policy = policy
+}
+
+// In returns the channel producers send items on. Closing it drains the queue and then closes Out.
+func (q *SpillQueue[T]) In() chan<- T {
+	return q.in
+}
+
+// Out returns the channel consumers receive items from.
+func (q *SpillQueue[T]) Out() <-chan T {
+	return q.out
+}
+
+// Errors returns a channel on which spill file I/O errors are reported. Items that could not be spilled are lost,
+// so callers should log anything received here. Lost items are also counted as drops under the queue's name (see
+// RecordDrop).
+func (q *SpillQueue[T]) Errors() <-chan error {
+	return q.errs
+}
+
+// Pending returns the number of items currently held on disk.
+func (q *SpillQueue[T]) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending
+}
+
+// Buffered returns the number of items waiting in the in-memory stage for the consumer.
+func (q *SpillQueue[T]) Buffered() int {
+	return len(q.out)
+}
+
+// Spilled returns the total number of items that have been spilled to disk since the queue was created.
+func (q *SpillQueue[T]) Spilled() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.spilled
+}
+
+// forward moves items from the input channel into the in-memory stage, spilling to disk when it is full.
+func (q *SpillQueue[T]) forward() {
+	for item := range q.in {
+		q.push(item)
+	}
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+// push hands an item to the in-memory stage if nothing is waiting on disk and there is room, and otherwise spills,
+// waits or drops as the overflow policy says. Only OverflowSpill ever puts items on disk.
+func (q *SpillQueue[T]) push(item T) {
+	q.mu.Lock()
+	if q.pending == 0 {
+		select {
+		case q.out <- item:
+			q.mu.Unlock()
+			return
+		default:
+		}
+	}
+	policy := q.policy
+	if policy != OverflowSpill {
+		q.mu.Unlock()
+	}
+	switch policy {
+	case OverflowBlock:
+		q.out <- item
+		return
+	case OverflowDropNewest:
+		RecordDrop(q.name, item)
+		return
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.out <- item:
+				return
+			default:
+			}
+			select {
+			case old := <-q.out:
+				RecordDrop(q.name, old)
+			default:
+			}
+		}
+	}
+	defer q.mu.Unlock()
+	if err := q.enc.Encode(&item); err != nil {
+		q.reportError(fmt.Errorf("failed to spill item to %s: %w", q.path, err))
+		RecordDrop(q.name, item)
+		return
+	}
+	q.pending++
+	q.spilled++
+	q.signal()
+}
+
+// drain feeds spilled items back into the in-memory stage in order, and closes Out once the input is closed and
+// everything has been delivered.
+func (q *SpillQueue[T]) drain() {
+	defer q.cleanup()
+	for range q.notify {
+		for {
+			q.mu.Lock()
+			if q.pending == 0 {
+				closed := q.closed
+				q.mu.Unlock()
+				if closed {
+					return
+				}
+				break
+			}
+			var item T
+			err := q.dec.Decode(&item)
+			q.mu.Unlock()
+			if err != nil {
+				q.reportError(fmt.Errorf("failed to read spilled item from %s: %w", q.path, err))
+				q.mu.Lock()
+				RecordDropCount(q.name, int64(q.pending))
+				q.truncate()
+				q.mu.Unlock()
+				continue
+			}
+			q.out <- item
+			q.mu.Lock()
+			q.pending--
+			if q.pending == 0 {
+				q.truncate()
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+// truncate empties the spill file once everything in it has been delivered. It must be called with mu held.
+func (q *SpillQueue[T]) truncate() {
+	q.pending = 0
+	if err := q.wf.Truncate(0); err != nil {
+		q.reportError(fmt.Errorf("failed to truncate spill file %s: %w", q.path, err))
+	}
+	if _, err := q.wf.Seek(0, 0); err != nil {
+		q.reportError(fmt.Errorf("failed to rewind spill file %s: %w", q.path, err))
+	}
+	if _, err := q.rf.Seek(0, 0); err != nil {
+		q.reportError(fmt.Errorf("failed to rewind spill file %s: %w", q.path, err))
+	}
+	q.resetCodecs()
+}
+
+// resetCodecs starts a fresh gob stream, which is required whenever the spill file is rewound because gob only
+// transmits type information once per stream.
+func (q *SpillQueue[T]) resetCodecs() {
+	q.enc = gob.NewEncoder(q.wf)
+	q.reader = bufio.NewReader(q.rf)
+	q.dec = gob.NewDecoder(q.reader)
+}
+
+func (q *SpillQueue[T]) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *SpillQueue[T]) reportError(err error) {
+	select {
+	case q.errs <- err:
+	default:
+	}
+}
+
+func (q *SpillQueue[T]) cleanup() {
+	close(q.out)
+	q.rf.Close()
+	q.wf.Close()
+	os.Remove(q.path)
+}
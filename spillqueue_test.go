@@ -0,0 +1,204 @@
+package gobinapi
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestSpillQueue_SpillsAndPreservesOrder pushes far more items than the in-memory capacity with no consumer running,
+// verifies the producer never blocks, and then checks that every item is delivered in order.
+func TestSpillQueue_SpillsAndPreservesOrder(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewSpillQueue[OrderBookDiff](dir, "BTCUSDT_orderBookDiff", 4)
+	if err != nil {
+		t.Fatalf("failed to create spill queue: %v", err)
+	}
+
+	const total = 200
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= total; i++ {
+			q.In() <- OrderBookDiff{
+				FirstUpdateID: int64(i),
+				FinalUpdateID: int64(i),
+				Bids:          []PriceLevel{{Price: "100.0", Quantity: "1.0"}},
+			}
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("producer blocked even though overflow should spill to disk")
+	}
+
+	// Give the forwarder a moment to move everything out of the input channel
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Pending() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if q.Spilled() == 0 {
+		t.Fatalf("expected items to be spilled to disk")
+	}
+	close(q.In())
+
+	var expected int64 = 1
+	for diff := range q.Out() {
+		if diff.FirstUpdateID != expected {
+			t.Fatalf("out of order delivery: expected %d, got %d", expected, diff.FirstUpdateID)
+		}
+		if len(diff.Bids) != 1 || diff.Bids[0].Price != "100.0" {
+			t.Fatalf("spilled item was not decoded correctly: %+v", diff)
+		}
+		expected++
+	}
+	if expected != total+1 {
+		t.Errorf("expected %d items, got %d", total, expected-1)
+	}
+	if FileExists(q.path) {
+		t.Errorf("expected spill file %s to be removed after draining", q.path)
+	}
+}
+
+// TestSpillQueue_SameNameKeepsItsOwnFile replaces a queue by one of the same name, as re-adding a removed instrument
+// does, and checks that draining the old queue leaves the new one's spill file alone.
+func TestSpillQueue_SameNameKeepsItsOwnFile(t *testing.T) {
+	dir := t.TempDir()
+	old, err := NewSpillQueue[Trade](dir, "BTCUSDT_trade", 1)
+	if err != nil {
+		t.Fatalf("failed to create spill queue: %v", err)
+	}
+	q, err := NewSpillQueue[Trade](dir, "BTCUSDT_trade", 1)
+	if err != nil {
+		t.Fatalf("failed to create spill queue: %v", err)
+	}
+	if q.path == old.path {
+		t.Fatalf("expected queues of the same name to spill to different files, both use %s", q.path)
+	}
+	close(old.In())
+	for range old.Out() {
+	}
+	if !FileExists(q.path) {
+		t.Errorf("expected spill file %s to survive the old queue", q.path)
+	}
+	close(q.In())
+	for range q.Out() {
+	}
+}
+
+// TestSpillQueue_ReusesFileAfterDrain checks that the queue keeps working after the spill file has been drained and
+// truncated, which requires starting a new gob stream.
+func TestSpillQueue_ReusesFileAfterDrain(t *testing.T) {
+	q, err := NewSpillQueue[Trade](t.TempDir(), "BTCUSDT_trade", 1)
+	if err != nil {
+		t.Fatalf("failed to create spill queue: %v", err)
+	}
+	defer close(q.In())
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 10; i++ {
+			q.In() <- Trade{TradeID: int64(round*10 + i)}
+		}
+		for i := 0; i < 10; i++ {
+			select {
+			case trade := <-q.Out():
+				if trade.TradeID != int64(round*10+i) {
+					t.Fatalf("round %d: expected trade %d, got %d", round, round*10+i, trade.TradeID)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("round %d: timed out waiting for trade %d", round, round*10+i)
+			}
+		}
+	}
+	select {
+	case err := <-q.Errors():
+		t.Errorf("unexpected spill error: %v", err)
+	default:
+	}
+}
+
+// TestSpillQueue_DropPolicies pushes more items than fit with no consumer running and checks that the drop policies
+// keep the first or the latest items, count the rest as dropped, and never spill.
+func TestSpillQueue_DropPolicies(t *testing.T) {
+	for policy, want := range map[OverflowPolicy][]int64{
+		OverflowDropNewest: {1, 2, 3, 4},
+		OverflowDropOldest: {7, 8, 9, 10},
+	} {
+		t.Run(string(policy), func(t *testing.T) {
+			name := "BTCUSDT_trade_" + string(policy)
+			q, err := NewSpillQueue[Trade](t.TempDir(), name, 4)
+			if err != nil {
+				t.Fatalf("failed to create spill queue: %v", err)
+			}
+			q.SetOverflowPolicy(policy)
+			dropped := func() float64 {
+				return DefaultMetrics.Value("binance_messages_dropped_total", Labels{"stream": name})
+			}
+			before := dropped()
+			for i := 1; i <= 10; i++ {
+				q.In() <- Trade{TradeID: int64(i)}
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for dropped()-before < 6 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if n := dropped() - before; n != 6 {
+				t.Fatalf("expected 6 dropped trades, got %v", n)
+			}
+			close(q.In())
+
+			var got []int64
+			for trade := range q.Out() {
+				got = append(got, trade.TradeID)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("expected trades %v, got %v", want, got)
+			}
+			if q.Spilled() != 0 {
+				t.Errorf("expected nothing spilled, got %d", q.Spilled())
+			}
+		})
+	}
+}
+
+// TestSpillQueue_BlockPolicy checks that with OverflowBlock a full queue holds up the producer until the consumer
+// catches up, and loses nothing.
+func TestSpillQueue_BlockPolicy(t *testing.T) {
+	q, err := NewSpillQueue[Trade](t.TempDir(), "BTCUSDT_trade_block", 2)
+	if err != nil {
+		t.Fatalf("failed to create spill queue: %v", err)
+	}
+	q.SetOverflowPolicy(OverflowBlock)
+
+	const total = 20
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= total; i++ {
+			q.In() <- Trade{TradeID: int64(i)}
+		}
+		close(q.In())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("producer did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var expected int64 = 1
+	for trade := range q.Out() {
+		if trade.TradeID != expected {
+			t.Fatalf("expected trade %d, got %d", expected, trade.TradeID)
+		}
+		expected++
+	}
+	if expected != total+1 {
+		t.Errorf("expected %d trades, got %d", total, expected-1)
+	}
+	if q.Spilled() != 0 {
+		t.Errorf("expected nothing spilled, got %d", q.Spilled())
+	}
+}
@@ -0,0 +1,309 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaxStreamsPerConnection is the number of streams the exchange allows on a single WebSocket connection.
+const MaxStreamsPerConnection = 1024
+
+// streamControl is a SUBSCRIBE or UNSUBSCRIBE request for a live connection; the exchange's answer is sent on done.
+type streamControl struct {
+	method string
+	params []string
+	done   chan error
+}
+
+// messageStreams maps the kind of a raw stream message to the stream name suffix it is received on.
+var messageStreams = map[string]string{
+	"trade":           StreamTrade,
+	"aggTrade":        StreamAggTrade,
+	"depthUpdate":     StreamDepth,
+	"bookTicker":      StreamBookTicker,
+	"markPriceUpdate": StreamMarkPrice,
+	"avgPrice":        StreamAvgPrice,
+	"24hrTicker":      StreamTicker,
+	"1hTicker":        StreamTicker1h,
+	"4hTicker":        StreamTicker4h,
+	"1dTicker":        StreamTicker1d,
+}
+
+// StreamManager multiplexes the streams of many symbols over one raw /ws connection, using the exchange's SUBSCRIBE
+// and UNSUBSCRIBE requests so symbols can be added and removed at runtime without tearing the connection down. A
+// connection that is replaced, after a failure or by a planned reconnect, subscribes to the current set.
+//
+// Messages are routed to the handler by the symbol and kind they carry, so each symbol may subscribe to only one
+// variant of a stream (e.g. depth or depth@100ms, not both).
+type StreamManager struct {
+	streams []string
+	handler func(stream string, msg []byte, session WSSession) error
+
+	mu sync.Mutex
+	// symbols maps each managed symbol to its full stream names, e.g. btcusdt@trade
+	symbols map[string][]string
+	// session is the live connection's control channel, nil while disconnected
+	session *managerSession
+}
+
+// managerSession connects a StreamManager to the connection currently serving it.
+type managerSession struct {
+	control chan streamControl
+	done    chan struct{}
+}
+
+// NewStreamManager creates a manager subscribing every symbol to streams (suffixes like "trade" or "depth@100ms")
+// unless AddSymbol names others. handler receives every message with the full name of the stream it belongs to.
+func NewStreamManager(streams []string, handler func(stream string, msg []byte, session WSSession) error) *StreamManager {
+	return &StreamManager{streams: streams, handler: handler, symbols: make(map[string][]string)}
+}
+
+// AddSymbol subscribes symbol to streams, or to the manager's streams if none are given. While connected, it waits for
+// the exchange to acknowledge the SUBSCRIBE request and forgets the symbol again if it is rejected; otherwise the
+// symbol is subscribed when the next connection is made. A symbol without streams is managed but receives nothing
+// until AddStreams subscribes it to some.
+func (m *StreamManager) AddSymbol(symbol string, streams ...string) error {
+	if len(streams) == 0 {
+		streams = m.streams
+	}
+	m.mu.Lock()
+	if _, ok := m.symbols[symbol]; ok {
+		m.mu.Unlock()
+		return fmt.Errorf("symbol %s is already subscribed", symbol)
+	}
+	m.symbols[symbol] = nil
+	m.mu.Unlock()
+	if len(streams) == 0 {
+		return nil
+	}
+	if err := m.AddStreams(symbol, streams...); err != nil {
+		m.mu.Lock()
+		delete(m.symbols, symbol)
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// AddStreams subscribes a managed symbol to further streams, waiting for the acknowledgement like AddSymbol. The
+// streams are dropped again if the request is rejected.
+func (m *StreamManager) AddStreams(symbol string, streams ...string) error {
+	names := streamNames(symbol, streams)
+	m.mu.Lock()
+	current, ok := m.symbols[symbol]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("symbol %s is not subscribed", symbol)
+	}
+	for _, name := range names {
+		if slices.Contains(current, name) {
+			m.mu.Unlock()
+			return fmt.Errorf("already subscribed to %s", name)
+		}
+	}
+	if n := m.countLocked() + len(names); n > MaxStreamsPerConnection {
+		m.mu.Unlock()
+		return fmt.Errorf("subscribing %s would need %d streams, more than the %d allowed on one connection", symbol, n, MaxStreamsPerConnection)
+	}
+	m.symbols[symbol] = append(current[:len(current):len(current)], names...)
+	session := m.session
+	m.mu.Unlock()
+
+	if err := session.request("SUBSCRIBE", names); err != nil {
+		m.mu.Lock()
+		if current, ok := m.symbols[symbol]; ok {
+			m.symbols[symbol] = without(current, names)
+		}
+		m.mu.Unlock()
+		return fmt.Errorf("failed to subscribe %s to %v: %w", symbol, streams, err)
+	}
+	return nil
+}
+
+// RemoveSymbol unsubscribes symbol from all its streams. Messages for it that are still in flight are dropped. The
+// symbol is forgotten even if the UNSUBSCRIBE request fails, so the next connection no longer subscribes to it.
+func (m *StreamManager) RemoveSymbol(symbol string) error {
+	m.mu.Lock()
+	names, ok := m.symbols[symbol]
+	delete(m.symbols, symbol)
+	session := m.session
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("symbol %s is not subscribed", symbol)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	if err := session.request("UNSUBSCRIBE", names); err != nil {
+		return fmt.Errorf("failed to unsubscribe %s: %w", symbol, err)
+	}
+	return nil
+}
+
+// RemoveStreams unsubscribes a managed symbol from some of its streams, like RemoveSymbol. The symbol stays managed
+// even if no streams are left.
+func (m *StreamManager) RemoveStreams(symbol string, streams ...string) error {
+	names := streamNames(symbol, streams)
+	m.mu.Lock()
+	current, ok := m.symbols[symbol]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("symbol %s is not subscribed", symbol)
+	}
+	for _, name := range names {
+		if !slices.Contains(current, name) {
+			m.mu.Unlock()
+			return fmt.Errorf("not subscribed to %s", name)
+		}
+	}
+	m.symbols[symbol] = without(current, names)
+	session := m.session
+	m.mu.Unlock()
+
+	if err := session.request("UNSUBSCRIBE", names); err != nil {
+		return fmt.Errorf("failed to unsubscribe %s from %v: %w", symbol, streams, err)
+	}
+	return nil
+}
+
+// streamNames returns the full names of symbol's streams, e.g. btcusdt@trade.
+func streamNames(symbol string, streams []string) []string {
+	names := make([]string, len(streams))
+	for i, s := range streams {
+		names[i] = strings.ToLower(symbol) + "@" + s
+	}
+	return names
+}
+
+// without returns names minus the ones in remove.
+func without(names, remove []string) []string {
+	var kept []string
+	for _, name := range names {
+		if !slices.Contains(remove, name) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// Symbols returns the managed symbols, sorted.
+func (m *StreamManager) Symbols() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	symbols := make([]string, 0, len(m.symbols))
+	for symbol := range m.symbols {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Streams returns the full names of every managed stream, sorted.
+func (m *StreamManager) Streams() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var streams []string
+	for _, names := range m.symbols {
+		streams = append(streams, names...)
+	}
+	sort.Strings(streams)
+	return streams
+}
+
+func (m *StreamManager) countLocked() int {
+	n := 0
+	for _, names := range m.symbols {
+		n += len(names)
+	}
+	return n
+}
+
+// Listen connects to the raw /ws endpoint of the stream base URL and serves the managed streams until ctx is
+// cancelled or the connection fails, like the other listeners. Wrap it in ListenWithFailover to reconnect.
+func (m *StreamManager) Listen(ctx context.Context, base string) error {
+	session := &managerSession{control: make(chan streamControl), done: make(chan struct{})}
+	m.mu.Lock()
+	m.session = session
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		if m.session == session {
+			m.session = nil
+		}
+		m.mu.Unlock()
+		close(session.done)
+	}()
+	return listenWebSocketControlled(ctx, base+"/ws", "ws", m.Streams, session.control, m.dispatch)
+}
+
+// dispatch passes a message to the handler with the name of the stream it belongs to.
+func (m *StreamManager) dispatch(msg []byte, session WSSession) error {
+	symbol, kind, err := messageKind(msg)
+	if err != nil {
+		return err
+	}
+	suffix, ok := messageStreams[kind]
+	if !ok {
+		return fmt.Errorf("unexpected %s message: %s", kind, msg)
+	}
+	prefix := strings.ToLower(symbol) + "@" + suffix
+	m.mu.Lock()
+	names := m.symbols[symbol]
+	m.mu.Unlock()
+	for _, name := range names {
+		if name == prefix || strings.HasPrefix(name, prefix+"@") {
+			return m.handler(name, msg, session)
+		}
+	}
+	// Unsubscribed, with the message still in flight
+	return nil
+}
+
+// request sends a control request on the session's connection and waits for the answer. Without a session, or if
+// the connection goes away first, the request is left to the next connection, which subscribes to the current set.
+func (s *managerSession) request(method string, params []string) error {
+	if s == nil {
+		return nil
+	}
+	req := streamControl{method: method, params: params, done: make(chan error, 1)}
+	select {
+	case s.control <- req:
+	case <-s.done:
+		return nil
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-s.done:
+		return nil
+	}
+}
+
+// messageKind returns the symbol of a raw stream message and its kind: the event type, or "bookTicker" for spot
+// book tickers, which carry none and are recognised by their update ID.
+func messageKind(msg []byte) (symbol, kind string, err error) {
+	// Every key that differs from a wanted one only in case is named, since encoding/json matches case-insensitively
+	var head struct {
+		EventType string          `json:"e"`
+		EventTime json.RawMessage `json:"E"`
+		Symbol    string          `json:"s"`
+		UpdateID  json.RawMessage `json:"u"`
+		FirstID   json.RawMessage `json:"U"`
+	}
+	if err := json.Unmarshal(msg, &head); err != nil {
+		return "", "", fmt.Errorf("failed to unmarshal stream message: %w, raw message: %s", err, msg)
+	}
+	kind = head.EventType
+	if kind == "" && head.UpdateID != nil {
+		kind = "bookTicker"
+	}
+	if kind == "" || head.Symbol == "" {
+		return "", "", fmt.Errorf("stream message without event type or symbol: %s", msg)
+	}
+	return head.Symbol, kind, nil
+}
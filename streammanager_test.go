@@ -0,0 +1,169 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestMessageKind(t *testing.T) {
+	cases := []struct {
+		msg          []byte
+		symbol, kind string
+	}{
+		{mockbinance.TradeMessage("BTCUSDT", 1, "1.0", "1"), "BTCUSDT", "trade"},
+		{mockbinance.AggTradeMessage("BTCUSDT", 1, "1.0", "1"), "BTCUSDT", "aggTrade"},
+		{mockbinance.DepthUpdateMessage("ETHUSDT", 1, 2, nil, nil), "ETHUSDT", "depthUpdate"},
+		{mockbinance.BookTickerMessage("ETHUSDT", 3, "1.0", "1", "1.1", "1"), "ETHUSDT", "bookTicker"},
+		{mockbinance.MarkPriceMessage("BTCUSDT", 1, "100.0", "0.0001"), "BTCUSDT", "markPriceUpdate"},
+		{mockbinance.AvgPriceMessage("BTCUSDT", 1, "100.0"), "BTCUSDT", "avgPrice"},
+	}
+	for _, c := range cases {
+		symbol, kind, err := messageKind(c.msg)
+		if err != nil || symbol != c.symbol || kind != c.kind {
+			t.Errorf("messageKind(%s) = %q, %q, %v; want %q, %q", c.msg, symbol, kind, err, c.symbol, c.kind)
+		}
+	}
+	if _, _, err := messageKind([]byte(`{"result":null,"id":1}`)); err == nil {
+		t.Errorf("expected an error for a message without event type or symbol")
+	}
+}
+
+// managedMessages collects what a StreamManager hands to its handler, keyed by stream.
+type managedMessages struct {
+	mu   sync.Mutex
+	msgs map[string]int
+}
+
+func (m *managedMessages) handle(stream string, msg []byte, session WSSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.msgs[stream]++
+	return nil
+}
+
+func (m *managedMessages) count(stream string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.msgs[stream]
+}
+
+func (m *managedMessages) waitFor(t *testing.T, stream string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for m.count(stream) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d messages on %s, got %d", n, stream, m.count(stream))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStreamManager_AddAndRemoveSymbolsAtRuntime(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade")
+	srv.SetStream("btcusdt@bookTicker")
+	srv.SetStream("ethusdt@trade")
+
+	got := &managedMessages{msgs: make(map[string]int)}
+	m := NewStreamManager([]string{StreamTrade}, got.handle)
+	// Added before connecting: subscribed by the connection's initial SUBSCRIBE
+	if err := m.AddSymbol("BTCUSDT", StreamTrade, StreamBookTicker); err != nil {
+		t.Fatalf("AddSymbol before connecting: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- m.Listen(ctx, StreamBaseURL) }()
+	waitForSubscribers(t, srv, "btcusdt@bookTicker", 1)
+
+	srv.Publish("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 1, "100.0", "1"))
+	srv.Publish("btcusdt@bookTicker", mockbinance.BookTickerMessage("BTCUSDT", 1, "99.0", "1", "101.0", "1"))
+	got.waitFor(t, "btcusdt@trade", 1)
+	got.waitFor(t, "btcusdt@bookTicker", 1)
+
+	// Added while connected, on the same connection
+	if err := m.AddSymbol("ETHUSDT"); err != nil {
+		t.Fatalf("AddSymbol while connected: %v", err)
+	}
+	if srv.Subscribers("ethusdt@trade") != 1 {
+		t.Fatalf("expected ETHUSDT to be subscribed once acknowledged")
+	}
+	srv.Publish("ethusdt@trade", mockbinance.TradeMessage("ETHUSDT", 1, "10.0", "1"))
+	got.waitFor(t, "ethusdt@trade", 1)
+
+	if err := m.RemoveSymbol("BTCUSDT"); err != nil {
+		t.Fatalf("RemoveSymbol: %v", err)
+	}
+	if srv.Subscribers("btcusdt@trade") != 0 || srv.Subscribers("btcusdt@bookTicker") != 0 {
+		t.Errorf("expected BTCUSDT to be unsubscribed")
+	}
+	if want := []string{"ETHUSDT"}; !reflect.DeepEqual(m.Symbols(), want) {
+		t.Errorf("expected symbols %v, got %v", want, m.Symbols())
+	}
+	if n := srv.Connections("ethusdt@trade"); n != 1 {
+		t.Errorf("expected the symbols to share one connection without reconnecting, got %d subscriptions", n)
+	}
+
+	// The exchange rejects unknown streams; the symbol is forgotten again
+	if err := m.AddSymbol("XYZUSDT"); err == nil || !strings.Contains(err.Error(), "unknown stream") {
+		t.Errorf("expected the rejected subscription to be reported, got %v", err)
+	}
+	if err := m.AddSymbol("ETHUSDT"); err == nil {
+		t.Errorf("expected adding a managed symbol twice to fail")
+	}
+	if err := m.RemoveSymbol("BTCUSDT"); err == nil {
+		t.Errorf("expected removing an unmanaged symbol to fail")
+	}
+	if want := []string{"ethusdt@trade"}; !reflect.DeepEqual(m.Streams(), want) {
+		t.Errorf("expected streams %v, got %v", want, m.Streams())
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Listen to stop with the context, got %v", err)
+	}
+}
+
+func TestStreamManager_ReconnectSubscribesCurrentSet(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade")
+	srv.SetStream("ethusdt@trade")
+	setWSTimings(t, time.Minute, 0, 0)
+
+	got := &managedMessages{msgs: make(map[string]int)}
+	m := NewStreamManager([]string{StreamTrade}, got.handle)
+	if err := m.AddSymbol("BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	first, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- m.Listen(first, StreamBaseURL) }()
+	waitForSubscribers(t, srv, "btcusdt@trade", 1)
+	stop()
+	<-done
+
+	// Changes while disconnected apply to the next connection
+	if err := m.AddSymbol("ETHUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveSymbol("BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	go func() { done <- m.Listen(ctx, StreamBaseURL) }()
+	waitForSubscribers(t, srv, "ethusdt@trade", 1)
+	if n := srv.Connections("btcusdt@trade"); n != 1 {
+		t.Errorf("expected the removed symbol not to be subscribed again, got %d subscriptions", n)
+	}
+	cancel()
+	<-done
+}
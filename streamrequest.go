@@ -0,0 +1,167 @@
+package gobinapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AckTimeout is how long a SUBSCRIBE or UNSUBSCRIBE request may go unacknowledged before the connection is treated
+// as broken.
+var AckTimeout = 10 * time.Second
+
+// ErrAckTimeout is delivered for a control request that was not acknowledged within AckTimeout.
+var ErrAckTimeout = errors.New("stream request not acknowledged")
+
+// StreamRequest is a control request sent on a market data WebSocket, e.g.
+// {"method":"SUBSCRIBE","params":["btcusdt@trade"],"id":1}.
+type StreamRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params,omitempty"`
+	ID     int64    `json:"id"`
+}
+
+// StreamError is an error payload the exchange returned on a market data WebSocket. ID is zero for errors that do
+// not belong to a request.
+type StreamError struct {
+	ID   int64
+	Code int
+	Msg  string
+}
+
+func (e *StreamError) Error() string {
+	if e.ID == 0 {
+		return fmt.Sprintf("stream error %d: %s", e.Code, e.Msg)
+	}
+	return fmt.Sprintf("stream request %d failed with error %d: %s", e.ID, e.Code, e.Msg)
+}
+
+// PendingRequests correlates control requests with the {"result":null,"id":N} acknowledgements and error payloads
+// that answer them, so those responses are not mistaken for market data.
+type PendingRequests struct {
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]*pendingRequest
+}
+
+type pendingRequest struct {
+	req      StreamRequest
+	deadline time.Time
+	done     chan error
+}
+
+// NewPendingRequests creates an empty set of pending requests.
+func NewPendingRequests() *PendingRequests {
+	return &PendingRequests{pending: make(map[int64]*pendingRequest)}
+}
+
+// New registers a request with a fresh ID, due to be acknowledged within AckTimeout of now. The returned channel
+// receives nil on acknowledgement, a *StreamError if the exchange rejected the request or ErrAckTimeout.
+func (p *PendingRequests) New(method string, params []string, now time.Time) (StreamRequest, <-chan error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nextID++
+	req := StreamRequest{Method: method, Params: params, ID: p.nextID}
+	done := make(chan error, 1)
+	p.pending[req.ID] = &pendingRequest{req: req, deadline: now.Add(AckTimeout), done: done}
+	return req, done
+}
+
+// Len returns how many requests are awaiting a response.
+func (p *PendingRequests) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+// NextDeadline returns the earliest acknowledgement deadline, or false if nothing is pending.
+func (p *PendingRequests) NextDeadline() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var next time.Time
+	for _, r := range p.pending {
+		if next.IsZero() || r.deadline.Before(next) {
+			next = r.deadline
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// Expire fails every request whose deadline has passed with ErrAckTimeout and returns them.
+func (p *PendingRequests) Expire(now time.Time) []StreamRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var expired []StreamRequest
+	for id, r := range p.pending {
+		if now.Before(r.deadline) {
+			continue
+		}
+		delete(p.pending, id)
+		r.done <- fmt.Errorf("%s %v (id %d): %w", r.req.Method, r.req.Params, id, ErrAckTimeout)
+		expired = append(expired, r.req)
+	}
+	return expired
+}
+
+// streamResponse covers both error layouts the exchange uses: {"error":{"code":2,"msg":"..."},"id":1} and
+// {"code":2,"msg":"...","id":1}.
+type streamResponse struct {
+	ID     *int64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"error"`
+	Code *int   `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// Resolve checks whether msg is a response to a control request rather than market data. Acknowledgements and
+// errors for pending requests are delivered to the request's channel. An error that matches no pending request is
+// returned, since it means the exchange rejected something on this connection.
+func (p *PendingRequests) Resolve(msg []byte) (bool, error) {
+	// Market data events carry neither an "id" nor an error, so most messages skip the decode below
+	if !bytes.Contains(msg, []byte(`"id"`)) && !bytes.Contains(msg, []byte(`"error"`)) && !bytes.Contains(msg, []byte(`"code"`)) {
+		return false, nil
+	}
+	var resp streamResponse
+	if err := json.Unmarshal(msg, &resp); err != nil {
+		return false, nil
+	}
+	var streamErr *StreamError
+	switch {
+	case resp.Error != nil:
+		streamErr = &StreamError{Code: resp.Error.Code, Msg: resp.Error.Msg}
+	case resp.Code != nil && resp.Msg != "":
+		streamErr = &StreamError{Code: *resp.Code, Msg: resp.Msg}
+	case resp.ID == nil:
+		return false, nil
+	}
+	if resp.ID != nil && streamErr != nil {
+		streamErr.ID = *resp.ID
+	}
+
+	var pending *pendingRequest
+	if resp.ID != nil {
+		p.mu.Lock()
+		pending = p.pending[*resp.ID]
+		delete(p.pending, *resp.ID)
+		p.mu.Unlock()
+	}
+	if pending == nil {
+		if streamErr != nil {
+			return true, streamErr
+		}
+		// A late acknowledgement of a request that already timed out
+		return true, nil
+	}
+	if streamErr != nil {
+		pending.done <- streamErr
+	} else {
+		pending.done <- nil
+	}
+	return true, nil
+}
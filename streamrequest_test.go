@@ -0,0 +1,105 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestPendingRequests_CorrelatesAcksAndErrors(t *testing.T) {
+	now := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	p := NewPendingRequests()
+	ack, ackDone := p.New("SUBSCRIBE", []string{"btcusdt@trade"}, now)
+	rej, rejDone := p.New("SUBSCRIBE", []string{"nope@trade"}, now)
+	_, lostDone := p.New("SUBSCRIBE", []string{"ethusdt@trade"}, now)
+	if ack.ID == rej.ID {
+		t.Fatalf("expected distinct request IDs, got %d twice", ack.ID)
+	}
+
+	if handled, err := p.Resolve([]byte(`{"e":"trade","s":"BTCUSDT","t":1,"p":"1","q":"1"}`)); handled || err != nil {
+		t.Errorf("expected market data not to be handled, got %v, %v", handled, err)
+	}
+	if handled, err := p.Resolve([]byte(`{"result":null,"id":1}`)); !handled || err != nil {
+		t.Errorf("expected the ack to be handled, got %v, %v", handled, err)
+	}
+	if err := <-ackDone; err != nil {
+		t.Errorf("expected the request to be acknowledged, got %v", err)
+	}
+	if handled, err := p.Resolve([]byte(`{"error":{"code":2,"msg":"Invalid request"},"id":2}`)); !handled || err != nil {
+		t.Errorf("expected the error payload to be handled, got %v, %v", handled, err)
+	}
+	var streamErr *StreamError
+	if err := <-rejDone; !errors.As(err, &streamErr) || streamErr.ID != 2 || streamErr.Code != 2 {
+		t.Errorf("expected a StreamError for request 2, got %v", err)
+	}
+
+	// Errors that belong to no pending request are surfaced to the caller
+	if handled, err := p.Resolve([]byte(`{"code":3,"msg":"Invalid JSON"}`)); !handled || !errors.As(err, &streamErr) || streamErr.Code != 3 {
+		t.Errorf("expected an unsolicited error to be returned, got %v, %v", handled, err)
+	}
+
+	if deadline, ok := p.NextDeadline(); !ok || !deadline.Equal(now.Add(AckTimeout)) {
+		t.Errorf("expected the remaining request due at %s, got %s", now.Add(AckTimeout), deadline)
+	}
+	if expired := p.Expire(now); len(expired) != 0 {
+		t.Errorf("expected nothing to expire yet, got %v", expired)
+	}
+	if expired := p.Expire(now.Add(AckTimeout)); len(expired) != 1 || expired[0].Params[0] != "ethusdt@trade" {
+		t.Errorf("expected the unanswered request to expire, got %v", expired)
+	}
+	if err := <-lostDone; !errors.Is(err, ErrAckTimeout) {
+		t.Errorf("expected ErrAckTimeout, got %v", err)
+	}
+	if p.Len() != 0 {
+		t.Errorf("expected no pending requests, got %d", p.Len())
+	}
+}
+
+func TestListenStreams_MockServer(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 7, "100.5", "0.25"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	msgs := make(chan string, 2)
+	done := make(chan error, 1)
+	go func() {
+		done <- ListenStreams(ctx, []string{"btcusdt@trade"}, func(msg []byte) error {
+			msgs <- string(msg)
+			return nil
+		})
+	}()
+	defer func() { cancel(); <-done }()
+
+	select {
+	case msg := <-msgs:
+		if !strings.Contains(msg, `"e":"trade"`) {
+			t.Errorf("expected only market data to reach the handler, got %s", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for a trade after subscribing")
+	}
+}
+
+func TestListenStreams_SurfacesRejectedAndUnackedSubscriptions(t *testing.T) {
+	srv := useMockServer(t)
+	handler := func([]byte) error { return nil }
+
+	err := ListenStreams(context.Background(), []string{"unknown@trade"}, handler)
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) || !strings.Contains(streamErr.Msg, "unknown stream") {
+		t.Errorf("expected the rejection to be returned, got %v", err)
+	}
+
+	oldTimeout := AckTimeout
+	AckTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { AckTimeout = oldTimeout })
+	srv.SetStream("btcusdt@trade")
+	srv.SetIgnoreRequests(true)
+	if err := ListenStreams(context.Background(), []string{"btcusdt@trade"}, handler); !errors.Is(err, ErrAckTimeout) {
+		t.Errorf("expected ErrAckTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package gobinapi
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Stream names selectable per instrument through Config.Streams.
+const (
+	StreamTrade       = "trade"
+	StreamAggTrade    = "aggTrade"
+	StreamDepth       = "depth"
+	StreamBookTicker  = "bookTicker"
+	StreamSnapshot    = "snapshot"
+	StreamSnapshotTop = "snapshotTop"
+	StreamBookTop     = "bookTop"
+	// StreamMarkPrice is only available on USD-M futures.
+	StreamMarkPrice = "markPrice"
+	// StreamTicker is the 24 hour rolling window ticker, and StreamTicker1h, StreamTicker4h and StreamTicker1d are
+	// the spot tickers of shorter windows. Each is recorded as the data type of its name (see Ticker24h).
+	StreamTicker   = "ticker"
+	StreamTicker1h = "ticker_1h"
+	StreamTicker4h = "ticker_4h"
+	StreamTicker1d = "ticker_1d"
+	// StreamAvgPrice is the spot 5 minute average price. Like the ticker streams, it is only recorded when selected.
+	StreamAvgPrice = "avgPrice"
+)
+
+// TickerStreams lists the ticker streams a spot instrument can record. Unlike AllStreams, they are only recorded
+// when selected in Config.Streams; USD-M futures only have StreamTicker.
+var TickerStreams = []string{StreamTicker, StreamTicker1h, StreamTicker4h, StreamTicker1d}
+
+// AllStreams lists every stream a spot instrument can record, which is what instruments without a selection record.
+var AllStreams = []string{StreamTrade, StreamAggTrade, StreamDepth, StreamBookTicker, StreamSnapshot, StreamSnapshotTop, StreamBookTop}
+
+// FuturesStreams is AllStreams for USD-M futures, which have no raw trade stream but add mark prices.
+var FuturesStreams = []string{StreamAggTrade, StreamDepth, StreamBookTicker, StreamMarkPrice, StreamSnapshot, StreamSnapshotTop, StreamBookTop}
+
+// marketStreams returns the streams available on market.
+func marketStreams(market string) []string {
+	if market == MarketUSDM {
+		return FuturesStreams
+	}
+	return AllStreams
+}
+
+// StreamsFor returns the set of streams recorded for instrument: its entry in Streams, or every stream of the
+// configured market (AllStreams or FuturesStreams) if it has none.
+func (cfg Config) StreamsFor(instrument string) map[string]bool {
+	streams, ok := cfg.Streams[instrument]
+	if !ok {
+		streams = marketStreams(cfg.Market)
+	}
+	set := make(map[string]bool, len(streams))
+	for _, s := range streams {
+		set[s] = true
+	}
+	return set
+}
+
+// validateStreams checks the instrument list and per-instrument stream selection.
+func (cfg Config) validateStreams() error {
+	seen := make(map[string]bool, len(cfg.Instruments))
+	for _, instrument := range cfg.Instruments {
+		if instrument == "" || strings.ToUpper(instrument) != instrument || strings.ContainsAny(instrument, " \t/@") {
+			return fmt.Errorf("config: invalid instrument %q, symbols are upper case like BTCUSDT", instrument)
+		}
+		if seen[instrument] {
+			return fmt.Errorf("config: instrument %s is listed twice", instrument)
+		}
+		seen[instrument] = true
+	}
+	for instrument, streams := range cfg.Streams {
+		if !seen[instrument] {
+			return fmt.Errorf("config: streams selected for %s, which is not a configured instrument", instrument)
+		}
+		if len(streams) == 0 {
+			return fmt.Errorf("config: no streams selected for %s", instrument)
+		}
+		for _, s := range streams {
+			if !isStream(cfg.Market, s) {
+				return fmt.Errorf("config: unknown stream %q for %s, want one of %s", s, instrument, strings.Join(selectableStreams(cfg.Market), ", "))
+			}
+			if s == StreamSnapshotTop && cfg.TopOfBookInterval <= 0 {
+				return fmt.Errorf("config: %s records %s, which requires a positive top-of-book interval", instrument, s)
+			}
+			if s == StreamBookTop && cfg.BookTopInterval <= 0 {
+				return fmt.Errorf("config: %s records %s, which requires a positive book top interval", instrument, s)
+			}
+		}
+		if selected := cfg.StreamsFor(instrument); selected[StreamBookTop] && !selected[StreamDepth] {
+			return fmt.Errorf("config: %s records %s, which is derived from the %s stream", instrument, StreamBookTop, StreamDepth)
+		}
+	}
+	return nil
+}
+
+// selectableStreams returns the streams that can be selected on market: its default streams plus its opt-in ticker
+// and average price streams.
+func selectableStreams(market string) []string {
+	if market == MarketUSDM {
+		return append(slices.Clone(FuturesStreams), StreamTicker)
+	}
+	return append(append(slices.Clone(AllStreams), TickerStreams...), StreamAvgPrice)
+}
+
+func isStream(market, name string) bool {
+	return slices.Contains(selectableStreams(market), name)
+}
+
+// websocketStreams returns how many WebSocket streams the configured instruments subscribe to; snapshots and book
+// tops come from REST and the local order book instead, and book tickers from their own connection with
+// AllBookTickers.
+func (cfg Config) websocketStreams() int {
+	n := 0
+	for _, instrument := range cfg.Instruments {
+		for s := range cfg.StreamsFor(instrument) {
+			switch s {
+			case StreamTrade, StreamAggTrade, StreamDepth, StreamMarkPrice, StreamTicker, StreamTicker1h, StreamTicker4h, StreamTicker1d,
+				StreamAvgPrice:
+				n++
+			case StreamBookTicker:
+				if !cfg.AllBookTickers {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
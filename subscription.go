@@ -1,8 +1,38 @@
-package main
+package gobinapi
 
-// RecorderWriter defines the minimal interface for writing records.
-type RecorderWriter interface {
-	Write(record interface{}) error
+// RecorderWriter defines the minimal interface for writing records of type T.
+type RecorderWriter[T any] interface {
+	Write(record T) error
+}
+
+// FanOutWriter writes every record to each of its RecorderWriters, e.g. a parquet Recorder plus a live sink.
+// All writers are attempted even if one fails; the first error is returned.
+type FanOutWriter[T any] []RecorderWriter[T]
+
+// Write writes record to every underlying RecorderWriter.
+func (f FanOutWriter[T]) Write(record T) error {
+	var firstErr error
+	for _, w := range f {
+		if err := w.Write(record); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// untypedWriter passes records of type T on to a writer accepting records of any type.
+type untypedWriter[T any] struct {
+	next RecorderWriter[any]
+}
+
+func (w untypedWriter[T]) Write(record T) error {
+	return w.next.Write(record)
+}
+
+// UntypedWriter adapts a writer accepting records of any type, like InfluxSink, to records of type T, e.g. to add it
+// to a FanOutWriter[T].
+func UntypedWriter[T any](next RecorderWriter[any]) RecorderWriter[T] {
+	return untypedWriter[T]{next: next}
 }
 
 // LoggerInterface defines the minimal interface for logging required by subscription functions.
@@ -30,7 +60,7 @@ func ProcessOrderBookDiffMessage(diff OrderBookDiff, lastSnapshotId, lastProcess
 }
 
 // SubscribeTrades listens to the trade channel and writes each Trade to the provided RecorderWriter.
-func SubscribeTrades(tradeCh <-chan Trade, recorder RecorderWriter, logger LoggerInterface) {
+func SubscribeTrades(tradeCh <-chan Trade, recorder RecorderWriter[Trade], logger LoggerInterface) {
 	for trade := range tradeCh {
 		if err := recorder.Write(trade); err != nil {
 			logger.Errorf("error writing trade: %v", err)
@@ -39,7 +69,7 @@ func SubscribeTrades(tradeCh <-chan Trade, recorder RecorderWriter, logger Logge
 }
 
 // SubscribeAggTrades listens to the aggregated trade channel and writes each AggTrade to the provided RecorderWriter.
-func SubscribeAggTrades(aggTradeCh <-chan AggTrade, recorder RecorderWriter, logger LoggerInterface) {
+func SubscribeAggTrades(aggTradeCh <-chan AggTrade, recorder RecorderWriter[AggTrade], logger LoggerInterface) {
 	for aggTrade := range aggTradeCh {
 		if err := recorder.Write(aggTrade); err != nil {
 			logger.Errorf("error writing aggregated trade: %v", err)
@@ -48,7 +78,7 @@ func SubscribeAggTrades(aggTradeCh <-chan AggTrade, recorder RecorderWriter, log
 }
 
 // SubscribeBestPrice listens to the best price channel and writes each BestPrice to the provided RecorderWriter.
-func SubscribeBestPrice(bestPriceCh <-chan BestPrice, recorder RecorderWriter, logger LoggerInterface) {
+func SubscribeBestPrice(bestPriceCh <-chan BestPrice, recorder RecorderWriter[BestPrice], logger LoggerInterface) {
 	for bestPrice := range bestPriceCh {
 		if err := recorder.Write(bestPrice); err != nil {
 			logger.Errorf("error writing best price: %v", err)
@@ -56,8 +86,35 @@ func SubscribeBestPrice(bestPriceCh <-chan BestPrice, recorder RecorderWriter, l
 	}
 }
 
+// SubscribeMarkPrices listens to the mark price channel and writes each MarkPrice to the provided RecorderWriter.
+func SubscribeMarkPrices(markPriceCh <-chan MarkPrice, recorder RecorderWriter[MarkPrice], logger LoggerInterface) {
+	for markPrice := range markPriceCh {
+		if err := recorder.Write(markPrice); err != nil {
+			logger.Errorf("error writing mark price: %v", err)
+		}
+	}
+}
+
+// SubscribeAvgPrices listens to the average price channel and writes each AvgPrice to the provided RecorderWriter.
+func SubscribeAvgPrices(avgPriceCh <-chan AvgPrice, recorder RecorderWriter[AvgPrice], logger LoggerInterface) {
+	for avgPrice := range avgPriceCh {
+		if err := recorder.Write(avgPrice); err != nil {
+			logger.Errorf("error writing average price: %v", err)
+		}
+	}
+}
+
+// SubscribeTickers writes every ticker from tickerCh to recorder until the channel is closed.
+func SubscribeTickers(tickerCh <-chan Ticker24h, recorder RecorderWriter[Ticker24h], logger LoggerInterface) {
+	for ticker := range tickerCh {
+		if err := recorder.Write(ticker); err != nil {
+			logger.Errorf("error writing ticker: %v", err)
+		}
+	}
+}
+
 // SubscribeSnapshots listens to the order book snapshot channel and writes each OrderBookSnapshot to the provided RecorderWriter.
-func SubscribeSnapshots(snapshotCh <-chan OrderBookSnapshot, recorder RecorderWriter, logger LoggerInterface) {
+func SubscribeSnapshots(snapshotCh <-chan OrderBookSnapshot, recorder RecorderWriter[OrderBookSnapshot], logger LoggerInterface) {
 	for snapshot := range snapshotCh {
 		if err := recorder.Write(snapshot); err != nil {
 			logger.Errorf("error writing order book snapshot: %v", err)
@@ -67,7 +124,17 @@ func SubscribeSnapshots(snapshotCh <-chan OrderBookSnapshot, recorder RecorderWr
 
 // SubscribeOrderBookDiff listens to the order book diff channel alongside the snapshot channel.
 // It applies filtering rules to ensure that outdated diff messages are discarded and sequence gaps trigger a new snapshot request.
-func SubscribeOrderBookDiff(diffCh <-chan OrderBookDiff, snapshotCh <-chan OrderBookSnapshot, diffRecorder RecorderWriter, snapshotRequest func(), logger LoggerInterface) {
+func SubscribeOrderBookDiff(diffCh <-chan OrderBookDiff, snapshotCh <-chan OrderBookSnapshot, diffRecorder RecorderWriter[OrderBookDiff], snapshotRequest func(), logger LoggerInterface) {
+	subscribeOrderBookDiff(ProcessOrderBookDiffMessage, diffCh, snapshotCh, diffRecorder, snapshotRequest, logger)
+}
+
+// SubscribeFuturesOrderBookDiff is SubscribeOrderBookDiff for USD-M futures diffs, which are sequenced by
+// ProcessFuturesOrderBookDiffMessage.
+func SubscribeFuturesOrderBookDiff(diffCh <-chan OrderBookDiff, snapshotCh <-chan OrderBookSnapshot, diffRecorder RecorderWriter[OrderBookDiff], snapshotRequest func(), logger LoggerInterface) {
+	subscribeOrderBookDiff(ProcessFuturesOrderBookDiffMessage, diffCh, snapshotCh, diffRecorder, snapshotRequest, logger)
+}
+
+func subscribeOrderBookDiff(sequence DiffSequencer, diffCh <-chan OrderBookDiff, snapshotCh <-chan OrderBookSnapshot, diffRecorder RecorderWriter[OrderBookDiff], snapshotRequest func(), logger LoggerInterface) {
 	snapshotRequest()
 	var lastSnapshotId int64 = 0
 	var lastProcessedId int64 = 0
@@ -83,16 +150,21 @@ func SubscribeOrderBookDiff(diffCh <-chan OrderBookDiff, snapshotCh <-chan Order
 			logger.Infof("Received new snapshot with LastUpdateID: %d", lastSnapshotId)
 		case diff, ok := <-diffCh:
 			if !ok {
-				logger.Errorf("order book diff channel closed")
+				logger.Infof("order book diff channel closed")
 				return
 			}
 			if lastSnapshotId == 0 {
 				logger.Infof("No snapshot received yet; skipping diff message with FinalUpdateID: %d", diff.FinalUpdateID)
 				continue
 			}
-			recordMsg, newProcessedId, gapDetected := ProcessOrderBookDiffMessage(diff, lastSnapshotId, lastProcessedId)
+			recordMsg, newProcessedId, gapDetected := sequence(diff, lastSnapshotId, lastProcessedId)
 			if gapDetected {
-				logger.Errorf("Sequence gap detected: expected %d but got %d. Triggering new snapshot request.", lastProcessedId+1, diff.FirstUpdateID)
+				if diff.PrevFinalUpdateID != 0 {
+					logger.Errorf("Sequence gap detected: expected pu %d but got %d. Triggering new snapshot request.", lastProcessedId, diff.PrevFinalUpdateID)
+				} else {
+					logger.Errorf("Sequence gap detected: expected %d but got %d. Triggering new snapshot request.", lastProcessedId+1, diff.FirstUpdateID)
+				}
+				DefaultAlerts.Raise(Alert{Kind: AlertSequenceGap, Symbol: diff.Symbol, Stream: "depth", Message: "order book diff sequence gap, resyncing from a new snapshot"})
 				snapshotRequest()
 				lastSnapshotId = 0
 				lastProcessedId = 0
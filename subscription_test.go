@@ -1,4 +1,4 @@
-package main
+package gobinapi
 
 import (
 	"fmt"
@@ -13,13 +13,9 @@ type FakeRecorder struct {
 	mu      sync.Mutex
 }
 
-func (fr *FakeRecorder) Write(record interface{}) error {
+func (fr *FakeRecorder) Write(agg AggTrade) error {
 	fr.mu.Lock()
 	defer fr.mu.Unlock()
-	agg, ok := record.(AggTrade)
-	if !ok {
-		return fmt.Errorf("expected AggTrade type, got %T", record)
-	}
 	fr.records = append(fr.records, agg)
 	return nil
 }
@@ -111,13 +107,9 @@ type FakeBestPriceRecorder struct {
 	mu      sync.Mutex
 }
 
-func (f *FakeBestPriceRecorder) Write(record interface{}) error {
+func (f *FakeBestPriceRecorder) Write(bp BestPrice) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	bp, ok := record.(BestPrice)
-	if !ok {
-		return fmt.Errorf("expected BestPrice type, got %T", record)
-	}
 	f.records = append(f.records, bp)
 	return nil
 }
@@ -189,14 +181,9 @@ type FakeSnapshotRecorder struct {
 	mu      sync.Mutex
 }
 
-func (fsr *FakeSnapshotRecorder) Write(record interface{}) error {
+func (fsr *FakeSnapshotRecorder) Write(snapshot OrderBookSnapshot) error {
 	fsr.mu.Lock()
 	defer fsr.mu.Unlock()
-
-	snapshot, ok := record.(OrderBookSnapshot)
-	if !ok {
-		return fmt.Errorf("expected OrderBookSnapshot type, got %T", record)
-	}
 	fsr.records = append(fsr.records, snapshot)
 	return nil
 }
@@ -272,11 +259,7 @@ type FakeDiffRecorder struct {
 	mu      sync.Mutex
 }
 
-func (f *FakeDiffRecorder) Write(record interface{}) error {
-	diff, ok := record.(OrderBookDiff)
-	if !ok {
-		return fmt.Errorf("expected OrderBookDiff, got %T", record)
-	}
+func (f *FakeDiffRecorder) Write(diff OrderBookDiff) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.records = append(f.records, diff)
@@ -413,3 +396,39 @@ func TestSubscribeOrderBookDiff_IgnoreOutdatedDiff(t *testing.T) {
 		t.Errorf("Expected snapshotRequest not to be called, but it was called %d times", snapshotRequestCalled)
 	}
 }
+
+type failingWriter struct{}
+
+func (failingWriter) Write(record BestPrice) error { return fmt.Errorf("disk full") }
+
+func TestFanOutWriter_WritesToAllWriters(t *testing.T) {
+	first := &FakeBestPriceRecorder{}
+	second := &FakeBestPriceRecorder{}
+	w := FanOutWriter[BestPrice]{first, failingWriter{}, second}
+	err := w.Write(BestPrice{UpdateID: 1})
+	if err == nil || err.Error() != "disk full" {
+		t.Errorf("expected the failing writer's error, got %v", err)
+	}
+	if len(first.GetRecords()) != 1 || len(second.GetRecords()) != 1 {
+		t.Errorf("expected every writer to receive the record despite the failure")
+	}
+}
+
+type anyWriter []interface{}
+
+func (w *anyWriter) Write(record interface{}) error {
+	*w = append(*w, record)
+	return nil
+}
+
+func TestUntypedWriter_PassesTypedRecordsOn(t *testing.T) {
+	typed := &FakeBestPriceRecorder{}
+	untyped := &anyWriter{}
+	w := FanOutWriter[BestPrice]{typed, UntypedWriter[BestPrice](untyped)}
+	if err := w.Write(BestPrice{UpdateID: 7}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if len(*untyped) != 1 || (*untyped)[0].(BestPrice).UpdateID != 7 || len(typed.GetRecords()) != 1 {
+		t.Errorf("expected both writers to receive the record, got %v and %v", *untyped, typed.GetRecords())
+	}
+}
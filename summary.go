@@ -0,0 +1,258 @@
+package gobinapi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SummaryDataType is the data type of daily summary files, e.g. "BTCUSDT_summary_2025-02-19.parquet".
+const SummaryDataType = "summary"
+
+// DailySummary is a row of a daily summary file: the activity of one of a symbol's data types over a UTC day, for
+// quick sanity checks and dashboards without reading the day's recordings. The price columns are only set for trades
+// and aggregate trades.
+type DailySummary struct {
+	Symbol   string `json:"symbol" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Date     string `json:"date" parquet:"name=date, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	DataType string `json:"data_type" parquet:"name=data_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Messages int64  `json:"messages" parquet:"name=messages, type=INT64"`
+	// FirstEventTime and LastEventTime are the earliest and latest event times, zero for types without one.
+	FirstEventTime int64 `json:"first_event_time" timestamp:"millis" parquet:"name=first_event_time, type=INT64"`
+	LastEventTime  int64 `json:"last_event_time" timestamp:"millis" parquet:"name=last_event_time, type=INT64"`
+	// Reconnects counts the connections records were received on after the first of the day.
+	Reconnects int64 `json:"reconnects" parquet:"name=reconnects, type=INT64"`
+	// Gaps counts breaks in the ID sequence of trades, aggregate trades and order book diffs.
+	Gaps int64 `json:"gaps" parquet:"name=gaps, type=INT64"`
+
+	MinPrice *float64 `json:"min_price,omitempty" parquet:"name=min_price, type=DOUBLE, repetitiontype=OPTIONAL"`
+	MaxPrice *float64 `json:"max_price,omitempty" parquet:"name=max_price, type=DOUBLE, repetitiontype=OPTIONAL"`
+	VWAP     *float64 `json:"vwap,omitempty" parquet:"name=vwap, type=DOUBLE, repetitiontype=OPTIONAL"`
+	// Volume is in base units and QuoteVolume in quote units.
+	Volume      *float64 `json:"volume,omitempty" parquet:"name=volume, type=DOUBLE, repetitiontype=OPTIONAL"`
+	QuoteVolume *float64 `json:"quote_volume,omitempty" parquet:"name=quote_volume, type=DOUBLE, repetitiontype=OPTIONAL"`
+}
+
+// SummaryCollector accumulates the records written by recorders (see Recorder.SetSummary) into a DailySummary per
+// symbol and data type. A symbol's summary file is written when the first record of the next UTC day is written for
+// it, and on Close for the day in progress; after a restart the day's summary continues in the next part file.
+type SummaryCollector struct {
+	mu         sync.Mutex
+	layout     FileLayout
+	logger     LoggerInterface
+	onFinalize func(filePath string)
+	symbols    map[string]*symbolSummary
+}
+
+// symbolSummary is the day in progress of one symbol.
+type symbolSummary struct {
+	day   time.Time
+	types map[string]*summaryAccumulator
+}
+
+// summaryAccumulator is the day in progress of one of a symbol's data types.
+type summaryAccumulator struct {
+	row      DailySummary
+	sessions map[string]bool
+	prevLast int64
+	havePrev bool
+
+	trades           bool
+	minPrice         float64
+	maxPrice         float64
+	volume, notional float64
+}
+
+// NewSummaryCollector creates a collector writing summary files according to DefaultFileLayout.
+func NewSummaryCollector(logger LoggerInterface) *SummaryCollector {
+	return &SummaryCollector{layout: DefaultFileLayout, logger: logger, symbols: make(map[string]*symbolSummary)}
+}
+
+// SetFinalizeHook sets a function called with the path of every summary file written, e.g. to queue it for upload.
+// It must be called before the first Observe.
+func (c *SummaryCollector) SetFinalizeHook(hook func(filePath string)) {
+	c.onFinalize = hook
+}
+
+// Observe adds a record of symbol's dataType written at now.
+func (c *SummaryCollector) Observe(symbol, dataType string, record interface{}, now time.Time) {
+	day := now.UTC().Truncate(24 * time.Hour)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.symbols[symbol]
+	if s != nil && !s.day.Equal(day) {
+		c.write(symbol, s)
+		s = nil
+	}
+	if s == nil {
+		s = &symbolSummary{day: day, types: make(map[string]*summaryAccumulator)}
+		c.symbols[symbol] = s
+	}
+	a := s.types[dataType]
+	if a == nil {
+		a = &summaryAccumulator{row: DailySummary{Symbol: symbol, Date: day.Format("2006-01-02"), DataType: dataType},
+			sessions: make(map[string]bool)}
+		s.types[dataType] = a
+	}
+	a.add(record)
+}
+
+// Close writes the summaries of the days in progress.
+func (c *SummaryCollector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var firstErr error
+	for symbol, s := range c.symbols {
+		if err := c.write(symbol, s); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.symbols, symbol)
+	}
+	return firstErr
+}
+
+// write writes the summary file of a symbol's day, logging failures. It must be called with c.mu held.
+func (c *SummaryCollector) write(symbol string, s *symbolSummary) error {
+	rows := make([]DailySummary, 0, len(s.types))
+	for _, a := range s.types {
+		rows = append(rows, a.summary())
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].DataType < rows[j].DataType })
+
+	part := 0
+	path := c.layout.FilePath(SummaryDataType, symbol, s.day, part)
+	for FileExists(path) {
+		part++
+		path = c.layout.FilePath(SummaryDataType, symbol, s.day, part)
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = WriteParquetFile(path, rows)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to write the daily summary of %s: %w", symbol, err)
+		c.logger.Errorf("%v", err)
+		return err
+	}
+	c.logger.Infof("Wrote the daily summary of %s to %s", symbol, path)
+	if c.onFinalize != nil {
+		c.onFinalize(path)
+	}
+	return nil
+}
+
+// add counts a record into the summary.
+func (a *summaryAccumulator) add(record interface{}) {
+	a.row.Messages++
+	if t, ok := recordEventTime(record); ok {
+		ms := t.UnixMilli()
+		if a.row.FirstEventTime == 0 || ms < a.row.FirstEventTime {
+			a.row.FirstEventTime = ms
+		}
+		a.row.LastEventTime = max(a.row.LastEventTime, ms)
+	}
+	if id := recordConnID(record); id != "" && !a.sessions[id] {
+		if len(a.sessions) > 0 {
+			a.row.Reconnects++
+		}
+		a.sessions[id] = true
+	}
+	if first, last, ok := sequenceIDs(record); ok {
+		if diff, isDiff := record.(OrderBookDiff); isDiff && diff.PrevFinalUpdateID != 0 {
+			if a.havePrev && diff.PrevFinalUpdateID != a.prevLast {
+				a.row.Gaps++
+			}
+		} else if a.havePrev && first > a.prevLast+1 {
+			a.row.Gaps++
+		}
+		if !a.havePrev || last > a.prevLast {
+			a.prevLast, a.havePrev = last, true
+		}
+	}
+
+	var price, qty string
+	switch r := record.(type) {
+	case Trade:
+		price, qty = r.Price, r.Quantity
+	case AggTrade:
+		price, qty = r.Price, r.Quantity
+	default:
+		return
+	}
+	p, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return
+	}
+	q, err := strconv.ParseFloat(qty, 64)
+	if err != nil {
+		return
+	}
+	if !a.trades || p < a.minPrice {
+		a.minPrice = p
+	}
+	if !a.trades || p > a.maxPrice {
+		a.maxPrice = p
+	}
+	a.trades = true
+	a.volume += q
+	a.notional += p * q
+}
+
+// summary returns the summary row of the records added so far.
+func (a *summaryAccumulator) summary() DailySummary {
+	row := a.row
+	if a.trades {
+		minPrice, maxPrice, volume, notional := a.minPrice, a.maxPrice, a.volume, a.notional
+		row.MinPrice, row.MaxPrice, row.Volume, row.QuoteVolume = &minPrice, &maxPrice, &volume, &notional
+		if volume > 0 {
+			vwap := notional / volume
+			row.VWAP = &vwap
+		}
+	}
+	return row
+}
+
+// recordConnID returns the ID of the WebSocket session a record was received on, empty for records that were not.
+func recordConnID(record interface{}) string {
+	switch r := record.(type) {
+	case Trade:
+		return r.ConnID
+	case AggTrade:
+		return r.ConnID
+	case OrderBookDiff:
+		return r.ConnID
+	case BestPrice:
+		return r.ConnID
+	case MarkPrice:
+		return r.ConnID
+	case AvgPrice:
+		return r.ConnID
+	case Ticker24h:
+		return r.ConnID
+	case OptionTrade:
+		return r.ConnID
+	case OptionTicker:
+		return r.ConnID
+	case OptionMarkPrice:
+		return r.ConnID
+	case OptionOpenInterest:
+		return r.ConnID
+	case ExecutionReport:
+		return r.ConnID
+	case AccountBalance:
+		return r.ConnID
+	case BalanceUpdate:
+		return r.ConnID
+	}
+	return ""
+}
+
+// SetSummary makes the recorder add every record it writes to collector's daily summary. It must be called before
+// the first Write.
+func (r *Recorder[T]) SetSummary(collector *SummaryCollector) {
+	r.summary = collector
+}
@@ -0,0 +1,100 @@
+package gobinapi
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRecorder_WritesDailySummaryAtRotation records trades and diffs over midnight and checks that the day's summary
+// is written with the first record of the next day, and the next day's on Close.
+func TestRecorder_WritesDailySummaryAtRotation(t *testing.T) {
+	day := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+	clock := useFakeClock(t, day.Add(23*time.Hour))
+	t.Chdir(t.TempDir())
+	summary := NewSummaryCollector(&FakeLogger{})
+	var finalized []string
+	summary.SetFinalizeHook(func(filePath string) { finalized = append(finalized, filePath) })
+	trades, err := NewRecorder[Trade]("BTCUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	trades.SetSummary(summary)
+	diffs, err := NewRecorder[OrderBookDiff]("BTCUSDT", "orderBookDiff", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	diffs.SetSummary(summary)
+
+	ms := func(d time.Duration) int64 { return day.Add(d).UnixMilli() }
+	for _, trade := range []Trade{
+		{TradeID: 1, EventTime: ms(time.Hour), Price: "100", Quantity: "1", ConnID: "a"},
+		{TradeID: 2, EventTime: ms(2 * time.Hour), Price: "110", Quantity: "3", ConnID: "a"},
+		{TradeID: 5, EventTime: ms(3 * time.Hour), Price: "90", Quantity: "1", ConnID: "b"},
+	} {
+		if err := trades.Write(trade); err != nil {
+			t.Fatalf("failed to write trade: %v", err)
+		}
+	}
+	for _, diff := range []OrderBookDiff{
+		{FirstUpdateID: 1, FinalUpdateID: 5, EventTime: ms(time.Hour)},
+		{FirstUpdateID: 6, FinalUpdateID: 9, EventTime: ms(4 * time.Hour)},
+	} {
+		if err := diffs.Write(diff); err != nil {
+			t.Fatalf("failed to write diff: %v", err)
+		}
+	}
+
+	clock.Advance(2 * time.Hour)
+	if err := diffs.Write(OrderBookDiff{FirstUpdateID: 10, FinalUpdateID: 12, EventTime: ms(25 * time.Hour)}); err != nil {
+		t.Fatalf("failed to write diff: %v", err)
+	}
+	path := DefaultFileLayout.FilePath(SummaryDataType, "BTCUSDT", day, 0)
+	if len(finalized) != 1 || finalized[0] != path {
+		t.Fatalf("expected the summary of the first day at %s, got %v", path, finalized)
+	}
+	rows, err := ReadParquetFile[DailySummary](path)
+	if err != nil {
+		t.Fatalf("failed to read summary: %v", err)
+	}
+	if len(rows) != 2 || rows[0].DataType != "orderBookDiff" || rows[1].DataType != "trade" {
+		t.Fatalf("expected a row per data type, got %+v", rows)
+	}
+	diffRow, tradeRow := rows[0], rows[1]
+	if diffRow.Messages != 2 || diffRow.Gaps != 0 || diffRow.FirstEventTime != ms(time.Hour) || diffRow.LastEventTime != ms(4*time.Hour) || diffRow.VWAP != nil {
+		t.Errorf("unexpected diff summary %+v", diffRow)
+	}
+	if tradeRow.Date != "2025-02-19" || tradeRow.Messages != 3 || tradeRow.Gaps != 1 || tradeRow.Reconnects != 1 {
+		t.Errorf("unexpected trade summary %+v", tradeRow)
+	}
+	if tradeRow.VWAP == nil || *tradeRow.MinPrice != 90 || *tradeRow.MaxPrice != 110 || *tradeRow.Volume != 5 ||
+		*tradeRow.QuoteVolume != 520 || *tradeRow.VWAP != 104 {
+		t.Errorf("unexpected trade prices %+v", tradeRow)
+	}
+
+	if err := summary.Close(); err != nil {
+		t.Fatalf("failed to close summaries: %v", err)
+	}
+	next := DefaultFileLayout.FilePath(SummaryDataType, "BTCUSDT", day.Add(24*time.Hour), 0)
+	if n, ok := parquetRowCount[DailySummary](next); !ok || n != 1 {
+		t.Errorf("expected the day in progress to be summarised on close, got %d rows (%v)", n, ok)
+	}
+	trades.Close()
+	diffs.Close()
+}
+
+func TestSummaryCollector_ContinuesInNextPart(t *testing.T) {
+	day := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+	t.Chdir(t.TempDir())
+	for restart := 0; restart < 2; restart++ {
+		summary := NewSummaryCollector(&FakeLogger{})
+		summary.Observe("BTCUSDT", "trade", Trade{TradeID: int64(restart + 1)}, day.Add(time.Hour))
+		if err := summary.Close(); err != nil {
+			t.Fatalf("failed to close summaries: %v", err)
+		}
+	}
+	for part := 0; part < 2; part++ {
+		if path := DefaultFileLayout.FilePath(SummaryDataType, "BTCUSDT", day, part); !FileExists(path) {
+			t.Errorf("expected summary part %d at %s", part, path)
+		}
+	}
+}
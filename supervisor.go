@@ -0,0 +1,79 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+func init() {
+	DefaultMetrics.Describe("binance_task_restarts_total", "counter", "Restarts of supervised stream tasks after an error or panic, per task.")
+}
+
+// SupervisorPolicy says how Supervise restarts a failing task: after Backoff, doubling up to MaxBackoff with every
+// consecutive failure, until it has failed MaxFailures times within Window, which escalates the failure.
+type SupervisorPolicy struct {
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	MaxFailures int
+	Window      time.Duration
+}
+
+// DefaultSupervisorPolicy supervises the listeners and subscription handlers of every stream Run records.
+var DefaultSupervisorPolicy = SupervisorPolicy{
+	Backoff:     time.Second,
+	MaxBackoff:  time.Minute,
+	MaxFailures: 5,
+	Window:      10 * time.Minute,
+}
+
+// Supervise runs task, named name in logs and metrics, isolating its failures: when task returns an error or
+// panics, it is restarted after a backoff as policy says, so one failing stream does not stop the others. Supervise
+// returns nil once task returns nil or ctx is cancelled, and an error once task has failed too often to keep
+// restarting it, for the caller to escalate.
+func Supervise(ctx context.Context, name string, policy SupervisorPolicy, task func(ctx context.Context) error, logger LoggerInterface) error {
+	var failures []time.Time
+	backoff := policy.Backoff
+	for {
+		err := runTask(ctx, task)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		now := NowFunc()
+		recent := failures[:0]
+		for _, t := range failures {
+			if now.Sub(t) < policy.Window {
+				recent = append(recent, t)
+			}
+		}
+		failures = append(recent, now)
+		if len(failures) >= policy.MaxFailures {
+			return fmt.Errorf("%s failed %d times within %s, last: %w", name, len(failures), policy.Window, err)
+		}
+		if len(failures) == 1 {
+			backoff = policy.Backoff
+		}
+		logger.Errorf("%s failed: %v; restarting in %s", name, err, backoff)
+		DefaultMetrics.Add("binance_task_restarts_total", Labels{"task": name}, 1)
+
+		timer := DefaultClock.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C():
+		}
+		backoff = min(2*backoff, policy.MaxBackoff)
+	}
+}
+
+// runTask runs task, turning a panic into an error.
+func runTask(ctx context.Context, task func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return task(ctx)
+}
@@ -0,0 +1,81 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSupervisorPolicy = SupervisorPolicy{Backoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond, MaxFailures: 3, Window: time.Minute}
+
+func TestSupervise_RestartsFailingTask(t *testing.T) {
+	runs := 0
+	task := func(ctx context.Context) error {
+		runs++
+		switch runs {
+		case 1:
+			return errors.New("connection refused")
+		case 2:
+			panic("malformed message")
+		}
+		return nil
+	}
+	if err := Supervise(context.Background(), "test task", testSupervisorPolicy, task, NewLogger(io.Discard)); err != nil {
+		t.Fatalf("expected the task to recover, got %v", err)
+	}
+	if runs != 3 {
+		t.Errorf("expected 3 runs, got %d", runs)
+	}
+	if got := DefaultMetrics.Value("binance_task_restarts_total", Labels{"task": "test task"}); got != 2 {
+		t.Errorf("expected 2 restarts counted, got %v", got)
+	}
+}
+
+func TestSupervise_EscalatesRepeatedFailures(t *testing.T) {
+	runs := 0
+	task := func(ctx context.Context) error {
+		runs++
+		panic("always")
+	}
+	err := Supervise(context.Background(), "doomed task", testSupervisorPolicy, task, NewLogger(io.Discard))
+	if err == nil || !strings.Contains(err.Error(), "doomed task failed 3 times within 1m0s, last: panic: always") {
+		t.Fatalf("expected the failures to be escalated, got %v", err)
+	}
+	if runs != 3 {
+		t.Errorf("expected 3 runs, got %d", runs)
+	}
+}
+
+func TestSupervise_ForgetsFailuresOutsideWindow(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	oldNow := NowFunc
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = oldNow }()
+
+	runs := 0
+	task := func(ctx context.Context) error {
+		runs++
+		now = now.Add(time.Minute)
+		if runs < 6 {
+			return errors.New("stream closed")
+		}
+		return nil
+	}
+	if err := Supervise(context.Background(), "slow failures", testSupervisorPolicy, task, NewLogger(io.Discard)); err != nil {
+		t.Fatalf("expected failures a window apart not to escalate, got %v", err)
+	}
+}
+
+func TestSupervise_StopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	task := func(ctx context.Context) error {
+		cancel()
+		return errors.New("cancelled")
+	}
+	if err := Supervise(ctx, "cancelled task", testSupervisorPolicy, task, NewLogger(io.Discard)); err != nil {
+		t.Errorf("expected nil once the context is cancelled, got %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package gobinapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TailTypes lists the stream types the tail subcommand can follow, named after their stream suffixes.
+var TailTypes = []string{"trade", "aggTrade", "depth", "bookTicker"}
+
+// TailFilter selects which live events are printed by Tail.
+type TailFilter struct {
+	// MinSize drops events smaller than this quantity: the trade quantity for trades, the larger of the bid and ask
+	// quantities for book tickers, and the largest level quantity for depth updates. Zero prints everything.
+	MinSize float64
+}
+
+// Match is a pure function reporting whether a decoded event passes the filter.
+func (f TailFilter) Match(record interface{}) bool {
+	if f.MinSize <= 0 {
+		return true
+	}
+	return eventSize(record) >= f.MinSize
+}
+
+// eventSize returns the quantity TailFilter.MinSize is compared against.
+func eventSize(record interface{}) float64 {
+	qty := func(s string) float64 {
+		v, _ := strconv.ParseFloat(s, 64)
+		return v
+	}
+	switch r := record.(type) {
+	case Trade:
+		return qty(r.Quantity)
+	case AggTrade:
+		return qty(r.Quantity)
+	case BestPrice:
+		return max(qty(r.BidQty), qty(r.AskQty))
+	case OrderBookDiff:
+		size := 0.0
+		for _, levels := range [][]PriceLevel{r.Bids, r.Asks} {
+			for _, l := range levels {
+				size = max(size, qty(l.Quantity))
+			}
+		}
+		return size
+	}
+	return 0
+}
+
+// takerSide returns the side of the aggressor: a buyer-maker trade was initiated by a seller.
+func takerSide(isBuyerMaker bool) string {
+	if isBuyerMaker {
+		return "sell"
+	}
+	return "buy"
+}
+
+// FormatTailEvent is a pure function rendering a decoded event as a single human-readable line, prefixed with the
+// local time it was received.
+func FormatTailEvent(received time.Time, symbol string, record interface{}) string {
+	prefix := fmt.Sprintf("%s %-10s", received.UTC().Format("15:04:05.000"), symbol)
+	switch r := record.(type) {
+	case Trade:
+		return fmt.Sprintf("%s trade      #%d %s x %s %s", prefix, r.TradeID, r.Price, r.Quantity, takerSide(r.IsBuyerMaker))
+	case AggTrade:
+		return fmt.Sprintf("%s aggTrade   #%d %s x %s %s (trades %d-%d)", prefix, r.AggTradeID, r.Price, r.Quantity, takerSide(r.IsBuyerMaker), r.FirstTradeID, r.LastTradeID)
+	case BestPrice:
+		return fmt.Sprintf("%s bookTicker #%d %s x %s / %s x %s", prefix, r.UpdateID, r.BidQty, r.BidPrice, r.AskPrice, r.AskQty)
+	case OrderBookDiff:
+		return fmt.Sprintf("%s depth      #%d-%d %d bids %d asks%s", prefix, r.FirstUpdateID, r.FinalUpdateID, len(r.Bids), len(r.Asks), formatTopLevels(r))
+	}
+	return fmt.Sprintf("%s %+v", prefix, record)
+}
+
+// formatTopLevels renders the first bid and ask level of a depth update, if any.
+func formatTopLevels(diff OrderBookDiff) string {
+	var parts []string
+	if len(diff.Bids) > 0 {
+		parts = append(parts, fmt.Sprintf("bid %s x %s", diff.Bids[0].Quantity, diff.Bids[0].Price))
+	}
+	if len(diff.Asks) > 0 {
+		parts = append(parts, fmt.Sprintf("ask %s x %s", diff.Asks[0].Price, diff.Asks[0].Quantity))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// tailEvent is an event received by Tail along with the symbol it was subscribed for.
+type tailEvent struct {
+	symbol string
+	record interface{}
+}
+
+// Tail connects to the given stream types for every symbol and writes each event passing the filter to w, one line
+// per event, until ctx is cancelled or a stream fails. It returns the first stream error, or nil when cancelled.
+func Tail(ctx context.Context, symbols []string, types []string, filter TailFilter, w io.Writer) error {
+	for _, typ := range types {
+		if !isTailType(typ) {
+			return fmt.Errorf("unknown stream type %q (want one of %s)", typ, strings.Join(TailTypes, ", "))
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events := make(chan tailEvent, 100)
+	errs := make(chan error, len(symbols)*len(types))
+	for _, symbol := range symbols {
+		for _, typ := range types {
+			go func() {
+				errs <- tailStream(ctx, symbol, typ, events)
+			}()
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil
+			}
+			// Print whatever the stream delivered before it failed
+			for {
+				select {
+				case ev := <-events:
+					printTailEvent(w, filter, ev)
+				default:
+					return err
+				}
+			}
+		case ev := <-events:
+			printTailEvent(w, filter, ev)
+		}
+	}
+}
+
+func printTailEvent(w io.Writer, filter TailFilter, ev tailEvent) {
+	if filter.Match(ev.record) {
+		fmt.Fprintln(w, FormatTailEvent(NowFunc(), ev.symbol, ev.record))
+	}
+}
+
+func isTailType(typ string) bool {
+	for _, t := range TailTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// tailStream runs the listener for one symbol and stream type, forwarding decoded events to out.
+func tailStream(ctx context.Context, symbol string, typ string, out chan<- tailEvent) error {
+	forward := func(record interface{}) {
+		select {
+		case out <- tailEvent{symbol: symbol, record: record}:
+		case <-ctx.Done():
+		}
+	}
+	var err error
+	switch typ {
+	case "trade":
+		err = tailListen(ctx, ListenTrade, symbol, forward)
+	case "aggTrade":
+		err = tailListen(ctx, ListenAggTrade, symbol, forward)
+	case "depth":
+		err = tailListen(ctx, ListenOrderBookDiff, symbol, forward)
+	case "bookTicker":
+		err = tailListen(ctx, ListenBestPrice, symbol, forward)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s stream: %w", symbol, typ, err)
+	}
+	return nil
+}
+
+// tailListen runs a typed listener and forwards everything it decodes, returning once all events are forwarded.
+func tailListen[T any](ctx context.Context, listen func(context.Context, string, chan<- T) error, symbol string, forward func(interface{})) error {
+	ch := make(chan T, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for r := range ch {
+			forward(r)
+		}
+	}()
+	err := listen(ctx, symbol, ch)
+	close(ch)
+	<-done
+	return err
+}
@@ -0,0 +1,70 @@
+package gobinapi
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestTailFilter_MinSize(t *testing.T) {
+	filter := TailFilter{MinSize: 1}
+	cases := []struct {
+		record interface{}
+		want   bool
+	}{
+		{Trade{Quantity: "0.5"}, false},
+		{Trade{Quantity: "1.0"}, true},
+		{AggTrade{Quantity: "2"}, true},
+		{BestPrice{BidQty: "0.1", AskQty: "3"}, true},
+		{OrderBookDiff{Bids: []PriceLevel{{Price: "1", Quantity: "0.2"}}}, false},
+		{OrderBookDiff{Asks: []PriceLevel{{Price: "1", Quantity: "5"}}}, true},
+	}
+	for _, c := range cases {
+		if got := filter.Match(c.record); got != c.want {
+			t.Errorf("Match(%+v) = %v, want %v", c.record, got, c.want)
+		}
+	}
+	if !(TailFilter{}).Match(Trade{Quantity: "0"}) {
+		t.Errorf("the zero filter should match everything")
+	}
+}
+
+func TestFormatTailEvent(t *testing.T) {
+	received := time.Date(2025, 2, 19, 12, 0, 0, 1e6, time.UTC)
+	line := FormatTailEvent(received, "BTCUSDT", Trade{TradeID: 7, Price: "100.5", Quantity: "0.25", IsBuyerMaker: true})
+	if want := "12:00:00.001 BTCUSDT    trade      #7 100.5 x 0.25 sell"; line != want {
+		t.Errorf("unexpected trade line:\n got %q\nwant %q", line, want)
+	}
+	line = FormatTailEvent(received, "BTCUSDT", OrderBookDiff{FirstUpdateID: 1, FinalUpdateID: 2, Bids: []PriceLevel{{Price: "99", Quantity: "1"}}})
+	if want := "12:00:00.001 BTCUSDT    depth      #1-2 1 bids 0 asks (bid 1 x 99)"; line != want {
+		t.Errorf("unexpected depth line:\n got %q\nwant %q", line, want)
+	}
+}
+
+func TestTail_MockServer(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade",
+		mockbinance.TradeMessage("BTCUSDT", 1, "100.0", "0.5"),
+		mockbinance.TradeMessage("BTCUSDT", 2, "100.1", "2.0"))
+	srv.SetCloseAfterFrames(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var out bytes.Buffer
+	err := Tail(ctx, []string{"BTCUSDT"}, []string{"trade"}, TailFilter{MinSize: 1}, &out)
+	if err == nil || !strings.Contains(err.Error(), "BTCUSDT trade stream") {
+		t.Errorf("expected the stream's disconnect to be reported, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "#2 100.1 x 2.0") {
+		t.Errorf("expected only the large trade to be printed, got %q", out.String())
+	}
+
+	if err := Tail(ctx, []string{"BTCUSDT"}, []string{"candles"}, TailFilter{}, &out); err == nil {
+		t.Errorf("expected an unknown stream type to be rejected")
+	}
+}
@@ -0,0 +1,98 @@
+package gobinapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// TelegramBaseURL is the Telegram Bot API that TelegramAlerter sends messages through.
+var TelegramBaseURL = "https://api.telegram.org"
+
+// TelegramConfig configures alerts sent as Telegram messages by a bot, e.g. to an operator's phone.
+type TelegramConfig struct {
+	// BotToken is the token BotFather gave the bot; it defaults to the TELEGRAM_BOT_TOKEN environment variable.
+	BotToken string `json:"-" yaml:"-"`
+	// ChatID is the chat the bot writes to: a user or group ID such as "123456789", or "@channel" for a channel.
+	// The bot must have been started in the chat, or added to the group or channel.
+	ChatID string `json:"chat_id" yaml:"chat_id"`
+}
+
+// Validate checks the settings.
+func (c TelegramConfig) Validate() error {
+	if c.ChatID == "" {
+		return errors.New("telegram: a chat ID is required")
+	}
+	return nil
+}
+
+func (c TelegramConfig) withDefaults() TelegramConfig {
+	if c.BotToken == "" {
+		c.BotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
+	}
+	return c
+}
+
+// TelegramAlerter sends alerts as Telegram messages through the Bot API's sendMessage method.
+type TelegramAlerter struct {
+	cfg    TelegramConfig
+	client *http.Client
+}
+
+// NewTelegramAlerter creates a TelegramAlerter sending with client. It fails if there is no bot token.
+func NewTelegramAlerter(cfg TelegramConfig, client *http.Client) (*TelegramAlerter, error) {
+	cfg = cfg.withDefaults()
+	if cfg.BotToken == "" {
+		return nil, errors.New("telegram: a bot token is required, set TELEGRAM_BOT_TOKEN")
+	}
+	return &TelegramAlerter{cfg: cfg, client: client}, nil
+}
+
+// Send sends alert.Text() to the configured chat.
+func (t *TelegramAlerter) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(map[string]any{
+		"chat_id":                  t.cfg.ChatID,
+		"text":                     alert.Text(),
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+	// The token is part of the path, so errors below must not include the URL
+	endpoint := TelegramBaseURL + "/bot" + t.cfg.BotToken + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("telegram: invalid request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("telegram: failed to read the response: %w", err)
+	}
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil || !result.OK {
+		if result.Description != "" {
+			return fmt.Errorf("telegram: sendMessage returned %s: %s", resp.Status, result.Description)
+		}
+		return fmt.Errorf("telegram: sendMessage returned %s", resp.Status)
+	}
+	return nil
+}
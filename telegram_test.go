@@ -0,0 +1,77 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func useTelegramServer(t *testing.T, handler http.HandlerFunc) {
+	srv := httptest.NewServer(handler)
+	old := TelegramBaseURL
+	TelegramBaseURL = srv.URL
+	t.Cleanup(func() {
+		TelegramBaseURL = old
+		srv.Close()
+	})
+}
+
+func TestTelegramAlerter_SendsMessageToChat(t *testing.T) {
+	var got map[string]any
+	useTelegramServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:secret/sendMessage" {
+			http.NotFound(w, r)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Errorf("malformed request %s: %v", data, err)
+		}
+		io.WriteString(w, `{"ok":true,"result":{"message_id":1}}`)
+	})
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123:secret")
+
+	telegram, err := NewTelegramAlerter(TelegramConfig{ChatID: "42"}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = telegram.Send(context.Background(), Alert{Kind: AlertLowDisk, Message: "/data has 10 MiB free"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["chat_id"] != "42" || got["text"] != "[low_disk]: /data has 10 MiB free" {
+		t.Errorf("unexpected message %v", got)
+	}
+}
+
+func TestTelegramAlerter_ReportsFailuresWithoutToken(t *testing.T) {
+	useTelegramServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+	})
+	telegram, err := NewTelegramAlerter(TelegramConfig{BotToken: "123:secret", ChatID: "42"}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = telegram.Send(context.Background(), Alert{Kind: AlertLowDisk, Message: "full"})
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Fatalf("expected Telegram's description, got %v", err)
+	}
+
+	TelegramBaseURL = "http://127.0.0.1:1"
+	err = telegram.Send(context.Background(), Alert{Kind: AlertLowDisk, Message: "full"})
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected an error not revealing the token, got %v", err)
+	}
+}
+
+func TestNewTelegramAlerter_RequiresToken(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "")
+	if _, err := NewTelegramAlerter(TelegramConfig{ChatID: "42"}, http.DefaultClient); err == nil {
+		t.Error("expected an error without a bot token")
+	}
+}
@@ -0,0 +1,142 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Data types of the all-market ticker arrays, which are also the names they are selected by in
+// Config.TickerArrays.
+const (
+	TickerDataType     = "ticker"
+	MiniTickerDataType = "miniTicker"
+)
+
+// MarketSymbol stands in for the symbol in the file names of market-wide recordings, which hold the rows of every
+// symbol, e.g. ALL_ticker_2025-02-19.parquet.
+const MarketSymbol = "ALL"
+
+// TickerArrayStream returns the all-market stream of a ticker data type, e.g. "!ticker@arr" for "ticker".
+func TickerArrayStream(dataType string) string {
+	return "!" + dataType + "@arr"
+}
+
+// Ticker24h is a symbol's rolling window statistics, one row per update: over 24 hours from the <symbol>@ticker
+// streams and the all-market ticker arrays, or over the window of a <symbol>@ticker_<window> stream (event type
+// "1hTicker", "4hTicker" or "1dTicker"). Rows of the mini ticker array (event type "24hrMiniTicker") only have the
+// event, symbol, last price, open, high, low and volume columns set. Spot 24 hour tickers also have the previous
+// close, last quantity and best bid and ask; window tickers and futures tickers do not.
+type Ticker24h struct {
+	EventType          string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventTime          int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	Symbol             string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	PriceChange        string `json:"p" decimal:"true" parquet:"name=price_change, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PriceChangePercent string `json:"P" decimal:"true" parquet:"name=price_change_percent, type=BYTE_ARRAY, convertedtype=UTF8"`
+	WeightedAvgPrice   string `json:"w" decimal:"true" parquet:"name=weighted_avg_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PrevClosePrice     string `json:"x" decimal:"true" parquet:"name=prev_close_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LastPrice          string `json:"c" decimal:"true" parquet:"name=last_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LastQty            string `json:"Q" decimal:"true" parquet:"name=last_qty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BidPrice           string `json:"b" decimal:"true" parquet:"name=bid_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BidQty             string `json:"B" decimal:"true" parquet:"name=bid_qty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AskPrice           string `json:"a" decimal:"true" parquet:"name=ask_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AskQty             string `json:"A" decimal:"true" parquet:"name=ask_qty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OpenPrice          string `json:"o" decimal:"true" parquet:"name=open_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	HighPrice          string `json:"h" decimal:"true" parquet:"name=high_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LowPrice           string `json:"l" decimal:"true" parquet:"name=low_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// Volume is in base and QuoteVolume in quote units.
+	Volume      string `json:"v" decimal:"true" parquet:"name=volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+	QuoteVolume string `json:"q" decimal:"true" parquet:"name=quote_volume, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// OpenTime and CloseTime bound the 24 hour window, FirstTradeID and LastTradeID its trades.
+	OpenTime     int64 `json:"O" timestamp:"millis" parquet:"name=open_time, type=INT64"`
+	CloseTime    int64 `json:"C" timestamp:"millis" parquet:"name=close_time, type=INT64"`
+	FirstTradeID int64 `json:"F" parquet:"name=first_trade_id, type=INT64"`
+	LastTradeID  int64 `json:"L" parquet:"name=last_trade_id, type=INT64"`
+	Trades       int64 `json:"n" parquet:"name=trades, type=INT64"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the array was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// ParseTickerArray is a pure function decoding an all-market ticker array, either bare or wrapped in a combined
+// stream message, into its rows.
+func ParseTickerArray(msg []byte) ([]Ticker24h, error) {
+	var combined struct {
+		Stream string          `json:"stream"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if len(msg) > 0 && msg[0] == '{' {
+		if err := json.Unmarshal(msg, &combined); err == nil && combined.Stream != "" {
+			msg = combined.Data
+		}
+	}
+	var tickers []Ticker24h
+	if err := json.Unmarshal(msg, &tickers); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ticker array: %w, raw message: %s", err, msg)
+	}
+	return tickers, nil
+}
+
+// ListenTickerArray subscribes to the all-market ticker array of dataType (TickerDataType or MiniTickerDataType),
+// which Binance sends once a second with the tickers that changed, and writes every symbol's row to recorder. One
+// connection covers every symbol of the market.
+func ListenTickerArray(ctx context.Context, dataType string, recorder RecorderWriter[Ticker24h]) error {
+	return listenTickerArray(ctx, StreamBaseURL, dataType, recorder)
+}
+
+// listenTickerArray is ListenTickerArray against the given stream base URL.
+func listenTickerArray(ctx context.Context, base, dataType string, recorder RecorderWriter[Ticker24h]) error {
+	url := fmt.Sprintf("%s/ws/%s", base, TickerArrayStream(dataType))
+	return listenWebSocket(ctx, url, tickerArrayHandler(recorder))
+}
+
+// ListenTicker subscribes to one of symbol's ticker streams, StreamTicker for 24 hours or a rolling window like
+// StreamTicker1h, each of which Binance sends once a second.
+func ListenTicker(ctx context.Context, symbol, stream string, out chan<- Ticker24h) error {
+	return listenTicker(ctx, StreamBaseURL, symbol, stream, out)
+}
+
+// listenTicker is ListenTicker against the given stream base URL.
+func listenTicker(ctx context.Context, base, symbol, stream string, out chan<- Ticker24h) error {
+	url := fmt.Sprintf("%s/ws/%s@%s", base, strings.ToLower(symbol), stream)
+	return listenWebSocket(ctx, url, tickerHandler(out))
+}
+
+// tickerHandler returns the handler decoding ticker messages into out.
+func tickerHandler(out chan<- Ticker24h) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
+		var combined struct {
+			Stream string          `json:"stream"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(msg, &combined); err == nil && combined.Stream != "" {
+			msg = combined.Data
+		}
+		var ticker Ticker24h
+		if err := json.Unmarshal(msg, &ticker); err != nil {
+			return fmt.Errorf("failed to unmarshal Ticker24h: %w, raw message: %s", err, msg)
+		}
+		ticker.ConnID, ticker.ConnGeneration = session.ID, session.Generation
+		out <- ticker
+		return nil
+	}
+}
+
+// tickerArrayHandler returns the handler fanning ticker arrays out into per-symbol rows written to recorder.
+func tickerArrayHandler(recorder RecorderWriter[Ticker24h]) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
+		tickers, err := ParseTickerArray(msg)
+		if err != nil {
+			return err
+		}
+		for _, t := range tickers {
+			t.ConnID, t.ConnGeneration = session.ID, session.Generation
+			if err := recorder.Write(t); err != nil {
+				return fmt.Errorf("error writing %s ticker: %w", t.Symbol, err)
+			}
+		}
+		return nil
+	}
+}
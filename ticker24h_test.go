@@ -0,0 +1,96 @@
+package gobinapi
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const tickerArrayFrame = `[{"e":"24hrTicker","E":1739966400000,"s":"BTCUSDT","p":"100.0","P":"0.1","w":"96000","x":"95900","c":"96000.5","Q":"0.01","b":"96000","B":"1","a":"96001","A":"2","o":"95900.5","h":"97000","l":"95000","v":"1234.5","q":"118512000","O":1739880000000,"C":1739966399999,"F":100,"L":200,"n":101},` +
+	`{"e":"24hrTicker","E":1739966400000,"s":"ETHUSDT","p":"-1","P":"-0.05","w":"2700","x":"2701","c":"2700","Q":"1","b":"2699","B":"3","a":"2700","A":"4","o":"2701","h":"2750","l":"2650","v":"10","q":"27000","O":1739880000000,"C":1739966399999,"F":5,"L":9,"n":5}]`
+
+func TestParseTickerArray(t *testing.T) {
+	tickers, err := ParseTickerArray([]byte(tickerArrayFrame))
+	if err != nil {
+		t.Fatalf("failed to parse ticker array: %v", err)
+	}
+	if len(tickers) != 2 || tickers[0].Symbol != "BTCUSDT" || tickers[1].Symbol != "ETHUSDT" {
+		t.Fatalf("expected a row per symbol, got %+v", tickers)
+	}
+	btc := tickers[0]
+	if btc.PriceChangePercent != "0.1" || btc.PriceChange != "100.0" || btc.LastQty != "0.01" || btc.LastPrice != "96000.5" ||
+		btc.OpenTime != 1739880000000 || btc.CloseTime != 1739966399999 || btc.BidQty != "1" || btc.BidPrice != "96000" ||
+		btc.OpenPrice != "95900.5" || btc.LastTradeID != 200 || btc.Trades != 101 || btc.QuoteVolume != "118512000" {
+		t.Errorf("fields decoded into the wrong columns: %+v", btc)
+	}
+
+	mini, err := ParseTickerArray([]byte(`{"stream":"!miniTicker@arr","data":[{"e":"24hrMiniTicker","E":1,"s":"BNBUSDT","c":"600","o":"590","h":"610","l":"580","v":"100","q":"60000"}]}`))
+	if err != nil || len(mini) != 1 || mini[0].EventType != "24hrMiniTicker" || mini[0].LastPrice != "600" || mini[0].QuoteVolume != "60000" {
+		t.Errorf("expected a combined mini ticker array to be unwrapped, got %+v (%v)", mini, err)
+	}
+	if _, err := ParseTickerArray([]byte(`{"e":"24hrTicker"}`)); err == nil {
+		t.Error("expected a single ticker to be rejected")
+	}
+}
+
+func TestListenTickerArray_RecordsRowPerSymbol(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	srv := useMockServer(t)
+	srv.SetStream("!ticker@arr", []byte(tickerArrayFrame), []byte(tickerArrayFrame))
+	srv.SetCloseAfterFrames(true)
+
+	rec, err := NewRecorder[Ticker24h](MarketSymbol, TickerDataType, 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	ListenTickerArray(context.Background(), TickerDataType, rec)
+	if err := rec.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	path := filepath.Join(".", BuildFileName(TickerDataType, MarketSymbol, time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)))
+	records, err := ReadRecordingFile(TickerDataType, path)
+	if err != nil {
+		t.Fatalf("failed to read tickers: %v", err)
+	}
+	if len(records) != 4 || records[1].(Ticker24h).Symbol != "ETHUSDT" || records[0].(Ticker24h).ConnID == "" {
+		t.Errorf("expected two rows per array with their session, got %+v", records)
+	}
+}
+
+func TestListenTicker_RecordsWindowTickers(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	srv := useMockServer(t)
+	frame := []byte(`{"e":"1hTicker","E":1739966400000,"s":"BNBBTC","p":"0.0015","P":"250.00","o":"0.0010","h":"0.0025","l":"0.0010","c":"0.0025","w":"0.0018","v":"10000","q":"18","O":1739962800000,"C":1739966399999,"F":0,"L":18150,"n":18151}`)
+	srv.SetStream("bnbbtc@ticker_1h", frame, frame)
+	srv.SetCloseAfterFrames(true)
+
+	rec, err := NewRecorder[Ticker24h]("BNBBTC", StreamTicker1h, 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	out := make(chan Ticker24h, 10)
+	ListenTicker(context.Background(), "BNBBTC", StreamTicker1h, out)
+	close(out)
+	SubscribeTickers(out, rec, &FakeLogger{})
+	if err := rec.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	path := filepath.Join(".", BuildFileName(StreamTicker1h, "BNBBTC", time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)))
+	records, err := ReadRecordingFile(StreamTicker1h, path)
+	if err != nil {
+		t.Fatalf("failed to read tickers: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected both window tickers, got %+v", records)
+	}
+	got := records[0].(Ticker24h)
+	if got.EventType != "1hTicker" || got.WeightedAvgPrice != "0.0018" || got.OpenPrice != "0.0010" || got.HighPrice != "0.0025" ||
+		got.Volume != "10000" || got.Trades != 18151 || got.LastTradeID != 18150 || got.ConnID == "" {
+		t.Errorf("fields decoded into the wrong columns: %+v", got)
+	}
+}
@@ -0,0 +1,125 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type fakeCopyFromer struct {
+	tables map[string][][]any
+	fail   bool
+	closed bool
+}
+
+func (f *fakeCopyFromer) IsClosed() bool {
+	return f.closed
+}
+
+func (f *fakeCopyFromer) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	if f.fail || f.closed {
+		return 0, errors.New("connection refused")
+	}
+	var n int64
+	for rowSrc.Next() {
+		values, err := rowSrc.Values()
+		if err != nil {
+			return n, err
+		}
+		if len(values) != len(columnNames) {
+			return n, errors.New("column count mismatch")
+		}
+		f.tables[tableName[0]] = append(f.tables[tableName[0]], values)
+		n++
+	}
+	return n, nil
+}
+
+func TestTimescaleSink_CopiesTradesAndBookTicker(t *testing.T) {
+	conn := &fakeCopyFromer{tables: map[string][][]any{}}
+	sink := newTimescaleSink(TimescaleConfig{}, conn, &FakeLogger{})
+	w := sink.ForSymbol("btcusdt")
+
+	if err := w.Write(Trade{TradeID: 7, Price: "100.25", Quantity: "0.5", TradeTime: 1700000000000, EventTime: 1700000000001}); err != nil {
+		t.Fatalf("failed to write trade: %v", err)
+	}
+	if err := w.Write(BestPrice{UpdateID: 9, BidPrice: "100", BidQty: "1", AskPrice: "101", AskQty: "2"}); err != nil {
+		t.Fatalf("failed to write best price: %v", err)
+	}
+	if err := w.Write(AggTrade{}); err != nil {
+		t.Fatalf("unsupported records should be ignored, got: %v", err)
+	}
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush returned error: %v", err)
+	}
+
+	trades := conn.tables["trades"]
+	if len(trades) != 1 || trades[0][1] != "BTCUSDT" || trades[0][2] != int64(7) {
+		t.Errorf("unexpected trade rows: %v", trades)
+	}
+	if books := conn.tables["book_ticker"]; len(books) != 1 || books[0][2] != int64(9) {
+		t.Errorf("unexpected book ticker rows: %v", books)
+	}
+}
+
+func TestTimescaleSink_RetriesFailedCopies(t *testing.T) {
+	conn := &fakeCopyFromer{tables: map[string][][]any{}, fail: true}
+	sink := newTimescaleSink(TimescaleConfig{}, conn, &FakeLogger{})
+	sink.ForSymbol("ETHUSDT").Write(Trade{TradeID: 1, Price: "1", Quantity: "1"})
+
+	if err := sink.Flush(context.Background()); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected copy failure to be reported, got %v", err)
+	}
+	conn.fail = false
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush returned error after recovery: %v", err)
+	}
+	if len(conn.tables["trades"]) != 1 {
+		t.Errorf("expected the failed row to be retried, got %d rows", len(conn.tables["trades"]))
+	}
+}
+
+func TestTimescaleSink_ReconnectsBrokenConnection(t *testing.T) {
+	broken := &fakeCopyFromer{tables: map[string][][]any{}, closed: true}
+	sink := newTimescaleSink(TimescaleConfig{}, broken, &FakeLogger{})
+	var reconnected *fakeCopyFromer
+	sink.connect = func(ctx context.Context) (copyFromer, error) {
+		if reconnected == nil {
+			return nil, errors.New("connection refused")
+		}
+		return reconnected, nil
+	}
+	sink.ForSymbol("ETHUSDT").Write(Trade{TradeID: 1, Price: "1", Quantity: "1"})
+
+	if err := sink.Flush(context.Background()); err == nil || !strings.Contains(err.Error(), "reconnect") {
+		t.Fatalf("expected the failed reconnect to be reported, got %v", err)
+	}
+	reconnected = &fakeCopyFromer{tables: map[string][][]any{}}
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush returned error after reconnecting: %v", err)
+	}
+	if len(reconnected.tables["trades"]) != 1 {
+		t.Errorf("expected the row to be copied on the new connection, got %d rows", len(reconnected.tables["trades"]))
+	}
+}
+
+func TestTimescaleSink_RejectsNonNumericPrice(t *testing.T) {
+	sink := newTimescaleSink(TimescaleConfig{}, &fakeCopyFromer{}, &FakeLogger{})
+	if err := sink.ForSymbol("BTCUSDT").Write(Trade{Price: "abc", Quantity: "1"}); err == nil {
+		t.Errorf("expected error for non-numeric price")
+	}
+}
+
+func TestTimescaleSchema_UsesConfiguredTables(t *testing.T) {
+	stmts := TimescaleSchema(TimescaleConfig{TradeTable: "spot_trades"})
+	joined := strings.Join(stmts, "\n")
+	if !strings.Contains(joined, `"spot_trades"`) || !strings.Contains(joined, `"book_ticker"`) {
+		t.Errorf("schema does not reference the configured tables:\n%s", joined)
+	}
+	if !strings.Contains(joined, `create_hypertable('"spot_trades"'`) {
+		t.Errorf("schema does not create a hypertable for the trade table:\n%s", joined)
+	}
+}
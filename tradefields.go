@@ -0,0 +1,57 @@
+package gobinapi
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Aggressor sides stored in the Side column of trades.
+const (
+	TradeSideBuy  = "buy"
+	TradeSideSell = "sell"
+)
+
+// AggressorSide is a pure function returning the side of the taker of a trade. When the buyer is the maker, the
+// seller crossed the spread, so the trade is a sell.
+func AggressorSide(isBuyerMaker bool) string {
+	if isBuyerMaker {
+		return TradeSideSell
+	}
+	return TradeSideBuy
+}
+
+// DeriveTradeFields is a pure function returning the aggressor side and the notional value (price × quantity, in
+// quote units) of a trade. The notional is computed exactly from the decimal strings and left empty if either is
+// not a decimal number.
+func DeriveTradeFields(price, quantity string, isBuyerMaker bool) (side string, notional string) {
+	notional, err := DecimalMul(price, quantity)
+	if err != nil {
+		notional = ""
+	}
+	return AggressorSide(isBuyerMaker), notional
+}
+
+// DecimalMul is a pure function multiplying two decimal strings exactly, e.g. "100.50" × "0.25" = "25.125".
+// Trailing zeros after the decimal point are trimmed.
+func DecimalMul(a, b string) (string, error) {
+	for _, s := range []string{a, b} {
+		if !IsDecimalString(s) {
+			return "", fmt.Errorf("invalid decimal %q", s)
+		}
+	}
+	x, _ := new(big.Rat).SetString(a)
+	y, _ := new(big.Rat).SetString(b)
+	product := new(big.Rat).Mul(x, y).FloatString(decimalPlaces(a) + decimalPlaces(b))
+	if strings.Contains(product, ".") {
+		product = strings.TrimRight(strings.TrimRight(product, "0"), ".")
+	}
+	return product, nil
+}
+
+func decimalPlaces(s string) int {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+package gobinapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecimalMul(t *testing.T) {
+	cases := []struct{ a, b, want string }{
+		{"100.50", "0.25", "25.125"},
+		{"96000.01000000", "0.00100000", "96.00001"},
+		{"2", "3", "6"},
+		{"10.0", "0.5", "5"},
+		{"0.00000001", "0.00000001", "0.0000000000000001"},
+	}
+	for _, c := range cases {
+		got, err := DecimalMul(c.a, c.b)
+		if err != nil || got != c.want {
+			t.Errorf("DecimalMul(%q, %q) = %q, %v; want %q", c.a, c.b, got, err, c.want)
+		}
+	}
+	for _, bad := range []string{"", "1e5", "1/2", "-1"} {
+		if _, err := DecimalMul(bad, "1"); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestTradeDecode_DerivesSideAndNotional(t *testing.T) {
+	var trade Trade
+	if err := json.Unmarshal([]byte(`{"e":"trade","t":1,"p":"100.5","q":"0.2","m":true,"M":true}`), &trade); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if trade.Side != TradeSideSell || trade.Notional != "20.1" {
+		t.Errorf("expected a sell of notional 20.1, got side %q notional %q", trade.Side, trade.Notional)
+	}
+
+	var agg AggTrade
+	if err := json.Unmarshal([]byte(`{"e":"aggTrade","a":1,"p":"3","q":"x","m":false,"M":true}`), &agg); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if agg.Side != TradeSideBuy || agg.Notional != "" {
+		t.Errorf("expected a buy without notional for a malformed quantity, got side %q notional %q", agg.Side, agg.Notional)
+	}
+}
@@ -0,0 +1,359 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Uploader copies a finished local file to remote storage (e.g. S3 or GCS). Implementations decide the remote key;
+// they must be idempotent, since a file is uploaded again if the process dies before the success is persisted.
+type Uploader interface {
+	Upload(ctx context.Context, filePath string) error
+}
+
+// UploadConfig configures uploading finished files. Exactly one backend must be set.
+type UploadConfig struct {
+	S3  *S3Config  `json:"s3,omitempty" yaml:"s3"`
+	GCS *GCSConfig `json:"gcs,omitempty" yaml:"gcs"`
+	// StateFile persists the upload queue (see UploadQueue). Defaults to uploads.json in the output directory.
+	StateFile string `json:"state_file,omitempty" yaml:"state_file"`
+	// DeleteUploaded removes each local file once it has been uploaded.
+	DeleteUploaded bool `json:"delete_uploaded" yaml:"delete_uploaded"`
+}
+
+// Validate checks that exactly one backend is configured.
+func (c UploadConfig) Validate() error {
+	switch {
+	case c.S3 == nil && c.GCS == nil:
+		return errors.New("no upload backend configured")
+	case c.S3 != nil && c.GCS != nil:
+		return errors.New("only one upload backend may be configured")
+	case c.GCS != nil:
+		return c.GCS.Validate()
+	}
+	if c.S3.Bucket == "" || c.S3.Region == "" {
+		return errors.New("s3: bucket and region are required")
+	}
+	if c.S3.PartSize != 0 && c.S3.PartSize < MinS3PartSize {
+		return fmt.Errorf("s3: part size must be at least %d bytes, got %d", MinS3PartSize, c.S3.PartSize)
+	}
+	return nil
+}
+
+// NewUploader creates the configured backend. Remote paths are derived from the files' paths below root.
+func (c UploadConfig) NewUploader(root string, client *http.Client) (Uploader, error) {
+	if c.S3 != nil {
+		return NewS3Uploader(*c.S3, root, client)
+	}
+	if c.GCS != nil {
+		return NewGCSUploader(*c.GCS, root, client)
+	}
+	return nil, errors.New("no upload backend configured")
+}
+
+// PendingUpload is a file waiting in an UploadQueue.
+type PendingUpload struct {
+	File        string    `json:"file"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// UploadQueue is a persistent retry queue in front of an Uploader. Every change to the queue is written to a state
+// file before it takes effect, so files pending upload survive restarts, and failed uploads are retried with
+// exponential backoff until they succeed. Transient storage outages therefore delay uploads but never lose files.
+type UploadQueue struct {
+	// BaseDelay and MaxDelay bound the retry backoff (see UploadBackoff). Set them before calling Run.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// DeleteUploaded removes the local copy of a file once it has been uploaded.
+	DeleteUploaded bool
+
+	statePath  string
+	uploader   Uploader
+	logger     LoggerInterface
+	notify     chan struct{}
+	mu         sync.Mutex
+	pending    []PendingUpload
+	inProgress map[string]bool
+	// uploaded holds the files uploaded successfully, which are logged one per line to donePath (see Uploaded)
+	uploaded map[string]bool
+	donePath string
+}
+
+func init() {
+	DefaultMetrics.Describe("binance_upload_backlog", "gauge", "Files waiting in the upload retry queue.")
+	DefaultMetrics.Describe("binance_upload_failures_total", "counter", "Failed upload attempts.")
+	DefaultMetrics.Describe("binance_uploads_total", "counter", "Files uploaded successfully.")
+}
+
+// NewUploadQueue creates an upload queue persisted at statePath, reloading any files left pending by a previous run.
+// Reloaded files are retried immediately.
+func NewUploadQueue(statePath string, uploader Uploader, logger LoggerInterface) (*UploadQueue, error) {
+	q := &UploadQueue{
+		statePath:  statePath,
+		uploader:   uploader,
+		logger:     logger,
+		BaseDelay:  time.Second,
+		MaxDelay:   10 * time.Minute,
+		notify:     make(chan struct{}, 1),
+		inProgress: make(map[string]bool),
+		uploaded:   make(map[string]bool),
+		donePath:   statePath + ".done",
+	}
+	done, err := os.ReadFile(q.donePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read uploaded files %s: %w", q.donePath, err)
+	}
+	for _, line := range strings.Split(string(done), "\n") {
+		if line != "" {
+			q.uploaded[line] = true
+		}
+	}
+	data, err := os.ReadFile(statePath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read upload queue %s: %w", statePath, err)
+	default:
+		if err := json.Unmarshal(data, &q.pending); err != nil {
+			return nil, fmt.Errorf("failed to parse upload queue %s: %w", statePath, err)
+		}
+		for i := range q.pending {
+			q.pending[i].NextAttempt = time.Time{}
+		}
+		if len(q.pending) > 0 {
+			logger.Infof("Resuming %d pending uploads from %s", len(q.pending), statePath)
+		}
+	}
+	DefaultMetrics.SetFunc("binance_upload_backlog", nil, func() float64 {
+		return float64(q.Backlog())
+	})
+	return q, nil
+}
+
+// UploadBackoff is a pure function returning the delay before retry number attempts (1 for the first retry):
+// base doubled for every previous failure, capped at max.
+func UploadBackoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// Enqueue adds a file to the queue and persists it. Adding a file that is already queued is a no-op.
+func (q *UploadQueue) Enqueue(filePath string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, p := range q.pending {
+		if p.File == filePath {
+			return nil
+		}
+	}
+	q.pending = append(q.pending, PendingUpload{File: filePath})
+	if err := q.persist(); err != nil {
+		q.pending = q.pending[:len(q.pending)-1]
+		return err
+	}
+	q.signal()
+	return nil
+}
+
+// Backlog returns the number of files waiting to be uploaded.
+func (q *UploadQueue) Backlog() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Pending returns a copy of the queued files.
+func (q *UploadQueue) Pending() []PendingUpload {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]PendingUpload(nil), q.pending...)
+}
+
+// Run uploads queued files until ctx is cancelled, retrying failures with backoff.
+func (q *UploadQueue) Run(ctx context.Context) error {
+	for {
+		wait := q.ProcessDue(ctx, NowFunc())
+		timer := DefaultClock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-q.notify:
+			timer.Stop()
+		case <-timer.C():
+		}
+	}
+}
+
+// ProcessDue attempts every upload due at now and returns how long to wait until the next one is due.
+func (q *UploadQueue) ProcessDue(ctx context.Context, now time.Time) time.Duration {
+	for _, item := range q.due(now) {
+		if !FileExists(item.File) {
+			// Nothing left to upload; retrying would keep the file in the backlog forever
+			q.logger.Errorf("Dropping %s from the upload queue: file no longer exists", item.File)
+			q.complete(item.File, nil, now)
+			continue
+		}
+		err := q.uploader.Upload(ctx, item.File)
+		if err == nil {
+			DefaultMetrics.Add("binance_uploads_total", nil, 1)
+			q.logger.Infof("Uploaded %s", item.File)
+			if err := q.markUploaded(item.File); err != nil {
+				q.logger.Errorf("%v", err)
+			}
+			if q.DeleteUploaded {
+				if err := os.Remove(item.File); err != nil {
+					q.logger.Errorf("Failed to delete uploaded file %s: %v", item.File, err)
+				}
+			}
+		}
+		q.complete(item.File, err, now)
+	}
+	return q.nextWait(now)
+}
+
+// Uploaded reports whether the file at filePath has been uploaded successfully, by this run or an earlier one using
+// the same state file.
+func (q *UploadQueue) Uploaded(filePath string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.uploaded[filePath]
+}
+
+// Forget drops files, e.g. ones deleted locally, from the record of uploaded files, so it does not grow forever.
+func (q *UploadQueue) Forget(filePaths ...string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, filePath := range filePaths {
+		delete(q.uploaded, filePath)
+	}
+	var b strings.Builder
+	for _, filePath := range slices.Sorted(maps.Keys(q.uploaded)) {
+		b.WriteString(filePath + "\n")
+	}
+	tmpPath := q.donePath + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write uploaded files %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, q.donePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename uploaded files to %s: %w", q.donePath, err)
+	}
+	return nil
+}
+
+// markUploaded records filePath as uploaded, appending it to the log of uploaded files.
+func (q *UploadQueue) markUploaded(filePath string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.uploaded[filePath] = true
+	f, err := os.OpenFile(q.donePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open uploaded files %s: %w", q.donePath, err)
+	}
+	_, err = f.WriteString(filePath + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record upload of %s in %s: %w", filePath, q.donePath, err)
+	}
+	return nil
+}
+
+// due marks and returns the queued files whose next attempt is at or before now.
+func (q *UploadQueue) due(now time.Time) []PendingUpload {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var items []PendingUpload
+	for _, p := range q.pending {
+		if !p.NextAttempt.After(now) && !q.inProgress[p.File] {
+			q.inProgress[p.File] = true
+			items = append(items, p)
+		}
+	}
+	return items
+}
+
+// complete records the outcome of an upload attempt and persists the queue.
+func (q *UploadQueue) complete(filePath string, uploadErr error, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.inProgress, filePath)
+	for i := range q.pending {
+		if q.pending[i].File != filePath {
+			continue
+		}
+		if uploadErr == nil {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+		} else {
+			p := &q.pending[i]
+			p.Attempts++
+			p.LastError = uploadErr.Error()
+			p.NextAttempt = now.Add(UploadBackoff(p.Attempts, q.BaseDelay, q.MaxDelay))
+			DefaultMetrics.Add("binance_upload_failures_total", nil, 1)
+			q.logger.Errorf("Upload of %s failed (attempt %d, next at %s): %v", filePath, p.Attempts, p.NextAttempt.Format(time.RFC3339), uploadErr)
+		}
+		break
+	}
+	if err := q.persist(); err != nil {
+		q.logger.Errorf("%v", err)
+	}
+}
+
+// nextWait returns how long until the earliest queued attempt is due, or a long idle wait if nothing is queued.
+func (q *UploadQueue) nextWait(now time.Time) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	wait := time.Hour
+	for _, p := range q.pending {
+		if d := p.NextAttempt.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// persist writes the queue state atomically. It must be called with mu held.
+func (q *UploadQueue) persist() error {
+	data, err := json.MarshalIndent(q.pending, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode upload queue: %w", err)
+	}
+	tmpPath := q.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write upload queue %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, q.statePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename upload queue to %s: %w", q.statePath, err)
+	}
+	return nil
+}
+
+func (q *UploadQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
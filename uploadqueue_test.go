@@ -0,0 +1,157 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// flakyUploader fails the first failures uploads and records the files it uploaded afterwards.
+type flakyUploader struct {
+	failures int
+	calls    int
+	uploaded []string
+}
+
+func (u *flakyUploader) Upload(ctx context.Context, filePath string) error {
+	u.calls++
+	if u.calls <= u.failures {
+		return errors.New("service unavailable")
+	}
+	u.uploaded = append(u.uploaded, filePath)
+	return nil
+}
+
+func TestUploadBackoff(t *testing.T) {
+	base, max := time.Second, 10*time.Second
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, want := range expected {
+		if got := UploadBackoff(i+1, base, max); got != want {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, want, got)
+		}
+	}
+}
+
+func TestUploadQueue_RetriesWithBackoffAndSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "uploads.json")
+	file := filepath.Join(dir, "BTCUSDT_trade_2025-02-19.parquet")
+	if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	failing := &flakyUploader{failures: 100}
+	q, err := NewUploadQueue(statePath, failing, &FakeLogger{})
+	if err != nil {
+		t.Fatalf("NewUploadQueue returned error: %v", err)
+	}
+	if err := q.Enqueue(file); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	q.Enqueue(file)
+	if q.Backlog() != 1 {
+		t.Fatalf("expected duplicate enqueue to be ignored, backlog %d", q.Backlog())
+	}
+
+	now := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	if wait := q.ProcessDue(context.Background(), now); wait != time.Second {
+		t.Errorf("expected a 1s retry after the first failure, got %s", wait)
+	}
+	q.ProcessDue(context.Background(), now.Add(500*time.Millisecond))
+	if failing.calls != 1 {
+		t.Errorf("expected no attempt before the backoff elapsed, got %d calls", failing.calls)
+	}
+	if wait := q.ProcessDue(context.Background(), now.Add(time.Second)); wait != 2*time.Second {
+		t.Errorf("expected a 2s retry after the second failure, got %s", wait)
+	}
+	if p := q.Pending(); len(p) != 1 || p[0].Attempts != 2 || p[0].LastError != "service unavailable" {
+		t.Errorf("unexpected pending state: %+v", p)
+	}
+
+	// A new process picks up the backlog from the state file and retries immediately
+	ok := &flakyUploader{}
+	restarted, err := NewUploadQueue(statePath, ok, &FakeLogger{})
+	if err != nil {
+		t.Fatalf("NewUploadQueue after restart returned error: %v", err)
+	}
+	if restarted.Backlog() != 1 {
+		t.Fatalf("expected the pending file to survive the restart, backlog %d", restarted.Backlog())
+	}
+	restarted.ProcessDue(context.Background(), now.Add(2*time.Second))
+	if len(ok.uploaded) != 1 || ok.uploaded[0] != file || restarted.Backlog() != 0 {
+		t.Errorf("expected %s to be uploaded after the restart, got %v (backlog %d)", file, ok.uploaded, restarted.Backlog())
+	}
+	reloaded, _ := NewUploadQueue(statePath, ok, &FakeLogger{})
+	if reloaded.Backlog() != 0 {
+		t.Errorf("expected the completed upload to be persisted, backlog %d", reloaded.Backlog())
+	}
+	if !reloaded.Uploaded(file) {
+		t.Errorf("expected %s to be recorded as uploaded across restarts", file)
+	}
+	if err := reloaded.Forget(file); err != nil {
+		t.Fatalf("Forget returned error: %v", err)
+	}
+	if forgotten, _ := NewUploadQueue(statePath, ok, &FakeLogger{}); forgotten.Uploaded(file) {
+		t.Errorf("expected %s to be forgotten", file)
+	}
+}
+
+func TestUploadQueue_DropsMissingFiles(t *testing.T) {
+	u := &flakyUploader{}
+	q, err := NewUploadQueue(filepath.Join(t.TempDir(), "uploads.json"), u, &FakeLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue("/nonexistent/file.parquet")
+	q.ProcessDue(context.Background(), time.Now())
+	if q.Backlog() != 0 || u.calls != 0 {
+		t.Errorf("expected a missing file to be dropped without an upload attempt, backlog %d, calls %d", q.Backlog(), u.calls)
+	}
+}
+
+func TestUploadQueue_DeleteUploadedRemovesLocalCopy(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data.parquet")
+	if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	u := &flakyUploader{failures: 1}
+	q, err := NewUploadQueue(filepath.Join(dir, "uploads.json"), u, &FakeLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.DeleteUploaded = true
+	q.Enqueue(file)
+	now := time.Now()
+	q.ProcessDue(context.Background(), now)
+	if !FileExists(file) {
+		t.Fatal("expected the file to be kept after a failed upload")
+	}
+	q.ProcessDue(context.Background(), now.Add(time.Hour))
+	if len(u.uploaded) != 1 || FileExists(file) {
+		t.Errorf("expected the file to be deleted after its upload, uploaded %v", u.uploaded)
+	}
+}
+
+func TestRecorder_FinalizeHookReportsFinishedFile(t *testing.T) {
+	type Dummy struct {
+		A int `parquet:"name=a, type=INT32"`
+	}
+	t.Chdir(t.TempDir())
+	r, err := NewRecorder[Dummy]("TEST-INSTR-HOOK", "testdata", 1)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	var finished []string
+	r.SetFinalizeHook(func(filePath string) { finished = append(finished, filePath) })
+	r.Write(Dummy{A: 1})
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+	if len(finished) != 1 || finished[0] != r.filePath {
+		t.Errorf("expected the hook to report %s, got %v", r.filePath, finished)
+	}
+}
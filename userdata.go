@@ -0,0 +1,324 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Data types of the user data stream's events.
+const (
+	ExecutionReportDataType = "executionReport"
+	AccountPositionDataType = "outboundAccountPosition"
+	BalanceUpdateDataType   = "balanceUpdate"
+)
+
+// AccountSymbol stands in for the symbol in the file names of user data recordings, which hold the events of every
+// symbol and asset of the account, e.g. ACCOUNT_executionReport_2025-02-19.parquet.
+const AccountSymbol = "ACCOUNT"
+
+// UserDataStreamBaseURL is the WebSocket base the spot user data stream is served from. The market data only
+// data-stream.binance.vision does not serve it. Tests point it at a local mock server.
+var UserDataStreamBaseURL = "wss://stream.binance.com:9443"
+
+// userDataStreamPath is the listen key endpoint, and userDataStreamWeight the request weight of its requests.
+const (
+	userDataStreamPath   = "/api/v3/userDataStream"
+	userDataStreamWeight = 2
+)
+
+// DefaultListenKeyKeepAlive is how often a listen key is kept alive by default. The exchange expires keys that
+// have not been for 60 minutes.
+const DefaultListenKeyKeepAlive = 30 * time.Minute
+
+// ExecutionReport is an update of one of the account's orders: placed, cancelled, rejected, expired or (partially)
+// filled, in which case it carries the fill.
+type ExecutionReport struct {
+	EventType       string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventTime       int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	Symbol          string `json:"s" parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ClientOrderID   string `json:"c" parquet:"name=client_order_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Side            string `json:"S" parquet:"name=side, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	OrderType       string `json:"o" parquet:"name=order_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	TimeInForce     string `json:"f" parquet:"name=time_in_force, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Quantity        string `json:"q" decimal:"true" parquet:"name=quantity, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Price           string `json:"p" decimal:"true" parquet:"name=price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StopPrice       string `json:"P" decimal:"true" parquet:"name=stop_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	IcebergQuantity string `json:"F" decimal:"true" parquet:"name=iceberg_quantity, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OrderListID     int64  `json:"g" parquet:"name=order_list_id, type=INT64"`
+	// OrigClientOrderID is the client order ID of the order being cancelled, for cancellations.
+	OrigClientOrderID string `json:"C" parquet:"name=orig_client_order_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// ExecutionType is what happened (NEW, CANCELED, REPLACED, REJECTED, TRADE, EXPIRED or TRADE_PREVENTION) and
+	// OrderStatus the order's status after it.
+	ExecutionType string `json:"x" parquet:"name=execution_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	OrderStatus   string `json:"X" parquet:"name=order_status, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	RejectReason  string `json:"r" parquet:"name=reject_reason, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	OrderID       int64  `json:"i" parquet:"name=order_id, type=INT64"`
+	// LastQuantity, LastPrice and LastQuoteQuantity describe the fill, if ExecutionType is TRADE.
+	LastQuantity       string `json:"l" decimal:"true" parquet:"name=last_quantity, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CumulativeQuantity string `json:"z" decimal:"true" parquet:"name=cumulative_quantity, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LastPrice          string `json:"L" decimal:"true" parquet:"name=last_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Commission         string `json:"n" decimal:"true" parquet:"name=commission, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CommissionAsset    string `json:"N" parquet:"name=commission_asset, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	TransactionTime    int64  `json:"T" timestamp:"millis" parquet:"name=transaction_time, type=INT64"`
+	// TradeID is -1 unless ExecutionType is TRADE.
+	TradeID          int64  `json:"t" parquet:"name=trade_id, type=INT64"`
+	IsWorking        bool   `json:"w" parquet:"name=is_working, type=BOOLEAN"`
+	IsMaker          bool   `json:"m" parquet:"name=is_maker, type=BOOLEAN"`
+	OrderCreateTime  int64  `json:"O" timestamp:"millis" parquet:"name=order_create_time, type=INT64"`
+	CumulativeQuote  string `json:"Z" decimal:"true" parquet:"name=cumulative_quote, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LastQuoteQty     string `json:"Y" decimal:"true" parquet:"name=last_quote_qty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	QuoteOrderQty    string `json:"Q" decimal:"true" parquet:"name=quote_order_qty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	WorkingTime      int64  `json:"W" timestamp:"millis" parquet:"name=working_time, type=INT64"`
+	SelfTradePrevent string `json:"V" parquet:"name=self_trade_prevention, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// UnmarshalJSON decodes an execution report. Binance also sends an unused "I", an unused "M" and, for orders expired
+// by self-trade prevention, the prevented match ID "v", which encoding/json would otherwise match case-insensitively
+// to OrderID ("i"), IsMaker ("m") and SelfTradePrevent ("V"); they are absorbed by shallower fields here.
+func (r *ExecutionReport) UnmarshalJSON(data []byte) error {
+	type plain ExecutionReport
+	var aux struct {
+		plain
+		IgnoreI          int64 `json:"I"`
+		IgnoreM          bool  `json:"M"`
+		PreventedMatchID int64 `json:"v"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*r = ExecutionReport(aux.plain)
+	return nil
+}
+
+// AccountBalance is the balance of one asset after an account update. An outboundAccountPosition event, sent
+// whenever balances change, is recorded as one row per asset it lists.
+type AccountBalance struct {
+	EventType      string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventTime      int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	LastUpdateTime int64  `json:"u" timestamp:"millis" parquet:"name=last_update_time, type=INT64"`
+	Asset          string `json:"a" parquet:"name=asset, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Free           string `json:"f" decimal:"true" parquet:"name=free, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Locked         string `json:"l" decimal:"true" parquet:"name=locked, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// BalanceUpdate is a deposit, withdrawal or transfer changing the balance of an asset by Delta.
+type BalanceUpdate struct {
+	EventType string `json:"e" parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventTime int64  `json:"E" timestamp:"millis" parquet:"name=event_time, type=INT64"`
+	Asset     string `json:"a" parquet:"name=asset, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Delta     string `json:"d" decimal:"true" parquet:"name=delta, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ClearTime int64  `json:"T" timestamp:"millis" parquet:"name=clear_time, type=INT64"`
+
+	// ConnID and ConnGeneration identify the WebSocket session the event was received on, see WSSession.
+	ConnID         string `json:"conn_id,omitempty" parquet:"name=conn_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnGeneration int64  `json:"conn_generation,omitempty" parquet:"name=conn_generation, type=INT64"`
+}
+
+// UserDataConfig configures recording the spot account's user data stream.
+type UserDataConfig struct {
+	// APIKey authenticates the listen key requests; it defaults to the BINANCE_API_KEY environment variable. No
+	// secret key is needed, and a key with read permissions only is enough.
+	APIKey string `json:"-" yaml:"-"`
+	// KeepAlive is how often the listen key is kept alive, DefaultListenKeyKeepAlive if zero.
+	KeepAlive time.Duration `json:"keep_alive,omitempty" yaml:"keep_alive"`
+}
+
+// Validate checks the settings.
+func (c UserDataConfig) Validate() error {
+	if c.KeepAlive < 0 || c.KeepAlive >= time.Hour {
+		return fmt.Errorf("keep alive must be below the listen key's 60 minute expiry, got %s", c.KeepAlive)
+	}
+	return nil
+}
+
+func (c UserDataConfig) withDefaults() UserDataConfig {
+	if c.APIKey == "" {
+		c.APIKey = os.Getenv("BINANCE_API_KEY")
+	}
+	if c.KeepAlive == 0 {
+		c.KeepAlive = DefaultListenKeyKeepAlive
+	}
+	return c
+}
+
+// UserDataRecorders are where a UserDataStream writes the events of each data type.
+type UserDataRecorders struct {
+	Orders         RecorderWriter[ExecutionReport]
+	Balances       RecorderWriter[AccountBalance]
+	BalanceUpdates RecorderWriter[BalanceUpdate]
+}
+
+// errListenKeyExpired ends a session whose listen key the exchange expired, so the next one creates a new key.
+var errListenKeyExpired = errors.New("listen key expired")
+
+// UserDataStream records the spot account's user data stream: it creates a listen key over REST, keeps it alive and
+// records the order and balance events received on it.
+type UserDataStream struct {
+	cfg    UserDataConfig
+	rest   *SignedClient
+	logger LoggerInterface
+}
+
+// NewUserDataStream creates a UserDataStream requesting listen keys with client. It fails if there is no API key.
+func NewUserDataStream(cfg UserDataConfig, client *http.Client, logger LoggerInterface) (*UserDataStream, error) {
+	cfg = cfg.withDefaults()
+	if cfg.APIKey == "" {
+		return nil, errors.New("user data: an API key is required, set BINANCE_API_KEY")
+	}
+	rest := NewSignedClient(client, MarketSpot, Credentials{APIKey: cfg.APIKey}, DefaultWeightTracker)
+	return &UserDataStream{cfg: cfg, rest: rest, logger: logger}, nil
+}
+
+// Listen runs one user data session against the stream base until ctx is cancelled or the session ends: it
+// creates a listen key, keeps it alive every KeepAlive while listening and closes it afterwards.
+func (u *UserDataStream) Listen(ctx context.Context, base string, recorders UserDataRecorders) error {
+	key, err := u.CreateListenKey(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := u.CloseListenKey(closeCtx, key); err != nil {
+			u.logger.Errorf("Failed to close listen key: %v", err)
+		}
+	}()
+
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := DefaultClock.NewTicker(u.cfg.KeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sessionCtx.Done():
+				return
+			case <-ticker.C():
+				if err := u.KeepAliveListenKey(sessionCtx, key); err != nil && sessionCtx.Err() == nil {
+					// A key that cannot be kept alive expires; reconnecting gets a new one
+					u.logger.Errorf("Failed to keep the listen key alive, reconnecting: %v", err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	err = listenWebSocket(sessionCtx, base+"/ws/"+key, userDataHandler(recorders))
+	if ctx.Err() == nil && sessionCtx.Err() != nil {
+		return errors.New("listen key could not be kept alive")
+	}
+	return err
+}
+
+// CreateListenKey creates a listen key, or returns the account's active one, extending its validity.
+func (u *UserDataStream) CreateListenKey(ctx context.Context) (string, error) {
+	var resp struct {
+		ListenKey string `json:"listenKey"`
+	}
+	if err := u.rest.Do(ctx, http.MethodPost, userDataStreamPath, nil, SecurityAPIKey, userDataStreamWeight, &resp); err != nil {
+		return "", fmt.Errorf("failed to create listen key: %w", err)
+	}
+	if resp.ListenKey == "" {
+		return "", errors.New("failed to create listen key: no key in the response")
+	}
+	return resp.ListenKey, nil
+}
+
+// KeepAliveListenKey extends the validity of key by 60 minutes.
+func (u *UserDataStream) KeepAliveListenKey(ctx context.Context, key string) error {
+	if err := u.rest.Do(ctx, http.MethodPut, userDataStreamPath, url.Values{"listenKey": {key}}, SecurityAPIKey, userDataStreamWeight, nil); err != nil {
+		return fmt.Errorf("failed to keep listen key alive: %w", err)
+	}
+	return nil
+}
+
+// CloseListenKey closes key, ending its stream.
+func (u *UserDataStream) CloseListenKey(ctx context.Context, key string) error {
+	if err := u.rest.Do(ctx, http.MethodDelete, userDataStreamPath, url.Values{"listenKey": {key}}, SecurityAPIKey, userDataStreamWeight, nil); err != nil {
+		return fmt.Errorf("failed to close listen key: %w", err)
+	}
+	return nil
+}
+
+// userDataHandler returns the handler writing the user data events to recorders. Events of other types, such as
+// the listStatus of order lists, are skipped; listenKeyExpired ends the session.
+func userDataHandler(recorders UserDataRecorders) func(msg []byte, session WSSession) error {
+	return func(msg []byte, session WSSession) error {
+		// EventTime absorbs "E", which would otherwise be matched case-insensitively to EventType
+		var event struct {
+			EventType string `json:"e"`
+			EventTime int64  `json:"E"`
+		}
+		if err := json.Unmarshal(msg, &event); err != nil {
+			return fmt.Errorf("failed to unmarshal user data event: %w, raw message: %s", err, msg)
+		}
+		switch event.EventType {
+		case ExecutionReportDataType:
+			var report ExecutionReport
+			if err := json.Unmarshal(msg, &report); err != nil {
+				return fmt.Errorf("failed to unmarshal ExecutionReport: %w, raw message: %s", err, msg)
+			}
+			report.ConnID, report.ConnGeneration = session.ID, session.Generation
+			return recorders.Orders.Write(report)
+		case AccountPositionDataType:
+			balances, err := ParseAccountPosition(msg)
+			if err != nil {
+				return err
+			}
+			for _, b := range balances {
+				b.ConnID, b.ConnGeneration = session.ID, session.Generation
+				if err := recorders.Balances.Write(b); err != nil {
+					return err
+				}
+			}
+			return nil
+		case BalanceUpdateDataType:
+			var update BalanceUpdate
+			if err := json.Unmarshal(msg, &update); err != nil {
+				return fmt.Errorf("failed to unmarshal BalanceUpdate: %w, raw message: %s", err, msg)
+			}
+			update.ConnID, update.ConnGeneration = session.ID, session.Generation
+			return recorders.BalanceUpdates.Write(update)
+		case "listenKeyExpired":
+			return errListenKeyExpired
+		}
+		return nil
+	}
+}
+
+// ParseAccountPosition is a pure function decoding an outboundAccountPosition event into a row per asset.
+func ParseAccountPosition(msg []byte) ([]AccountBalance, error) {
+	var event struct {
+		EventType      string `json:"e"`
+		EventTime      int64  `json:"E"`
+		LastUpdateTime int64  `json:"u"`
+		Balances       []struct {
+			Asset  string `json:"a"`
+			Free   string `json:"f"`
+			Locked string `json:"l"`
+		} `json:"B"`
+	}
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal outboundAccountPosition: %w, raw message: %s", err, msg)
+	}
+	balances := make([]AccountBalance, len(event.Balances))
+	for i, b := range event.Balances {
+		balances[i] = AccountBalance{EventType: event.EventType, EventTime: event.EventTime, LastUpdateTime: event.LastUpdateTime,
+			Asset: b.Asset, Free: b.Free, Locked: b.Locked}
+	}
+	return balances, nil
+}
@@ -0,0 +1,135 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestUserDataHandler(t *testing.T) {
+	orders, balances, updates := &fakeSink{}, &fakeSink{}, &fakeSink{}
+	handler := userDataHandler(UserDataRecorders{
+		Orders:         UntypedWriter[ExecutionReport](orders),
+		Balances:       UntypedWriter[AccountBalance](balances),
+		BalanceUpdates: UntypedWriter[BalanceUpdate](updates),
+	})
+	session := WSSession{ID: "c1", Generation: 2}
+
+	frames := []string{
+		`{"e":"executionReport","E":1739966400000,"s":"BTCUSDT","c":"web_1","S":"BUY","o":"LIMIT","f":"GTC","q":"0.01","p":"95000.00",` +
+			`"P":"0.00","F":"0.00","g":-1,"C":"","x":"TRADE","X":"PARTIALLY_FILLED","r":"NONE","i":4293153,"l":"0.004","z":"0.004",` +
+			`"L":"95000.00","n":"0.00000400","N":"BTC","T":1739966399990,"t":1187,"I":8641984,"w":false,"m":true,"M":true,` +
+			`"O":1739966300000,"Z":"380.00","Y":"380.00","Q":"0.00","W":1739966300000,"V":"EXPIRE_MAKER","v":12}`,
+		`{"e":"outboundAccountPosition","E":1739966400001,"u":1739966399990,"B":[{"a":"BTC","f":"0.004","l":"0.00"},` +
+			`{"a":"USDT","f":"420.00","l":"570.00"}]}`,
+		`{"e":"balanceUpdate","E":1739966400002,"a":"USDT","d":"100.00","T":1739966400000}`,
+		`{"e":"listStatus","E":1739966400003,"s":"BTCUSDT","g":2}`,
+	}
+	for _, frame := range frames {
+		if err := handler([]byte(frame), session); err != nil {
+			t.Fatalf("handler failed on %s: %v", frame, err)
+		}
+	}
+
+	if len(orders.records) != 1 {
+		t.Fatalf("expected one execution report, got %d", len(orders.records))
+	}
+	report := orders.records[0].(ExecutionReport)
+	if report.OrderID != 4293153 || report.ExecutionType != "TRADE" || report.LastQuantity != "0.004" || report.LastPrice != "95000.00" ||
+		report.Commission != "0.00000400" || !report.IsMaker || report.SelfTradePrevent != "EXPIRE_MAKER" || report.ConnID != "c1" {
+		t.Errorf("fields decoded into the wrong columns: %+v", report)
+	}
+	if len(balances.records) != 2 {
+		t.Fatalf("expected a balance row per asset, got %d", len(balances.records))
+	}
+	want := AccountBalance{EventType: "outboundAccountPosition", EventTime: 1739966400001, LastUpdateTime: 1739966399990, Asset: "USDT",
+		Free: "420.00", Locked: "570.00", ConnID: "c1", ConnGeneration: 2}
+	if got := balances.records[1].(AccountBalance); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if len(updates.records) != 1 || updates.records[0].(BalanceUpdate).Delta != "100.00" {
+		t.Errorf("expected the balance update to be recorded, got %+v", updates.records)
+	}
+
+	if err := handler([]byte(`{"e":"listenKeyExpired","E":1739966400004,"listenKey":"k"}`), session); !errors.Is(err, errListenKeyExpired) {
+		t.Errorf("expected an expired listen key to end the session, got %v", err)
+	}
+}
+
+func TestNewUserDataStream_RequiresAPIKey(t *testing.T) {
+	t.Setenv("BINANCE_API_KEY", "")
+	if _, err := NewUserDataStream(UserDataConfig{}, http.DefaultClient, &FakeLogger{}); err == nil {
+		t.Fatal("expected a missing API key to be rejected")
+	}
+	t.Setenv("BINANCE_API_KEY", "key")
+	u, err := NewUserDataStream(UserDataConfig{}, http.DefaultClient, &FakeLogger{})
+	if err != nil {
+		t.Fatalf("expected the API key to be read from the environment: %v", err)
+	}
+	if u.cfg.APIKey != "key" || u.cfg.KeepAlive != DefaultListenKeyKeepAlive {
+		t.Errorf("unexpected defaults %+v", u.cfg)
+	}
+}
+
+func TestRun_RecordsUserData(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade", []byte(`{"e":"trade","E":1700000000000,"s":"BTCUSDT","t":1,"p":"100.00","q":"1","T":1700000000000,"m":false,"M":true}`))
+	srv.SetStream(mockbinance.ListenKey,
+		[]byte(`{"e":"executionReport","E":1739966400000,"s":"BTCUSDT","c":"web_1","S":"SELL","o":"MARKET","x":"NEW","X":"NEW","i":7,"t":-1,"T":1739966400000}`),
+		[]byte(`{"e":"outboundAccountPosition","E":1739966400001,"u":1739966400000,"B":[{"a":"BTC","f":"1.00","l":"0.00"}]}`))
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("BINANCE_API_KEY", "key")
+
+	cfg := DefaultConfig()
+	cfg.Instruments = []string{"BTCUSDT"}
+	cfg.Streams = map[string][]string{"BTCUSDT": {StreamTrade}}
+	cfg.UserData = &UserDataConfig{}
+	cfg.SpillDir = filepath.Join(dir, "spill")
+	cfg.Logger = NewLogger(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rows := make(map[string]int64)
+		for _, s := range Introspect().Recorders {
+			rows[s.Instrument+" "+s.DataType] = s.Rows
+		}
+		if rows["ACCOUNT executionReport"] >= 1 && rows["ACCOUNT outboundAccountPosition"] >= 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("timed out waiting for user data to be recorded, got %v", rows)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	if created, closed := srv.ListenKeyRequests(http.MethodPost), srv.ListenKeyRequests(http.MethodDelete); created != 1 || closed != 1 {
+		t.Errorf("expected the listen key to be created and closed once, got %d and %d", created, closed)
+	}
+	orders, err := ReadRecordingFile(ExecutionReportDataType, BuildFileName(ExecutionReportDataType, AccountSymbol, NowFunc()))
+	if err != nil || len(orders) != 1 {
+		t.Fatalf("expected the execution report to be recorded, got %d (%v)", len(orders), err)
+	}
+	if got := orders[0].(ExecutionReport); got.OrderID != 7 || got.Side != "SELL" || got.ConnID == "" {
+		t.Errorf("unexpected execution report %+v", got)
+	}
+	balances, err := ReadRecordingFile(AccountPositionDataType, BuildFileName(AccountPositionDataType, AccountSymbol, NowFunc()))
+	if err != nil || len(balances) != 1 || balances[0].(AccountBalance).Free != "1.00" {
+		t.Errorf("expected the balance to be recorded, got %+v (%v)", balances, err)
+	}
+}
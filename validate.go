@@ -0,0 +1,252 @@
+package gobinapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Strict validation rejects feed anomalies at ingest instead of letting them surface later in research: messages
+// with fields Binance does not document for the stream, zero or negative IDs, non-numeric price or quantity
+// strings, or event times far from local time. Rejected messages are not forwarded to the recorders; they are
+// appended to a quarantine file together with the reason, so they can be inspected and, if needed, replayed.
+
+// messageSchema lists the documented fields of one stream's payload and which of them are validated how.
+type messageSchema struct {
+	fields []string
+	// optional are further documented fields sent by only some markets, like USD-M futures' previous update ID
+	optional []string
+	ids      []string
+	decimals []string
+	levels   []string
+	// eventTime is the field holding the exchange event time in milliseconds, if the stream has one
+	eventTime string
+}
+
+var messageSchemas = map[string]messageSchema{
+	"trade": {
+		fields:    []string{"e", "E", "s", "t", "p", "q", "b", "a", "T", "m", "M"},
+		ids:       []string{"t"},
+		decimals:  []string{"p", "q"},
+		eventTime: "E",
+	},
+	"aggTrade": {
+		fields:    []string{"e", "E", "s", "a", "p", "q", "f", "l", "T", "m", "M"},
+		ids:       []string{"a", "f", "l"},
+		decimals:  []string{"p", "q"},
+		eventTime: "E",
+	},
+	"depthUpdate": {
+		fields:    []string{"e", "E", "s", "U", "u", "b", "a"},
+		optional:  []string{"T", "pu"},
+		ids:       []string{"U", "u"},
+		levels:    []string{"b", "a"},
+		eventTime: "E",
+	},
+	"bookTicker": {
+		fields:   []string{"u", "s", "b", "B", "a", "A"},
+		optional: []string{"e", "E", "T"},
+		ids:      []string{"u"},
+		decimals: []string{"b", "B", "a", "A"},
+	},
+	"markPriceUpdate": {
+		fields:    []string{"e", "E", "s", "p", "i", "P", "r", "T"},
+		decimals:  []string{"p", "i"},
+		eventTime: "E",
+	},
+	"avgPrice": {
+		fields:    []string{"e", "E", "s", "i", "w", "T"},
+		decimals:  []string{"w"},
+		eventTime: "E",
+	},
+}
+
+// ValidateMessage is a pure function checking a raw stream payload of the given kind ("trade", "aggTrade",
+// "depthUpdate", "bookTicker", "markPriceUpdate" or "avgPrice") against its documented schema. Event times must lie within maxSkew of now.
+func ValidateMessage(kind string, raw []byte, now time.Time, maxSkew time.Duration) error {
+	schema, ok := messageSchemas[kind]
+	if !ok {
+		return fmt.Errorf("no schema for message kind %q", kind)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return fmt.Errorf("malformed message: %w", err)
+	}
+
+	allowed := make(map[string]bool, len(schema.fields)+len(schema.optional))
+	for _, name := range schema.fields {
+		allowed[name] = true
+	}
+	for _, name := range schema.optional {
+		allowed[name] = true
+	}
+	var unexpected []string
+	for name := range fields {
+		if !allowed[name] {
+			unexpected = append(unexpected, name)
+		}
+	}
+	if len(unexpected) > 0 {
+		sort.Strings(unexpected)
+		return fmt.Errorf("unexpected fields %q", unexpected)
+	}
+
+	for _, name := range schema.ids {
+		var id int64
+		if err := json.Unmarshal(fields[name], &id); err != nil || id <= 0 {
+			return fmt.Errorf("field %q must be a positive ID, got %s", name, fields[name])
+		}
+	}
+	for _, name := range schema.decimals {
+		var s string
+		if err := json.Unmarshal(fields[name], &s); err != nil || !IsDecimalString(s) {
+			return fmt.Errorf("field %q must be a numeric string, got %s", name, fields[name])
+		}
+	}
+	for _, name := range schema.levels {
+		var levels [][]string
+		if err := json.Unmarshal(fields[name], &levels); err != nil {
+			return fmt.Errorf("field %q must be a list of price levels: %w", name, err)
+		}
+		for _, level := range levels {
+			if len(level) != 2 || !IsDecimalString(level[0]) || !IsDecimalString(level[1]) {
+				return fmt.Errorf("field %q has a malformed price level %q", name, level)
+			}
+		}
+	}
+	if schema.eventTime != "" {
+		var ms int64
+		if err := json.Unmarshal(fields[schema.eventTime], &ms); err != nil || ms <= 0 {
+			return fmt.Errorf("field %q must be an event time in milliseconds, got %s", schema.eventTime, fields[schema.eventTime])
+		}
+		skew := now.Sub(time.UnixMilli(ms))
+		if skew > maxSkew || skew < -maxSkew {
+			return fmt.Errorf("event time %s is %s away from local time", time.UnixMilli(ms).UTC().Format(time.RFC3339Nano), skew)
+		}
+	}
+	return nil
+}
+
+// IsDecimalString reports whether s is an unsigned decimal number as Binance formats prices and quantities,
+// e.g. "100.01000000".
+func IsDecimalString(s string) bool {
+	if s == "" {
+		return false
+	}
+	dot := false
+	digits := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '.' && !dot && i > 0 && i < len(s)-1:
+			dot = true
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// QuarantineEntry is one line of the quarantine file.
+type QuarantineEntry struct {
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Kind   string    `json:"kind"`
+	Reason string    `json:"reason"`
+	Raw    string    `json:"raw"`
+}
+
+// Validator applies ValidateMessage to incoming messages and appends rejects to a quarantine file.
+type Validator struct {
+	maxSkew time.Duration
+
+	mu       sync.Mutex
+	file     *os.File
+	enc      *json.Encoder
+	rejected int64
+}
+
+// StrictValidator, if set, is used by the listeners to validate every message before decoding it. It is nil by
+// default, which disables strict mode; Run sets it when Config.StrictValidation is enabled. It is read by every
+// listener goroutine, so it is only ever loaded and stored atomically.
+var StrictValidator atomic.Pointer[Validator]
+
+func init() {
+	DefaultMetrics.Describe("binance_messages_rejected_total", "counter", "Messages rejected by strict validation, per stream.")
+}
+
+// NewValidator creates a Validator appending rejects to the quarantine file at quarantinePath.
+func NewValidator(quarantinePath string, maxSkew time.Duration) (*Validator, error) {
+	f, err := os.OpenFile(quarantinePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quarantine file %s: %w", quarantinePath, err)
+	}
+	return &Validator{maxSkew: maxSkew, file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Accept validates a message received on stream and reports whether it should be processed. Rejected messages are
+// written to the quarantine file.
+func (v *Validator) Accept(stream string, kind string, raw []byte) bool {
+	now := NowFunc()
+	err := ValidateMessage(kind, raw, now, v.maxSkew)
+	if err == nil {
+		return true
+	}
+	DefaultMetrics.Add("binance_messages_rejected_total", Labels{"stream": stream}, 1)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.rejected++
+	entry := QuarantineEntry{Time: now.UTC(), Stream: stream, Kind: kind, Reason: err.Error(), Raw: string(raw)}
+	if err := v.enc.Encode(entry); err != nil {
+		log.Printf("failed to write quarantine entry for %s: %v", stream, err)
+	}
+	return false
+}
+
+// Rejected returns the number of messages rejected so far.
+func (v *Validator) Rejected() int64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.rejected
+}
+
+// Close closes the quarantine file.
+func (v *Validator) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.file.Close()
+}
+
+// acceptStrict applies StrictValidator, if set, to a message received from the stream at url.
+func acceptStrict(url string, kind string, raw []byte) bool {
+	validator := StrictValidator.Load()
+	if validator == nil {
+		return true
+	}
+	return validator.Accept(streamNameFromURL(url), kind, raw)
+}
+
+// ReadQuarantineFile reads every entry of a quarantine file.
+func ReadQuarantineFile(filePath string) ([]QuarantineEntry, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var entries []QuarantineEntry
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var entry QuarantineEntry
+		if err := dec.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("failed to parse quarantine file %s: %w", filePath, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,107 @@
+package gobinapi
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestValidateMessage(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	cases := []struct {
+		name    string
+		kind    string
+		raw     string
+		wantErr string
+	}{
+		{"valid trade", "trade", `{"e":"trade","E":1700000000000,"s":"BTCUSDT","t":1,"p":"100.0","q":"0.5","T":1700000000000,"m":false,"M":true}`, ""},
+		{"unexpected field", "trade", `{"e":"trade","E":1700000000000,"t":1,"p":"100.0","q":"0.5","x":1}`, `unexpected fields ["x"]`},
+		{"zero ID", "trade", `{"e":"trade","E":1700000000000,"t":0,"p":"100.0","q":"0.5"}`, `"t" must be a positive ID`},
+		{"missing ID", "aggTrade", `{"e":"aggTrade","E":1700000000000,"p":"100.0","q":"0.5","f":1,"l":2}`, `"a" must be a positive ID`},
+		{"non-numeric price", "trade", `{"e":"trade","E":1700000000000,"t":1,"p":"1e5","q":"0.5"}`, `"p" must be a numeric string`},
+		{"stale event time", "trade", `{"e":"trade","E":1699999000000,"t":1,"p":"100.0","q":"0.5"}`, "away from local time"},
+		{"future event time", "depthUpdate", `{"e":"depthUpdate","E":1700000061000,"U":1,"u":1,"b":[],"a":[]}`, "away from local time"},
+		{"valid depth", "depthUpdate", `{"e":"depthUpdate","E":1700000000000,"s":"BTCUSDT","U":1,"u":2,"b":[["100.0","1"]],"a":[]}`, ""},
+		{"malformed level", "depthUpdate", `{"e":"depthUpdate","E":1700000000000,"U":1,"u":2,"b":[["100.0","-1"]],"a":[]}`, "malformed price level"},
+		{"valid book ticker", "bookTicker", `{"u":5,"s":"BTCUSDT","b":"100.0","B":"1","a":"100.1","A":"2"}`, ""},
+		{"valid futures depth", "depthUpdate", `{"e":"depthUpdate","E":1700000000000,"T":1700000000000,"s":"BTCUSDT","U":3,"u":4,"pu":2,"b":[],"a":[]}`, ""},
+		{"valid futures book ticker", "bookTicker", `{"e":"bookTicker","u":5,"E":1700000000000,"T":1700000000000,"s":"BTCUSDT","b":"100.0","B":"1","a":"100.1","A":"2"}`, ""},
+		{"valid mark price", "markPriceUpdate", `{"e":"markPriceUpdate","E":1700000000000,"s":"BTCUSDT","p":"100.1","i":"100.0","P":"100.2","r":"0.0001","T":1700006400000}`, ""},
+		{"malformed mark price", "markPriceUpdate", `{"e":"markPriceUpdate","E":1700000000000,"s":"BTCUSDT","p":"","i":"100.0","P":"100.2","r":"0.0001","T":1700006400000}`, "numeric string"},
+		{"valid average price", "avgPrice", `{"e":"avgPrice","E":1700000000000,"s":"BTCUSDT","i":"5m","w":"100.01","T":1699999999999}`, ""},
+		{"malformed average price", "avgPrice", `{"e":"avgPrice","E":1700000000000,"s":"BTCUSDT","i":"5m","w":100.01,"T":1699999999999}`, "numeric string"},
+		{"not JSON", "bookTicker", `nope`, "malformed message"},
+	}
+	for _, c := range cases {
+		err := ValidateMessage(c.kind, []byte(c.raw), now, time.Minute)
+		if c.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+		} else if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+			t.Errorf("%s: expected error containing %q, got %v", c.name, c.wantErr, err)
+		}
+	}
+}
+
+func TestIsDecimalString(t *testing.T) {
+	for s, want := range map[string]bool{
+		"100": true, "100.01000000": true, "0.1": true,
+		"": false, ".5": false, "5.": false, "1.2.3": false, "-1": false, "NaN": false, "1e5": false,
+	} {
+		if got := IsDecimalString(s); got != want {
+			t.Errorf("IsDecimalString(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+// TestStrictValidator_QuarantinesRejects runs a trade listener in strict mode against the mock server and checks
+// that only the valid trade is forwarded while the anomalous one ends up in the quarantine file.
+func TestStrictValidator_QuarantinesRejects(t *testing.T) {
+	srv := useMockServer(t)
+	good := mockbinance.TradeMessage("BTCUSDT", 1, "100.0", "0.5")
+	bad := mockbinance.TradeMessage("BTCUSDT", 2, "abc", "0.5")
+	srv.SetStream("btcusdt@trade", bad, good)
+	srv.SetCloseAfterFrames(true)
+
+	oldNow := NowFunc
+	NowFunc = func() time.Time { return time.UnixMilli(1700000000001) }
+	quarantine := filepath.Join(t.TempDir(), "quarantine.jsonl")
+	validator, err := NewValidator(quarantine, time.Minute)
+	if err != nil {
+		t.Fatalf("NewValidator returned error: %v", err)
+	}
+	StrictValidator.Store(validator)
+	defer func() {
+		StrictValidator.Store(nil)
+		NowFunc = oldNow
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tradeChan := make(chan Trade, 2)
+	ListenTrade(ctx, "BTCUSDT", tradeChan)
+	close(tradeChan)
+	validator.Close()
+
+	var ids []int64
+	for trade := range tradeChan {
+		ids = append(ids, trade.TradeID)
+	}
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("expected only trade 1 to be forwarded, got %v", ids)
+	}
+	if validator.Rejected() != 1 {
+		t.Errorf("expected 1 rejected message, got %d", validator.Rejected())
+	}
+	entries, err := ReadQuarantineFile(quarantine)
+	if err != nil {
+		t.Fatalf("ReadQuarantineFile returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Stream != "btcusdt@trade" || entries[0].Raw != string(bad) ||
+		!strings.Contains(entries[0].Reason, `"p" must be a numeric string`) {
+		t.Errorf("unexpected quarantine entries: %+v", entries)
+	}
+}
@@ -0,0 +1,92 @@
+package gobinapi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func init() {
+	DefaultMetrics.Describe("binance_recorder_verify_failures_total", "counter", "Finished files whose rows did not match the records written, per symbol and stream.")
+}
+
+// ChecksumSuffix is appended to a file's path to name its checksum sidecar, see WriteChecksumFile.
+const ChecksumSuffix = ".sha256"
+
+// WriteChecksumFile writes the sidecar of the file at filePath holding sum, its hex encoded SHA-256, in the format of
+// sha256sum, so `sha256sum -c` run next to the file checks it.
+func WriteChecksumFile(filePath, sum string) error {
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(filePath))
+	if err := os.WriteFile(filePath+ChecksumSuffix, []byte(line), 0644); err != nil {
+		return fmt.Errorf("failed to write checksum of %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// VerifyChecksum checks the file at filePath against its checksum sidecar.
+func VerifyChecksum(filePath string) error {
+	data, err := os.ReadFile(filePath + ChecksumSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to read checksum of %s: %w", filePath, err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty checksum file for %s", filePath)
+	}
+	_, actual, err := FileChecksum(filePath)
+	if err != nil {
+		return err
+	}
+	if expected := strings.ToLower(fields[0]); actual != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filePath, expected, actual)
+	}
+	return nil
+}
+
+// VerifyParquetFile re-opens the parquet file at filePath and checks that its footer is readable and that it holds
+// rows rows, in row groups adding up to that.
+func VerifyParquetFile(filePath string, rows int64) error {
+	fr, err := local.NewLocalFileReader(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		return fmt.Errorf("failed to read parquet footer of %s: %w", filePath, err)
+	}
+	defer pr.ReadStop()
+	var grouped int64
+	for _, rg := range pr.Footer.RowGroups {
+		grouped += rg.NumRows
+	}
+	if n := pr.GetNumRows(); n != rows || grouped != rows {
+		return fmt.Errorf("%s holds %d rows in row groups of %d rows, expected %d", filePath, n, grouped, rows)
+	}
+	return nil
+}
+
+// SetChecksums makes the recorder write a checksum sidecar (see WriteChecksumFile) next to every file it finishes
+// under its final name. It must be called before the first Write.
+func (r *Recorder[T]) SetChecksums(enabled bool) {
+	r.checksums = enabled
+}
+
+// SetVerify makes the recorder re-open every file it finishes and check that it holds the rows written to it (see
+// VerifyParquetFile). A file that does not raises an AlertCorruptFile alert; it is finalized all the same, so its
+// data is kept for inspection. It must be called before the first Write.
+func (r *Recorder[T]) SetVerify(enabled bool) {
+	r.verify = enabled
+}
+
+// verifyFile checks the just finished current file, raising an alert if it does not hold the rows written to it.
+func (r *Recorder[T]) verifyFile() {
+	if err := VerifyParquetFile(r.filePath, r.partRows); err != nil {
+		DefaultMetrics.Add("binance_recorder_verify_failures_total", r.metricLabels(), 1)
+		DefaultAlerts.Raise(Alert{Kind: AlertCorruptFile, Symbol: r.instrument, Stream: r.dataType, Message: err.Error()})
+	}
+}
@@ -0,0 +1,82 @@
+package gobinapi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRecorder_WritesChecksumSidecar checks that a finished file gets a sidecar that verifies it, and stops
+// verifying once the file changes.
+func TestRecorder_WritesChecksumSidecar(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	r, err := NewRecorder[Trade]("BTCUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	r.SetChecksums(true)
+	if err := r.Write(Trade{TradeID: 1, Price: "100", Quantity: "1"}); err != nil {
+		t.Fatalf("failed to write trade: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	data, err := os.ReadFile(r.filePath + ChecksumSuffix)
+	if err != nil {
+		t.Fatalf("expected a checksum sidecar: %v", err)
+	}
+	if !strings.HasSuffix(string(data), "  "+filepath.Base(r.filePath)+"\n") {
+		t.Errorf("expected a sha256sum line for %s, got %q", r.filePath, data)
+	}
+	if err := VerifyChecksum(r.filePath); err != nil {
+		t.Errorf("expected the file to match its checksum: %v", err)
+	}
+	if err := os.WriteFile(r.filePath, []byte("corrupt"), 0644); err != nil {
+		t.Fatalf("failed to overwrite file: %v", err)
+	}
+	if err := VerifyChecksum(r.filePath); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+}
+
+// TestRecorder_VerifiesFinishedFiles checks that verification passes for a file holding every record but those
+// dropped for not fitting decimal columns, and that VerifyParquetFile rejects a wrong row count.
+func TestRecorder_VerifiesFinishedFiles(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	oldOptions := DefaultParquetOptions
+	defer func() { DefaultParquetOptions = oldOptions }()
+	DefaultParquetOptions = ParquetOptions{Numbers: NumbersDecimal, DecimalScale: 2}
+	failures := func() float64 {
+		return DefaultMetrics.Value("binance_recorder_verify_failures_total", Labels{"symbol": "BTCUSDT", "stream": "trade"})
+	}
+	before := failures()
+
+	r, err := NewRecorder[Trade]("BTCUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	r.SetVerify(true)
+	for _, price := range []string{"100", "1.001", "101"} {
+		if err := r.Write(Trade{Price: price, Quantity: "1"}); err != nil {
+			t.Fatalf("failed to write trade: %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+	if got := failures() - before; got != 0 {
+		t.Errorf("expected the file to verify, got %v failures", got)
+	}
+
+	if err := VerifyParquetFile(r.filePath, 2); err != nil {
+		t.Errorf("expected 2 rows: %v", err)
+	}
+	if err := VerifyParquetFile(r.filePath, 3); err == nil {
+		t.Error("expected a row count mismatch")
+	}
+}
@@ -0,0 +1,373 @@
+package gobinapi
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VisionBaseURL is the host of the exchange's bulk historical data archives. Tests point it at a local server.
+var VisionBaseURL = "https://data.binance.vision"
+
+// SourceVision marks trades and aggregate trades read from the bulk archives by a VisionDownloader in their source
+// column.
+const SourceVision = "vision"
+
+// errArchiveNotFound is returned for archives that are not published, either because the symbol did not trade that
+// day or because the day is too recent.
+var errArchiveNotFound = errors.New("archive not found")
+
+// VisionDownloader converts the daily and monthly zip archives of data.binance.vision into recordings. Archives are
+// checked against their published SHA-256 checksums before they are read.
+type VisionDownloader struct {
+	client *http.Client
+	market string
+	logger LoggerInterface
+}
+
+// NewVisionDownloader creates a VisionDownloader for market (MarketSpot or MarketUSDM). Monthly archives run to
+// gigabytes, so client should not have a short overall timeout.
+func NewVisionDownloader(client *http.Client, market string, logger LoggerInterface) *VisionDownloader {
+	return &VisionDownloader{client: client, market: market, logger: logger}
+}
+
+// visionArchive names a series of archives: kind is the archive directory (trades, aggTrades or klines) and interval
+// the kline interval.
+type visionArchive struct {
+	market, kind, symbol, interval string
+}
+
+// url returns the address of the archive of period ("daily" or "monthly") named by date, e.g.
+// data/spot/daily/klines/BTCUSDT/1m/BTCUSDT-1m-2025-01-01.zip.
+func (a visionArchive) url(period, date string) string {
+	market, dir, name := "spot", a.kind+"/"+a.symbol, a.kind
+	if a.market == MarketUSDM {
+		market = "futures/um"
+	}
+	if a.interval != "" {
+		dir, name = dir+"/"+a.interval, a.interval
+	}
+	return fmt.Sprintf("%s/data/%s/%s/%s/%s-%s-%s.zip", VisionBaseURL, market, period, dir, a.symbol, name, date)
+}
+
+// Download converts symbol's trades, aggregate trades or klines (dataType "trade", "aggTrade" or a KlineDataType)
+// from [start, end) into the recordings under layout. Whole calendar months are read from the monthly archive when it
+// is published and all other days from the daily archives. Trades already recorded, by ID, are skipped and the rest
+// go to the day's next part file like a Backfill's, while klines are merged into the day's file like BackfillKlines,
+// so Download fills the days a recorder missed without duplicating the ones it recorded. Days without an archive are
+// listed in the stats' Unavailable.
+func (d *VisionDownloader) Download(ctx context.Context, layout FileLayout, dataType, symbol string, start, end time.Time) (BackfillStats, error) {
+	archive := visionArchive{market: d.market, symbol: symbol}
+	switch dataType {
+	case "trade":
+		archive.kind = "trades"
+		key := func(t Trade) (int64, int64) { return t.TradeID, t.TradeTime }
+		return downloadArchives(ctx, d, archive, start, end, parseVisionTrade, key, func(day time.Time, records []Trade) (int, string, error) {
+			return writeMissing(layout, dataType, symbol, day, records, key)
+		})
+	case "aggTrade":
+		archive.kind = "aggTrades"
+		key := func(t AggTrade) (int64, int64) { return t.AggTradeID, t.TradeTime }
+		parse := func(fields []string) (AggTrade, error) { return parseVisionAggTrade(fields, symbol) }
+		return downloadArchives(ctx, d, archive, start, end, parse, key, func(day time.Time, records []AggTrade) (int, string, error) {
+			return writeMissing(layout, dataType, symbol, day, records, key)
+		})
+	}
+	interval, ok := KlineInterval(dataType)
+	if !ok {
+		return BackfillStats{}, fmt.Errorf("unsupported data type for archive download: %s", dataType)
+	}
+	archive.kind, archive.interval = "klines", interval
+	key := func(k Kline) (int64, int64) { return k.OpenTime, k.OpenTime }
+	parse := func(fields []string) (Kline, error) { return parseVisionKline(fields, symbol, interval) }
+	return downloadArchives(ctx, d, archive, start, end, parse, key, func(day time.Time, records []Kline) (int, string, error) {
+		return mergeDayFile(layout, dataType, symbol, day, records, func(k Kline) int64 { return k.OpenTime })
+	})
+}
+
+// downloadArchives reads the archives covering [start, end), parsing their rows with parse and passing them to write
+// a UTC day at a time. key returns a record's ID and time in milliseconds.
+func downloadArchives[T any](ctx context.Context, d *VisionDownloader, archive visionArchive, start, end time.Time, parse func([]string) (T, error), key func(T) (int64, int64), write func(time.Time, []T) (int, string, error)) (BackfillStats, error) {
+	var stats BackfillStats
+	for day := start.UTC().Truncate(24 * time.Hour); day.Before(end); {
+		month := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		nextMonth := month.AddDate(0, 1, 0)
+		if day.Equal(month) && !start.After(month) && !nextMonth.After(end) {
+			err := convertArchive(ctx, d, archive.url("monthly", month.Format("2006-01")), start, end, parse, key, write, &stats)
+			if err == nil {
+				day = nextMonth
+				continue
+			}
+			if !errors.Is(err, errArchiveNotFound) {
+				return stats, err
+			}
+			// Monthly archives appear a few days into the next month; until then the daily ones cover it
+		}
+		date := day.Format("2006-01-02")
+		err := convertArchive(ctx, d, archive.url("daily", date), start, end, parse, key, write, &stats)
+		if errors.Is(err, errArchiveNotFound) {
+			d.logger.Infof("vision: no %s archive for %s on %s", archive.kind, archive.symbol, date)
+			stats.Unavailable = append(stats.Unavailable, date)
+		} else if err != nil {
+			return stats, err
+		}
+		day = day.Add(24 * time.Hour)
+	}
+	return stats, nil
+}
+
+// convertArchive downloads the archive at url and writes its records in [start, end) day by day, adding to stats.
+func convertArchive[T any](ctx context.Context, d *VisionDownloader, url string, start, end time.Time, parse func([]string) (T, error), key func(T) (int64, int64), write func(time.Time, []T) (int, string, error), stats *BackfillStats) error {
+	archivePath, err := d.fetch(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archivePath)
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s: %w", url, err)
+	}
+	defer zr.Close()
+
+	var day time.Time
+	var records []T
+	flush := func() error {
+		if len(records) == 0 {
+			return nil
+		}
+		added, filePath, err := write(day, records)
+		if err != nil {
+			return err
+		}
+		if added > 0 {
+			stats.Recorded += added
+			stats.Files = append(stats.Files, filePath)
+		}
+		records = nil
+		return nil
+	}
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".csv") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %s in %s: %w", f.Name, url, err)
+		}
+		err = readVisionCSV(rc, parse, func(record T) error {
+			_, millis := key(record)
+			if millis < start.UnixMilli() || millis >= end.UnixMilli() {
+				return nil
+			}
+			stats.Fetched++
+			if recordDay := time.UnixMilli(millis).UTC().Truncate(24 * time.Hour); !recordDay.Equal(day) {
+				if err := flush(); err != nil {
+					return err
+				}
+				day = recordDay
+			}
+			records = append(records, record)
+			return nil
+		})
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %w", url, err)
+		}
+	}
+	return flush()
+}
+
+// readVisionCSV parses the rows of an archived CSV file with parse and passes them to handle. The header row that
+// some archives start with is skipped.
+func readVisionCSV[T any](r io.Reader, parse func([]string) (T, error), handle func(T) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	for line := 1; ; line++ {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := strconv.ParseInt(fields[0], 10, 64); err != nil && line == 1 {
+			continue
+		}
+		record, err := parse(fields)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := handle(record); err != nil {
+			return err
+		}
+	}
+}
+
+// fetch downloads the archive at url to a temporary file and returns its path once the file matches the checksum
+// published next to it. Archives without a checksum are not downloaded.
+func (d *VisionDownloader) fetch(ctx context.Context, url string) (string, error) {
+	checksum, err := d.get(ctx, url+".CHECKSUM")
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(checksum)
+	checksum.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read checksum of %s: %w", url, err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum for %s", url)
+	}
+	expected := strings.ToLower(fields[0])
+
+	body, err := d.get(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	f, err := os.CreateTemp("", "gobinapi-vision-*.zip")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hash), body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, expected, actual)
+	}
+	return f.Name(), nil
+}
+
+// get returns the body of a GET request to url, or errArchiveNotFound for a 404.
+func (d *VisionDownloader) get(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("archive request failed: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", url, errArchiveNotFound)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("archive request %s returned %s", url, resp.Status)
+	}
+}
+
+// visionRow parses the fields of an archived CSV row, keeping the first error.
+type visionRow struct {
+	fields []string
+	err    error
+}
+
+func (r *visionRow) int(i int) int64 {
+	v, err := strconv.ParseInt(r.fields[i], 10, 64)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("malformed field %d: %w", i, err)
+	}
+	return v
+}
+
+// millis parses a timestamp, which spot archives give in microseconds since 2025.
+func (r *visionRow) millis(i int) int64 {
+	v := r.int(i)
+	if v > 1e14 {
+		v /= 1000
+	}
+	return v
+}
+
+func (r *visionRow) bool(i int) bool {
+	v, err := strconv.ParseBool(r.fields[i])
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("malformed field %d: %w", i, err)
+	}
+	return v
+}
+
+// parseVisionTrade is a pure function converting a row of a trades archive: ID, price, quantity, quote quantity,
+// time and whether the buyer was the maker.
+func parseVisionTrade(fields []string) (Trade, error) {
+	if len(fields) < 6 {
+		return Trade{}, fmt.Errorf("malformed trade with %d fields", len(fields))
+	}
+	r := &visionRow{fields: fields}
+	t := Trade{EventType: "trade", TradeID: r.int(0), Price: fields[1], Quantity: fields[2], TradeTime: r.millis(4), IsBuyerMaker: r.bool(5), Source: SourceVision}
+	t.EventTime = t.TradeTime
+	t.Side, t.Notional = DeriveTradeFields(t.Price, t.Quantity, t.IsBuyerMaker)
+	return t, r.err
+}
+
+// parseVisionAggTrade is a pure function converting a row of an aggTrades archive: aggregate trade ID, price,
+// quantity, first and last trade ID, time and whether the buyer was the maker.
+func parseVisionAggTrade(fields []string, symbol string) (AggTrade, error) {
+	if len(fields) < 7 {
+		return AggTrade{}, fmt.Errorf("malformed aggregate trade with %d fields", len(fields))
+	}
+	r := &visionRow{fields: fields}
+	t := AggTrade{
+		EventType:    "aggTrade",
+		Symbol:       symbol,
+		AggTradeID:   r.int(0),
+		Price:        fields[1],
+		Quantity:     fields[2],
+		FirstTradeID: r.int(3),
+		LastTradeID:  r.int(4),
+		TradeTime:    r.millis(5),
+		IsBuyerMaker: r.bool(6),
+		Source:       SourceVision,
+	}
+	t.EventTime = t.TradeTime
+	t.Side, t.Notional = DeriveTradeFields(t.Price, t.Quantity, t.IsBuyerMaker)
+	return t, r.err
+}
+
+// parseVisionKline is a pure function converting a row of a klines archive, whose columns are those of the klines
+// endpoint (see parseKlines).
+func parseVisionKline(fields []string, symbol, interval string) (Kline, error) {
+	if len(fields) < 11 {
+		return Kline{}, fmt.Errorf("malformed kline with %d fields", len(fields))
+	}
+	r := &visionRow{fields: fields}
+	k := Kline{
+		Symbol:              symbol,
+		Interval:            interval,
+		OpenTime:            r.millis(0),
+		Open:                fields[1],
+		High:                fields[2],
+		Low:                 fields[3],
+		Close:               fields[4],
+		Volume:              fields[5],
+		CloseTime:           r.millis(6),
+		QuoteVolume:         fields[7],
+		Trades:              r.int(8),
+		TakerBuyVolume:      fields[9],
+		TakerBuyQuoteVolume: fields[10],
+	}
+	return k, r.err
+}
@@ -0,0 +1,188 @@
+package gobinapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVision serves zipped CSV archives and their checksums by path.
+type fakeVision struct {
+	mu        sync.Mutex
+	archives  map[string][]byte
+	checksums map[string]string
+	requests  []string
+}
+
+func newFakeVision(t *testing.T) *fakeVision {
+	v := &fakeVision{archives: make(map[string][]byte), checksums: make(map[string]string)}
+	srv := httptest.NewServer(v)
+	t.Cleanup(srv.Close)
+	old := VisionBaseURL
+	VisionBaseURL = srv.URL
+	t.Cleanup(func() { VisionBaseURL = old })
+	return v
+}
+
+// add publishes an archive at path holding a single CSV file of rows.
+func (v *fakeVision) add(t *testing.T, path string, rows ...string) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	name := path[strings.LastIndex(path, "/")+1:]
+	w, err := zw.Create(strings.TrimSuffix(name, ".zip") + ".csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(strings.Join(rows, "\n") + "\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.archives[path] = buf.Bytes()
+	v.checksums[path] = hex.EncodeToString(sum[:]) + "  " + name + "\n"
+}
+
+func (v *fakeVision) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.requests = append(v.requests, r.URL.Path)
+	if path, ok := strings.CutSuffix(r.URL.Path, ".CHECKSUM"); ok {
+		if sum, ok := v.checksums[path]; ok {
+			w.Write([]byte(sum))
+			return
+		}
+	} else if data, ok := v.archives[r.URL.Path]; ok {
+		w.Write(data)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func TestVisionDownloader_FillsMissingTradesFromDailyArchives(t *testing.T) {
+	vision := newFakeVision(t)
+	day := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+	var rows []string
+	for id := 1; id <= 10; id++ {
+		// Spot archives give microseconds
+		micros := day.Add(time.Duration(id) * time.Minute).UnixMicro()
+		rows = append(rows, strings.Join([]string{strconv.Itoa(id), "100.5", "2", "201", strconv.FormatInt(micros, 10), "True", "True"}, ","))
+	}
+	vision.add(t, "/data/spot/daily/trades/BTCUSDT/BTCUSDT-trades-2025-02-19.zip", rows...)
+
+	layout := FileLayout{Root: t.TempDir()}
+	var recorded []Trade
+	for id := int64(1); id <= 4; id++ {
+		recorded = append(recorded, Trade{TradeID: id, TradeTime: day.Add(time.Duration(id) * time.Minute).UnixMilli()})
+	}
+	if err := WriteParquetFile(layout.FilePath("trade", "BTCUSDT", day, 0), recorded); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewVisionDownloader(http.DefaultClient, MarketSpot, &FakeLogger{})
+	stats, err := d.Download(context.Background(), layout, "trade", "BTCUSDT", day, day.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Fetched != 10 || stats.Recorded != 6 || len(stats.Files) != 1 {
+		t.Fatalf("expected 6 of 10 trades recorded in one file, got %+v", stats)
+	}
+	if len(stats.Unavailable) != 1 || stats.Unavailable[0] != "2025-02-20" {
+		t.Errorf("expected the second day to be reported unavailable, got %v", stats.Unavailable)
+	}
+	trades, err := ReadParquetFile[Trade](layout.FilePath("trade", "BTCUSDT", day, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := trades[0]
+	if len(trades) != 6 || first.TradeID != 5 || first.TradeTime != day.Add(5*time.Minute).UnixMilli() {
+		t.Fatalf("unexpected trades %+v", trades)
+	}
+	if first.Source != SourceVision || !first.IsBuyerMaker || first.Side != TradeSideSell || first.Notional != "201" {
+		t.Errorf("unexpected converted trade %+v", first)
+	}
+}
+
+func TestVisionDownloader_ReadsMonthlyArchivesAndFallsBackToDaily(t *testing.T) {
+	vision := newFakeVision(t)
+	header := "open_time,open,high,low,close,volume,close_time,quote_volume,count,taker_buy_volume,taker_buy_quote_volume,ignore"
+	kline := func(open time.Time) string {
+		ms := open.UnixMilli()
+		return strings.Join([]string{strconv.FormatInt(ms, 10), "1", "2", "0.5", "1.5", "10", strconv.FormatInt(ms+59999, 10), "15", "3", "4", "6", "0"}, ",")
+	}
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := jan.AddDate(0, 1, 0)
+	vision.add(t, "/data/futures/um/monthly/klines/BTCUSDT/1m/BTCUSDT-1m-2025-01.zip", header, kline(jan), kline(jan.Add(time.Minute)), kline(feb.Add(-time.Minute)))
+	vision.add(t, "/data/futures/um/daily/klines/BTCUSDT/1m/BTCUSDT-1m-2025-02-01.zip", header, kline(feb))
+
+	layout := FileLayout{Root: t.TempDir(), Hive: true}
+	d := NewVisionDownloader(http.DefaultClient, MarketUSDM, &FakeLogger{})
+	stats, err := d.Download(context.Background(), layout, KlineDataType("1m"), "BTCUSDT", jan, feb.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Recorded != 4 || len(stats.Files) != 3 || len(stats.Unavailable) != 0 {
+		t.Fatalf("expected 4 klines in 3 day files, got %+v", stats)
+	}
+	klines, err := ReadParquetFile[Kline](layout.FilePath("kline_1m", "BTCUSDT", jan, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(klines) != 2 || klines[1].OpenTime != jan.Add(time.Minute).UnixMilli() || klines[1].Trades != 3 || klines[1].Interval != "1m" {
+		t.Errorf("unexpected klines %+v", klines)
+	}
+
+	// Running it again over a published range adds nothing
+	stats, err = d.Download(context.Background(), layout, KlineDataType("1m"), "BTCUSDT", feb, feb.Add(24*time.Hour))
+	if err != nil || stats.Fetched != 1 || stats.Recorded != 0 {
+		t.Errorf("expected the day to be complete already, got %+v, %v", stats, err)
+	}
+	vision.mu.Lock()
+	defer vision.mu.Unlock()
+	for _, path := range vision.requests {
+		if strings.Contains(path, "monthly") && strings.Contains(path, "2025-02") {
+			t.Errorf("requested the monthly archive of a partly covered month: %s", path)
+		}
+	}
+}
+
+func TestVisionDownloader_RejectsChecksumMismatch(t *testing.T) {
+	vision := newFakeVision(t)
+	path := "/data/spot/daily/aggTrades/BTCUSDT/BTCUSDT-aggTrades-2025-02-19.zip"
+	vision.add(t, path, "1,100.5,2,1,1,1739923200000,False,True")
+	vision.checksums[path] = strings.Repeat("0", 64) + "  BTCUSDT-aggTrades-2025-02-19.zip\n"
+
+	layout := FileLayout{Root: t.TempDir()}
+	d := NewVisionDownloader(http.DefaultClient, MarketSpot, &FakeLogger{})
+	day := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+	_, err := d.Download(context.Background(), layout, "aggTrade", "BTCUSDT", day, day.Add(24*time.Hour))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	if FileExists(layout.FilePath("aggTrade", "BTCUSDT", day, 0)) {
+		t.Error("expected nothing to be written from a corrupt archive")
+	}
+}
+
+func TestParseVisionAggTrade(t *testing.T) {
+	trade, err := parseVisionAggTrade(strings.Split("26129,0.01633102,4.70443515,27781,27781,1498793709153,true,true", ","), "BNBBTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trade.AggTradeID != 26129 || trade.FirstTradeID != 27781 || trade.TradeTime != 1498793709153 || !trade.IsBuyerMaker || trade.Symbol != "BNBBTC" {
+		t.Errorf("unexpected aggregate trade %+v", trade)
+	}
+	if _, err := parseVisionAggTrade(strings.Split("26129,0.01633102,4.70443515,x,27781,1498793709153,true", ","), "BNBBTC"); err == nil {
+		t.Error("expected a malformed first trade ID to be rejected")
+	}
+}
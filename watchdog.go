@@ -0,0 +1,61 @@
+package gobinapi
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// StreamIdleTimeout is how long a WebSocket connection may go without receiving anything (a message, ping or pong)
+// before it is considered half-dead, force-closed and reported with ErrStreamStalled so it is reconnected. Binance
+// pings spot connections every 20 seconds, so a healthy connection is never idle for long even when its stream is
+// quiet. Zero disables the watchdog. Run sets it from Config.StreamIdleTimeout.
+var StreamIdleTimeout = time.Minute
+
+// ErrStreamStalled is returned by a listener whose connection was closed by the liveness watchdog.
+var ErrStreamStalled = errors.New("no data received within the stream idle timeout")
+
+func init() {
+	DefaultMetrics.Describe("binance_ws_stalls_total", "counter", "WebSocket connections closed by the liveness watchdog, per stream.")
+}
+
+// streamWatchdog tracks when a connection last received anything. Touch may be called from the read goroutine,
+// e.g. by ping and pong handlers, while the listener loop waits on C.
+type streamWatchdog struct {
+	timeout time.Duration
+	last    atomic.Int64
+	timer   Timer
+}
+
+// newStreamWatchdog starts a watchdog at now that expires timeout after the last Touch.
+func newStreamWatchdog(timeout time.Duration, now time.Time) *streamWatchdog {
+	w := &streamWatchdog{timeout: timeout, timer: DefaultClock.NewTimer(timeout)}
+	w.last.Store(now.UnixNano())
+	return w
+}
+
+// Touch records that the connection received something at now.
+func (w *streamWatchdog) Touch(now time.Time) {
+	w.last.Store(now.UnixNano())
+}
+
+// C fires when the watchdog may have expired; call Expired to find out.
+func (w *streamWatchdog) C() <-chan time.Time {
+	return w.timer.C()
+}
+
+// Expired reports whether nothing was received within the timeout before now. If something was, the watchdog is
+// re-armed for the remainder of the window and must be waited on again through C.
+func (w *streamWatchdog) Expired(now time.Time) bool {
+	idle := now.Sub(time.Unix(0, w.last.Load()))
+	if idle >= w.timeout {
+		return true
+	}
+	w.timer = DefaultClock.NewTimer(w.timeout - idle)
+	return false
+}
+
+// Stop stops the watchdog's timer.
+func (w *streamWatchdog) Stop() {
+	w.timer.Stop()
+}
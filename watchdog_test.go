@@ -0,0 +1,63 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+func TestStreamWatchdog_ReArmsUntilIdle(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	clock := useFakeClock(t, start)
+	w := newStreamWatchdog(time.Minute, start)
+	defer w.Stop()
+
+	clock.Advance(40 * time.Second)
+	w.Touch(clock.Now())
+	clock.Advance(20 * time.Second)
+	<-w.C()
+	if w.Expired(clock.Now()) {
+		t.Fatalf("expected a connection touched 20s ago not to have expired")
+	}
+	// Re-armed for the rest of the window since the touch
+	clock.Advance(39 * time.Second)
+	select {
+	case <-w.C():
+		t.Fatalf("expected the re-armed watchdog not to fire early")
+	default:
+	}
+	clock.Advance(time.Second)
+	<-w.C()
+	if !w.Expired(clock.Now()) {
+		t.Errorf("expected the watchdog to expire after a minute without data")
+	}
+}
+
+func TestListenTrade_WatchdogClosesStalledConnection(t *testing.T) {
+	srv := useMockServer(t)
+	// The mock sends one frame and then holds the connection open without sending anything else
+	srv.SetStream("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 1, "100.0", "1"))
+	old := StreamIdleTimeout
+	StreamIdleTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { StreamIdleTimeout = old })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	trades := make(chan Trade, 1)
+	err := ListenTrade(ctx, "BTCUSDT", trades)
+	if !errors.Is(err, ErrStreamStalled) {
+		t.Fatalf("expected ErrStreamStalled, got %v", err)
+	}
+	if len(trades) != 1 {
+		t.Errorf("expected the frame received before the stall to be delivered")
+	}
+	for _, stats := range WebSocketStats() {
+		if stats.Stream == "btcusdt@trade" && !strings.Contains(stats.LastError, ErrStreamStalled.Error()) {
+			t.Errorf("expected the stall to be recorded as the last disconnect error, got %q", stats.LastError)
+		}
+	}
+}
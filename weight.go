@@ -0,0 +1,185 @@
+package gobinapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRESTWeightLimit is the share of the exchange's 6000 per-minute request weight limit the recorder allows
+// itself by default, leaving headroom for other clients sharing the IP.
+const DefaultRESTWeightLimit = 4800
+
+// DepthWeight is a pure function returning the request weight of a depth snapshot with the given limit, following
+// the exchange's published table.
+func DepthWeight(limit int) int {
+	switch {
+	case limit <= 100:
+		return 5
+	case limit <= 500:
+		return 25
+	case limit <= 1000:
+		return 50
+	default:
+		return 250
+	}
+}
+
+// BanBackoff is how long a WeightTracker stops all requests after an HTTP 418 without a Retry-After header. The
+// exchange answers 418 once an IP keeps sending requests after 429s, and its bans start at two minutes.
+var BanBackoff = 2 * time.Minute
+
+// WeightTracker keeps track of the REST request weight used in the current one-minute window, so callers can pace
+// requests to stay within a budget instead of running into HTTP 429 bans. Usage is counted locally as weight is
+// reserved, and corrected upwards from the X-MBX-USED-WEIGHT-1M header the exchange returns, which also accounts
+// for requests made outside the tracker. Once the exchange does answer 429 or 418 (see ObserveResponse), no weight
+// is granted to anyone until the backoff it asked for has passed.
+type WeightTracker struct {
+	mu           sync.Mutex
+	limit        int
+	window       time.Time
+	used         int
+	blockedUntil time.Time
+	// queue holds the token of the caller whose turn it is in Wait
+	queue chan struct{}
+}
+
+// DefaultWeightTracker observes the weight reported on every REST response made by this package.
+var DefaultWeightTracker = NewWeightTracker(DefaultRESTWeightLimit)
+
+func init() {
+	DefaultMetrics.Describe("binance_rest_weight_used", "gauge", "REST request weight used in the current minute.")
+	DefaultMetrics.Describe("binance_rest_backoffs_total", "counter", "REST responses that stopped all requests for a while, per HTTP status.")
+}
+
+// NewWeightTracker creates a tracker allowing limit weight per minute.
+func NewWeightTracker(limit int) *WeightTracker {
+	return &WeightTracker{limit: limit, queue: make(chan struct{}, 1)}
+}
+
+// SetLimit changes the per-minute weight budget.
+func (t *WeightTracker) SetLimit(limit int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limit = limit
+}
+
+// Used returns the weight used in the minute containing now.
+func (t *WeightTracker) Used(now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.roll(now)
+	return t.used
+}
+
+// Reserve claims weight in the minute containing now if it fits in the budget and no backoff is in effect, and
+// returns zero. Otherwise nothing is claimed and Reserve returns how long to wait: until the backoff ends or the next
+// window starts.
+func (t *WeightTracker) Reserve(weight int, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Before(t.blockedUntil) {
+		return t.blockedUntil.Sub(now)
+	}
+	t.roll(now)
+	// A single request heavier than the whole budget is let through in an empty window rather than never
+	if t.used > 0 && t.used+weight > t.limit {
+		return t.window.Add(time.Minute).Sub(now)
+	}
+	t.used += weight
+	DefaultMetrics.Set("binance_rest_weight_used", nil, float64(t.used))
+	return 0
+}
+
+// Wait blocks until weight fits in the budget and no backoff is in effect, then claims it. Callers are served in
+// turn, first come first served, so a heavy request is not starved by a stream of light ones. It returns ctx's error
+// if ctx is cancelled first.
+func (t *WeightTracker) Wait(ctx context.Context, weight int) error {
+	select {
+	case t.queue <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-t.queue }()
+	for {
+		wait := t.Reserve(weight, NowFunc())
+		if wait == 0 {
+			return nil
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Observe updates the used weight from a response's X-MBX-USED-WEIGHT-1M header, or the older X-MBX-USED-WEIGHT,
+// if present.
+func (t *WeightTracker) Observe(header http.Header, now time.Time) {
+	value := header.Get("X-MBX-USED-WEIGHT-1M")
+	if value == "" {
+		value = header.Get("X-MBX-USED-WEIGHT")
+	}
+	used, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+	t.ObserveUsed(used, now)
+}
+
+// ObserveUsed updates the used weight with the weight the exchange reports used in the current minute, e.g. in the
+// rateLimits of a WebSocket API response.
+func (t *WeightTracker) ObserveUsed(used int, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.roll(now)
+	if used > t.used {
+		t.used = used
+		DefaultMetrics.Set("binance_rest_weight_used", nil, float64(t.used))
+	}
+}
+
+// ObserveResponse observes resp's headers like Observe and, if the exchange answered 429 (rate limited) or 418 (IP
+// banned), stops granting weight until the Retry-After delay it asked for has passed. Without a Retry-After header
+// a 429 backs off until the next window and a 418 for BanBackoff. It returns the backoff, or zero for other
+// responses.
+func (t *WeightTracker) ObserveResponse(resp *http.Response, now time.Time) time.Duration {
+	t.Observe(resp.Header, now)
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return t.BackOff(resp.StatusCode, retryAfter, now)
+}
+
+// BackOff stops granting weight after a response of status 429 or 418 until retryAfter has passed or, if it is
+// zero, as long as ObserveResponse describes. It returns the backoff, or zero for other statuses.
+func (t *WeightTracker) BackOff(status int, retryAfter time.Duration, now time.Time) time.Duration {
+	if status != http.StatusTooManyRequests && status != http.StatusTeapot {
+		return 0
+	}
+	wait := retryAfter
+	if wait <= 0 && status == http.StatusTeapot {
+		wait = BanBackoff
+	} else if wait <= 0 {
+		wait = now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+	}
+	wait = max(wait, time.Second)
+	t.mu.Lock()
+	if until := now.Add(wait); until.After(t.blockedUntil) {
+		t.blockedUntil = until
+	}
+	t.mu.Unlock()
+	DefaultMetrics.Add("binance_rest_backoffs_total", Labels{"status": strconv.Itoa(status)}, 1)
+	return wait
+}
+
+// roll starts a new window when now has moved past the current minute. It must be called with mu held.
+func (t *WeightTracker) roll(now time.Time) {
+	window := now.Truncate(time.Minute)
+	if !window.Equal(t.window) {
+		t.window = window
+		t.used = 0
+	}
+}
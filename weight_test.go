@@ -0,0 +1,137 @@
+package gobinapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDepthWeight(t *testing.T) {
+	cases := map[int]int{5: 5, 100: 5, 101: 25, 500: 25, 1000: 50, 5000: 250}
+	for limit, want := range cases {
+		if got := DepthWeight(limit); got != want {
+			t.Errorf("DepthWeight(%d) = %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestWeightTracker_ReservesWithinBudgetPerMinute(t *testing.T) {
+	tr := NewWeightTracker(100)
+	start := time.Date(2025, 2, 19, 12, 0, 10, 0, time.UTC)
+
+	if wait := tr.Reserve(50, start); wait != 0 {
+		t.Fatalf("expected the first reservation to fit, got wait %s", wait)
+	}
+	if wait := tr.Reserve(50, start); wait != 0 {
+		t.Fatalf("expected the second reservation to fit, got wait %s", wait)
+	}
+	if wait := tr.Reserve(1, start); wait != 50*time.Second {
+		t.Fatalf("expected to wait for the next minute, got %s", wait)
+	}
+	if used := tr.Used(start); used != 100 {
+		t.Errorf("expected 100 used, got %d", used)
+	}
+	next := start.Add(50 * time.Second)
+	if wait := tr.Reserve(1, next); wait != 0 {
+		t.Errorf("expected a new window to allow the reservation, got wait %s", wait)
+	}
+	if wait := NewWeightTracker(100).Reserve(250, start); wait != 0 {
+		t.Errorf("expected an oversized request to pass in an empty window, got wait %s", wait)
+	}
+}
+
+func TestWeightTracker_ObserveRaisesUsage(t *testing.T) {
+	tr := NewWeightTracker(100)
+	now := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	tr.Reserve(10, now)
+
+	h := http.Header{}
+	h.Set("X-MBX-USED-WEIGHT-1M", "90")
+	tr.Observe(h, now)
+	if used := tr.Used(now); used != 90 {
+		t.Fatalf("expected the exchange's usage to win, got %d", used)
+	}
+	h.Set("X-MBX-USED-WEIGHT-1M", "20")
+	tr.Observe(h, now)
+	if used := tr.Used(now); used != 90 {
+		t.Errorf("expected a stale lower report to be ignored, got %d", used)
+	}
+	tr.Observe(http.Header{}, now)
+	if wait := tr.Reserve(20, now); wait == 0 {
+		t.Errorf("expected the observed usage to limit reservations")
+	}
+}
+
+func TestWeightTracker_BacksOffAfterRateLimitAndBan(t *testing.T) {
+	tr := NewWeightTracker(100)
+	now := time.Date(2025, 2, 19, 12, 0, 10, 0, time.UTC)
+
+	limited := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	limited.Header.Set("Retry-After", "30")
+	if backoff := tr.ObserveResponse(limited, now); backoff != 30*time.Second {
+		t.Fatalf("expected the Retry-After backoff, got %s", backoff)
+	}
+	if wait := tr.Reserve(1, now.Add(10*time.Second)); wait != 20*time.Second {
+		t.Fatalf("expected every reservation to wait out the backoff, got %s", wait)
+	}
+	if wait := tr.Reserve(1, now.Add(30*time.Second)); wait != 0 {
+		t.Fatalf("expected reservations once the backoff has passed, got %s", wait)
+	}
+
+	banned := &http.Response{StatusCode: http.StatusTeapot, Header: http.Header{}}
+	if backoff := tr.ObserveResponse(banned, now); backoff != BanBackoff {
+		t.Fatalf("expected a ban without Retry-After to back off for BanBackoff, got %s", backoff)
+	}
+	if wait := tr.Reserve(1, now); wait != BanBackoff {
+		t.Errorf("expected the ban to hold up reservations, got %s", wait)
+	}
+	if backoff := tr.ObserveResponse(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, now); backoff != 0 {
+		t.Errorf("expected no backoff for a successful response, got %s", backoff)
+	}
+
+	h := http.Header{}
+	h.Set("X-MBX-USED-WEIGHT", "70")
+	tr.Observe(h, now.Add(3*time.Minute))
+	if used := tr.Used(now.Add(3 * time.Minute)); used != 70 {
+		t.Errorf("expected the X-MBX-USED-WEIGHT header to be observed, got %d", used)
+	}
+}
+
+func TestWeightTracker_WaitQueuesCallers(t *testing.T) {
+	start := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	clock := useFakeClock(t, start)
+	oldNow := NowFunc
+	NowFunc = clock.Now
+	t.Cleanup(func() { NowFunc = oldNow })
+
+	tr := NewWeightTracker(10)
+	if err := tr.Wait(context.Background(), 10); err != nil {
+		t.Fatal(err)
+	}
+	// The first waiter holds the queue until the next window; the second waits behind it
+	first, second := make(chan error, 1), make(chan error, 1)
+	go func() { first <- tr.Wait(context.Background(), 10) }()
+	waitForWaiters(t, clock, 1)
+	go func() { second <- tr.Wait(context.Background(), 10) }()
+	select {
+	case err := <-second:
+		t.Fatalf("expected the second caller to queue, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Minute)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	waitForWaiters(t, clock, 1)
+	clock.Advance(time.Minute)
+	if err := <-second; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := tr.Wait(ctx, 10); err == nil {
+		t.Error("expected a cancelled wait to fail")
+	}
+}
@@ -0,0 +1,73 @@
+package gobinapi
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// WorkerPool runs submitted tasks on a fixed number of goroutines, so work such as REST fetches for hundreds of
+// symbols uses a predictable amount of resources instead of a goroutine per task. Tasks wait in a bounded queue;
+// Submit blocks while the queue is full, which pushes back on the producer. Long-lived consumers such as the
+// Subscribe* loops are not tasks in this sense: they stay one goroutine per stream, a fixed number per symbol.
+type WorkerPool struct {
+	name  string
+	tasks chan func()
+	busy  atomic.Int64
+	wg    sync.WaitGroup
+}
+
+func init() {
+	DefaultMetrics.Describe("binance_worker_queue_length", "gauge", "Tasks waiting for a worker, per pool.")
+	DefaultMetrics.Describe("binance_worker_busy", "gauge", "Workers currently running a task, per pool.")
+}
+
+// NewWorkerPool starts workers goroutines serving a queue of up to queueSize tasks. The pool's queue length and busy
+// workers are exported as metrics labelled with name.
+func NewWorkerPool(name string, workers, queueSize int) *WorkerPool {
+	p := &WorkerPool{
+		name:  name,
+		tasks: make(chan func(), queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	DefaultMetrics.SetFunc("binance_worker_queue_length", Labels{"pool": name}, func() float64 {
+		return float64(p.QueueLength())
+	})
+	DefaultMetrics.SetFunc("binance_worker_busy", Labels{"pool": name}, func() float64 {
+		return float64(p.busy.Load())
+	})
+	return p
+}
+
+// Submit queues task, waiting for room in the queue until ctx is cancelled. It must not be called after Close.
+func (p *WorkerPool) Submit(ctx context.Context, task func()) error {
+	select {
+	case p.tasks <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// QueueLength returns the number of tasks waiting for a worker.
+func (p *WorkerPool) QueueLength() int {
+	return len(p.tasks)
+}
+
+// Close stops accepting tasks and waits for the queued ones to finish.
+func (p *WorkerPool) Close() {
+	close(p.tasks)
+	p.wg.Wait()
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+	for task := range p.tasks {
+		p.busy.Add(1)
+		task()
+		p.busy.Add(-1)
+	}
+}
@@ -0,0 +1,60 @@
+package gobinapi
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_BoundsConcurrencyAndReportsQueue(t *testing.T) {
+	p := NewWorkerPool("test_bounded", 2, 3)
+	release := make(chan struct{})
+	var running, maxRunning, finished atomic.Int64
+	task := func() {
+		n := running.Add(1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		<-release
+		running.Add(-1)
+		finished.Add(1)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if err := p.Submit(ctx, task); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for p.QueueLength() != 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	labels := Labels{"pool": "test_bounded"}
+	if got := DefaultMetrics.Value("binance_worker_queue_length", labels); got != 3 {
+		t.Errorf("expected 3 queued tasks, got %v", got)
+	}
+	if got := DefaultMetrics.Value("binance_worker_busy", labels); got != 2 {
+		t.Errorf("expected 2 busy workers, got %v", got)
+	}
+
+	// The queue is full, so a further submission waits until the context gives up
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := p.Submit(short, task); err == nil {
+		t.Errorf("expected Submit to block on a full queue")
+	}
+
+	close(release)
+	p.Close()
+	if finished.Load() != 5 {
+		t.Errorf("expected Close to wait for all 5 tasks, %d finished", finished.Load())
+	}
+	if maxRunning.Load() > 2 {
+		t.Errorf("expected at most 2 concurrent tasks, saw %d", maxRunning.Load())
+	}
+}
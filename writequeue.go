@@ -0,0 +1,120 @@
+package gobinapi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+func init() {
+	DefaultMetrics.Describe("binance_recorder_flushes_total", "counter", "Batches and row groups written to parquet files, per symbol and stream.")
+	DefaultMetrics.Describe("binance_recorder_flush_seconds_total", "counter", "Seconds spent writing batches and row groups to parquet files, per symbol and stream.")
+	DefaultMetrics.Describe("binance_recorder_last_flush_seconds", "gauge", "Seconds the latest batch or row group write took, per symbol and stream.")
+	DefaultMetrics.Describe("binance_recorder_write_queue", "gauge", "Records waiting for the recorder's writer goroutine, per symbol and stream.")
+}
+
+// queuedRecord is a record in a Recorder's write queue with the time it was written, which decides its file.
+type queuedRecord[T any] struct {
+	record T
+	at     time.Time
+}
+
+// SetWriteQueue makes Write hand records to a writer goroutine of the recorder through a queue of up to size records,
+// so that encoding and writing them, and a slow disk, hold up the caller only once the queue is full. A failed
+// write raises an alert as usual and is returned by the next Write. Flush, Rotate and Close first wait for the
+// records written before them. It must be called before the first Write; zero keeps writing on the caller's
+// goroutine.
+func (r *Recorder[T]) SetWriteQueue(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if size <= 0 || r.closed || r.queue != nil {
+		return
+	}
+	queue := make(chan queuedRecord[T], size)
+	r.queue = queue
+	r.queueDone = make(chan struct{})
+	r.queueCond = sync.NewCond(&r.queueMu)
+	DefaultMetrics.SetFunc("binance_recorder_write_queue", r.metricLabels(), func() float64 {
+		return float64(len(queue))
+	})
+	go r.writeQueued(queue, r.queueDone)
+}
+
+// enqueue queues record for the writer goroutine, returning a failed queued write not yet reported instead.
+func (r *Recorder[T]) enqueue(record T) error {
+	r.queueMu.Lock()
+	if err := r.queueErr; err != nil {
+		r.queueErr = nil
+		r.queueMu.Unlock()
+		return err
+	}
+	if r.queueClosed {
+		r.queueMu.Unlock()
+		return fmt.Errorf("%s recorder for %s is closed", r.dataType, r.instrument)
+	}
+	// Counted before sending, so closeQueue waits for this send rather than closing the channel under it
+	r.enqueued++
+	r.queueMu.Unlock()
+	r.queue <- queuedRecord[T]{record: record, at: NowFunc().UTC()}
+	return nil
+}
+
+// writeQueued writes the records of queue until it is closed, then closes done.
+func (r *Recorder[T]) writeQueued(queue <-chan queuedRecord[T], done chan<- struct{}) {
+	defer close(done)
+	for item := range queue {
+		err := r.writeAt(item.record, item.at)
+		r.queueMu.Lock()
+		r.written++
+		if err != nil && r.queueErr == nil {
+			r.queueErr = err
+		}
+		r.queueCond.Broadcast()
+		r.queueMu.Unlock()
+	}
+}
+
+// awaitQueue waits until the records queued so far have been written. It returns at once without a write queue.
+func (r *Recorder[T]) awaitQueue() {
+	if r.queue == nil {
+		return
+	}
+	r.queueMu.Lock()
+	defer r.queueMu.Unlock()
+	for target := r.enqueued; r.written < target; {
+		r.queueCond.Wait()
+	}
+}
+
+// closeQueue refuses further records, waits for the queued ones to be written and stops the writer goroutine.
+func (r *Recorder[T]) closeQueue() {
+	if r.queue == nil {
+		return
+	}
+	r.queueMu.Lock()
+	if r.queueClosed {
+		r.queueMu.Unlock()
+		return
+	}
+	r.queueClosed = true
+	for r.written < r.enqueued {
+		r.queueCond.Wait()
+	}
+	r.queueMu.Unlock()
+	close(r.queue)
+	<-r.queueDone
+}
+
+// observeFlush records a write to the parquet file that started at start in the flush metrics.
+func (r *Recorder[T]) observeFlush(start time.Time) {
+	seconds := time.Since(start).Seconds()
+	labels := r.metricLabels()
+	DefaultMetrics.Add("binance_recorder_flushes_total", labels, 1)
+	DefaultMetrics.Add("binance_recorder_flush_seconds_total", labels, seconds)
+	DefaultMetrics.Set("binance_recorder_last_flush_seconds", labels, seconds)
+}
+
+// metricLabels returns the labels of the recorder's metrics, named like those of the stream channels feeding it.
+func (r *Recorder[T]) metricLabels() Labels {
+	return Labels{"symbol": r.instrument, "stream": r.dataType}
+}
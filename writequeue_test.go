@@ -0,0 +1,79 @@
+package gobinapi
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRecorder_WriteQueueWritesInOrder writes through a queue much shorter than the records written and checks that
+// Close writes every one of them, in order, and that the flushes were measured.
+func TestRecorder_WriteQueueWritesInOrder(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	r, err := NewRecorder[Trade]("QUEUEUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	r.SetWriteQueue(4)
+	labels := Labels{"symbol": "QUEUEUSDT", "stream": "trade"}
+	flushes := DefaultMetrics.Value("binance_recorder_flushes_total", labels)
+
+	const total = 100
+	for id := int64(1); id <= total; id++ {
+		if err := r.Write(Trade{TradeID: id}); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+	if err := r.Write(Trade{TradeID: total + 1}); err == nil {
+		t.Error("expected writing to a closed recorder to fail")
+	}
+
+	trades, err := ReadParquetFile[Trade](r.filePath)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", r.filePath, err)
+	}
+	if len(trades) != total {
+		t.Fatalf("expected %d trades, got %d", total, len(trades))
+	}
+	for i, trade := range trades {
+		if trade.TradeID != int64(i+1) {
+			t.Fatalf("expected trade %d at row %d, got %d", i+1, i, trade.TradeID)
+		}
+	}
+	if got := DefaultMetrics.Value("binance_recorder_flushes_total", labels) - flushes; got != total/10 {
+		t.Errorf("expected %d flushes, got %v", total/10, got)
+	}
+	if got := DefaultMetrics.Value("binance_recorder_write_queue", labels); got != 0 {
+		t.Errorf("expected an empty write queue, got %v", got)
+	}
+}
+
+// TestRecorder_FlushWaitsForQueuedRecords checks that Flush puts the records queued before it on disk.
+func TestRecorder_FlushWaitsForQueuedRecords(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC))
+	t.Chdir(t.TempDir())
+	r, err := NewRecorder[Trade]("QUEUEUSDT", "trade", 10)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	defer r.Close()
+	r.SetWriteQueue(16)
+
+	for id := int64(1); id <= 3; id++ {
+		if err := r.Write(Trade{TradeID: id}); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	if rows := r.Stats().Rows; rows != 3 {
+		t.Errorf("expected 3 rows written before Flush returned, got %d", rows)
+	}
+	if r.pw.Offset <= 4 {
+		t.Errorf("expected the flushed row group on disk, got %d bytes", r.pw.Offset)
+	}
+}
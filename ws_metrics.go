@@ -0,0 +1,195 @@
+package gobinapi
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ConnStats holds lifecycle metrics for one WebSocket stream, so flapping connections are visible at a glance.
+type ConnStats struct {
+	Stream        string
+	Attempts      int64
+	Connects      int64
+	Reconnects    int64
+	LastConnect   time.Time
+	LastClose     time.Time
+	LastCloseCode int
+	LastError     string
+	// Endpoint is the base URL (scheme, host and port) that served the stream's latest connection.
+	Endpoint string
+	// Address is the IP address and port the latest connection was made to.
+	Address string
+	// SessionID is the WSSession ID of the latest connection.
+	SessionID string
+}
+
+// SinceLastConnect returns how long ago the stream last connected successfully, or zero if it never has.
+func (s ConnStats) SinceLastConnect(now time.Time) time.Duration {
+	if s.LastConnect.IsZero() {
+		return 0
+	}
+	return now.Sub(s.LastConnect)
+}
+
+var wsStats = struct {
+	mu      sync.Mutex
+	streams map[string]*ConnStats
+	// connections logs the latest connections for the session file; logged counts every one ever logged
+	connections []SessionConnection
+	logged      int
+}{streams: make(map[string]*ConnStats)}
+
+func init() {
+	DefaultMetrics.Describe("binance_ws_connect_attempts_total", "counter", "WebSocket dial attempts per stream.")
+	DefaultMetrics.Describe("binance_ws_connects_total", "counter", "Successful WebSocket connects per stream.")
+	DefaultMetrics.Describe("binance_ws_reconnects_total", "counter", "Successful WebSocket connects after the first, per stream.")
+	DefaultMetrics.Describe("binance_ws_seconds_since_connect", "gauge", "Seconds since the stream last connected successfully.")
+	DefaultMetrics.Describe("binance_ws_last_close_code", "gauge", "WebSocket close code of the stream's last disconnect (1006 for abnormal closures).")
+	DefaultMetrics.Describe("binance_ws_endpoint_connects_total", "counter", "Successful WebSocket connects per stream and endpoint.")
+}
+
+// streamNameFromURL extracts the stream name (e.g. "btcusdt@trade") from a WebSocket URL.
+func streamNameFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Path == "" {
+		return rawURL
+	}
+	if streams := u.Query().Get("streams"); streams != "" {
+		return streams
+	}
+	return path.Base(u.Path)
+}
+
+// endpointFromURL returns the scheme, host and port of a WebSocket URL.
+func endpointFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// connStatsFor returns the stats entry for a stream, creating and registering it on first use.
+// It must be called with wsStats.mu held.
+func connStatsFor(stream string) *ConnStats {
+	s, ok := wsStats.streams[stream]
+	if !ok {
+		s = &ConnStats{Stream: stream}
+		wsStats.streams[stream] = s
+		DefaultMetrics.SetFunc("binance_ws_seconds_since_connect", Labels{"stream": stream}, func() float64 {
+			wsStats.mu.Lock()
+			defer wsStats.mu.Unlock()
+			return s.SinceLastConnect(NowFunc()).Seconds()
+		})
+	}
+	return s
+}
+
+func recordConnectAttempt(stream string) {
+	wsStats.mu.Lock()
+	defer wsStats.mu.Unlock()
+	connStatsFor(stream).Attempts++
+	DefaultMetrics.Add("binance_ws_connect_attempts_total", Labels{"stream": stream}, 1)
+}
+
+// recordConnect records a successful connect and returns the stream's WSSession for it.
+// Reconnects are also reported to DefaultAlerts.
+func recordConnect(stream string) WSSession {
+	session := WSSession{ID: NewConnID()}
+	wsStats.mu.Lock()
+	defer wsStats.mu.Unlock()
+	s := connStatsFor(stream)
+	s.Connects++
+	s.SessionID = session.ID
+	session.Generation = s.Connects
+	s.LastConnect = NowFunc()
+	DefaultMetrics.Add("binance_ws_connects_total", Labels{"stream": stream}, 1)
+	if s.Connects > 1 {
+		s.Reconnects++
+		DefaultMetrics.Add("binance_ws_reconnects_total", Labels{"stream": stream}, 1)
+		DefaultAlerts.noteReconnect(stream)
+	}
+	return session
+}
+
+// recordEndpoint records which endpoint and address served a stream's latest connection, session, and logs the
+// connection for the session file.
+func recordEndpoint(stream, endpoint, address string, session WSSession) {
+	wsStats.mu.Lock()
+	defer wsStats.mu.Unlock()
+	s := connStatsFor(stream)
+	s.Endpoint = endpoint
+	s.Address = address
+	DefaultMetrics.Add("binance_ws_endpoint_connects_total", Labels{"stream": stream, "endpoint": endpoint}, 1)
+
+	wsStats.connections = append(wsStats.connections, SessionConnection{ConnID: session.ID, Generation: session.Generation,
+		Stream: stream, Endpoint: endpoint, Address: address, ConnectTime: NowFunc().UTC()})
+	wsStats.logged++
+	if over := len(wsStats.connections) - MaxSessionConnections; over > 0 {
+		wsStats.connections = append(wsStats.connections[:0:0], wsStats.connections[over:]...)
+	}
+}
+
+// MaxSessionConnections is how many connections are kept for the session file; older ones are dropped first.
+var MaxSessionConnections = 10000
+
+// connectionsLogged returns how many connections this process has logged, to pass to connectionsSince later.
+func connectionsLogged() int {
+	wsStats.mu.Lock()
+	defer wsStats.mu.Unlock()
+	return wsStats.logged
+}
+
+// connectionsSince returns the connections logged after the first n that are still kept, oldest first.
+func connectionsSince(n int) []SessionConnection {
+	wsStats.mu.Lock()
+	defer wsStats.mu.Unlock()
+	first := n - (wsStats.logged - len(wsStats.connections))
+	return append([]SessionConnection(nil), wsStats.connections[max(first, 0):]...)
+}
+
+// recordDisconnect records why a stream's connection ended. A nil error means the recorder closed the connection
+// itself (e.g. on shutdown), which is reported as a normal closure.
+func recordDisconnect(stream string, err error) {
+	code := CloseCodeForError(err)
+	wsStats.mu.Lock()
+	defer wsStats.mu.Unlock()
+	s := connStatsFor(stream)
+	s.LastClose = NowFunc()
+	s.LastCloseCode = code
+	if err != nil {
+		s.LastError = err.Error()
+	}
+	DefaultMetrics.Set("binance_ws_last_close_code", Labels{"stream": stream}, float64(code))
+}
+
+// CloseCodeForError maps a read error to a WebSocket close code: the server's code for close frames, 1000 for a
+// locally initiated close and 1006 (abnormal closure) for anything else, such as a dropped TCP connection.
+func CloseCodeForError(err error) int {
+	if err == nil {
+		return websocket.CloseNormalClosure
+	}
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+	return websocket.CloseAbnormalClosure
+}
+
+// WebSocketStats returns a snapshot of the lifecycle metrics of every stream seen so far, sorted by stream name.
+func WebSocketStats() []ConnStats {
+	wsStats.mu.Lock()
+	defer wsStats.mu.Unlock()
+	stats := make([]ConnStats, 0, len(wsStats.streams))
+	for _, s := range wsStats.streams {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Stream < stats[j].Stream })
+	return stats
+}
@@ -0,0 +1,96 @@
+package gobinapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestStreamNameFromURL(t *testing.T) {
+	cases := map[string]string{
+		"wss://data-stream.binance.vision:9443/ws/btcusdt@trade":                   "btcusdt@trade",
+		"wss://stream.binance.com:9443/stream?streams=btcusdt@trade/btcusdt@depth": "btcusdt@trade/btcusdt@depth",
+	}
+	for url, expected := range cases {
+		if actual := streamNameFromURL(url); actual != expected {
+			t.Errorf("streamNameFromURL(%q) = %q, want %q", url, actual, expected)
+		}
+	}
+}
+
+func TestCloseCodeForError(t *testing.T) {
+	if code := CloseCodeForError(nil); code != websocket.CloseNormalClosure {
+		t.Errorf("expected normal closure for nil error, got %d", code)
+	}
+	if code := CloseCodeForError(&websocket.CloseError{Code: websocket.CloseGoingAway}); code != websocket.CloseGoingAway {
+		t.Errorf("expected server close code, got %d", code)
+	}
+	if code := CloseCodeForError(errors.New("connection reset by peer")); code != websocket.CloseAbnormalClosure {
+		t.Errorf("expected abnormal closure, got %d", code)
+	}
+}
+
+func TestConnStats_TracksReconnects(t *testing.T) {
+	oldNowFunc := NowFunc
+	defer func() { NowFunc = oldNowFunc }()
+	now := time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+	NowFunc = func() time.Time { return now }
+
+	stream := "testmetrics@trade"
+	recordConnectAttempt(stream)
+	recordConnect(stream)
+	recordDisconnect(stream, &websocket.CloseError{Code: websocket.CloseGoingAway})
+	recordConnectAttempt(stream)
+	recordDisconnect(stream, errors.New("dial tcp: i/o timeout"))
+	recordConnectAttempt(stream)
+	now = now.Add(time.Minute)
+	recordConnect(stream)
+	now = now.Add(30 * time.Second)
+
+	var stats *ConnStats
+	for _, s := range WebSocketStats() {
+		if s.Stream == stream {
+			s := s
+			stats = &s
+		}
+	}
+	if stats == nil {
+		t.Fatalf("no stats recorded for %s", stream)
+	}
+	if stats.Attempts != 3 || stats.Connects != 2 || stats.Reconnects != 1 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if stats.LastCloseCode != websocket.CloseAbnormalClosure || stats.LastError != "dial tcp: i/o timeout" {
+		t.Errorf("unexpected last close: %+v", stats)
+	}
+	if since := stats.SinceLastConnect(now); since != 30*time.Second {
+		t.Errorf("expected 30s since last connect, got %s", since)
+	}
+	if v := DefaultMetrics.Value("binance_ws_reconnects_total", Labels{"stream": stream}); v != 1 {
+		t.Errorf("expected reconnect counter 1, got %v", v)
+	}
+	if v := DefaultMetrics.Value("binance_ws_seconds_since_connect", Labels{"stream": stream}); v != 30 {
+		t.Errorf("expected 30 seconds since connect, got %v", v)
+	}
+}
+
+func TestConnectionsSince_ListsTheRunsConnections(t *testing.T) {
+	oldMax := MaxSessionConnections
+	MaxSessionConnections = 2
+	defer func() { MaxSessionConnections = oldMax }()
+
+	recordEndpoint("before@trade", "wss://a", "1.2.3.4:443", WSSession{ID: "c0", Generation: 1})
+	first := connectionsLogged()
+	recordEndpoint("testlog@trade", "wss://a", "1.2.3.4:443", WSSession{ID: "c1", Generation: 1})
+	recordEndpoint("testlog@trade", "wss://b", "5.6.7.8:443", WSSession{ID: "c2", Generation: 2})
+	got := connectionsSince(first)
+	if len(got) != 2 || got[0].ConnID != "c1" || got[1].Endpoint != "wss://b" || got[1].Generation != 2 {
+		t.Fatalf("expected the two connections after the mark, got %+v", got)
+	}
+	recordEndpoint("testlog@trade", "wss://a", "1.2.3.4:443", WSSession{ID: "c3", Generation: 3})
+	if got := connectionsSince(first); len(got) != 2 || got[0].ConnID != "c2" || got[1].ConnID != "c3" {
+		t.Errorf("expected only the latest connections to be kept, got %+v", got)
+	}
+}
@@ -0,0 +1,407 @@
+package gobinapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WSAPIBaseURL and FuturesWSAPIBaseURL are the WebSocket API endpoints of the spot and USD-M futures markets, which
+// DialWSAPI connects to. The testnets serve it on "wss://ws-api.testnet.binance.vision/ws-api/v3" and
+// "wss://testnet.binancefuture.com/ws-fapi/v1". Tests point them at a local server.
+var (
+	WSAPIBaseURL        = "wss://ws-api.binance.com:443/ws-api/v3"
+	FuturesWSAPIBaseURL = "wss://ws-fapi.binance.com/ws-fapi/v1"
+)
+
+// ErrWSAPIClosed is returned for requests on a WebSocket API connection that has been closed or broke before they
+// were answered.
+var ErrWSAPIClosed = errors.New("websocket API connection closed")
+
+// Request weights of the WebSocket API methods that differ from their REST counterparts.
+const (
+	wsAPIPingWeight           = 1
+	wsAPIAccountWeight        = 20
+	futuresWSAPIAccountWeight = 5
+)
+
+// wsAPIRequest is a request of the WebSocket API, e.g. {"id":1,"method":"order.place","params":{...}}.
+type wsAPIRequest struct {
+	ID     int64                  `json:"id"`
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// wsAPIResponse is the response to a request, carrying either a result or an error.
+type wsAPIResponse struct {
+	ID     int64           `json:"id"`
+	Status int             `json:"status"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		// Data of 429 and 418 errors holds the time the limit or ban ends, in milliseconds
+		Data struct {
+			RetryAfter int64 `json:"retryAfter"`
+		} `json:"data"`
+	} `json:"error"`
+	RateLimits []struct {
+		RateLimitType string `json:"rateLimitType"`
+		Interval      string `json:"interval"`
+		IntervalNum   int    `json:"intervalNum"`
+		Count         int    `json:"count"`
+	} `json:"rateLimits"`
+}
+
+// WSAPIClient sends requests over one persistent connection to the WebSocket API, matching the responses to them
+// by request ID, so orders, book snapshots and account queries avoid the TLS handshake and connection setup of
+// separate REST requests. Signed requests are signed like SignedClient's, with the same clock correction and
+// weight budget; the exchange counts the WebSocket API's request weight together with the REST API's.
+type WSAPIClient struct {
+	conn    *websocket.Conn
+	market  string
+	creds   Credentials
+	tracker *WeightTracker
+	// offset is the exchange's clock minus the local clock, in milliseconds
+	offset     atomic.Int64
+	recvWindow int64
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]chan wsAPIResponse
+	err     error
+	done    chan struct{}
+}
+
+// DialWSAPI connects to the WebSocket API of market (MarketSpot or MarketUSDM) through the proxy DialProxy picks, if
+// any. creds may be empty for public methods only. The connection stays open until Close or until it breaks, after
+// which every request fails with ErrWSAPIClosed and a new client must be dialed.
+func DialWSAPI(ctx context.Context, market string, creds Credentials) (*WSAPIClient, error) {
+	endpoint := WSAPIBaseURL
+	if market == MarketUSDM {
+		endpoint = FuturesWSAPIBaseURL
+	}
+	conn, _, err := streamDialer.DialContext(ctx, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial websocket API %s: %w", endpoint, err)
+	}
+	c := &WSAPIClient{conn: conn, market: market, creds: creds, tracker: DefaultWeightTracker,
+		recvWindow: DefaultRecvWindow.Milliseconds(), pending: make(map[int64]chan wsAPIResponse), done: make(chan struct{})}
+	go c.read()
+	return c, nil
+}
+
+// Close closes the connection, failing the requests still waiting for a response.
+func (c *WSAPIClient) Close() error {
+	return c.conn.Close()
+}
+
+// read delivers responses to the requests waiting for them until the connection breaks.
+func (c *WSAPIClient) read() {
+	var err error
+	for {
+		var msg []byte
+		if _, msg, err = c.conn.ReadMessage(); err != nil {
+			break
+		}
+		var resp wsAPIResponse
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			log.Printf("websocket API: failed to unmarshal response: %v, raw message: %s", err, msg)
+			continue
+		}
+		c.observeRateLimits(resp)
+		c.mu.Lock()
+		ch, ok := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+	c.mu.Lock()
+	c.err = fmt.Errorf("%w: %v", ErrWSAPIClosed, err)
+	close(c.done)
+	c.mu.Unlock()
+}
+
+// observeRateLimits updates the weight tracker with the weight the exchange reports used in the current minute.
+func (c *WSAPIClient) observeRateLimits(resp wsAPIResponse) {
+	for _, limit := range resp.RateLimits {
+		if limit.RateLimitType == "REQUEST_WEIGHT" && limit.Interval == "MINUTE" && limit.IntervalNum == 1 {
+			c.tracker.ObserveUsed(limit.Count, NowFunc())
+		}
+	}
+}
+
+// Call sends a request of method with params, secured as security, after waiting for weight in the tracker's
+// queue, and decodes its result into out unless out is nil. Signed requests get the apiKey, timestamp, recvWindow
+// and signature parameters added; a rejected timestamp is retried once after SyncTime. Error responses are
+// returned as *APIError; after a 429 or 418 the tracker holds up every request until the time the exchange names,
+// and the error carries the backoff, wrapping ErrIPBanned too for a 418. Requests are not retried after either.
+func (c *WSAPIClient) Call(ctx context.Context, method string, params map[string]interface{}, security Security, weight int, out interface{}) error {
+	if security != SecurityNone && c.creds.APIKey == "" {
+		return fmt.Errorf("%s: an API key is required", method)
+	}
+	if security == SecuritySigned && !c.creds.CanSign() {
+		return fmt.Errorf("%s: a secret or private key is required", method)
+	}
+	synced := false
+	for {
+		resp, err := c.roundTrip(ctx, method, params, security, weight)
+		if err != nil {
+			return err
+		}
+		if resp.Error != nil {
+			apiErr := &APIError{Status: resp.Status, Code: resp.Error.Code, Msg: resp.Error.Msg}
+			if resp.Status == http.StatusTooManyRequests || resp.Status == http.StatusTeapot {
+				now := NowFunc()
+				var retryAfter time.Duration
+				if resp.Error.Data.RetryAfter > 0 {
+					retryAfter = time.UnixMilli(resp.Error.Data.RetryAfter).Sub(now)
+				}
+				apiErr.Backoff = c.tracker.BackOff(resp.Status, retryAfter, now)
+			}
+			if apiErr.Code == errCodeTimestamp && security == SecuritySigned && !synced {
+				synced = true
+				if err := c.SyncTime(ctx); err != nil {
+					return err
+				}
+				continue
+			}
+			if resp.Status == http.StatusTeapot {
+				return fmt.Errorf("%s: %w: %w", method, ErrIPBanned, apiErr)
+			}
+			return fmt.Errorf("%s: %w", method, apiErr)
+		}
+		if out == nil {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, out); err != nil {
+			return fmt.Errorf("failed to parse %s result: %w", method, err)
+		}
+		return nil
+	}
+}
+
+// roundTrip sends one attempt of a request and waits for its response.
+func (c *WSAPIClient) roundTrip(ctx context.Context, method string, params map[string]interface{}, security Security, weight int) (wsAPIResponse, error) {
+	if err := c.tracker.Wait(ctx, weight); err != nil {
+		return wsAPIResponse{}, err
+	}
+	req := wsAPIRequest{ID: c.nextID.Add(1), Method: method, Params: c.secure(params, security)}
+	ch := make(chan wsAPIResponse, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return wsAPIResponse{}, c.err
+	}
+	c.pending[req.ID] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, req.ID)
+		c.mu.Unlock()
+	}()
+
+	c.writeMu.Lock()
+	err := c.conn.WriteJSON(req)
+	c.writeMu.Unlock()
+	if err != nil {
+		return wsAPIResponse{}, fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-c.done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return wsAPIResponse{}, c.err
+	case <-ctx.Done():
+		return wsAPIResponse{}, ctx.Err()
+	}
+}
+
+// secure returns params with the authentication parameters security requires added, leaving params unchanged.
+func (c *WSAPIClient) secure(params map[string]interface{}, security Security) map[string]interface{} {
+	if security == SecurityNone {
+		return params
+	}
+	out := make(map[string]interface{}, len(params)+4)
+	for k, v := range params {
+		out[k] = v
+	}
+	out["apiKey"] = c.creds.APIKey
+	if security == SecuritySigned {
+		out["timestamp"] = NowFunc().UnixMilli() + c.offset.Load()
+		out["recvWindow"] = c.recvWindow
+		out["signature"] = c.creds.Sign(wsAPIPayload(out))
+	}
+	return out
+}
+
+// wsAPIPayload returns the string a request's params are signed as: every parameter as key=value, sorted by key and
+// joined by "&".
+func wsAPIPayload(params map[string]interface{}) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", k, params[k])
+	}
+	return strings.Join(pairs, "&")
+}
+
+// wsAPIParams converts the query parameters of a REST request into WebSocket API params, with IDs as numbers.
+func wsAPIParams(q url.Values) map[string]interface{} {
+	params := make(map[string]interface{}, len(q))
+	for k := range q {
+		value := q.Get(k)
+		if k == "orderId" {
+			if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+				params[k] = id
+				continue
+			}
+		}
+		params[k] = value
+	}
+	return params
+}
+
+// TimeOffset returns the offset of the exchange's clock from the local one measured by the last SyncTime.
+func (c *WSAPIClient) TimeOffset() time.Duration {
+	return time.Duration(c.offset.Load()) * time.Millisecond
+}
+
+// SyncTime measures the offset of the exchange's clock from the local one like SignedClient.SyncTime.
+func (c *WSAPIClient) SyncTime(ctx context.Context) error {
+	start := NowFunc()
+	var resp struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := c.Call(ctx, "time", nil, SecurityNone, serverTimeWeight, &resp); err != nil {
+		return fmt.Errorf("failed to sync time: %w", err)
+	}
+	end := NowFunc()
+	midpoint := start.Add(end.Sub(start) / 2)
+	c.offset.Store(resp.ServerTime - midpoint.UnixMilli())
+	return nil
+}
+
+// Ping checks the connection with a request the exchange answers with an empty result.
+func (c *WSAPIClient) Ping(ctx context.Context) error {
+	return c.Call(ctx, "ping", nil, SecurityNone, wsAPIPingWeight, nil)
+}
+
+// Depth returns an order book snapshot of symbol with up to limit levels per side, as the REST depth endpoint does.
+func (c *WSAPIClient) Depth(ctx context.Context, symbol string, limit int) (*OrderBookSnapshot, error) {
+	weight := DepthWeight(limit)
+	if c.market == MarketUSDM {
+		weight = FuturesDepthWeight(limit)
+	}
+	var result json.RawMessage
+	if err := c.Call(ctx, "depth", map[string]interface{}{"symbol": symbol, "limit": limit}, SecurityNone, weight, &result); err != nil {
+		return nil, err
+	}
+	snapshot, err := parseOrderBookSnapshot(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse depth result: %w", err)
+	}
+	return snapshot, nil
+}
+
+// PlaceOrder places an order like BinanceRESTClient.NewOrder.
+func (c *WSAPIClient) PlaceOrder(ctx context.Context, req NewOrderRequest) (Order, error) {
+	q, err := req.params(c.market)
+	if err != nil {
+		return Order{}, err
+	}
+	weight := newOrderWeight
+	if c.market == MarketUSDM {
+		weight = futuresNewOrderWeight
+	}
+	var order Order
+	err = c.Call(ctx, "order.place", wsAPIParams(q), SecuritySigned, weight, &order)
+	return order, err
+}
+
+// CancelOrder cancels an open order like BinanceRESTClient.CancelOrder.
+func (c *WSAPIClient) CancelOrder(ctx context.Context, ref OrderRef) (Order, error) {
+	weight := cancelOrderWeight
+	if c.market == MarketUSDM {
+		weight = futuresCancelOrderWeight
+	}
+	return c.orderCall(ctx, "order.cancel", weight, ref)
+}
+
+// QueryOrder returns the current state of an order like BinanceRESTClient.QueryOrder.
+func (c *WSAPIClient) QueryOrder(ctx context.Context, ref OrderRef) (Order, error) {
+	weight := queryOrderWeight
+	if c.market == MarketUSDM {
+		weight = futuresQueryOrderWeight
+	}
+	return c.orderCall(ctx, "order.status", weight, ref)
+}
+
+// orderCall sends a request about the order ref and decodes the order it returns.
+func (c *WSAPIClient) orderCall(ctx context.Context, method string, weight int, ref OrderRef) (Order, error) {
+	q, err := ref.params()
+	if err != nil {
+		return Order{}, err
+	}
+	var order Order
+	err = c.Call(ctx, method, wsAPIParams(q), SecuritySigned, weight, &order)
+	return order, err
+}
+
+// OpenOrders returns the open orders of symbol, or of every symbol if symbol is empty, like
+// BinanceRESTClient.OpenOrders.
+func (c *WSAPIClient) OpenOrders(ctx context.Context, symbol string) ([]Order, error) {
+	weight := openOrdersWeight
+	if c.market == MarketUSDM {
+		weight = futuresOpenOrdersWeight
+	}
+	params := map[string]interface{}{}
+	if symbol != "" {
+		params["symbol"] = symbol
+	} else if c.market == MarketUSDM {
+		weight = futuresAllOpenOrdersWeight
+	} else {
+		weight = allOpenOrdersWeight
+	}
+	var orders []Order
+	if err := c.Call(ctx, "openOrders.status", params, SecuritySigned, weight, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// Account returns the account's balances, and on USD-M futures its positions, as the exchange's account.status
+// method lays them out for the market.
+func (c *WSAPIClient) Account(ctx context.Context) (json.RawMessage, error) {
+	method, weight := "account.status", wsAPIAccountWeight
+	if c.market == MarketUSDM {
+		weight = futuresWSAPIAccountWeight
+	}
+	var account json.RawMessage
+	if err := c.Call(ctx, method, nil, SecuritySigned, weight, &account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
@@ -0,0 +1,177 @@
+package gobinapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// useWSAPIServer starts a WebSocket API stand-in answering every request with handle's result, or error if it
+// returns one, and points DialWSAPI at it. An error's Backoff is sent as the time the limit ends.
+func useWSAPIServer(t *testing.T, handle func(method string, params map[string]interface{}) (interface{}, *APIError)) {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req struct {
+				ID     int64                  `json:"id"`
+				Method string                 `json:"method"`
+				Params map[string]interface{} `json:"params"`
+			}
+			// Numbers are kept as sent, so the signature payload can be rebuilt exactly
+			dec := json.NewDecoder(bytes.NewReader(msg))
+			dec.UseNumber()
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			if req.Method == "close" {
+				return
+			}
+			resp := map[string]interface{}{"id": req.ID, "status": 200,
+				"rateLimits": []map[string]interface{}{{"rateLimitType": "REQUEST_WEIGHT", "interval": "MINUTE", "intervalNum": 1, "limit": 6000, "count": 70}}}
+			result, apiErr := handle(req.Method, req.Params)
+			if apiErr != nil {
+				errBody := map[string]interface{}{"code": apiErr.Code, "msg": apiErr.Msg}
+				if apiErr.Backoff > 0 {
+					errBody["data"] = map[string]interface{}{"retryAfter": time.Now().Add(apiErr.Backoff).UnixMilli()}
+				}
+				resp["status"], resp["error"] = apiErr.Status, errBody
+			} else {
+				resp["result"] = result
+			}
+			if err := conn.WriteJSON(resp); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	old, oldTracker := WSAPIBaseURL, DefaultWeightTracker
+	WSAPIBaseURL, DefaultWeightTracker = "ws"+strings.TrimPrefix(srv.URL, "http"), NewWeightTracker(DefaultRESTWeightLimit)
+	t.Cleanup(func() { WSAPIBaseURL, DefaultWeightTracker = old, oldTracker })
+}
+
+func TestWSAPIClient_PlacesOrdersAndFetchesDepth(t *testing.T) {
+	creds := Credentials{APIKey: "key", Secret: "secret"}
+	useWSAPIServer(t, func(method string, params map[string]interface{}) (interface{}, *APIError) {
+		switch method {
+		case "depth":
+			return map[string]interface{}{"lastUpdateId": 1027024, "bids": [][]string{{"95000.00", "0.5"}}, "asks": [][]string{{"95000.01", "1.2"}}}, nil
+		case "order.place":
+			signature := params["signature"]
+			delete(params, "signature")
+			if params["apiKey"] != "key" || signature != creds.Sign(wsAPIPayload(params)) {
+				return nil, &APIError{Status: 400, Code: -1022, Msg: "Signature for this request is not valid."}
+			}
+			return map[string]interface{}{"symbol": params["symbol"], "orderId": 12569099453, "clientOrderId": params["newClientOrderId"],
+				"transactTime": 1739966400000, "price": params["price"], "origQty": params["quantity"], "executedQty": "0.00000000",
+				"cummulativeQuoteQty": "0.00000000", "status": "NEW", "timeInForce": "GTC", "type": "LIMIT", "side": "BUY"}, nil
+		}
+		return nil, &APIError{Status: 400, Code: -2011, Msg: "Unknown order sent."}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := DialWSAPI(ctx, MarketSpot, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	snapshot, err := c.Depth(ctx, "BTCUSDT", 5)
+	if err != nil {
+		t.Fatalf("Depth failed: %v", err)
+	}
+	if snapshot.LastUpdateID != 1027024 || len(snapshot.Bids) != 1 || snapshot.Asks[0].Price != "95000.01" {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+	order, err := c.PlaceOrder(ctx, NewOrderRequest{Symbol: "BTCUSDT", Side: SideBuy, Type: OrderTypeLimit, TimeInForce: TimeInForceGTC,
+		Quantity: "0.01", Price: "95000.00", NewClientOrderID: "ws-1"})
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	if order.OrderID != 12569099453 || order.ClientOrderID != "ws-1" || order.Time != 1739966400000 {
+		t.Errorf("unexpected order %+v", order)
+	}
+	_, err = c.CancelOrder(ctx, OrderRef{Symbol: "BTCUSDT", OrderID: 1})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -2011 {
+		t.Errorf("expected the exchange's error as an APIError, got %v", err)
+	}
+	if used := DefaultWeightTracker.Used(time.Now()); used < 70 {
+		t.Errorf("expected at least the used weight the exchange reports, got %d", used)
+	}
+}
+
+func TestWSAPIClient_FailsRequestsWhenTheConnectionBreaks(t *testing.T) {
+	useWSAPIServer(t, func(method string, params map[string]interface{}) (interface{}, *APIError) {
+		return struct{}{}, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := DialWSAPI(ctx, MarketSpot, Credentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if err := c.Call(ctx, "close", nil, SecurityNone, 1, nil); !errors.Is(err, ErrWSAPIClosed) {
+		t.Errorf("expected a request unanswered when the connection broke to fail, got %v", err)
+	}
+	if err := c.Ping(ctx); !errors.Is(err, ErrWSAPIClosed) {
+		t.Errorf("expected requests on a broken connection to fail, got %v", err)
+	}
+	if _, err := c.Account(ctx); err == nil {
+		t.Error("expected a signed request without credentials to be refused")
+	}
+}
+
+func TestWSAPIClient_RateLimitsHoldUpRequests(t *testing.T) {
+	useWSAPIServer(t, func(method string, params map[string]interface{}) (interface{}, *APIError) {
+		if method == "order.cancel" {
+			return nil, &APIError{Status: 418, Code: -1003, Msg: "Way too many requests; IP banned.", Backoff: 2 * time.Minute}
+		}
+		return nil, &APIError{Status: 429, Code: -1003, Msg: "Too many requests.", Backoff: 30 * time.Second}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := DialWSAPI(ctx, MarketSpot, Credentials{APIKey: "key", Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = c.PlaceOrder(ctx, NewOrderRequest{Symbol: "BTCUSDT", Side: SideBuy, Type: OrderTypeMarket, Quantity: "0.01"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != 429 || apiErr.Backoff < 29*time.Second || apiErr.Backoff > 30*time.Second {
+		t.Fatalf("expected the rate limit as an APIError carrying its backoff, got %v", err)
+	}
+	if wait := DefaultWeightTracker.Reserve(1, time.Now()); wait < 29*time.Second {
+		t.Errorf("expected the rate limit to hold up further requests, got %s", wait)
+	}
+
+	// Start from a fresh budget rather than wait out the rate limit
+	DefaultWeightTracker = NewWeightTracker(DefaultRESTWeightLimit)
+	c.tracker = DefaultWeightTracker
+	_, err = c.CancelOrder(ctx, OrderRef{Symbol: "BTCUSDT", OrderID: 1})
+	if !errors.Is(err, ErrIPBanned) || !errors.As(err, &apiErr) || apiErr.Backoff < 119*time.Second {
+		t.Errorf("expected the ban as ErrIPBanned and an APIError carrying its backoff, got %v", err)
+	}
+}
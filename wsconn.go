@@ -0,0 +1,218 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// PingInterval is how often a ping frame is sent on every market data connection. The exchange answers with a pong,
+// which keeps the liveness watchdog fed on quiet streams of markets that rarely ping themselves (USD-M futures ping
+// every 3 minutes). Zero disables client pings. Run sets it from Config.PingInterval.
+var PingInterval = 30 * time.Second
+
+// ConnectionLifetime is how long a connection is used before a planned reconnect replaces it, ahead of the exchange
+// closing every connection after 24 hours. The replacement is connected and subscribed before the old connection is
+// closed, so no messages are lost. Zero disables planned reconnects. Run sets it from Config.ConnectionLifetime.
+var ConnectionLifetime = 23 * time.Hour
+
+// HandoverTimeout bounds how long a planned reconnect reads both connections while looking for the first message
+// the new connection has in common with the old one. If none turns up, the new connection takes over regardless.
+var HandoverTimeout = 10 * time.Second
+
+// controlWriteTimeout bounds writing a ping or pong frame.
+const controlWriteTimeout = 5 * time.Second
+
+// wsConn is one dialled market data connection, read by its own goroutine.
+type wsConn struct {
+	url     string
+	conn    *websocket.Conn
+	session WSSession
+	reads   chan readResult
+	// stop releases the read goroutine if the connection is abandoned while it is handing over a message
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	// pending is set if the connection subscribes with SUBSCRIBE requests; subscribed receives the outcome of the
+	// initial one, which ackTimer bounds
+	pending    *PendingRequests
+	subscribed <-chan error
+	ackTimer   Timer
+}
+
+// dialWSConn connects to url, sends a SUBSCRIBE request for streams if there are any and starts reading. If requests
+// is set, the connection is prepared for further requests even without initial streams. Ping frames are answered
+// with pongs; touch is called on every ping and pong received, which the read loop does not see.
+func dialWSConn(ctx context.Context, url, stream string, streams []string, requests bool, touch func()) (*wsConn, error) {
+	recordConnectAttempt(stream)
+	conn, _, err := streamDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		recordDisconnect(stream, err)
+		return nil, fmt.Errorf("failed to dial websocket %s: %w", url, err)
+	}
+	session := recordConnect(stream)
+	recordEndpoint(stream, endpointFromURL(url), conn.RemoteAddr().String(), session)
+	log.Printf("Successfully connected to %s (%s), session %s generation %d", url, conn.RemoteAddr(), session.ID, session.Generation)
+
+	conn.SetPingHandler(func(data string) error {
+		touch()
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(controlWriteTimeout))
+		if err != nil && !errors.Is(err, websocket.ErrCloseSent) {
+			return err
+		}
+		return nil
+	})
+	conn.SetPongHandler(func(string) error {
+		touch()
+		return nil
+	})
+
+	c := &wsConn{url: url, conn: conn, session: session, reads: make(chan readResult), stop: make(chan struct{})}
+	if requests || len(streams) > 0 {
+		c.pending = NewPendingRequests()
+	}
+	if len(streams) > 0 {
+		req, done := c.pending.New("SUBSCRIBE", streams, NowFunc())
+		if err := conn.WriteJSON(req); err != nil {
+			conn.Close()
+			recordDisconnect(stream, err)
+			return nil, fmt.Errorf("failed to subscribe to %v: %w", streams, err)
+		}
+		c.subscribed, c.ackTimer = done, DefaultClock.NewTimer(AckTimeout)
+	}
+	go c.read()
+	return c, nil
+}
+
+// read passes every message read from the connection to reads until a read fails or the connection is closed.
+func (c *wsConn) read() {
+	defer close(c.reads)
+	for {
+		// Blocking read with no deadline; closing the connection unblocks it
+		mt, msg, err := safeReadMessage(c.conn)
+		select {
+		case c.reads <- readResult{mt: mt, msg: msg, err: err}:
+		case <-c.stop:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// request sends a control request on the connection and delivers the exchange's answer, ErrAckTimeout or the
+// write error to result.
+func (c *wsConn) request(method string, params []string, result chan<- error) {
+	req, done := c.pending.New(method, params, NowFunc())
+	if err := c.conn.WriteJSON(req); err != nil {
+		// The read goroutine surfaces the broken connection
+		result <- fmt.Errorf("failed to send %s %v: %w", method, params, err)
+		return
+	}
+	go func() {
+		timer := DefaultClock.NewTimer(AckTimeout)
+		defer timer.Stop()
+		select {
+		case err := <-done:
+			result <- err
+		case <-timer.C():
+			c.pending.Expire(NowFunc())
+			result <- <-done
+		}
+	}()
+}
+
+// ping sends a ping frame, which the exchange answers with a pong.
+func (c *wsConn) ping() {
+	if c == nil {
+		return
+	}
+	if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(controlWriteTimeout)); err != nil {
+		log.Printf("Failed to ping %s: %v", c.url, err)
+	}
+}
+
+// acknowledged marks the initial subscription as settled.
+func (c *wsConn) acknowledged() {
+	c.subscribed = nil
+	if c.ackTimer != nil {
+		c.ackTimer.Stop()
+		c.ackTimer = nil
+	}
+}
+
+// close closes the connection and releases its read goroutine. It may be called on a nil connection.
+func (c *wsConn) close() {
+	if c == nil {
+		return
+	}
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		c.acknowledged()
+		c.conn.Close()
+	})
+}
+
+// The channel accessors return nil for a nil connection, disabling their select cases.
+
+func (c *wsConn) readC() <-chan readResult {
+	if c == nil {
+		return nil
+	}
+	return c.reads
+}
+
+func (c *wsConn) subscribedC() <-chan error {
+	if c == nil {
+		return nil
+	}
+	return c.subscribed
+}
+
+func (c *wsConn) ackC() <-chan time.Time {
+	if c == nil || c.ackTimer == nil {
+		return nil
+	}
+	return c.ackTimer.C()
+}
+
+// handover tracks a planned reconnect while both connections are read. Messages are matched on their raw bytes: the
+// old connection keeps delivering until it delivers the message the new one started with, or the new one receives a
+// message the old one already delivered, and from then on only the new connection is used.
+type handover struct {
+	// delivered holds the messages the old connection delivered since the handover started
+	delivered map[string]bool
+	// buffered holds the new connection's messages that the old one has not delivered yet, in order
+	buffered [][]byte
+	timer    Timer
+}
+
+func newHandover() *handover {
+	return &handover{delivered: make(map[string]bool), timer: DefaultClock.NewTimer(HandoverTimeout)}
+}
+
+// fromOld records a message delivered by the old connection and reports whether the new connection has caught up
+// with it, in which case the buffered messages after it are to be delivered from the new connection.
+func (h *handover) fromOld(msg []byte) (caughtUp bool, rest [][]byte) {
+	if len(h.buffered) > 0 && string(h.buffered[0]) == string(msg) {
+		return true, h.buffered[1:]
+	}
+	h.delivered[string(msg)] = true
+	return false, nil
+}
+
+// fromNew records a message received on the new connection and reports whether the old connection already
+// delivered it, in which case the new connection takes over with the buffered messages.
+func (h *handover) fromNew(msg []byte) (caughtUp bool, rest [][]byte) {
+	if h.delivered[string(msg)] {
+		return true, h.buffered
+	}
+	h.buffered = append(h.buffered, msg)
+	return false, nil
+}
@@ -0,0 +1,149 @@
+package gobinapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gobinapi_o3/internal/mockbinance"
+)
+
+// setWSTimings overrides the connection timings for the test's duration.
+func setWSTimings(t *testing.T, idle, ping, lifetime time.Duration) {
+	t.Helper()
+	oldIdle, oldPing, oldLifetime := StreamIdleTimeout, PingInterval, ConnectionLifetime
+	StreamIdleTimeout, PingInterval, ConnectionLifetime = idle, ping, lifetime
+	t.Cleanup(func() { StreamIdleTimeout, PingInterval, ConnectionLifetime = oldIdle, oldPing, oldLifetime })
+}
+
+func TestListenTrade_PlannedReconnectHandsOverWithoutGaps(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade")
+	setWSTimings(t, time.Minute, 0, 40*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	trades := make(chan Trade, 1000)
+	done := make(chan error, 1)
+	go func() { done <- ListenTrade(ctx, "BTCUSDT", trades) }()
+	waitForSubscribers(t, srv, "btcusdt@trade", 1)
+
+	// A live feed: every connection open at the time receives the trade
+	const n = 60
+	for id := int64(1); id <= n; id++ {
+		srv.Publish("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", id, "100.0", "1"))
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	sessions := make(map[string]bool)
+	for want := int64(1); want <= n; want++ {
+		select {
+		case trade := <-trades:
+			if trade.TradeID != want {
+				t.Fatalf("expected trade %d, got %d: planned reconnects must neither drop nor repeat messages", want, trade.TradeID)
+			}
+			sessions[trade.ConnID] = true
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for trade %d", want)
+		}
+	}
+	cancel()
+	<-done
+	if len(sessions) < 2 {
+		t.Errorf("expected trades from more than one connection, got sessions %v", sessions)
+	}
+	if srv.Connections("btcusdt@trade") < 2 {
+		t.Errorf("expected planned reconnects, got %d connections", srv.Connections("btcusdt@trade"))
+	}
+}
+
+func TestListenTrade_PingsKeepQuietConnectionAlive(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade")
+	// Without pongs the watchdog would close the connection after 100ms
+	setWSTimings(t, 100*time.Millisecond, 20*time.Millisecond, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+	err := ListenTrade(ctx, "BTCUSDT", make(chan Trade))
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the quiet connection to stay up until the deadline, got %v", err)
+	}
+}
+
+func TestListenTrade_UnansweredPingsStall(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade")
+	srv.SetIgnorePings(true)
+	setWSTimings(t, 100*time.Millisecond, 20*time.Millisecond, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ListenTrade(ctx, "BTCUSDT", make(chan Trade)); !errors.Is(err, ErrStreamStalled) {
+		t.Errorf("expected a connection whose pings go unanswered to stall, got %v", err)
+	}
+}
+
+func TestListenTrade_ForcedDisconnectEndsListener(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade")
+	srv.SetPingInterval(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	trades := make(chan Trade, 1)
+	done := make(chan error, 1)
+	go func() { done <- ListenTrade(ctx, "BTCUSDT", trades) }()
+	waitForSubscribers(t, srv, "btcusdt@trade", 1)
+	srv.Publish("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 1, "100.0", "1"))
+	select {
+	case trade := <-trades:
+		if trade.TradeID != 1 {
+			t.Errorf("expected trade 1, got %d", trade.TradeID)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the published trade")
+	}
+	// The listener answers the server's pings
+	for srv.Pongs("btcusdt@trade") == 0 {
+		if ctx.Err() != nil {
+			t.Fatal("timed out waiting for a pong")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	srv.Disconnect("btcusdt@trade")
+	if err := <-done; err == nil || ctx.Err() != nil {
+		t.Errorf("expected the dropped connection to end the listener with an error, got %v", err)
+	}
+}
+
+func TestTradeHandler_CombinedStream(t *testing.T) {
+	srv := useMockServer(t)
+	srv.SetStream("btcusdt@trade", mockbinance.TradeMessage("BTCUSDT", 1, "100.0", "1"))
+	srv.SetStream("ethusdt@trade", mockbinance.TradeMessage("ETHUSDT", 2, "3000.0", "1"))
+	srv.SetCloseAfterFrames(true)
+
+	url := srv.WSURL() + "/stream?streams=btcusdt@trade/ethusdt@trade"
+	trades := make(chan Trade, 2)
+	listenWebSocket(context.Background(), url, tradeHandler(url, trades))
+	if len(trades) != 2 {
+		t.Fatalf("expected a trade from each stream, got %d", len(trades))
+	}
+	if first, second := <-trades, <-trades; first.TradeID != 1 || second.TradeID != 2 {
+		t.Errorf("expected trades 1 and 2 in stream order, got %d and %d", first.TradeID, second.TradeID)
+	}
+}
+
+// waitForSubscribers blocks until n connections to stream receive the mock server's published frames.
+func waitForSubscribers(t *testing.T, srv *mockbinance.Server, stream string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.Subscribers(stream) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d connections to %s", n, stream)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
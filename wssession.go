@@ -0,0 +1,25 @@
+package gobinapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// WSSession identifies one WebSocket connection. ID is unique per connection; Generation counts the stream's
+// successful connects in this process, starting at 1, so a jump marks a reconnect. Both are stamped into every
+// record received on the connection (ConnID and ConnGeneration), so anomalies in the data can be traced back to the
+// connection and reconnect that produced them.
+type WSSession struct {
+	ID         string
+	Generation int64
+}
+
+// NewConnID returns a new WebSocket session ID. Tests replace it for deterministic output.
+var NewConnID = func() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms; an empty ID only loses correlation
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}